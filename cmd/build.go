@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,7 +10,6 @@ import (
 
 	"alif-cli/internal/builder"
 	"alif-cli/internal/color"
-	"alif-cli/internal/config"
 	"alif-cli/internal/project"
 	"alif-cli/internal/signer"
 	"alif-cli/internal/ui"
@@ -21,6 +21,16 @@ import (
 var buildProject string
 var buildSign bool
 var buildClean bool
+var buildCompress string
+var buildAll bool
+var buildTarget string
+var buildType string
+var buildJobs int
+var buildForce bool
+var buildReports []string
+var buildJSON bool
+var buildWatch bool
+var buildAndFlash bool
 
 var buildCmd = &cobra.Command{
 	Use:   "build [solution_path]",
@@ -28,10 +38,31 @@ var buildCmd = &cobra.Command{
 	Long: `Compiles the source code using cbuild.
 	
 If solution_path is not specified, uses the current directory.
-The --project (-p) flag filters the build context.
+The --project (-p), --target (-t) and --type flags filter the build context;
+when together they leave one context it is built without asking.
 The --clean flag forces a rebuild (clean then build).
   
-By default, this command compiles the code. To create a bootable image immediately, use the --sign (-s) flag.`,
+By default, this command compiles the code. To create a bootable image immediately, use the --sign (-s) flag.
+
+The --all flag builds every context of the solution, one cbuild run per
+context (optionally narrowed by --target and --type), and prints a summary
+of all results. It cannot be combined with --project.
+
+--report sarif=<file> and --report junit=<file> write the compiler errors
+and warnings as SARIF 2.1.0 or JUnit XML, whether or not the build succeeds.
+Paths inside the solution are written relative to it. Repeat --report to
+write several reports.
+
+--json prints one object on stdout when the build ends: the context, status,
+duration, the bin, elf and map paths, the memory usage and, on failure, the
+error and the first error lines of the cbuild output. Everything else is
+written to stderr.
+
+--watch builds the selected context, then watches the solution's folders
+(not out/, tmp/, .git, packs or the context's output folder) and rebuilds it
+shortly after each change, printing one line per build. --and-flash also
+creates the image and flashes it over ISP on the project's remembered port
+after each successful build. Ctrl-C stops the watch and any running cbuild.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		solutionPath := ""
 		if len(args) > 0 {
@@ -45,64 +76,175 @@ func init() {
 	buildCmd.Flags().StringVarP(&buildProject, "project", "p", "", "Project name or context filter (e.g. 'blinky' or 'blinky.debug')")
 	buildCmd.Flags().BoolVarP(&buildSign, "sign", "s", false, "Create bootable image (package/sign) after building")
 	buildCmd.Flags().BoolVar(&buildClean, "clean", false, "Clean artifacts and rebuild (full rebuild)")
+	addCompressFlag(buildCmd, &buildCompress)
+	buildCmd.Flags().BoolVar(&buildAll, "all", false, "Build every context in the solution")
+	buildCmd.Flags().StringVarP(&buildTarget, "target", "t", "", "Target filter, matched against the +<target> suffix of contexts (e.g. 'E7-HE')")
+	buildCmd.Flags().StringVar(&buildType, "type", "", "Build type filter (e.g. 'debug')")
+	buildCmd.Flags().BoolVar(&buildForce, "force", false, "Create the image even when the binary cannot be attributed to the context")
+	buildCmd.Flags().IntVarP(&buildJobs, "jobs", "j", 1, "Number of contexts to build in parallel with --all")
+	buildCmd.Flags().StringArrayVar(&buildReports, "report", nil, "Write compiler diagnostics as sarif=<file> or junit=<file> (repeatable)")
+	buildCmd.Flags().BoolVar(&buildJSON, "json", false, "Print the result as one JSON object on stdout; progress goes to stderr")
+	buildCmd.Flags().BoolVar(&buildWatch, "watch", false, "Rebuild the selected context whenever a source file changes, until Ctrl-C")
+	buildCmd.Flags().BoolVar(&buildAndFlash, "and-flash", false, "With --watch, create the image and flash it over ISP after each successful build")
 	rootCmd.AddCommand(buildCmd)
 }
 
+// maxBuildErrorLines is how many cbuild error lines --json reports.
+const maxBuildErrorLines = 20
+
+// buildResult is the object `alif build --json` prints on stdout.
+type buildResult struct {
+	Context    string               `json:"context,omitempty"`
+	Status     string               `json:"status"`
+	DurationMS int64                `json:"duration_ms"`
+	Bin        string               `json:"bin,omitempty"`
+	Elf        string               `json:"elf,omitempty"`
+	Map        string               `json:"map,omitempty"`
+	Image      string               `json:"image,omitempty"`
+	TOC        string               `json:"toc,omitempty"`
+	Size       *builder.MemoryUsage `json:"size,omitempty"`
+	Error      string               `json:"error,omitempty"`
+	ErrorLines []string             `json:"error_lines,omitempty"`
+}
+
+// finishBuild prints res with --json, stamped with the duration since start
+// and status.
+func finishBuild(res *buildResult, start time.Time, status string) {
+	if !buildJSON {
+		return
+	}
+	res.Status = status
+	res.DurationMS = time.Since(start).Milliseconds()
+	data, _ := json.MarshalIndent(res, "", "  ")
+	fmt.Println(string(data))
+}
+
+// failBuild reports msg, prints res as failed with --json and exits with
+// status 1.
+func failBuild(res *buildResult, start time.Time, msg string) {
+	ui.Error(msg)
+	res.Error = msg
+	finishBuild(res, start, "failed")
+	os.Exit(1)
+}
+
 func runBuild(solutionPath string) {
 	start := time.Now()
+	res := &buildResult{}
+	if buildJSON {
+		ui.UseStderr()
+		if buildAll {
+			failBuild(res, start, "--json builds one context; it cannot be combined with --all")
+		}
+	}
+
+	if buildAll && buildProject != "" {
+		failBuild(res, start, "--all builds every context; it cannot be combined with -p (narrow it with -t or --type)")
+	}
+	if buildAndFlash && !buildWatch {
+		failBuild(res, start, "--and-flash only works with --watch")
+	}
+	if buildWatch && (buildAll || buildJSON || buildSign || len(buildReports) > 0) {
+		failBuild(res, start, "--watch cannot be combined with --all, --json, --sign or --report")
+	}
 
 	// 1. Validate Solution
 	solDir, err := project.IsSolutionRoot(solutionPath)
 	if err != nil {
-		ui.Error(fmt.Sprintf("%v", err))
-		os.Exit(1)
+		failBuild(res, start, fmt.Sprintf("%v", err))
 	}
 
-	cfg, _ := config.LoadConfig()
-	if cfg == nil || cfg.AlifToolsPath == "" {
-		ui.Error("Alif CLI not configured. Run 'alif setup' first.")
-		os.Exit(1)
+	for _, spec := range buildReports {
+		if _, _, err := builder.ParseReportSpec(spec); err != nil {
+			failBuild(res, start, fmt.Sprintf("%v", err))
+		}
+	}
+
+	cfg := requireConfig()
+
+	if buildWatch {
+		runBuildWatch(solDir, cfg)
+		return
+	}
+
+	if buildAll {
+		ops := []string{opBuild}
+		if buildSign {
+			ops = append(ops, opImage)
+		}
+		ok := runWorkspace(solDir, cfg, workspaceOptions{
+			Target:    buildTarget,
+			BuildType: buildType,
+			Ops:       ops,
+			Clean:     buildClean,
+			Jobs:      buildJobs,
+			Compress:  buildCompress,
+			Reports:   buildReports,
+		})
+		ui.Item("Duration", time.Since(start).Round(time.Millisecond).String())
+		if !ok {
+			os.Exit(1)
+		}
+		return
 	}
 
 	// 2. Build
 	b := builder.New(cfg)
 	// Pass clean flag to trigger --rebuild if requested
-	selectedContext, err := b.Build(solDir, "", buildProject, buildClean)
+	selectedContext, err := b.Build(solDir, buildTarget, buildProject, buildType, buildClean)
+	res.Context = selectedContext
+	report := builder.BuildReport{Failed: err != nil, Diagnostics: b.Diagnostics}
+	if selectedContext != "" {
+		report.Contexts = []string{selectedContext}
+	}
+	writeBuildReports(buildReports, report)
 	if err != nil {
-		ui.Error("Build process failed.")
-		os.Exit(1)
+		res.ErrorLines = builder.ErrorLines(b.Output, maxBuildErrorLines)
+		failBuild(res, start, fmt.Sprintf("Build process failed: %v", err))
 	}
 
-	// Determine Artifact Path (Only if single context selected)
-	if selectedContext == "" {
-		ui.Header("Process Complete")
-		ui.Item("Duration", time.Since(start).Round(time.Millisecond).String())
-		ui.Success("Clean & Rebuild of all contexts completed successfully.")
-		return
+	binPath, elfPath, errArt := b.ResolveArtifacts(solDir, selectedContext)
+	if errArt == nil {
+		res.Bin, res.Elf = binPath, elfPath
+	}
+	res.Size, res.Map = contextMemoryUsage(b, solDir, selectedContext)
+	if res.Size != nil {
+		printMemoryUsage(res.Size)
 	}
-
-	binPath := b.GetArtifactPath(solDir, selectedContext)
 
 	if !buildSign {
 		// Summary
 		ui.Header("Build Summary")
 		ui.Item("Context", selectedContext)
-		ui.Item("Artifact", binPath)
+		if errArt != nil {
+			ui.Warn(fmt.Sprintf("Artifact unknown: %v", errArt))
+		} else {
+			ui.Item("Artifact", binPath)
+		}
 		ui.Item("Duration", time.Since(start).Round(time.Millisecond).String())
 
-		fmt.Println()
-		ui.Info(fmt.Sprintf("To flash this project, run: %s", color.Sprintf(color.BoldCyan, "alif flash -p %s", selectedContext)))
+		if !buildJSON {
+			fmt.Println()
+			ui.Info(fmt.Sprintf("To flash this project, run: %s", color.Sprintf(color.BoldCyan, "alif flash -p %s", selectedContext)))
+		}
+		finishBuild(res, start, "success")
 		return
 	}
 
-	// 3. Resolve Artifacts for Signing (if path missing, try fallback)
-	if _, err := os.Stat(binPath); os.IsNotExist(err) {
-		ui.Info("Scanning out/ directory for most recent binary...")
-		binPath = findRecentBin(solDir)
+	// 3. Resolve Artifacts for Signing
+	if errArt != nil {
+		failBuild(res, start, fmt.Sprintf("Could not locate built binary: %v", errArt))
+	}
+	if _, err := os.Stat(binPath); err != nil {
+		if elfPath != "" {
+			ui.Info(fmt.Sprintf("To create an image, %s.", addBinOutputHint))
+		}
+		failBuild(res, start, fmt.Sprintf("Built binary %s not found.", binPath))
 	}
 
-	if binPath == "" {
-		ui.Error("Could not locate built binary.")
+	if !checkSharedOutDir(solDir, selectedContext, buildForce) {
+		res.Error = "the binary cannot be attributed to the context; its output directory is shared"
+		finishBuild(res, start, "failed")
 		os.Exit(1)
 	}
 
@@ -114,33 +256,107 @@ func runBuild(solutionPath string) {
 
 	signBuildDir := filepath.Dir(binPath)
 	s := signer.New(cfg)
-	_, errSign := s.SignArtifact(solDir, signBuildDir, binPath, targetCore, buildProject, "")
+	s.Compression = buildCompress
+	images, errSign := s.SignArtifact(solDir, signBuildDir, binPath, targetCore, buildProject, "")
 	if errSign != nil {
-		ui.Error(fmt.Sprintf("Image creation failed: %v", errSign))
-		os.Exit(1)
+		failBuild(res, start, fmt.Sprintf("Image creation failed: %v", errSign))
 	}
+	res.Image, res.TOC = images.Image, images.TOC
 
 	// Final Summary
 	ui.Header("Process Complete")
 	ui.Item("Context", selectedContext)
-	ui.Item("Image", filepath.Join(signBuildDir, "AppTocPackage.bin"))
+	ui.Item("Image", res.TOC)
 	ui.Item("Duration", time.Since(start).Round(time.Millisecond).String())
 	ui.Success("Build and packaging completed successfully.")
+	finishBuild(res, start, "success")
+}
+
+// contextMemoryUsage returns the text, data and bss totals of the built
+// context and how full each linker region is, with the map they came from.
+// When the elf or arm-none-eabi-size cannot be found it returns nil; the
+// build does not fail for it.
+func contextMemoryUsage(b *builder.Builder, solDir, context string) (*builder.MemoryUsage, string) {
+	out, err := project.FindContextOutput(solDir, context)
+	if err != nil {
+		ui.Debug(fmt.Sprintf("Memory usage skipped: %v", err))
+		return nil, ""
+	}
+	mapPath := out.MapPath()
+	_, elfPath := out.Artifacts()
+	if elfPath == "" {
+		ui.Debug(fmt.Sprintf("Memory usage skipped: %s lists no elf output", filepath.Base(out.File)))
+		return nil, mapPath
+	}
+	usage, err := b.MemoryUsage(elfPath, mapPath)
+	if err != nil {
+		ui.Debug(fmt.Sprintf("Memory usage skipped: %v", err))
+		return nil, mapPath
+	}
+	return usage, mapPath
 }
 
-func findRecentBin(root string) string {
-	var recent string
-	var recentTime int64
-	filepath.Walk(filepath.Join(root, "out"), func(path string, info os.FileInfo, err error) error {
-		if err == nil && !info.IsDir() && strings.HasSuffix(info.Name(), ".bin") {
-			if !strings.Contains(info.Name(), "alif-img") && !strings.Contains(info.Name(), "AppTocPackage") {
-				if info.ModTime().Unix() > recentTime {
-					recentTime = info.ModTime().Unix()
-					recent = path
-				}
+// printMemoryUsage shows usage as section totals and a table of regions.
+func printMemoryUsage(usage *builder.MemoryUsage) {
+	ui.Header("Memory Usage")
+	ui.Item("Text", formatBytes(usage.Text))
+	ui.Item("Data", formatBytes(usage.Data))
+	ui.Item("BSS", formatBytes(usage.BSS))
+	if len(usage.Regions) == 0 {
+		return
+	}
+	var rows [][]string
+	for _, r := range usage.Regions {
+		use := "-"
+		if r.Size > 0 {
+			use = fmt.Sprintf("%.1f%%", float64(r.Used)*100/float64(r.Size))
+		}
+		rows = append(rows, []string{r.Name, formatBytes(r.Used), formatBytes(r.Size), use})
+	}
+	ui.Table([]string{"REGION", "USED", "SIZE", "USE"}, rows)
+}
+
+// writeBuildReports writes report once per --report spec. A report that
+// cannot be written is a warning; it does not change the build result.
+func writeBuildReports(specs []string, report builder.BuildReport) {
+	if len(specs) == 0 {
+		return
+	}
+	errs, warns := builder.CountDiagnostics(report.Diagnostics)
+	ui.Item("Diagnostics", fmt.Sprintf("%d errors, %d warnings", errs, warns))
+	for _, spec := range specs {
+		format, path, err := builder.ParseReportSpec(spec)
+		if err == nil {
+			err = builder.WriteReport(format, path, report)
+		}
+		if err != nil {
+			ui.Warn(fmt.Sprintf("Failed to write %s report: %v", format, err))
+			continue
+		}
+		ui.Item("Report", fmt.Sprintf("%s (%s)", path, format))
+	}
+}
+
+// workspaceReport combines the diagnostics of every context built by
+// --all into one report.
+func workspaceReport(contexts []string, results map[string]map[string]*opResult) builder.BuildReport {
+	report := builder.BuildReport{Contexts: contexts}
+	seen := map[builder.Diagnostic]bool{}
+	for _, c := range contexts {
+		res, ok := results[c][opBuild]
+		if !ok {
+			continue
+		}
+		if res.Err != nil {
+			report.Failed = true
+		}
+		for _, d := range res.Diagnostics {
+			// A shared source file reports the same diagnostic per context.
+			if !seen[d] {
+				seen[d] = true
+				report.Diagnostics = append(report.Diagnostics, d)
 			}
 		}
-		return nil
-	})
-	return recent
+	}
+	return report
 }
@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"alif-cli/internal/builder"
+	"alif-cli/internal/config"
+	"alif-cli/internal/flasher"
+	"alif-cli/internal/project"
+	"alif-cli/internal/signer"
+	"alif-cli/internal/toolkit"
+	"alif-cli/internal/ui"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long the watch waits after the last change before
+// it builds, so a save that touches several files starts one build.
+const watchDebounce = 300 * time.Millisecond
+
+// watchSkipDirs are folders whose changes never start a build: build
+// output, cbuild's intermediates, version control and installed packs.
+var watchSkipDirs = map[string]bool{
+	"out": true, "tmp": true, ".git": true, "packs": true, ".alif": true, "node_modules": true,
+}
+
+// watchSkipFile reports files cbuild and editors write that are not
+// sources: the generated .cbuild*.yml and .cprj files, compile_commands.json
+// and editor swap and backup files.
+func watchSkipFile(name string) bool {
+	switch {
+	case strings.Contains(name, ".cbuild") && strings.HasSuffix(name, ".yml"),
+		strings.HasSuffix(name, ".cprj"),
+		name == "compile_commands.json",
+		strings.HasSuffix(name, "~"),
+		strings.HasSuffix(name, ".swp"), strings.HasSuffix(name, ".swx"),
+		strings.HasPrefix(name, ".#"),
+		name == "4913":
+		return true
+	}
+	return false
+}
+
+// buildWatcher watches the source folders of a solution for alif build
+// --watch.
+type buildWatcher struct {
+	*fsnotify.Watcher
+	solDir string
+	// outDir is the output folder of the watched context, from its
+	// .cbuild.yml; it may lie outside out/.
+	outDir string
+}
+
+// skip reports whether path is in a skipped folder or is a skipped file.
+func (w *buildWatcher) skip(path string, isDir bool) bool {
+	if w.outDir != "" && (path == w.outDir || strings.HasPrefix(path, w.outDir+string(filepath.Separator))) {
+		return true
+	}
+	rel, err := filepath.Rel(w.solDir, path)
+	if err != nil {
+		return true
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for _, dir := range parts[:len(parts)-1] {
+		if watchSkipDirs[dir] {
+			return true
+		}
+	}
+	last := parts[len(parts)-1]
+	if isDir {
+		return watchSkipDirs[last]
+	}
+	return watchSkipFile(last)
+}
+
+// addTree watches root and every folder below it that is not skipped, and
+// returns how many were added.
+func (w *buildWatcher) addTree(root string) int {
+	count := 0
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if path != w.solDir && w.skip(path, true) {
+			return filepath.SkipDir
+		}
+		if err := w.Add(path); err != nil {
+			ui.Debug(fmt.Sprintf("Not watching %s: %v", path, err))
+			return nil
+		}
+		count++
+		return nil
+	})
+	return count
+}
+
+// runBuildWatch builds the selected context, then rebuilds it each time a
+// source file in the solution changes, until Ctrl-C. With --and-flash each
+// successful build is also turned into an image and flashed over ISP on
+// the port remembered for the project.
+func runBuildWatch(solDir string, cfg *config.Config) {
+	b := builder.New(cfg)
+	selectedContext, err := b.ResolveContext(solDir, buildTarget, buildProject, buildType)
+	if err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(exitCode(err))
+	}
+
+	var port string
+	if buildAndFlash {
+		f := flasher.New(cfg)
+		f.StateDir = filepath.Join(solDir, ".alif")
+		if port, err = f.SelectPort(); err != nil {
+			ui.Error(fmt.Sprintf("Error identifying port: %v", err))
+			os.Exit(exitCode(err))
+		}
+	}
+
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		ui.Error(fmt.Sprintf("Cannot watch files: %v", err))
+		os.Exit(1)
+	}
+	defer fw.Close()
+	w := &buildWatcher{Watcher: fw, solDir: solDir}
+	if out, err := project.FindContextOutput(solDir, selectedContext); err == nil {
+		w.outDir = out.OutDir
+	}
+	folders := w.addTree(solDir)
+
+	ui.Header("Watch")
+	ui.Item("Context", selectedContext)
+	ui.Item("Watching", fmt.Sprintf("%d folder(s) in %s", folders, solDir))
+	if port != "" {
+		ui.Item("Flash", port)
+	}
+	ui.Info("Rebuilding on every change; press Ctrl-C to stop.")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	b.Report = ui.Silent
+
+	cycle := func(trigger string, clean bool) {
+		if !watchBuild(ctx, b, solDir, selectedContext, trigger, clean) || port == "" {
+			return
+		}
+		if err := watchFlash(cfg, solDir, selectedContext, port); err != nil {
+			ui.Error(fmt.Sprintf("%v", err))
+		}
+	}
+	cycle("start", buildClean)
+
+	var changed []string
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println()
+			ui.Info("Watch stopped.")
+			return
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			ui.Warn(fmt.Sprintf("Watch error: %v", err))
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			info, statErr := os.Stat(ev.Name)
+			isDir := statErr == nil && info.IsDir()
+			if w.skip(ev.Name, isDir) {
+				continue
+			}
+			if isDir {
+				if ev.Has(fsnotify.Create) {
+					w.addTree(ev.Name)
+				}
+				continue
+			}
+			if ev.Op == fsnotify.Chmod {
+				continue
+			}
+			if rel, err := filepath.Rel(solDir, ev.Name); err == nil && !slices.Contains(changed, rel) {
+				changed = append(changed, rel)
+			}
+			debounce = time.After(watchDebounce)
+		case <-debounce:
+			debounce = nil
+			trigger := changed[0]
+			if len(changed) > 1 {
+				trigger += fmt.Sprintf(" +%d more", len(changed)-1)
+			}
+			changed = nil
+			cycle(trigger, false)
+			if out, err := project.FindContextOutput(solDir, selectedContext); err == nil {
+				w.outDir = out.OutDir
+			}
+		}
+	}
+}
+
+// watchBuild builds the context name once and prints one line for the result,
+// followed by the first error lines when it fails. It reports whether the
+// build succeeded; a build stopped by Ctrl-C counts as failed.
+func watchBuild(ctx context.Context, b *builder.Builder, solDir, name, trigger string, clean bool) bool {
+	stamp := time.Now().Format(time.TimeOnly)
+	s := ui.StartSpinner(fmt.Sprintf("[%s] Building %s (%s)...", stamp, name, trigger))
+	start := time.Now()
+	output, err := b.RunContext(ctx, solDir, name, clean)
+	elapsed := time.Since(start).Round(100 * time.Millisecond)
+	if ctx.Err() != nil {
+		s.Fail(fmt.Sprintf("[%s] Build of %s stopped", stamp, name))
+		return false
+	}
+	if err != nil {
+		s.Fail(fmt.Sprintf("[%s] %s failed after %s (%s)", stamp, name, elapsed, trigger))
+		if lines := builder.ErrorLines(output, maxBuildErrorLines); len(lines) > 0 {
+			ui.Output(strings.Join(lines, "\n"))
+		} else {
+			ui.Output(output)
+		}
+		return false
+	}
+	s.Succeed(fmt.Sprintf("[%s] %s built in %s (%s)", stamp, name, elapsed, trigger))
+	return true
+}
+
+// watchFlash creates the image of a freshly built context and flashes it
+// over ISP on port, quietly, for alif build --watch --and-flash. A context
+// that only lists an elf is converted with objcopy without asking.
+func watchFlash(cfg *config.Config, solDir, name, port string) error {
+	art, err := contextArtifacts(solDir, name, buildProject)
+	if err != nil {
+		return err
+	}
+	if err := projectBinary(cfg, art, false); err != nil {
+		return err
+	}
+	release, err := toolkit.Lock(cfg.AlifToolsPath, toolkit.LockTimeout, ui.Silent)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	sp := ui.StartSpinner(fmt.Sprintf("Flashing %s...", filepath.Base(art.binPath)))
+	f := flasher.New(cfg)
+	f.Report = ui.Silent
+	f.StateDir = filepath.Join(solDir, ".alif")
+	f.Port = port
+	releasePort, err := f.AcquirePort(port)
+	if err != nil {
+		sp.Fail("Port busy")
+		return err
+	}
+	defer releasePort()
+	if err := f.UpdateISPConfig(port); err != nil {
+		sp.Fail("ISP config not updated")
+		return err
+	}
+
+	s := signer.New(cfg)
+	s.Report = ui.Silent
+	s.StateDir = f.StateDir
+	s.Compression = buildCompress
+	images, err := s.SignArtifact(solDir, art.binDir, art.binPath, art.coreHint, art.projectHint, "")
+	if err != nil {
+		sp.Fail("Image creation failed")
+		return err
+	}
+	f.TargetConfigPath = images.Config
+	if err := f.Flash(images.Image, images.TOC, flasher.Options{Method: "ISP", Port: port, Target: art.targetCore}); err != nil {
+		sp.Fail("Flash failed")
+		return err
+	}
+	sp.Succeed(fmt.Sprintf("[%s] Flashed %s on %s", time.Now().Format(time.TimeOnly), filepath.Base(images.Image), port))
+	if err := f.RememberPort(port); err != nil {
+		ui.Debug(fmt.Sprintf("Port not remembered: %v", err))
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"alif-cli/internal/color"
+	"alif-cli/internal/project"
+	"alif-cli/internal/signer"
+	"alif-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+// Hook phases accepted by `alif cmsis-hook`.
+const (
+	hookPhaseImage = "image"
+	hookPhaseFlash = "flash"
+)
+
+var cmsisHookCmd = &cobra.Command{
+	Use:    "cmsis-hook <image|flash> [args...]",
+	Short:  "Entry point for IDE post-build and run commands",
+	Hidden: true,
+	Long: `Runs the image or flash step for a build context, taking the context the
+way Keil Studio and the CMSIS VS Code extension pass it. Output is plain
+text and prompts are disabled.
+
+The context is taken from, in order:
+  --context <ctx>               e.g. blinky.debug+E7-HE
+  CMSIS_BUILD_CONTEXT           environment variable, same format
+  <name>.cbuild.yml             positional, e.g. $Bname$.cbuild.yml
+  --output <file>, -o <file>    or a positional output file: the context is
+                                derived from its out/<project>/<target>/<build-type>
+                                folder, e.g. $Output$ or $OutDir()$/$Bname$.elf
+
+The solution is the folder holding the .csolution.yml: --solution <dir or
+file>, else the nearest one above the output file, else the current folder.
+Other arguments are ignored, so IDE-specific extras do not break the hook.
+This mapping is kept stable for IDE configurations.`,
+	Args:               cobra.MinimumNArgs(1),
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if args[0] == "-h" || args[0] == "--help" {
+			cmd.Help()
+			return
+		}
+		runCmsisHook(args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cmsisHookCmd)
+}
+
+// hookRequest is an IDE invocation mapped onto alif's project pipeline.
+type hookRequest struct {
+	Phase       string
+	Context     string
+	SolutionDir string
+	Output      string
+}
+
+// parseHookArgs maps the phase, arguments and environment an IDE passes to
+// a hookRequest. It only inspects paths; it does not touch the disk.
+func parseHookArgs(args []string, getenv func(string) string) (hookRequest, error) {
+	var req hookRequest
+	if len(args) == 0 {
+		return req, fmt.Errorf("missing phase (use %s or %s)", hookPhaseImage, hookPhaseFlash)
+	}
+	req.Phase = strings.ToLower(args[0])
+	if req.Phase != hookPhaseImage && req.Phase != hookPhaseFlash {
+		return req, fmt.Errorf("unknown phase '%s' (use %s or %s)", args[0], hookPhaseImage, hookPhaseFlash)
+	}
+
+	var cbuildContext string
+	rest := args[1:]
+	for i := 0; i < len(rest); i++ {
+		arg := rest[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch name {
+		case "--context", "--solution", "--output", "-o":
+			if !hasValue {
+				if i+1 >= len(rest) {
+					return req, fmt.Errorf("%s needs a value", name)
+				}
+				i++
+				value = rest[i]
+			}
+			switch name {
+			case "--context":
+				req.Context = value
+			case "--solution":
+				req.SolutionDir = value
+			default:
+				req.Output = value
+			}
+			continue
+		}
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		switch {
+		case strings.HasSuffix(arg, ".cbuild.yml"):
+			cbuildContext = strings.TrimSuffix(filepath.Base(arg), ".cbuild.yml")
+		case strings.HasSuffix(arg, ".csolution.yml"):
+			req.SolutionDir = arg
+		case req.Output == "":
+			req.Output = arg
+		}
+	}
+
+	if strings.HasSuffix(req.SolutionDir, ".csolution.yml") {
+		req.SolutionDir = filepath.Dir(req.SolutionDir)
+	}
+	if req.Context == "" {
+		req.Context = getenv("CMSIS_BUILD_CONTEXT")
+	}
+	if req.Context == "" {
+		req.Context = cbuildContext
+	}
+	if req.Context == "" && req.Output != "" {
+		req.Context = contextFromOutput(req.Output)
+	}
+	if req.Context == "" {
+		return req, fmt.Errorf("no build context: pass --context <project.build-type+target> or set CMSIS_BUILD_CONTEXT")
+	}
+	return req, nil
+}
+
+// contextFromOutput derives "project.build-type+target" from a file in the
+// default csolution output layout out/<project>/<target>/<build-type>/.
+func contextFromOutput(output string) string {
+	dir := filepath.Dir(filepath.Clean(output))
+	buildType := filepath.Base(dir)
+	target := filepath.Base(filepath.Dir(dir))
+	proj := filepath.Base(filepath.Dir(filepath.Dir(dir)))
+	out := filepath.Base(filepath.Dir(filepath.Dir(filepath.Dir(dir))))
+	if out != "out" || proj == "." || target == "." || buildType == "." {
+		return ""
+	}
+	return fmt.Sprintf("%s.%s+%s", proj, buildType, target)
+}
+
+// findSolutionDir returns the nearest folder at or above start holding a
+// .csolution.yml.
+func findSolutionDir(start string) (string, error) {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return "", err
+	}
+	for {
+		if sol, err := project.IsSolutionRoot(dir); err == nil {
+			return sol, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no .csolution.yml found at or above %s", start)
+		}
+		dir = parent
+	}
+}
+
+func runCmsisHook(args []string) {
+	color.DisableColors()
+	ui.SetNonInteractive(true)
+
+	req, err := parseHookArgs(args, os.Getenv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "alif cmsis-hook: error: %v\n", err)
+		os.Exit(1)
+	}
+
+	start := req.SolutionDir
+	if start == "" && req.Output != "" {
+		start = filepath.Dir(req.Output)
+	}
+	if start == "" {
+		start = "."
+	}
+	solDir, err := findSolutionDir(start)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "alif cmsis-hook: error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.Chdir(solDir); err != nil {
+		fmt.Fprintf(os.Stderr, "alif cmsis-hook: error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("alif cmsis-hook: %s %s (solution %s)\n", req.Phase, req.Context, solDir)
+
+	flashProject = req.Context
+	if req.Phase == hookPhaseFlash {
+		runFlash("")
+		return
+	}
+
+	cfg := requireConfig()
+	art, err := resolveProjectArtifacts(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "alif cmsis-hook: error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	images, err := signer.New(cfg).SignArtifact(art.solDir, art.binDir, art.binPath, art.coreHint, art.projectHint, "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "alif cmsis-hook: error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	fmt.Printf("alif cmsis-hook: image %s, TOC %s\n", images.Image, images.TOC)
+}
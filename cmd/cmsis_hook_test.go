@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseHookArgs(t *testing.T) {
+	const ctx = "blinky.debug+E7-HE"
+	output := filepath.Join("sol", "out", "blinky", "E7-HE", "debug", "blinky.elf")
+	tests := []struct {
+		name      string
+		args      []string
+		env       string // CMSIS_BUILD_CONTEXT
+		want      hookRequest
+		errSubstr string
+	}{
+		{"context flag", []string{"image", "--context", ctx}, "", hookRequest{Phase: "image", Context: ctx}, ""},
+		{"context flag with =", []string{"flash", "--context=" + ctx}, "", hookRequest{Phase: "flash", Context: ctx}, ""},
+		{"phase ignores case", []string{"Flash", "--context", ctx}, "", hookRequest{Phase: "flash", Context: ctx}, ""},
+		{"environment", []string{"image"}, ctx, hookRequest{Phase: "image", Context: ctx}, ""},
+		{"flag over environment", []string{"image", "--context", "blinky.release+E7-HE"}, ctx, hookRequest{Phase: "image", Context: "blinky.release+E7-HE"}, ""},
+		{"environment over cbuild file", []string{"image", "blinky.release+E7-HE.cbuild.yml"}, ctx, hookRequest{Phase: "image", Context: ctx}, ""},
+		{"cbuild file", []string{"image", filepath.Join("tmp", ctx+".cbuild.yml")}, "", hookRequest{Phase: "image", Context: ctx}, ""},
+		{"output flag", []string{"flash", "-o", output}, "", hookRequest{Phase: "flash", Context: ctx, Output: output}, ""},
+		{"positional output", []string{"flash", output}, "", hookRequest{Phase: "flash", Context: ctx, Output: output}, ""},
+		{"solution file", []string{"image", "--solution", filepath.Join("sol", "blinky.csolution.yml"), "--context", ctx}, "", hookRequest{Phase: "image", Context: ctx, SolutionDir: "sol"}, ""},
+		{"positional solution file", []string{"image", filepath.Join("sol", "blinky.csolution.yml")}, ctx, hookRequest{Phase: "image", Context: ctx, SolutionDir: "sol"}, ""},
+		{"unknown flags ignored", []string{"flash", "--toolchain", "--verbose", "--context", ctx}, "", hookRequest{Phase: "flash", Context: ctx}, ""},
+		{"output outside the out folder", []string{"flash", filepath.Join("build", "blinky.elf")}, "", hookRequest{}, "no build context"},
+		{"no context", []string{"image"}, "", hookRequest{}, "no build context"},
+		{"flag without value", []string{"image", "--context"}, "", hookRequest{}, "--context needs a value"},
+		{"unknown phase", []string{"debug", "--context", ctx}, "", hookRequest{}, "unknown phase 'debug'"},
+		{"no phase", nil, "", hookRequest{}, "missing phase"},
+	}
+	for _, tt := range tests {
+		getenv := func(key string) string {
+			if key == "CMSIS_BUILD_CONTEXT" {
+				return tt.env
+			}
+			return ""
+		}
+		got, err := parseHookArgs(tt.args, getenv)
+		switch {
+		case tt.errSubstr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.errSubstr != "" && (err == nil || !strings.Contains(err.Error(), tt.errSubstr)):
+			t.Errorf("%s: error = %v, want one mentioning %q", tt.name, err, tt.errSubstr)
+		case tt.errSubstr == "" && got != tt.want:
+			t.Errorf("%s: parseHookArgs = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestContextFromOutput(t *testing.T) {
+	tests := []struct {
+		output, want string
+	}{
+		{"out/blinky/E7-HE/debug/blinky.elf", "blinky.debug+E7-HE"},
+		{"/work/sol/out/hello/E7-HP/release/hello.bin", "hello.release+E7-HP"},
+		{"out/blinky/E7-HE/blinky.elf", ""},
+		{"build/blinky/E7-HE/debug/blinky.elf", ""},
+		{"blinky.elf", ""},
+	}
+	for _, tt := range tests {
+		if got := contextFromOutput(filepath.FromSlash(tt.output)); got != tt.want {
+			t.Errorf("contextFromOutput(%q) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+// hookSolution lays out a built blinky solution with a toolkit whose
+// app-gen-toc writes the image, a configured home, and a cbuild that lists
+// the solution's contexts. It returns the solution directory.
+func hookSolution(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	home, tools, bin, sol := t.TempDir(), t.TempDir(), t.TempDir(), t.TempDir()
+	writeFiles(t, map[string]string{
+		filepath.Join(home, ".alif", "config.yaml"):    "alif_tools_path: " + tools + "\n",
+		filepath.Join(tools, "utils", "global-cfg.db"): `{"DEVICE": {"Part#": "E7 (AE722F80F55D5LS) - 5.5 MRAM / 13.5 SRAM", "Revision": "B2"}}`,
+		filepath.Join(tools, "app-gen-toc"): `#!/bin/sh
+mkdir -p build
+printf 'TOC' > build/AppTocPackage.bin
+printf '0x80000000  0x00000010  alif-img.bin\n' > build/app-package-map.txt
+`,
+		filepath.Join(bin, "cbuild"):               "#!/bin/sh\necho blinky.debug+E7-HE\necho blinky.release+E7-HE\n",
+		filepath.Join(sol, "blinky.csolution.yml"): "solution:\n  projects:\n    - project: blinky/blinky.cproject.yml\n",
+		filepath.Join(sol, "blinky", "blinky.debug+E7-HE.cbuild.yml"): `build:
+  device: Alif Semiconductor::AE722F80F55D5LS:M55_HE
+  output-dirs:
+    outdir: ../out/blinky/E7-HE/debug
+  output:
+    - type: bin
+      file: blinky.bin
+`,
+		filepath.Join(sol, "out", "blinky", "E7-HE", "debug", "blinky.bin"): "0123456789abcdef",
+		filepath.Join(sol, ".alif", "M55_HE_cfg.json"):                      `{"USER_APP": {"binary": "alif-img.bin", "mramAddress": "0x80000000", "cpu_id": "M55_HE"}}`,
+	})
+	t.Setenv("HOME", home)
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return sol
+}
+
+func TestCmsisHookImage(t *testing.T) {
+	sol := hookSolution(t)
+	outDir := filepath.Join(sol, "out", "blinky", "E7-HE", "debug")
+
+	// The CMSIS VS Code extension runs the hook from a folder of the
+	// workspace with the context in the environment.
+	t.Setenv("CMSIS_BUILD_CONTEXT", "blinky.debug+E7-HE")
+	out, code := runAlif(t, filepath.Join(sol, "blinky"), "cmsis-hook", "image", "--vscode-extra")
+	if code != 0 {
+		t.Fatalf("exit code %d, output:\n%s", code, out)
+	}
+	for _, want := range []string{
+		"alif cmsis-hook: image blinky.debug+E7-HE (solution " + sol + ")",
+		"alif cmsis-hook: image " + filepath.Join(outDir, "alif-img.bin") + ", TOC " + filepath.Join(outDir, "AppTocPackage.bin"),
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "\033[") {
+		t.Errorf("output has terminal escapes:\n%q", out)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "AppTocPackage.bin")); err != nil {
+		t.Errorf("no TOC in the output folder: %v", err)
+	}
+}
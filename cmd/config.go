@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"alif-cli/internal/config"
+	"alif-cli/internal/toolkit"
+	"alif-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Read or change Alif CLI settings",
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set a value in ~/.alif/config.yaml",
+	Long: `Sets a single configuration value. Known keys: ` + strings.Join([]string{
+		config.KeyAlifToolsPath, config.KeyCmsisToolbox, config.KeyGccToolchain,
+		config.KeyArmclang, config.KeyCmsisPackRoot, config.KeySigningKeyPath, config.KeyDefaultPort,
+		config.KeyFlashTimeout, config.KeyJLinkSerial, config.KeyJLinkPath,
+		config.KeyProbeCacheTTL,
+	}, ", ") + `.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		runConfigSet(args[0], args[1])
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configSetCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+func runConfigSet(key, value string) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		var parseErr *config.ParseError
+		if errors.As(err, &parseErr) {
+			reportConfigError(err)
+			os.Exit(1)
+		}
+		cfg = &config.Config{}
+	}
+
+	if err := cfg.Set(key, value); err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+	if err := config.SaveConfig(cfg); err != nil {
+		ui.Error(fmt.Sprintf("Error saving config: %v", err))
+		os.Exit(1)
+	}
+	ui.Success(fmt.Sprintf("%s set to: %s", key, value))
+}
+
+// requireConfig loads the configuration and exits with a specific message
+// when it is missing, unreadable, or lacks any of the required keys
+// (alif_tools_path if none are given).
+func requireConfig(required ...string) *config.Config {
+	if len(required) == 0 {
+		required = []string{config.KeyAlifToolsPath}
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		reportConfigError(err)
+		os.Exit(1)
+	}
+	applyLocalToolchain(cfg)
+
+	if missing := cfg.Missing(required...); len(missing) > 0 {
+		ui.Error(fmt.Sprintf("Alif CLI configuration is incomplete: %s not set.", strings.Join(missing, ", ")))
+		for _, key := range missing {
+			ui.Info(fmt.Sprintf("Run: alif config set %s <value>", key))
+		}
+		os.Exit(1)
+	}
+	if cfg.AlifToolsPath != "" {
+		toolkitRoots = append(toolkitRoots, cfg.AlifToolsPath)
+		useToolkitWorkDir(cfg)
+	}
+	return cfg
+}
+
+// applyLocalToolchain overrides cfg with the .alif/toolchain.yml of the
+// solution the command runs in, if it has one. A toolchain.yml that cannot
+// be read or names a missing tool stops the command, since building with
+// the global toolchain instead is what the file is there to prevent.
+func applyLocalToolchain(cfg *config.Config) {
+	local, err := config.FindLocal(".")
+	if err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+	if local == nil {
+		return
+	}
+	applied, err := local.Apply(cfg)
+	if err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		ui.Info("Fetch the vendored tools, or run 'alif setup --register-local' to rewrite the file")
+		os.Exit(1)
+	}
+	if len(applied) > 0 {
+		ui.Debug(fmt.Sprintf("%s overrides %s", local.Path, strings.Join(applied, ", ")))
+	}
+}
+
+// useToolkitWorkDir points cfg at a writable work directory when the
+// toolkit installation is read-only or --toolkit-workdir is given.
+func useToolkitWorkDir(cfg *config.Config) {
+	workDir := toolkitWorkDir
+	if workDir == "" {
+		if toolkit.Writable(cfg.AlifToolsPath) {
+			return
+		}
+		dir, err := toolkit.DefaultWorkDir(cfg.AlifToolsPath)
+		if err != nil {
+			ui.Error(fmt.Sprintf("Toolkit at %s is read-only and no work directory is available: %v", cfg.AlifToolsPath, err))
+			os.Exit(1)
+		}
+		workDir = dir
+	}
+
+	if err := toolkit.Prepare(cfg.AlifToolsPath, workDir); err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		if errors.Is(err, toolkit.ErrIncompatible) {
+			ui.Info("Install the toolkit in a writable directory, or point alif_tools_path at a writable copy.")
+		}
+		os.Exit(1)
+	}
+	ui.Info(fmt.Sprintf("Using toolkit work directory %s", workDir))
+	toolkitRoots = append(toolkitRoots, workDir)
+	cfg.AlifToolsPath = workDir
+}
+
+// lockToolkit takes the toolkit's staging lock for the rest of the command,
+// so another alif process cannot stage its image, TOC or ISP config in
+// between. Exiting releases it: a lock whose process is gone is taken over.
+func lockToolkit(cfg *config.Config) func() {
+	release, err := toolkit.Lock(cfg.AlifToolsPath, toolkit.LockTimeout, ui.Console)
+	if err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+	return release
+}
+
+// reportConfigError explains a LoadConfig failure and what to do about it.
+func reportConfigError(err error) {
+	var parseErr *config.ParseError
+	switch {
+	case errors.Is(err, config.ErrNotFound):
+		ui.Error("Alif CLI not configured. Run 'alif setup' first.")
+	case errors.As(err, &parseErr):
+		ui.Error(fmt.Sprintf("Config file %s is invalid:", parseErr.Path))
+		fmt.Println("\n" + parseErr.Err.Error() + "\n")
+		ui.Info("Fix the file, or delete it and run 'alif setup' again.")
+	default:
+		ui.Error(fmt.Sprintf("Failed to load configuration: %v", err))
+	}
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"alif-cli/internal/builder"
+	"alif-cli/internal/config"
+	"alif-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+// rawBinary returns path when it is a raw binary. An ELF or Intel HEX file
+// is converted with arm-none-eabi-objcopy first, to <name>.bin in workDir
+// (--working-dir) when set, otherwise into a temporary folder, or next to
+// the original when keep is set (--keep-bin). The returned cleanup removes
+// a temporary conversion.
+func rawBinary(cfg *config.Config, path, workDir string, keep bool) (string, func()) {
+	format, err := builder.DetectFormat(path)
+	if err != nil {
+		ui.Error(fmt.Sprintf("Cannot read %s: %v", path, err))
+		os.Exit(1)
+	}
+	if format == builder.FormatBinary {
+		if keep {
+			ui.Warn("--keep-bin only applies to .elf and .hex inputs; ignoring it")
+		}
+		return path, func() {}
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + ".bin"
+	dst := filepath.Join(filepath.Dir(path), name)
+	cleanup := func() {}
+	if workDir != "" {
+		dst = filepath.Join(workDir, name)
+	} else if !keep {
+		dir, err := os.MkdirTemp("", "alif-bin-")
+		if err != nil {
+			ui.Error(fmt.Sprintf("Failed to create a temporary folder: %v", err))
+			os.Exit(1)
+		}
+		dst = filepath.Join(dir, name)
+		cleanup = func() { os.RemoveAll(dir) }
+	}
+
+	label := map[string]string{builder.FormatELF: "ELF", builder.FormatHex: "Intel HEX"}[format]
+	sp := ui.StartSpinner(fmt.Sprintf("Converting %s (%s) to a raw binary...", filepath.Base(path), label))
+	if err := builder.New(cfg).ToBinary(path, dst, format); err != nil {
+		sp.Fail("Conversion failed")
+		cleanup()
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+	sp.Succeed(fmt.Sprintf("Converted to %s", dst))
+	return dst, cleanup
+}
+
+// addWorkingDirFlag registers --working-dir on the binary-mode commands.
+func addWorkingDirFlag(cmd *cobra.Command, target *string, usage string) {
+	cmd.Flags().StringVar(target, "working-dir", "", usage)
+}
+
+// binaryWorkDir creates the --working-dir directory, or a temporary one
+// when dir is empty. The cleanup removes a temporary directory unless keep
+// is set.
+func binaryWorkDir(dir string, keep bool) (string, func()) {
+	if dir == "" {
+		tmp, err := os.MkdirTemp("", "alif-work-")
+		if err != nil {
+			ui.Error(fmt.Sprintf("Failed to create a temporary folder: %v", err))
+			os.Exit(1)
+		}
+		if keep {
+			return tmp, func() {}
+		}
+		return tmp, func() { os.RemoveAll(tmp) }
+	}
+	abs, err := filepath.Abs(dir)
+	if err == nil {
+		err = os.MkdirAll(abs, 0755)
+	}
+	if err != nil {
+		ui.Error(fmt.Sprintf("Cannot use working directory %s: %v", dir, err))
+		os.Exit(1)
+	}
+	return abs, func() {}
+}
@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"alif-cli/internal/flasher"
+	"alif-cli/internal/targets"
+	"alif-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var eraseAll bool
+var eraseVerbose bool
+var eraseMethod string
+var eraseDevice string
+var eraseYes bool
+var erasePort string
+var eraseSerial string
+var eraseProbeSerial string
+var eraseJSON bool
+
+var eraseCmd = &cobra.Command{
+	Use:   "erase",
+	Short: "Erase the application MRAM area",
+	Long: `Erases the application area of the connected board without flashing.
+
+Over ISP the toolkit's app-write-mram erases the application area, or with
+--all the whole application MRAM including TOCs at non-default offsets.
+Over JTAG the whole application area is filled with 0x00 through J-Link.
+
+The region is shown and confirmed before anything is erased; --yes skips
+the question.
+
+--json prints the device, the region and the result as JSON lines, in the
+same form as alif recover --json.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runErase()
+	},
+}
+
+func init() {
+	eraseCmd.Flags().BoolVar(&eraseAll, "all", false, "Erase the whole application MRAM, including TOCs at other offsets")
+	eraseCmd.Flags().BoolVarP(&eraseVerbose, "verbose", "v", false, "Enable verbose output")
+	eraseCmd.Flags().StringVarP(&eraseMethod, "method", "m", "ISP", "Erase method (ISP or JTAG)")
+	eraseCmd.Flags().StringVarP(&eraseDevice, "device", "d", "", "J-Link device name (JTAG only)")
+	eraseCmd.Flags().BoolVarP(&eraseYes, "yes", "y", false, "Do not ask for confirmation")
+	eraseCmd.Flags().StringVar(&erasePort, "port", "", "Serial port to use, or tcp://host:port / rfc2217://host:port (overrides ALIF_PORT and default_port)")
+	eraseCmd.Flags().StringVar(&eraseSerial, "serial", "", "Select the board by USB serial number (exact or prefix match)")
+	eraseCmd.Flags().StringVar(&eraseProbeSerial, "probe-serial", "", "Serial number of the J-Link to use when several are attached (default: jlink_serial from the config)")
+	eraseCmd.Flags().BoolVar(&eraseJSON, "json", false, "Print progress as JSON lines, ending with a result object")
+	rootCmd.AddCommand(eraseCmd)
+}
+
+// confirmErase asks before erasing what; it exits when the answer is no or
+// nobody can answer. yes skips the question.
+func confirmErase(what string, yes bool) {
+	if err := eraseConfirmed(what, yes); err != nil {
+		ui.Error(err.Error())
+		os.Exit(exitCode(err))
+	}
+}
+
+// eraseConfirmed asks before erasing what, and returns an error when the
+// answer is no or nobody can answer. yes skips the question.
+func eraseConfirmed(what string, yes bool) error {
+	if yes {
+		return nil
+	}
+	ok, err := ui.Confirm(fmt.Sprintf("Erase %s?", what))
+	if err != nil {
+		return fmt.Errorf("%w (pass --yes to erase without asking)", err)
+	}
+	if !ok {
+		return errors.New("Aborted; nothing was erased")
+	}
+	return nil
+}
+
+func runErase() {
+	run := newRecoverRun(eraseJSON)
+	r := run.report
+	method := strings.ToUpper(eraseMethod)
+	if method != "ISP" && method != "JTAG" {
+		run.fail(fmt.Sprintf("Unsupported method '%s' (use ISP or JTAG)", eraseMethod))
+	}
+	run.result.Interface = method
+
+	cfg := requireConfig()
+	defer lockToolkit(cfg)()
+
+	r.Header("Erase")
+	dev, err := targets.CurrentDevice(cfg.AlifToolsPath)
+	if err != nil && method == "JTAG" {
+		run.fail(fmt.Sprintf("Cannot determine MRAM layout: %v", err))
+	}
+
+	what := "the application area"
+	area := flasher.EraseApp
+	if eraseAll {
+		what = "the whole application MRAM, including any TOCs"
+		area = flasher.EraseAll
+	}
+	if dev != nil {
+		region := flasher.AppRegion(dev)
+		r.Item("Device", dev.DisplayName())
+		r.Item("Region", region.String())
+		what += fmt.Sprintf(" (%s)", region)
+
+		device, source := dev.PartNumber, "toolkit"
+		if method == "JTAG" && eraseDevice != "" {
+			device, source = eraseDevice, "flag"
+		}
+		run.result.Device = device
+		run.emit(recoverEvent{Event: "device", Device: device, Source: source})
+		addr, length := fmt.Sprintf("0x%x", region.Start), int(region.End-region.Start)
+		run.emit(recoverEvent{Event: "region", Address: addr, Length: length})
+		run.result.Regions = append(run.result.Regions, recoverRegion{Address: addr, Length: length, Status: "pending"})
+	}
+	r.Item("Method", method)
+	if method == "ISP" {
+		r.Item("Area", area)
+	}
+	if err := eraseConfirmed(what, eraseYes); err != nil {
+		run.failErr(err, "")
+	}
+
+	f := flasher.New(cfg)
+	f.Report = r
+	f.Port = erasePort
+	f.Serial = eraseSerial
+	if eraseProbeSerial != "" {
+		f.ProbeSerial = eraseProbeSerial
+	}
+
+	if method == "JTAG" {
+		if err := f.EraseViaJLink(flasher.AppRegion(dev), dev.PartNumber, eraseDevice); err != nil {
+			eraseWritten(run, "failed")
+			run.fail(fmt.Sprintf("Erase failed: %v", err))
+		}
+		eraseWritten(run, "written")
+		run.finish("ok", "")
+		return
+	}
+
+	port, err := f.SelectPort()
+	if err != nil {
+		run.failErr(fmt.Errorf("Error identifying port: %w", err), "")
+	}
+	release, err := f.AcquirePort(port)
+	if err != nil {
+		run.fail(fmt.Sprintf("%v", err))
+	}
+	defer release()
+	if err := f.UpdateISPConfig(port); err != nil {
+		run.fail(fmt.Sprintf("Failed to update ISP config: %v", err))
+	}
+	if err := f.EraseAreaViaISP(area, eraseVerbose); err != nil {
+		eraseWritten(run, "failed")
+		run.fail(fmt.Sprintf("Erase failed: %v", err))
+	}
+	eraseWritten(run, "written")
+	run.finish("ok", "")
+}
+
+// eraseWritten emits a write event with status for each erased region, or
+// one without an address when the region is not known. An erase writes
+// zeros without reading them back, so success is "written".
+func eraseWritten(run *recoverRun, status string) {
+	for i := range run.result.Regions {
+		run.result.Regions[i].Status = status
+		run.emit(recoverEvent{Event: "write", Address: run.result.Regions[i].Address, Status: status})
+	}
+	if len(run.result.Regions) == 0 {
+		run.emit(recoverEvent{Event: "write", Status: status})
+	}
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// eraseBench sets up a home with a configured E7 toolkit and a J-Link
+// Commander that exits with code, and a project whose JLinkDevices.xml
+// lists the E7 HE core. It returns the project directory.
+func eraseBench(t *testing.T, code string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	home, tools, project := t.TempDir(), t.TempDir(), t.TempDir()
+	jlinkExe := filepath.Join(tools, "JLinkExe")
+	writeFiles(t, map[string]string{
+		jlinkExe: "#!/bin/sh\necho 'Connected successfully'\nexit " + code + "\n",
+		filepath.Join(home, ".alif", "config.yaml"):    "alif_tools_path: " + tools + "\njlink_path: " + jlinkExe + "\n",
+		filepath.Join(tools, "utils", "global-cfg.db"): `{"DEVICE": {"Part#": "E7 (AE722F80F55D5LS) - 5.5 MRAM / 13.5 SRAM", "Revision": "B2"}}`,
+		filepath.Join(project, ".alif", "JLinkDevices.xml"): `<DataBase>
+  <Device>
+    <ChipInfo Vendor="AlifSemi" Name="AE722F80F55D5_M55_HE" Aliases="AE722F80F55D5LS_M55_HE" />
+  </Device>
+</DataBase>
+`,
+	})
+	t.Setenv("HOME", home)
+	return project
+}
+
+func TestEraseJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		exit   string
+		golden string
+		code   int
+	}{
+		{name: "erased", exit: "0", golden: "erase-ok.ndjson"},
+		{name: "J-Link fails", exit: "1", golden: "erase-failed.ndjson", code: 1},
+	}
+	for _, tt := range tests {
+		project := eraseBench(t, tt.exit)
+		out, code := runAlif(t, project, "erase", "--json", "--yes", "-m", "JTAG", "-d", "AE722F80F55D5LS_M55_HE")
+		if code != tt.code {
+			t.Errorf("%s: exit code %d, want %d", tt.name, code, tt.code)
+		}
+		want, err := os.ReadFile(filepath.Join("testdata", tt.golden))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := durationPattern.ReplaceAllString(strings.ReplaceAll(out, project, "PROJECT"), `"duration_ms":0`)
+		if got != string(want) {
+			t.Errorf("%s: output\n%s\nwant (%s)\n%s", tt.name, got, tt.golden, want)
+		}
+	}
+}
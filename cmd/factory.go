@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"alif-cli/internal/config"
+	"alif-cli/internal/factory"
+	"alif-cli/internal/flasher"
+	"alif-cli/internal/project"
+	"alif-cli/internal/targets"
+	"alif-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var factoryManifest string
+var factoryValues []string
+var factoryOperator string
+var factoryBoardSerial string
+var factoryMethod string
+var factoryPort string
+var factoryVerbose bool
+
+var factoryCmd = &cobra.Command{
+	Use:   "factory",
+	Short: "Manufacturing image sets",
+}
+
+var factoryFlashCmd = &cobra.Command{
+	Use:   "flash",
+	Short: "Program every step of the factory manifest onto the connected board",
+	Long: `Runs the operations in .alif/factory.yaml in order: image steps flash a built
+context's image and TOC, raw steps write a file at an address, and data steps
+render a per-board template (serial number, date, operator and --set values)
+and write it at an address. Raw and data regions are read back at the end
+(JTAG only). A record of every run is appended to .alif/factory_history.jsonl.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runFactoryFlash()
+	},
+}
+
+func init() {
+	factoryFlashCmd.Flags().StringVar(&factoryManifest, "manifest", "", "Manifest file (default: .alif/factory.yaml)")
+	factoryFlashCmd.Flags().StringArrayVar(&factoryValues, "set", nil, "Template value as key=value (repeatable)")
+	factoryFlashCmd.Flags().StringVar(&factoryOperator, "operator", "", "Name of the operator signing off this run")
+	factoryFlashCmd.Flags().StringVar(&factoryBoardSerial, "board-serial", "", "Board serial number (default: USB serial number of the selected port)")
+	factoryFlashCmd.Flags().StringVarP(&factoryMethod, "method", "m", "ISP", "Loading method (ISP or JTAG)")
+	factoryFlashCmd.Flags().StringVar(&factoryPort, "port", "", "Serial port to use, or tcp://host:port / rfc2217://host:port (overrides ALIF_PORT and default_port)")
+	factoryFlashCmd.Flags().BoolVarP(&factoryVerbose, "verbose", "v", false, "Enable verbose output")
+	factoryFlashCmd.MarkFlagRequired("operator")
+	factoryCmd.AddCommand(factoryFlashCmd)
+	rootCmd.AddCommand(factoryCmd)
+}
+
+func runFactoryFlash() {
+	method := strings.ToUpper(factoryMethod)
+	if method != "ISP" && method != "JTAG" {
+		ui.Error(fmt.Sprintf("Unsupported method '%s' (use ISP or JTAG)", factoryMethod))
+		os.Exit(1)
+	}
+
+	solDir, err := project.IsSolutionRoot("")
+	if err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+	alifDir := filepath.Join(solDir, ".alif")
+	cfg := requireConfig()
+	defer lockToolkit(cfg)()
+
+	manifestPath := factoryManifest
+	if manifestPath == "" {
+		manifestPath = filepath.Join(alifDir, factory.ManifestFile)
+	}
+	manifest, err := factory.LoadManifest(manifestPath)
+	if err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+	values, err := factory.ParseValues(factoryValues)
+	if err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+
+	ui.Header("Factory Programming")
+	ui.Item("Manifest", manifestPath)
+	ui.Item("Steps", fmt.Sprintf("%d", len(manifest.Steps)))
+	ui.Item("Operator", factoryOperator)
+
+	f := flasher.New(cfg)
+	f.Port = factoryPort
+	port, err := f.SelectPort()
+	if err != nil {
+		ui.Error(fmt.Sprintf("Error identifying port: %v", err))
+		os.Exit(exitCode(err))
+	}
+	if method == "ISP" {
+		if err := f.UpdateISPConfig(port); err != nil {
+			ui.Error(fmt.Sprintf("Failed to update ISP config: %v", err))
+			os.Exit(1)
+		}
+	}
+
+	serial := factoryBoardSerial
+	if serial == "" {
+		serial = flasher.PortSerialNumber(port)
+	}
+	if serial == "" {
+		ui.Error("Could not read the board serial number; pass --board-serial.")
+		os.Exit(1)
+	}
+	ui.Item("Board Serial", serial)
+
+	workDir, err := os.MkdirTemp("", "alif-factory")
+	if err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+	defer os.RemoveAll(workDir)
+
+	ops := &factoryOps{cfg: cfg, f: f, solDir: solDir, port: port, method: method}
+	vars := factory.NewVars(serial, factoryOperator, values, time.Now())
+	rec, runErr := factory.Run(manifest, ops, vars, workDir)
+
+	if err := factory.AppendHistory(alifDir, rec); err != nil {
+		ui.Warn(fmt.Sprintf("Failed to record run: %v", err))
+	}
+
+	ui.Header("Summary")
+	for _, s := range rec.Steps {
+		if s.Error != "" {
+			ui.Item(s.Step, "failed: "+s.Error)
+		} else {
+			ui.Item(s.Step, "ok")
+		}
+	}
+	if runErr != nil {
+		ui.Error(fmt.Sprintf("Board %s failed: %v", serial, runErr))
+		os.Exit(1)
+	}
+	if !rec.Verified {
+		ui.Warn("Readback verification needs --method JTAG; raw and data regions were not verified.")
+	}
+	ui.Success(fmt.Sprintf("Board %s programmed (%s)", serial, rec.Duration))
+}
+
+// factoryOps performs factory steps with the flasher.
+type factoryOps struct {
+	cfg    *config.Config
+	f      *flasher.Flasher
+	solDir string
+	port   string
+	method string
+	target string
+}
+
+func (o *factoryOps) FlashImage(step factory.Step) error {
+	out, err := selectBuiltContext(o.solDir, step.Project)
+	if err != nil {
+		return err
+	}
+	binPath := filepath.Join(out.OutDir, "alif-img.bin")
+	tocPath := filepath.Join(out.OutDir, "AppTocPackage.bin")
+	for _, p := range []string{binPath, tocPath} {
+		if _, err := os.Stat(p); err != nil {
+			return fmt.Errorf("%s not found; run 'alif build -s' for %s first", filepath.Base(p), out.Context)
+		}
+	}
+
+	o.target = out.PartNumber()
+	if idx := strings.Index(out.Device, "::"); idx != -1 {
+		o.target = out.Device[idx+2:]
+	}
+	ui.Item("Context", out.Context)
+	return o.f.Flash(binPath, tocPath, flasher.Options{Method: o.method, Port: o.port, Target: o.target, Verbose: factoryVerbose})
+}
+
+func (o *factoryOps) WriteRaw(path string, addr uint64) error {
+	return o.f.WriteRaw(path, addr, o.method, o.rawTarget(), "", false, factoryVerbose)
+}
+
+func (o *factoryOps) VerifyRaw(path string, addr uint64) error {
+	return o.f.VerifyRaw(path, addr, o.rawTarget(), "")
+}
+
+// rawTarget is the device of the last image step, or the toolkit's
+// configured device when no image has been flashed yet.
+func (o *factoryOps) rawTarget() string {
+	if o.target == "" {
+		if dev, err := targets.CurrentDevice(o.cfg.AlifToolsPath); err == nil {
+			o.target = dev.PartNumber
+		}
+	}
+	return o.target
+}
+
+func (o *factoryOps) CanVerify() bool {
+	return o.method == "JTAG"
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"alif-cli/internal/fetch"
+	"alif-cli/internal/ui"
+)
+
+// localArtifact returns arg unchanged when it is a path, or downloads it
+// into the cache when it is an http(s) URL and returns the cached copy.
+// sum is the expected SHA-256 (--sha256), if any.
+func localArtifact(arg, sum string) string {
+	if !fetch.IsURL(arg) {
+		if sum != "" {
+			ui.Warn("--sha256 only applies to URLs; ignoring it")
+		}
+		return arg
+	}
+	f, err := fetch.New()
+	if err != nil {
+		ui.Error(fmt.Sprintf("Cannot use the download cache: %v", err))
+		os.Exit(1)
+	}
+	f.SHA256 = sum
+	path, err := f.Get(arg)
+	if err != nil {
+		ui.Error(fmt.Sprintf("Failed to download %s: %v", arg, err))
+		os.Exit(1)
+	}
+	return path
+}
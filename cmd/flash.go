@@ -1,14 +1,11 @@
 package cmd
 
 import (
-	"bytes"
-	"errors"
 	"fmt"
-	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"alif-cli/internal/builder"
 	"alif-cli/internal/config"
@@ -19,7 +16,6 @@ import (
 	"alif-cli/internal/ui"
 
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 // Variables for flags
@@ -28,11 +24,56 @@ var flashSlow bool
 var flashMethod string
 var flashVerbose bool
 var flashErase bool
+var flashEraseAll bool
+var flashYes bool
 var flashProject string
+var flashTarget string
 var flashNoVerify bool
+var flashCompress string
+var flashMap string
+var flashAppAddress string
+var flashTOCAddress string
+var flashForce bool
+var flashPort string
+var flashSerial string
+var flashNoProbe bool
+var flashNoStablePath bool
+var flashListArtifacts bool
+var flashForgetPort bool
+var flashNoRetry bool
+var flashJLinkSpeed int
+var flashJLinkIf string
+var flashProbeSerial string
+var flashBaud int
+var flashVerify bool
+var flashTimeout time.Duration
+var flashAssistISP bool
+var flashDevice string
+var flashOpenOCDCfg string
+var flashSHA256 string
+var flashForceImage bool
+var flashArtifacts string
+var flashAppOnly bool
+var flashTOCOnly bool
+var flashAllCores bool
+var flashKeepBin bool
+var flashWorkingDir string
+var flashKeep bool
+var flashSection string
+var flashDryRun bool
+var flashRemote string
+var flashRemoteAlif string
+var flashReset string
+var flashAutoISP bool
+var flashNoAutoISP bool
+var flashSkipProbe bool
+var flashSkipIfSame bool
+var flashEmitScript string
+var flashReportPath string
+var flashAllBoards bool
 
 var flashCmd = &cobra.Command{
-	Use:   "flash [binary_file]",
+	Use:   "flash [binary_file|elf|hex|url]",
 	Short: "Flash a built project or a specific binary",
 	Long:  `Flashes the signed binary to the connected Alif board.`,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -46,20 +87,125 @@ var flashCmd = &cobra.Command{
 
 func init() {
 	flashCmd.Flags().StringVarP(&flashConfig, "config", "c", "", "Custom signing configuration file (JSON)")
+	flashCmd.Flags().StringVar(&flashSection, "section", "", "Config section that receives the binary when the signing config describes several images")
 	flashCmd.Flags().BoolVar(&flashSlow, "slow", false, "Disable dynamic baud rate switching (more stable)")
-	flashCmd.Flags().StringVarP(&flashMethod, "method", "m", "ISP", "Loading method (ISP or JTAG)")
+	flashCmd.Flags().BoolVar(&flashNoRetry, "no-retry", false, "Do not rerun with --slow after a transient ISP failure")
+	flashCmd.Flags().BoolVar(&flashAutoISP, "auto-isp", true, "Reset the board into ISP mode over the serial DTR/RTS lines and wait for the SE-UART banner before an ISP flash")
+	flashCmd.Flags().BoolVar(&flashNoAutoISP, "no-auto-isp", false, "Do not reset the board before an ISP flash; put it into ISP mode by hand")
+	flashCmd.Flags().BoolVar(&flashSkipProbe, "skip-probe", false, "Do not check that the ISP bootloader answers on the port before an ISP flash")
+	flashCmd.Flags().BoolVar(&flashAssistISP, "assist-isp", false, "If the target does not answer ISP, halt it over J-Link and retry without asking")
+	flashCmd.Flags().StringVarP(&flashMethod, "method", "m", "ISP", "Loading method (ISP, JTAG, PYOCD or OPENOCD)")
+	flashCmd.Flags().StringVarP(&flashDevice, "device", "d", "", deviceFlagUsage)
+	flashCmd.Flags().BoolVar(&flashForceImage, "force-image", false, "Regenerate the bootable image even when the binary, config and toolkit are unchanged")
+	addWorkingDirFlag(flashCmd, &flashWorkingDir, "Directory to copy the generated image, TOC and package map to when flashing a binary (default: a temporary directory)")
+	flashCmd.Flags().BoolVar(&flashKeep, "keep", false, "Keep the temporary working directory of a binary flash and print its path")
+	flashCmd.Flags().BoolVar(&flashKeepBin, "keep-bin", false, "Keep the .bin converted from an .elf or .hex input next to it")
+	flashCmd.Flags().StringVar(&flashSHA256, "sha256", "", "Expected SHA-256 of a binary given as an http(s) URL")
+	flashCmd.Flags().StringVar(&flashOpenOCDCfg, "openocd-cfg", "", "OpenOCD adapter config (default: .alif/openocd.cfg in the project)")
 	flashCmd.Flags().BoolVarP(&flashVerbose, "verbose", "v", false, "Enable verbose output")
 	flashCmd.Flags().BoolVarP(&flashErase, "erase", "e", false, "Erase the target device application area before flashing")
-	flashCmd.Flags().StringVarP(&flashProject, "project", "p", "", "Project name or context filter")
+	flashCmd.Flags().BoolVar(&flashEraseAll, "erase-all", false, "Erase the whole application MRAM, including TOCs at other offsets, before flashing (ISP only; asks first)")
+	flashCmd.Flags().BoolVarP(&flashYes, "yes", "y", false, "Do not ask before --erase-all")
+	flashCmd.Flags().StringVarP(&flashProject, "project", "p", "", "Project name or context filter; a comma-separated list flashes one context per core together")
+	flashCmd.Flags().StringVarP(&flashTarget, "target", "t", "", "Target filter for the project context, matched against its +<target> suffix (e.g. 'E7-HP')")
+	flashCmd.Flags().BoolVar(&flashAllBoards, "all-boards", false, "Flash every connected board at once over ISP, each with its own copy of the toolkit config")
+	flashCmd.Flags().BoolVar(&flashAllCores, "all-cores", false, "Flash the built context of every core (e.g. M55_HE and M55_HP) with one combined TOC (ISP only)")
 	flashCmd.Flags().BoolVar(&flashNoVerify, "no-verify", false, "Skip checking the connected hardware device")
 	flashCmd.Flags().BoolVar(&flashNoVerify, "nv", false, "Skip checking the connected hardware device (alias for --no-verify)")
+	flashCmd.Flags().BoolVar(&flashVerify, "verify", false, "Check the image and TOC after flashing (J-Link readback, or the staged copies for ISP without J-Link)")
+	flashCmd.Flags().StringVar(&flashReportPath, "report", "", "Write a JSON report of the flash (port, artifacts and their SHA-256, addresses, status) to this file, also on failure")
+	flashCmd.Flags().StringVar(&flashEmitScript, "emit-script", "", "Write the J-Link command file for -m JTAG to this path and exit without running J-Link")
+	flashCmd.Flags().BoolVar(&flashSkipIfSame, "skip-if-same", false, "Read the start of the image and TOC back over J-Link first and skip flashing when they already match")
+	addCompressFlag(flashCmd, &flashCompress)
+	addArtifactsFlag(flashCmd, &flashArtifacts)
+	flashCmd.Flags().StringVar(&flashMap, "map", "", "Package map (app-package-map.txt) to take JTAG load addresses from")
+	flashCmd.Flags().StringVar(&flashAppAddress, "app-address", "", "Force the image load address for JTAG (hex)")
+	flashCmd.Flags().StringVar(&flashAppAddress, "app-addr", "", "Force the image load address for JTAG (alias for --app-address)")
+	flashCmd.Flags().BoolVar(&flashAppOnly, "app-only", false, "Write only the application image, leaving the TOC on the device as it is")
+	flashCmd.Flags().BoolVar(&flashTOCOnly, "toc-only", false, "Write only the TOC package, leaving the application image on the device as it is")
+	flashCmd.Flags().BoolVar(&flashForce, "force", false, "Flash even when safety checks report a problem")
+	flashCmd.Flags().StringVar(&flashTOCAddress, "toc-address", "", "Force the TOC load address for JTAG (hex)")
+	flashCmd.Flags().StringVar(&flashTOCAddress, "toc-addr", "", "Force the TOC load address for JTAG (alias for --toc-address)")
+	flashCmd.Flags().BoolVar(&flashListArtifacts, "list-artifacts", false, "Show the files, addresses and port that would be used, then exit without flashing")
+	flashCmd.Flags().StringVar(&flashReset, "reset", flasher.ResetRun, "What the target does after flashing: run, halt (debug probes only) or none")
+	flashCmd.Flags().BoolVar(&flashDryRun, "dry-run", false, "Print the files that would be staged, the ISP config and the commands that would run, without touching the toolkit or the board")
+	flashCmd.Flags().StringVar(&flashRemote, "remote", "", "Flash a board attached to another host: copy the generated image there over ssh (user@host) and run 'alif flash package' on it")
+	flashCmd.Flags().StringVar(&flashRemoteAlif, "remote-alif", "alif", "alif executable on the --remote host")
+	flashCmd.Flags().BoolVar(&flashForgetPort, "forget-port", false, "Clear the port remembered for this project and choose again")
+	flashCmd.PersistentFlags().StringVar(&flashPort, "port", "", "Serial port to use, or tcp://host:port / rfc2217://host:port (overrides ALIF_PORT and default_port)")
+	flashCmd.PersistentFlags().BoolVar(&flashNoStablePath, "no-stable-path", false, "Use kernel port names instead of /dev/serial/by-id links (Linux)")
+	flashCmd.PersistentFlags().BoolVar(&flashNoProbe, "no-probe", false, "Do not open-test serial ports while choosing one")
+	flashCmd.PersistentFlags().StringVar(&flashSerial, "serial", "", "Select the board by USB serial number (exact or prefix match)")
+	flashCmd.PersistentFlags().DurationVar(&flashTimeout, "timeout", 0, fmt.Sprintf("Stop app-write-mram or J-Link if it runs longer than this (default: flash_timeout, or %s)", flasher.DefaultToolTimeout))
+	flashCmd.PersistentFlags().IntVar(&flashBaud, "baud", flasher.DefaultISPBaud, "ISP baud rate written to isp_config_data.cfg")
+	flashCmd.PersistentFlags().IntVar(&flashJLinkSpeed, "jlink-speed", 0, "J-Link SWD/JTAG speed in kHz (default: from the detected probe)")
+	flashCmd.PersistentFlags().StringVar(&flashJLinkIf, "jlink-if", "", "J-Link target interface, SWD or JTAG (default: from the detected probe)")
+	flashCmd.PersistentFlags().IntVar(&flashJLinkSpeed, "jtag-speed", 0, "J-Link SWD/JTAG speed in kHz (alias for --jlink-speed)")
+	flashCmd.PersistentFlags().StringVar(&flashJLinkIf, "jtag-if", "", "J-Link target interface, SWD or JTAG (alias for --jlink-if)")
+	flashCmd.PersistentFlags().StringVar(&flashProbeSerial, "probe-serial", "", "Serial number of the J-Link to use when several are attached (default: jlink_serial from the config)")
 	rootCmd.AddCommand(flashCmd)
 }
 
 func runFlash(path string) {
+	if err := flasher.ValidateBaud(flashBaud); err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+	flashMethod = strings.ToUpper(flashMethod)
+	switch flashMethod {
+	case "ISP", "JTAG", "PYOCD", "OPENOCD":
+	default:
+		ui.Error(fmt.Sprintf("Unsupported method '%s' (use ISP, JTAG, PYOCD or OPENOCD)", flashMethod))
+		os.Exit(1)
+	}
+	if flashAppOnly && flashTOCOnly {
+		ui.Error("--app-only cannot be combined with --toc-only")
+		os.Exit(1)
+	}
+	flashReset = parseResetFlag()
+	for _, a := range []string{flashAppAddress, flashTOCAddress} {
+		if a == "" {
+			continue
+		}
+		if _, err := flasher.ParseAddress(a); err != nil {
+			ui.Error(fmt.Sprintf("%v", err))
+			os.Exit(1)
+		}
+	}
+	if flashRemote != "" && (flashDryRun || flashListArtifacts) {
+		ui.Error("--remote cannot be combined with --dry-run or --list-artifacts")
+		os.Exit(1)
+	}
+	erase := eraseArea()
+	checkAllBoardsFlags(path)
+	if flashReportPath != "" && (flashesCores() || flashAllBoards || flashRemote != "" || flashDryRun || flashListArtifacts || flashEmitScript != "") {
+		ui.Error("--report only works for a single flash without --dry-run, --list-artifacts, --emit-script, --remote, --all-boards or several cores")
+		os.Exit(1)
+	}
+	if flashEmitScript != "" {
+		if flashMethod != "JTAG" {
+			ui.Error("--emit-script needs -m JTAG")
+			os.Exit(1)
+		}
+		if path != "" || flashesCores() || flashAllBoards || flashRemote != "" || flashDryRun || flashListArtifacts {
+			ui.Error("--emit-script only works for a single project flash without --dry-run, --list-artifacts, --remote or --all-boards")
+			os.Exit(1)
+		}
+	}
+
+	if flashesCores() {
+		if path != "" || flashListArtifacts || flashDryRun || flashRemote != "" {
+			ui.Error("Flashing several cores cannot be combined with a binary file, --list-artifacts, --dry-run or --remote")
+			os.Exit(1)
+		}
+		runFlashCores(erase)
+		return
+	}
+
 	// 0. Determine Mode
 	isBinary := false
 	if path != "" {
+		path = localArtifact(path, flashSHA256)
 		info, err := os.Stat(path)
 		if err != nil {
 			ui.Error(fmt.Sprintf("%v", err))
@@ -72,253 +218,479 @@ func runFlash(path string) {
 		isBinary = true
 	}
 
-	var signedBinPath, tocPath, workingDir string
-	var targetCore string
+	cfg := requireConfig()
 
-	cfg, _ := config.LoadConfig()
-	if cfg == nil || cfg.AlifToolsPath == "" {
-		ui.Error("Alif CLI not configured. Run 'alif setup' first.")
+	if flashListArtifacts {
+		listFlashArtifacts(path, isBinary, cfg)
+		return
+	}
+	if isBinary && flashRemote != "" {
+		ui.Error("--remote is only supported when flashing a project; run 'alif image' and copy the result instead")
 		os.Exit(1)
 	}
+	if !flashDryRun {
+		defer lockToolkit(cfg)()
+	}
+	startFlashReport(cfg)
 
+	// A binary goes through the same staging, image and flash steps as a
+	// project, with the signing config found next to it.
+	var art *projectArtifacts
 	if isBinary {
-		// --- BINARY MODE ---
-		ui.Header("Binary Mode Setup")
-		binPath, _ := filepath.Abs(path)
-		workingDir = filepath.Dir(binPath)
-
-		// 0. Retrieve configuration
-		resolvedConfig, resolvedConfigPath, err := targets.ResolveTargetConfig(flashConfig, workingDir, "", "")
+		var cleanup func()
+		art, cleanup = binaryArtifacts(cfg, path)
+		defer cleanup()
+	} else {
+		var err error
+		art, err = resolveProjectArtifacts(cfg)
 		if err != nil {
-			ui.Error(fmt.Sprintf("Configuration error: %v", err))
+			ui.Error(fmt.Sprintf("%v", err))
+			os.Exit(exitCode(err))
+		}
+		if !checkSharedOutDir(art.solDir, art.context, flashForce) {
 			os.Exit(1)
 		}
+		if flashRemote != "" {
+			runFlashRemote(cfg, art, erase)
+			return
+		}
+	}
+	solDir, binDir := art.solDir, art.binDir
+	targetCore := art.targetCore
+
+	// --- Hardware Pre-Verification ---
+	f := flasher.New(cfg)
+	f.Port = flashPort
+	f.Serial = flashSerial
+	f.NoProbe = flashNoProbe
+	f.NoStablePath = flashNoStablePath
+	f.Baud = flashBaud
+	applyJLinkFlags(f)
+	applyTimeoutFlag(f)
+	f.Verify = flashVerify
+	f.SkipIfSame = flashSkipIfSame
+	f.Force = flashForce
+	f.EmitScript = flashEmitScript
+	if flashAppOnly {
+		f.Only = flasher.OnlyApp
+	} else if flashTOCOnly {
+		f.Only = flasher.OnlyTOC
+	}
+	f.Addresses = flasher.AddressOverrides{MapPath: flashMap, AppAddr: flashAppAddress, TOCAddr: flashTOCAddress}
+	f.StateDir = filepath.Join(solDir, ".alif")
+	if isBinary {
+		f.StateDir = binaryStateDir(solDir)
+	}
+	f.ForgetPort = flashForgetPort
+	f.NoRetry = flashNoRetry
+	f.AssistISP = flashAssistISP
+	f.PyOCDTarget = flashDevice
+	f.OpenOCDConfig = flashOpenOCDCfg
+	f.DryRun = flashDryRun
+	f.Reset = flashReset
+	if flashMethod == "ISP" && !flashSkipIfSame && (flashMap != "" || flashAppAddress != "" || flashTOCAddress != "") {
+		ui.Warn("--map, --app-address and --toc-address only apply to JTAG, pyOCD and OpenOCD flashing")
+	}
+	if flashAllBoards {
+		runFlashAllBoards(f, art, erase)
+		return
+	}
 
-		// --- Hardware Pre-Verification ---
-		f := flasher.New(cfg)
-
-		ui.Header("Flash Target")
-		port, err := f.SelectPort()
+	ui.Header("Flash Target")
+	// An emitted script is run later by someone else; no port is used.
+	var port string
+	if flashEmitScript == "" {
+		var err error
+		port, err = f.SelectPort()
 		if err != nil {
 			ui.Error(fmt.Sprintf("Error identifying port: %v", err))
-			os.Exit(1)
-		}
-
-		// Update ISP Config so verification tools use the correct port
-		if flashMethod == "ISP" {
-			if err := f.UpdateISPConfig(port); err != nil {
-				ui.Warn(fmt.Sprintf("Failed to update ISP config: %v", err))
-			}
+			os.Exit(exitCode(err))
 		}
+	}
+	updateFlashReport(func(r *flasher.FlashReport) {
+		r.SetPort(port)
+		r.SetTarget(targetCore)
+	})
 
-		// 1. Copy binary to toolkit
-		configAbsPath, _ := filepath.Abs(resolvedConfigPath)
-		binBaseName := filepath.Base(binPath)
-		toolkitBinPath := filepath.Join(cfg.AlifToolsPath, "build", "images", binBaseName)
+	if flashDryRun {
+		runFlashDryRun(f, art, port, erase)
+		return
+	}
 
-		os.MkdirAll(filepath.Dir(toolkitBinPath), 0755)
+	// Update ISP Config so verification tools use the correct port
+	if flashMethod == "ISP" {
+		release, err := f.AcquirePort(port)
+		if err != nil {
+			ui.Error(fmt.Sprintf("%v", err))
+			os.Exit(1)
+		}
+		defer release()
+		enterISPMode(f, port)
+		probeISP(f, port)
+		if err := f.UpdateISPConfig(port); err != nil {
+			ui.Warn(fmt.Sprintf("Failed to update ISP config: %v", err))
+		}
+	}
 
-		srcFile, _ := os.Open(binPath)
-		dstFile, _ := os.Create(toolkitBinPath)
-		io.Copy(dstFile, srcFile)
-		srcFile.Close()
-		dstFile.Close()
+	// 2. Sync Toolkit Config
+	if err := targets.SyncToolkitConfig(cfg.AlifToolsPath, targetCore, ui.Console); err != nil {
+		ui.Warn(fmt.Sprintf("Toolkit sync failed: %v", err))
+	}
 
-		// 2. Sync Toolkit Config
-		if err = targets.SyncToolkitConfig(cfg.AlifToolsPath, resolvedConfig.GetCPU()); err != nil {
-			ui.Warn(fmt.Sprintf("Toolkit sync failed: %v", err))
+	// Perform live verification (User wants this after toolkit sync logs)
+	if flashMethod == "ISP" && !flashNoVerify {
+		if err := f.VerifyConnectedDevice(port, targetCore); err != nil {
+			// VerifyConnectedDevice prints its own failure
+			os.Exit(1)
 		}
+	}
 
-		// Perform live verification
-		if flashMethod == "ISP" && !flashNoVerify {
-			if err := targets.VerifyConnectedDevice(cfg.AlifToolsPath, resolvedConfig.GetCPU()); err != nil {
-				// VerifyConnectedDevice prints its own failure
-				os.Exit(1)
-			}
+	// 3. Create Image (Pack/Sign) with Hints. app-gen-toc is skipped when
+	// the binary, signing config and toolkit match the last image made.
+	s := signer.New(cfg)
+	s.Compression = flashCompress
+	if !isBinary {
+		s.StateDir = filepath.Join(solDir, ".alif")
+	}
+	s.ForceImage = flashForceImage
+	s.Output = flashArtifacts
+	s.Section = flashSection
+	images, err := s.SignArtifact(solDir, binDir, art.binPath, art.coreHint, art.projectHint, flashConfig)
+	if err != nil {
+		ui.Error(fmt.Sprintf("Failed to create bootable image: %v", err))
+		os.Exit(exitCode(err))
+	}
+	if isBinary {
+		// With no project around the binary, the part number the toolkit
+		// was synced to picks the J-Link device.
+		if dev, err := targets.CurrentDevice(cfg.AlifToolsPath); err == nil && dev.PartNumber != "" {
+			targetCore = dev.PartNumber + ":" + targetCore
 		}
+		if flashWorkingDir != "" || flashKeep {
+			ui.Item("Artifacts", binDir)
+		}
+	}
+	f.TargetConfigPath = images.Config
+	updateFlashReport(func(r *flasher.FlashReport) {
+		r.SetTarget(targetCore)
+		f.DescribeArtifacts(r, images.Image, images.TOC, targetCore)
+	})
+
+	// 4. Flash
+	if err := f.Flash(images.Image, images.TOC, flashOptions(port, targetCore, erase)); err != nil {
+		ui.Error(fmt.Sprintf("Flash failed: %v", err))
+		os.Exit(exitCode(err))
+	}
+	if flashEmitScript != "" {
+		return
+	}
+	finishFlashReport(nil)
+	if isBinary {
+		return
+	}
+	if err := f.RememberPort(port); err != nil {
+		ui.Warn(fmt.Sprintf("Failed to remember port: %v", err))
+	}
+}
 
-		// 3. Run app-gen-toc
-		cmd := exec.Command(filepath.Join(cfg.AlifToolsPath, "app-gen-toc"), "-f", configAbsPath)
-		cmd.Dir = cfg.AlifToolsPath
-		var output bytes.Buffer
-		cmd.Stdout = &output
-		cmd.Stderr = &output
-
-		sp := ui.StartSpinner("Generating TOC...")
-		if err := cmd.Run(); err != nil {
-			sp.Fail("TOC generation failed")
-			fmt.Println("\n" + output.String())
-			os.Exit(1)
+// binaryArtifacts describes a binary given to alif flash the way
+// resolveProjectArtifacts describes a project's build: an .elf or .hex is
+// converted first, the signing config is resolved next to the binary and
+// kept in --config for the signer, and the image is made in the working
+// directory (--working-dir, or a temporary one unless --keep). J-Link
+// devices come from the nearest .alif/JLinkDevices.xml above the binary,
+// or from ~/.alif. The returned function removes what was only needed for
+// this flash.
+func binaryArtifacts(cfg *config.Config, path string) (*projectArtifacts, func()) {
+	ui.Header("Binary Mode Setup")
+	binPath, _ := filepath.Abs(path)
+	workingDir := filepath.Dir(binPath)
+	outDir, cleanupDir := binaryWorkDir(flashWorkingDir, flashKeep)
+	convertDir := ""
+	if flashWorkingDir != "" {
+		convertDir = outDir
+	}
+	binPath, cleanupBin := rawBinary(cfg, binPath, convertDir, flashKeepBin)
+	cleanup := func() {
+		cleanupBin()
+		cleanupDir()
+	}
+
+	resolvedConfig, resolvedConfigPath, err := targets.ResolveTargetConfig(flashConfig, workingDir, "", "", ui.Console)
+	if err != nil {
+		cleanup()
+		ui.Error(fmt.Sprintf("Configuration error: %v", err))
+		os.Exit(exitCode(err))
+	}
+	flashConfig = resolvedConfigPath
+
+	return &projectArtifacts{
+		solDir:        workingDir,
+		binDir:        outDir,
+		binPath:       binPath,
+		signedBinPath: filepath.Join(outDir, "alif-img.bin"),
+		tocPath:       filepath.Join(outDir, "AppTocPackage.bin"),
+		targetCore:    resolvedConfig.GetCPU(),
+	}, cleanup
+}
+
+// binaryStateDir returns the .alif directory nearest above dir, or
+// ~/.alif, for the J-Link device mapping of a binary flashed on its own.
+func binaryStateDir(dir string) string {
+	if p := config.FindUpwards(dir, []string{".alif"}); p != "" {
+		if info, err := os.Stat(p); err == nil && info.IsDir() {
+			return p
 		}
-		sp.Succeed("TOC generated successfully")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".alif")
+	}
+	return ""
+}
 
-		// 4. Erase if requested
+// eraseArea returns the MRAM area --erase or --erase-all asks for, or ""
+// when neither was given. --erase-all is confirmed first unless --yes.
+func eraseArea() string {
+	if !flashEraseAll {
 		if flashErase {
-			if err := f.EraseViaISP(flashVerbose); err != nil {
-				ui.Warn(fmt.Sprintf("Erase failed: %v", err))
-			}
+			return flasher.EraseApp
 		}
+		return ""
+	}
+	if flashMethod != "ISP" {
+		ui.Error(fmt.Sprintf("--erase-all needs the ISP method (got %s)", flashMethod))
+		os.Exit(1)
+	}
+	confirmErase("the whole application MRAM, including any TOCs, before flashing", flashYes || flashListArtifacts || flashDryRun)
+	return flasher.EraseAll
+}
 
-		// 5. Flash
-		cmdFlash := exec.Command(filepath.Join(cfg.AlifToolsPath, "app-write-mram"), "-p")
-		cmdFlash.Dir = cfg.AlifToolsPath
-		var outFlash bytes.Buffer
-		cmdFlash.Stdout = &outFlash
-		cmdFlash.Stderr = &outFlash
-
-		spFlash := ui.StartSpinner("Flashing binary...")
-		if err := cmdFlash.Run(); err != nil {
-			spFlash.Fail("Flash failed")
-			fmt.Println("\n" + outFlash.String())
-			os.Exit(1)
-		}
-		spFlash.Succeed("Flash complete!")
+// enterISPMode resets the board into ISP mode before an ISP flash unless
+// --no-auto-isp (or --auto-isp=false) was given. Without the SE-UART
+// banner the flash is not attempted; the manual procedure is printed.
+func enterISPMode(f *flasher.Flasher, port string) {
+	if !flashAutoISP || flashNoAutoISP {
 		return
+	}
+	if err := f.EnterISPMode(port); err != nil {
+		ui.Error(fmt.Sprintf("Could not put the board into ISP mode: %v", err))
+		f.ReportManualISP(port)
+		os.Exit(1)
+	}
+}
 
-	} else {
-		// --- PROJECT MODE (Solution/Context) ---
-		// Find Solution Root (Scanning silently)
-		cwd, _ := os.Getwd()
-		solDir, err := project.IsSolutionRoot(cwd)
-		if err != nil {
-			ui.Error("Could not find solution (.csolution.yml) in current directory.")
-			os.Exit(1)
+// probeISP checks that the ISP bootloader answers on port before anything
+// is staged for an ISP flash, unless --skip-probe was given. With
+// --assist-isp the flash goes ahead anyway, since halting the application
+// over J-Link may be what makes the SE answer.
+func probeISP(f *flasher.Flasher, port string) {
+	if flashSkipProbe {
+		return
+	}
+	if err := f.ProbeISP(port); err != nil {
+		if f.AssistISP {
+			ui.Warn(fmt.Sprintf("%v on %s; continuing so --assist-isp can halt the application", err, port))
+			return
 		}
+		ui.Error(fmt.Sprintf("%s did not answer as an ISP bootloader: %v", port, err))
+		f.ReportISPProbeHelp(port)
+		os.Exit(1)
+	}
+}
 
-		// Resolve Context
-		b := builder.New(cfg)
-		selectedContext, err := b.ResolveContext(solDir, "", flashProject)
-		if err != nil {
-			ui.Error(fmt.Sprintf("%v", err))
-			os.Exit(1)
-		}
+// parseResetFlag validates --reset and returns it in canonical form.
+func parseResetFlag() string {
+	mode, err := flasher.ParseReset(flashReset)
+	if err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+	return mode
+}
 
-		// Find corresponding .cbuild.yml file recursively
-		targetFile := selectedContext + ".cbuild.yml"
-		var selectedFile string
-
-		filepath.Walk(solDir, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return nil
-			}
-			if info.IsDir() {
-				name := info.Name()
-				if name == ".git" || name == "packs" || name == "tools" || name == "node_modules" || name == "out" || name == "tmp" {
-					return filepath.SkipDir
-				}
-				return nil
-			}
-			if info.Name() == targetFile {
-				selectedFile = path
-				return errors.New("found")
-			}
-			return nil
-		})
-
-		if selectedFile == "" {
-			ui.Error(fmt.Sprintf("Build configuration file '%s' not found.", targetFile))
-			os.Exit(1)
-		}
+// applyTimeoutFlag sets f.Timeout from --timeout when it was given;
+// otherwise the flash_timeout value chosen by flasher.New stands.
+func applyTimeoutFlag(f *flasher.Flasher) {
+	if flashTimeout > 0 {
+		f.Timeout = flashTimeout
+	}
+}
 
-		ui.Item("Config", filepath.Base(selectedFile))
+// flashOptions returns the Flash options the flash flags give for a board
+// on port and target, erasing erase first.
+func flashOptions(port, target, erase string) flasher.Options {
+	return flasher.Options{Method: flashMethod, Port: port, Target: target, Slow: flashSlow, Verbose: flashVerbose, Erase: erase}
+}
 
-		// Parse YAML
-		v := viper.New()
-		v.SetConfigFile(selectedFile)
-		if err := v.ReadInConfig(); err != nil {
-			ui.Error(fmt.Sprintf("Error reading build config: %v", err))
-			os.Exit(1)
-		}
+// deviceFlagUsage describes --device on alif flash and alif flash package.
+const deviceFlagUsage = "J-Link device name for JTAG, pyOCD target for PYOCD (default: jlink_device in .alif/toolchain.yml, else .alif/JLinkDevices.xml; for pyOCD the toolkit's part number)"
 
-		// Parse Hints (Device Core and Project Name)
-		deviceStr := v.GetString("build.device") // e.g. "Alif Semiconductor::AE722F80F55D5LS:M55_HE"
-		var coreHint string
-		if parts := strings.Split(deviceStr, ":"); len(parts) > 0 {
-			coreHint = parts[len(parts)-1]
-		}
+// applyJLinkFlags copies --jlink-speed, --jlink-if, --probe-serial and the
+// J-Link device (--device, else jlink_device in .alif/toolchain.yml) onto f.
+func applyJLinkFlags(f *flasher.Flasher) {
+	switch strings.ToUpper(flashJLinkIf) {
+	case "", "SWD", "JTAG":
+	default:
+		ui.Error(fmt.Sprintf("Unsupported J-Link interface '%s' (use SWD or JTAG)", flashJLinkIf))
+		os.Exit(1)
+	}
+	f.JLinkSpeed = flashJLinkSpeed
+	f.JLinkInterface = flashJLinkIf
+	if flashProbeSerial != "" {
+		f.ProbeSerial = flashProbeSerial
+	}
+	if flashDevice != "" {
+		f.JLinkDevice, f.JLinkDeviceSource = flashDevice, "--device"
+	} else if local, _ := config.FindLocal("."); local != nil && local.JLinkDevice != "" {
+		f.JLinkDevice, f.JLinkDeviceSource = local.JLinkDevice, config.LocalFile
+	}
+}
 
-		// Extract Full Part Number for dynamic resolution
-		targetCore = coreHint
-		deviceParts := strings.Split(deviceStr, "::")
-		if len(deviceParts) > 1 {
-			targetCore = deviceParts[1] // e.g. AE722F80F55D5LS:M55_HE
-		}
+// projectArtifacts is the outcome of project-mode resolution: the selected
+// context, its build outputs and the device hints used for signing.
+type projectArtifacts struct {
+	solDir     string
+	context    string
+	cbuildFile string
+	binDir     string
+	binPath    string
+	// elfPath is set when the .cbuild.yml lists no bin output; binPath is
+	// then made from it.
+	elfPath       string
+	signedBinPath string
+	tocPath       string
+	coreHint      string
+	projectHint   string
+	targetCore    string
+}
 
-		projectHint := flashProject
-		if projectHint == "" {
-			if idx := strings.Index(selectedContext, "."); idx != -1 {
-				projectHint = selectedContext[:idx]
-			} else {
-				projectHint = selectedContext
-			}
-		}
+// resolveProjectArtifacts finds the solution, resolves the context (-p) and
+// reads its .cbuild.yml to locate the build outputs.
+func resolveProjectArtifacts(cfg *config.Config) (*projectArtifacts, error) {
+	// Find Solution Root (Scanning silently)
+	cwd, _ := os.Getwd()
+	solDir, err := project.IsSolutionRoot(cwd)
+	if err != nil {
+		return nil, fmt.Errorf("could not find solution (.csolution.yml) in current directory")
+	}
 
-		// Extract Output Directory
-		outRel := v.GetString("build.output-dirs.outdir")
-		binName := ""
-		outputs := v.Get("build.output").([]interface{})
-		for _, o := range outputs {
-			omap := o.(map[string]interface{})
-			if omap["type"] == "bin" {
-				binName = omap["file"].(string)
-				break
-			}
-		}
+	// Resolve Context
+	b := builder.New(cfg)
+	selectedContext, err := b.ResolveContext(solDir, flashTarget, flashProject, "")
+	if err != nil {
+		return nil, err
+	}
 
-		// Construct paths
-		cbuildDir := filepath.Dir(selectedFile)
-		binDir := filepath.Join(cbuildDir, outRel)
-		binPath := filepath.Join(binDir, binName)
+	art, err := contextArtifacts(solDir, selectedContext, flashProject)
+	if err != nil {
+		return nil, err
+	}
+	if err := projectBinary(cfg, art, true); err != nil {
+		return nil, err
+	}
+	ui.Item("Config", filepath.Base(art.cbuildFile))
+	return art, nil
+}
 
-		// Derived Artifact Paths
-		signedBinPath = filepath.Join(binDir, "alif-img.bin")
-		tocPath = filepath.Join(binDir, "AppTocPackage.bin")
-		workingDir = binDir
+// contextArtifacts reads the .cbuild.yml of context to locate its build
+// outputs. projectFilter is the -p value the context was chosen with, used
+// as the project hint for signing.
+func contextArtifacts(solDir, selectedContext, projectFilter string) (*projectArtifacts, error) {
+	// Find corresponding .cbuild.yml file recursively
+	out, err := project.FindContextOutput(solDir, selectedContext)
+	if err != nil {
+		return nil, err
+	}
+	selectedFile := out.File
 
-		// --- Hardware Pre-Verification ---
-		f := flasher.New(cfg)
+	// Parse Hints (Device Core and Project Name)
+	deviceStr := out.Device // e.g. "Alif Semiconductor::AE722F80F55D5LS:M55_HE"
+	var coreHint string
+	if parts := strings.Split(deviceStr, ":"); len(parts) > 0 {
+		coreHint = parts[len(parts)-1]
+	}
 
-		ui.Header("Flash Target")
-		port, err := f.SelectPort()
-		if err != nil {
-			ui.Error(fmt.Sprintf("Error identifying port: %v", err))
-			os.Exit(1)
-		}
+	// Extract Full Part Number for dynamic resolution
+	targetCore := coreHint
+	deviceParts := strings.Split(deviceStr, "::")
+	if len(deviceParts) > 1 {
+		targetCore = deviceParts[1] // e.g. AE722F80F55D5LS:M55_HE
+	}
 
-		// Update ISP Config so verification tools use the correct port
-		if flashMethod == "ISP" {
-			if err := f.UpdateISPConfig(port); err != nil {
-				ui.Warn(fmt.Sprintf("Failed to update ISP config: %v", err))
-			}
+	projectHint := projectFilter
+	if projectHint == "" {
+		if idx := strings.Index(selectedContext, "."); idx != -1 {
+			projectHint = selectedContext[:idx]
+		} else {
+			projectHint = selectedContext
 		}
+	}
 
-		// 2. Sync Toolkit Config
-		if err := targets.SyncToolkitConfig(cfg.AlifToolsPath, targetCore); err != nil {
-			ui.Warn(fmt.Sprintf("Toolkit sync failed: %v", err))
-		}
+	// Without a bin output the binary can still be made from the elf; see
+	// projectBinary.
+	binPath, elfPath := out.Artifacts()
+	if binPath == "" {
+		return nil, fmt.Errorf("no bin output listed in %s; %s", filepath.Base(selectedFile), addBinOutputHint)
+	}
+	if out.Bin != "" {
+		elfPath = ""
+	}
 
-		// Perform live verification (User wants this after toolkit sync logs)
-		if flashMethod == "ISP" && !flashNoVerify {
-			if err := targets.VerifyConnectedDevice(cfg.AlifToolsPath, targetCore); err != nil {
-				// VerifyConnectedDevice prints its own failure
-				os.Exit(1)
-			}
-		}
+	// Construct paths
+	binDir := out.OutDir
+	return &projectArtifacts{
+		solDir:        solDir,
+		context:       selectedContext,
+		cbuildFile:    selectedFile,
+		binDir:        binDir,
+		binPath:       binPath,
+		elfPath:       elfPath,
+		signedBinPath: filepath.Join(binDir, "alif-img.bin"),
+		tocPath:       filepath.Join(binDir, "AppTocPackage.bin"),
+		coreHint:      coreHint,
+		projectHint:   projectHint,
+		targetCore:    targetCore,
+	}, nil
+}
 
-		// 3. Create Image (Pack/Sign) with Hints. We always run this to ensure
-		// all artifacts and side-effects (like .ds script updates) are applied.
-		s := signer.New(cfg)
-		_, err = s.SignArtifact(solDir, binDir, binPath, coreHint, projectHint, flashConfig)
+// addBinOutputHint tells how to make a build list a bin output.
+const addBinOutputHint = "add bin to the output types (output: type: [elf, bin]) in the .cproject.yml or .csolution.yml and rebuild"
+
+// projectBinary makes the raw binary of a context whose .cbuild.yml only
+// lists an elf, with arm-none-eabi-objcopy, after asking when ask is set.
+// Without ask (alif build --watch --and-flash, where a prompt would stall
+// the loop) it converts straight away. A binary that is already newer than
+// the elf is used as is.
+func projectBinary(cfg *config.Config, art *projectArtifacts, ask bool) error {
+	if art.elfPath == "" {
+		return nil
+	}
+	elfInfo, err := os.Stat(art.elfPath)
+	if err != nil {
+		return fmt.Errorf("%s lists no bin output and %s was not found; build the project, or %s", filepath.Base(art.cbuildFile), filepath.Base(art.elfPath), addBinOutputHint)
+	}
+	if info, err := os.Stat(art.binPath); err == nil && !info.ModTime().Before(elfInfo.ModTime()) {
+		ui.Debug(fmt.Sprintf("Using %s made from %s", art.binPath, filepath.Base(art.elfPath)))
+		return nil
+	}
+	ui.Warn(fmt.Sprintf("%s lists no bin output, only %s", filepath.Base(art.cbuildFile), filepath.Base(art.elfPath)))
+	if ask {
+		if !ui.CanPrompt() {
+			return fmt.Errorf("no binary to flash; %s", addBinOutputHint)
+		}
+		ok, err := ui.Confirm(fmt.Sprintf("Create %s from %s with objcopy?", filepath.Base(art.binPath), filepath.Base(art.elfPath)))
 		if err != nil {
-			ui.Error(fmt.Sprintf("Failed to create bootable image: %v", err))
-			os.Exit(1)
+			return fmt.Errorf("no binary to flash: %w", err)
 		}
-
-		// 4. Flash
-		if err := f.Flash(signedBinPath, tocPath, port, targetCore, flashConfig, flashSlow, flashMethod, flashVerbose, flashErase); err != nil {
-			ui.Error(fmt.Sprintf("Flash failed: %v", err))
-			os.Exit(1)
+		if !ok {
+			return fmt.Errorf("no binary to flash; %s", addBinOutputHint)
 		}
-		return
 	}
+	sp := ui.StartSpinner(fmt.Sprintf("Converting %s to a raw binary...", filepath.Base(art.elfPath)))
+	if err := builder.New(cfg).ToBinary(art.elfPath, art.binPath, builder.FormatELF); err != nil {
+		sp.Fail("Conversion failed")
+		return err
+	}
+	sp.Succeed(fmt.Sprintf("Converted to %s", art.binPath))
+	return nil
 }
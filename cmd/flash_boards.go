@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"alif-cli/internal/flasher"
+	"alif-cli/internal/signer"
+	"alif-cli/internal/targets"
+	"alif-cli/internal/ui"
+)
+
+// boardResult is the outcome of flashing one board of a batch.
+type boardResult struct {
+	board   flasher.Board
+	err     error
+	elapsed time.Duration
+}
+
+// checkAllBoardsFlags rejects flags that pick one board or do not flash
+// one, since --all-boards flashes every connected board over ISP.
+func checkAllBoardsFlags(path string) {
+	if !flashAllBoards {
+		return
+	}
+	switch {
+	case flashMethod != "ISP":
+		ui.Error(fmt.Sprintf("--all-boards needs the ISP method (got %s)", flashMethod))
+	case flashPort != "" || flashSerial != "":
+		ui.Error("--all-boards flashes every connected board; it cannot be combined with --port or --serial")
+	case path != "" || flashesCores():
+		ui.Error("--all-boards flashes a project; it cannot be combined with a binary file or several cores")
+	case flashRemote != "" || flashDryRun || flashListArtifacts:
+		ui.Error("--all-boards cannot be combined with --remote, --dry-run or --list-artifacts")
+	default:
+		return
+	}
+	os.Exit(1)
+}
+
+// runFlashAllBoards generates the image of art once and flashes it to
+// every connected board concurrently. Each board gets its own copy of the
+// toolkit's ISP config and staging directories (a toolkit overlay in a
+// temporary directory), its output lines are prefixed with its serial
+// number, and a summary lists the result per board. The exit status is 1
+// when any board failed.
+func runFlashAllBoards(f *flasher.Flasher, art *projectArtifacts, erase string) {
+	cfg := f.Cfg
+	ui.Header("Boards")
+	boards, err := f.Boards()
+	if err != nil {
+		ui.Error(fmt.Sprintf("Error finding boards: %v", err))
+		os.Exit(1)
+	}
+	for _, b := range boards {
+		ui.Item(b.Name(), b.Port)
+	}
+
+	if err := targets.SyncToolkitConfig(cfg.AlifToolsPath, art.targetCore, ui.Console); err != nil {
+		ui.Warn(fmt.Sprintf("Toolkit sync failed: %v", err))
+	}
+	s := signer.New(cfg)
+	s.Compression = flashCompress
+	s.StateDir = filepath.Join(art.solDir, ".alif")
+	s.ForceImage = flashForceImage
+	s.Output = flashArtifacts
+	s.Section = flashSection
+	images, err := s.SignArtifact(art.solDir, art.binDir, art.binPath, art.coreHint, art.projectHint, flashConfig)
+	if err != nil {
+		ui.Error(fmt.Sprintf("Failed to create bootable image: %v", err))
+		os.Exit(exitCode(err))
+	}
+	f.TargetConfigPath = images.Config
+
+	overlays, err := os.MkdirTemp("", "alif-boards-")
+	if err != nil {
+		ui.Error(fmt.Sprintf("Failed to create a working directory: %v", err))
+		os.Exit(1)
+	}
+	defer os.RemoveAll(overlays)
+
+	// Several boards cannot share the terminal for questions.
+	ui.SetNonInteractive(true)
+	ui.Header(fmt.Sprintf("Flash %d Boards", len(boards)))
+	results := make([]boardResult, len(boards))
+	var wg sync.WaitGroup
+	for i, b := range boards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			toolkit := filepath.Join(overlays, fmt.Sprintf("board%d", i+1))
+			err := flashBoard(f, b, toolkit, images, art.targetCore, erase)
+			results[i] = boardResult{board: b, err: err, elapsed: time.Since(start)}
+		}()
+	}
+	wg.Wait()
+
+	ui.Header("Summary")
+	failed := 0
+	for _, r := range results {
+		if r.err != nil {
+			failed++
+			ui.Error(fmt.Sprintf("%s (%s): %v", r.board.Name(), r.board.Port, r.err))
+			continue
+		}
+		ui.Success(fmt.Sprintf("%s (%s): flashed in %s", r.board.Name(), r.board.Port, r.elapsed.Round(100*time.Millisecond)))
+	}
+	if failed > 0 {
+		ui.Error(fmt.Sprintf("%d of %d boards failed", failed, len(boards)))
+		os.Exit(1)
+	}
+	ui.Success(fmt.Sprintf("All %d boards flashed", len(boards)))
+}
+
+// flashBoard runs the ISP steps of a project flash for one board through
+// its own toolkit overlay: reset into ISP mode, bootloader probe, device
+// check and the flash itself.
+func flashBoard(f *flasher.Flasher, b flasher.Board, toolkit string, images *signer.Artifacts, targetCore, erase string) error {
+	if err := flasher.NewToolkitOverlay(f.Cfg.AlifToolsPath, toolkit); err != nil {
+		return fmt.Errorf("cannot prepare the toolkit: %w", err)
+	}
+	g := f.ForBoard(b, toolkit, ui.Prefixed(fmt.Sprintf("[%s] ", b.Name())))
+	release, err := g.AcquirePort(b.Port)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if flashAutoISP && !flashNoAutoISP {
+		if err := g.EnterISPMode(b.Port); err != nil {
+			return fmt.Errorf("could not put the board into ISP mode: %w", err)
+		}
+	}
+	if !flashSkipProbe {
+		if err := g.ProbeISP(b.Port); err != nil {
+			return err
+		}
+	}
+	if err := g.UpdateISPConfig(b.Port); err != nil {
+		return fmt.Errorf("failed to update ISP config: %w", err)
+	}
+	if !flashNoVerify {
+		if err := g.VerifyConnectedDevice(b.Port, targetCore); err != nil {
+			return err
+		}
+	}
+	return g.Flash(images.Image, images.TOC, flashOptions(b.Port, targetCore, erase))
+}
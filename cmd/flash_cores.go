@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"alif-cli/internal/builder"
+	"alif-cli/internal/config"
+	"alif-cli/internal/flasher"
+	"alif-cli/internal/project"
+	"alif-cli/internal/signer"
+	"alif-cli/internal/targets"
+	"alif-cli/internal/ui"
+)
+
+// flashesCores reports whether the flags ask for several cores at once
+// (--all-cores, or a comma-separated -p list).
+func flashesCores() bool {
+	return flashAllCores || strings.Contains(flashProject, ",")
+}
+
+// runFlashCores signs the context of every selected core into one package
+// with a combined TOC and flashes it over ISP in one operation.
+func runFlashCores(erase string) {
+	if flashMethod != "ISP" {
+		ui.Error(fmt.Sprintf("Flashing several cores needs the ISP method (got %s)", flashMethod))
+		os.Exit(1)
+	}
+	if flashAllCores && flashProject != "" {
+		ui.Error("--all-cores cannot be combined with -p; list the projects instead (-p he_app,hp_app)")
+		os.Exit(1)
+	}
+	if flashTarget != "" {
+		ui.Error("-t cannot be used when flashing several cores; each core has its own target (filter with -p instead)")
+		os.Exit(1)
+	}
+	if flashAppOnly || flashTOCOnly {
+		ui.Error("--app-only and --toc-only cannot be used when flashing several cores")
+		os.Exit(1)
+	}
+
+	cfg := requireConfig()
+	defer lockToolkit(cfg)()
+	arts, err := resolveCoreArtifacts(cfg)
+	if err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(exitCode(err))
+	}
+	solDir := arts[0].solDir
+	for _, art := range arts {
+		if !checkSharedOutDir(solDir, art.context, flashForce) {
+			os.Exit(1)
+		}
+	}
+	if flashVerify {
+		ui.Warn("--verify is not supported when flashing several cores; skipping verification")
+	}
+	if flashSkipIfSame {
+		ui.Warn("--skip-if-same is not supported when flashing several cores; flashing anyway")
+	}
+
+	f := flasher.New(cfg)
+	f.Port = flashPort
+	f.Serial = flashSerial
+	f.NoProbe = flashNoProbe
+	f.NoStablePath = flashNoStablePath
+	f.Baud = flashBaud
+	applyTimeoutFlag(f)
+	f.StateDir = filepath.Join(solDir, ".alif")
+	f.ForgetPort = flashForgetPort
+	f.NoRetry = flashNoRetry
+	f.Reset = flashReset
+
+	ui.Header("Flash Target")
+	port, err := f.SelectPort()
+	if err != nil {
+		ui.Error(fmt.Sprintf("Error identifying port: %v", err))
+		os.Exit(exitCode(err))
+	}
+	release, err := f.AcquirePort(port)
+	if err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+	defer release()
+	enterISPMode(f, port)
+	probeISP(f, port)
+	if err := f.UpdateISPConfig(port); err != nil {
+		ui.Warn(fmt.Sprintf("Failed to update ISP config: %v", err))
+	}
+
+	targetCore := arts[0].targetCore
+	if err := targets.SyncToolkitConfig(cfg.AlifToolsPath, targetCore, ui.Console); err != nil {
+		ui.Warn(fmt.Sprintf("Toolkit sync failed: %v", err))
+	}
+	if !flashNoVerify {
+		if err := f.VerifyConnectedDevice(port, targetCore); err != nil {
+			// VerifyConnectedDevice prints its own failure
+			os.Exit(1)
+		}
+	}
+
+	s := signer.New(cfg)
+	s.Compression = flashCompress
+	s.Output = flashArtifacts
+	var images []signer.CoreImage
+	for _, art := range arts {
+		images = append(images, signer.CoreImage{Core: art.coreHint, Binary: art.binPath, CoreHint: art.coreHint, ProjectHint: art.projectHint})
+	}
+	pkg, err := s.SignCores(solDir, filepath.Join(solDir, "out", "cores"), images, flashConfig)
+	if err != nil {
+		ui.Error(fmt.Sprintf("Failed to create bootable image: %v", err))
+		os.Exit(1)
+	}
+
+	ui.Header("Flash Cores")
+	var coreImages []flasher.CoreImage
+	for i, path := range pkg.Images {
+		coreImages = append(coreImages, flasher.CoreImage{Core: arts[i].coreHint, Path: path})
+	}
+	part := strings.Split(targetCore, ":")[0]
+	if err := f.FlashCores(coreImages, pkg.TOC, port, part, flashSlow, flashVerbose, erase); err != nil {
+		ui.Error(fmt.Sprintf("Flash failed: %v", err))
+		os.Exit(1)
+	}
+	if err := f.RememberPort(port); err != nil {
+		ui.Warn(fmt.Sprintf("Failed to remember port: %v", err))
+	}
+}
+
+// resolveCoreArtifacts resolves one context per core: each filter of a
+// comma-separated -p, or with --all-cores every built context, grouped by
+// the core it builds for. The cores must differ and share one part.
+func resolveCoreArtifacts(cfg *config.Config) ([]*projectArtifacts, error) {
+	cwd, _ := os.Getwd()
+	solDir, err := project.IsSolutionRoot(cwd)
+	if err != nil {
+		return nil, fmt.Errorf("could not find solution (.csolution.yml) in current directory")
+	}
+	b := builder.New(cfg)
+
+	var arts []*projectArtifacts
+	if flashAllCores {
+		ui.Header("Resolve Core Contexts")
+		contexts, err := b.ListContexts(solDir)
+		if err != nil {
+			return nil, err
+		}
+		var cores []string
+		byCore := map[string][]*projectArtifacts{}
+		for _, c := range contexts {
+			// Contexts that were never built have no .cbuild.yml yet.
+			art, err := contextArtifacts(solDir, c, "")
+			if err != nil || art.coreHint == "" {
+				continue
+			}
+			if byCore[art.coreHint] == nil {
+				cores = append(cores, art.coreHint)
+			}
+			byCore[art.coreHint] = append(byCore[art.coreHint], art)
+		}
+		for _, core := range cores {
+			candidates := byCore[core]
+			if len(candidates) > 1 {
+				names := make([]string, len(candidates))
+				for i, art := range candidates {
+					names[i] = art.context
+				}
+				idx, err := ui.Select(fmt.Sprintf("Several contexts build for %s:", core), "Select context (enter number): ", names, "Pass -p <he_project>,<hp_project> to choose them.")
+				if err != nil {
+					return nil, err
+				}
+				candidates = candidates[idx:]
+			}
+			arts = append(arts, candidates[0])
+		}
+	} else {
+		for _, filter := range strings.Split(flashProject, ",") {
+			filter = strings.TrimSpace(filter)
+			if filter == "" {
+				continue
+			}
+			selected, err := b.ResolveContext(solDir, "", filter, "")
+			if err != nil {
+				return nil, err
+			}
+			art, err := contextArtifacts(solDir, selected, filter)
+			if err != nil {
+				return nil, err
+			}
+			arts = append(arts, art)
+		}
+	}
+
+	if len(arts) < 2 {
+		return nil, fmt.Errorf("found %d built context(s) with a core; flashing several cores needs at least two", len(arts))
+	}
+	seen := map[string]string{}
+	part := strings.Split(arts[0].targetCore, ":")[0]
+	ui.Header("Cores")
+	for _, art := range arts {
+		if other, dup := seen[art.coreHint]; dup {
+			return nil, fmt.Errorf("%s and %s both build for %s", other, art.context, art.coreHint)
+		}
+		seen[art.coreHint] = art.context
+		if p := strings.Split(art.targetCore, ":")[0]; p != part {
+			return nil, fmt.Errorf("%s builds for %s, not %s", art.context, p, part)
+		}
+		ui.Item(art.coreHint, art.context)
+	}
+	for _, art := range arts {
+		if err := projectBinary(cfg, art, true); err != nil {
+			return nil, err
+		}
+	}
+	return arts, nil
+}
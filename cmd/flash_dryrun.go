@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"alif-cli/internal/flasher"
+	"alif-cli/internal/signer"
+	"alif-cli/internal/targets"
+	"alif-cli/internal/ui"
+)
+
+// runFlashDryRun walks through a project flash with f.DryRun set: the
+// toolkit sync, the device check and the image generation are described,
+// and Flash prints the files it would stage, the ISP config it would write
+// and every command it would run. The board, the toolkit and the project
+// state are left untouched, and no serial port is locked.
+func runFlashDryRun(f *flasher.Flasher, art *projectArtifacts, port, erase string) {
+	ui.Header("Dry Run")
+	if flashMethod == "ISP" {
+		enterISPMode(f, port)
+		probeISP(f, port)
+	}
+	ui.Item("Would sync", fmt.Sprintf("the toolkit config to %s", art.targetCore))
+	if flashMethod == "ISP" && !flashNoVerify {
+		ui.Item("Would check", "the connected device over ISP (maintenance)")
+	}
+
+	s := signer.New(f.Cfg)
+	s.Compression = flashCompress
+	s.StateDir = filepath.Join(art.solDir, ".alif")
+	s.ForceImage = flashForceImage
+	s.Section = flashSection
+	_, cfgPath, err := targets.ResolveTargetConfig(flashConfig, art.solDir, art.coreHint, art.projectHint, ui.Console)
+	if err != nil {
+		ui.Error(fmt.Sprintf("Configuration error: %v", err))
+		os.Exit(exitCode(err))
+	}
+	f.TargetConfigPath = cfgPath
+	if s.ImageUpToDate(art.binDir, art.binPath, cfgPath) {
+		ui.Item("Image", fmt.Sprintf("%s is up to date; app-gen-toc would be skipped", art.signedBinPath))
+	} else {
+		ui.Item("Would run", fmt.Sprintf("app-gen-toc -f %s (for %s)", filepath.Join(f.Cfg.AlifToolsPath, "staged_config.json"), art.binPath))
+		if _, err := os.Stat(art.tocPath); err != nil {
+			ui.Warn("No image has been generated yet; the steps below use the paths it would have. Run 'alif image' first to see the load addresses.")
+		}
+	}
+
+	ui.Header("Flash (dry run)")
+	if err := f.Flash(art.signedBinPath, art.tocPath, flashOptions(port, art.targetCore, erase)); err != nil {
+		ui.Error(fmt.Sprintf("Flash failed: %v", err))
+		os.Exit(exitCode(err))
+	}
+	ui.Success("Dry run complete; nothing was copied, written or flashed")
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"alif-cli/internal/config"
+	"alif-cli/internal/flasher"
+	"alif-cli/internal/signer"
+	"alif-cli/internal/targets"
+	"alif-cli/internal/ui"
+)
+
+// artifactRow is one file in the --list-artifacts plan.
+type artifactRow struct {
+	path        string
+	dest        string
+	regenerated bool
+}
+
+// listFlashArtifacts runs the flash resolution phases (context, signing
+// config, artifact paths, addresses, port) and prints the resulting plan
+// without staging files, running toolkit tools or opening the board.
+// Prompts are disabled: anything ambiguous is reported and the command
+// exits with status 1.
+func listFlashArtifacts(path string, isBinary bool, cfg *config.Config) {
+	ui.SetNonInteractive(true)
+
+	method := strings.ToUpper(flashMethod)
+	toolsPath := cfg.AlifToolsPath
+	var rows []artifactRow
+	var problems []string
+
+	f := flasher.New(cfg)
+	f.Port = flashPort
+	f.Serial = flashSerial
+	f.NoProbe = true
+	f.NoStablePath = flashNoStablePath
+	f.Addresses = flasher.AddressOverrides{MapPath: flashMap, AppAddr: flashAppAddress, TOCAddr: flashTOCAddress}
+
+	ui.Header("Flash Plan")
+	// A binary is described by the same steps alif flash takes with it:
+	// converted if needed, imaged in the working directory and flashed
+	// with -m like a project.
+	var art *projectArtifacts
+	cleanup := func() {}
+	targetCore := ""
+	if isBinary {
+		ui.Item("Mode", "Binary")
+		art, cleanup = binaryArtifacts(cfg, path)
+		defer cleanup()
+		f.StateDir = binaryStateDir(art.solDir)
+		targetCore = art.targetCore
+		if dev, err := targets.CurrentDevice(toolsPath); err == nil && dev.PartNumber != "" {
+			targetCore = dev.PartNumber + ":" + targetCore
+		}
+	} else {
+		ui.Item("Mode", "Project")
+		var err error
+		art, err = resolveProjectArtifacts(cfg)
+		if err != nil {
+			ui.Error(fmt.Sprintf("%v", err))
+			os.Exit(exitCode(err))
+		}
+		checkSharedOutDir(art.solDir, art.context, true)
+		if !flashForgetPort {
+			f.StateDir = filepath.Join(art.solDir, ".alif")
+		}
+		targetCore = art.targetCore
+	}
+
+	s := signer.New(cfg)
+	s.Compression = flashCompress
+	if !isBinary {
+		s.StateDir = filepath.Join(art.solDir, ".alif")
+	}
+	s.ForceImage = flashForceImage
+	s.Section = flashSection
+	staged := "?"
+	// binaryArtifacts has already reported the config it found.
+	report := ui.Console
+	if isBinary {
+		report = ui.Silent
+	}
+	_, cfgPath, err := targets.ResolveTargetConfig(flashConfig, art.solDir, art.coreHint, art.projectHint, report)
+	f.TargetConfigPath = cfgPath
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("Signing config: %v", err))
+	} else if staged, err = s.StagingPath(cfgPath); err != nil {
+		problems = append(problems, fmt.Sprintf("Signing config: %v", err))
+		staged = "?"
+	}
+	rows = append(rows, artifactRow{path: art.binPath, dest: staged})
+	if cfgPath != "" {
+		rows = append(rows, artifactRow{path: cfgPath, dest: filepath.Join(toolsPath, "staged_config.json")})
+	}
+
+	imageDest := filepath.Join(toolsPath, "build", "images", "alif-img.bin")
+	tocDest := filepath.Join(toolsPath, "AppTocPackage.bin")
+	if method == "JTAG" || method == "PYOCD" || method == "OPENOCD" {
+		imageDest, tocDest = "?", "?"
+		if plan, err := f.PlanAddresses(art.binDir, targetCore); err != nil {
+			problems = append(problems, fmt.Sprintf("Load addresses: %v", err))
+		} else {
+			imageDest = fmt.Sprintf("%s (%s)", plan.App, filepath.Base(plan.AppSource))
+			tocDest = fmt.Sprintf("%s (%s)", plan.TOC, filepath.Base(plan.TOCSource))
+		}
+	}
+	regenerate := cfgPath == "" || !s.ImageUpToDate(art.binDir, art.binPath, cfgPath)
+	rows = append(rows,
+		artifactRow{path: art.signedBinPath, dest: imageDest, regenerated: regenerate},
+		artifactRow{path: art.tocPath, dest: tocDest, regenerated: regenerate},
+	)
+
+	ui.Header("Flash Target")
+	ui.Item("Method", method)
+	if _, err := f.SelectPort(); err != nil {
+		problems = append(problems, fmt.Sprintf("Port: %v", err))
+	}
+
+	ui.Header("Artifacts")
+	cwd, _ := os.Getwd()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "  FILE\tSIZE\tSHA256\tDESTINATION\tREGENERATED")
+	for _, r := range rows {
+		name := r.path
+		if rel, err := filepath.Rel(cwd, r.path); err == nil && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+		size, sum := "missing", "-"
+		if data, err := os.ReadFile(r.path); err == nil {
+			size = formatBytes(uint64(len(data)))
+			digest := sha256.Sum256(data)
+			sum = hex.EncodeToString(digest[:])[:12]
+		}
+		regen := "no"
+		if r.regenerated {
+			regen = "yes"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", name, size, sum, r.dest, regen)
+	}
+	w.Flush()
+
+	if len(problems) > 0 {
+		fmt.Println()
+		for _, p := range problems {
+			ui.Warn(p)
+		}
+		cleanup()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"alif-cli/internal/flasher"
+	"alif-cli/internal/targets"
+	"alif-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var packageTarget string
+
+var flashPackageCmd = &cobra.Command{
+	Use:   "package <dir>",
+	Short: "Flash an image and TOC generated earlier, without regenerating them",
+	Long: `Flashes alif-img.bin and AppTocPackage.bin from a folder, together with their
+.sign/.crt files and app-package-map.txt, as 'alif image' or 'alif flash
+--remote' left them. The toolkit is synced to --target, the part and core the
+image was made for, before the device check and the write.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runFlashPackage(args[0])
+	},
+}
+
+func init() {
+	flashPackageCmd.Flags().StringVar(&packageTarget, "target", "", "Part and core the image was made for (e.g. AE722F80F55D5LS:M55_HE)")
+	flashPackageCmd.Flags().StringVarP(&flashMethod, "method", "m", "ISP", "Loading method (ISP, JTAG, PYOCD or OPENOCD)")
+	flashPackageCmd.Flags().BoolVar(&flashSlow, "slow", false, "Disable dynamic baud rate switching (more stable)")
+	flashPackageCmd.Flags().BoolVar(&flashNoRetry, "no-retry", false, "Do not rerun with --slow after a transient ISP failure")
+	flashPackageCmd.Flags().BoolVarP(&flashVerbose, "verbose", "v", false, "Enable verbose output")
+	flashPackageCmd.Flags().BoolVarP(&flashErase, "erase", "e", false, "Erase the target device application area before flashing")
+	flashPackageCmd.Flags().BoolVar(&flashEraseAll, "erase-all", false, "Erase the whole application MRAM before flashing (ISP only; asks first)")
+	flashPackageCmd.Flags().BoolVarP(&flashYes, "yes", "y", false, "Do not ask before --erase-all")
+	flashPackageCmd.Flags().BoolVar(&flashNoVerify, "no-verify", false, "Skip checking the connected hardware device")
+	flashPackageCmd.Flags().BoolVar(&flashNoVerify, "nv", false, "Skip checking the connected hardware device (alias for --no-verify)")
+	flashPackageCmd.Flags().BoolVar(&flashVerify, "verify", false, "Check the image and TOC after flashing")
+	flashPackageCmd.Flags().BoolVar(&flashForce, "force", false, "Flash even when the image does not fit the device's MRAM")
+	flashPackageCmd.Flags().BoolVar(&flashSkipIfSame, "skip-if-same", false, "Skip flashing when the image and TOC on the device already match (needs J-Link)")
+	flashPackageCmd.Flags().BoolVar(&flashAppOnly, "app-only", false, "Write only the application image")
+	flashPackageCmd.Flags().BoolVar(&flashTOCOnly, "toc-only", false, "Write only the TOC package")
+	flashPackageCmd.Flags().BoolVar(&flashAutoISP, "auto-isp", true, "Reset the board into ISP mode over the serial DTR/RTS lines and wait for the SE-UART banner before an ISP flash")
+	flashPackageCmd.Flags().BoolVar(&flashNoAutoISP, "no-auto-isp", false, "Do not reset the board before an ISP flash; put it into ISP mode by hand")
+	flashPackageCmd.Flags().BoolVar(&flashSkipProbe, "skip-probe", false, "Do not check that the ISP bootloader answers on the port before an ISP flash")
+	flashPackageCmd.Flags().StringVarP(&flashDevice, "device", "d", "", deviceFlagUsage)
+	flashPackageCmd.Flags().StringVar(&flashReset, "reset", flasher.ResetRun, "What the target does after flashing: run, halt (debug probes only) or none")
+	flashPackageCmd.MarkFlagRequired("target")
+	flashCmd.AddCommand(flashPackageCmd)
+}
+
+func runFlashPackage(dir string) {
+	if err := flasher.ValidateBaud(flashBaud); err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+	flashMethod = strings.ToUpper(flashMethod)
+	switch flashMethod {
+	case "ISP", "JTAG", "PYOCD", "OPENOCD":
+	default:
+		ui.Error(fmt.Sprintf("Unsupported method '%s' (use ISP, JTAG, PYOCD or OPENOCD)", flashMethod))
+		os.Exit(1)
+	}
+	if flashAppOnly && flashTOCOnly {
+		ui.Error("--app-only cannot be combined with --toc-only")
+		os.Exit(1)
+	}
+	flashReset = parseResetFlag()
+	erase := eraseArea()
+
+	dir, _ = filepath.Abs(dir)
+	imagePath := filepath.Join(dir, "alif-img.bin")
+	tocPath := filepath.Join(dir, "AppTocPackage.bin")
+	for _, path := range []string{imagePath, tocPath} {
+		if _, err := os.Stat(path); err != nil {
+			ui.Error(fmt.Sprintf("%s not found; run 'alif image' to create it", path))
+			os.Exit(1)
+		}
+	}
+
+	cfg := requireConfig()
+	defer lockToolkit(cfg)()
+	f := flasher.New(cfg)
+	f.Port = flashPort
+	f.Serial = flashSerial
+	f.NoProbe = flashNoProbe
+	f.NoStablePath = flashNoStablePath
+	f.Baud = flashBaud
+	applyJLinkFlags(f)
+	applyTimeoutFlag(f)
+	f.Verify = flashVerify
+	f.SkipIfSame = flashSkipIfSame
+	f.Force = flashForce
+	f.NoRetry = flashNoRetry
+	f.PyOCDTarget = flashDevice
+	f.Reset = flashReset
+	if flashAppOnly {
+		f.Only = flasher.OnlyApp
+	} else if flashTOCOnly {
+		f.Only = flasher.OnlyTOC
+	}
+
+	ui.Header("Flash Target")
+	ui.Item("Package", dir)
+	port, err := f.SelectPort()
+	if err != nil {
+		ui.Error(fmt.Sprintf("Error identifying port: %v", err))
+		os.Exit(exitCode(err))
+	}
+	if flashMethod == "ISP" {
+		release, err := f.AcquirePort(port)
+		if err != nil {
+			ui.Error(fmt.Sprintf("%v", err))
+			os.Exit(1)
+		}
+		defer release()
+		enterISPMode(f, port)
+		probeISP(f, port)
+	}
+
+	if err := targets.SyncToolkitConfig(cfg.AlifToolsPath, packageTarget, ui.Console); err != nil {
+		ui.Warn(fmt.Sprintf("Toolkit sync failed: %v", err))
+	}
+	if flashMethod == "ISP" && !flashNoVerify {
+		if err := f.UpdateISPConfig(port); err != nil {
+			ui.Warn(fmt.Sprintf("Failed to update ISP config: %v", err))
+		}
+		if err := f.VerifyConnectedDevice(port, packageTarget); err != nil {
+			// VerifyConnectedDevice prints its own failure
+			os.Exit(1)
+		}
+	}
+
+	if err := f.Flash(imagePath, tocPath, flashOptions(port, packageTarget, erase)); err != nil {
+		ui.Error(fmt.Sprintf("Flash failed: %v", err))
+		os.Exit(exitCode(err))
+	}
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"alif-cli/internal/flasher"
+	"alif-cli/internal/targets"
+	"alif-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var rawAddress string
+var rawMethod string
+var rawDevice string
+var rawForce bool
+var rawVerify bool
+var rawVerbose bool
+
+var flashRawCmd = &cobra.Command{
+	Use:   "raw <file>",
+	Short: "Write a file to MRAM at a fixed address without a TOC",
+	Long: `Writes raw bytes (filesystem images, data blobs, model weights) to the given
+MRAM address. No image or TOC is generated.
+
+The address range is checked against the application MRAM area of the device
+the toolkit is configured for. Writes overlapping the TOC region are refused
+unless --force is given.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runFlashRaw(args[0])
+	},
+}
+
+func init() {
+	flashRawCmd.Flags().StringVarP(&rawAddress, "address", "a", "", "MRAM address to write at (e.g. 0x80300000)")
+	flashRawCmd.Flags().StringVarP(&rawMethod, "method", "m", "ISP", "Loading method (ISP or JTAG)")
+	flashRawCmd.Flags().StringVarP(&rawDevice, "device", "d", "", "J-Link device name (JTAG only)")
+	flashRawCmd.Flags().BoolVar(&rawForce, "force", false, "Allow writes overlapping the TOC region")
+	flashRawCmd.Flags().BoolVar(&rawVerify, "verify", false, "Read the data back after writing (JTAG only)")
+	flashRawCmd.Flags().BoolVarP(&rawVerbose, "verbose", "v", false, "Enable verbose output")
+	flashRawCmd.MarkFlagRequired("address")
+	flashCmd.AddCommand(flashRawCmd)
+}
+
+func runFlashRaw(path string) {
+	method := strings.ToUpper(rawMethod)
+	if method != "ISP" && method != "JTAG" {
+		ui.Error(fmt.Sprintf("Unsupported method '%s' (use ISP or JTAG)", rawMethod))
+		os.Exit(1)
+	}
+	if err := flasher.ValidateBaud(flashBaud); err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		ui.Error(fmt.Sprintf("File not found: %s", path))
+		os.Exit(1)
+	}
+
+	addr, err := flasher.ParseAddress(rawAddress)
+	if err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+
+	cfg := requireConfig()
+	defer lockToolkit(cfg)()
+
+	ui.Header("Raw MRAM Write")
+	dev, err := targets.CurrentDevice(cfg.AlifToolsPath)
+	if err != nil {
+		ui.Error(fmt.Sprintf("Cannot determine MRAM layout: %v", err))
+		os.Exit(1)
+	}
+
+	app := flasher.AppRegion(dev)
+	toc := flasher.TOCRegion(dev, filepath.Join(cfg.AlifToolsPath, "build", "app-package-map.txt"))
+	ui.Item("Device", dev.DisplayName())
+	ui.Item("File", filepath.Base(path))
+	ui.Item("Range", flasher.Region{Start: addr, End: addr + uint64(info.Size())}.String())
+	ui.Item("Method", method)
+
+	if err := flasher.ValidateRawWrite(addr, uint64(info.Size()), app, toc, rawForce); err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+
+	f := flasher.New(cfg)
+	f.Port = flashPort
+	f.Serial = flashSerial
+	f.NoProbe = flashNoProbe
+	f.NoStablePath = flashNoStablePath
+	f.Baud = flashBaud
+	applyJLinkFlags(f)
+	applyTimeoutFlag(f)
+	if method == "ISP" {
+		port, err := f.SelectPort()
+		if err != nil {
+			ui.Error(fmt.Sprintf("Error identifying port: %v", err))
+			os.Exit(exitCode(err))
+		}
+		if err := f.UpdateISPConfig(port); err != nil {
+			ui.Error(fmt.Sprintf("Failed to update ISP config: %v", err))
+			os.Exit(1)
+		}
+	}
+
+	if err := f.WriteRaw(path, addr, method, dev.PartNumber, rawDevice, rawVerify, rawVerbose); err != nil {
+		ui.Error(fmt.Sprintf("Raw write failed: %v", err))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"alif-cli/internal/config"
+	"alif-cli/internal/flasher"
+	"alif-cli/internal/remote"
+	"alif-cli/internal/signer"
+	"alif-cli/internal/targets"
+	"alif-cli/internal/ui"
+)
+
+// runFlashRemote creates the image of a project locally, copies it to the
+// --remote host and flashes it there with 'alif flash package', which
+// stages it and runs app-write-mram on the host. The host's output is
+// streamed back, and its exit status becomes ours.
+func runFlashRemote(cfg *config.Config, art *projectArtifacts, erase string) {
+	host, err := remote.New(flashRemote)
+	if err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+
+	// app-gen-toc reads the device from the local toolkit config.
+	if err := targets.SyncToolkitConfig(cfg.AlifToolsPath, art.targetCore, ui.Console); err != nil {
+		ui.Warn(fmt.Sprintf("Toolkit sync failed: %v", err))
+	}
+	s := signer.New(cfg)
+	s.Compression = flashCompress
+	s.StateDir = filepath.Join(art.solDir, ".alif")
+	s.ForceImage = flashForceImage
+	s.Output = flashArtifacts
+	s.Section = flashSection
+	images, err := s.SignArtifact(art.solDir, art.binDir, art.binPath, art.coreHint, art.projectHint, flashConfig)
+	if err != nil {
+		ui.Error(fmt.Sprintf("Failed to create bootable image: %v", err))
+		os.Exit(exitCode(err))
+	}
+
+	ui.Header("Remote Flash")
+	ui.Item("Host", host.Target)
+	dir, err := host.TempDir()
+	if err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+
+	var files []string
+	for _, path := range []string{images.Image, images.TOC} {
+		for _, suffix := range []string{"", ".sign", ".crt"} {
+			if _, err := os.Stat(path + suffix); err == nil {
+				files = append(files, path+suffix)
+			}
+		}
+	}
+	if _, err := os.Stat(images.Map); err == nil {
+		files = append(files, images.Map)
+	}
+	sp := ui.StartSpinner(fmt.Sprintf("Copying %d files to %s...", len(files), host.Target))
+	if err := host.Copy(files, dir); err != nil {
+		sp.Fail("Copy failed")
+		host.Remove(dir)
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+	sp.Succeed(fmt.Sprintf("Copied to %s:%s", host.Target, dir))
+
+	err = host.Run(remoteFlashArgs(dir, art.targetCore, erase), ui.StdoutIsTerminal())
+	host.Remove(dir)
+	var exitErr *remote.ExitError
+	if errors.As(err, &exitErr) {
+		ui.Error(fmt.Sprintf("Remote flash on %s failed (exit status %d)", host.Target, exitErr.Code))
+		os.Exit(exitErr.Code)
+	}
+	if err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+}
+
+// remoteFlashArgs is the 'alif flash package' command line for the host,
+// carrying over the flash flags that apply there. --erase-all was already
+// confirmed here, so it goes with --yes.
+func remoteFlashArgs(dir, targetCore, erase string) []string {
+	args := []string{flashRemoteAlif, "flash", "package", dir, "--target", targetCore, "--method", flashMethod}
+	switch erase {
+	case flasher.EraseApp:
+		args = append(args, "--erase")
+	case flasher.EraseAll:
+		args = append(args, "--erase-all", "--yes")
+	}
+	values := []struct{ flag, value string }{
+		{"--port", flashPort},
+		{"--serial", flashSerial},
+		{"--jlink-if", flashJLinkIf},
+		{"--probe-serial", flashProbeSerial},
+		{"--device", flashDevice},
+	}
+	if flashReset != flasher.ResetRun {
+		values = append(values, struct{ flag, value string }{"--reset", flashReset})
+	}
+	if flashBaud != flasher.DefaultISPBaud {
+		values = append(values, struct{ flag, value string }{"--baud", strconv.Itoa(flashBaud)})
+	}
+	if flashJLinkSpeed > 0 {
+		values = append(values, struct{ flag, value string }{"--jlink-speed", strconv.Itoa(flashJLinkSpeed)})
+	}
+	if flashTimeout > 0 {
+		values = append(values, struct{ flag, value string }{"--timeout", flashTimeout.String()})
+	}
+	for _, v := range values {
+		if v.value != "" {
+			args = append(args, v.flag, v.value)
+		}
+	}
+	switches := []struct {
+		flag string
+		set  bool
+	}{
+		{"--slow", flashSlow},
+		{"--no-retry", flashNoRetry},
+		{"--verbose", flashVerbose},
+		{"--no-verify", flashNoVerify},
+		{"--verify", flashVerify},
+		{"--skip-if-same", flashSkipIfSame},
+		{"--force", flashForce},
+		{"--app-only", flashAppOnly},
+		{"--toc-only", flashTOCOnly},
+		{"--no-probe", flashNoProbe},
+		{"--no-stable-path", flashNoStablePath},
+		{"--no-auto-isp", !flashAutoISP || flashNoAutoISP},
+		{"--skip-probe", flashSkipProbe},
+	}
+	for _, s := range switches {
+		if s.set {
+			args = append(args, s.flag)
+		}
+	}
+	return args
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"alif-cli/internal/config"
+	"alif-cli/internal/flasher"
+	"alif-cli/internal/toolkit"
+	"alif-cli/internal/ui"
+)
+
+// flashReport is the report --report writes, or nil without it.
+var flashReport *flasher.FlashReport
+
+// flashReportMu guards flashReport against the report sink, which runs
+// on whichever goroutine emits.
+var flashReportMu sync.Mutex
+
+// errUnfinished stands in for the error of a flash that has not ended yet,
+// so a run that is killed still leaves a failed report behind.
+var errUnfinished = errors.New("flash did not finish")
+
+// startFlashReport begins the --report document. It is written right away
+// as failed and rewritten whenever something fails, so every way out of
+// the command, including os.Exit, leaves a row for the provisioning
+// database; finishFlashReport records the outcome.
+func startFlashReport(cfg *config.Config) {
+	if flashReportPath == "" {
+		return
+	}
+	r := flasher.NewFlashReport(flashMethod)
+	r.ToolkitVersion = toolkit.Version(cfg.AlifToolsPath)
+	r.Finish(errUnfinished)
+	flashReport = r
+	writeFlashReport()
+	ui.AddSink(flashReportSink{})
+}
+
+// updateFlashReport changes the report under its lock and saves it.
+func updateFlashReport(fn func(r *flasher.FlashReport)) {
+	if flashReport == nil {
+		return
+	}
+	flashReportMu.Lock()
+	fn(flashReport)
+	flashReportMu.Unlock()
+	writeFlashReport()
+}
+
+// finishFlashReport records the outcome of the flash and reports where the
+// document went.
+func finishFlashReport(err error) {
+	if flashReport == nil {
+		return
+	}
+	updateFlashReport(func(r *flasher.FlashReport) { r.Finish(err) })
+	ui.Item("Report", flashReportPath)
+}
+
+func writeFlashReport() {
+	flashReportMu.Lock()
+	defer flashReportMu.Unlock()
+	if err := flashReport.Write(flashReportPath); err != nil {
+		// Called from the sink too, where reporting through ui would deadlock.
+		fmt.Fprintf(os.Stderr, "cannot write flash report %s: %v\n", flashReportPath, err)
+	}
+}
+
+// flashReportSink keeps the report's error at the last failure shown.
+type flashReportSink struct{}
+
+func (flashReportSink) Emit(e ui.Event) {
+	if e.Kind != ui.EventError && e.Kind != ui.EventTaskFail {
+		return
+	}
+	flashReportMu.Lock()
+	done := flashReport.Status == flasher.ReportSuccess
+	if !done {
+		flashReport.Finish(errors.New(e.Text))
+	}
+	flashReportMu.Unlock()
+	if !done {
+		writeFlashReport()
+	}
+}
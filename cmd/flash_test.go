@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"alif-cli/internal/config"
+	"alif-cli/internal/flasher"
+)
+
+// flashBench sets up a home with a configured toolkit whose app-write-mram
+// logs its arguments to "runs", and a folder with a binary and its
+// signing config. It returns the toolkit and the binary folder.
+func flashBench(t *testing.T) (tools, dir string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	home, tools, dir := t.TempDir(), t.TempDir(), t.TempDir()
+	writeFiles(t, map[string]string{
+		filepath.Join(home, ".alif", "config.yaml"):    "alif_tools_path: " + tools + "\n",
+		filepath.Join(tools, "utils", "global-cfg.db"): `{"DEVICE": {"Part#": "E7 (AE722F80F55D5LS) - 5.5 MRAM / 13.5 SRAM", "Revision": "B2"}}`,
+		filepath.Join(tools, "isp_config_data.cfg"):    "comport /dev/ttyACM9\nbaudrate 115200\n",
+		filepath.Join(tools, "app-gen-toc"): `#!/bin/sh
+mkdir -p build
+printf 'TOC' > build/AppTocPackage.bin
+printf '0x80000000  0x00000010  alif-img.bin\n' > build/app-package-map.txt
+`,
+		filepath.Join(tools, "app-write-mram"): "#!/bin/sh\necho \"$*\" >> \"$(dirname \"$0\")/runs\"\necho Done\n",
+		filepath.Join(dir, "blinky.bin"):       "0123456789abcdef",
+		filepath.Join(dir, "he.json"):          `{"USER_APP": {"binary": "alif-img.bin", "mramAddress": "0x80000000", "cpu_id": "M55_HE"}}`,
+	})
+	t.Setenv("HOME", home)
+	return tools, dir
+}
+
+func TestFlashBinaryArgs(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags []string
+		runs  []string
+	}{
+		{"defaults", nil, []string{"-p"}},
+		{"slow", []string{"--slow"}, []string{"-p -s"}},
+		{"verbose", []string{"-v"}, []string{"-p -v"}},
+		{"erase", []string{"--erase"}, []string{"-e APP", "-p"}},
+		{"slow, verbose and erase", []string{"--slow", "--verbose", "-e"}, []string{"-e APP -v", "-p -s -v"}},
+		{"erase all", []string{"--erase-all", "--yes", "--slow"}, []string{"-e ALL", "-p -s"}},
+	}
+	for _, tt := range tests {
+		tools, dir := flashBench(t)
+		args := append([]string{"flash", "blinky.bin", "-c", "he.json", "--port", "/dev/ttyALIF0",
+			"--no-auto-isp", "--skip-probe", "--no-verify", "--no-probe", "--reset", "none"}, tt.flags...)
+		out, code := runAlif(t, dir, args...)
+		if code != 0 {
+			t.Errorf("%s: exit code %d, output:\n%s", tt.name, code, out)
+			continue
+		}
+		runs, _ := os.ReadFile(filepath.Join(tools, "runs"))
+		if got := strings.Split(strings.TrimSpace(string(runs)), "\n"); strings.Join(got, "|") != strings.Join(tt.runs, "|") {
+			t.Errorf("%s: app-write-mram ran with %q, want %q", tt.name, got, tt.runs)
+		}
+		// The port reaches the toolkit through UpdateISPConfig.
+		if cfg, _ := os.ReadFile(filepath.Join(tools, "isp_config_data.cfg")); !strings.HasPrefix(string(cfg), "comport /dev/ttyALIF0\n") {
+			t.Errorf("%s: isp_config_data.cfg = %q", tt.name, cfg)
+		}
+	}
+}
+
+func TestFlashListArtifactsBinary(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags []string
+		code  int
+		want  []string
+	}{
+		{"ISP", nil, 0, []string{"Method:", "ISP", "WORK/alif-img.bin", "WORK/AppTocPackage.bin"}},
+		{"JTAG", []string{"-m", "JTAG", "--app-address", "0x80000000", "--toc-address", "0x8057F000"}, 0, []string{
+			"JTAG", "0x80000000 (--app-address)", "0x8057F000 (--toc-address)",
+		}},
+	}
+	for _, tt := range tests {
+		tools, dir := flashBench(t)
+		work := filepath.Join(t.TempDir(), "work")
+		args := append([]string{"flash", "blinky.bin", "-c", "he.json", "--port", "/dev/ttyALIF0", "--list-artifacts", "--working-dir", work}, tt.flags...)
+		out, code := runAlif(t, dir, args...)
+		out = strings.ReplaceAll(out, work, "WORK")
+		if code != tt.code {
+			t.Errorf("%s: exit code %d, want %d; output:\n%s", tt.name, code, tt.code, out)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: output lacks %q:\n%s", tt.name, want, out)
+			}
+		}
+		if strings.Contains(out, filepath.Join(tools, "build", "images", "blinky.bin")) {
+			t.Errorf("%s: binary planned into the toolkit:\n%s", tt.name, out)
+		}
+	}
+}
+
+func TestJLinkDevicePrecedence(t *testing.T) {
+	tests := []struct {
+		name      string
+		flag      string
+		toolchain string
+		device    string
+		source    string
+	}{
+		{"--device wins", "AE722F80F55D5_M55_HP", "jlink_device: AE722F80F55D5_M55_HE\n", "AE722F80F55D5_M55_HP", "--device"},
+		{"toolchain.yml", "", "jlink_device: AE722F80F55D5_M55_HE\n", "AE722F80F55D5_M55_HE", "toolchain.yml"},
+		// JLinkDevices.xml is consulted later, when the flash resolves it.
+		{"neither", "", "gcc: tools/gcc\n", "", ""},
+	}
+	defer func(device string) { flashDevice = device }(flashDevice)
+	for _, tt := range tests {
+		solution := t.TempDir()
+		writeFiles(t, map[string]string{
+			filepath.Join(solution, "app.csolution.yml"):      "solution:\n",
+			filepath.Join(solution, ".alif", "toolchain.yml"): tt.toolchain,
+		})
+		t.Chdir(solution)
+		flashDevice = tt.flag
+		f := flasher.New(&config.Config{})
+		applyJLinkFlags(f)
+		if f.JLinkDevice != tt.device || f.JLinkDeviceSource != tt.source {
+			t.Errorf("%s: device %q from %q, want %q from %q", tt.name, f.JLinkDevice, f.JLinkDeviceSource, tt.device, tt.source)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The CLI exits the process on failure, so each run re-executes the test
+// binary with the command line in alifArgsEnv, one argument per line.
+const alifArgsEnv = "ALIF_TEST_ARGS"
+
+// TestHelperAlif is not a test: it runs the alif command line it is given.
+func TestHelperAlif(t *testing.T) {
+	args := os.Getenv(alifArgsEnv)
+	if args == "" {
+		t.Skip("helper process")
+	}
+	rootCmd.SetArgs(strings.Split(args, "\n"))
+	Execute()
+	os.Exit(0)
+}
+
+// runAlif runs alif with args in dir and returns its stdout and exit code.
+func runAlif(t *testing.T, dir string, args ...string) (string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperAlif$")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), alifArgsEnv+"="+strings.Join(args, "\n"))
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	err := cmd.Run()
+	code := 0
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return stdout.String(), code
+}
+
+// writeFiles creates each path with its content, and the folders above it.
+func writeFiles(t *testing.T, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -4,35 +4,74 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
-	"alif-cli/internal/config"
+	"alif-cli/internal/project"
 	"alif-cli/internal/signer"
+	"alif-cli/internal/toc"
 	"alif-cli/internal/ui"
 
 	"github.com/spf13/cobra"
 )
 
 var imageConfig string
+var imageCompress string
+var imageAll bool
+var imageTarget string
+var imageType string
+var imageSHA256 string
+var imageArtifacts string
+var imageKeepBin bool
+var imageWorkingDir string
+var imageKeep bool
+var imageSection string
+var imageForce bool
 
 var imageCmd = &cobra.Command{
-	Use:   "image <binary_file>",
+	Use:   "image <binary_file|elf|hex|url>",
 	Short: "Create a bootable firmware image (package/sign)",
 	Long: `Packages a raw binary into a bootable image (alif-img.bin) and generates the TOC (AppTocPackage.bin).
 This step is required for the device to boot the application.
-Use -c to specify a configuration file, or let the tool auto-detect one.`,
-	Args: cobra.ExactArgs(1),
+Use -c to specify a configuration file, or let the tool auto-detect one.
+An .elf or Intel .hex file is converted to a raw binary with
+arm-none-eabi-objcopy first.
+
+With --all, every built context of the solution in the current directory is
+packaged (optionally narrowed by --target and --type).`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if imageAll {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
+		if imageAll {
+			runImageAll()
+			return
+		}
 		runImage(args[0])
 	},
 }
 
 func init() {
 	imageCmd.Flags().StringVarP(&imageConfig, "config", "c", "", "Configuration file (JSON)")
+	imageCmd.Flags().StringVar(&imageSection, "section", "", "Config section that receives the binary when the config describes several images")
+	addCompressFlag(imageCmd, &imageCompress)
+	addArtifactsFlag(imageCmd, &imageArtifacts)
+	imageCmd.Flags().BoolVar(&imageAll, "all", false, "Create images for every built context in the solution")
+	imageCmd.Flags().StringVar(&imageTarget, "target", "", "Target filter for --all (e.g. 'E7-HE')")
+	addWorkingDirFlag(imageCmd, &imageWorkingDir, "Directory to put the generated image, TOC and package map in (default: a new temporary directory)")
+	imageCmd.Flags().BoolVar(&imageKeep, "keep", false, "Keep the temporary working directory even when packaging fails")
+	imageCmd.Flags().BoolVar(&imageKeepBin, "keep-bin", false, "Keep the .bin converted from an .elf or .hex input next to it")
+	imageCmd.Flags().BoolVar(&imageForce, "force", false, "Package the input even when it looks like an image app-gen-toc already made")
+	imageCmd.Flags().StringVar(&imageSHA256, "sha256", "", "Expected SHA-256 of a binary given as an http(s) URL")
+	imageCmd.Flags().StringVar(&imageType, "type", "", "Build type filter for --all (e.g. 'debug')")
 	rootCmd.AddCommand(imageCmd)
 }
 
 func runImage(binPath string) {
 	// 1. Validate Input
+	binPath = localArtifact(binPath, imageSHA256)
 	absBinPath, err := filepath.Abs(binPath)
 	if err != nil {
 		ui.Error(fmt.Sprintf("Error resolving binary path: %v", err))
@@ -43,22 +82,89 @@ func runImage(binPath string) {
 		os.Exit(1)
 	}
 
-	cfg, _ := config.LoadConfig()
-	if cfg == nil || cfg.AlifToolsPath == "" {
-		ui.Error("Alif CLI not configured. Run 'alif setup' first.")
-		os.Exit(1)
-	}
+	cfg := requireConfig()
+	checkNotPackaged(cfg.AlifToolsPath, absBinPath, imageForce)
 
-	workDir := filepath.Dir(absBinPath)
+	// The image is made in the working directory (--working-dir, or a new
+	// temporary one), as for a binary flash; an explicit --artifacts still
+	// decides where the artifacts go. Signing configs are looked up next
+	// to the input.
+	inputDir := filepath.Dir(absBinPath)
+	outDir, cleanupDir := binaryWorkDir(imageWorkingDir, imageKeep)
+	convertDir := ""
+	if imageWorkingDir != "" {
+		convertDir = outDir
+		if imageArtifacts != signer.OutputBuildDir {
+			ui.Warn(fmt.Sprintf("--artifacts %s takes precedence over --working-dir for the generated files", imageArtifacts))
+		}
+	}
+	absBinPath, cleanupBin := rawBinary(cfg, absBinPath, convertDir, imageKeepBin)
+	defer cleanupBin()
 
 	// signer.SignArtifact prints its own UI Header ("Create Bootable Image")
 	s := signer.New(cfg)
+	s.Compression = imageCompress
+	s.Output = imageArtifacts
+	s.Section = imageSection
 	// targetCore is unused in SignArtifact/ResolveTargetConfig if explicit config passed
-	tocPath, err := s.SignArtifact(workDir, workDir, absBinPath, "", "", imageConfig)
+	art, err := s.SignArtifact(inputDir, outDir, absBinPath, "", "", imageConfig)
 	if err != nil {
+		cleanupBin()
+		cleanupDir()
 		ui.Error(fmt.Sprintf("Failed to create image: %v", err))
+		os.Exit(exitCode(err))
+	}
+	// The working directory holds the artifacts unless --artifacts sent
+	// them elsewhere; only then is a temporary one removed.
+	if imageArtifacts == signer.OutputBuildDir {
+		ui.Item("Artifacts", outDir)
+	} else {
+		defer cleanupDir()
+	}
+
+	ui.Success(fmt.Sprintf("Image created successfully: %s", art.TOC))
+}
+
+// checkNotPackaged stops when binPath looks like an image app-gen-toc
+// already made: a file inside the toolkit, or one carrying an APP TOC or
+// signature. Wrapped in another TOC it would not boot. With force the
+// warning is shown and packaging goes ahead.
+func checkNotPackaged(toolkit, binPath string, force bool) {
+	var reasons []string
+	if toc.InsideDir(binPath, toolkit) {
+		reasons = append(reasons, fmt.Sprintf("it lies inside the toolkit (%s), where generated images are written", toolkit))
+	}
+	if reason, err := toc.Detect(binPath); err == nil && reason != "" {
+		reasons = append(reasons, reason)
+	}
+	if len(reasons) == 0 {
+		return
+	}
+	ui.Warn(fmt.Sprintf("%s appears to be an already packaged image: %s", filepath.Base(binPath), strings.Join(reasons, "; ")))
+	if force {
+		ui.Warn("Packaging it again because of --force")
+		return
+	}
+	ui.Error("Wrapping a packaged image in another TOC gives an image that does not boot. Pass the application's raw .bin, or --force to package it anyway")
+	os.Exit(1)
+}
+
+func runImageAll() {
+	solDir, err := project.IsSolutionRoot("")
+	if err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
 		os.Exit(1)
 	}
 
-	ui.Success(fmt.Sprintf("Image created successfully: %s", filepath.Base(tocPath)))
+	cfg := requireConfig()
+
+	ok := runWorkspace(solDir, cfg, workspaceOptions{
+		Target:    imageTarget,
+		BuildType: imageType,
+		Ops:       []string{opImage},
+		Compress:  imageCompress,
+	})
+	if !ok {
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestImageSections(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	home, tools, project := t.TempDir(), t.TempDir(), t.TempDir()
+	writeFiles(t, map[string]string{
+		filepath.Join(home, ".alif", "config.yaml"):    "alif_tools_path: " + tools + "\n",
+		filepath.Join(tools, "utils", "global-cfg.db"): `{"DEVICE": {"Part#": "E7 (AE722F80F55D5LS) - 5.5 MRAM / 13.5 SRAM", "Revision": "B2"}}`,
+		filepath.Join(tools, "app-gen-toc"): `#!/bin/sh
+mkdir -p build
+printf 'TOC' > build/AppTocPackage.bin
+printf '0x80000000  0x00000010  alif-img.bin\n' > build/app-package-map.txt
+`,
+		filepath.Join(project, "blinky.bin"): "0123456789abcdef",
+		filepath.Join(project, "one.json"):   `{"USER_APP": {"binary": "alif-img.bin", "mramAddress": "0x80000000", "cpu_id": "M55_HE"}}`,
+		filepath.Join(project, "two.json"): `{"HP_APP": {"binary": "hp.bin", "mramAddress": "0x80200000", "cpu_id": "M55_HP"},
+	"HE_APP": {"binary": "he.bin", "mramAddress": "0x80000000", "cpu_id": "M55_HE"}}`,
+		filepath.Join(project, "none.json"): `{"DEVICE": {"disable_wdt": true}}`,
+	})
+	t.Setenv("HOME", home)
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+		want string
+	}{
+		{"one section", []string{"-c", "one.json"}, 0, "Image created successfully"},
+		{"two sections", []string{"-c", "two.json"}, 1, "several image sections (HE_APP, HP_APP); choose one with --section"},
+		{"two sections, one chosen", []string{"-c", "two.json", "--section", "HP_APP"}, 0, "Image created successfully"},
+		{"unknown section", []string{"-c", "two.json", "--section", "M55_HP"}, 1, "no section 'M55_HP' (image sections: HE_APP, HP_APP)"},
+		{"no section", []string{"-c", "none.json"}, 1, "could not find application binary field"},
+	}
+	for _, tt := range tests {
+		work := t.TempDir()
+		out, code := runAlif(t, project, append([]string{"image", "blinky.bin", "--working-dir", work}, tt.args...)...)
+		if code != tt.code || !strings.Contains(out, tt.want) {
+			t.Errorf("%s: exit code %d, want %d with %q; output:\n%s", tt.name, code, tt.code, tt.want, out)
+		}
+		_, err := os.Stat(filepath.Join(work, "alif-img.bin"))
+		if (err == nil) != (tt.code == 0) {
+			t.Errorf("%s: image written: %v", tt.name, err == nil)
+		}
+	}
+}
+
+func TestImageReadOnlyInput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	home, tools, input := t.TempDir(), t.TempDir(), t.TempDir()
+	writeFiles(t, map[string]string{
+		filepath.Join(home, ".alif", "config.yaml"):    "alif_tools_path: " + tools + "\n",
+		filepath.Join(tools, "utils", "global-cfg.db"): `{"DEVICE": {"Part#": "E7 (AE722F80F55D5LS) - 5.5 MRAM / 13.5 SRAM", "Revision": "B2"}}`,
+		filepath.Join(tools, "app-gen-toc"): `#!/bin/sh
+mkdir -p build
+printf 'TOC' > build/AppTocPackage.bin
+printf '0x80000000  0x00000010  alif-img.bin\n' > build/app-package-map.txt
+`,
+		filepath.Join(input, "blinky.bin"): "0123456789abcdef",
+		filepath.Join(input, "he.json"):    `{"USER_APP": {"binary": "alif-img.bin", "mramAddress": "0x80000000", "cpu_id": "M55_HE"}}`,
+		filepath.Join(input, "none.json"):  `{"DEVICE": {"disable_wdt": true}}`,
+	})
+	t.Setenv("HOME", home)
+	if err := os.Chmod(input, 0555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(input, 0755) })
+	before, _ := os.ReadDir(input)
+
+	tests := []struct {
+		name    string
+		args    []string
+		code    int
+		work    bool // pass --working-dir
+		kept    bool // the image (or, on failure, the staging) is left in the working directory
+		listing string
+	}{
+		{"temporary working directory", []string{"-c", "he.json"}, 0, false, true, "AppTocPackage.bin|alif-img.bin|app-package-map.txt"},
+		{"working directory", []string{"-c", "he.json"}, 0, true, true, "AppTocPackage.bin|alif-img.bin|app-package-map.txt"},
+		{"failure removes the temporary directory", []string{"-c", "none.json"}, 1, false, false, ""},
+		{"failure with --keep", []string{"-c", "none.json", "--keep"}, 1, false, true, ""},
+	}
+	for _, tt := range tests {
+		tmp := t.TempDir()
+		t.Setenv("TMPDIR", tmp)
+		args := append([]string{"image", "blinky.bin"}, tt.args...)
+		work := filepath.Join(t.TempDir(), "work")
+		if tt.work {
+			args = append(args, "--working-dir", work)
+		}
+		out, code := runAlif(t, input, args...)
+		if code != tt.code {
+			t.Errorf("%s: exit code %d, want %d; output:\n%s", tt.name, code, tt.code, out)
+		}
+		if after, _ := os.ReadDir(input); len(after) != len(before) {
+			t.Errorf("%s: the input folder gained files: %v", tt.name, after)
+		}
+		if !tt.work {
+			dirs, _ := filepath.Glob(filepath.Join(tmp, "alif-work-*"))
+			if (len(dirs) == 1) != tt.kept {
+				t.Errorf("%s: temporary working directories %q, want kept %v", tt.name, dirs, tt.kept)
+			}
+			if len(dirs) != 1 {
+				continue
+			}
+			work = dirs[0]
+		}
+		if tt.code == 0 && !strings.Contains(out, work) {
+			t.Errorf("%s: output does not name %s:\n%s", tt.name, work, out)
+		}
+		var names []string
+		entries, _ := os.ReadDir(work)
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		if tt.code == 0 && strings.Join(names, "|") != tt.listing {
+			t.Errorf("%s: working directory holds %q, want %s", tt.name, names, tt.listing)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"alif-cli/internal/config"
+	"alif-cli/internal/flasher"
+	"alif-cli/internal/monitor"
+	"alif-cli/internal/netserial"
+	"alif-cli/internal/portlock"
+	"alif-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+	"go.bug.st/serial"
+)
+
+var monitorPort string
+var monitorSerial string
+var monitorNoProbe bool
+var monitorNoStablePath bool
+var monitorBaud int
+var monitorSend []string
+var monitorExpect []string
+var monitorTimeout time.Duration
+var monitorDelay time.Duration
+var monitorScript string
+
+var monitorCmd = &cobra.Command{
+	Use:   "monitor",
+	Short: "Open the board's console UART",
+	Long: `Streams the console UART to the terminal until interrupted.
+
+With --send / --expect (repeatable, paired in order) or --script, runs a
+scripted interaction instead: each command is sent and the matching pattern
+(a regular expression) must appear in the output within the step timeout.
+The command exits 0 only if every expectation matched in order; otherwise the
+transcript is printed.
+
+Script format (YAML):
+  delay: 200ms
+  steps:
+    - send: "version"
+      expect: "v[0-9]+"
+      timeout: 2s`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runMonitor()
+	},
+}
+
+func init() {
+	monitorCmd.Flags().StringVar(&monitorPort, "port", "", "Serial port to open, or tcp://host:port / rfc2217://host:port (overrides ALIF_PORT and default_port)")
+	monitorCmd.Flags().StringVar(&monitorSerial, "serial", "", "Select the board by USB serial number (exact or prefix match)")
+	monitorCmd.Flags().BoolVar(&monitorNoStablePath, "no-stable-path", false, "Use kernel port names instead of /dev/serial/by-id links (Linux)")
+	monitorCmd.Flags().BoolVar(&monitorNoProbe, "no-probe", false, "Do not open-test serial ports while choosing one")
+	monitorCmd.Flags().IntVarP(&monitorBaud, "baud", "b", 115200, "Baud rate")
+	monitorCmd.Flags().StringArrayVar(&monitorSend, "send", nil, "Command to send (repeatable)")
+	monitorCmd.Flags().StringArrayVar(&monitorExpect, "expect", nil, "Regular expression to wait for (repeatable)")
+	monitorCmd.Flags().DurationVar(&monitorTimeout, "timeout", monitor.DefaultStepTimeout, "Timeout for each --expect")
+	monitorCmd.Flags().DurationVar(&monitorDelay, "delay", 0, "Delay between sent commands")
+	monitorCmd.Flags().StringVar(&monitorScript, "script", "", "YAML file describing a send/expect sequence")
+	rootCmd.AddCommand(monitorCmd)
+}
+
+func runMonitor() {
+	var script *monitor.Script
+	if monitorScript != "" {
+		if len(monitorSend) > 0 || len(monitorExpect) > 0 {
+			ui.Error("--script cannot be combined with --send / --expect")
+			os.Exit(1)
+		}
+		s, err := monitor.LoadScript(monitorScript)
+		if err != nil {
+			ui.Error(fmt.Sprintf("%v", err))
+			os.Exit(1)
+		}
+		script = s
+	} else if len(monitorSend) > 0 || len(monitorExpect) > 0 {
+		script = &monitor.Script{
+			Delay: monitorDelay,
+			Steps: monitor.PairSteps(monitorSend, monitorExpect, monitorTimeout),
+		}
+	}
+
+	// The configuration is optional here; it only supplies default_port.
+	cfg, err := config.LoadConfig()
+	if err != nil && !errors.Is(err, config.ErrNotFound) {
+		reportConfigError(err)
+		os.Exit(1)
+	}
+	f := flasher.New(cfg)
+	f.Port = monitorPort
+	f.Serial = monitorSerial
+	f.NoProbe = monitorNoProbe
+	f.NoStablePath = monitorNoStablePath
+
+	ui.Header("Serial Monitor")
+	port, err := f.SelectPort()
+	if err != nil {
+		ui.Error(fmt.Sprintf("Error identifying port: %v", err))
+		os.Exit(exitCode(err))
+	}
+	ui.Item("Baud", fmt.Sprintf("%d", monitorBaud))
+
+	conn, err := netserial.Open(port, &serial.Mode{BaudRate: monitorBaud})
+	if err != nil {
+		ui.Error(fmt.Sprintf("Failed to open %s: %v", port, err))
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	if script == nil {
+		streamMonitor(port, conn)
+		return
+	}
+
+	ui.Header("Scripted Interaction")
+	ui.Item("Steps", fmt.Sprintf("%d", len(script.Steps)))
+
+	var transcript bytes.Buffer
+	if err := monitor.Run(conn, script, &transcript); err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		fmt.Println("\n" + transcript.String())
+		os.Exit(1)
+	}
+	ui.Success("All expectations matched.")
+}
+
+// reattachTimeout bounds how long the monitor waits for the port to come
+// back after a flash (the board may re-enumerate when it resets).
+const reattachTimeout = 10 * time.Second
+
+// streamMonitor copies the UART to stdout until interrupted. The port is
+// registered so `alif flash` can ask for it: the monitor then closes the
+// port, waits for the flash to finish and re-opens it.
+func streamMonitor(port string, conn serial.Port) {
+	var session *portlock.Session
+	if dir, err := portlock.Dir(); err == nil {
+		if session, err = portlock.Register(dir, port, "alif monitor"); err != nil {
+			ui.Warn(fmt.Sprintf("Cannot register %s for flash handoff: %v", port, err))
+		}
+	}
+
+	ui.Info("Press Ctrl-C to exit.")
+	fmt.Println()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		if session != nil {
+			session.Close()
+		}
+		fmt.Println()
+		os.Exit(0)
+	}()
+
+	// A short read timeout lets the loop notice release requests.
+	conn.SetReadTimeout(portlock.PollInterval)
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if n > 0 {
+			os.Stdout.Write(buf[:n])
+		}
+		if err != nil {
+			if session != nil {
+				session.Close()
+			}
+			fmt.Println()
+			ui.Error(fmt.Sprintf("Read from %s failed: %v", port, err))
+			os.Exit(1)
+		}
+		if session == nil || !session.ReleaseRequested() {
+			continue
+		}
+
+		conn.Close()
+		if err := session.Released(); err != nil {
+			ui.Warn(fmt.Sprintf("Failed to acknowledge release: %v", err))
+		}
+		fmt.Println()
+		ui.Info(fmt.Sprintf("Released %s for flashing; waiting to re-attach...", port))
+		session.WaitForReturn()
+
+		conn, err = reopenPort(port)
+		if err != nil {
+			session.Close()
+			ui.Error(fmt.Sprintf("Failed to re-open %s: %v", port, err))
+			os.Exit(1)
+		}
+		conn.SetReadTimeout(portlock.PollInterval)
+		ui.Info(fmt.Sprintf("Re-attached to %s", port))
+		fmt.Println()
+	}
+}
+
+// reopenPort retries opening port until it succeeds or reattachTimeout
+// passes.
+func reopenPort(port string) (serial.Port, error) {
+	deadline := time.Now().Add(reattachTimeout)
+	for {
+		conn, err := netserial.Open(port, &serial.Mode{BaudRate: monitorBaud})
+		if err == nil || time.Now().After(deadline) {
+			return conn, err
+		}
+		time.Sleep(portlock.PollInterval)
+	}
+}
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"alif-cli/internal/builder"
+	"alif-cli/internal/config"
+	"alif-cli/internal/project"
+	"alif-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var packsForSolution bool
+var packsAgreeLicense bool
+
+var packsCmd = &cobra.Command{
+	Use:   "packs",
+	Short: "List, install and update CMSIS packs with cpackget",
+	Long: `Manages the CMSIS packs in cmsis_pack_root with cpackget from the CMSIS
+Toolbox, so the packs a build needs can be installed ahead of the first
+'alif build' instead of being downloaded by cbuild --packs.`,
+}
+
+var packsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the installed packs",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runPacksList()
+	},
+}
+
+var packsInstallCmd = &cobra.Command{
+	Use:   "install [<vendor::pack[@version]>...]",
+	Short: "Install packs, or the packs the solution is missing",
+	Long: `Installs the given packs (e.g. ARM::CMSIS@6.1.0). With --for-solution the
+packs the .csolution.yml in the current folder requires are read with
+'cbuild list packs --missing', and only those not yet installed are added,
+so CI can fill the pack cache before building.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runPacksInstall(args)
+	},
+}
+
+var packsUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update the pack index and the installed packs",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runPacksUpdate()
+	},
+}
+
+func init() {
+	packsInstallCmd.Flags().BoolVar(&packsForSolution, "for-solution", false, "Install the packs the solution in the current folder is missing")
+	packsInstallCmd.Flags().BoolVar(&packsAgreeLicense, "agree-license", false, "Accept embedded pack licenses without asking (passes --agree-embedded-license)")
+	packsCmd.AddCommand(packsListCmd, packsInstallCmd, packsUpdateCmd)
+	rootCmd.AddCommand(packsCmd)
+}
+
+// packsBuilder loads the configuration the pack commands need and reports
+// where the packs live.
+func packsBuilder() *builder.Builder {
+	cfg := requireConfig(config.KeyCmsisToolbox)
+	b := builder.New(cfg)
+	root := cfg.CmsisPackRoot
+	if root == "" {
+		root = "(cpackget default)"
+	}
+	ui.Item("Pack Root", root)
+	return b
+}
+
+func runPacksList() {
+	ui.Header("CMSIS Packs")
+	b := packsBuilder()
+	packs, err := b.InstalledPacks()
+	if err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+	if len(packs) == 0 {
+		ui.Info("No packs installed.")
+		return
+	}
+	rows := make([][]string, 0, len(packs))
+	for _, p := range packs {
+		rows = append(rows, []string{p.Vendor + "::" + p.Name, p.Version})
+	}
+	ui.Table([]string{"PACK", "VERSION"}, rows)
+	ui.Item("Installed", fmt.Sprintf("%d", len(packs)))
+}
+
+func runPacksInstall(args []string) {
+	if packsForSolution == (len(args) > 0) {
+		ui.Error("Name the packs to install, or pass --for-solution (not both).")
+		os.Exit(1)
+	}
+
+	ui.Header("Install CMSIS Packs")
+	b := packsBuilder()
+
+	var packs []builder.Pack
+	if packsForSolution {
+		solDir, err := project.IsSolutionRoot("")
+		if err != nil {
+			ui.Error(fmt.Sprintf("%v", err))
+			os.Exit(1)
+		}
+		ui.Item("Solution", solDir)
+		s := ui.StartSpinner("Resolving required packs...")
+		packs, err = b.MissingPacks(solDir)
+		if err != nil {
+			s.Fail("Could not resolve the required packs")
+			ui.Error(fmt.Sprintf("%v", err))
+			os.Exit(1)
+		}
+		s.Succeed(fmt.Sprintf("%d missing pack(s)", len(packs)))
+		if len(packs) == 0 {
+			ui.Success("All packs the solution requires are installed.")
+			return
+		}
+	} else {
+		for _, arg := range args {
+			p, err := builder.ParsePack(arg)
+			if err != nil {
+				ui.Error(fmt.Sprintf("%v", err))
+				os.Exit(1)
+			}
+			packs = append(packs, p)
+		}
+	}
+
+	failed, err := b.InstallPacks(packs, packsAgreeLicense)
+	if err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+
+	ui.Header("Summary")
+	ui.Item("Installed", fmt.Sprintf("%d", len(packs)-len(failed)))
+	if len(failed) > 0 {
+		ui.Item("Failed", strings.Join(failed, ", "))
+		ui.Error(fmt.Sprintf("%d of %d pack(s) could not be installed.", len(failed), len(packs)))
+		os.Exit(1)
+	}
+	ui.Success("Packs installed.")
+}
+
+func runPacksUpdate() {
+	ui.Header("Update CMSIS Packs")
+	b := packsBuilder()
+	added, err := b.UpdatePacks()
+	if err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+	if len(added) == 0 {
+		ui.Success("All installed packs are up to date.")
+		return
+	}
+	rows := make([][]string, 0, len(added))
+	for _, p := range added {
+		rows = append(rows, []string{p.Vendor + "::" + p.Name, p.Version})
+	}
+	ui.Table([]string{"PACK", "VERSION"}, rows)
+	ui.Success(fmt.Sprintf("%d pack version(s) installed.", len(added)))
+}
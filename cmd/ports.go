@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"text/tabwriter"
+
+	"alif-cli/internal/config"
+	"alif-cli/internal/flasher"
+	"alif-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var portsJSON bool
+var portsAll bool
+
+var portsCmd = &cobra.Command{
+	Use:   "ports",
+	Short: "List serial ports that look like connected boards",
+	Long: `Lists the serial ports 'alif flash' would offer, using the same detection
+(port_patterns / port_ids in the config). Exits with status 1 when no board
+port is found, so scripts can check for a connected board.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runPorts()
+	},
+}
+
+func init() {
+	portsCmd.Flags().BoolVar(&portsJSON, "json", false, "Print ports as a JSON array")
+	portsCmd.Flags().BoolVar(&portsAll, "all", false, "List every serial port, not only board candidates")
+	rootCmd.AddCommand(portsCmd)
+}
+
+// portEntry is the JSON form of a port.
+type portEntry struct {
+	Name       string `json:"name"`
+	VID        string `json:"vid"`
+	PID        string `json:"pid"`
+	Serial     string `json:"serial"`
+	Label      string `json:"label"`
+	StablePath string `json:"stable_path,omitempty"`
+}
+
+func runPorts() {
+	// The configuration is optional here; it only adds patterns and labels.
+	cfg, err := config.LoadConfig()
+	if err != nil && !errors.Is(err, config.ErrNotFound) {
+		reportConfigError(err)
+		os.Exit(1)
+	}
+	var labels map[string]string
+	if cfg != nil {
+		labels = cfg.PortLabels
+	}
+
+	candidates, all, err := flasher.New(cfg).CandidatePorts()
+	if err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+	ports := candidates
+	if portsAll {
+		ports = all
+	}
+
+	entries := []portEntry{}
+	for _, p := range ports {
+		e := portEntry{Name: p.Name, VID: p.VID, PID: p.PID, Serial: p.SerialNumber, Label: flasher.PortLabel(p, labels)}
+		if runtime.GOOS == "linux" {
+			e.StablePath = flasher.StablePath(p.Name, flasher.SerialByIDDir)
+		}
+		entries = append(entries, e)
+	}
+
+	if portsJSON {
+		data, _ := json.MarshalIndent(entries, "", "  ")
+		fmt.Println(string(data))
+	} else if len(entries) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "  PORT\tVID\tPID\tSERIAL\tLABEL")
+		for _, e := range entries {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", e.Name, e.VID, e.PID, e.Serial, e.Label)
+		}
+		w.Flush()
+	}
+
+	if len(entries) == 0 {
+		if !portsJSON {
+			ui.Error("No board serial ports found.")
+		}
+		os.Exit(1)
+	}
+}
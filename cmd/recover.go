@@ -1,24 +1,42 @@
 package cmd
 
 import (
-	"bufio"
 	"bytes"
+	"encoding/binary"
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"alif-cli/internal/audit"
 	"alif-cli/internal/config"
+	"alif-cli/internal/flasher"
+	"alif-cli/internal/jlink"
+	"alif-cli/internal/project"
+	"alif-cli/internal/targets"
 	"alif-cli/internal/ui"
 
 	"github.com/spf13/cobra"
 )
 
 var recoverDevice string
+var recoverJSON bool
+var recoverProbeSerial string
+var recoverAddresses []string
+var recoverYes bool
+var recoverMethod string
+var recoverPort string
+var recoverSerial string
+var recoverGuide bool
+var recoverDryRun bool
+var recoverNoScan bool
+var recoverMassErase bool
 
 // XML structures for parsing JLinkDevices.xml
 type JLinkDataBase struct {
@@ -39,7 +57,11 @@ var recoverCmd = &cobra.Command{
 	Use:   "recover",
 	Short: "Recover a locked or unresponsive device via J-Link JTAG",
 	Long: `Clears the boot signatures in MRAM using a J-Link debugger. 
-This forces the ROM bootloader back into ISP mode by zeroing out the TOC and Application entry points.`,
+This forces the ROM bootloader back into ISP mode by zeroing out the TOC and Application entry points.
+
+Without a J-Link, --method ISP erases the application area over the SE-UART
+instead, for boards that are stuck booting a bad application but still
+answer in ISP (maintenance) mode.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		runEmergencyRecover()
 	},
@@ -47,28 +69,26 @@ This forces the ROM bootloader back into ISP mode by zeroing out the TOC and App
 
 func init() {
 	recoverCmd.Flags().StringVarP(&recoverDevice, "device", "d", "", "Target J-Link device name (e.g. AE722F80F55D5LS_M55_HE)")
+	recoverCmd.Flags().StringVar(&recoverProbeSerial, "probe-serial", "", "Serial number of the J-Link to use when several are attached (default: jlink_serial from the config)")
+	recoverCmd.Flags().BoolVar(&recoverJSON, "json", false, "Print progress as JSON lines, ending with a result object")
+	recoverCmd.Flags().StringArrayVar(&recoverAddresses, "address", nil, "Zero this MRAM address instead of the guessed ones, as 0x80xxxxxx[:len] (len in bytes, default 64, multiple of 4; repeatable)")
+	recoverCmd.Flags().BoolVarP(&recoverYes, "yes", "y", false, "Do not ask before zeroing the --address regions")
+	recoverCmd.Flags().StringVarP(&recoverMethod, "method", "m", "JTAG", "Recovery method: JTAG (clear the boot entries over J-Link) or ISP (erase the application area over the SE-UART)")
+	recoverCmd.Flags().StringVar(&recoverPort, "port", "", "Serial port to use with --method ISP, or tcp://host:port / rfc2217://host:port")
+	recoverCmd.Flags().StringVar(&recoverSerial, "serial", "", "Select the board by USB serial number with --method ISP (exact or prefix match)")
+	recoverCmd.Flags().BoolVar(&recoverMassErase, "mass-erase", false, "Zero the whole application MRAM of the device (from devicesDB) instead of the boot entries; asks first unless --yes")
+	recoverCmd.Flags().BoolVar(&recoverDryRun, "dry-run", false, "Resolve the device and addresses, print the J-Link command file and JLinkExe arguments, and exit without running J-Link (JTAG only)")
+	recoverCmd.Flags().BoolVar(&recoverNoScan, "no-scan", false, "Do not look for app-package-map.txt files in the solution; guess only from the toolkit's application_package.ds and the common offsets")
+	recoverCmd.Flags().BoolVar(&recoverGuide, "guide", false, "With --method ISP, walk through the RESET and ISP button sequence instead of resetting the board over DTR/RTS")
 	rootCmd.AddCommand(recoverCmd)
 }
 
 func findJLinkDevicesXML(cfg *config.Config) string {
 	cwd, _ := os.Getwd()
 	// 1. Check current and parents for .alif/JLinkDevices.xml or JLinkDevices.xml
-	curr := cwd
-	for {
-		paths := []string{
-			filepath.Join(curr, ".alif", "JLinkDevices.xml"),
-			filepath.Join(curr, "JLinkDevices.xml"),
-		}
-		for _, p := range paths {
-			if _, err := os.Stat(p); err == nil {
-				return p
-			}
-		}
-		parent := filepath.Dir(curr)
-		if parent == curr || parent == "/" {
-			break
-		}
-		curr = parent
+	candidates := []string{filepath.Join(".alif", "JLinkDevices.xml"), "JLinkDevices.xml"}
+	if p := config.FindUpwards(cwd, candidates); p != "" {
+		return p
 	}
 
 	// 2. Check the Toolkit directory (from config)
@@ -91,7 +111,75 @@ func findJLinkDevicesXML(cfg *config.Config) string {
 	return ""
 }
 
-func extractCandidateAddresses(cfg *config.Config) []string {
+// jlinkDevices reads the devices in the JLinkDevices.xml at xmlPath.
+func jlinkDevices(xmlPath string) ([]JLinkChipInfo, error) {
+	data, err := os.ReadFile(xmlPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read device database: %w", err)
+	}
+	var db JLinkDataBase
+	if err := xml.Unmarshal(data, &db); err != nil {
+		return nil, fmt.Errorf("failed to parse device database: %w", err)
+	}
+	var devices []JLinkChipInfo
+	for _, d := range db.Devices {
+		if d.ChipInfo.Name != "" {
+			devices = append(devices, d.ChipInfo)
+		}
+	}
+	return devices, nil
+}
+
+// matchJLinkDevices returns the names of the devices whose name or one of
+// whose aliases contains filter, ignoring case; an empty filter matches
+// all of them. A device named or aliased exactly filter is returned alone.
+func matchJLinkDevices(devices []JLinkChipInfo, filter string) []string {
+	filter = strings.ToLower(strings.TrimSpace(filter))
+	var matches []string
+	for _, d := range devices {
+		names := append([]string{d.Name}, strings.Split(d.Aliases, ";")...)
+		matched := false
+		for _, n := range names {
+			n = strings.ToLower(strings.TrimSpace(n))
+			if n == "" {
+				continue
+			}
+			if n == filter {
+				return []string{d.Name}
+			}
+			matched = matched || strings.Contains(n, filter)
+		}
+		if matched {
+			matches = append(matches, d.Name)
+		}
+	}
+	return matches
+}
+
+// recoverScanDepth is how many folders below the solution root the
+// package map scan looks, deep enough for out/<project>/<target>/<build>.
+const recoverScanDepth = 6
+
+// recoverScanRoot returns the solution root holding the current folder,
+// or "" outside a solution, where no package maps are scanned.
+func recoverScanRoot() string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for dir := cwd; ; dir = filepath.Dir(dir) {
+		if root, err := project.IsSolutionRoot(dir); err == nil {
+			return root
+		}
+		if filepath.Dir(dir) == dir {
+			return ""
+		}
+	}
+}
+
+// extractCandidateAddresses guesses the MRAM addresses holding boot
+// entries and reports how many package maps added to the guesses.
+func extractCandidateAddresses(cfg *config.Config, r ui.Reporter) []string {
 	addrs := map[string]bool{
 		"0x80000000": true, // Base Application MRAM
 		"0x80010000": true, // Secondary application offset
@@ -117,119 +205,410 @@ func extractCandidateAddresses(cfg *config.Config) []string {
 		}
 	}
 
-	// 2. Extract from ALL local app-package-map.txt files found
-	cwd, _ := os.Getwd()
-	filepath.Walk(cwd, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
-		}
-		if info.Name() == "app-package-map.txt" {
+	// 2. Extract from the app-package-map.txt files in the solution. Build
+	// outputs live under out/, so unlike the .cbuild.yml search it is kept.
+	maps := 0
+	root := recoverScanRoot()
+	switch {
+	case recoverNoScan:
+		r.Item("Package maps", "not scanned (--no-scan)")
+	case root == "":
+		r.Item("Package maps", "not scanned (no solution here)")
+	default:
+		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info.IsDir() {
+				if path == root {
+					return nil
+				}
+				name := info.Name()
+				if name == ".git" || name == "packs" || name == "tools" || name == "node_modules" || name == "tmp" {
+					return filepath.SkipDir
+				}
+				if rel, _ := filepath.Rel(root, path); strings.Count(rel, string(filepath.Separator)) >= recoverScanDepth {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.Name() != "app-package-map.txt" {
+				return nil
+			}
+			found := false
 			if content, err := os.ReadFile(path); err == nil {
-				lines := strings.Split(string(content), "\n")
-				for _, l := range lines {
-					if strings.Contains(l, "Address:") {
-						parts := strings.Split(l, ":")
-						if len(parts) > 1 {
-							addr := strings.TrimSpace(parts[1])
-							if strings.HasPrefix(addr, "0x") {
-								fields := strings.Fields(addr)
-								if len(fields) > 0 && strings.HasPrefix(fields[0], "0x") {
-									addrs[fields[0]] = true
-								}
-							}
-						}
+				for _, l := range strings.Split(string(content), "\n") {
+					if !strings.Contains(l, "Address:") {
+						continue
+					}
+					parts := strings.Split(l, ":")
+					if len(parts) < 2 {
+						continue
+					}
+					fields := strings.Fields(strings.TrimSpace(parts[1]))
+					if len(fields) > 0 && strings.HasPrefix(fields[0], "0x") {
+						addrs[fields[0]] = true
+						found = true
 					}
 				}
 			}
-		}
-		return nil
-	})
+			if found {
+				maps++
+			}
+			return nil
+		})
+		r.Item("Package maps", fmt.Sprintf("%d in %s", maps, root))
+	}
 
 	var result []string
 	for a := range addrs {
 		result = append(result, a)
 	}
+	sort.Slice(result, func(i, j int) bool {
+		vi, _ := strconv.ParseUint(strings.TrimPrefix(result[i], "0x"), 16, 64)
+		vj, _ := strconv.ParseUint(strings.TrimPrefix(result[j], "0x"), 16, 64)
+		return vi < vj
+	})
 	return result
 }
 
-func runEmergencyRecover() {
-	cfg, err := config.LoadConfig()
-	if err != nil || cfg.AlifToolsPath == "" {
-		ui.Error("Alif CLI not configured or config missing. Run 'alif setup' first.")
-		os.Exit(1)
-	}
-
-	ui.Header("Hardware Recovery")
-
-	// 0. Resolve Device if not provided
-	if recoverDevice == "" {
-		xmlPath := findJLinkDevicesXML(cfg)
-		if xmlPath == "" {
-			ui.Warn("No JLinkDevices.xml found. Please provide device name with -d flag.")
-			fmt.Print("Enter J-Link Device Name: ")
-			reader := bufio.NewReader(os.Stdin)
-			input, _ := reader.ReadString('\n')
-			recoverDevice = strings.TrimSpace(input)
-			if recoverDevice == "" {
-				ui.Error("Device name is required.")
-				os.Exit(1)
-			}
-		} else {
-			ui.Item("Device DB", filepath.Base(xmlPath))
-			data, err := os.ReadFile(xmlPath)
+// recoverRegionBytes is how much of each candidate address is zeroed.
+const recoverRegionBytes = 64
+
+// recoverTarget is an MRAM region recovery zeroes.
+type recoverTarget struct {
+	addr   uint64
+	length int
+}
+
+// parseRecoverAddress parses an --address value, 0x80xxxxxx[:len]. Both
+// the address and the length must be multiples of 4, as the region is
+// cleared one 32-bit word at a time.
+func parseRecoverAddress(s string) (recoverTarget, error) {
+	addrStr, lenStr, hasLen := strings.Cut(s, ":")
+	addr, err := flasher.ParseAddress(addrStr)
+	if err != nil {
+		return recoverTarget{}, err
+	}
+	if addr%4 != 0 {
+		return recoverTarget{}, fmt.Errorf("address %s is not 4-byte aligned", addrStr)
+	}
+	length := recoverRegionBytes
+	if hasLen {
+		n, err := strconv.ParseUint(strings.TrimSpace(lenStr), 0, 32)
+		if err != nil || n == 0 {
+			return recoverTarget{}, fmt.Errorf("invalid length '%s' in --address %s", lenStr, s)
+		}
+		if n%4 != 0 {
+			return recoverTarget{}, fmt.Errorf("length %d in --address %s is not a multiple of 4", n, s)
+		}
+		length = int(n)
+	}
+	return recoverTarget{addr: addr, length: length}, nil
+}
+
+// recoverTargets returns the regions to zero: the --address list when one
+// was given, otherwise recoverRegionBytes at each guessed address.
+func recoverTargets(cfg *config.Config, r ui.Reporter) ([]recoverTarget, error) {
+	var targets []recoverTarget
+	if len(recoverAddresses) > 0 {
+		for _, a := range recoverAddresses {
+			t, err := parseRecoverAddress(a)
 			if err != nil {
-				ui.Error(fmt.Sprintf("Failed to read device database: %v", err))
-				os.Exit(1)
+				return nil, err
 			}
+			targets = append(targets, t)
+		}
+		return targets, nil
+	}
+	for _, addr := range extractCandidateAddresses(cfg, r) {
+		val, err := strconv.ParseUint(strings.TrimPrefix(addr, "0x"), 16, 64)
+		if err != nil {
+			continue
+		}
+		targets = append(targets, recoverTarget{addr: val, length: recoverRegionBytes})
+	}
+	return targets, nil
+}
 
-			var db JLinkDataBase
-			if err := xml.Unmarshal(data, &db); err != nil {
-				ui.Error(fmt.Sprintf("Failed to parse device database: %v", err))
-				os.Exit(1)
-			}
+// recoverEvent is one line of `alif recover --json` and `alif erase --json`
+// output. Event is "device", "region" or "write"; the run ends with a
+// recoverResult.
+type recoverEvent struct {
+	Event   string `json:"event"`
+	Device  string `json:"device,omitempty"`
+	Source  string `json:"source,omitempty"`
+	Address string `json:"address,omitempty"`
+	Length  int    `json:"length,omitempty"`
+	Status  string `json:"status,omitempty"`
+}
 
-			var options []string
-			for _, d := range db.Devices {
-				if d.ChipInfo.Name != "" {
-					options = append(options, d.ChipInfo.Name)
-				}
-				if d.ChipInfo.Aliases != "" {
-					aliases := strings.Split(d.ChipInfo.Aliases, ";")
-					for _, a := range aliases {
-						trimmed := strings.TrimSpace(a)
-						if trimmed != "" {
-							options = append(options, trimmed)
-						}
-					}
-				}
+// recoverRegion is a cleared region in the final result. Status is
+// "verified" when every word read back as zero afterwards, "written" when
+// the erase succeeded without a read-back, and "failed" when a word did not
+// clear or the run failed.
+type recoverRegion struct {
+	Address string `json:"address"`
+	Length  int    `json:"length"`
+	Status  string `json:"status"`
+}
+
+// recoverResult is the last line of `alif recover --json` and
+// `alif erase --json` output.
+type recoverResult struct {
+	Event      string          `json:"event"`
+	Device     string          `json:"device,omitempty"`
+	Interface  string          `json:"interface"`
+	Regions    []recoverRegion `json:"regions"`
+	DurationMS int64           `json:"duration_ms"`
+	Status     string          `json:"status"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// recoverRun carries the output mode of one recovery or erase.
+type recoverRun struct {
+	report  ui.Reporter
+	enc     *json.Encoder
+	started time.Time
+	result  recoverResult
+}
+
+// newRecoverRun starts a run that prints JSON lines when asJSON is set.
+func newRecoverRun(asJSON bool) *recoverRun {
+	r := &recoverRun{
+		report:  ui.Console,
+		started: time.Now(),
+		result:  recoverResult{Event: "result", Interface: "JTAG", Regions: []recoverRegion{}},
+	}
+	if asJSON {
+		r.report = ui.Silent
+		r.enc = json.NewEncoder(os.Stdout)
+	}
+	return r
+}
+
+// emit prints an event line in JSON mode.
+func (r *recoverRun) emit(v interface{}) {
+	if r.enc != nil {
+		r.enc.Encode(v)
+	}
+}
+
+// fail reports msg and exits with status 1; in JSON mode the result object
+// carries the error instead.
+func (r *recoverRun) fail(msg string) {
+	r.exit(msg, 1)
+}
+
+// failErr is fail for err followed by hint, exiting with exitCode(err) so
+// an unanswered prompt keeps its own status.
+func (r *recoverRun) failErr(err error, hint string) {
+	r.exit(err.Error()+hint, exitCode(err))
+}
+
+func (r *recoverRun) exit(msg string, code int) {
+	if r.enc == nil {
+		ui.Error(msg)
+		os.Exit(code)
+	}
+	for i := range r.result.Regions {
+		if r.result.Regions[i].Status == "pending" {
+			r.result.Regions[i].Status = "failed"
+		}
+	}
+	r.finish("failed", msg)
+	os.Exit(code)
+}
+
+func (r *recoverRun) finish(status, errMsg string) {
+	r.result.Status = status
+	r.result.Error = errMsg
+	r.result.DurationMS = time.Since(r.started).Milliseconds()
+	r.emit(r.result)
+}
+
+// parseMem32 collects the words J-Link Commander printed for mem32
+// commands ("80000000 = 00000000 00000000 ..."), keyed by address.
+func parseMem32(output string) map[uint64]uint32 {
+	words := map[uint64]uint32{}
+	for _, line := range strings.Split(output, "\n") {
+		addrStr, values, ok := strings.Cut(line, " = ")
+		if !ok {
+			continue
+		}
+		addr, err := strconv.ParseUint(strings.TrimSpace(addrStr), 16, 64)
+		if err != nil {
+			continue
+		}
+		for _, v := range strings.Fields(values) {
+			word, err := strconv.ParseUint(v, 16, 32)
+			if err != nil {
+				break
 			}
+			words[addr] = uint32(word)
+			addr += 4
+		}
+	}
+	return words
+}
+
+// nonZeroWords returns the addresses of the words in the size bytes read
+// back from start into path that are not zero. A missing or short read
+// counts every word it lacks.
+func nonZeroWords(path string, start, size uint64) []uint64 {
+	content, _ := os.ReadFile(path)
+	var bad []uint64
+	for off := uint64(0); off < size; off += 4 {
+		if off+4 > uint64(len(content)) || binary.LittleEndian.Uint32(content[off:]) != 0 {
+			bad = append(bad, start+off)
+		}
+	}
+	return bad
+}
+
+// recordRecoverCheck sets the status of region i from the words in it that
+// did not clear.
+func recordRecoverCheck(run *recoverRun, i int, bad []uint64) {
+	status := "verified"
+	if len(bad) > 0 {
+		status = "failed"
+	}
+	run.result.Regions[i].Status = status
+	run.emit(recoverEvent{Event: "write", Address: run.result.Regions[i].Address, Status: status})
+}
+
+// maxListedAddresses caps how many addresses formatAddresses lists.
+const maxListedAddresses = 16
+
+// formatAddresses lists addrs in hex, eliding the rest of a long list.
+func formatAddresses(addrs []uint64) string {
+	var parts []string
+	for i, a := range addrs {
+		if i == maxListedAddresses {
+			parts = append(parts, fmt.Sprintf("and %d more", len(addrs)-i))
+			break
+		}
+		parts = append(parts, fmt.Sprintf("0x%08x", a))
+	}
+	return strings.Join(parts, ", ")
+}
 
-			if len(options) == 0 {
-				ui.Error("No devices found in database.")
-				os.Exit(1)
+func runEmergencyRecover() {
+	cfg := requireConfig()
+	run := newRecoverRun(recoverJSON)
+	r := run.report
+
+	r.Header("Hardware Recovery")
+	switch strings.ToUpper(recoverMethod) {
+	case "JTAG":
+	case "ISP":
+		if len(recoverAddresses) > 0 || recoverDryRun || recoverMassErase {
+			run.fail("--address, --mass-erase and --dry-run need the JTAG method")
+		}
+		runISPRecover(cfg, run)
+		return
+	default:
+		run.fail(fmt.Sprintf("Unsupported method '%s' (use JTAG or ISP)", recoverMethod))
+	}
+	if recoverMassErase && len(recoverAddresses) > 0 {
+		run.fail("--mass-erase and --address cannot be combined")
+	}
+	var zeroed []recoverTarget
+	var err error
+	if !recoverMassErase {
+		if zeroed, err = recoverTargets(cfg, r); err != nil {
+			run.fail(fmt.Sprintf("%v", err))
+		}
+	}
+
+	// 0. Resolve the device: -d filters the names and aliases in
+	// JLinkDevices.xml, and the list is only offered when nothing narrows
+	// it to one entry.
+	source := "flag"
+	xmlPath := findJLinkDevicesXML(cfg)
+	if xmlPath == "" && recoverDevice == "" {
+		source = "input"
+		r.Warn("No JLinkDevices.xml found. Please provide device name with -d flag.")
+		input, err := ui.Input("Enter J-Link Device Name: ")
+		if err != nil {
+			run.failErr(err, "")
+		}
+		recoverDevice = input
+		if recoverDevice == "" {
+			run.fail("Device name is required.")
+		}
+	} else if xmlPath != "" {
+		r.Item("Device DB", filepath.Base(xmlPath))
+		devices, err := jlinkDevices(xmlPath)
+		if err != nil {
+			run.fail(fmt.Sprintf("%v", err))
+		}
+		if len(devices) == 0 {
+			run.fail("No devices found in database.")
+		}
+
+		candidates := matchJLinkDevices(devices, recoverDevice)
+		hint := "Pass -d <device> to choose one."
+		if recoverDevice != "" {
+			hint = fmt.Sprintf("Pass a longer -d than '%s' to choose one.", recoverDevice)
+		}
+		switch {
+		case len(candidates) == 0:
+			r.Warn(fmt.Sprintf("'%s' matches nothing in %s; passing it to J-Link as is", recoverDevice, filepath.Base(xmlPath)))
+		case len(candidates) == 1 && recoverDevice != "":
+			source = xmlPath
+			recoverDevice = candidates[0]
+		default:
+			source = xmlPath
+			selection, err := ui.Select("Select Target Device:", "Select number: ", candidates, hint)
+			if err != nil {
+				run.failErr(err, "")
 			}
+			recoverDevice = candidates[selection]
+		}
+	}
+
+	r.Item("Selected", recoverDevice)
+	run.result.Device = recoverDevice
+	run.emit(recoverEvent{Event: "device", Device: recoverDevice, Source: source})
 
-			// Filter for Alif-like names to reduce noise if needed, but here we just show all from the Alif-provided XML
-			fmt.Println("Select Target Device:")
-			for i, op := range options {
-				fmt.Printf("[%d] %s\n", i+1, op)
+	// A mass erase covers the device's whole application MRAM, which only
+	// devicesDB knows; without it nothing is erased.
+	var massRegion flasher.Region
+	if recoverMassErase {
+		dev, err := recoverDeviceLayout(cfg, recoverDevice)
+		if err != nil {
+			run.fail(fmt.Sprintf("Cannot mass erase %s: %v", recoverDevice, err))
+		}
+		massRegion = flasher.AppRegion(dev)
+		r.Item("Part", dev.DisplayName())
+		r.Item("Region", massRegion.String())
+		if !recoverYes && !recoverDryRun {
+			ok, err := ui.Confirm(fmt.Sprintf("Zero the whole application MRAM %s (%d bytes) on %s?", massRegion, massRegion.End-massRegion.Start, recoverDevice))
+			if err != nil {
+				run.failErr(err, "; pass --yes to erase without asking")
 			}
-			fmt.Print("Select number: ")
-			reader := bufio.NewReader(os.Stdin)
-			input, _ := reader.ReadString('\n')
-			selection, err := strconv.Atoi(strings.TrimSpace(input))
-			if err != nil || selection < 1 || selection > len(options) {
-				ui.Error("Invalid selection.")
-				os.Exit(1)
+			if !ok {
+				run.fail("Recovery cancelled.")
 			}
-			recoverDevice = options[selection-1]
 		}
 	}
 
-	ui.Item("Selected", recoverDevice)
-
-	// 1. Resolve Candidate Addresses
-	candidateAddrs := extractCandidateAddresses(cfg)
+	// 1. Confirm an explicit address list; the guessed one is the default
+	// recovery and runs without asking.
+	if len(recoverAddresses) > 0 && !recoverYes && !recoverDryRun {
+		r.Info("These MRAM regions will be zeroed:")
+		for _, t := range zeroed {
+			r.Item(fmt.Sprintf("0x%08x", t.addr), fmt.Sprintf("%d bytes", t.length))
+		}
+		ok, err := ui.Confirm(fmt.Sprintf("Zero %d region(s) on %s?", len(zeroed), recoverDevice))
+		if err != nil {
+			run.failErr(err, "; pass --yes to zero them without asking")
+		}
+		if !ok {
+			run.fail("Recovery cancelled.")
+		}
+	}
 
 	// 2. Create J-Link command file on the fly
 	commands := []string{
@@ -239,24 +618,54 @@ func runEmergencyRecover() {
 		"halt",
 	}
 
-	for _, addr := range candidateAddrs {
-		ui.Item("Targeting", addr)
-		// Write 64 bytes of zeros (16 x 32-bit words) to kill multiple possible headers
-		for i := 0; i < 16; i++ {
-			if val, err := strconv.ParseUint(strings.TrimPrefix(addr, "0x"), 16, 64); err == nil {
-				targetAddr := fmt.Sprintf("0x%x", val+uint64(i*4))
-				commands = append(commands, fmt.Sprintf("w4 %s 0x00000000", targetAddr))
-			}
+	for _, t := range zeroed {
+		addr := fmt.Sprintf("0x%x", t.addr)
+		r.Item("Targeting", addr)
+		run.emit(recoverEvent{Event: "region", Address: addr, Length: t.length})
+		run.result.Regions = append(run.result.Regions, recoverRegion{Address: addr, Length: t.length, Status: "pending"})
+		// Write zeros one 32-bit word at a time (64 bytes by default, to kill multiple possible headers)
+		for i := 0; i < t.length/4; i++ {
+			targetAddr := fmt.Sprintf("0x%x", t.addr+uint64(i*4))
+			commands = append(commands, fmt.Sprintf("w4 %s 0x00000000", targetAddr))
+		}
+	}
+	// Read every cleared word back, so a write that did not take is caught.
+	for _, t := range zeroed {
+		commands = append(commands, fmt.Sprintf("mem32 0x%x, %x", t.addr, t.length/4))
+	}
+	// The command file, erase image and readback go in a folder of their
+	// own, so concurrent runs never share them. A dry run keeps the folder,
+	// so the command it prints can be run by hand.
+	workDir, err := os.MkdirTemp("", "alif-recover-*")
+	if err != nil {
+		run.fail(fmt.Sprintf("Failed to create a temporary folder: %v", err))
+	}
+	if !recoverDryRun {
+		defer os.RemoveAll(workDir)
+	}
+	readback := filepath.Join(workDir, "readback.bin")
+	if recoverMassErase {
+		// MRAM has no erase command; a zero-filled image the size of the
+		// region is loaded over it, as alif erase -m JTAG does.
+		zeros := filepath.Join(workDir, "zeros.bin")
+		addr := fmt.Sprintf("0x%x", massRegion.Start)
+		length := int(massRegion.End - massRegion.Start)
+		run.emit(recoverEvent{Event: "region", Address: addr, Length: length})
+		run.result.Regions = append(run.result.Regions, recoverRegion{Address: addr, Length: length, Status: "pending"})
+		commands = append(commands,
+			fmt.Sprintf("loadbin %s 0x%08x", zeros, massRegion.Start),
+			fmt.Sprintf("savebin %s 0x%08x 0x%X", readback, massRegion.Start, length))
+		if err := audit.WriteFile(zeros, make([]byte, length), 0644); err != nil {
+			run.fail(fmt.Sprintf("Failed to create erase image: %v", err))
 		}
 	}
 	commands = append(commands, "reset", "q")
 
-	jlinkFile := filepath.Join(os.TempDir(), "alif_recover.jlink")
-	if err := os.WriteFile(jlinkFile, []byte(strings.Join(commands, "\n")+"\n"), 0644); err != nil {
-		ui.Error(fmt.Sprintf("Failed to create recovery script: %v", err))
-		os.Exit(1)
+	jlinkFile := filepath.Join(workDir, "recover.jlink")
+	script := strings.Join(commands, "\n") + "\n"
+	if err := audit.WriteFile(jlinkFile, []byte(script), 0644); err != nil {
+		run.fail(fmt.Sprintf("Failed to create recovery script: %v", err))
 	}
-	defer os.Remove(jlinkFile)
 
 	// 2. Prepare J-Link command arguments
 	args := []string{
@@ -267,6 +676,29 @@ func runEmergencyRecover() {
 		"-CommandFile", jlinkFile,
 	}
 
+	// Pick the probe up front: with several attached, J-Link Commander would
+	// otherwise open its own selection dialog and hang.
+	// A dry run needs neither J-Link nor a probe; it shows the configured
+	// ones.
+	jlinkExe, err := jlink.Resolve(cfg.JLinkPath)
+	if err != nil && !recoverDryRun {
+		run.fail(fmt.Sprintf("%v", err))
+	} else if err != nil {
+		jlinkExe = jlink.Executable()
+	}
+	f := flasher.New(cfg)
+	f.Report = r
+	if recoverProbeSerial != "" {
+		f.ProbeSerial = recoverProbeSerial
+	}
+	serial := f.ProbeSerial
+	if !recoverDryRun {
+		if serial, err = f.SelectProbe(); err != nil {
+			run.failErr(err, "")
+		}
+	}
+	args = append(jlink.SelectArgs(serial), args...)
+
 	// Try to find a J-Link reset script in the current directory .alif folder
 	cwd, _ := os.Getwd()
 	localScript := filepath.Join(cwd, ".alif", "E7_Series_Reset.jlinkscript")
@@ -274,29 +706,136 @@ func runEmergencyRecover() {
 		args = append([]string{"-JLinkScriptFile", localScript}, args...)
 	}
 
-	// 3. Run JLinkExe
-	jlinkExec := "JLinkExe"
-	if runtime.GOOS == "windows" {
-		jlinkExec = "JLink.exe"
+	if recoverDryRun {
+		r.Item("Command file", jlinkFile)
+		r.Output(script)
+		r.Item("Run", strings.Join(append([]string{jlinkExe}, args...), " "))
+		r.Info(fmt.Sprintf("Dry run: J-Link was not started; its files are kept in %s.", workDir))
+		run.finish("dry-run", "")
+		return
 	}
 
-	cmd := exec.Command(jlinkExec, args...)
+	// 3. Run JLinkExe
+	cmd := exec.Command(jlinkExe, args...)
 	var output bytes.Buffer
 	cmd.Stdout = &output
 	cmd.Stderr = &output
 
-	sp := ui.StartSpinner(fmt.Sprintf("Recovering device %s via J-Link...", recoverDevice))
+	sp := r.StartTask(fmt.Sprintf("Recovering device %s via J-Link...", recoverDevice))
 	err = cmd.Run()
 	outStr := output.String()
 
 	if err != nil || !strings.Contains(outStr, "Connected successfully") {
 		sp.Fail("Recovery failed")
-		fmt.Println("\n" + outStr)
-		ui.Warn("Check J-Link connection, power, and target device name.")
-		ui.Info("Suggestion: Put the board in ISP mode manually (Reset button while holding ISP button) if JTAG fails.")
-		os.Exit(1)
+		r.Output(outStr)
+		r.Warn("Check J-Link connection, power, and target device name.")
+		r.Info("Suggestion: Put the board in ISP mode manually (Reset button while holding ISP button) if JTAG fails.")
+		run.emit(recoverEvent{Event: "write", Status: "failed"})
+		msg := "J-Link did not connect to the target"
+		if err != nil {
+			msg = fmt.Sprintf("J-Link failed: %v", err)
+		}
+		run.fail(msg)
+	}
+
+	// 4. Check that every cleared word reads back as zero
+	var notCleared []uint64
+	if recoverMassErase {
+		notCleared = nonZeroWords(readback, massRegion.Start, massRegion.End-massRegion.Start)
+		recordRecoverCheck(run, 0, notCleared)
+	} else {
+		words := parseMem32(outStr)
+		for i, t := range zeroed {
+			var bad []uint64
+			for a := t.addr; a < t.addr+uint64(t.length); a += 4 {
+				if v, ok := words[a]; !ok || v != 0 {
+					bad = append(bad, a)
+				}
+			}
+			recordRecoverCheck(run, i, bad)
+			notCleared = append(notCleared, bad...)
+		}
+	}
+	if len(notCleared) > 0 {
+		sp.Fail("Recovery incomplete")
+		r.Info("MRAM is written in aligned words; check the addresses, or try --mass-erase or alif recover -m ISP.")
+		run.fail(fmt.Sprintf("%d word(s) did not read back as zero: %s", len(notCleared), formatAddresses(notCleared)))
 	}
 
 	sp.Succeed("Boot signatures cleared successfully.")
-	ui.Info("Please Power Cycle the board to enter ISP mode.")
+	r.Info("Please Power Cycle the board to enter ISP mode.")
+	run.finish("ok", "")
+}
+
+// runISPRecover erases the application area over the SE-UART, so the SE
+// stops booting the application and stays in ISP mode. The board is reset
+// into ISP mode over DTR/RTS first, or by hand with --guide or when that
+// fails. A board that does not answer is pointed to the J-Link recovery.
+func runISPRecover(cfg *config.Config, run *recoverRun) {
+	r := run.report
+	run.result.Interface = "ISP"
+	defer lockToolkit(cfg)()
+
+	f := flasher.New(cfg)
+	f.Report = r
+	f.Port = recoverPort
+	f.Serial = recoverSerial
+	port, err := f.SelectPort()
+	if err != nil {
+		run.fail(fmt.Sprintf("Error identifying port: %v", err))
+	}
+	release, err := f.AcquirePort(port)
+	if err != nil {
+		run.fail(fmt.Sprintf("%v", err))
+	}
+	defer release()
+
+	// Without anyone to press the buttons the erase is tried anyway; the
+	// board may already be in ISP mode.
+	guide := recoverGuide
+	if !guide {
+		if err := f.EnterISPMode(port); err != nil {
+			r.Warn(fmt.Sprintf("Could not reset the board into ISP mode: %v", err))
+			guide = ui.CanPrompt()
+		}
+	}
+	if guide {
+		if err := guideISPMode(r, port); err != nil {
+			run.failErr(err, "")
+		}
+	}
+	if err := f.UpdateISPConfig(port); err != nil {
+		run.fail(fmt.Sprintf("Failed to update ISP config: %v", err))
+	}
+
+	if err := f.EraseAreaViaISP(flasher.EraseApp, false); err != nil {
+		run.emit(recoverEvent{Event: "write", Status: "failed"})
+		r.Info("If the board does not answer over ISP, attach a J-Link and run 'alif recover' (JTAG) to clear the boot entries instead.")
+		run.fail(fmt.Sprintf("ISP erase failed: %v", err))
+	}
+	run.emit(recoverEvent{Event: "write", Status: "written"})
+	r.Info("The application area is empty; the board stays in ISP mode for the next flash.")
+	run.finish("ok", "")
+}
+
+// guideISPMode walks through the button sequence that puts the board into
+// ISP mode and waits until it is done.
+func guideISPMode(r ui.Reporter, port string) error {
+	r.Info("Put the board into ISP mode by hand:")
+	r.Info(fmt.Sprintf("  1. Close any terminal or monitor that has %s open", port))
+	r.Info("  2. Hold the ISP button (if the board has one), press and release RESET, then release ISP")
+	if _, err := ui.Input("Press Enter once the board is in ISP mode: "); err != nil {
+		return fmt.Errorf("%w; put the board into ISP mode and rerun without --guide", err)
+	}
+	return nil
+}
+
+// recoverDeviceLayout looks up the part of a J-Link device name (e.g.
+// AE722F80F55D5LS_M55_HE) in the toolkit's devicesDB.
+func recoverDeviceLayout(cfg *config.Config, device string) (*targets.DeviceInfo, error) {
+	part := strings.FieldsFunc(device, func(r rune) bool { return r == '_' || r == ':' })
+	if len(part) == 0 {
+		return nil, fmt.Errorf("no part number in the device name")
+	}
+	return targets.LookupDevice(cfg.AlifToolsPath, part[0])
 }
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// recoverBench sets up a home with a configured toolkit and a J-Link
+// Commander whose mem32 readback is readback, and a project whose
+// JLinkDevices.xml lists the E7 HE core. It returns the project directory.
+func recoverBench(t *testing.T, readback string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	home := t.TempDir()
+	tools := t.TempDir()
+	jlinkExe := filepath.Join(tools, "JLinkExe")
+	script := `#!/bin/sh
+while [ $# -gt 0 ]; do [ "$1" = -CommandFile ] && file=$2; shift; done
+if grep -q ShowEmuList "$file"; then
+  echo 'J-Link[0]: Connection: USB, Serial number: 600101234, ProductName: J-Link OB-K22-Cortex-M'
+  exit 0
+fi
+echo 'Connected successfully'
+cat <<'EOF'
+` + readback + `EOF
+`
+	if err := os.WriteFile(jlinkExe, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := "alif_tools_path: " + tools + "\njlink_path: " + jlinkExe + "\n"
+	project := t.TempDir()
+	files := map[string]string{
+		filepath.Join(home, ".alif", "config.yaml"): cfg,
+		filepath.Join(project, ".alif", "JLinkDevices.xml"): `<DataBase>
+  <Device>
+    <ChipInfo Vendor="AlifSemi" Name="AE722F80F55D5_M55_HE" Aliases="AE722F80F55D5LS_M55_HE" />
+  </Device>
+</DataBase>
+`,
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("HOME", home)
+	return project
+}
+
+// durationPattern matches the run time, which the golden files hold as 0.
+var durationPattern = regexp.MustCompile(`"duration_ms":\d+`)
+
+func TestRecoverJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		readback string
+		golden   string
+		code     int
+	}{
+		{
+			name:     "cleared",
+			readback: "80000000 = 00000000 00000000 \n8057F000 = 00000000 00000000 \n",
+			golden:   "recover-ok.ndjson",
+		},
+		{
+			name:     "word not cleared",
+			readback: "80000000 = 00000000 00000000 \n8057F000 = 00000000 DEADBEEF \n",
+			golden:   "recover-failed.ndjson",
+			code:     1,
+		},
+	}
+	for _, tt := range tests {
+		project := recoverBench(t, tt.readback)
+		out, code := runAlif(t, project, "recover", "--json", "--yes", "-d", "AE722F80F55D5LS_M55_HE",
+			"--address", "0x80000000:8", "--address", "0x8057F000:8")
+		if code != tt.code {
+			t.Errorf("%s: exit code %d, want %d", tt.name, code, tt.code)
+		}
+		want, err := os.ReadFile(filepath.Join("testdata", tt.golden))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := durationPattern.ReplaceAllString(strings.ReplaceAll(out, project, "PROJECT"), `"duration_ms":0`)
+		if got != string(want) {
+			t.Errorf("%s: output\n%s\nwant (%s)\n%s", tt.name, got, tt.golden, want)
+		}
+	}
+}
+
+func TestRecoverDryRunCommandFile(t *testing.T) {
+	project := recoverBench(t, "")
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	out, code := runAlif(t, project, "recover", "--dry-run", "-d", "AE722F80F55D5LS_M55_HE", "--address", "0x80000000:8")
+	if code != 0 {
+		t.Fatalf("exit code %d, output:\n%s", code, out)
+	}
+	m := regexp.MustCompile(`Command file:\s+(\S+)`).FindStringSubmatch(out)
+	if m == nil {
+		t.Fatalf("no command file in output:\n%s", out)
+	}
+	file := m[1]
+	if !strings.HasPrefix(file, tmp) || strings.Contains(file, "*") {
+		t.Errorf("command file %s is not a new folder in %s", file, tmp)
+	}
+	script, err := os.ReadFile(file)
+	if err != nil || !strings.Contains(string(script), "w4 0x80000000 0x00000000") {
+		t.Errorf("command file %s: %q, %v", file, script, err)
+	}
+	if !strings.Contains(out, "-CommandFile "+file) {
+		t.Errorf("JLinkExe is not run with %s:\n%s", file, out)
+	}
+}
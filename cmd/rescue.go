@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"alif-cli/internal/audit"
+	"alif-cli/internal/flasher"
+	"alif-cli/internal/jlink"
+	"alif-cli/internal/rescue"
+	"alif-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var rescuePort string
+var rescueOut string
+
+var rescueCmd = &cobra.Command{
+	Use:   "rescue",
+	Short: "Walk through getting an unresponsive board back, step by step",
+	Long: `Asks what you can see on the board, runs the matching checks (serial ports,
+ISP bootloader, J-Link probe) and offers the recovery that fits: a reset into
+ISP mode, an erase of the application area or a J-Link recovery. It ends with
+a conclusion and the findings that led there; save them with --out to attach
+to a support request.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runRescue()
+	},
+}
+
+func init() {
+	rescueCmd.Flags().StringVar(&rescuePort, "port", "", "Serial port of the board's SE-UART (default: detected)")
+	rescueCmd.Flags().StringVarP(&rescueOut, "out", "o", "", "Write the conclusion and findings to this file")
+	rootCmd.AddCommand(rescueCmd)
+}
+
+func runRescue() {
+	if !ui.CanPrompt() {
+		ui.Error("alif rescue asks questions about the board and needs an interactive terminal")
+		os.Exit(1)
+	}
+	cfg := requireConfig()
+	f := flasher.New(cfg)
+	// Checks report through the rescue steps instead.
+	f.Report = ui.Silent
+	port := rescuePort
+
+	// findPort sets port from the board candidates, asking when there are
+	// several; --port is taken as it is.
+	findPort := func() (string, error) {
+		if rescuePort != "" {
+			return rescuePort, nil
+		}
+		candidates, all, err := f.CandidatePorts()
+		if err != nil {
+			return "", err
+		}
+		switch len(candidates) {
+		case 0:
+			port = ""
+			return "", fmt.Errorf("none of %d serial port(s) looks like a board", len(all))
+		case 1:
+			port = candidates[0].Name
+		default:
+			options := make([]string, len(candidates))
+			for i, p := range candidates {
+				options[i] = p.Name
+			}
+			idx, err := ui.Select("Several board ports found:", "Select the SE-UART port: ", options, "Pass --port <port> to choose one.")
+			if err != nil {
+				return "", err
+			}
+			port = candidates[idx].Name
+		}
+		return port, nil
+	}
+	needPort := func() error {
+		if port != "" {
+			return nil
+		}
+		_, err := findPort()
+		return err
+	}
+
+	engine := &rescue.Engine{
+		Tree: rescue.DefaultTree,
+		Ask: func(question string) (bool, error) {
+			return ui.Confirm(question)
+		},
+		Checks: map[string]func() (string, error){
+			rescue.CheckPorts: findPort,
+			rescue.CheckISP: func() (string, error) {
+				if err := needPort(); err != nil {
+					return "", err
+				}
+				if err := f.ProbeISP(port); err != nil {
+					return fmt.Sprintf("%s: %v", port, err), err
+				}
+				return port, nil
+			},
+			rescue.CheckJLink: func() (string, error) {
+				exe, err := jlink.Resolve(cfg.JLinkPath)
+				if err != nil {
+					return "", err
+				}
+				emus, err := jlink.Emulators(exe)
+				if err != nil {
+					return "", err
+				}
+				if len(emus) == 0 {
+					return "", fmt.Errorf("no J-Link probe attached")
+				}
+				var names []string
+				for _, e := range emus {
+					names = append(names, fmt.Sprintf("%s %s", e.Product, e.Serial))
+				}
+				return strings.Join(names, ", "), nil
+			},
+		},
+		Actions: map[string]func() error{
+			rescue.ActionAutoISP: func() error {
+				if err := needPort(); err != nil {
+					return err
+				}
+				return f.EnterISPMode(port)
+			},
+			rescue.ActionErase: func() error {
+				if err := needPort(); err != nil {
+					return err
+				}
+				if err := f.UpdateISPConfig(port); err != nil {
+					return err
+				}
+				return f.EraseViaISP(false)
+			},
+			rescue.ActionRecover: func() error {
+				self, err := os.Executable()
+				if err != nil {
+					return err
+				}
+				cmd := exec.Command(self, "recover")
+				cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+				return cmd.Run()
+			},
+		},
+		Report: ui.Console,
+	}
+
+	ui.Header("Board Rescue")
+	ui.Info("Answer each question with y or n; nothing is changed on the board without asking.")
+	outcome, err := engine.Run()
+	if err != nil {
+		ui.Error(fmt.Sprintf("Rescue stopped: %v", err))
+		os.Exit(exitCode(err))
+	}
+
+	ui.Header("Conclusion")
+	ui.Info(outcome.Conclusion.Text)
+	ui.Header("Hardware State")
+	for _, finding := range outcome.Findings {
+		ui.Info(finding.String())
+	}
+	if rescueOut != "" {
+		if err := audit.WriteFile(rescueOut, []byte(outcome.Summary()), 0644); err != nil {
+			ui.Error(fmt.Sprintf("Failed to write %s: %v", rescueOut, err))
+			os.Exit(1)
+		}
+		ui.Item("Saved", rescueOut)
+	} else {
+		ui.Info("Rerun with --out rescue.txt to save this for a support request")
+	}
+}
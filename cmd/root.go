@@ -1,14 +1,25 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"time"
+
+	"alif-cli/internal/audit"
+	"alif-cli/internal/signer"
+	"alif-cli/internal/ui"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var cfgFile string
+var promptTimeout time.Duration
+var nonInteractive bool
+var toolkitWorkDir string
+var logFile string
 
 var rootCmd = &cobra.Command{
 	Use:   "alif",
@@ -17,6 +28,11 @@ var rootCmd = &cobra.Command{
 and other Alif boards with ease. It manages toolchains and signing keys 
 to simplify your workflow.`,
 	Version: "0.2.0",
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if showWrites {
+			printWrites()
+		}
+	},
 }
 
 func Execute() {
@@ -26,8 +42,22 @@ func Execute() {
 	}
 }
 
+// exitCode is the exit status for a command that failed with err:
+// ui.ExitPromptAbandoned when a prompt went unanswered, otherwise 1.
+func exitCode(err error) int {
+	if errors.Is(err, ui.ErrPromptAbandoned) {
+		return ui.ExitPromptAbandoned
+	}
+	return 1
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
+	rootCmd.PersistentFlags().BoolVar(&nonInteractive, "non-interactive", false, "Never prompt; fail with the list of candidates instead")
+	rootCmd.PersistentFlags().StringVar(&toolkitWorkDir, "toolkit-workdir", "", "Run the Security Toolkit from this writable copy (default when the toolkit is read-only: ~/.alif/toolkit-work/<id>)")
+	rootCmd.PersistentFlags().BoolVar(&showWrites, "show-writes", false, "List the files the command wrote, grouped by project, toolkit, home and temp")
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "Also append the output to this file as plain, timestamped lines, including the tool command lines")
+	rootCmd.PersistentFlags().DurationVar(&promptTimeout, "prompt-timeout", 0, "Abandon interactive prompts after this long (e.g. 60s; default: wait forever)")
 }
 
 func initConfig() {
@@ -42,4 +72,34 @@ func initConfig() {
 	viper.SetConfigType("yaml")
 
 	viper.ReadInConfig()
+
+	// The flag wins; otherwise fall back to prompt_timeout in config.yaml.
+	timeout := promptTimeout
+	if !rootCmd.PersistentFlags().Changed("prompt-timeout") {
+		timeout = viper.GetDuration("prompt_timeout")
+	}
+	ui.SetPromptTimeout(timeout)
+	ui.SetNonInteractive(nonInteractive)
+
+	if logFile != "" {
+		// The file is written unbuffered, so nothing is lost on os.Exit.
+		f, err := audit.OpenAppend(logFile)
+		if err != nil {
+			ui.Warn(fmt.Sprintf("Cannot open log file %s: %v", logFile, err))
+		} else {
+			ui.AddSink(ui.NewLogSink(f))
+			ui.Debug("alif " + strings.Join(os.Args[1:], " "))
+		}
+	}
+}
+
+// addCompressFlag registers --compress[=lzf|none] on commands that create images.
+func addCompressFlag(cmd *cobra.Command, target *string) {
+	cmd.Flags().StringVar(target, "compress", "", "Compress the application image in the TOC (lzf or none; the Security Toolkit compresses with LZF, not lzma)")
+	cmd.Flags().Lookup("compress").NoOptDefVal = signer.CompressLZF
+}
+
+// addArtifactsFlag registers --artifacts on commands that create images.
+func addArtifactsFlag(cmd *cobra.Command, target *string) {
+	cmd.Flags().StringVar(target, "artifacts", signer.OutputBuildDir, "Where the generated image goes: build (the build folder, or the working directory for a binary file), in-place (leave it in the toolkit) or a directory")
 }
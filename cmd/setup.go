@@ -1,21 +1,30 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 
 	"alif-cli/internal/color"
 	"alif-cli/internal/config"
+	"alif-cli/internal/jlink"
+	"alif-cli/internal/project"
+	"alif-cli/internal/ui"
 
 	"github.com/spf13/cobra"
 )
 
 var (
-	setupCmsis string
-	setupGcc   string
-	setupCheck bool
+	setupCmsis    string
+	setupGcc      string
+	setupArmclang string
+	setupCheck    bool
+	setupLocal    bool
 )
 
 var setupCmd = &cobra.Command{
@@ -30,24 +39,48 @@ var setupCmd = &cobra.Command{
 func init() {
 	setupCmd.Flags().StringVar(&setupCmsis, "cmsis", "", "Set path to CMSIS Toolbox bin directory")
 	setupCmd.Flags().StringVar(&setupGcc, "gcc", "", "Set path to GCC Toolchain bin directory")
+	setupCmd.Flags().StringVar(&setupArmclang, "armclang", "", "Set path to Arm Compiler for Embedded (AC6) bin directory")
 	setupCmd.Flags().BoolVar(&setupCheck, "check", false, "Verify current configuration")
+	setupCmd.Flags().BoolVar(&setupLocal, "register-local", false, "Write .alif/toolchain.yml for the solution in the current directory from the GCC, CMSIS Toolbox and toolkit found inside it")
 	rootCmd.AddCommand(setupCmd)
 }
 
 func runSetup() {
-	cfg, _ := config.LoadConfig() // Ignore error, start fresh if needed
-	if cfg == nil {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		// Start fresh, but say so when an existing file is being replaced.
+		var parseErr *config.ParseError
+		if errors.As(err, &parseErr) {
+			ui.Warn(fmt.Sprintf("%v", err))
+			ui.Warn("Starting from an empty configuration; saving will overwrite the file.")
+		}
 		cfg = &config.Config{}
 	}
 
 	// Mode 1: Check Configuration
 	if setupCheck {
+		if local, err := config.FindLocal("."); err != nil {
+			color.Error("%v", err)
+			os.Exit(1)
+		} else if local != nil {
+			color.Info("Project toolchain: %s", local.Path)
+			if _, err := local.Apply(cfg); err != nil {
+				color.Error("✖ %v", err)
+				os.Exit(1)
+			}
+		}
 		checkConfiguration(cfg)
 		return
 	}
 
-	// Mode 2: Set Specific Paths (Non-interactive)
-	if setupCmsis != "" || setupGcc != "" {
+	// Mode 2: Register the toolchain vendored in this solution
+	if setupLocal {
+		registerLocalToolchain()
+		return
+	}
+
+	// Mode 3: Set Specific Paths (Non-interactive)
+	if setupCmsis != "" || setupGcc != "" || setupArmclang != "" {
 		if setupCmsis != "" {
 			cfg.CmsisToolbox = setupCmsis
 			color.Success("CMSIS Toolbox path set to: %s", setupCmsis)
@@ -56,6 +89,10 @@ func runSetup() {
 			cfg.GccToolchain = setupGcc
 			color.Success("GCC Toolchain path set to: %s", setupGcc)
 		}
+		if setupArmclang != "" {
+			cfg.ArmclangPath = setupArmclang
+			color.Success("Arm Compiler path set to: %s", setupArmclang)
+		}
 		// Save and exit
 		if err := config.SaveConfig(cfg); err != nil {
 			color.Error("Error saving config: %v", err)
@@ -64,7 +101,7 @@ func runSetup() {
 		return
 	}
 
-	// Mode 3: Interactive Wizard (Default)
+	// Mode 4: Interactive Wizard (Default)
 	fmt.Println("Running Alif CLI Setup...")
 
 	// 1. Detect Alif Security Toolkit
@@ -72,8 +109,7 @@ func runSetup() {
 		cfg.AlifToolsPath = detectAlifTools()
 	}
 	if cfg.AlifToolsPath == "" {
-		fmt.Print("Enter path to Alif Security Toolkit (folder containing app-write-mram): ")
-		fmt.Scanln(&cfg.AlifToolsPath)
+		cfg.AlifToolsPath, _ = ui.Input("Enter path to Alif Security Toolkit (folder containing app-write-mram): ")
 	}
 
 	// 2. Detect CMSIS Toolbox
@@ -81,8 +117,7 @@ func runSetup() {
 		cfg.CmsisToolbox = detectCmsisToolbox()
 	}
 	if cfg.CmsisToolbox == "" {
-		fmt.Print("Enter path to CMSIS Toolbox bin folder (folder containing cbuild): ")
-		fmt.Scanln(&cfg.CmsisToolbox)
+		cfg.CmsisToolbox, _ = ui.Input("Enter path to CMSIS Toolbox bin folder (folder containing cbuild): ")
 	}
 
 	// 3. Detect GCC Toolchain
@@ -90,8 +125,7 @@ func runSetup() {
 		cfg.GccToolchain = detectGccToolchain()
 	}
 	if cfg.GccToolchain == "" {
-		fmt.Print("Enter path to GCC Toolchain bin folder (folder containing arm-none-eabi-gcc): ")
-		fmt.Scanln(&cfg.GccToolchain)
+		cfg.GccToolchain, _ = ui.Input("Enter path to GCC Toolchain bin folder (folder containing arm-none-eabi-gcc): ")
 	}
 
 	// 4. Default CMSIS Pack Root
@@ -99,6 +133,16 @@ func runSetup() {
 		cfg.CmsisPackRoot = detectCmsisPacks()
 	}
 
+	// 5. J-Link (optional; only needed for JTAG flashing and recovery)
+	if cfg.JLinkPath == "" {
+		cfg.JLinkPath = detectJLink()
+	}
+
+	// 6. Arm Compiler (optional; only needed for AC6 projects)
+	if cfg.ArmclangPath == "" {
+		cfg.ArmclangPath = detectArmclang()
+	}
+
 	// Save
 	if err := config.SaveConfig(cfg); err != nil {
 		color.Error("Error saving config: %v", err)
@@ -108,6 +152,84 @@ func runSetup() {
 	}
 }
 
+// registerLocalToolchain writes .alif/toolchain.yml for the solution in the
+// current directory, naming the tools found inside its tree. Tools that are
+// not vendored are left out, so they keep coming from config.yaml.
+func registerLocalToolchain() {
+	root, err := project.IsSolutionRoot("")
+	if err != nil {
+		color.Error("%v; run 'alif setup --register-local' in the solution folder", err)
+		os.Exit(1)
+	}
+	local := &config.Local{
+		Root:         root,
+		Gcc:          findVendoredTool(root, exeName("arm-none-eabi-gcc")),
+		CmsisToolbox: findVendoredTool(root, exeName("cbuild")),
+		Toolkit:      findVendoredTool(root, exeName("app-write-mram")),
+	}
+	if local.Gcc == "" && local.CmsisToolbox == "" && local.Toolkit == "" {
+		color.Error("No GCC, CMSIS Toolbox or Security Toolkit found inside %s", root)
+		os.Exit(1)
+	}
+	// Settings that are not tool paths survive the rewrite.
+	if old, err := config.LoadLocal(config.LocalPath(root), root); err == nil {
+		local.JLinkDevice = old.JLinkDevice
+	}
+	if err := config.SaveLocal(local); err != nil {
+		color.Error("Error saving %s: %v", config.LocalPath(root), err)
+		os.Exit(1)
+	}
+	color.Success("Project toolchain saved to %s", local.Path)
+	for _, t := range []struct{ name, path string }{
+		{"GCC:    ", local.Gcc},
+		{"CMSIS:  ", local.CmsisToolbox},
+		{"Toolkit:", local.Toolkit},
+	} {
+		if t.path == "" {
+			t.path = "not vendored (from config.yaml)"
+		}
+		color.Info("%s %s", t.name, t.path)
+	}
+}
+
+// vendoredSearchDepth bounds how deep below the solution root
+// findVendoredTool looks, e.g. tools/gcc/arm-gnu-toolchain-13.2/bin.
+const vendoredSearchDepth = 5
+
+// findVendoredTool returns the first folder under root holding the file
+// name, skipping hidden folders and build output, or "" when there is none.
+func findVendoredTool(root, name string) string {
+	found := ""
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if path != root {
+			base := d.Name()
+			if strings.HasPrefix(base, ".") || base == "out" || base == "tmp" {
+				return filepath.SkipDir
+			}
+			if rel, _ := filepath.Rel(root, path); strings.Count(rel, string(filepath.Separator)) >= vendoredSearchDepth {
+				return filepath.SkipDir
+			}
+		}
+		if info, err := os.Stat(filepath.Join(path, name)); err == nil && !info.IsDir() {
+			found = path
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found
+}
+
+// exeName adds the Windows executable extension to name.
+func exeName(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
+}
+
 func checkConfiguration(cfg *config.Config) {
 	fmt.Println("Checking Alif CLI Configuration...")
 	fmt.Println("----------------------------------")
@@ -152,6 +274,22 @@ func checkConfiguration(cfg *config.Config) {
 		color.Success("✓ GCC Toolchain: OK")
 	}
 
+	// Check J-Link (optional)
+	if exe, err := jlink.Resolve(cfg.JLinkPath); err != nil {
+		color.Warning("! J-Link: %v", err)
+	} else {
+		color.Success("✓ J-Link: %s", exe)
+	}
+
+	// Check Arm Compiler (optional)
+	if cfg.ArmclangPath != "" {
+		if _, err := os.Stat(filepath.Join(cfg.ArmclangPath, exeName("armclang"))); err != nil {
+			color.Warning("! Arm Compiler: armclang not found at %s", cfg.ArmclangPath)
+		} else {
+			color.Success("✓ Arm Compiler: OK")
+		}
+	}
+
 	fmt.Println("----------------------------------")
 	if ok {
 		color.Success("Configuration is valid.")
@@ -166,6 +304,12 @@ func printConfigSummary(cfg *config.Config) {
 	color.Info("CMSIS:   %s", cfg.CmsisToolbox)
 	color.Info("GCC:     %s", cfg.GccToolchain)
 	color.Info("Packs:   %s", cfg.CmsisPackRoot)
+	if cfg.JLinkPath != "" {
+		color.Info("J-Link:  %s", cfg.JLinkPath)
+	}
+	if cfg.ArmclangPath != "" {
+		color.Info("AC6:     %s", cfg.ArmclangPath)
+	}
 }
 
 // getCommonSearchDirs returns platform-appropriate common installation directories
@@ -440,3 +584,43 @@ func detectCmsisPacks() string {
 	}
 	return filepath.Join(home, ".cache", "arm", "packs")
 }
+
+// detectJLink looks for J-Link Commander in SEGGER's default install
+// folders. Nothing is recorded when it is already on PATH.
+func detectJLink() string {
+	name := jlink.Executable()
+	if _, err := exec.LookPath(name); err == nil {
+		return ""
+	}
+	for _, dir := range jlink.InstallDirs() {
+		candidate := filepath.Join(dir, name)
+		if _, err := os.Stat(candidate); err == nil {
+			fmt.Printf("Detected J-Link at: %s\n", candidate)
+			return candidate
+		}
+	}
+	return ""
+}
+
+// detectArmclang looks for the Arm Compiler for Embedded on PATH and in
+// its usual install folders. Unlike J-Link the folder is recorded even
+// when it is on PATH, since the CMSIS Toolbox needs it registered.
+func detectArmclang() string {
+	name := exeName("armclang")
+	if path, err := exec.LookPath(name); err == nil {
+		dir := filepath.Dir(path)
+		fmt.Printf("Detected Arm Compiler at: %s\n", dir)
+		return dir
+	}
+	for _, root := range getCommonSearchDirs() {
+		for _, pattern := range []string{"ArmCompilerforEmbedded*", filepath.Join("Arm", "ArmCompilerforEmbedded*"), filepath.Join("Keil_v5", "ARM", "ARMCLANG")} {
+			matches, _ := filepath.Glob(filepath.Join(root, pattern, "bin", name))
+			if len(matches) > 0 {
+				dir := filepath.Dir(matches[len(matches)-1])
+				fmt.Printf("Detected Arm Compiler at: %s\n", dir)
+				return dir
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"alif-cli/internal/builder"
+	"alif-cli/internal/config"
+	"alif-cli/internal/mapfile"
+	"alif-cli/internal/project"
+	"alif-cli/internal/targets"
+	"alif-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var sizeProject string
+var sizeMap string
+var sizeJSON bool
+
+var sizeCmd = &cobra.Command{
+	Use:   "size",
+	Short: "Analyze memory placement of a built context",
+	Long: `Reads the linker map of a built context and reports where ML data is placed:
+model sections (weights) and arena sections (tensor arena / activation
+buffers), with their sizes and memory regions. Warns when arena sections do
+not fit their SRAM region or the device's SRAM.
+
+Section names are classified with built-in patterns; add your own in
+.alif/ml_sections.json, e.g. {"arena": ["*my_arena*"], "model": ["*weights*"]}.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runSize()
+	},
+}
+
+func init() {
+	sizeCmd.Flags().StringVarP(&sizeProject, "project", "p", "", "Project name or context filter")
+	sizeCmd.Flags().StringVar(&sizeMap, "map", "", "Linker map file to analyze instead of a built context")
+	sizeCmd.Flags().BoolVar(&sizeJSON, "json", false, "Print the analysis as JSON")
+	rootCmd.AddCommand(sizeCmd)
+}
+
+// mlReport is the result of analyzing one map file.
+type mlReport struct {
+	Context  string                  `json:"context,omitempty"`
+	Map      string                  `json:"map"`
+	Device   string                  `json:"device,omitempty"`
+	SRAMSize uint64                  `json:"sram_size,omitempty"`
+	Sections []mapfile.MLSection     `json:"sections"`
+	Usage    []mapfile.MLRegionUsage `json:"usage"`
+	Warnings []string                `json:"warnings"`
+}
+
+func runSize() {
+	report := &mlReport{Map: sizeMap}
+	alifDir := ""
+	partNumber := ""
+
+	if sizeMap == "" {
+		solDir, err := project.IsSolutionRoot("")
+		if err != nil {
+			ui.Error(fmt.Sprintf("%v", err))
+			os.Exit(1)
+		}
+		alifDir = filepath.Join(solDir, ".alif")
+
+		out, err := selectBuiltContext(solDir, sizeProject)
+		if err != nil {
+			ui.Error(fmt.Sprintf("%v", err))
+			os.Exit(exitCode(err))
+		}
+		report.Context = out.Context
+		report.Map = out.MapPath()
+		partNumber = out.PartNumber()
+		if report.Map == "" {
+			ui.Error(fmt.Sprintf("No linker map found for %s in %s. Enable map output in the project, or pass --map.", out.Context, out.OutDir))
+			os.Exit(1)
+		}
+	}
+
+	rules, err := mapfile.LoadMLRules(alifDir)
+	if err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+	m, err := mapfile.ParseFile(report.Map)
+	if err != nil {
+		ui.Error(fmt.Sprintf("Failed to read map file: %v", err))
+		os.Exit(1)
+	}
+	report.Sections, report.Usage = mapfile.AnalyzeML(m, rules)
+
+	// The device is optional: it only adds the whole-chip SRAM check.
+	if cfg, err := config.LoadConfig(); err == nil && cfg.AlifToolsPath != "" {
+		var dev *targets.DeviceInfo
+		if partNumber != "" {
+			dev, _ = targets.LookupDevice(cfg.AlifToolsPath, partNumber)
+		} else {
+			dev, _ = targets.CurrentDevice(cfg.AlifToolsPath)
+		}
+		if dev != nil {
+			report.Device, report.SRAMSize = dev.PartNumber, dev.SRAMSize
+		}
+	}
+	report.Warnings = mlWarnings(report)
+
+	if sizeJSON {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	printMLReport(report)
+}
+
+// selectBuiltContext picks a context that has been built (has a .cbuild.yml).
+func selectBuiltContext(solDir, filter string) (*project.ContextOutput, error) {
+	files := project.FindCbuildFiles(solDir)
+	var contexts []string
+	for c := range files {
+		contexts = append(contexts, c)
+	}
+	sort.Strings(contexts)
+	contexts = builder.FilterContexts(contexts, filter, "", "")
+	if len(contexts) == 0 {
+		return nil, fmt.Errorf("no built context matches '%s'. Run 'alif build' first", filter)
+	}
+
+	context := contexts[0]
+	if len(contexts) > 1 {
+		idx, err := ui.Select("Multiple built contexts found:", "Select context (enter number): ", contexts, "Pass -p <project> to choose one.")
+		if err != nil {
+			return nil, err
+		}
+		context = contexts[idx]
+	}
+	return project.ReadContextOutput(files[context])
+}
+
+// mlWarnings flags arena usage that cannot fit at runtime.
+func mlWarnings(r *mlReport) []string {
+	var warnings []string
+	var arenaSRAM uint64
+	for _, u := range r.Usage {
+		if u.Kind != mapfile.KindArena {
+			continue
+		}
+		if u.Exceeds() {
+			warnings = append(warnings, fmt.Sprintf("arena sections in %s need %s but the region is %s",
+				u.Region, formatBytes(u.Size), formatBytes(u.Capacity)))
+		}
+		if mapfile.IsSRAMRegion(u.Region) {
+			arenaSRAM += u.Size
+		}
+	}
+	if r.SRAMSize > 0 && arenaSRAM > r.SRAMSize {
+		warnings = append(warnings, fmt.Sprintf("arena sections need %s of SRAM but %s has %s",
+			formatBytes(arenaSRAM), r.Device, formatBytes(r.SRAMSize)))
+	}
+	return warnings
+}
+
+func printMLReport(r *mlReport) {
+	ui.Header("ML Memory Placement")
+	if r.Context != "" {
+		ui.Item("Context", r.Context)
+	}
+	ui.Item("Map", r.Map)
+	if r.Device != "" {
+		ui.Item("Device", fmt.Sprintf("%s (%s SRAM)", r.Device, formatBytes(r.SRAMSize)))
+	}
+
+	if len(r.Sections) == 0 {
+		ui.Info("No model or arena sections found.")
+		return
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "  KIND\tSECTION\tADDRESS\tSIZE\tREGION")
+	for _, s := range r.Sections {
+		region := s.Region
+		if region == "" {
+			region = "-"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t0x%08X\t%s\t%s\n", s.Kind, s.Name, s.Address, formatBytes(s.Size), region)
+	}
+	w.Flush()
+
+	fmt.Println()
+	for _, u := range r.Usage {
+		value := formatBytes(u.Size)
+		if u.Capacity > 0 {
+			value = fmt.Sprintf("%s of %s (%.1f%%)", value, formatBytes(u.Capacity), float64(u.Size)*100/float64(u.Capacity))
+		}
+		region := u.Region
+		if region == "" {
+			region = "unknown region"
+		}
+		ui.Item(fmt.Sprintf("%s in %s", u.Kind, region), value)
+	}
+
+	for _, warning := range r.Warnings {
+		ui.Warn(warning)
+	}
+}
+
+// formatBytes renders a size in B, KB or MB.
+func formatBytes(n uint64) string {
+	switch {
+	case n >= 1024*1024:
+		return fmt.Sprintf("%.2f MB", float64(n)/(1024*1024))
+	case n >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(n)/1024)
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"alif-cli/internal/project"
+	"alif-cli/internal/state"
+	"alif-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the state remembered for the current project",
+	Long:  `Prints what Alif CLI has recorded in the project's .alif folder, such as resolved J-Link devices.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runStatus()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
+
+func runStatus() {
+	solDir, err := project.IsSolutionRoot("")
+	if err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		os.Exit(1)
+	}
+
+	alifDir := filepath.Join(solDir, ".alif")
+	st, err := state.Load(alifDir)
+	if err != nil {
+		ui.Error(fmt.Sprintf("Failed to read %s: %v", state.Path(alifDir), err))
+		os.Exit(1)
+	}
+
+	ui.Header("Project")
+	ui.Item("Solution", solDir)
+	ui.Item("State", state.Path(alifDir))
+
+	showToolchains(st)
+
+	ui.Header("J-Link Devices")
+	if len(st.JLink) == 0 {
+		ui.Info("No J-Link device resolved yet.")
+		return
+	}
+	var targetNames []string
+	for t := range st.JLink {
+		targetNames = append(targetNames, t)
+	}
+	sort.Strings(targetNames)
+	for _, t := range targetNames {
+		res := st.JLink[t]
+		value := res.Device
+		if res.Script != "" {
+			value += " (script: " + filepath.Base(res.Script) + ")"
+		}
+		if res.Fallback {
+			value += " — generic fallback, JTAG may fail"
+		}
+		ui.Item(t, value)
+	}
+}
+
+// showToolchains lists the cbuild and gcc versions of each context's last
+// successful build.
+func showToolchains(st *state.State) {
+	ui.Header("Toolchains")
+	if len(st.Toolchains) == 0 {
+		ui.Info("No build recorded yet.")
+		return
+	}
+	var contexts []string
+	for c := range st.Toolchains {
+		contexts = append(contexts, c)
+	}
+	sort.Strings(contexts)
+	for _, c := range contexts {
+		v := st.Toolchains[c]
+		var tools []string
+		if v.Cbuild != "" {
+			tools = append(tools, "cbuild "+v.Cbuild)
+		}
+		if v.GCC != "" {
+			tools = append(tools, "gcc "+v.GCC)
+		}
+		ui.Item(c, fmt.Sprintf("%s (built %s)", strings.Join(tools, ", "), v.RecordedAt.Local().Format("2006-01-02 15:04")))
+	}
+}
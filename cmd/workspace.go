@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"alif-cli/internal/builder"
+	"alif-cli/internal/config"
+	"alif-cli/internal/project"
+	"alif-cli/internal/signer"
+	"alif-cli/internal/ui"
+)
+
+// Operations that can be run across every context of a solution.
+const (
+	opBuild = "build"
+	opImage = "image"
+)
+
+// workspaceOptions describes a solution-wide run.
+type workspaceOptions struct {
+	Project   string
+	Target    string
+	BuildType string
+	Ops       []string
+	Clean     bool
+	Jobs      int
+	Compress  string
+	// Reports are --report <format>=<file> specs written after building.
+	Reports []string
+}
+
+// opResult is the outcome of one operation on one context.
+type opResult struct {
+	Err      error
+	Duration time.Duration
+	Ran      bool
+	// Diagnostics are the compiler diagnostics of a build.
+	Diagnostics []builder.Diagnostic
+	// Artifact is the binary a successful build produced, relative to the
+	// solution, or "" when it is not where the context says it should be.
+	Artifact string
+}
+
+// runWorkspace runs the requested operations for every matching context and
+// prints a context × operation summary. Returns false if anything failed.
+func runWorkspace(solDir string, cfg *config.Config, opts workspaceOptions) bool {
+	b := builder.New(cfg)
+
+	ui.Header("Resolve Build Contexts")
+	contexts, err := b.ListContexts(solDir)
+	if err != nil {
+		ui.Error(fmt.Sprintf("%v", err))
+		return false
+	}
+	contexts = builder.FilterContexts(contexts, opts.Project, opts.Target, opts.BuildType)
+	if len(contexts) == 0 {
+		ui.Error("No build contexts match the given filters.")
+		return false
+	}
+	for _, c := range contexts {
+		ui.Item("Context", c)
+	}
+
+	results := make(map[string]map[string]*opResult)
+	for _, c := range contexts {
+		results[c] = make(map[string]*opResult)
+	}
+
+	for _, op := range opts.Ops {
+		switch op {
+		case opBuild:
+			buildAllContexts(b, solDir, contexts, results, opts)
+			writeBuildReports(opts.Reports, workspaceReport(contexts, results))
+		case opImage:
+			imageAllContexts(b, cfg, solDir, contexts, results, opts)
+		}
+	}
+
+	return printWorkspaceSummary(contexts, opts.Ops, results)
+}
+
+// buildAllContexts compiles each context with at most opts.Jobs cbuild
+// processes running at once.
+func buildAllContexts(b *builder.Builder, solDir string, contexts []string, results map[string]map[string]*opResult, opts workspaceOptions) {
+	jobs := opts.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	ui.Header("Compile Source Code")
+	ui.Item("Contexts", fmt.Sprintf("%d", len(contexts)))
+	ui.Item("Jobs", fmt.Sprintf("%d", jobs))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, jobs)
+
+	for _, c := range contexts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(context string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			start := time.Now()
+			output, err := b.BuildContext(solDir, context, opts.Clean)
+			res := &opResult{Err: err, Duration: time.Since(start), Ran: true}
+			res.Diagnostics = builder.ParseDiagnostics(output, solDir)
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[context][opBuild] = res
+			if err != nil {
+				ui.Error(fmt.Sprintf("%s: build failed", context))
+				ui.Output(output)
+				return
+			}
+			if bin, _, err := b.ResolveArtifacts(solDir, context); err == nil {
+				if _, err := os.Stat(bin); err == nil {
+					res.Artifact, _ = filepath.Rel(solDir, bin)
+				}
+			}
+			ui.Success(fmt.Sprintf("%s (%s)", context, res.Duration.Round(time.Millisecond)))
+		}(c)
+	}
+	wg.Wait()
+}
+
+// imageAllContexts creates a bootable image per context. This is sequential
+// because every image is staged through the shared toolkit directory.
+// Artifacts are copied back next to each context's own binary, so contexts
+// never overwrite each other's images.
+func imageAllContexts(b *builder.Builder, cfg *config.Config, solDir string, contexts []string, results map[string]map[string]*opResult, opts workspaceOptions) {
+	for _, c := range contexts {
+		if br, ok := results[c][opBuild]; ok && br.Err != nil {
+			continue
+		}
+
+		start := time.Now()
+		res := &opResult{Ran: true}
+		results[c][opImage] = res
+
+		binPath, _, err := b.ResolveArtifacts(solDir, c)
+		if err != nil {
+			res.Err = err
+			ui.Error(fmt.Sprintf("%s: %v", c, res.Err))
+			continue
+		}
+		if _, err := os.Stat(binPath); err != nil {
+			res.Err = fmt.Errorf("binary not found: %s", binPath)
+			ui.Error(fmt.Sprintf("%s: %v", c, res.Err))
+			continue
+		}
+
+		targetCore := ""
+		if parts := strings.Split(c, "+"); len(parts) > 1 {
+			targetCore = parts[1]
+		}
+		projectHint := c
+		if idx := strings.Index(c, "."); idx != -1 {
+			projectHint = c[:idx]
+		}
+
+		s := signer.New(cfg)
+		s.Compression = opts.Compress
+		_, res.Err = s.SignArtifact(solDir, filepath.Dir(binPath), binPath, targetCore, projectHint, "")
+		res.Duration = time.Since(start)
+		if res.Err != nil {
+			ui.Error(fmt.Sprintf("%s: image creation failed: %v", c, res.Err))
+		}
+	}
+}
+
+// printWorkspaceSummary prints the result matrix and reports overall success.
+func printWorkspaceSummary(contexts, ops []string, results map[string]map[string]*opResult) bool {
+	ui.Header("Summary")
+
+	ok := true
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	header := "  CONTEXT\t" + strings.ToUpper(strings.Join(ops, "\t"))
+	for _, op := range ops {
+		if op == opBuild {
+			header += "\tARTIFACT"
+		}
+	}
+	fmt.Fprintln(w, header)
+	for _, c := range contexts {
+		cells := []string{}
+		for _, op := range ops {
+			res, ran := results[c][op]
+			switch {
+			case !ran || !res.Ran:
+				cells = append(cells, "skipped")
+				ok = false
+			case res.Err != nil:
+				cells = append(cells, fmt.Sprintf("fail (%s)", res.Duration.Round(time.Millisecond)))
+				ok = false
+			default:
+				cells = append(cells, fmt.Sprintf("ok (%s)", res.Duration.Round(time.Millisecond)))
+			}
+		}
+		if res, ran := results[c][opBuild]; ran {
+			switch {
+			case res.Err != nil:
+				cells = append(cells, "-")
+			case res.Artifact == "":
+				cells = append(cells, "(no .bin found)")
+			default:
+				cells = append(cells, res.Artifact)
+			}
+		}
+		fmt.Fprintf(w, "  %s\t%s\n", c, strings.Join(cells, "\t"))
+	}
+	w.Flush()
+
+	fmt.Println()
+	if ok {
+		ui.Success(fmt.Sprintf("All %d contexts completed successfully.", len(contexts)))
+	} else {
+		ui.Error("One or more contexts failed.")
+	}
+	return ok
+}
+
+// checkSharedOutDir guards against imaging another context's binary when
+// several contexts emit into the same output directory. Returns false when
+// the binary is ambiguous and force is not set.
+func checkSharedOutDir(solDir, context string, force bool) bool {
+	outputs := project.ReadAllContextOutputs(solDir)
+	var selected *project.ContextOutput
+	for _, o := range outputs {
+		if o.Context == context {
+			selected = o
+			break
+		}
+	}
+	if selected == nil {
+		return true
+	}
+
+	shared := project.SharingOutDir(outputs, selected)
+	if len(shared) == 0 {
+		return true
+	}
+	var names []string
+	for _, o := range shared {
+		names = append(names, o.Context)
+	}
+	ui.Warn(fmt.Sprintf("Output directory is shared with: %s", strings.Join(names, ", ")))
+
+	warnings, err := project.CheckSharedOutDir(selected, shared, project.FileModTime)
+	for _, w := range warnings {
+		ui.Warn(w)
+	}
+	if err != nil {
+		if force {
+			ui.Warn(fmt.Sprintf("%v (continuing because of --force)", err))
+			return true
+		}
+		ui.Error(fmt.Sprintf("%v", err))
+		ui.Info("Give each context its own output directory, or pass --force to continue anyway.")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// workspaceContexts are the contexts of the solution workspaceBench sets
+// up; broken fails to compile.
+var workspaceContexts = []string{"blinky.debug+E7-HE", "blinky.debug+E7-HP", "broken.debug+E7-HE"}
+
+// workspaceBench sets up a home with a configured toolkit and a cbuild that
+// lists workspaceContexts, logs each --context it builds to "runs" and
+// writes that context's bin, failing for broken. It returns the toolbox
+// folder and the solution.
+func workspaceBench(t *testing.T) (toolbox, solution string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	home, tools, toolbox, solution := t.TempDir(), t.TempDir(), t.TempDir(), t.TempDir()
+	files := map[string]string{
+		filepath.Join(home, ".alif", "config.yaml"):    "alif_tools_path: " + tools + "\ncmsis_toolbox_path: " + toolbox + "\n",
+		filepath.Join(tools, "utils", "global-cfg.db"): `{"DEVICE": {"Part#": "E7 (AE722F80F55D5LS) - 5.5 MRAM / 13.5 SRAM", "Revision": "B2"}}`,
+		filepath.Join(tools, "app-gen-toc"): `#!/bin/sh
+mkdir -p build
+printf 'TOC' > build/AppTocPackage.bin
+printf '0x80000000  0x00000010  alif-img.bin\n' > build/app-package-map.txt
+`,
+		filepath.Join(toolbox, "cbuild"): `#!/bin/sh
+if [ "$1" = list ]; then
+  printf '` + strings.Join(workspaceContexts, `\n`) + `\n'
+  exit 0
+fi
+while [ $# -gt 0 ]; do [ "$1" = --context ] && context=$2; shift; done
+echo "$context" >> "$(dirname "$0")/runs"
+case "$context" in broken.*) echo "main.c:3:1: error: expected ';'"; exit 1;; esac
+mkdir -p "out/$context"
+printf 'APP' > "out/$context/app.bin"
+`,
+		filepath.Join(solution, "app.csolution.yml"): "solution:\n  compiler: GCC\n",
+		filepath.Join(solution, ".alif", "app.json"): `{"USER_APP": {"binary": "alif-img.bin", "mramAddress": "0x80000000", "cpu_id": "M55_HE"}}`,
+	}
+	for _, c := range workspaceContexts {
+		files[filepath.Join(solution, c+".cbuild.yml")] = "build:\n  output-dirs:\n    outdir: out/" + c + "\n  output:\n    - type: bin\n      file: app.bin\n"
+	}
+	writeFiles(t, files)
+	t.Setenv("HOME", home)
+	t.Setenv("PATH", toolbox+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return toolbox, solution
+}
+
+func TestBuildAll(t *testing.T) {
+	tests := []struct {
+		name   string
+		flags  []string
+		code   int
+		runs   []string
+		images []string
+		want   []string
+	}{
+		{"every context", nil, 1, workspaceContexts, nil, []string{
+			"blinky.debug+E7-HE   ok",
+			"blinky.debug+E7-HP   ok",
+			"broken.debug+E7-HE   fail",
+			"out/blinky.debug+E7-HP/app.bin",
+		}},
+		{"one target", []string{"-t", "E7-HP"}, 0, []string{"blinky.debug+E7-HP"}, nil, []string{
+			"All 1 contexts completed successfully.",
+		}},
+		{"no matching build type", []string{"--type", "release"}, 1, nil, nil, []string{
+			"No build contexts match the given filters.",
+		}},
+		{"with a project", []string{"-p", "blinky"}, 1, nil, nil, []string{
+			"cannot be combined with -p",
+		}},
+		// A broken context does not keep the others from being imaged.
+		{"sign", []string{"--sign"}, 1, workspaceContexts, []string{"blinky.debug+E7-HE", "blinky.debug+E7-HP"}, []string{
+			"broken.debug+E7-HE   fail",
+		}},
+	}
+	for _, tt := range tests {
+		toolbox, solution := workspaceBench(t)
+		out, code := runAlif(t, solution, append([]string{"build", "--all", "-j", "2"}, tt.flags...)...)
+		if code != tt.code {
+			t.Errorf("%s: exit code %d, want %d; output:\n%s", tt.name, code, tt.code, out)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: output lacks %q:\n%s", tt.name, want, out)
+			}
+		}
+		var runs []string
+		if log, err := os.ReadFile(filepath.Join(toolbox, "runs")); err == nil {
+			runs = strings.Fields(string(log))
+		}
+		// Contexts build in parallel, so in any order.
+		slices.Sort(runs)
+		if got := strings.Join(runs, "|"); got != strings.Join(tt.runs, "|") {
+			t.Errorf("%s: cbuild built %q, want %q", tt.name, runs, tt.runs)
+		}
+		for _, c := range workspaceContexts {
+			_, err := os.Stat(filepath.Join(solution, "out", c, "alif-img.bin"))
+			if want := slices.Contains(tt.images, c); (err == nil) != want {
+				t.Errorf("%s: image of %s written: %v, want %v", tt.name, c, err == nil, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"alif-cli/internal/audit"
+	"alif-cli/internal/config"
+	"alif-cli/internal/project"
+	"alif-cli/internal/ui"
+)
+
+var showWrites bool
+
+// toolkitRoots are the toolkit directories in use: the configured
+// installation and, for read-only installs, its work directory.
+var toolkitRoots []string
+
+// printWrites lists the files written during the command, grouped by
+// location.
+func printWrites() {
+	ui.Header("Files Written")
+	entries := audit.Entries()
+	if len(entries) == 0 {
+		ui.Info("No files were written.")
+		return
+	}
+
+	home, _ := os.UserHomeDir()
+	projectDir, err := project.IsSolutionRoot("")
+	if err != nil {
+		projectDir, _ = os.Getwd()
+	}
+	roots := audit.Roots{Project: projectDir, Toolkit: toolkitRoots, Home: home, Temp: os.TempDir()}
+	if len(roots.Toolkit) == 0 {
+		if cfg, err := config.LoadConfig(); err == nil && cfg.AlifToolsPath != "" {
+			roots.Toolkit = []string{cfg.AlifToolsPath}
+		}
+	}
+
+	groups := roots.Group(entries)
+	for _, loc := range []string{audit.LocProject, audit.LocToolkit, audit.LocHome, audit.LocTemp, audit.LocOther} {
+		for _, e := range groups[loc] {
+			ui.Item(loc, fmt.Sprintf("%s (%s)", e.Path, e.Op))
+		}
+	}
+}
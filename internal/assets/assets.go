@@ -1,3 +1,11 @@
 package assets
 
+import _ "embed"
+
 // Logic for embedded assets removed as we moved to project-specific configuration.
+
+// DevicesXML is the built-in device database (parts, families, cores, MRAM
+// layout and revisions) used when the toolkit's own databases are missing.
+//
+//go:embed devices.xml
+var DevicesXML []byte
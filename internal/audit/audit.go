@@ -0,0 +1,230 @@
+// Package audit records the files the CLI writes, so a command can report
+// what it touched in the project, the toolkit and the home directory.
+// Writes go through the helpers here instead of os.WriteFile / os.Create.
+package audit
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Operations recorded for a path.
+const (
+	OpWrite  = "write"
+	OpCopy   = "copy"
+	OpMove   = "move"
+	OpAppend = "append"
+	OpLink   = "link"
+)
+
+// Entry is one recorded write.
+type Entry struct {
+	Path string
+	Op   string
+}
+
+var (
+	mu      sync.Mutex
+	entries []Entry
+)
+
+// Record registers a write performed outside the helpers (e.g. by viper).
+// Repeated writes of the same path and operation are recorded once.
+func Record(path, op string) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, e := range entries {
+		if e.Path == path && e.Op == op {
+			return
+		}
+	}
+	entries = append(entries, Entry{Path: path, Op: op})
+}
+
+// Entries returns the writes recorded so far, in order.
+func Entries() []Entry {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]Entry(nil), entries...)
+}
+
+// WriteFile is os.WriteFile, recorded.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	if err := os.WriteFile(path, data, perm); err != nil {
+		return err
+	}
+	Record(path, OpWrite)
+	return nil
+}
+
+// CreateTemp writes data to a new file in the temporary directory, named
+// from pattern as os.CreateTemp names it, and returns its path. Unlike a
+// fixed name it cannot collide with a concurrent run; the caller removes it.
+func CreateTemp(pattern string, data []byte) (string, error) {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	Record(f.Name(), OpWrite)
+	return f.Name(), nil
+}
+
+// WriteFileAtomic writes data to a temporary file next to path and renames
+// it over path, so a crash leaves either the old or the new content.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	Record(path, OpWrite)
+	return nil
+}
+
+// CopyFile copies src to dst. An existing dst is removed first so a symlink
+// (e.g. into a read-only toolkit) is replaced rather than written through.
+func CopyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	_ = os.Remove(dst)
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	Record(dst, OpCopy)
+	return nil
+}
+
+// Rename moves src to dst, copying when a rename is not possible (e.g.
+// across file systems).
+func Rename(src, dst string) error {
+	_ = os.Remove(dst)
+	if err := os.Rename(src, dst); err != nil {
+		if err := CopyFile(src, dst); err != nil {
+			return err
+		}
+		_ = os.Remove(src)
+		return nil
+	}
+	Record(dst, OpMove)
+	return nil
+}
+
+// Symlink is os.Symlink, recorded.
+func Symlink(target, link string) error {
+	if err := os.Symlink(target, link); err != nil {
+		return err
+	}
+	Record(link, OpLink)
+	return nil
+}
+
+// OpenAppend opens path for appending, creating it if needed.
+func OpenAppend(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	Record(path, OpAppend)
+	return f, nil
+}
+
+// Location names.
+const (
+	LocProject = "project"
+	LocToolkit = "toolkit"
+	LocHome    = "home"
+	LocTemp    = "temp"
+	LocOther   = "other"
+)
+
+// Roots are the directories writes are grouped by. Toolkit paths are
+// checked first since a toolkit work directory lives under the home
+// directory, then the project, home and temp.
+type Roots struct {
+	Project string
+	Toolkit []string
+	Home    string
+	Temp    string
+}
+
+// Locate returns the location name for path.
+func (r Roots) Locate(path string) string {
+	for _, t := range r.Toolkit {
+		if within(path, t) {
+			return LocToolkit
+		}
+	}
+	switch {
+	case within(path, r.Project):
+		return LocProject
+	case within(path, r.Home):
+		return LocHome
+	case within(path, r.Temp):
+		return LocTemp
+	}
+	return LocOther
+}
+
+// Group sorts entries by location, keeping their order within each.
+func (r Roots) Group(list []Entry) map[string][]Entry {
+	groups := make(map[string][]Entry)
+	for _, e := range list {
+		loc := r.Locate(e.Path)
+		groups[loc] = append(groups[loc], e)
+	}
+	return groups
+}
+
+func within(path, root string) bool {
+	if root == "" {
+		return false
+	}
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,111 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// reset forgets the writes recorded by earlier tests.
+func reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	entries = nil
+}
+
+// TestSignFlashEntries records the writes of a sign and an ISP flash with
+// a read-only toolkit, scripted with the helpers the signer and the flasher
+// call, and checks the entries and where they are grouped.
+func TestSignFlashEntries(t *testing.T) {
+	reset()
+	project, toolkit, home, temp := t.TempDir(), t.TempDir(), t.TempDir(), t.TempDir()
+	t.Setenv("TMPDIR", temp)
+	work := filepath.Join(home, ".alif", "toolkit-work")
+	for _, dir := range []string{filepath.Join(project, "out"), filepath.Join(toolkit, "utils"), filepath.Join(work, "build", "images"), filepath.Join(work, "build", "config")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	app := filepath.Join(project, "out", "app.bin")
+	if err := os.WriteFile(app, []byte("APP"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := func(dir string, elem ...string) string { return filepath.Join(append([]string{dir}, elem...)...) }
+
+	// Sign: link the toolkit into its work directory, stage the binary and
+	// the config, and move the package app-gen-toc wrote into the project.
+	if err := Symlink(path(toolkit, "utils"), path(work, "utils")); err != nil {
+		t.Fatal(err)
+	}
+	if err := CopyFile(app, path(work, "build", "images", "alif-img.bin")); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := WriteFile(path(work, "build", "config", "app-cfg.json"), []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(path(work, "build", "AppTocPackage.bin"), []byte("TOC"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Rename(path(work, "build", "AppTocPackage.bin"), path(project, "out", "AppTocPackage.bin")); err != nil {
+		t.Fatal(err)
+	}
+	// Flash: point the ISP config at the port, write a J-Link script and log
+	// the run.
+	if err := WriteFileAtomic(path(work, "isp_config_data.cfg"), []byte("comport /dev/ttyACM0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	script, err := CreateTemp("alif-flash-*.jlink", []byte("exit\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log, err := OpenAppend(path(home, ".alif", "flash.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Close()
+	// A failed write is not recorded.
+	if err := WriteFile(path(home, "missing", "flash.json"), []byte("{}"), 0644); err == nil {
+		t.Fatal("WriteFile wrote into a missing folder")
+	}
+
+	want := []Entry{
+		{path(work, "utils"), OpLink},
+		{path(work, "build", "images", "alif-img.bin"), OpCopy},
+		{path(work, "build", "config", "app-cfg.json"), OpWrite},
+		{path(project, "out", "AppTocPackage.bin"), OpMove},
+		{path(work, "isp_config_data.cfg"), OpWrite},
+		{script, OpWrite},
+		{path(home, ".alif", "flash.log"), OpAppend},
+	}
+	if got := Entries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("entries:\n%v\nwant\n%v", got, want)
+	}
+
+	roots := Roots{Project: project, Toolkit: []string{toolkit, work}, Home: home, Temp: temp}
+	groups := roots.Group(Entries())
+	wantGroups := map[string][]Entry{
+		LocToolkit: {want[0], want[1], want[2], want[4]},
+		LocProject: {want[3]},
+		LocTemp:    {want[5]},
+		LocHome:    {want[6]},
+	}
+	if !reflect.DeepEqual(groups, wantGroups) {
+		t.Errorf("groups:\n%v\nwant\n%v", groups, wantGroups)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	reset()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	Record("app.bin", OpWrite)
+	Record(filepath.Join(dir, "app.bin"), OpWrite)
+	Record("app.bin", OpCopy)
+	want := []Entry{{filepath.Join(dir, "app.bin"), OpWrite}, {filepath.Join(dir, "app.bin"), OpCopy}}
+	if got := Entries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("entries %v, want %v", got, want)
+	}
+}
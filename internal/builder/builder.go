@@ -1,125 +1,196 @@
 package builder
 
 import (
-	"bufio"
 	"bytes"
+	gocontext "context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
-	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"alif-cli/internal/config"
+	"alif-cli/internal/eta"
+	"alif-cli/internal/project"
+	"alif-cli/internal/state"
 	"alif-cli/internal/ui"
 )
 
 type Builder struct {
 	Cfg *config.Config
+	// Report receives progress output; New sets it to ui.Console.
+	Report ui.Reporter
+	// Diagnostics holds the compiler errors and warnings of the last Build,
+	// whether or not it succeeded.
+	Diagnostics []Diagnostic
+	// Output is the combined cbuild output of the last Build.
+	Output string
+
+	// configChecked makes checkToolchainConfig warn once, however many
+	// cbuild runs the Builder starts.
+	configChecked sync.Once
 }
 
 func New(cfg *config.Config) *Builder {
-	return &Builder{Cfg: cfg}
+	return &Builder{Cfg: cfg, Report: ui.Console}
 }
 
 func (b *Builder) setupEnv() []string {
 	env := os.Environ()
 
-	// Build PATH with platform-appropriate separator
-	pathSep := ":"
-	if runtime.GOOS == "windows" {
-		pathSep = ";"
-	}
-
 	// Prepend toolchain paths to PATH
-	pathComponents := []string{
-		b.Cfg.CmsisToolbox,
-		b.Cfg.GccToolchain,
-		os.Getenv("PATH"),
+	env = append(env, "PATH="+b.buildPath())
+	env = append(env, b.gccRegistration())
+	if ac6 := b.ac6Registration(); ac6 != "" {
+		env = append(env, ac6)
 	}
-	newPath := strings.Join(pathComponents, pathSep)
-
-	env = append(env, "PATH="+newPath)
-	env = append(env, "GCC_TOOLCHAIN_13_2_1="+b.Cfg.GccToolchain)
 	env = append(env, "CMSIS_PACK_ROOT="+b.Cfg.CmsisPackRoot)
 
 	return env
 }
 
-// ResolveContext lists available contexts and prompts user to select one if ambiguous.
-func (b *Builder) ResolveContext(solutionPath, targetFilter, projectFilter string) (string, error) {
-	ui.Header("Resolve Build Context")
-	ui.Item("Filter", projectFilter)
+// ResolveContext lists available contexts and prompts user to select one if
+// ambiguous. The project, target and build type filters all narrow the
+// list first, so a unique match is selected without asking.
+func (b *Builder) ResolveContext(solutionPath, targetFilter, projectFilter, buildType string) (string, error) {
+	b.Report.Header("Resolve Build Context")
+	b.Report.Item("Filter", projectFilter)
 	if targetFilter != "" {
-		ui.Item("Target", targetFilter)
+		b.Report.Item("Target", targetFilter)
+	}
+	if buildType != "" {
+		b.Report.Item("Type", buildType)
+	}
+
+	contexts, err := b.ListContexts(solutionPath)
+	if err != nil {
+		return "", err
+	}
+	candidates := FilterContexts(contexts, projectFilter, targetFilter, buildType)
+
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no matching build contexts found for filter='%s' target='%s' type='%s'", projectFilter, targetFilter, buildType)
+	}
+
+	var selectedContext string
+	if len(candidates) == 1 {
+		selectedContext = candidates[0]
+		b.Report.Item("Selected", selectedContext)
+		// b.Report.Success("Context resolved automatically") // Not implemented in UI yet, assume implicit
+	} else {
+		idx, err := ui.Select("Multiple build contexts found:", "Select context (enter number): ", candidates, "Pass -p <project>, -t <target> or --type <build-type> to choose one.")
+		if err != nil {
+			return "", err
+		}
+		selectedContext = candidates[idx]
+		b.Report.Item("Selected", selectedContext)
 	}
 
-	// Find solution file
+	return selectedContext, nil
+}
+
+// ListContexts returns every build context declared by the solution.
+func (b *Builder) ListContexts(solutionPath string) ([]string, error) {
 	solutionFile, _ := filepath.Glob(filepath.Join(solutionPath, "*.csolution.yml"))
 	if len(solutionFile) == 0 {
-		return "", fmt.Errorf("no .csolution.yml file found in %s", solutionPath)
+		return nil, fmt.Errorf("no .csolution.yml file found in %s", solutionPath)
 	}
 	sol := solutionFile[0]
 
-	env := b.setupEnv()
-
-	// List available contexts
 	cmdList := exec.Command("cbuild", "list", "contexts", sol)
-	cmdList.Env = env
+	cmdList.Env = b.setupEnv()
 	out, err := cmdList.Output()
 	if err != nil {
 		if strings.Contains(err.Error(), "executable file not found") {
-			return "", fmt.Errorf("cbuild not found. Ensure CMSIS Toolbox is installed and in PATH. Error: %v", err)
+			return nil, fmt.Errorf("cbuild not found. Ensure CMSIS Toolbox is installed and in PATH. Error: %v", err)
 		}
-		return "", fmt.Errorf("failed to list contexts: %w", err)
+		return nil, fmt.Errorf("failed to list contexts: %w", err)
 	}
 
-	lines := strings.Split(string(out), "\n")
-	var candidates []string
-	for _, line := range lines {
+	var contexts []string
+	for _, line := range strings.Split(string(out), "\n") {
 		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
+		if line != "" {
+			contexts = append(contexts, line)
 		}
-		if targetFilter != "" && !strings.HasSuffix(line, "+"+targetFilter) {
+	}
+	return contexts, nil
+}
+
+// FilterContexts narrows contexts (<project>.<build-type>+<target>) by
+// project prefix, target (with or without the leading +) and build type.
+// Empty filters match everything.
+func FilterContexts(contexts []string, projectFilter, targetFilter, buildType string) []string {
+	var matched []string
+	targetFilter = strings.TrimPrefix(targetFilter, "+")
+	for _, c := range contexts {
+		if targetFilter != "" && !strings.HasSuffix(c, "+"+targetFilter) {
 			continue
 		}
-		if projectFilter != "" && !strings.HasPrefix(line, projectFilter) {
+		if projectFilter != "" && !strings.HasPrefix(c, projectFilter) {
 			continue
 		}
-		candidates = append(candidates, line)
+		if buildType != "" {
+			left := strings.Split(c, "+")[0]
+			if !strings.HasSuffix(left, "."+buildType) {
+				continue
+			}
+		}
+		matched = append(matched, c)
 	}
+	return matched
+}
 
-	if len(candidates) == 0 {
-		return "", fmt.Errorf("no matching build contexts found for filter='%s'", projectFilter)
+// BuildContext compiles a single context without any UI and returns the
+// combined cbuild output. Used when building many contexts at once.
+func (b *Builder) BuildContext(solutionPath, context string, clean bool) (string, error) {
+	return b.RunContext(gocontext.Background(), solutionPath, context, clean)
+}
+
+// cancelGrace is how long cbuild gets to stop after an interrupt before it
+// is killed.
+const cancelGrace = 3 * time.Second
+
+// RunContext is BuildContext that stops cbuild when ctx is done: it is
+// interrupted, so it can stop the compiler it runs, and killed if it is
+// still there after cancelGrace.
+func (b *Builder) RunContext(ctx gocontext.Context, solutionPath, context string, clean bool) (string, error) {
+	solutionFiles, _ := filepath.Glob(filepath.Join(solutionPath, "*.csolution.yml"))
+	if len(solutionFiles) == 0 {
+		return "", fmt.Errorf("no .csolution.yml file found in %s", solutionPath)
 	}
 
-	var selectedContext string
-	if len(candidates) == 1 {
-		selectedContext = candidates[0]
-		ui.Item("Selected", selectedContext)
-		// ui.Success("Context resolved automatically") // Not implemented in UI yet, assume implicit
-	} else {
-		fmt.Println("Multiple build contexts found:")
-		for i, c := range candidates {
-			fmt.Printf("[%d] %s\n", i+1, c)
-		}
-		fmt.Print("Select context (enter number): ")
-		reader := bufio.NewReader(os.Stdin)
-		input, _ := reader.ReadString('\n')
-		selection, err := strconv.Atoi(strings.TrimSpace(input))
-		if err != nil || selection < 1 || selection > len(candidates) {
-			return "", fmt.Errorf("invalid selection")
-		}
-		selectedContext = candidates[selection-1]
-		ui.Item("Selected", selectedContext)
+	if err := b.checkCompiler(solutionPath); err != nil {
+		return "", err
 	}
 
-	return selectedContext, nil
+	args := []string{solutionFiles[0], "--packs", "--context", context}
+	if clean {
+		args = append(args, "--rebuild")
+	}
+
+	cmd := exec.CommandContext(ctx, "cbuild", args...)
+	cmd.Env = b.setupEnv()
+	cmd.Dir = solutionPath
+	if runtime.GOOS != "windows" {
+		cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	}
+	cmd.WaitDelay = cancelGrace
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	return output.String(), err
 }
 
-func (b *Builder) Build(solutionPath, target, projectName string, clean bool) (string, error) {
+// Build resolves the context the filters select and compiles it with
+// cbuild. It returns the context, also when cbuild fails, or "" when the
+// whole solution was rebuilt.
+func (b *Builder) Build(solutionPath, target, projectName, buildType string, clean bool) (string, error) {
 	// Find solution file first/always
 	solutionFiles, _ := filepath.Glob(filepath.Join(solutionPath, "*.csolution.yml"))
 	if len(solutionFiles) == 0 {
@@ -127,34 +198,24 @@ func (b *Builder) Build(solutionPath, target, projectName string, clean bool) (s
 	}
 	sol := solutionFiles[0]
 
-	var selectedContext string
-	var err error
-
-	// If cleaning without specific filters, we Clean/Build ALL (skip selection)
-	buildAll := clean && target == "" && projectName == ""
+	// 1. Resolve Context (Handles its own UI)
+	selectedContext, err := b.ResolveContext(solutionPath, target, projectName, buildType)
+	if err != nil {
+		return "", err
+	}
+	b.Report.Header("Compile Source Code")
+	b.Report.Item("Context", selectedContext)
 
-	if !buildAll {
-		// 1. Resolve Context (Handles its own UI)
-		selectedContext, err = b.ResolveContext(solutionPath, target, projectName)
-		if err != nil {
-			return "", err
-		}
-		ui.Header("Compile Source Code")
-		ui.Item("Context", selectedContext)
-	} else {
-		ui.Header("Clean & Rebuild Solution")
-		ui.Item("Scope", "All Contexts")
+	if err := b.checkCompiler(solutionPath); err != nil {
+		return selectedContext, err
 	}
 
 	env := b.setupEnv()
 
-	args := []string{sol, "--packs"}
-	if selectedContext != "" {
-		args = append(args, "--context", selectedContext)
-	}
+	args := []string{sol, "--packs", "--context", selectedContext}
 	if clean {
 		args = append(args, "--rebuild")
-		ui.Item("Action", "Clean & Build")
+		b.Report.Item("Action", "Clean & Build")
 	}
 
 	cmd := exec.Command("cbuild", args...)
@@ -166,20 +227,36 @@ func (b *Builder) Build(solutionPath, target, projectName string, clean bool) (s
 	cmd.Stdout = &output
 	cmd.Stderr = &output
 
-	msg := "Building..."
-	if selectedContext != "" {
-		msg = fmt.Sprintf("Building %s...", selectedContext)
-	} else if buildAll {
-		msg = "Building all contexts..."
+	msg := fmt.Sprintf("Building %s...", selectedContext)
+
+	// Earlier build times give an estimate; they are kept per context, with
+	// rebuilds tracked separately since they take much longer.
+	alifDir := filepath.Join(solutionPath, ".alif")
+	op := "build"
+	if clean {
+		op = "rebuild"
 	}
+	etaKey := eta.Key(op, selectedContext, 0)
 
-	s := ui.StartSpinner(msg)
-	if err := cmd.Run(); err != nil {
+	s := b.Report.StartTask(msg)
+	if st, err := state.Load(alifDir); err == nil {
+		if est, ok := st.EstimateDuration(etaKey, 0); ok {
+			s.SetEstimate(est)
+		}
+	}
+	start := time.Now()
+	err = cmd.Run()
+	b.Output = output.String()
+	b.Diagnostics = ParseDiagnostics(b.Output, solutionPath)
+	if err != nil {
 		s.Fail("Build failed")
-		fmt.Println("\n" + output.String()) // Print full output on error
-		return "", err
+		b.Report.Output(output.String()) // Print full output on error
+		return selectedContext, err
 	}
 	s.Succeed("Build completed successfully")
+	elapsed := time.Since(start)
+	state.Update(alifDir, func(st *state.State) { st.RecordDuration(etaKey, elapsed, 0) })
+	b.recordToolchain(alifDir, selectedContext)
 
 	// Optional: Print size or artifacts if possible?
 	// But Build returns context, caller prints artifact path.
@@ -187,22 +264,17 @@ func (b *Builder) Build(solutionPath, target, projectName string, clean bool) (s
 	return selectedContext, nil
 }
 
-// GetArtifactPath returns the expected location of the elf/bin after build
-func (b *Builder) GetArtifactPath(projectPath, fullContext string) string {
-	parts := strings.Split(fullContext, "+")
-	if len(parts) != 2 {
-		return ""
+// ResolveArtifacts reads the <context>.cbuild.yml under solDir and returns
+// the bin and elf the build wrote, in the output directory the solution
+// sets. When only an elf is listed, binPath is where it converts to.
+func (b *Builder) ResolveArtifacts(solDir, context string) (binPath, elfPath string, err error) {
+	out, err := project.FindContextOutput(solDir, context)
+	if err != nil {
+		return "", "", err
 	}
-	target := parts[1]
-
-	left := parts[0]
-	lastDot := strings.LastIndex(left, ".")
-	if lastDot == -1 {
-		return ""
+	binPath, elfPath = out.Artifacts()
+	if binPath == "" {
+		return "", "", fmt.Errorf("%s lists neither a bin nor an elf output", filepath.Base(out.File))
 	}
-
-	proj := left[:lastDot]
-	buildType := left[lastDot+1:]
-
-	return filepath.Join(projectPath, "out", proj, target, buildType, proj+".bin")
+	return binPath, elfPath, nil
 }
@@ -0,0 +1,124 @@
+package builder
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic is one compiler error, warning or note from the build output.
+type Diagnostic struct {
+	// File is relative to the solution when it lies inside it, with forward
+	// slashes; otherwise it is the path the compiler printed.
+	File     string
+	Line     int
+	Column   int
+	Severity string // "error", "warning" or "note"
+	Message  string
+	// Rule is the warning option that enabled the diagnostic (e.g.
+	// "-Wunused-variable"), or "compiler-error" / "compiler-warning" /
+	// "compiler-note" when the compiler named none.
+	Rule string
+}
+
+// diagnosticRe matches GCC and Clang diagnostics:
+// "file:line[:col]: (fatal error|error|warning|note): message [-Wflag]".
+var diagnosticRe = regexp.MustCompile(`^(.+?):(\d+):(?:(\d+):)? (fatal error|error|warning|note): (.*?)(?: \[(-W[^\]]+)\])?$`)
+
+// ansiRe matches terminal control sequences compilers add to diagnostics:
+// colors, and the erase-to-end-of-line GCC prints after each of them.
+var ansiRe = regexp.MustCompile(`\x1b\[[0-9;]*[a-zA-Z]`)
+
+// ParseDiagnostics extracts compiler diagnostics from cbuild output.
+// Paths are made relative to solutionDir when they lie inside it, and a
+// diagnostic printed more than once (e.g. from a header included by several
+// files) is kept once.
+func ParseDiagnostics(output, solutionDir string) []Diagnostic {
+	root, _ := filepath.Abs(solutionDir)
+	seen := map[Diagnostic]bool{}
+	var diags []Diagnostic
+	for _, line := range strings.Split(ansiRe.ReplaceAllString(output, ""), "\n") {
+		m := diagnosticRe.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		d := Diagnostic{File: relativePath(m[1], root), Message: m[5]}
+		d.Line, _ = strconv.Atoi(m[2])
+		d.Column, _ = strconv.Atoi(m[3])
+		d.Severity = m[4]
+		if d.Severity == "fatal error" {
+			d.Severity = "error"
+		}
+		d.Rule = ruleID(m[6], d.Severity)
+		if !seen[d] {
+			seen[d] = true
+			diags = append(diags, d)
+		}
+	}
+	return diags
+}
+
+// ruleID turns the option GCC prints after a diagnostic into a rule id;
+// "-Werror=foo" is reported as "-Wfoo".
+func ruleID(flag, severity string) string {
+	flag = strings.TrimSpace(flag)
+	if flag == "" {
+		return "compiler-" + severity
+	}
+	if rule, ok := strings.CutPrefix(flag, "-Werror="); ok {
+		flag = "-W" + rule
+	}
+	// Clang may list several options ("-Wfoo,-Wbar"); options taking a
+	// level are printed with a trailing "=" ("-Wformat=").
+	return strings.TrimSuffix(strings.Split(flag, ",")[0], "=")
+}
+
+// relativePath returns path relative to root with forward slashes when it
+// lies inside root.
+func relativePath(path, root string) string {
+	if root == "" || !filepath.IsAbs(path) {
+		return filepath.ToSlash(path)
+	}
+	rel, err := filepath.Rel(root, filepath.Clean(path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// CountDiagnostics returns the number of errors and warnings in diags.
+func CountDiagnostics(diags []Diagnostic) (errors, warnings int) {
+	for _, d := range diags {
+		switch d.Severity {
+		case "error":
+			errors++
+		case "warning":
+			warnings++
+		}
+	}
+	return errors, warnings
+}
+
+// errorLineRe matches output lines reporting an error: compiler and linker
+// errors ("error:", "undefined reference") and cbuild's own ("error cbuild").
+var errorLineRe = regexp.MustCompile(`(?i)\berror\b|undefined reference`)
+
+// ErrorLines returns up to max distinct lines of output that report an
+// error, in order, without color sequences.
+func ErrorLines(output string, max int) []string {
+	seen := map[string]bool{}
+	var lines []string
+	for _, line := range strings.Split(ansiRe.ReplaceAllString(output, ""), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || seen[line] || !errorLineRe.MatchString(line) {
+			continue
+		}
+		seen[line] = true
+		lines = append(lines, line)
+		if len(lines) == max {
+			break
+		}
+	}
+	return lines
+}
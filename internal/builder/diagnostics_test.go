@@ -0,0 +1,96 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// buildDiagnostics parses the captured output of a failed build of the
+// solution at /work/sol.
+func buildDiagnostics(t *testing.T) []Diagnostic {
+	t.Helper()
+	output, err := os.ReadFile(filepath.Join("testdata", "cbuild-errors.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ParseDiagnostics(string(output), "/work/sol")
+}
+
+func TestParseDiagnostics(t *testing.T) {
+	if filepath.Separator != '/' {
+		t.Skip("the capture has Unix paths")
+	}
+	want := []Diagnostic{
+		{File: "blinky/main.c", Line: 42, Column: 9, Severity: "warning", Message: "unused variable 'count'", Rule: "-Wunused-variable"},
+		{File: "blinky/main.c", Line: 57, Column: 5, Severity: "error", Message: "implicit declaration of function 'LED_Init'", Rule: "-Wimplicit-function-declaration"},
+		{File: "blinky/main.c", Line: 57, Column: 5, Severity: "note", Message: "include '<board.h>' or provide a declaration of 'LED_Init'", Rule: "compiler-note"},
+		{File: "board/board.h", Line: 12, Column: 1, Severity: "warning", Message: "'always_inline' function might not be inlinable", Rule: "-Wattributes"},
+		{File: "/opt/packs/AlifSemiconductor/Ensemble/1.1.0/Device/core/startup.c", Line: 88, Severity: "warning", Message: "format '%d' expects argument of type 'int'", Rule: "-Wformat"},
+		{File: "blinky/retarget.c", Line: 10, Column: 10, Severity: "error", Message: "stdio_retarget.h: No such file or directory", Rule: "compiler-error"},
+	}
+	got := buildDiagnostics(t)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseDiagnostics =\n%+v\nwant\n%+v", got, want)
+	}
+	if errors, warnings := CountDiagnostics(got); errors != 2 || warnings != 3 {
+		t.Errorf("CountDiagnostics = %d errors, %d warnings; want 2, 3", errors, warnings)
+	}
+}
+
+func TestRuleID(t *testing.T) {
+	tests := []struct {
+		flag, severity, want string
+	}{
+		{"-Wunused-variable", "warning", "-Wunused-variable"},
+		{"-Werror=return-type", "error", "-Wreturn-type"},
+		{"-Wformat=", "warning", "-Wformat"},
+		{"-Wunused-parameter,-Wextra", "warning", "-Wunused-parameter"},
+		{" -Wshadow ", "warning", "-Wshadow"},
+		{"", "error", "compiler-error"},
+		{"", "note", "compiler-note"},
+	}
+	for _, tt := range tests {
+		if got := ruleID(tt.flag, tt.severity); got != tt.want {
+			t.Errorf("ruleID(%q, %q) = %q, want %q", tt.flag, tt.severity, got, tt.want)
+		}
+	}
+}
+
+func TestRelativePath(t *testing.T) {
+	if filepath.Separator != '/' {
+		t.Skip("Unix paths")
+	}
+	tests := []struct {
+		path, want string
+	}{
+		{"/work/sol/blinky/main.c", "blinky/main.c"},
+		{"/work/sol/blinky/../board/board.h", "board/board.h"},
+		{"/work/solution2/main.c", "/work/solution2/main.c"},
+		{"/opt/packs/startup.c", "/opt/packs/startup.c"},
+		{"blinky/main.c", "blinky/main.c"},
+	}
+	for _, tt := range tests {
+		if got := relativePath(tt.path, "/work/sol"); got != tt.want {
+			t.Errorf("relativePath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestErrorLines(t *testing.T) {
+	output, err := os.ReadFile(filepath.Join("testdata", "cbuild-errors.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"/work/sol/blinky/main.c:57:5: error: implicit declaration of function 'LED_Init' [-Werror=implicit-function-declaration]",
+		"/work/sol/blinky/retarget.c:10:10: fatal error: stdio_retarget.h: No such file or directory",
+	}
+	if got := ErrorLines(string(output), 2); !reflect.DeepEqual(got, want) {
+		t.Errorf("ErrorLines = %q, want %q", got, want)
+	}
+	if got := ErrorLines(string(output), 10); len(got) != 3 || got[2] != "error cbuild: error building 'blinky.debug+E7-HE'" {
+		t.Errorf("ErrorLines without a limit = %q", got)
+	}
+}
@@ -0,0 +1,98 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"alif-cli/internal/audit"
+)
+
+// Input file formats recognised by DetectFormat.
+const (
+	FormatBinary = "binary"
+	FormatELF    = "elf"
+	FormatHex    = "ihex"
+)
+
+// DetectFormat tells an ELF file (by its magic number) and an Intel HEX
+// file (by its leading ':' record mark) from a raw binary.
+func DetectFormat(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	head := make([]byte, 4)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	head = head[:n]
+	switch {
+	case bytes.Equal(head, []byte("\x7fELF")):
+		return FormatELF, nil
+	case n > 0 && head[0] == ':':
+		return FormatHex, nil
+	}
+	return FormatBinary, nil
+}
+
+// gccTool returns the GNU tool name (e.g. arm-none-eabi-objcopy) from the
+// configured GCC toolchain, or from the PATH.
+func (b *Builder) gccTool(name string) (string, error) {
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	if b.Cfg.GccToolchain != "" {
+		path := filepath.Join(b.Cfg.GccToolchain, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	if path, err := exec.LookPath(name); err == nil {
+		return path, nil
+	}
+	where := "on the PATH (gcc_toolchain_path is not set)"
+	if b.Cfg.GccToolchain != "" {
+		where = fmt.Sprintf("in gcc_toolchain_path (%s) or on the PATH", b.Cfg.GccToolchain)
+	}
+	return "", fmt.Errorf("%s not found %s", name, where)
+}
+
+// objcopyPath returns arm-none-eabi-objcopy from the configured GCC
+// toolchain, or from the PATH.
+func (b *Builder) objcopyPath() (string, error) {
+	path, err := b.gccTool("arm-none-eabi-objcopy")
+	if err != nil {
+		return "", fmt.Errorf("%w; run 'alif setup' or convert the file to a raw binary", err)
+	}
+	return path, nil
+}
+
+// ToBinary converts an ELF or Intel HEX file (format, from DetectFormat)
+// to a raw binary at dst with arm-none-eabi-objcopy -O binary.
+func (b *Builder) ToBinary(src, dst, format string) error {
+	objcopy, err := b.objcopyPath()
+	if err != nil {
+		return err
+	}
+	args := []string{"-O", "binary", src, dst}
+	if format == FormatHex {
+		args = append([]string{"-I", FormatHex}, args...)
+	}
+	cmd := exec.Command(objcopy, args...)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("objcopy failed: %w\n%s", err, output.String())
+	}
+	audit.Record(dst, audit.OpWrite)
+	return nil
+}
@@ -0,0 +1,215 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"alif-cli/internal/project"
+)
+
+// packIndexURL is the public CMSIS pack index cpackget initialises an empty
+// pack root from, as cbuild --packs does.
+const packIndexURL = "https://www.keil.com/pack/index.pidx"
+
+// packPattern matches a pack id at the start of a cpackget or cbuild line
+// (ARM::CMSIS@6.1.0, AlifSemiconductor::Ensemble@>=1.3.0).
+var packPattern = regexp.MustCompile(`^([\w.-]+::[\w.-]+(?:@\S+)?)`)
+
+// Pack is a CMSIS pack as cpackget and cbuild name it.
+type Pack struct {
+	Vendor  string
+	Name    string
+	Version string
+}
+
+// ID returns the pack as Vendor::Name@Version, or Vendor::Name without a
+// version.
+func (p Pack) ID() string {
+	if p.Version == "" {
+		return p.Vendor + "::" + p.Name
+	}
+	return p.Vendor + "::" + p.Name + "@" + p.Version
+}
+
+// ParsePack splits a pack id such as ARM::CMSIS@6.1.0. The version may be
+// missing or a range (@>=6.0.0, @^6.1.0).
+func ParsePack(id string) (Pack, error) {
+	vendor, rest, ok := strings.Cut(strings.TrimSpace(id), "::")
+	if !ok || vendor == "" || rest == "" {
+		return Pack{}, fmt.Errorf("invalid pack '%s' (use Vendor::Name[@version], e.g. ARM::CMSIS@6.1.0)", id)
+	}
+	name, version, _ := strings.Cut(rest, "@")
+	return Pack{Vendor: vendor, Name: name, Version: version}, nil
+}
+
+// parsePacks returns the pack ids listed one per line in tool output,
+// skipping cpackget's "I:"/"W:" messages and anything else.
+func parsePacks(output string) []Pack {
+	var packs []Pack
+	for _, line := range strings.Split(output, "\n") {
+		m := packPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		if p, err := ParsePack(m[1]); err == nil {
+			packs = append(packs, p)
+		}
+	}
+	return packs
+}
+
+// packEnv is the environment cpackget and cbuild list run in: the CMSIS
+// Toolbox first on PATH and CMSIS_PACK_ROOT from the config, when set.
+func (b *Builder) packEnv() []string {
+	env := append(os.Environ(), "PATH="+b.buildPath())
+	if b.Cfg.CmsisPackRoot != "" {
+		env = append(env, "CMSIS_PACK_ROOT="+b.Cfg.CmsisPackRoot)
+	}
+	return env
+}
+
+// packTool runs a CMSIS Toolbox executable and returns its combined output.
+func (b *Builder) packTool(name string, args ...string) (string, error) {
+	path := lookPath(name, filepath.SplitList(b.buildPath()))
+	if path == "" {
+		return "", fmt.Errorf("%s not found; set cmsis_toolbox_path to the CMSIS Toolbox bin folder", name)
+	}
+	b.Report.Debug(path + " " + strings.Join(args, " "))
+	cmd := exec.Command(path, args...)
+	cmd.Env = b.packEnv()
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	return output.String(), err
+}
+
+// packRootReady reports whether the configured pack root has a pack
+// index. Without cmsis_pack_root cpackget's default root is assumed ready.
+func (b *Builder) packRootReady() bool {
+	if b.Cfg.CmsisPackRoot == "" {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(b.Cfg.CmsisPackRoot, ".Web", "index.pidx"))
+	return err == nil
+}
+
+// initPackRoot creates the pack root with the public index when it has
+// none, since cpackget refuses to work in an uninitialised one.
+func (b *Builder) initPackRoot() error {
+	if b.packRootReady() {
+		return nil
+	}
+	s := b.Report.StartTask("Initialising pack root...")
+	if out, err := b.packTool("cpackget", "init", packIndexURL); err != nil {
+		s.Fail("Could not initialise the pack root")
+		b.Report.Output(out)
+		return fmt.Errorf("cpackget init: %w", err)
+	}
+	s.Succeed("Pack root initialised: " + b.Cfg.CmsisPackRoot)
+	return nil
+}
+
+// InstalledPacks lists the packs in the pack root, sorted by id. A pack
+// root that was never initialised holds none.
+func (b *Builder) InstalledPacks() ([]Pack, error) {
+	if !b.packRootReady() {
+		return nil, nil
+	}
+	out, err := b.packTool("cpackget", "list")
+	if err != nil {
+		b.Report.Output(out)
+		return nil, fmt.Errorf("cpackget list: %w", err)
+	}
+	packs := parsePacks(out)
+	sort.Slice(packs, func(i, j int) bool { return packs[i].ID() < packs[j].ID() })
+	return packs, nil
+}
+
+// MissingPacks returns the packs the .csolution.yml in solutionPath
+// requires that are not in the pack root, as cbuild list packs --missing
+// reports them.
+func (b *Builder) MissingPacks(solutionPath string) ([]Pack, error) {
+	sol, err := project.FindCsolution(solutionPath)
+	if err != nil {
+		return nil, err
+	}
+	out, err := b.packTool("cbuild", "list", "packs", sol, "--missing")
+	if err != nil {
+		b.Report.Output(out)
+		return nil, fmt.Errorf("cbuild list packs: %w", err)
+	}
+	return parsePacks(out), nil
+}
+
+// InstallPacks installs each pack with cpackget add, showing one step per
+// pack, and returns the ids that failed. agreeLicense accepts embedded
+// pack licenses without asking, for unattended runs.
+func (b *Builder) InstallPacks(packs []Pack, agreeLicense bool) (failed []string, err error) {
+	if err := b.initPackRoot(); err != nil {
+		return nil, err
+	}
+	for i, p := range packs {
+		args := []string{"add", p.ID()}
+		if agreeLicense {
+			args = append(args, "--agree-embedded-license")
+		}
+		s := b.Report.StartTask(fmt.Sprintf("[%d/%d] Installing %s...", i+1, len(packs), p.ID()))
+		if out, err := b.packTool("cpackget", args...); err != nil {
+			s.Fail("Failed to install " + p.ID())
+			b.Report.Output(out)
+			failed = append(failed, p.ID())
+			continue
+		}
+		s.Succeed("Installed " + p.ID())
+	}
+	return failed, nil
+}
+
+// UpdatePacks refreshes the pack index and updates the installed packs to
+// their latest versions. It returns the packs that are new afterwards.
+func (b *Builder) UpdatePacks() ([]Pack, error) {
+	if err := b.initPackRoot(); err != nil {
+		return nil, err
+	}
+	before, err := b.InstalledPacks()
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range []struct {
+		args            []string
+		msg, done, fail string
+	}{
+		{[]string{"update-index"}, "Updating pack index...", "Pack index updated", "Index update failed"},
+		{[]string{"update"}, "Updating installed packs...", "Packs updated", "Pack update failed"},
+	} {
+		s := b.Report.StartTask(step.msg)
+		if out, err := b.packTool("cpackget", step.args...); err != nil {
+			s.Fail(step.fail)
+			b.Report.Output(out)
+			return nil, fmt.Errorf("cpackget %s: %w", step.args[0], err)
+		}
+		s.Succeed(step.done)
+	}
+	after, err := b.InstalledPacks()
+	if err != nil {
+		return nil, err
+	}
+	had := make(map[string]bool, len(before))
+	for _, p := range before {
+		had[p.ID()] = true
+	}
+	var added []Pack
+	for _, p := range after {
+		if !had[p.ID()] {
+			added = append(added, p)
+		}
+	}
+	return added, nil
+}
@@ -0,0 +1,236 @@
+package builder
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+
+	"alif-cli/internal/audit"
+)
+
+// Report formats accepted by `alif build --report <format>=<file>`.
+const (
+	ReportSARIF = "sarif"
+	ReportJUnit = "junit"
+)
+
+// BuildReport is what a diagnostics report describes: the contexts built,
+// whether the build failed, and the diagnostics it produced.
+type BuildReport struct {
+	Contexts    []string
+	Failed      bool
+	Diagnostics []Diagnostic
+}
+
+// ParseReportSpec splits "<format>=<file>" and checks the format.
+func ParseReportSpec(spec string) (format, path string, err error) {
+	format, path, ok := strings.Cut(spec, "=")
+	format = strings.ToLower(strings.TrimSpace(format))
+	if !ok || path == "" {
+		return "", "", fmt.Errorf("invalid --report '%s' (use sarif=<file> or junit=<file>)", spec)
+	}
+	if format != ReportSARIF && format != ReportJUnit {
+		return "", "", fmt.Errorf("unsupported report format '%s' (use sarif or junit)", format)
+	}
+	return format, path, nil
+}
+
+// WriteReport writes r in format to path.
+func WriteReport(format, path string, r BuildReport) error {
+	var data []byte
+	var err error
+	switch format {
+	case ReportSARIF:
+		data, err = SARIF(r)
+	case ReportJUnit:
+		data, err = JUnit(r)
+	default:
+		return fmt.Errorf("unsupported report format '%s'", format)
+	}
+	if err != nil {
+		return err
+	}
+	return audit.WriteFile(path, data, 0644)
+}
+
+// SARIF 2.1.0 (https://docs.oasis-open.org/sarif/sarif/v2.1.0/) subset
+// used for compiler diagnostics.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool              `json:"tool"`
+	Invocations []sarifInvocation      `json:"invocations"`
+	Results     []sarifResult          `json:"results"`
+	Properties  map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful bool `json:"executionSuccessful"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// SARIF renders r as a SARIF 2.1.0 log. Solution-relative paths carry the
+// %SRCROOT% base id so code scanning maps them to the repository.
+func SARIF(r BuildReport) ([]byte, error) {
+	run := sarifRun{
+		Tool:        sarifTool{Driver: sarifDriver{Name: "alif build", Rules: []sarifRule{}}},
+		Invocations: []sarifInvocation{{ExecutionSuccessful: !r.Failed}},
+		Results:     []sarifResult{},
+	}
+	if len(r.Contexts) > 0 {
+		run.Properties = map[string]interface{}{"contexts": r.Contexts}
+	}
+	rules := map[string]bool{}
+	for _, d := range r.Diagnostics {
+		if !rules[d.Rule] {
+			rules[d.Rule] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: d.Rule})
+		}
+		loc := sarifArtifactLocation{URI: d.File}
+		if !isAbsURI(d.File) {
+			loc.URIBaseID = "%SRCROOT%"
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:  d.Rule,
+			Level:   d.Severity,
+			Message: sarifMessage{Text: d.Message},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: loc,
+				Region:           sarifRegion{StartLine: max(d.Line, 1), StartColumn: d.Column},
+			}}},
+		})
+	}
+	sort.Slice(run.Tool.Driver.Rules, func(i, j int) bool {
+		return run.Tool.Driver.Rules[i].ID < run.Tool.Driver.Rules[j].ID
+	})
+	return json.MarshalIndent(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+}
+
+// isAbsURI reports whether a diagnostic path is absolute (outside the
+// solution) on either Unix or Windows.
+func isAbsURI(p string) bool {
+	return strings.HasPrefix(p, "/") || (len(p) > 2 && p[1] == ':')
+}
+
+// JUnit XML in the form CI test report parsers accept: one test case per
+// diagnostic, warnings as failures and errors as errors.
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// JUnit renders r as JUnit XML. Notes are left out; a clean build is a
+// single passing "build" case, and a failed build without any parsed error
+// gets an error case so it is not reported as clean.
+func JUnit(r BuildReport) ([]byte, error) {
+	suite := junitSuite{Name: "alif build"}
+	if len(r.Contexts) > 0 {
+		suite.Name += " " + strings.Join(r.Contexts, ", ")
+	}
+	for _, d := range r.Diagnostics {
+		if d.Severity == "note" {
+			continue
+		}
+		where := fmt.Sprintf("%s:%d", d.File, d.Line)
+		if d.Column > 0 {
+			where += fmt.Sprintf(":%d", d.Column)
+		}
+		tc := junitCase{ClassName: d.File, Name: fmt.Sprintf("%s %s", where, d.Rule)}
+		p := &junitProblem{Type: d.Rule, Message: d.Message, Text: fmt.Sprintf("%s: %s: %s", where, d.Severity, d.Message)}
+		if d.Severity == "error" {
+			tc.Error = p
+			suite.Errors++
+		} else {
+			tc.Failure = p
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	switch {
+	case r.Failed && suite.Errors == 0:
+		suite.Cases = append(suite.Cases, junitCase{ClassName: "build", Name: "build", Error: &junitProblem{Type: "build", Message: "build failed"}})
+		suite.Errors++
+	case len(suite.Cases) == 0:
+		suite.Cases = append(suite.Cases, junitCase{ClassName: "build", Name: "build"})
+	}
+	suite.Tests = len(suite.Cases)
+
+	out, err := xml.MarshalIndent(junitSuites{Suites: []junitSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+}
@@ -0,0 +1,201 @@
+package builder
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// checkSARIF validates a log against the parts of the SARIF 2.1.0 schema
+// code scanning relies on: required properties, the level enum, and
+// positive line and column numbers.
+func checkSARIF(t *testing.T, data []byte) {
+	t.Helper()
+	var log struct {
+		Schema  string `json:"$schema"`
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool *struct {
+				Driver *struct {
+					Name  string `json:"name"`
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID  string `json:"ruleId"`
+				Level   string `json:"level"`
+				Message *struct {
+					Text string `json:"text"`
+				} `json:"message"`
+				Locations []struct {
+					PhysicalLocation *struct {
+						ArtifactLocation *struct {
+							URI       string `json:"uri"`
+							URIBaseID string `json:"uriBaseId"`
+						} `json:"artifactLocation"`
+						Region *struct {
+							StartLine   *int `json:"startLine"`
+							StartColumn *int `json:"startColumn"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatalf("SARIF is not JSON: %v", err)
+	}
+	if log.Version != "2.1.0" || !strings.Contains(log.Schema, "sarif-2.1.0") || log.Runs == nil {
+		t.Errorf("SARIF header: version %q, $schema %q, runs %v", log.Version, log.Schema, log.Runs != nil)
+	}
+	for _, run := range log.Runs {
+		if run.Tool == nil || run.Tool.Driver == nil || run.Tool.Driver.Name == "" {
+			t.Errorf("run without tool.driver.name")
+			continue
+		}
+		rules := map[string]bool{}
+		for _, r := range run.Tool.Driver.Rules {
+			rules[r.ID] = true
+		}
+		for i, r := range run.Results {
+			switch r.Level {
+			case "none", "note", "warning", "error":
+			default:
+				t.Errorf("result %d: level %q", i, r.Level)
+			}
+			if r.Message == nil || r.Message.Text == "" {
+				t.Errorf("result %d: no message.text", i)
+			}
+			if !rules[r.RuleID] {
+				t.Errorf("result %d: rule %q not in tool.driver.rules", i, r.RuleID)
+			}
+			for _, loc := range r.Locations {
+				pl := loc.PhysicalLocation
+				if pl == nil || pl.ArtifactLocation == nil || pl.ArtifactLocation.URI == "" || pl.Region == nil || pl.Region.StartLine == nil {
+					t.Errorf("result %d: incomplete physicalLocation", i)
+					continue
+				}
+				if *pl.Region.StartLine < 1 || (pl.Region.StartColumn != nil && *pl.Region.StartColumn < 1) {
+					t.Errorf("result %d: region %d:%v", i, *pl.Region.StartLine, pl.Region.StartColumn)
+				}
+				if strings.HasPrefix(pl.ArtifactLocation.URI, "/") == (pl.ArtifactLocation.URIBaseID != "") {
+					t.Errorf("result %d: uri %q with uriBaseId %q", i, pl.ArtifactLocation.URI, pl.ArtifactLocation.URIBaseID)
+				}
+			}
+		}
+	}
+}
+
+// checkJUnit validates a report against the JUnit XML format CI parsers
+// read: a testsuites root whose suites' counts match their test cases.
+func checkJUnit(t *testing.T, data []byte) {
+	t.Helper()
+	var suites struct {
+		XMLName xml.Name `xml:"testsuites"`
+		Suites  []struct {
+			Name     string `xml:"name,attr"`
+			Tests    int    `xml:"tests,attr"`
+			Failures int    `xml:"failures,attr"`
+			Errors   int    `xml:"errors,attr"`
+			Cases    []struct {
+				ClassName string    `xml:"classname,attr"`
+				Name      string    `xml:"name,attr"`
+				Failure   *struct{} `xml:"failure"`
+				Error     *struct{} `xml:"error"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	if err := xml.Unmarshal(data, &suites); err != nil {
+		t.Fatalf("JUnit report is not testsuites XML: %v", err)
+	}
+	if len(suites.Suites) == 0 {
+		t.Error("no testsuite")
+	}
+	for _, s := range suites.Suites {
+		failures, errors := 0, 0
+		for _, c := range s.Cases {
+			if c.Name == "" || c.ClassName == "" {
+				t.Errorf("%s: test case without name or classname", s.Name)
+			}
+			if c.Failure != nil {
+				failures++
+			}
+			if c.Error != nil {
+				errors++
+			}
+		}
+		if s.Name == "" || s.Tests != len(s.Cases) || s.Failures != failures || s.Errors != errors {
+			t.Errorf("suite %q counts tests=%d failures=%d errors=%d, has %d cases, %d failures, %d errors",
+				s.Name, s.Tests, s.Failures, s.Errors, len(s.Cases), failures, errors)
+		}
+	}
+}
+
+func TestReportGolden(t *testing.T) {
+	if filepath.Separator != '/' {
+		t.Skip("the capture has Unix paths")
+	}
+	failed := BuildReport{Contexts: []string{"blinky.debug+E7-HE"}, Failed: true, Diagnostics: buildDiagnostics(t)}
+	tests := []struct {
+		name   string
+		format string
+		report BuildReport
+		golden string
+		check  func(*testing.T, []byte)
+	}{
+		{"failed build", ReportSARIF, failed, "report-failed.sarif", checkSARIF},
+		{"failed build", ReportJUnit, failed, "report-failed.junit.xml", checkJUnit},
+		{"clean build", ReportSARIF, BuildReport{Contexts: []string{"blinky.release+E7-HE"}}, "report-clean.sarif", checkSARIF},
+		{"clean build", ReportJUnit, BuildReport{Contexts: []string{"blinky.release+E7-HE"}}, "report-clean.junit.xml", checkJUnit},
+		// A link error has no compiler diagnostic, but the build did fail.
+		{"failed without diagnostics", ReportJUnit, BuildReport{Failed: true}, "report-link-error.junit.xml", checkJUnit},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), tt.golden)
+		if err := WriteReport(tt.format, path, tt.report); err != nil {
+			t.Fatalf("%s %s: %v", tt.name, tt.format, err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := os.ReadFile(filepath.Join("testdata", tt.golden))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s %s: report differs from %s:\n%s", tt.name, tt.format, tt.golden, got)
+		}
+		tt.check(t, want)
+	}
+}
+
+func TestParseReportSpec(t *testing.T) {
+	tests := []struct {
+		spec, format, path string
+		errSubstr          string
+	}{
+		{"sarif=build.sarif", ReportSARIF, "build.sarif", ""},
+		{"JUnit=out/junit.xml", ReportJUnit, "out/junit.xml", ""},
+		{"sarif=a=b.sarif", ReportSARIF, "a=b.sarif", ""},
+		{"sarif", "", "", "invalid --report"},
+		{"sarif=", "", "", "invalid --report"},
+		{"html=report.html", "", "", "unsupported report format 'html'"},
+	}
+	for _, tt := range tests {
+		format, path, err := ParseReportSpec(tt.spec)
+		switch {
+		case tt.errSubstr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.spec, err)
+		case tt.errSubstr != "" && (err == nil || !strings.Contains(err.Error(), tt.errSubstr)):
+			t.Errorf("%s: error = %v, want one mentioning %q", tt.spec, err, tt.errSubstr)
+		case format != tt.format || path != tt.path:
+			t.Errorf("ParseReportSpec(%q) = %q, %q; want %q, %q", tt.spec, format, path, tt.format, tt.path)
+		}
+	}
+}
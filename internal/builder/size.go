@@ -0,0 +1,70 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"alif-cli/internal/mapfile"
+)
+
+// MemoryUsage is how much memory a built image takes: the section totals
+// arm-none-eabi-size reports for the elf, and the use of each linker
+// region when the map file is there.
+type MemoryUsage struct {
+	Text    uint64                `json:"text"`
+	Data    uint64                `json:"data"`
+	BSS     uint64                `json:"bss"`
+	Regions []mapfile.RegionUsage `json:"regions,omitempty"`
+}
+
+// MemoryUsage runs arm-none-eabi-size on elfPath and, if mapPath is not
+// empty, sums the linker map per memory region. A map that cannot be read
+// only leaves Regions empty.
+func (b *Builder) MemoryUsage(elfPath, mapPath string) (*MemoryUsage, error) {
+	size, err := b.gccTool("arm-none-eabi-size")
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(size, elfPath)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("arm-none-eabi-size failed: %w\n%s", err, output.String())
+	}
+	usage, err := parseSize(output.String())
+	if err != nil {
+		return nil, err
+	}
+	if mapPath != "" {
+		if m, err := mapfile.ParseFile(mapPath); err == nil {
+			usage.Regions = m.Usage()
+		}
+	}
+	return usage, nil
+}
+
+// parseSize reads the Berkeley format of arm-none-eabi-size: a header
+// line, then text, data, bss, dec, hex and the file name in decimal.
+func parseSize(out string) (*MemoryUsage, error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) < 2 {
+		return nil, fmt.Errorf("unexpected arm-none-eabi-size output: %q", out)
+	}
+	fields := strings.Fields(lines[1])
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("unexpected arm-none-eabi-size output: %q", lines[1])
+	}
+	var values [3]uint64
+	for i := range values {
+		v, err := strconv.ParseUint(fields[i], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected arm-none-eabi-size output: %q", lines[1])
+		}
+		values[i] = v
+	}
+	return &MemoryUsage{Text: values[0], Data: values[1], BSS: values[2]}, nil
+}
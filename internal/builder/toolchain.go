@@ -0,0 +1,314 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"alif-cli/internal/audit"
+	"alif-cli/internal/config"
+	"alif-cli/internal/project"
+	"alif-cli/internal/state"
+)
+
+// versionPattern matches a semantic version in a tool banner, with an
+// optional pre-release suffix (2.6.0, 13.3.1, 2.7.0-dev1).
+var versionPattern = regexp.MustCompile(`\d+\.\d+\.\d+(-[0-9A-Za-z.]+)?`)
+
+// armCompilerPattern finds the compiler version in an armclang banner,
+// whose first line names the product it came with ("Product: MDK Plus
+// 5.38") and a later one the compiler ("Component: Arm Compiler for
+// Embedded 6.22").
+var armCompilerPattern = regexp.MustCompile(`Component: Arm Compiler[^\n]*?(\d+\.\d+(?:\.\d+)?)`)
+
+// ParseToolVersion extracts the version from the first line of a --version
+// banner. GCC names its vendor build in parentheses before the version
+// ("arm-none-eabi-gcc (Arm GNU Toolchain 13.3.Rel1 (Build arm-13.24))
+// 13.3.1 20240614"), so text after the last ')' is preferred; armclang's
+// Component line is used over its first. Returns the trimmed first line
+// when it holds no version.
+func ParseToolVersion(banner string) string {
+	if m := armCompilerPattern.FindStringSubmatch(banner); m != nil {
+		return m[1]
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(banner), "\n")
+	line = strings.TrimSpace(line)
+	if i := strings.LastIndex(line, ")"); i >= 0 {
+		if v := versionPattern.FindString(line[i+1:]); v != "" {
+			return v
+		}
+	}
+	if v := versionPattern.FindString(line); v != "" {
+		return v
+	}
+	return line
+}
+
+// ToolchainChanges lists the tools whose version differs between the last
+// build (old) and this one, as "gcc 13.2.1 -> 13.3.1". Tools unknown on
+// either side are not compared.
+func ToolchainChanges(old, cur state.ToolchainVersions) []string {
+	var changes []string
+	for _, tool := range []struct{ name, old, cur string }{
+		{"cbuild", old.Cbuild, cur.Cbuild},
+		{"gcc", old.GCC, cur.GCC},
+	} {
+		if tool.old != "" && tool.cur != "" && tool.old != tool.cur {
+			changes = append(changes, fmt.Sprintf("%s %s -> %s", tool.name, tool.old, tool.cur))
+		}
+	}
+	return changes
+}
+
+// ToolchainVersions returns the versions of cbuild and arm-none-eabi-gcc
+// that a build runs, found on the build's PATH. A tool that cannot be
+// found or run is left empty.
+func (b *Builder) ToolchainVersions() state.ToolchainVersions {
+	dirs := filepath.SplitList(b.buildPath())
+	v := state.ToolchainVersions{RecordedAt: time.Now()}
+	if path := lookPath("cbuild", dirs); path != "" {
+		v.Cbuild, v.CbuildPath = cachedToolVersion(path), path
+	}
+	if path := lookPath("arm-none-eabi-gcc", dirs); path != "" {
+		v.GCC, v.GCCPath = cachedToolVersion(path), path
+	}
+	return v
+}
+
+// buildPath is the PATH setupEnv gives the build.
+func (b *Builder) buildPath() string {
+	dirs := []string{b.Cfg.CmsisToolbox, b.Cfg.GccToolchain}
+	if b.Cfg.ArmclangPath != "" {
+		dirs = append(dirs, b.Cfg.ArmclangPath)
+	}
+	return strings.Join(append(dirs, os.Getenv("PATH")), string(os.PathListSeparator))
+}
+
+// fallbackGccVersion is registered when the version of arm-none-eabi-gcc
+// cannot be read, as cbuild needs some GCC_TOOLCHAIN_* to find it at all.
+const fallbackGccVersion = "13.2.1"
+
+// toolchainVariable names the variable that registers a compiler of the
+// given version with the CMSIS Toolbox ("GCC", "13.3.1" gives
+// GCC_TOOLCHAIN_13_3_1; a missing patch counts as 0). Returns "" when
+// version is not numeric.
+func toolchainVariable(compiler, version string) string {
+	parts := strings.Split(version, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return ""
+	}
+	for _, p := range parts {
+		if _, err := strconv.Atoi(p); err != nil {
+			return ""
+		}
+	}
+	for len(parts) < 3 {
+		parts = append(parts, "0")
+	}
+	return compiler + "_TOOLCHAIN_" + strings.Join(parts, "_")
+}
+
+// gccRegistration returns the GCC_TOOLCHAIN_<major>_<minor>_<patch>
+// variable for the arm-none-eabi-gcc a build runs, with the version from
+// its --version banner.
+func (b *Builder) gccRegistration() string {
+	dir, version := b.Cfg.GccToolchain, ""
+	if path := lookPath("arm-none-eabi-gcc", filepath.SplitList(b.buildPath())); path != "" {
+		dir, version = filepath.Dir(path), cachedToolVersion(path)
+	}
+	name := toolchainVariable("GCC", version)
+	if name == "" {
+		b.Report.Debug(fmt.Sprintf("Unknown arm-none-eabi-gcc version '%s'; registering it as %s", version, fallbackGccVersion))
+		version = fallbackGccVersion
+		name = toolchainVariable("GCC", version)
+	}
+	b.configChecked.Do(func() { b.checkToolchainConfig("GCC", version) })
+	return name + "=" + dir
+}
+
+// ac6Registration returns the AC6_TOOLCHAIN_<major>_<minor>_<patch>
+// variable that registers armclang_path with the CMSIS Toolbox, or "" when
+// no Arm Compiler is configured or its version cannot be read.
+func (b *Builder) ac6Registration() string {
+	if b.Cfg.ArmclangPath == "" {
+		return ""
+	}
+	path := lookPath("armclang", []string{b.Cfg.ArmclangPath})
+	if path == "" {
+		return ""
+	}
+	version := cachedToolVersion(path)
+	name := toolchainVariable("AC6", version)
+	if name == "" {
+		b.Report.Debug(fmt.Sprintf("Unknown armclang version '%s'; AC6 not registered", version))
+		return ""
+	}
+	return name + "=" + b.Cfg.ArmclangPath
+}
+
+// checkToolchainConfig warns when the CMSIS Toolbox has no
+// etc/<compiler>.<version>.cmake for the registered version. cbuild uses
+// the newest file not above the compiler's version, so a compiler older
+// than all of them is rejected as not registered. The files are not
+// written here: one made for a version the toolbox does not support would
+// only move the failure into the build.
+func (b *Builder) checkToolchainConfig(compiler, version string) {
+	if b.Cfg.CmsisToolbox == "" {
+		return
+	}
+	etc := filepath.Join(filepath.Dir(b.Cfg.CmsisToolbox), "etc")
+	files, _ := filepath.Glob(filepath.Join(etc, compiler+".*.cmake"))
+	if len(files) == 0 {
+		return
+	}
+	var known []string
+	for _, f := range files {
+		v := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(f), compiler+"."), ".cmake")
+		if compareVersions(v, version) <= 0 {
+			return
+		}
+		known = append(known, v)
+	}
+	b.Report.Warn(fmt.Sprintf("The CMSIS Toolbox in %s has no %s configuration for version %s (it has %s); cbuild may report the toolchain as not registered", etc, compiler, version, strings.Join(known, ", ")))
+}
+
+// compareVersions orders dotted numeric versions, treating missing or
+// non-numeric parts as 0.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	return 0
+}
+
+// checkCompiler fails early when the solution selects the Arm Compiler
+// (compiler: AC6) and none is configured or registered in the environment.
+func (b *Builder) checkCompiler(solutionPath string) error {
+	if !strings.EqualFold(project.SolutionCompiler(solutionPath), "AC6") {
+		return nil
+	}
+	if b.Cfg.ArmclangPath != "" {
+		if lookPath("armclang", []string{b.Cfg.ArmclangPath}) == "" {
+			return fmt.Errorf("project requires AC6 but armclang was not found in armclang_path (%s)", b.Cfg.ArmclangPath)
+		}
+		return nil
+	}
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, "AC6_TOOLCHAIN_") {
+			return nil
+		}
+	}
+	return fmt.Errorf("project requires AC6 but no Arm Compiler is configured; run 'alif setup --armclang <bin folder>' or 'alif config set %s <bin folder>'", config.KeyArmclang)
+}
+
+// recordToolchain stores the toolchain versions of a successful build of
+// scope in the project state and warns when they differ from the ones its
+// previous build used.
+func (b *Builder) recordToolchain(alifDir, scope string) {
+	cur := b.ToolchainVersions()
+	if cur.Cbuild == "" && cur.GCC == "" {
+		return
+	}
+	var changes []string
+	state.Update(alifDir, func(st *state.State) {
+		if old, ok := st.Toolchains[scope]; ok {
+			changes = ToolchainChanges(old, cur)
+		}
+		if st.Toolchains == nil {
+			st.Toolchains = make(map[string]state.ToolchainVersions)
+		}
+		st.Toolchains[scope] = cur
+	})
+	if len(changes) > 0 {
+		b.Report.Warn(fmt.Sprintf("Toolchain changed since the last build of %s: %s", scope, strings.Join(changes, ", ")))
+	}
+}
+
+// lookPath finds an executable in dirs, like exec.LookPath on a PATH that
+// is not the process's own.
+func lookPath(name string, dirs []string) string {
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// versionCacheFile holds tool versions by executable path
+// (~/.alif/cache/toolchain-versions.json).
+const versionCacheFile = "toolchain-versions.json"
+
+// cachedVersion is a tool version and the modification time and size of
+// the executable it was read from.
+type cachedVersion struct {
+	Version string    `json:"version"`
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`
+}
+
+// current reports whether c was read from the executable info describes,
+// so a tool replaced in place (an upgrade, a re-pointed symlink) is read
+// again.
+func (c cachedVersion) current(info os.FileInfo) bool {
+	return c.ModTime.Equal(info.ModTime()) && c.Size == info.Size()
+}
+
+// cachedToolVersion runs path --version only when the executable at path
+// is not the one last read, by modification time and size, since a
+// compiler can take a noticeable moment to start.
+func cachedToolVersion(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	cache := map[string]cachedVersion{}
+	cacheFile := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		cacheFile = filepath.Join(home, ".alif", "cache", versionCacheFile)
+		if content, err := os.ReadFile(cacheFile); err == nil {
+			json.Unmarshal(content, &cache)
+		}
+	}
+	if c, ok := cache[path]; ok && c.current(info) {
+		return c.Version
+	}
+	out, err := exec.Command(path, "--version").CombinedOutput()
+	if err != nil && len(out) == 0 {
+		return ""
+	}
+	version := ParseToolVersion(string(out))
+	if cacheFile != "" {
+		cache[path] = cachedVersion{Version: version, ModTime: info.ModTime(), Size: info.Size()}
+		if content, err := json.MarshalIndent(cache, "", "  "); err == nil {
+			if os.MkdirAll(filepath.Dir(cacheFile), 0755) == nil {
+				audit.WriteFileAtomic(cacheFile, content, 0644)
+			}
+		}
+	}
+	return version
+}
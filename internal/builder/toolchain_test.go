@@ -0,0 +1,191 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"alif-cli/internal/config"
+	"alif-cli/internal/ui"
+)
+
+// fakeTool writes an executable at dir/name that prints banner and counts
+// its runs in dir/runs.
+func fakeTool(t *testing.T, dir, name, banner string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	path := filepath.Join(dir, name)
+	script := "#!/bin/sh\necho run >> " + filepath.Join(dir, "runs") + "\necho '" + banner + "'\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func toolRuns(t *testing.T, dir string) int {
+	t.Helper()
+	b, _ := os.ReadFile(filepath.Join(dir, "runs"))
+	return strings.Count(string(b), "run")
+}
+
+func TestCachedToolVersion(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	gcc := fakeTool(t, dir, "arm-none-eabi-gcc", "arm-none-eabi-gcc (Arm GNU Toolchain 13.2.rel1 (Build arm-13.7)) 13.2.1 20231009")
+
+	if v := cachedToolVersion(gcc); v != "13.2.1" {
+		t.Fatalf("first read = %q, want 13.2.1", v)
+	}
+	if v := cachedToolVersion(gcc); v != "13.2.1" || toolRuns(t, dir) != 1 {
+		t.Fatalf("second read = %q after %d run(s), want the cached 13.2.1 after 1", v, toolRuns(t, dir))
+	}
+
+	// An upgrade in place changes the size: the version is read again.
+	fakeTool(t, dir, "arm-none-eabi-gcc", "arm-none-eabi-gcc (Arm GNU Toolchain 14.2.Rel1 (Build arm-14.52)) 14.2.1 20241119")
+	if v := cachedToolVersion(gcc); v != "14.2.1" || toolRuns(t, dir) != 2 {
+		t.Fatalf("after resize = %q after %d run(s), want 14.2.1 after 2", v, toolRuns(t, dir))
+	}
+
+	// So does a new modification time with the same size.
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(gcc, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if cachedToolVersion(gcc); toolRuns(t, dir) != 3 {
+		t.Fatalf("after touch: %d run(s), want 3", toolRuns(t, dir))
+	}
+	if cachedToolVersion(gcc); toolRuns(t, dir) != 3 {
+		t.Fatalf("unchanged: %d run(s), want 3", toolRuns(t, dir))
+	}
+}
+
+func TestCachedToolVersionMissing(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if v := cachedToolVersion(filepath.Join(t.TempDir(), "cbuild")); v != "" {
+		t.Errorf("missing tool = %q, want empty", v)
+	}
+}
+
+func TestParseToolVersion(t *testing.T) {
+	tests := []struct {
+		banner string
+		want   string
+	}{
+		// GNU Arm Embedded Toolchain 9-2019-q4
+		{"arm-none-eabi-gcc (GNU Tools for Arm Embedded Processors 9-2019-q4-major) 9.2.1 20191025 (release) [ARM/arm-9-branch revision 277599]", "9.2.1"},
+		// GNU Arm Embedded Toolchain 10.3-2021.10
+		{"arm-none-eabi-gcc (GNU Arm Embedded Toolchain 10.3-2021.10) 10.3.1 20210824 (release)\nCopyright (C) 2020 Free Software Foundation, Inc.\n", "10.3.1"},
+		// GNU Toolchain for the Arm Architecture 11.2-2022.02
+		{"arm-none-eabi-gcc (GNU Toolchain for the Arm Architecture 11.2-2022.02 (arm-11.14)) 11.2.1 20220111", "11.2.1"},
+		// Arm GNU Toolchain 12.3.Rel1 to 14.2.Rel1
+		{"arm-none-eabi-gcc (Arm GNU Toolchain 12.3.Rel1 (Build arm-12.35)) 12.3.1 20230626\nCopyright (C) 2022 Free Software Foundation, Inc.", "12.3.1"},
+		{"arm-none-eabi-gcc (Arm GNU Toolchain 13.2.rel1 (Build arm-13.7)) 13.2.1 20231009", "13.2.1"},
+		{"arm-none-eabi-gcc (Arm GNU Toolchain 13.3.Rel1 (Build arm-13.24)) 13.3.1 20240614", "13.3.1"},
+		{"arm-none-eabi-gcc (Arm GNU Toolchain 14.2.Rel1 (Build arm-14.52)) 14.2.1 20241119", "14.2.1"},
+		// Debian and Ubuntu's gcc-arm-none-eabi
+		{"arm-none-eabi-gcc (15:12.2.rel1-1) 12.2.1 20221205", "12.2.1"},
+		// xPack
+		{"arm-none-eabi-gcc (xPack GNU Arm Embedded GCC x86_64) 13.2.1 20231009", "13.2.1"},
+		// Windows reports the executable name with its extension.
+		{"arm-none-eabi-gcc.exe (Arm GNU Toolchain 13.3.Rel1 (Build arm-13.24)) 13.3.1 20240614\r\n", "13.3.1"},
+		{"cbuild version 2.6.0 (C) 2024 Arm Ltd. and Contributors", "2.6.0"},
+		{"cbuild version 2.7.0-dev1", "2.7.0-dev1"},
+		{"Product: MDK Plus 5.38\nComponent: Arm Compiler for Embedded 6.22\nTool: armclang [5ee92100]", "6.22"},
+		{"  unknown tool  \nline two", "unknown tool"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := ParseToolVersion(tt.banner); got != tt.want {
+			t.Errorf("ParseToolVersion(%q) = %q, want %q", tt.banner, got, tt.want)
+		}
+	}
+}
+
+func TestToolchainVariable(t *testing.T) {
+	tests := []struct {
+		compiler, version string
+		want              string
+	}{
+		{"GCC", "13.3.1", "GCC_TOOLCHAIN_13_3_1"},
+		{"GCC", "12.2.1", "GCC_TOOLCHAIN_12_2_1"},
+		{"AC6", "6.22", "AC6_TOOLCHAIN_6_22_0"},
+		{"GCC", "2.7.0-dev1", ""},
+		{"GCC", "13", ""},
+		{"GCC", "1.2.3.4", ""},
+		{"GCC", "unknown tool", ""},
+		{"GCC", "", ""},
+	}
+	for _, tt := range tests {
+		if got := toolchainVariable(tt.compiler, tt.version); got != tt.want {
+			t.Errorf("toolchainVariable(%q, %q) = %q, want %q", tt.compiler, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int // sign only
+	}{
+		{"13.3.1", "13.3.1", 0},
+		{"10.3.1", "9.2.1", 1},
+		{"12.3.1", "13.2.1", -1},
+		{"6.22", "6.22.0", 0},
+		{"6.18", "6.22", -1},
+	}
+	for _, tt := range tests {
+		got := compareVersions(tt.a, tt.b)
+		if (got > 0) != (tt.want > 0) || (got < 0) != (tt.want < 0) {
+			t.Errorf("compareVersions(%q, %q) = %d, want sign %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// warnings is a silent Reporter that keeps the warnings it was given.
+type warnings struct {
+	ui.Reporter
+	list []string
+}
+
+func (w *warnings) Warn(msg string) { w.list = append(w.list, msg) }
+
+func TestGccRegistration(t *testing.T) {
+	tests := []struct {
+		name   string
+		banner string
+		want   string
+		warned bool
+	}{
+		{"current", "arm-none-eabi-gcc (Arm GNU Toolchain 14.2.Rel1 (Build arm-14.52)) 14.2.1 20241119", "GCC_TOOLCHAIN_14_2_1", false},
+		{"between configurations", "arm-none-eabi-gcc (Arm GNU Toolchain 12.3.Rel1 (Build arm-12.35)) 12.3.1 20230626", "GCC_TOOLCHAIN_12_3_1", false},
+		{"older than every configuration", "arm-none-eabi-gcc (GNU Tools for Arm Embedded Processors 9-2019-q4-major) 9.2.1 20191025 (release)", "GCC_TOOLCHAIN_9_2_1", true},
+		{"unreadable banner", "arm-none-eabi-gcc: fatal error", "GCC_TOOLCHAIN_" + strings.ReplaceAll(fallbackGccVersion, ".", "_"), false},
+	}
+	for _, tt := range tests {
+		t.Setenv("HOME", t.TempDir())
+		t.Setenv("PATH", filepath.Join(t.TempDir(), "empty"))
+		gcc, toolbox := t.TempDir(), t.TempDir()
+		fakeTool(t, gcc, "arm-none-eabi-gcc", tt.banner)
+		for _, name := range []string{"GCC.10.3.1.cmake", "GCC.13.3.1.cmake"} {
+			if err := os.MkdirAll(filepath.Join(toolbox, "etc"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(toolbox, "etc", name), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		w := &warnings{Reporter: ui.Silent}
+		b := &Builder{Cfg: &config.Config{GccToolchain: gcc, CmsisToolbox: filepath.Join(toolbox, "bin")}, Report: w}
+		if got := b.gccRegistration(); got != tt.want+"="+gcc {
+			t.Errorf("%s: %q, want %q", tt.name, got, tt.want+"="+gcc)
+		}
+		if (len(w.list) > 0) != tt.warned {
+			t.Errorf("%s: warnings %q", tt.name, w.list)
+		}
+	}
+}
@@ -1,8 +1,13 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"time"
+
+	"alif-cli/internal/audit"
 
 	"github.com/spf13/viper"
 )
@@ -13,25 +18,143 @@ type Config struct {
 	GccToolchain   string `mapstructure:"gcc_toolchain_path"`
 	CmsisPackRoot  string `mapstructure:"cmsis_pack_root"`
 	SigningKeyPath string `mapstructure:"signing_key_path"`
+	DefaultPort    string `mapstructure:"default_port"`
+	// ArmclangPath is the bin folder of the Arm Compiler for Embedded (AC6),
+	// for solutions that select compiler: AC6.
+	ArmclangPath string `mapstructure:"armclang_path"`
+	// FlashTimeout bounds each toolkit or J-Link run while flashing, as a
+	// Go duration such as "120s" or "3m".
+	FlashTimeout string `mapstructure:"flash_timeout"`
+	// JLinkSerial selects the J-Link probe by serial number when several
+	// are attached.
+	JLinkSerial string `mapstructure:"jlink_serial"`
+	// JLinkPath is J-Link Commander (or the folder holding it) when it is
+	// not on PATH.
+	JLinkPath string `mapstructure:"jlink_path"`
+	// ProbeCacheTTL is how long a board's probed part number and revision
+	// are reused, as a Go duration; "0s" probes every time.
+	ProbeCacheTTL string `mapstructure:"probe_cache_ttl"`
+	// PortPatterns are substrings of port names that identify a board's
+	// serial port; PortIDs are "VID:PID" pairs that do the same.
+	PortPatterns []string `mapstructure:"port_patterns"`
+	PortIDs      []string `mapstructure:"port_ids"`
+	// PortLabels names USB devices in the port picker, keyed by "VID:PID"
+	// or just "VID".
+	PortLabels map[string]string `mapstructure:"port_labels"`
 }
 
-func LoadConfig() (*Config, error) {
+// Config keys, as written in config.yaml.
+const (
+	KeyAlifToolsPath  = "alif_tools_path"
+	KeyCmsisToolbox   = "cmsis_toolbox_path"
+	KeyGccToolchain   = "gcc_toolchain_path"
+	KeyArmclang       = "armclang_path"
+	KeyCmsisPackRoot  = "cmsis_pack_root"
+	KeySigningKeyPath = "signing_key_path"
+	KeyDefaultPort    = "default_port"
+	KeyFlashTimeout   = "flash_timeout"
+	KeyJLinkSerial    = "jlink_serial"
+	KeyJLinkPath      = "jlink_path"
+	KeyProbeCacheTTL  = "probe_cache_ttl"
+)
+
+// ErrNotFound is returned by LoadConfig when there is no config file yet.
+var ErrNotFound = errors.New("config file not found")
+
+// ParseError is returned by LoadConfig when the config file exists but
+// cannot be parsed.
+type ParseError struct {
+	Path string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("failed to parse %s: %v", e.Path, e.Err)
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// Path returns the location of config.yaml.
+func Path() (string, error) {
 	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".alif", "config.yaml"), nil
+}
+
+func LoadConfig() (*Config, error) {
+	path, err := Path()
 	if err != nil {
 		return nil, err
 	}
 
-	viper.AddConfigPath(filepath.Join(home, ".alif"))
+	viper.AddConfigPath(filepath.Dir(path))
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
 
 	if err := viper.ReadInConfig(); err != nil {
-		return nil, err
+		var notFound viper.ConfigFileNotFoundError
+		if errors.As(err, &notFound) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, path)
+		}
+		return nil, &ParseError{Path: path, Err: err}
 	}
 
 	var cfg Config
-	err = viper.Unmarshal(&cfg)
-	return &cfg, err
+	if err := viper.Unmarshal(&cfg); err != nil {
+		return nil, &ParseError{Path: path, Err: err}
+	}
+	return &cfg, nil
+}
+
+// fields maps config keys to the struct fields backing them.
+func (c *Config) fields() map[string]*string {
+	return map[string]*string{
+		KeyAlifToolsPath:  &c.AlifToolsPath,
+		KeyCmsisToolbox:   &c.CmsisToolbox,
+		KeyGccToolchain:   &c.GccToolchain,
+		KeyArmclang:       &c.ArmclangPath,
+		KeyCmsisPackRoot:  &c.CmsisPackRoot,
+		KeySigningKeyPath: &c.SigningKeyPath,
+		KeyDefaultPort:    &c.DefaultPort,
+		KeyFlashTimeout:   &c.FlashTimeout,
+		KeyJLinkSerial:    &c.JLinkSerial,
+		KeyJLinkPath:      &c.JLinkPath,
+		KeyProbeCacheTTL:  &c.ProbeCacheTTL,
+	}
+}
+
+// Set assigns a value by config key.
+func (c *Config) Set(key, value string) error {
+	field, ok := c.fields()[key]
+	if !ok {
+		return fmt.Errorf("unknown config key '%s'", key)
+	}
+	if key == KeyFlashTimeout {
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			return fmt.Errorf("invalid %s '%s': use a duration such as 120s or 3m", key, value)
+		}
+	}
+	if key == KeyProbeCacheTTL {
+		if d, err := time.ParseDuration(value); err != nil || d < 0 {
+			return fmt.Errorf("invalid %s '%s': use a duration such as 60s, or 0s to always probe", key, value)
+		}
+	}
+	*field = value
+	return nil
+}
+
+// Missing returns the keys among required that have no value.
+func (c *Config) Missing(required ...string) []string {
+	fields := c.fields()
+	var missing []string
+	for _, key := range required {
+		if f, ok := fields[key]; ok && *f == "" {
+			missing = append(missing, key)
+		}
+	}
+	return missing
 }
 
 func SaveConfig(cfg *Config) error {
@@ -50,6 +173,38 @@ func SaveConfig(cfg *Config) error {
 	viper.Set("gcc_toolchain_path", cfg.GccToolchain)
 	viper.Set("cmsis_pack_root", cfg.CmsisPackRoot)
 	viper.Set("signing_key_path", cfg.SigningKeyPath)
+	if cfg.ArmclangPath != "" {
+		viper.Set("armclang_path", cfg.ArmclangPath)
+	}
+	if cfg.DefaultPort != "" {
+		viper.Set("default_port", cfg.DefaultPort)
+	}
+	if cfg.FlashTimeout != "" {
+		viper.Set("flash_timeout", cfg.FlashTimeout)
+	}
+	if cfg.JLinkSerial != "" {
+		viper.Set("jlink_serial", cfg.JLinkSerial)
+	}
+	if cfg.JLinkPath != "" {
+		viper.Set("jlink_path", cfg.JLinkPath)
+	}
+	if cfg.ProbeCacheTTL != "" {
+		viper.Set("probe_cache_ttl", cfg.ProbeCacheTTL)
+	}
+	if len(cfg.PortPatterns) > 0 {
+		viper.Set("port_patterns", cfg.PortPatterns)
+	}
+	if len(cfg.PortIDs) > 0 {
+		viper.Set("port_ids", cfg.PortIDs)
+	}
+	if len(cfg.PortLabels) > 0 {
+		viper.Set("port_labels", cfg.PortLabels)
+	}
 
-	return viper.WriteConfigAs(filepath.Join(configDir, "config.yaml"))
+	path := filepath.Join(configDir, "config.yaml")
+	if err := viper.WriteConfigAs(path); err != nil {
+		return err
+	}
+	audit.Record(path, audit.OpWrite)
+	return nil
 }
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// home points HOME at a new directory holding testdata/fixture as the
+// config file (none when fixture is empty), with viper's state cleared.
+func home(t *testing.T, fixture string) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	if fixture == "" {
+		return
+	}
+	content, err := os.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, ".alif"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".alif", "config.yaml"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	required := []string{KeyAlifToolsPath, KeyCmsisToolbox, KeyGccToolchain}
+	tests := []struct {
+		fixture   string
+		notFound  bool
+		parseErr  bool
+		missing   []string
+		toolsPath string
+	}{
+		{fixture: "", notFound: true},
+		{fixture: "invalid.yaml", parseErr: true},
+		{fixture: "incomplete.yaml", missing: []string{KeyAlifToolsPath, KeyGccToolchain}},
+		{fixture: "complete.yaml", toolsPath: "/opt/alif/app-release-exec-linux"},
+	}
+	for _, tt := range tests {
+		home(t, tt.fixture)
+		cfg, err := LoadConfig()
+
+		var parseErr *ParseError
+		if got := errors.Is(err, ErrNotFound); got != tt.notFound {
+			t.Errorf("%q: errors.Is(%v, ErrNotFound) = %v, want %v", tt.fixture, err, got, tt.notFound)
+		}
+		if got := errors.As(err, &parseErr); got != tt.parseErr {
+			t.Errorf("%q: error %v is a ParseError = %v, want %v", tt.fixture, err, got, tt.parseErr)
+		}
+		if tt.parseErr && filepath.Base(parseErr.Path) != "config.yaml" {
+			t.Errorf("%q: ParseError.Path = %q", tt.fixture, parseErr.Path)
+		}
+		if tt.notFound || tt.parseErr {
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tt.fixture, err)
+			continue
+		}
+		if got := cfg.Missing(required...); !reflect.DeepEqual(got, tt.missing) {
+			t.Errorf("%q: Missing = %q, want %q", tt.fixture, got, tt.missing)
+		}
+		if cfg.AlifToolsPath != tt.toolsPath {
+			t.Errorf("%q: AlifToolsPath = %q, want %q", tt.fixture, cfg.AlifToolsPath, tt.toolsPath)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		key, value string
+		wantErr    bool
+	}{
+		{KeyAlifToolsPath, "/opt/alif", false},
+		{KeyFlashTimeout, "90s", false},
+		{KeyFlashTimeout, "0s", true},
+		{KeyFlashTimeout, "soon", true},
+		{KeyProbeCacheTTL, "0s", false},
+		{KeyProbeCacheTTL, "-1s", true},
+		{"alif_tool_path", "/opt/alif", true},
+	}
+	for _, tt := range tests {
+		var cfg Config
+		if err := cfg.Set(tt.key, tt.value); (err != nil) != tt.wantErr {
+			t.Errorf("Set(%q, %q) error = %v, want error %v", tt.key, tt.value, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"alif-cli/internal/audit"
+
+	"github.com/spf13/viper"
+)
+
+// LocalFile is the project-local toolchain file, kept in the solution's
+// .alif folder next to the signing configs.
+const LocalFile = "toolchain.yml"
+
+// Keys of toolchain.yml.
+const (
+	LocalKeyGcc          = "gcc"
+	LocalKeyCmsisToolbox = "cmsis_toolbox"
+	LocalKeyToolkit      = "toolkit"
+	LocalKeyJLinkDevice  = "jlink_device"
+)
+
+// Local is a toolchain vendored inside a repository. Paths are relative to
+// the solution root, so the file works in every checkout; an empty path
+// leaves the global setting alone.
+type Local struct {
+	// Path is the toolchain.yml the values came from.
+	Path string
+	// Root is the solution folder the paths are relative to.
+	Root         string
+	Gcc          string
+	CmsisToolbox string
+	Toolkit      string
+	// JLinkDevice is the J-Link device name used for the solution's
+	// boards instead of the one resolved from .alif/JLinkDevices.xml.
+	JLinkDevice string
+}
+
+// LocalPath returns where the toolchain.yml of the solution in root lives.
+func LocalPath(root string) string {
+	return filepath.Join(root, ".alif", LocalFile)
+}
+
+// FindUpwards returns the first of candidates, joined to start or one of
+// its parents, that exists, checking each directory up to the filesystem
+// root before moving on to its parent. It returns "" when none exists.
+func FindUpwards(start string, candidates []string) string {
+	for curr := filepath.Clean(start); ; curr = filepath.Dir(curr) {
+		for _, c := range candidates {
+			p := filepath.Join(curr, c)
+			if _, err := os.Stat(p); err == nil {
+				return p
+			}
+		}
+		if filepath.Dir(curr) == curr {
+			return ""
+		}
+	}
+}
+
+// FindLocal looks for a toolchain.yml in the solution holding dir: dir
+// itself or the nearest parent with a .csolution.yml. It returns nil when
+// there is no solution or the solution has no toolchain.yml.
+func FindLocal(dir string) (*Local, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if solutions, _ := filepath.Glob(filepath.Join(dir, "*.csolution.yml")); len(solutions) > 0 {
+			path := LocalPath(dir)
+			if _, err := os.Stat(path); err != nil {
+				return nil, nil
+			}
+			return LoadLocal(path, dir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// LoadLocal reads the toolchain.yml at path for the solution in root.
+func LoadLocal(path, root string) (*Local, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, &ParseError{Path: path, Err: err}
+	}
+	return &Local{
+		Path:         path,
+		Root:         root,
+		Gcc:          v.GetString(LocalKeyGcc),
+		CmsisToolbox: v.GetString(LocalKeyCmsisToolbox),
+		Toolkit:      v.GetString(LocalKeyToolkit),
+		JLinkDevice:  v.GetString(LocalKeyJLinkDevice),
+	}, nil
+}
+
+// localField is a toolchain.yml key, the config key it overrides and the
+// Local field holding it.
+type localField struct {
+	key, global string
+	value       *string
+}
+
+func (l *Local) fields() []localField {
+	return []localField{
+		{LocalKeyGcc, KeyGccToolchain, &l.Gcc},
+		{LocalKeyCmsisToolbox, KeyCmsisToolbox, &l.CmsisToolbox},
+		{LocalKeyToolkit, KeyAlifToolsPath, &l.Toolkit},
+	}
+}
+
+// Resolve returns the absolute path of a toolchain.yml entry. Relative
+// entries are taken from the solution root; absolute ones are kept, though
+// they tie the file to one machine.
+func (l *Local) Resolve(path string) string {
+	path = filepath.FromSlash(path)
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(l.Root, path)
+}
+
+// Apply overrides cfg with the entries of l, which win over config.yaml for
+// commands run inside the solution. Every entry must exist: a vendored
+// tool that is missing from the checkout is an error rather than a silent
+// fall back to the global toolchain. It returns the config keys it set.
+func (l *Local) Apply(cfg *Config) ([]string, error) {
+	fields := cfg.fields()
+	var applied []string
+	for _, f := range l.fields() {
+		if *f.value == "" {
+			continue
+		}
+		path := l.Resolve(*f.value)
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("%s in %s points to %s, which does not exist in this checkout", f.key, l.Path, path)
+		}
+		*fields[f.global] = path
+		applied = append(applied, f.global)
+	}
+	return applied, nil
+}
+
+// SaveLocal writes l to the toolchain.yml of its solution, storing paths
+// below the root relative to it with forward slashes.
+func SaveLocal(l *Local) error {
+	v := viper.New()
+	for _, f := range l.fields() {
+		if *f.value == "" {
+			continue
+		}
+		path := *f.value
+		if rel, err := filepath.Rel(l.Root, path); err == nil && filepath.IsLocal(rel) {
+			path = filepath.ToSlash(rel)
+		}
+		v.Set(f.key, path)
+	}
+	if l.JLinkDevice != "" {
+		v.Set(LocalKeyJLinkDevice, l.JLinkDevice)
+	}
+	path := LocalPath(l.Root)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := v.WriteConfigAs(path); err != nil {
+		return err
+	}
+	audit.Record(path, audit.OpWrite)
+	l.Path = path
+	return nil
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindUpwards(t *testing.T) {
+	// root/.alif/JLinkDevices.xml
+	// root/a/.alif/
+	// root/a/b/c/d/
+	root := t.TempDir()
+	deep := filepath.Join(root, "a", "b", "c", "d")
+	for _, dir := range []string{filepath.Join(root, ".alif"), filepath.Join(root, "a", ".alif"), deep} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	xml := filepath.Join(root, ".alif", "JLinkDevices.xml")
+	if err := os.WriteFile(xml, []byte("<DataBase/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A filesystem root, where the walk has to stop.
+	top := filepath.VolumeName(root) + string(filepath.Separator)
+
+	tests := []struct {
+		name       string
+		start      string
+		candidates []string
+		want       string
+	}{
+		{"in start", filepath.Join(root, "a"), []string{".alif"}, filepath.Join(root, "a", ".alif")},
+		{"several levels up", deep, []string{".alif"}, filepath.Join(root, "a", ".alif")},
+		{"nearest directory wins", deep, []string{filepath.Join(".alif", "JLinkDevices.xml"), ".alif"}, filepath.Join(root, "a", ".alif")},
+		{"candidates in order", root, []string{".alif", filepath.Join(".alif", "JLinkDevices.xml")}, filepath.Join(root, ".alif")},
+		{"file further up", deep, []string{filepath.Join(".alif", "JLinkDevices.xml")}, xml},
+		{"unclean start", deep + string(filepath.Separator) + ".." + string(filepath.Separator), []string{".alif"}, filepath.Join(root, "a", ".alif")},
+		{"missing", deep, []string{".alif-missing-b6f2"}, ""},
+		{"no candidates", deep, nil, ""},
+		{"filesystem root", top, []string{".alif-missing-b6f2"}, ""},
+	}
+	for _, tt := range tests {
+		if got := FindUpwards(tt.start, tt.candidates); got != tt.want {
+			t.Errorf("%s: FindUpwards(%q, %q) = %q, want %q", tt.name, tt.start, tt.candidates, got, tt.want)
+		}
+	}
+}
+
+// localSolution writes a solution with the given toolchain.yml ("" for
+// none) and a vendored tools/gcc folder, and returns its root.
+func localSolution(t *testing.T, toolchain string) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, dir := range []string{filepath.Join(root, ".alif"), filepath.Join(root, "tools", "gcc", "bin"), filepath.Join(root, "app", "src")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "app.csolution.yml"), []byte("solution:\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if toolchain != "" {
+		if err := os.WriteFile(LocalPath(root), []byte(toolchain), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestFindLocal(t *testing.T) {
+	tests := []struct {
+		name      string
+		toolchain string
+		sub       string // folder below the root FindLocal starts from
+		gcc       string // resolved gcc entry; "" when no file is found
+		errSubstr string
+	}{
+		{"solution root", "gcc: tools/gcc\n", "", "tools/gcc", ""},
+		{"inside the solution", "gcc: tools/gcc\n", "app/src", "tools/gcc", ""},
+		{"no toolchain.yml", "", "app/src", "", ""},
+		{"broken toolchain.yml", "gcc: [tools\n", "", "", "failed to parse"},
+	}
+	for _, tt := range tests {
+		root := localSolution(t, tt.toolchain)
+		local, err := FindLocal(filepath.Join(root, filepath.FromSlash(tt.sub)))
+		switch {
+		case tt.errSubstr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		case tt.errSubstr != "" && (err == nil || !strings.Contains(err.Error(), tt.errSubstr)):
+			t.Errorf("%s: error = %v, want one mentioning %q", tt.name, err, tt.errSubstr)
+			continue
+		case tt.errSubstr != "":
+			continue
+		}
+		if tt.gcc == "" {
+			if local != nil {
+				t.Errorf("%s: found %s", tt.name, local.Path)
+			}
+			continue
+		}
+		if local == nil {
+			t.Errorf("%s: no toolchain.yml found", tt.name)
+			continue
+		}
+		if local.Root != root || local.Path != LocalPath(root) {
+			t.Errorf("%s: root %s, path %s; want %s", tt.name, local.Root, local.Path, root)
+		}
+		if got, want := local.Resolve(local.Gcc), filepath.Join(root, filepath.FromSlash(tt.gcc)); got != want {
+			t.Errorf("%s: gcc resolves to %s, want %s", tt.name, got, want)
+		}
+	}
+
+	// Outside any solution there is nothing to find.
+	if local, err := FindLocal(t.TempDir()); local != nil || err != nil {
+		t.Errorf("no solution: %v, %v", local, err)
+	}
+}
+
+func TestLocalApply(t *testing.T) {
+	abs := t.TempDir()
+	global := Config{
+		AlifToolsPath: "/opt/alif/toolkit",
+		CmsisToolbox:  "/opt/cmsis-toolbox",
+		GccToolchain:  "/opt/gcc/bin",
+		DefaultPort:   "/dev/ttyACM0",
+	}
+	tests := []struct {
+		name      string
+		local     Local // Root is set to the solution
+		want      Config
+		applied   []string
+		errSubstr string
+	}{
+		{"nothing set", Local{}, global, nil, ""},
+		{"vendored gcc wins over config.yaml", Local{Gcc: "tools/gcc/bin"}, Config{
+			AlifToolsPath: "/opt/alif/toolkit", CmsisToolbox: "/opt/cmsis-toolbox", GccToolchain: "ROOT/tools/gcc/bin", DefaultPort: "/dev/ttyACM0",
+		}, []string{KeyGccToolchain}, ""},
+		{"every tool", Local{Gcc: "tools/gcc/bin", CmsisToolbox: "tools/gcc", Toolkit: "tools"}, Config{
+			AlifToolsPath: "ROOT/tools", CmsisToolbox: "ROOT/tools/gcc", GccToolchain: "ROOT/tools/gcc/bin", DefaultPort: "/dev/ttyACM0",
+		}, []string{KeyGccToolchain, KeyCmsisToolbox, KeyAlifToolsPath}, ""},
+		{"absolute path kept", Local{Toolkit: abs}, Config{
+			AlifToolsPath: abs, CmsisToolbox: "/opt/cmsis-toolbox", GccToolchain: "/opt/gcc/bin", DefaultPort: "/dev/ttyACM0",
+		}, []string{KeyAlifToolsPath}, ""},
+		{"J-Link device is no config key", Local{JLinkDevice: "AE722F80F55D5_M55_HE"}, global, nil, ""},
+		{"vendored path missing", Local{Gcc: "tools/gcc/bin", Toolkit: "tools/alif"}, global, nil, "toolkit in ROOT/.alif/toolchain.yml points to ROOT/tools/alif, which does not exist in this checkout"},
+	}
+	for _, tt := range tests {
+		root := localSolution(t, "")
+		local := tt.local
+		local.Root, local.Path = root, LocalPath(root)
+		cfg := global
+		applied, err := local.Apply(&cfg)
+		switch {
+		case tt.errSubstr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		case tt.errSubstr != "":
+			want := strings.ReplaceAll(tt.errSubstr, "ROOT/", root+string(filepath.Separator))
+			if err == nil || !strings.Contains(filepath.ToSlash(err.Error()), filepath.ToSlash(want)) {
+				t.Errorf("%s: error = %v, want one mentioning %q", tt.name, err, want)
+			}
+			continue
+		}
+		want := tt.want
+		for _, f := range []*string{&want.AlifToolsPath, &want.CmsisToolbox, &want.GccToolchain} {
+			if rel, ok := strings.CutPrefix(*f, "ROOT/"); ok {
+				*f = filepath.Join(root, filepath.FromSlash(rel))
+			}
+		}
+		if !reflect.DeepEqual(cfg, want) {
+			t.Errorf("%s: config %+v, want %+v", tt.name, cfg, want)
+		}
+		if !reflect.DeepEqual(applied, tt.applied) {
+			t.Errorf("%s: applied %q, want %q", tt.name, applied, tt.applied)
+		}
+	}
+}
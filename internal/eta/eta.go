@@ -0,0 +1,93 @@
+// Package eta predicts how long a build or flash will take from the
+// durations of earlier runs of the same operation.
+package eta
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+	"time"
+)
+
+// MaxSamples is how many recent runs are kept per key.
+const MaxSamples = 10
+
+// Sample is one successful run.
+type Sample struct {
+	Seconds float64 `json:"seconds"`
+	// Size is the artifact size in bytes, when the operation depends on it.
+	Size int64     `json:"size,omitempty"`
+	At   time.Time `json:"at"`
+}
+
+// Key identifies comparable runs: the operation, the build context or
+// target, and for size-dependent operations the power-of-two size bucket.
+func Key(op, context string, size int64) string {
+	if size <= 0 {
+		return op + ":" + context
+	}
+	return fmt.Sprintf("%s:%s:%d", op, context, bits.Len64(uint64(size)))
+}
+
+// Add appends s and drops samples beyond MaxSamples, oldest first.
+func Add(samples []Sample, s Sample) []Sample {
+	samples = append(samples, s)
+	if len(samples) > MaxSamples {
+		samples = samples[len(samples)-MaxSamples:]
+	}
+	return samples
+}
+
+// Estimate returns the expected duration of a run with the given size. It
+// is the median of the recent samples; when size and every sample's size
+// are known, the median rate is scaled to size instead.
+func Estimate(samples []Sample, size int64) (time.Duration, bool) {
+	if len(samples) > MaxSamples {
+		samples = samples[len(samples)-MaxSamples:]
+	}
+	if len(samples) == 0 {
+		return 0, false
+	}
+
+	scale := size > 0
+	values := make([]float64, len(samples))
+	for i, s := range samples {
+		if s.Size <= 0 {
+			scale = false
+		}
+		values[i] = s.Seconds
+	}
+	if scale {
+		for i, s := range samples {
+			values[i] = s.Seconds / float64(s.Size)
+		}
+	}
+
+	m := median(values)
+	if scale {
+		m *= float64(size)
+	}
+	if m <= 0 {
+		return 0, false
+	}
+	return time.Duration(m * float64(time.Second)), true
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// Format renders an estimate with the precision it deserves: whole seconds
+// under a minute, 10-second steps above.
+func Format(d time.Duration) string {
+	if d < time.Minute {
+		return d.Round(time.Second).String()
+	}
+	return d.Round(10 * time.Second).String()
+}
@@ -0,0 +1,93 @@
+package eta
+
+import (
+	"testing"
+	"time"
+)
+
+// runs builds samples from durations in seconds, all of the given size.
+func runs(size int64, seconds ...float64) []Sample {
+	var samples []Sample
+	for _, s := range seconds {
+		samples = append(samples, Sample{Seconds: s, Size: size})
+	}
+	return samples
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		op, context string
+		size        int64
+		want        string
+	}{
+		{"build", "blinky.debug+E7-HE", 0, "build:blinky.debug+E7-HE"},
+		{"flash-ISP", "AE722F80F55D5LS:M55_HE", 1, "flash-ISP:AE722F80F55D5LS:M55_HE:1"},
+		{"flash-ISP", "E7", 4096, "flash-ISP:E7:13"},
+		{"flash-ISP", "E7", 8191, "flash-ISP:E7:13"},
+		{"flash-ISP", "E7", 8192, "flash-ISP:E7:14"},
+		{"flash-ISP", "E7", -1, "flash-ISP:E7"},
+	}
+	for _, tt := range tests {
+		if got := Key(tt.op, tt.context, tt.size); got != tt.want {
+			t.Errorf("Key(%q, %q, %d) = %q, want %q", tt.op, tt.context, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestAdd(t *testing.T) {
+	var samples []Sample
+	for i := 1; i <= MaxSamples+3; i++ {
+		samples = Add(samples, Sample{Seconds: float64(i)})
+	}
+	if len(samples) != MaxSamples {
+		t.Fatalf("kept %d samples, want %d", len(samples), MaxSamples)
+	}
+	if samples[0].Seconds != 4 || samples[MaxSamples-1].Seconds != MaxSamples+3 {
+		t.Errorf("kept %v..%v, want the newest runs", samples[0].Seconds, samples[MaxSamples-1].Seconds)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []Sample
+		size    int64
+		want    time.Duration
+		ok      bool
+	}{
+		{"no history", nil, 0, 0, false},
+		{"one run", runs(0, 40), 0, 40 * time.Second, true},
+		{"odd median", runs(0, 30, 90, 40), 0, 40 * time.Second, true},
+		{"even median", runs(0, 30, 50, 40, 60), 0, 45 * time.Second, true},
+		{"outlier ignored", runs(0, 40, 41, 39, 600), 0, 40500 * time.Millisecond, true},
+		{"scaled to size", runs(1000, 10, 12, 11), 2000, 22 * time.Second, true},
+		{"unknown sample size", append(runs(1000, 10), Sample{Seconds: 30}), 2000, 20 * time.Second, true},
+		{"unknown run size", runs(1000, 10, 12, 11), 0, 11 * time.Second, true},
+		{"zero durations", runs(0, 0, 0), 0, 0, false},
+		{"only recent runs", append(runs(0, 500, 500, 500, 500, 500), runs(0, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10)...), 0, 10 * time.Second, true},
+	}
+	for _, tt := range tests {
+		got, ok := Estimate(tt.samples, tt.size)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: Estimate = %v, %v; want %v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{1400 * time.Millisecond, "1s"},
+		{42600 * time.Millisecond, "43s"},
+		{74 * time.Second, "1m10s"},
+		{76 * time.Second, "1m20s"},
+		{10 * time.Minute, "10m0s"},
+	}
+	for _, tt := range tests {
+		if got := Format(tt.d); got != tt.want {
+			t.Errorf("Format(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Package factory runs manufacturing image sets described by a manifest.
+package factory
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+)
+
+// ManifestFile is the default manifest location inside .alif.
+const ManifestFile = "factory.yaml"
+
+// Step types.
+const (
+	StepImage = "image"
+	StepRaw   = "raw"
+	StepData  = "data"
+)
+
+// Step is one flash operation.
+type Step struct {
+	Type string `mapstructure:"type"`
+	Name string `mapstructure:"name"`
+	// Project selects the built context whose image and TOC are flashed
+	// (image steps).
+	Project string `mapstructure:"project"`
+	// File is written as-is at Address (raw steps). Relative paths are
+	// resolved against the manifest's directory.
+	File    string `mapstructure:"file"`
+	Address string `mapstructure:"address"`
+	// Template is rendered per board and written at Address (data steps).
+	// Available fields: {{.Serial}}, {{.Date}}, {{.Time}}, {{.Operator}} and
+	// {{index .Values "key"}} for --set key=value.
+	Template string `mapstructure:"template"`
+	// Size pads the rendered data block with 0xFF to a fixed length.
+	Size int `mapstructure:"size"`
+}
+
+// Label names the step for reports.
+func (s Step) Label(index int) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return fmt.Sprintf("%d:%s", index+1, s.Type)
+}
+
+// Manifest is the content of .alif/factory.yaml:
+//
+//	name: dk-production
+//	steps:
+//	  - type: image
+//	    project: blinky
+//	  - type: raw
+//	    file: provisioning.bin
+//	    address: 0x80500000
+//	  - type: data
+//	    address: 0x80570000
+//	    size: 64
+//	    template: "SN={{.Serial}};DATE={{.Date}};LOT={{index .Values \"lot\"}}"
+type Manifest struct {
+	Name  string `mapstructure:"name"`
+	Steps []Step `mapstructure:"steps"`
+	// Dir is the directory the manifest was loaded from.
+	Dir string `mapstructure:"-"`
+}
+
+// LoadManifest reads and validates a manifest.
+func LoadManifest(path string) (*Manifest, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %w", path, err)
+	}
+	var m Manifest
+	if err := v.Unmarshal(&m); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	m.Dir = filepath.Dir(path)
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// Validate checks that every step has the fields its type needs.
+func (m *Manifest) Validate() error {
+	if len(m.Steps) == 0 {
+		return fmt.Errorf("no steps defined")
+	}
+	for i, s := range m.Steps {
+		label := s.Label(i)
+		switch s.Type {
+		case StepImage:
+		case StepRaw:
+			if s.File == "" || s.Address == "" {
+				return fmt.Errorf("step %s: raw steps need file and address", label)
+			}
+		case StepData:
+			if s.Template == "" || s.Address == "" {
+				return fmt.Errorf("step %s: data steps need template and address", label)
+			}
+		default:
+			return fmt.Errorf("step %s: unknown type '%s' (use image, raw or data)", label, s.Type)
+		}
+	}
+	return nil
+}
+
+// FilePath resolves a raw step's file relative to the manifest.
+func (m *Manifest) FilePath(s Step) string {
+	if filepath.IsAbs(s.File) {
+		return s.File
+	}
+	return filepath.Join(m.Dir, s.File)
+}
@@ -0,0 +1,71 @@
+package factory
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadManifest(t *testing.T) {
+	path := filepath.Join("testdata", "factory.yaml")
+	m, err := LoadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "dk-production" || len(m.Steps) != 3 || m.Dir != "testdata" {
+		t.Fatalf("manifest = %+v", m)
+	}
+	if s := m.Steps[0]; s.Type != StepImage || s.Project != "blinky" || s.Label(0) != "1:image" {
+		t.Errorf("image step = %+v (%s)", s, s.Label(0))
+	}
+	// An unquoted YAML hex number arrives in decimal; parseAddress takes both.
+	raw := m.Steps[1]
+	if addr, err := parseAddress(raw.Address); err != nil || addr != 0x80500000 || raw.Label(1) != "provisioning" {
+		t.Errorf("raw step = %+v: address 0x%x, %v", raw, addr, err)
+	}
+	if got := m.FilePath(raw); got != filepath.Join("testdata", "provisioning.bin") {
+		t.Errorf("FilePath = %q", got)
+	}
+	if data := m.Steps[2]; data.Address != "0x80570000" || data.Size != 64 || !strings.Contains(data.Template, "{{.Serial}}") {
+		t.Errorf("data step = %+v", data)
+	}
+}
+
+func TestLoadManifestInvalid(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "factory.yaml")
+	if err := os.WriteFile(path, []byte("name: x\nsteps:\n  - type: flash\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadManifest(path); err == nil || !strings.Contains(err.Error(), "unknown type 'flash'") {
+		t.Errorf("error = %v", err)
+	}
+	if _, err := LoadManifest(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("missing manifest: no error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		steps     []Step
+		errSubstr string
+	}{
+		{"image only", []Step{{Type: StepImage}}, ""},
+		{"no steps", nil, "no steps"},
+		{"raw without address", []Step{{Type: StepRaw, File: "p.bin"}}, "raw steps need file and address"},
+		{"raw without file", []Step{{Type: StepRaw, Address: "0x80500000"}}, "raw steps need file and address"},
+		{"data without template", []Step{{Type: StepData, Address: "0x80570000"}}, "data steps need template and address"},
+		{"unknown type", []Step{{Type: StepImage}, {Type: "erase", Name: "wipe"}}, "step wipe: unknown type 'erase'"},
+	}
+	for _, tt := range tests {
+		err := (&Manifest{Steps: tt.steps}).Validate()
+		switch {
+		case tt.errSubstr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.errSubstr != "" && (err == nil || !strings.Contains(err.Error(), tt.errSubstr)):
+			t.Errorf("%s: error = %v, want one mentioning %q", tt.name, err, tt.errSubstr)
+		}
+	}
+}
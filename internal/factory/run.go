@@ -0,0 +1,223 @@
+package factory
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"alif-cli/internal/audit"
+)
+
+// HistoryFile collects one JSON record per programmed board inside .alif.
+const HistoryFile = "factory_history.jsonl"
+
+// Vars are the per-board values available to data templates.
+type Vars struct {
+	Serial   string
+	Operator string
+	Date     string
+	Time     string
+	Values   map[string]string
+}
+
+// NewVars fills in the date and time for now.
+func NewVars(serial, operator string, values map[string]string, now time.Time) Vars {
+	return Vars{
+		Serial:   serial,
+		Operator: operator,
+		Date:     now.Format("2006-01-02"),
+		Time:     now.Format("15:04:05"),
+		Values:   values,
+	}
+}
+
+// RenderData renders a data step. The result is padded with 0xFF to
+// step.Size when set; output longer than Size is an error.
+func RenderData(step Step, vars Vars) ([]byte, error) {
+	tmpl, err := template.New(step.Name).Option("missingkey=error").Parse(step.Template)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	data := buf.Bytes()
+	if step.Size > 0 {
+		if len(data) > step.Size {
+			return nil, fmt.Errorf("rendered data is %d bytes, larger than size %d", len(data), step.Size)
+		}
+		data = append(data, bytes.Repeat([]byte{0xFF}, step.Size-len(data))...)
+	}
+	return data, nil
+}
+
+// Operations performs the hardware side of each step. The CLI implements it
+// with the flasher; tests can substitute a fake.
+type Operations interface {
+	FlashImage(step Step) error
+	WriteRaw(path string, addr uint64) error
+	// VerifyRaw reads back a region and compares it with path.
+	VerifyRaw(path string, addr uint64) error
+	// CanVerify reports whether VerifyRaw is available (JTAG only).
+	CanVerify() bool
+}
+
+// StepResult records one executed step.
+type StepResult struct {
+	Step    string `json:"step"`
+	Type    string `json:"type"`
+	Address string `json:"address,omitempty"`
+	SHA256  string `json:"sha256,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Record is the per-board result appended to the history.
+type Record struct {
+	Manifest string            `json:"manifest"`
+	Serial   string            `json:"serial"`
+	Operator string            `json:"operator"`
+	Values   map[string]string `json:"values,omitempty"`
+	Start    time.Time         `json:"start"`
+	Duration string            `json:"duration"`
+	Steps    []StepResult      `json:"steps"`
+	Verified bool              `json:"verified"`
+	OK       bool              `json:"ok"`
+}
+
+// written is a region programmed by a raw or data step, kept for the
+// verification pass.
+type written struct {
+	index int
+	path  string
+	addr  uint64
+}
+
+// Run executes every step in order, then reads back raw and data regions.
+// It stops at the first failure; the returned record is always complete
+// enough to be appended to the history.
+func Run(m *Manifest, ops Operations, vars Vars, workDir string) (*Record, error) {
+	rec := &Record{
+		Manifest: m.Name,
+		Serial:   vars.Serial,
+		Operator: vars.Operator,
+		Values:   vars.Values,
+		Start:    time.Now(),
+	}
+	defer func() { rec.Duration = time.Since(rec.Start).Round(time.Millisecond).String() }()
+
+	var regions []written
+	for i, s := range m.Steps {
+		res := StepResult{Step: s.Label(i), Type: s.Type, Address: s.Address}
+		err := func() error {
+			switch s.Type {
+			case StepImage:
+				return ops.FlashImage(s)
+			case StepRaw, StepData:
+				addr, err := parseAddress(s.Address)
+				if err != nil {
+					return err
+				}
+				res.Address = fmt.Sprintf("0x%08X", addr)
+				path := m.FilePath(s)
+				if s.Type == StepData {
+					data, err := RenderData(s, vars)
+					if err != nil {
+						return err
+					}
+					path = filepath.Join(workDir, fmt.Sprintf("factory-data-%d.bin", i+1))
+					if err := audit.WriteFile(path, data, 0644); err != nil {
+						return err
+					}
+				}
+				sum, err := fileSHA256(path)
+				if err != nil {
+					return err
+				}
+				res.SHA256 = sum
+				if err := ops.WriteRaw(path, addr); err != nil {
+					return err
+				}
+				regions = append(regions, written{index: i, path: path, addr: addr})
+			}
+			return nil
+		}()
+		if err != nil {
+			res.Error = err.Error()
+			rec.Steps = append(rec.Steps, res)
+			return rec, fmt.Errorf("step %s: %w", res.Step, err)
+		}
+		rec.Steps = append(rec.Steps, res)
+	}
+
+	if ops.CanVerify() {
+		for _, w := range regions {
+			if err := ops.VerifyRaw(w.path, w.addr); err != nil {
+				rec.Steps[w.index].Error = "verify: " + err.Error()
+				return rec, fmt.Errorf("verification of step %s failed: %w", rec.Steps[w.index].Step, err)
+			}
+		}
+		rec.Verified = true
+	}
+
+	rec.OK = true
+	return rec, nil
+}
+
+// AppendHistory appends rec as one JSON line to <alifDir>/factory_history.jsonl.
+func AppendHistory(alifDir string, rec *Record) error {
+	if err := os.MkdirAll(alifDir, 0755); err != nil {
+		return err
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	f, err := audit.OpenAppend(filepath.Join(alifDir, HistoryFile))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// ParseValues turns key=value pairs (from --set) into a map.
+func ParseValues(pairs []string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, p := range pairs {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid value '%s' (use key=value)", p)
+		}
+		values[k] = v
+	}
+	return values, nil
+}
+
+// parseAddress accepts hex (0x...) and, because unquoted YAML hex numbers
+// arrive as integers, decimal addresses.
+func parseAddress(s string) (uint64, error) {
+	v, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address '%s'", s)
+	}
+	return v, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
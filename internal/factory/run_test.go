@@ -0,0 +1,248 @@
+package factory
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeOps records the operations Run asks for and fails the ones named in
+// fail ("image", "write@0x...", "verify@0x...").
+type fakeOps struct {
+	calls  []string
+	data   map[uint64][]byte
+	fail   map[string]bool
+	verify bool
+}
+
+func newFakeOps(verify bool, fail ...string) *fakeOps {
+	ops := &fakeOps{data: make(map[uint64][]byte), fail: make(map[string]bool), verify: verify}
+	for _, f := range fail {
+		ops.fail[f] = true
+	}
+	return ops
+}
+
+func (o *fakeOps) do(call string) error {
+	o.calls = append(o.calls, call)
+	if o.fail[call] {
+		return errors.New(call + " failed")
+	}
+	return nil
+}
+
+func (o *fakeOps) FlashImage(step Step) error { return o.do("image") }
+
+func (o *fakeOps) WriteRaw(path string, addr uint64) error {
+	if err := o.do(fmt.Sprintf("write@0x%X", addr)); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	o.data[addr] = data
+	return err
+}
+
+func (o *fakeOps) VerifyRaw(path string, addr uint64) error {
+	return o.do(fmt.Sprintf("verify@0x%X", addr))
+}
+
+func (o *fakeOps) CanVerify() bool { return o.verify }
+
+func TestRenderData(t *testing.T) {
+	vars := NewVars("000900123456", "jd", map[string]string{"lot": "L42"}, time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC))
+	tests := []struct {
+		name      string
+		step      Step
+		want      string
+		errSubstr string
+	}{
+		{"fields", Step{Template: "SN={{.Serial}};OP={{.Operator}};AT={{.Date}}T{{.Time}}"}, "SN=000900123456;OP=jd;AT=2026-03-04T05:06:07", ""},
+		{"values", Step{Template: `LOT={{index .Values "lot"}}`}, "LOT=L42", ""},
+		{"padded", Step{Template: "SN={{.Serial}}", Size: 20}, "SN=000900123456\xff\xff\xff\xff\xff", ""},
+		{"exact size", Step{Template: "SN={{.Serial}}", Size: 15}, "SN=000900123456", ""},
+		{"too large", Step{Template: "SN={{.Serial}}", Size: 8}, "", "larger than size 8"},
+		{"missing value", Step{Template: `{{.Values.batch}}`}, "", "failed to render"},
+		{"bad template", Step{Template: "{{.Serial"}, "", "invalid template"},
+	}
+	for _, tt := range tests {
+		got, err := RenderData(tt.step, vars)
+		if tt.errSubstr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errSubstr) {
+				t.Errorf("%s: error = %v, want one mentioning %q", tt.name, err, tt.errSubstr)
+			}
+			continue
+		}
+		if err != nil || string(got) != tt.want {
+			t.Errorf("%s: RenderData = %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+// factoryManifest is an image, a raw write and a data block, with the raw
+// file present in a temporary directory.
+func factoryManifest(t *testing.T) *Manifest {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "provisioning.bin"), []byte("PROV"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return &Manifest{
+		Name: "dk-production",
+		Dir:  dir,
+		Steps: []Step{
+			{Type: StepImage, Project: "blinky"},
+			{Type: StepRaw, Name: "provisioning", File: "provisioning.bin", Address: "0x80500000"},
+			{Type: StepData, Address: "2152136704", Size: 16, Template: "SN={{.Serial}}"},
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name      string
+		ops       *fakeOps
+		calls     []string
+		ok        bool
+		verified  bool
+		errSubstr string
+		failed    int // index of the step holding the error, -1 for none
+	}{
+		{
+			name:     "verified",
+			ops:      newFakeOps(true),
+			calls:    []string{"image", "write@0x80500000", "write@0x80470000", "verify@0x80500000", "verify@0x80470000"},
+			ok:       true,
+			verified: true,
+			failed:   -1,
+		},
+		{
+			name:   "no verification over ISP",
+			ops:    newFakeOps(false),
+			calls:  []string{"image", "write@0x80500000", "write@0x80470000"},
+			ok:     true,
+			failed: -1,
+		},
+		{
+			name:      "image fails",
+			ops:       newFakeOps(true, "image"),
+			calls:     []string{"image"},
+			errSubstr: "step 1:image: image failed",
+			failed:    0,
+		},
+		{
+			name:      "raw write fails",
+			ops:       newFakeOps(true, "write@0x80500000"),
+			calls:     []string{"image", "write@0x80500000"},
+			errSubstr: "step provisioning",
+			failed:    1,
+		},
+		{
+			name:      "verification fails",
+			ops:       newFakeOps(true, "verify@0x80470000"),
+			calls:     []string{"image", "write@0x80500000", "write@0x80470000", "verify@0x80500000", "verify@0x80470000"},
+			errSubstr: "verification of step 3:data failed",
+			failed:    2,
+		},
+	}
+	for _, tt := range tests {
+		m := factoryManifest(t)
+		vars := NewVars("000900123456", "jd", nil, time.Now())
+		rec, err := Run(m, tt.ops, vars, t.TempDir())
+
+		switch {
+		case tt.errSubstr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.errSubstr != "" && (err == nil || !strings.Contains(err.Error(), tt.errSubstr)):
+			t.Errorf("%s: error = %v, want one mentioning %q", tt.name, err, tt.errSubstr)
+		}
+		if !reflect.DeepEqual(tt.ops.calls, tt.calls) {
+			t.Errorf("%s: calls = %q, want %q", tt.name, tt.ops.calls, tt.calls)
+		}
+		if rec == nil {
+			t.Errorf("%s: no record", tt.name)
+			continue
+		}
+		if rec.OK != tt.ok || rec.Verified != tt.verified || rec.Serial != "000900123456" || rec.Duration == "" {
+			t.Errorf("%s: record = %+v", tt.name, rec)
+		}
+		for i, s := range rec.Steps {
+			if (s.Error != "") != (i == tt.failed) {
+				t.Errorf("%s: step %d error %q", tt.name, i, s.Error)
+			}
+		}
+	}
+}
+
+func TestRunWritesRenderedData(t *testing.T) {
+	m := factoryManifest(t)
+	ops := newFakeOps(false)
+	rec, err := Run(m, ops, NewVars("000900123456", "", nil, time.Now()), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ops.data[0x80500000]; string(got) != "PROV" {
+		t.Errorf("raw step wrote %q", got)
+	}
+	want := append([]byte("SN=000900123456"), 0xFF)
+	if got := ops.data[0x80470000]; !bytes.Equal(got, want) {
+		t.Errorf("data step wrote %q, want %q", got, want)
+	}
+	if s := rec.Steps[2]; s.Address != "0x80470000" || len(s.SHA256) != 64 {
+		t.Errorf("data step result = %+v", s)
+	}
+}
+
+func TestAppendHistory(t *testing.T) {
+	alifDir := filepath.Join(t.TempDir(), ".alif")
+	for _, serial := range []string{"A1", "A2"} {
+		if err := AppendHistory(alifDir, &Record{Manifest: "dk", Serial: serial, OK: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := os.Open(filepath.Join(alifDir, HistoryFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var serials []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		serials = append(serials, rec.Serial)
+	}
+	if strings.Join(serials, ",") != "A1,A2" {
+		t.Errorf("history serials = %v, want A1,A2", serials)
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	tests := []struct {
+		pairs   []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{nil, map[string]string{}, false},
+		{[]string{"lot=L42", "line=3"}, map[string]string{"lot": "L42", "line": "3"}, false},
+		{[]string{"note=a=b"}, map[string]string{"note": "a=b"}, false},
+		{[]string{"empty="}, map[string]string{"empty": ""}, false},
+		{[]string{"lot"}, nil, true},
+		{[]string{"=L42"}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseValues(tt.pairs)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("ParseValues(%q) = %v, %v; want %v, error %v", tt.pairs, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
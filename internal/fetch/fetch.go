@@ -0,0 +1,327 @@
+// Package fetch downloads artifacts given as http(s) URLs into a local
+// cache, so commands that take a file can take a URL instead.
+//
+// Each URL gets its own cache entry under ~/.alif/cache/downloads holding
+// the file, a .part file while downloading, and meta.json with the ETag
+// and Last-Modified the server sent. A repeat fetch asks the server
+// whether the copy is still current and reuses it when it is; an
+// interrupted download resumes from the .part file.
+package fetch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"alif-cli/internal/audit"
+	"alif-cli/internal/ui"
+)
+
+// metaFile is the per-entry metadata file.
+const metaFile = "meta.json"
+
+// IsURL reports whether s is an http or https URL rather than a path.
+func IsURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// DefaultDir returns the download cache (~/.alif/cache/downloads).
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".alif", "cache", "downloads"), nil
+}
+
+// meta records what the server said about the cached file.
+type meta struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	SHA256       string `json:"sha256,omitempty"`
+}
+
+// Fetcher downloads URLs into Dir.
+type Fetcher struct {
+	Dir string
+	// Client makes the requests; New uses one that honours HTTP(S)_PROXY
+	// and NO_PROXY.
+	Client *http.Client
+	// SHA256 is the expected checksum (--sha256). When empty, a sibling
+	// "<url>.sha256" file is used if the server has one.
+	SHA256 string
+	// Report receives progress output; New sets it to ui.Console.
+	Report ui.Reporter
+}
+
+// New returns a Fetcher using the default cache directory.
+func New() (*Fetcher, error) {
+	dir, err := DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return &Fetcher{
+		Dir: dir,
+		Client: &http.Client{
+			Transport: &http.Transport{Proxy: http.ProxyFromEnvironment},
+		},
+		Report: ui.Console,
+	}, nil
+}
+
+// entryDir returns the cache entry for rawURL.
+func (f *Fetcher) entryDir(rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	return filepath.Join(f.Dir, hex.EncodeToString(sum[:8]))
+}
+
+// fileName is the last path element of the URL, used for the cached copy
+// so tools that look at the name (e.g. config auto-detection) still work.
+func fileName(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "artifact.bin"
+	}
+	name := path.Base(u.Path)
+	if name == "." || name == "/" || name == "" {
+		return "artifact.bin"
+	}
+	return name
+}
+
+// Get returns a local path holding the content of rawURL, downloading it
+// unless the cached copy is still current.
+func (f *Fetcher) Get(rawURL string) (string, error) {
+	dir := f.entryDir(rawURL)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	dest := filepath.Join(dir, fileName(rawURL))
+	part := dest + ".part"
+
+	want, err := f.expectedSum(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	m := f.loadMeta(dir)
+	cached := false
+	if _, err := os.Stat(dest); err == nil && m.URL == rawURL {
+		cached = true
+	}
+
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	var offset int64
+	if cached {
+		if m.ETag != "" {
+			req.Header.Set("If-None-Match", m.ETag)
+		}
+		if m.LastModified != "" {
+			req.Header.Set("If-Modified-Since", m.LastModified)
+		}
+	} else if info, err := os.Stat(part); err == nil && info.Size() > 0 && m.URL == rawURL && (m.ETag != "" || m.LastModified != "") {
+		// Resume only when the server can tell us the file is unchanged.
+		offset = info.Size()
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if m.ETag != "" {
+			req.Header.Set("If-Range", m.ETag)
+		} else {
+			req.Header.Set("If-Range", m.LastModified)
+		}
+	}
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		if cached {
+			f.Report.Warn(fmt.Sprintf("Cannot reach %s (%v); using the cached copy", rawURL, err))
+			return dest, verify(dest, want)
+		}
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		f.Report.Info(fmt.Sprintf("Using cached %s", filepath.Base(dest)))
+		return dest, verify(dest, want)
+	case http.StatusOK:
+		offset = 0
+		os.Remove(part)
+	case http.StatusPartialContent:
+		if offset == 0 {
+			return "", fmt.Errorf("unexpected partial response from %s", rawURL)
+		}
+	default:
+		return "", fmt.Errorf("downloading %s: %s", rawURL, resp.Status)
+	}
+
+	m = meta{URL: rawURL, ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	if err := f.saveMeta(dir, m); err != nil {
+		return "", err
+	}
+
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+	if err := f.download(resp.Body, part, offset, total, filepath.Base(dest)); err != nil {
+		return "", err
+	}
+	if err := verify(part, want); err != nil {
+		os.Remove(part)
+		return "", err
+	}
+	if err := audit.Rename(part, dest); err != nil {
+		return "", err
+	}
+	m.SHA256 = want
+	f.saveMeta(dir, m)
+	return dest, nil
+}
+
+// download appends body to part, which already holds offset bytes.
+func (f *Fetcher) download(body io.Reader, part string, offset, total int64, name string) error {
+	out, err := audit.OpenAppend(part)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	msg := fmt.Sprintf("Downloading %s...", name)
+	if offset > 0 {
+		msg = fmt.Sprintf("Resuming %s...", name)
+	}
+	sp := f.Report.StartProgress(msg)
+	written := offset
+	buf := make([]byte, 64*1024)
+	for {
+		n, rerr := body.Read(buf)
+		if n > 0 {
+			if _, err := out.Write(buf[:n]); err != nil {
+				sp.Fail("Download failed")
+				return err
+			}
+			written += int64(n)
+			if total > 0 {
+				sp.Update(written, total)
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			sp.Fail("Download interrupted; rerun to resume")
+			return rerr
+		}
+	}
+	if total > 0 && written != total {
+		sp.Fail("Download incomplete; rerun to resume")
+		return fmt.Errorf("received %d of %d bytes", written, total)
+	}
+	sp.Succeed(fmt.Sprintf("Downloaded %s", name))
+	return nil
+}
+
+// expectedSum returns f.SHA256, or the checksum published next to rawURL
+// as "<url>.sha256", or "" when there is neither.
+func (f *Fetcher) expectedSum(rawURL string) (string, error) {
+	if f.SHA256 != "" {
+		return normalizeSum(f.SHA256)
+	}
+	client := *f.Client
+	client.Timeout = 30 * time.Second
+	resp, err := client.Get(rawURL + ".sha256")
+	if err != nil {
+		return "", nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", nil
+	}
+	// sha256sum format: "<hex>  <name>".
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return "", nil
+	}
+	sum, err := normalizeSum(fields[0])
+	if err != nil {
+		return "", fmt.Errorf("%s.sha256: %w", rawURL, err)
+	}
+	return sum, nil
+}
+
+func normalizeSum(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if b, err := hex.DecodeString(s); err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("invalid SHA-256 %q", s)
+	}
+	return s, nil
+}
+
+// verify checks the SHA-256 of file against want; an empty want passes.
+func verify(file, want string) error {
+	if want == "" {
+		return nil
+	}
+	got, err := FileSum(file)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", filepath.Base(file), want, got)
+	}
+	return nil
+}
+
+// FileSum returns the hex SHA-256 of file.
+func FileSum(file string) (string, error) {
+	in, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, in); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (f *Fetcher) loadMeta(dir string) meta {
+	var m meta
+	if data, err := os.ReadFile(filepath.Join(dir, metaFile)); err == nil {
+		if err := json.Unmarshal(data, &m); err != nil {
+			return meta{}
+		}
+	}
+	return m
+}
+
+func (f *Fetcher) saveMeta(dir string, m meta) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := audit.WriteFile(filepath.Join(dir, metaFile), data, 0644); err != nil {
+		return errors.New("cannot write download cache metadata: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package fetch
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"alif-cli/internal/ui"
+)
+
+// artifactServer serves content at /fw/blinky.bin with an ETag, and
+// optionally a sibling blinky.bin.sha256, logging every request.
+type artifactServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	content  []byte
+	etag     string
+	sumFile  string
+	requests []string
+}
+
+func newArtifactServer(t *testing.T, content []byte) *artifactServer {
+	t.Helper()
+	s := &artifactServer{content: content, etag: `"v1"`}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		entry := r.URL.Path
+		for _, h := range []string{"If-None-Match", "Range", "If-Range"} {
+			if v := r.Header.Get(h); v != "" {
+				entry += " " + h + "=" + v
+			}
+		}
+		s.requests = append(s.requests, entry)
+		switch r.URL.Path {
+		case "/fw/blinky.bin":
+			w.Header().Set("ETag", s.etag)
+			http.ServeContent(w, r, "blinky.bin", time.Time{}, bytes.NewReader(s.content))
+		case "/fw/blinky.bin.sha256":
+			if s.sumFile == "" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(s.sumFile))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// log returns and clears the requests for the artifact itself.
+func (s *artifactServer) log() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var artifact []string
+	for _, r := range s.requests {
+		if !strings.HasPrefix(r, "/fw/blinky.bin.sha256") {
+			artifact = append(artifact, r)
+		}
+	}
+	s.requests = nil
+	return artifact
+}
+
+func (s *artifactServer) set(content []byte, etag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.content, s.etag = content, etag
+}
+
+func sum(content []byte) string {
+	h := sha256.Sum256(content)
+	return hex.EncodeToString(h[:])
+}
+
+func newFetcher(t *testing.T) *Fetcher {
+	return &Fetcher{Dir: t.TempDir(), Client: http.DefaultClient, Report: ui.Silent}
+}
+
+// get fetches rawURL and checks the cached copy holds want.
+func get(t *testing.T, f *Fetcher, rawURL string, want []byte) string {
+	t.Helper()
+	path, err := f.Get(rawURL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("cached copy = %q, want %q", got, want)
+	}
+	return path
+}
+
+func TestGetCaches(t *testing.T) {
+	v1 := []byte("firmware v1")
+	srv := newArtifactServer(t, v1)
+	f := newFetcher(t)
+	rawURL := srv.URL + "/fw/blinky.bin"
+
+	path := get(t, f, rawURL, v1)
+	if filepath.Base(path) != "blinky.bin" {
+		t.Errorf("cached as %s, want the URL's file name", filepath.Base(path))
+	}
+	if log := srv.log(); strings.Join(log, "|") != "/fw/blinky.bin" {
+		t.Errorf("first fetch requests %q", log)
+	}
+
+	// A repeat fetch revalidates and keeps the copy.
+	if again := get(t, f, rawURL, v1); again != path {
+		t.Errorf("repeat fetch cached at %s, want %s", again, path)
+	}
+	if log := srv.log(); strings.Join(log, "|") != `/fw/blinky.bin If-None-Match="v1"` {
+		t.Errorf("repeat fetch requests %q", log)
+	}
+
+	// A new release replaces the copy.
+	v2 := []byte("firmware v2, a little longer")
+	srv.set(v2, `"v2"`)
+	get(t, f, rawURL, v2)
+	if log := srv.log(); strings.Join(log, "|") != `/fw/blinky.bin If-None-Match="v1"` {
+		t.Errorf("fetch after release requests %q", log)
+	}
+
+	// Without the server the cached copy is still used.
+	srv.Close()
+	get(t, f, rawURL, v2)
+}
+
+func TestGetResumes(t *testing.T) {
+	content := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	tests := []struct {
+		name    string
+		etag    string // the server's ETag when the download is resumed
+		request string
+	}{
+		{"unchanged", `"v1"`, `/fw/blinky.bin Range=bytes=10- If-Range="v1"`},
+		// If-Range fails, so the whole file is sent again.
+		{"changed meanwhile", `"v2"`, `/fw/blinky.bin Range=bytes=10- If-Range="v1"`},
+	}
+	for _, tt := range tests {
+		srv := newArtifactServer(t, content)
+		f := newFetcher(t)
+		rawURL := srv.URL + "/fw/blinky.bin"
+
+		// An interrupted download: metadata and the first ten bytes.
+		dir := f.entryDir(rawURL)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.saveMeta(dir, meta{URL: rawURL, ETag: `"v1"`}); err != nil {
+			t.Fatal(err)
+		}
+		part := filepath.Join(dir, "blinky.bin.part")
+		if err := os.WriteFile(part, content[:10], 0644); err != nil {
+			t.Fatal(err)
+		}
+		srv.set(content, tt.etag)
+
+		get(t, f, rawURL, content)
+		if log := srv.log(); strings.Join(log, "|") != tt.request {
+			t.Errorf("%s: requests %q, want %q", tt.name, log, tt.request)
+		}
+		if _, err := os.Stat(part); !os.IsNotExist(err) {
+			t.Errorf("%s: .part file left behind (%v)", tt.name, err)
+		}
+	}
+}
+
+func TestGetChecksum(t *testing.T) {
+	content := []byte("signed release")
+	good := sum(content)
+	bad := sum([]byte("something else"))
+	tests := []struct {
+		name      string
+		flag      string // --sha256
+		sumFile   string // the server's blinky.bin.sha256
+		errSubstr string
+	}{
+		{"no checksum", "", "", ""},
+		{"flag matches", strings.ToUpper(good), "", ""},
+		{"flag differs", bad, "", "checksum mismatch"},
+		{"invalid flag", "abc", "", "invalid SHA-256"},
+		{"sibling file matches", "", good + "  blinky.bin\n", ""},
+		{"sibling file differs", "", bad + "  blinky.bin\n", "checksum mismatch"},
+		{"invalid sibling file", "", "not-a-sum\n", "blinky.bin.sha256: invalid SHA-256"},
+		{"flag wins over sibling file", good, bad + "  blinky.bin\n", ""},
+	}
+	for _, tt := range tests {
+		srv := newArtifactServer(t, content)
+		srv.sumFile = tt.sumFile
+		f := newFetcher(t)
+		f.SHA256 = tt.flag
+		rawURL := srv.URL + "/fw/blinky.bin"
+
+		path, err := f.Get(rawURL)
+		switch {
+		case tt.errSubstr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.errSubstr != "" && (err == nil || !strings.Contains(err.Error(), tt.errSubstr)):
+			t.Errorf("%s: error = %v, want one mentioning %q", tt.name, err, tt.errSubstr)
+		}
+		if tt.errSubstr == "" {
+			continue
+		}
+		// A download that fails its checksum is never kept.
+		dest := filepath.Join(f.entryDir(rawURL), "blinky.bin")
+		for _, p := range []string{dest, dest + ".part"} {
+			if _, err := os.Stat(p); !os.IsNotExist(err) {
+				t.Errorf("%s: %s kept (%v), path %q", tt.name, filepath.Base(p), err, path)
+			}
+		}
+	}
+}
+
+func TestGetHTTPError(t *testing.T) {
+	srv := newArtifactServer(t, nil)
+	f := newFetcher(t)
+	if _, err := f.Get(srv.URL + "/fw/missing.bin"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Get of a missing file: error = %v, want the status", err)
+	}
+}
+
+func TestFileName(t *testing.T) {
+	tests := []struct {
+		url, want string
+	}{
+		{"https://ci.example.com/releases/1.2/blinky.bin", "blinky.bin"},
+		{"https://ci.example.com/releases/blinky.bin?token=abc", "blinky.bin"},
+		{"https://ci.example.com/", "artifact.bin"},
+		{"https://ci.example.com", "artifact.bin"},
+	}
+	for _, tt := range tests {
+		if got := fileName(tt.url); got != tt.want {
+			t.Errorf("fileName(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestIsURL(t *testing.T) {
+	for s, want := range map[string]bool{
+		"https://ci.example.com/blinky.bin": true,
+		"http://10.0.0.2:8080/blinky.bin":   true,
+		"out/blinky/release/blinky.bin":     false,
+		"ftp://ci.example.com/blinky.bin":   false,
+		`C:\build\blinky.bin`:               false,
+	} {
+		if got := IsURL(s); got != want {
+			t.Errorf("IsURL(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package flasher
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"alif-cli/internal/targets"
+)
+
+// AddressOverrides are user-supplied inputs for JTAG address resolution.
+type AddressOverrides struct {
+	// MapPath is an explicit app-package-map.txt to read addresses from.
+	MapPath string
+	// AppAddr and TOCAddr force the image and TOC load addresses.
+	AppAddr string
+	TOCAddr string
+}
+
+// AddressInputs is everything ResolveAddresses needs.
+type AddressInputs struct {
+	AddressOverrides
+	// SearchMaps are package maps tried in order when no explicit map is given.
+	SearchMaps []string
+	// Bounds, when set, is the MRAM range every address must fall in.
+	Bounds *Region
+	// Config is the target config. When no package map is found, its
+	// mramAddress stands in for the image address; ConfigName labels it as
+	// the source.
+	Config     targets.TargetConfig
+	ConfigName string
+	// TOCSize is the size of the TOC package. When no package map is found,
+	// the TOC is placed so it ends at the end of Bounds, where app-gen-toc
+	// puts it; zero assumes tocReserve.
+	TOCSize uint64
+}
+
+// AddressPlan holds the resolved load addresses and where each came from.
+type AddressPlan struct {
+	App       string
+	TOC       string
+	AppSource string
+	TOCSource string
+}
+
+// ResolveAddresses determines the image and TOC addresses. Explicit
+// addresses win, then an explicit map, then the first existing search map.
+// An explicit map that cannot be read is an error rather than a silent
+// fallback.
+func ResolveAddresses(in AddressInputs) (*AddressPlan, error) {
+	plan := &AddressPlan{}
+
+	if in.AppAddr != "" {
+		plan.App, plan.AppSource = in.AppAddr, "--app-address"
+	}
+	if in.TOCAddr != "" {
+		plan.TOC, plan.TOCSource = in.TOCAddr, "--toc-address"
+	}
+
+	if plan.App == "" || plan.TOC == "" {
+		var content []byte
+		var mapPath string
+		if in.MapPath != "" {
+			c, err := os.ReadFile(in.MapPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read package map '%s': %w", in.MapPath, err)
+			}
+			content, mapPath = c, in.MapPath
+		} else {
+			for _, p := range in.SearchMaps {
+				if c, err := os.ReadFile(p); err == nil {
+					content, mapPath = c, p
+					break
+				}
+			}
+		}
+
+		if content == nil && in.MapPath == "" {
+			fallbackAddresses(plan, in)
+		}
+		if content == nil && (plan.App == "" || plan.TOC == "") {
+			return nil, fmt.Errorf("could not find package map file (app-package-map.txt). Please ensure the project is built correctly, or pass --map / --app-address / --toc-address")
+		}
+
+		if plan.App == "" {
+			plan.App = parseBinaryAddress(content)
+			plan.AppSource = mapPath
+			if plan.App == "" {
+				return nil, fmt.Errorf("failed to extract binary MRAM address from package map. Please check the content of %s", mapPath)
+			}
+		}
+		if plan.TOC == "" {
+			plan.TOC = parseTOCAddress(content)
+			plan.TOCSource = mapPath
+			if plan.TOC == "" {
+				return nil, fmt.Errorf("failed to extract TOC address from package map. Please check the content of %s", mapPath)
+			}
+		}
+	}
+
+	for _, a := range []struct{ name, value string }{{"image", plan.App}, {"TOC", plan.TOC}} {
+		v, err := ParseAddress(a.value)
+		if err != nil {
+			return nil, fmt.Errorf("%s address: %w", a.name, err)
+		}
+		if in.Bounds != nil && (v < in.Bounds.Start || v >= in.Bounds.End) {
+			return nil, fmt.Errorf("%s address %s is outside MRAM %s", a.name, a.value, in.Bounds)
+		}
+	}
+
+	return plan, nil
+}
+
+// fallbackAddresses fills in the addresses a missing package map would have
+// given: the image address from the target config's mramAddress and the TOC
+// address from the end of the application MRAM area.
+func fallbackAddresses(plan *AddressPlan, in AddressInputs) {
+	if plan.App == "" {
+		if addr := in.Config.GetMRAMAddress(); addr != "" {
+			plan.App, plan.AppSource = addr, in.ConfigName+" mramAddress"
+		}
+	}
+	if plan.TOC == "" && in.Bounds != nil {
+		size := in.TOCSize
+		if size == 0 {
+			size = tocReserve
+		}
+		// app-gen-toc aligns the package start to 16 bytes.
+		plan.TOC = fmt.Sprintf("0x%08X", (in.Bounds.End-size)&^0xF)
+		plan.TOCSource = "end of application MRAM"
+	}
+}
+
+// parseBinaryAddress returns the MRAM address of alif-img.bin in a package map.
+func parseBinaryAddress(content []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.Contains(line, "alif-img.bin") {
+			fields := strings.Fields(line)
+			if len(fields) > 1 {
+				return fields[0]
+			}
+		}
+	}
+	return ""
+}
+
+// parseTOCAddress returns the APP package start address from a package map.
+func parseTOCAddress(content []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.Contains(line, "APP Package Start Address:") {
+			parts := strings.Split(line, ":")
+			if len(parts) > 1 {
+				if addr := strings.TrimSpace(parts[1]); addr != "" {
+					return addr
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// resolveAddresses resolves the JTAG load addresses and echoes the result.
+func (f *Flasher) resolveAddresses(buildDir, target string) (*AddressPlan, error) {
+	plan, err := f.PlanAddresses(buildDir, target)
+	if err != nil {
+		return nil, err
+	}
+	f.Report.Item("App Address", fmt.Sprintf("%s (%s)", plan.App, filepath.Base(plan.AppSource)))
+	f.Report.Item("TOC Address", fmt.Sprintf("%s (%s)", plan.TOC, filepath.Base(plan.TOCSource)))
+	return plan, nil
+}
+
+// PlanAddresses applies the flasher's overrides to the standard map search
+// order (build dir, then toolkit) without reporting anything. Without a
+// map, the image address comes from the target config at TargetConfigPath
+// and the TOC address from the device's MRAM layout.
+func (f *Flasher) PlanAddresses(buildDir, target string) (*AddressPlan, error) {
+	in := AddressInputs{
+		AddressOverrides: f.Addresses,
+		SearchMaps: []string{
+			filepath.Join(buildDir, "app-package-map.txt"),
+			filepath.Join(f.Cfg.AlifToolsPath, "build", "app-package-map.txt"),
+		},
+	}
+	dev, err := targets.LookupDevice(f.Cfg.AlifToolsPath, target)
+	if err != nil {
+		dev, err = targets.CurrentDevice(f.Cfg.AlifToolsPath)
+	}
+	if err == nil {
+		bounds := Region{Start: dev.MRAMBase, End: dev.AppEnd()}
+		in.Bounds = &bounds
+	}
+	if f.TargetConfigPath != "" {
+		if content, _, err := targets.ReadConfig(f.TargetConfigPath); err == nil {
+			json.Unmarshal(content, &in.Config)
+			in.ConfigName = filepath.Base(f.TargetConfigPath)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(buildDir, "AppTocPackage.bin")); err == nil {
+		in.TOCSize = uint64(info.Size())
+	}
+
+	return ResolveAddresses(in)
+}
@@ -0,0 +1,116 @@
+package flasher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"alif-cli/internal/targets"
+)
+
+const packageMap = `APP Package Start Address: 0x8057F000
+0x80000000  0x00010000  alif-img.bin
+`
+
+func TestResolveAddresses(t *testing.T) {
+	dir := t.TempDir()
+	mapFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	buildMap := mapFile("build.map", packageMap)
+	ciMap := mapFile("ci.map", strings.ReplaceAll(packageMap, "0x80000000", "0x80010000"))
+	noTOC := mapFile("no-toc.map", "0x80000000  0x00010000  alif-img.bin\n")
+	missing := filepath.Join(dir, "missing.map")
+	bounds := &Region{Start: 0x80000000, End: 0x80580000}
+	config := targets.TargetConfig{"USER_APP": map[string]interface{}{"mramAddress": "0x80020000"}}
+
+	tests := []struct {
+		name      string
+		in        AddressInputs
+		app, toc  string
+		appSource string
+		tocSource string
+		errSubstr string
+	}{
+		{
+			name: "search map",
+			in:   AddressInputs{SearchMaps: []string{missing, buildMap}},
+			app:  "0x80000000", toc: "0x8057F000", appSource: buildMap, tocSource: buildMap,
+		},
+		{
+			name: "explicit map beats the search maps",
+			in:   AddressInputs{AddressOverrides: AddressOverrides{MapPath: ciMap}, SearchMaps: []string{buildMap}},
+			app:  "0x80010000", toc: "0x8057F000", appSource: ciMap, tocSource: ciMap,
+		},
+		{
+			name: "explicit addresses beat an explicit map",
+			in: AddressInputs{
+				AddressOverrides: AddressOverrides{MapPath: ciMap, AppAddr: "0x80100000", TOCAddr: "0x8057E000"},
+				SearchMaps:       []string{buildMap},
+			},
+			app: "0x80100000", toc: "0x8057E000", appSource: "--app-address", tocSource: "--toc-address",
+		},
+		{
+			name: "one explicit address, the other from the map",
+			in:   AddressInputs{AddressOverrides: AddressOverrides{AppAddr: "0x80100000"}, SearchMaps: []string{buildMap}},
+			app:  "0x80100000", toc: "0x8057F000", appSource: "--app-address", tocSource: buildMap,
+		},
+		{
+			name: "explicit addresses need no map",
+			in:   AddressInputs{AddressOverrides: AddressOverrides{AppAddr: "0x80100000", TOCAddr: "0x8057E000", MapPath: missing}},
+			app:  "0x80100000", toc: "0x8057E000", appSource: "--app-address", tocSource: "--toc-address",
+		},
+		{
+			name:      "unreadable explicit map",
+			in:        AddressInputs{AddressOverrides: AddressOverrides{MapPath: missing}, SearchMaps: []string{buildMap}, Config: config, Bounds: bounds},
+			errSubstr: "failed to read package map",
+		},
+		{
+			name: "no map: config and end of MRAM",
+			in:   AddressInputs{SearchMaps: []string{missing}, Config: config, ConfigName: "he.json", Bounds: bounds, TOCSize: 0x2a8},
+			app:  "0x80020000", toc: "0x8057FD50", appSource: "he.json mramAddress", tocSource: "end of application MRAM",
+		},
+		{
+			name:      "no map and no fallback",
+			in:        AddressInputs{SearchMaps: []string{missing}},
+			errSubstr: "could not find package map",
+		},
+		{
+			name:      "map without a TOC address",
+			in:        AddressInputs{SearchMaps: []string{noTOC}},
+			errSubstr: "failed to extract TOC address",
+		},
+		{
+			name:      "address not hexadecimal",
+			in:        AddressInputs{AddressOverrides: AddressOverrides{AppAddr: "2148532224"}, SearchMaps: []string{buildMap}},
+			errSubstr: "must be hexadecimal",
+		},
+		{
+			name:      "address outside MRAM",
+			in:        AddressInputs{AddressOverrides: AddressOverrides{TOCAddr: "0x80580000"}, SearchMaps: []string{buildMap}, Bounds: bounds},
+			errSubstr: "outside MRAM",
+		},
+	}
+	for _, tt := range tests {
+		plan, err := ResolveAddresses(tt.in)
+		if tt.errSubstr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errSubstr) {
+				t.Errorf("%s: error = %v, want one mentioning %q", tt.name, err, tt.errSubstr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		want := AddressPlan{App: tt.app, TOC: tt.toc, AppSource: tt.appSource, TOCSource: tt.tocSource}
+		if *plan != want {
+			t.Errorf("%s: plan = %+v, want %+v", tt.name, *plan, want)
+		}
+	}
+}
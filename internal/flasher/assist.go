@@ -0,0 +1,86 @@
+package flasher
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"alif-cli/internal/audit"
+	"alif-cli/internal/jlink"
+	"alif-cli/internal/ui"
+)
+
+// offerISPAssist decides whether to halt the target over J-Link after the
+// ISP handshake went unanswered. It needs a connected probe; with
+// AssistISP set it proceeds without asking, otherwise the user is asked
+// when a prompt is possible. The error is that of an unanswered prompt.
+func (f *Flasher) offerISPAssist() (bool, error) {
+	exe, err := f.jlinkExecutable()
+	if err != nil {
+		return false, nil
+	}
+	model, err := jlink.Detect(exe)
+	if err != nil || model == "" {
+		return false, nil
+	}
+	if f.AssistISP {
+		f.Report.Info(fmt.Sprintf("Halting the target over %s and retrying ISP (--assist-isp)", model))
+		return true, nil
+	}
+	if !ui.CanPrompt() {
+		f.Report.Info(fmt.Sprintf("A %s is connected: rerun with --assist-isp to halt the running application and retry", model))
+		return false, nil
+	}
+	return ui.Confirm(fmt.Sprintf("The running application may be blocking ISP. Halt it over %s and retry?", model))
+}
+
+// haltViaJLink connects to the target and halts the core, which stops the
+// application from driving the SE-UART pins.
+func (f *Flasher) haltViaJLink(device, scriptPathOverride string) error {
+	sp := f.Report.StartTask("Halting target via J-Link...")
+	if err := f.runJLinkCommands(device, scriptPathOverride, "alif-halt-*.jlink", "h"); err != nil {
+		sp.Fail("J-Link halt failed")
+		return err
+	}
+	sp.Succeed("Target halted")
+	return nil
+}
+
+// resumeViaJLink resets the target and lets it run again.
+func (f *Flasher) resumeViaJLink(device, scriptPathOverride string) error {
+	sp := f.Report.StartTask("Resetting target via J-Link...")
+	f.InvalidateDeviceIdentity("")
+	if err := f.runJLinkCommands(device, scriptPathOverride, "alif-resume-*.jlink", "r", "g"); err != nil {
+		sp.Fail("J-Link reset failed")
+		return err
+	}
+	sp.Succeed("Target reset")
+	return nil
+}
+
+// runJLinkCommands runs cmds through a temporary J-Link command file named
+// from pattern.
+func (f *Flasher) runJLinkCommands(device, scriptPathOverride, pattern string, cmds ...string) error {
+	link, err := f.jlinkSettings(scriptPathOverride)
+	if err != nil {
+		return err
+	}
+	scriptPath, err := audit.CreateTemp(pattern, jlinkCommands(link, device, cmds...))
+	if err != nil {
+		return fmt.Errorf("failed to create J-Link script: %w", err)
+	}
+	defer os.Remove(scriptPath)
+
+	cmd, err := f.jlinkCommand(scriptPathOverride, scriptPath)
+	if err != nil {
+		return err
+	}
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := f.RunTool(cmd); err != nil {
+		f.Report.Output(output.String())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package flasher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"alif-cli/internal/config"
+	"alif-cli/internal/ui"
+)
+
+// fakeJLink creates a J-Link Commander that lists an on-board probe when
+// probe is set and logs the commands of every other run to "runs" beside
+// it. Halting the core ("h") leaves a "halted" marker.
+func fakeJLink(t *testing.T, probe bool) string {
+	t.Helper()
+	dir := t.TempDir()
+	listing := ""
+	if probe {
+		listing = "echo 'J-Link[0]: Connection: USB, Serial number: 600101234, ProductName: J-Link OB-K22-Cortex-M'"
+	}
+	script := `#!/bin/sh
+dir=$(dirname "$0")
+while [ $# -gt 0 ]; do [ "$1" = -CommandFile ] && file=$2; shift; done
+if grep -q ShowEmuList "$file"; then
+  ` + listing + `
+  exit 0
+fi
+sed -e '1,/^connect$/d' -e '/^qc$/d' "$file" | tr '\n' ' ' | sed 's/ $//' >> "$dir/runs"
+echo >> "$dir/runs"
+grep -qx h "$file" && touch "$dir/halted"
+exit 0
+`
+	if err := os.WriteFile(filepath.Join(dir, "JLinkExe"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// blockedWriteMRAM is an app-write-mram that logs its arguments and gets
+// no answer from the SE until the J-Link in jlinkDir has halted the core,
+// or never when stuck is set.
+func blockedWriteMRAM(jlinkDir string, stuck bool) string {
+	check := `[ -f '` + filepath.Join(jlinkDir, "halted") + `' ] && { echo Done; exit 0; }`
+	if stuck {
+		check = ""
+	}
+	return `#!/bin/sh
+echo "$*" >> writes
+` + check + `
+echo 'Target did not respond'
+exit 1
+`
+}
+
+func TestFlashISPAssist(t *testing.T) {
+	tests := []struct {
+		name      string
+		probe     bool
+		assist    bool
+		stuck     bool
+		writes    []string
+		jlinkRuns []string
+		results   []string
+		hint      bool
+		wantErr   bool
+	}{
+		{
+			name:      "halt, retry and reset",
+			probe:     true,
+			assist:    true,
+			writes:    []string{"-p", "-p -s", "-p -s"},
+			jlinkRuns: []string{"h", "r g"},
+			results:   []string{"failed: Flash failed", "failed: Target did not respond", "ok: Flash complete!"},
+		},
+		{
+			name:      "still no answer after halting",
+			probe:     true,
+			assist:    true,
+			stuck:     true,
+			writes:    []string{"-p", "-p -s", "-p -s"},
+			jlinkRuns: []string{"h", "r g"},
+			results:   []string{"failed: Flash failed", "failed: Target did not respond", "failed: Flash failed"},
+			wantErr:   true,
+		},
+		{
+			name:    "not asked without a terminal",
+			probe:   true,
+			writes:  []string{"-p", "-p -s"},
+			results: []string{"failed: Flash failed", "failed: Target did not respond"},
+			hint:    true,
+			wantErr: true,
+		},
+		{
+			name:    "no probe attached",
+			assist:  true,
+			writes:  []string{"-p", "-p -s"},
+			results: []string{"failed: Flash failed", "failed: Target did not respond"},
+			wantErr: true,
+		},
+	}
+	ui.SetNonInteractive(true)
+	t.Cleanup(func() { ui.SetNonInteractive(false) })
+	for _, tt := range tests {
+		t.Setenv("HOME", t.TempDir())
+		jlinkDir := fakeJLink(t, tt.probe)
+		tk := scriptedToolkit(t)
+		if err := os.WriteFile(filepath.Join(tk, "app-write-mram"), []byte(blockedWriteMRAM(jlinkDir, tt.stuck)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		build := t.TempDir()
+		for _, name := range []string{"alif-img.bin", "AppTocPackage.bin"} {
+			if err := os.WriteFile(filepath.Join(build, name), []byte(name), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		port := filepath.Join(t.TempDir(), "ttyACM0")
+		if err := os.WriteFile(port, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		rec := newRecorder()
+		f := New(&config.Config{AlifToolsPath: tk, JLinkPath: filepath.Join(jlinkDir, "JLinkExe")})
+		f.Report = rec
+		f.AssistISP = tt.assist
+		err := f.Flash(filepath.Join(build, "alif-img.bin"), filepath.Join(build, "AppTocPackage.bin"),
+			Options{Method: "ISP", Port: port, Target: "AE722F80F55D5LS:M55_HE"})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+
+		log, _ := os.ReadFile(filepath.Join(tk, "writes"))
+		if got := strings.Split(strings.TrimSpace(string(log)), "\n"); strings.Join(got, "|") != strings.Join(tt.writes, "|") {
+			t.Errorf("%s: app-write-mram runs %q, want %q", tt.name, got, tt.writes)
+		}
+		var jlinkRuns []string
+		if runs, err := os.ReadFile(filepath.Join(jlinkDir, "runs")); err == nil {
+			jlinkRuns = strings.Split(strings.TrimSpace(string(runs)), "\n")
+		}
+		if strings.Join(jlinkRuns, "|") != strings.Join(tt.jlinkRuns, "|") {
+			t.Errorf("%s: J-Link runs %q, want %q", tt.name, jlinkRuns, tt.jlinkRuns)
+		}
+		// Each attempt reports its result once.
+		if strings.Join(rec.results, "|") != strings.Join(tt.results, "|") {
+			t.Errorf("%s: task results %q, want %q", tt.name, rec.results, tt.results)
+		}
+		hinted := false
+		for _, info := range rec.infos {
+			hinted = hinted || strings.Contains(info, "rerun with --assist-isp")
+		}
+		if hinted != tt.hint {
+			t.Errorf("%s: notes %q, want the --assist-isp hint: %v", tt.name, rec.infos, tt.hint)
+		}
+	}
+}
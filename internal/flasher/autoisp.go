@@ -0,0 +1,104 @@
+package flasher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Timing of the ISP request: how long reset is held, and how long the
+// SE-UART may take to print its boot banner after release.
+const (
+	ispResetHold     = 100 * time.Millisecond
+	ispBannerTimeout = 2 * time.Second
+)
+
+// ispBannerMarkers are strings of the Secure Enclave boot output on the
+// SE-UART: the SEROM version line and the [SES] processing lines.
+var ispBannerMarkers = []string{"SEROM", "[SES]", "SERAM"}
+
+// ErrNoISPBanner means the board printed no Secure Enclave banner after
+// the DTR/RTS reset, so it is not known to be listening for ISP.
+var ErrNoISPBanner = errors.New("no Secure Enclave banner on the SE-UART after reset")
+
+// EnterISPMode resets the board through the serial control lines and waits
+// for the SE-UART banner, replacing the RESET button press before an ISP
+// flash: RTS drives reset and DTR is held asserted while the Secure
+// Enclave boots, the way the DK's USB UART bridge wires them. When it
+// fails the board may still be in ISP mode, but nothing has confirmed it.
+func (f *Flasher) EnterISPMode(port string) error {
+	if f.DryRun {
+		f.Report.Item("Would reset", fmt.Sprintf("into ISP mode by toggling DTR/RTS on %s and waiting for the SE-UART banner", port))
+		return nil
+	}
+	sp := f.Report.StartTask(fmt.Sprintf("Requesting ISP mode on %s...", port))
+	f.InvalidateDeviceIdentity(port)
+	if err := f.requestISP(port); err != nil {
+		sp.Fail("Board did not enter ISP mode")
+		return err
+	}
+	sp.Succeed("Board reset into ISP mode")
+	return nil
+}
+
+// ReportManualISP prints the button sequence that puts the board into ISP
+// mode by hand, for when EnterISPMode could not.
+func (f *Flasher) ReportManualISP(port string) {
+	f.Report.Info("Put the board into ISP mode by hand, then flash again:")
+	f.Report.Info(fmt.Sprintf("  1. Close any terminal or monitor that has %s open", port))
+	f.Report.Info("  2. Check that the SE-UART (not an application UART) is connected to this port")
+	f.Report.Info("  3. Press and release RESET on the board (or power-cycle it)")
+	f.Report.Info("  4. Rerun the flash with --no-auto-isp")
+}
+
+// ispBaud is the SE-UART speed written to isp_config_data.cfg.
+func (f *Flasher) ispBaud() int {
+	if f.Baud != 0 {
+		return f.Baud
+	}
+	return DefaultISPBaud
+}
+
+// requestISP holds the board in reset through RTS with DTR asserted,
+// releases it and reads the port until a banner marker appears or
+// ispBannerTimeout passes. DTR is released before returning.
+func (f *Flasher) requestISP(port string) error {
+	p, closePort, err := f.openPort(port, f.ispBaud())
+	if err != nil {
+		return err
+	}
+	defer closePort()
+	if err := p.SetDTR(true); err != nil {
+		return err
+	}
+	defer p.SetDTR(false)
+	if err := p.SetRTS(true); err != nil {
+		return err
+	}
+	time.Sleep(ispResetHold)
+	p.ResetInputBuffer()
+	if err := p.SetRTS(false); err != nil {
+		return err
+	}
+
+	if err := p.SetReadTimeout(100 * time.Millisecond); err != nil {
+		return err
+	}
+	var seen strings.Builder
+	buf := make([]byte, 256)
+	deadline := time.Now().Add(ispBannerTimeout)
+	for time.Now().Before(deadline) {
+		n, err := p.Read(buf)
+		if err != nil {
+			return err
+		}
+		seen.Write(buf[:n])
+		for _, marker := range ispBannerMarkers {
+			if strings.Contains(seen.String(), marker) {
+				return nil
+			}
+		}
+	}
+	return ErrNoISPBanner
+}
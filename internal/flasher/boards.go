@@ -0,0 +1,87 @@
+package flasher
+
+import (
+	"fmt"
+	"runtime"
+
+	"alif-cli/internal/toolkit"
+	"alif-cli/internal/ui"
+)
+
+// Board is one connected board of a batch (flash --all-boards).
+type Board struct {
+	Port string
+	// Serial is the USB serial number; empty when the adapter has none.
+	Serial string
+}
+
+// Name identifies the board in output: its serial number, or else its
+// port.
+func (b Board) Name() string {
+	if b.Serial != "" {
+		return b.Serial
+	}
+	return b.Port
+}
+
+// Boards returns every connected port that looks like a board, by the
+// same filter SelectPort auto-detects with. Ports the quick open-test
+// finds busy or unresponsive are left out with a warning unless NoProbe
+// is set. On Linux each port is given as its /dev/serial/by-id link unless
+// NoStablePath is set.
+func (f *Flasher) Boards() ([]Board, error) {
+	candidates, _, err := f.CandidatePorts()
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no connected board found. %s", noBoardCauses(runtime.GOOS))
+	}
+	var health map[string]PortHealth
+	if !f.NoProbe {
+		health = ProbePorts(candidates, openAndClose, probeDeadline)
+	}
+	var boards []Board
+	for _, p := range candidates {
+		if h, ok := health[p.Name]; ok && h != PortHealthy {
+			f.Report.Warn(fmt.Sprintf("Skipping %s: %s", p.Name, h))
+			continue
+		}
+		port := p.Name
+		if !f.NoStablePath && runtime.GOOS == "linux" {
+			if link := StablePath(port, SerialByIDDir); link != "" {
+				port = link
+			}
+		}
+		boards = append(boards, Board{Port: port, Serial: p.SerialNumber})
+	}
+	if len(boards) == 0 {
+		return nil, fmt.Errorf("none of the %d board port(s) could be opened", len(candidates))
+	}
+	return boards, nil
+}
+
+// NewToolkitOverlay makes dir a private view of the Security Toolkit at
+// toolkit, so several boards can be flashed at once (see
+// toolkit.NewOverlay): the files the tools write, such as global-cfg.db,
+// isp_config_data.cfg and build/, are copied per board and only read-only
+// content is linked. Windows only allows the links in Developer Mode or
+// with administrator rights.
+func NewToolkitOverlay(toolkitPath, dir string) error {
+	return toolkit.NewOverlay(toolkitPath, dir)
+}
+
+// ForBoard returns a copy of f that flashes b through the toolkit overlay
+// at toolkit (see NewToolkitOverlay) and reports through r.
+func (f *Flasher) ForBoard(b Board, toolkit string, r ui.Reporter) *Flasher {
+	g := *f
+	cfg := *f.Cfg
+	cfg.AlifToolsPath = toolkit
+	g.Cfg = &cfg
+	g.Report = r
+	g.Port = b.Port
+	g.Serial = ""
+	g.selectedPort = b.Port
+	g.bridge = nil
+	return &g
+}
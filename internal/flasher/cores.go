@@ -0,0 +1,153 @@
+package flasher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"alif-cli/internal/ui"
+)
+
+// CoreImage is one core's signed image for FlashCores.
+type CoreImage struct {
+	// Core is the cpu_id the image runs on, e.g. "M55_HE".
+	Core string
+	Path string
+}
+
+// FlashCores writes the images of several cores and the TOC package that
+// covers them in a single app-write-mram run over ISP. app-write-mram
+// writes the package one file at a time; each file is shown as its own
+// progress stage, named by matching its size against the images and TOC.
+// erase is the MRAM area to erase first (EraseApp or EraseAll), or "".
+func (f *Flasher) FlashCores(images []CoreImage, tocPath, port, target string, noSwitch, verbose bool, erase string) error {
+	f.beginDryRun()
+	f.Report.Item("Method", "ISP")
+
+	imagesDir := filepath.Join(f.Cfg.AlifToolsPath, "build", "images")
+	if err := f.mkdirAll(imagesDir); err != nil {
+		return err
+	}
+	var stages []writeStage
+	for _, img := range images {
+		info, err := os.Stat(img.Path)
+		if err != nil {
+			return fmt.Errorf("%s image not found: %w", img.Core, err)
+		}
+		stages = append(stages, writeStage{label: img.Core + " image", size: info.Size()})
+		for _, suffix := range []string{"", ".sign", ".crt"} {
+			src := img.Path + suffix
+			dst := filepath.Join(imagesDir, filepath.Base(img.Path)+suffix)
+			if _, err := os.Stat(src); err == nil && !samePath(src, dst) {
+				if err := f.copyFile(src, dst); err != nil {
+					return fmt.Errorf("failed to stage %s: %w", filepath.Base(src), err)
+				}
+			}
+		}
+	}
+	if info, err := os.Stat(tocPath); err == nil {
+		stages = append(stages, writeStage{label: "TOC", size: info.Size()})
+	}
+	if err := f.stageTOC(tocPath); err != nil {
+		return err
+	}
+
+	if err := f.UpdateISPConfig(port); err != nil {
+		return fmt.Errorf("failed to update ISP config: %w", err)
+	}
+	f.InvalidateDeviceIdentity(port)
+	noSwitch = noSwitch || f.FixedSpeed()
+	if erase != "" {
+		if err := f.EraseAreaViaISP(erase, verbose); err != nil {
+			f.Report.Warn(fmt.Sprintf("Automatic erase failed: %v", err))
+		}
+	}
+
+	progress := newStageProgress(f.Report, stages)
+	output, err := f.writeMRAM(noSwitch, verbose, nil, progress.update)
+	var timedOut *TimeoutError
+	stopped := errors.As(err, &timedOut) || errors.Is(err, ErrInterrupted)
+	if err != nil && !stopped && !noSwitch && !f.NoRetry {
+		if msg := RetryableISPError(output); msg != "" {
+			progress.finish(err)
+			f.Report.Warn(fmt.Sprintf("app-write-mram reported \"%s\"; retrying once without baud rate switching (--slow)", msg))
+			progress = newStageProgress(f.Report, stages)
+			output, err = f.writeMRAM(true, verbose, nil, progress.update)
+		}
+	}
+	progress.finish(err)
+	if err != nil {
+		f.Report.Output(output)
+		f.ReportTimeout(err)
+		return err
+	}
+	f.Report.Success(fmt.Sprintf("Flashed %d cores on %s", len(images), target))
+	f.ResetAfterISP(port)
+	return nil
+}
+
+// writeStage is one file of the package app-write-mram writes.
+type writeStage struct {
+	label string
+	size  int64
+}
+
+// stageProgress turns the progress app-write-mram reports for each file in
+// turn into one progress stage per file. A new file is recognised by its
+// size changing or its byte count starting over.
+type stageProgress struct {
+	report  ui.Reporter
+	stages  []writeStage
+	current ui.Progress
+	label   string
+	total   int64
+	written int64
+	count   int
+}
+
+// newStageProgress shows a connecting stage until the first file starts.
+func newStageProgress(report ui.Reporter, stages []writeStage) *stageProgress {
+	return &stageProgress{report: report, stages: stages, current: report.StartProgress("Connecting to target...")}
+}
+
+func (p *stageProgress) update(current, total int64) {
+	if p.count == 0 || total != p.total || current < p.written {
+		if p.count == 0 {
+			p.current.Succeed("Connected")
+		} else {
+			p.current.Succeed(p.label + " written")
+		}
+		p.count++
+		p.label = p.stageLabel(total)
+		p.total = total
+		p.current = p.report.StartProgress(fmt.Sprintf("Writing %s...", p.label))
+	}
+	p.written = current
+	p.current.Update(current, total)
+}
+
+// stageLabel names the file of the given size. app-write-mram may pad a
+// file to a 16-byte boundary.
+func (p *stageProgress) stageLabel(total int64) string {
+	for _, s := range p.stages {
+		if s.size == total || (s.size+15)/16*16 == total {
+			return s.label
+		}
+	}
+	return fmt.Sprintf("file %d", p.count)
+}
+
+// finish closes the current stage.
+func (p *stageProgress) finish(err error) {
+	switch {
+	case p.count == 0 && err != nil:
+		p.current.Fail("Flash failed")
+	case p.count == 0:
+		p.current.Succeed("Flash complete!")
+	case err != nil:
+		p.current.Fail(p.label + " failed")
+	default:
+		p.current.Succeed(p.label + " written")
+	}
+}
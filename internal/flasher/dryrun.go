@@ -0,0 +1,86 @@
+package flasher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"alif-cli/internal/audit"
+	"alif-cli/internal/ui"
+)
+
+// beginDryRun switches the report to describe steps instead of animating
+// them when DryRun is set, so no "Flash complete!" is claimed for a run
+// that wrote nothing.
+func (f *Flasher) beginDryRun() {
+	if _, ok := f.Report.(dryRunReporter); f.DryRun && !ok {
+		f.Report = dryRunReporter{f.Report}
+	}
+}
+
+// copyFile stages src at dst, or with DryRun only says it would.
+func (f *Flasher) copyFile(src, dst string) error {
+	if f.DryRun {
+		f.Report.Item("Would copy", fmt.Sprintf("%s → %s", src, dst))
+		return nil
+	}
+	return audit.CopyFile(src, dst)
+}
+
+// mkdirAll creates a staging directory unless DryRun is set.
+func (f *Flasher) mkdirAll(dir string) error {
+	if f.DryRun {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	return nil
+}
+
+// writeScript writes a generated tool script, or with DryRun prints it.
+func (f *Flasher) writeScript(path string, content []byte) error {
+	if f.DryRun {
+		f.Report.Item("Would write", path)
+		f.Report.Output(strings.TrimRight(string(content), "\n"))
+		return nil
+	}
+	return audit.WriteFile(path, content, 0644)
+}
+
+// tempScript writes a generated tool script to a new temporary file named
+// from pattern and returns its path, or with DryRun prints it under the
+// pattern's name. The caller removes the file.
+func (f *Flasher) tempScript(pattern string, content []byte) (string, error) {
+	if f.DryRun {
+		path := filepath.Join(os.TempDir(), pattern)
+		f.Report.Item("Would write", path)
+		f.Report.Output(strings.TrimRight(string(content), "\n"))
+		return path, nil
+	}
+	return audit.CreateTemp(pattern, content)
+}
+
+// dryRunReporter lists each task as a step instead of running a spinner.
+type dryRunReporter struct {
+	ui.Reporter
+}
+
+func (r dryRunReporter) StartTask(msg string) ui.Task {
+	r.Item("Step", msg)
+	return dryRunTask{}
+}
+
+func (r dryRunReporter) StartProgress(msg string) ui.Progress {
+	r.Item("Step", msg)
+	return dryRunTask{}
+}
+
+type dryRunTask struct{}
+
+func (dryRunTask) Succeed(string)            {}
+func (dryRunTask) Fail(string)               {}
+func (dryRunTask) Update(int64, int64)       {}
+func (dryRunTask) SetEstimate(time.Duration) {}
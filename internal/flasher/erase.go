@@ -0,0 +1,57 @@
+package flasher
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"alif-cli/internal/audit"
+)
+
+// EraseViaJLink fills region with 0x00 over J-Link. MRAM has no erase
+// command of its own, so a zero-filled file the size of the region is
+// loaded at its start. device overrides the J-Link device resolved for
+// target from the project's .alif/JLinkDevices.xml.
+func (f *Flasher) EraseViaJLink(region Region, target, device string) error {
+	f.InvalidateDeviceIdentity("")
+	cwd, _ := os.Getwd()
+	resolved, scriptPathOverride := f.resolveJLinkConfig(cwd, target)
+	if device == "" {
+		device = resolved
+	}
+	link, err := f.jlinkSettings(scriptPathOverride)
+	if err != nil {
+		return err
+	}
+
+	zeros, err := audit.CreateTemp("alif-erase-*.bin", make([]byte, region.End-region.Start))
+	if err != nil {
+		return fmt.Errorf("failed to create erase image: %w", err)
+	}
+	defer os.Remove(zeros)
+
+	cmds := []string{fmt.Sprintf("loadbin %s 0x%08x", zeros, region.Start), "r"}
+	scriptPath, err := audit.CreateTemp("alif-erase-*.jlink", jlinkCommands(link, device, cmds...))
+	if err != nil {
+		return fmt.Errorf("failed to create J-Link script: %w", err)
+	}
+	defer os.Remove(scriptPath)
+
+	cmd, err := f.jlinkCommand(scriptPathOverride, scriptPath)
+	if err != nil {
+		return err
+	}
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	sp := f.Report.StartTask(fmt.Sprintf("Erasing %s via J-Link...", region))
+	if err := f.RunTool(cmd); err != nil {
+		sp.Fail("Erase failed")
+		f.Report.Output(output.String())
+		f.ReportTimeout(err)
+		return fmt.Errorf("J-Link erase failed: %w", err)
+	}
+	sp.Succeed("Erased successfully")
+	return nil
+}
@@ -0,0 +1,48 @@
+package flasher
+
+import (
+	"os"
+	"time"
+
+	"alif-cli/internal/eta"
+	"alif-cli/internal/state"
+	"alif-cli/internal/ui"
+)
+
+// flashTiming tracks one flash for the duration history in the project
+// state. Flash times scale with the image, so samples are keyed by the
+// image's size bucket as well as the method and target.
+type flashTiming struct {
+	f     *Flasher
+	key   string
+	size  int64
+	start time.Time
+}
+
+// startTiming shows the historical estimate on task, if there is one.
+func (f *Flasher) startTiming(task ui.Task, method, target, binPath string) *flashTiming {
+	var size int64
+	if info, err := os.Stat(binPath); err == nil {
+		size = info.Size()
+	}
+	t := &flashTiming{f: f, key: eta.Key("flash-"+method, target, size), size: size, start: time.Now()}
+	if f.StateDir == "" {
+		return t
+	}
+	if st, err := state.Load(f.StateDir); err == nil {
+		if est, ok := st.EstimateDuration(t.key, size); ok {
+			task.SetEstimate(est)
+		}
+	}
+	return t
+}
+
+// done records the duration of a successful flash. Dry runs are not
+// recorded.
+func (t *flashTiming) done() {
+	if t.f.StateDir == "" || t.f.DryRun {
+		return
+	}
+	elapsed := time.Since(t.start)
+	state.Update(t.f.StateDir, func(st *state.State) { st.RecordDuration(t.key, elapsed, t.size) })
+}
@@ -0,0 +1,81 @@
+package flasher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"alif-cli/internal/targets"
+)
+
+// ValidateImageFit checks that image (alif-img.bin at its load address)
+// and toc (the TOC package at its own) lie inside the application MRAM
+// area app and that the image stops short of the TOC. A zero region is not
+// checked.
+func ValidateImageFit(image, toc, app Region) error {
+	for _, r := range []struct {
+		name   string
+		region Region
+	}{{"alif-img.bin", image}, {"AppTocPackage.bin", toc}} {
+		if r.region == (Region{}) || app.Contains(r.region) {
+			continue
+		}
+		size := r.region.End - r.region.Start
+		if r.region.Start < app.Start || r.region.Start >= app.End {
+			return fmt.Errorf("%s (%d bytes at 0x%08x) starts outside the application MRAM area %s", r.name, size, r.region.Start, app)
+		}
+		return fmt.Errorf("%s (%d bytes at 0x%08x) ends at 0x%08x, %d bytes past the end of the application MRAM area %s (%d bytes free from its load address)",
+			r.name, size, r.region.Start, r.region.End, r.region.End-app.End, app, app.End-r.region.Start)
+	}
+	if image != (Region{}) && toc != (Region{}) && image.Overlaps(toc) {
+		return fmt.Errorf("alif-img.bin (%d bytes at %s) overlaps the TOC at %s by %d bytes",
+			image.End-image.Start, image, toc, min(image.End, toc.End)-max(image.Start, toc.Start))
+	}
+	return nil
+}
+
+// checkFit refuses a flash whose image or TOC would not fit the MRAM of
+// the device the toolkit is set up for, before anything is written. With
+// Force it only warns. When the device or the load addresses cannot be
+// determined there is nothing to compare against and the flash goes ahead.
+func (f *Flasher) checkFit(binPath, tocPath, buildDir, target string) error {
+	dev, err := targets.CurrentDevice(f.Cfg.AlifToolsPath)
+	if err != nil {
+		f.Report.Debug(fmt.Sprintf("Skipping the MRAM size check: %v", err))
+		return nil
+	}
+	plan, err := f.PlanAddresses(buildDir, target)
+	if err != nil {
+		f.Report.Debug(fmt.Sprintf("Skipping the MRAM size check: %v", err))
+		return nil
+	}
+	regionOf := func(path, addr string) Region {
+		start, err := ParseAddress(addr)
+		info, serr := os.Stat(path)
+		if err != nil || serr != nil {
+			return Region{}
+		}
+		return Region{Start: start, End: start + uint64(info.Size())}
+	}
+	var image, toc Region
+	if f.Only != OnlyTOC {
+		image = regionOf(binPath, plan.App)
+	}
+	if f.Only != OnlyApp {
+		toc = regionOf(tocPath, plan.TOC)
+	} else if start, err := ParseAddress(plan.TOC); err == nil {
+		// The TOC already on the device still has to stay clear of the image.
+		toc = Region{Start: start, End: max(start, dev.AppEnd())}
+	}
+
+	err = ValidateImageFit(image, toc, AppRegion(dev))
+	if err == nil {
+		return nil
+	}
+	err = fmt.Errorf("%w on %s", err, dev.PartNumber)
+	if f.Force {
+		f.Report.Warn(fmt.Sprintf("%v; flashing anyway (--force)", err))
+		return nil
+	}
+	return fmt.Errorf("%w (addresses from %s; use --force to flash anyway)", err, filepath.Base(plan.AppSource))
+}
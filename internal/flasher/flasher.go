@@ -3,15 +3,23 @@ package flasher
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strconv"
+	"runtime"
 	"strings"
+	"time"
 
+	"alif-cli/internal/audit"
 	"alif-cli/internal/config"
+	"alif-cli/internal/jlink"
+	"alif-cli/internal/netserial"
+	"alif-cli/internal/state"
+	"alif-cli/internal/toolkit"
 	"alif-cli/internal/ui"
 
 	"go.bug.st/serial/enumerator"
@@ -19,157 +27,654 @@ import (
 
 type Flasher struct {
 	Cfg *config.Config
+	// Report receives progress output; New sets it to ui.Console.
+	Report ui.Reporter
+	// StateDir is the project's .alif directory. When set, RememberPort
+	// stores the port there and SelectPort prefers it on later runs.
+	StateDir string
+	// ProbeCacheTTL is how long GetDeviceIdentity reuses a probed device
+	// identity (probe_cache_ttl); zero probes every time.
+	ProbeCacheTTL time.Duration
+	// selectedPort is the port SelectPort chose last.
+	selectedPort string
+	// bridge stands in for a network port while toolkit tools run.
+	bridge *netserial.Bridge
+	// ForgetPort clears the remembered port before selection.
+	ForgetPort bool
+	// Addresses overrides JTAG load address resolution.
+	Addresses AddressOverrides
+	// Port is an explicitly requested serial port (--port). It takes
+	// precedence over ALIF_PORT and the configured default_port.
+	Port string
+	// NoStablePath keeps kernel port names (e.g. /dev/ttyACM0) instead of
+	// resolving them to /dev/serial/by-id links (--no-stable-path).
+	NoStablePath bool
+	// NoProbe skips the quick open-test of candidate ports (--no-probe).
+	NoProbe bool
+	// Baud is written to isp_config_data.cfg as the ISP baud rate (--baud).
+	// Zero leaves the file's baudrate line untouched.
+	Baud int
+	// Verify checks the flashed image and TOC after programming (--verify).
+	Verify bool
+	// TargetConfigPath is the target config (e.g. he.json) whose
+	// mramAddress gives the image address when there is no package map.
+	TargetConfigPath string
+	// EmitScript, when set, is where flashViaJLink writes its J-Link
+	// command file instead of running J-Link Commander (--emit-script).
+	EmitScript string
+	// Force flashes an image that does not fit the device's MRAM or
+	// overlaps the TOC, with a warning instead of an error (--force).
+	Force bool
+	// SkipIfSame has Flash read the start of the image and TOC back over
+	// J-Link first and skip programming when they already match
+	// (--skip-if-same).
+	SkipIfSame bool
+	// Only limits Flash to the image (OnlyApp) or the TOC (OnlyTOC);
+	// empty writes both (--app-only, --toc-only).
+	Only string
+	// JLinkSpeed and JLinkInterface override the probe profile's SWD speed
+	// (kHz) and interface (--jlink-speed, --jlink-if).
+	JLinkSpeed     int
+	JLinkInterface string
+	// NoRetry disables the automatic --slow rerun after a transient ISP
+	// failure (--no-retry).
+	NoRetry bool
+	// PyOCDTarget overrides the pyOCD target name derived from the toolkit's
+	// device (--device).
+	PyOCDTarget string
+	// JLinkDevice overrides the J-Link device resolved from
+	// .alif/JLinkDevices.xml (--device, jlink_device in toolchain.yml);
+	// JLinkDeviceSource names where it came from.
+	JLinkDevice       string
+	JLinkDeviceSource string
+	// OpenOCDConfig is the OpenOCD adapter config (--openocd-cfg); empty
+	// means .alif/openocd.cfg under StateDir.
+	OpenOCDConfig string
+	// ProbeSerial picks the J-Link by serial number (--probe-serial,
+	// jlink_serial). New sets it from the config.
+	ProbeSerial string
+	// AssistISP halts the target over J-Link and retries without asking
+	// when the ISP handshake goes unanswered (--assist-isp).
+	AssistISP bool
+	// Timeout stops app-write-mram and J-Link runs that take longer
+	// (--timeout, flash_timeout). New sets it from the config; zero
+	// disables it.
+	Timeout time.Duration
+	// DryRun prints the files Flash would stage, the config it would write
+	// and the commands it would run instead of doing any of it (--dry-run).
+	DryRun bool
+	// Reset is what happens to the target after flashing: ResetRun (the
+	// default when empty), ResetHalt or ResetNone (--reset).
+	Reset string
+
+	jlinkProfile *jlink.Profile
+	probe        *jlink.Emulator
+	jlinkExe     string
+	// Serial restricts selection to boards whose USB serial number equals or
+	// starts with this value (--serial).
+	Serial string
 }
 
+// PortEnvVar names the environment variable consulted for a default port.
+const PortEnvVar = "ALIF_PORT"
+
 func New(cfg *config.Config) *Flasher {
-	return &Flasher{Cfg: cfg}
+	f := &Flasher{Cfg: cfg, Report: ui.Console, Timeout: ToolTimeout(cfg), ProbeCacheTTL: ProbeCacheTTL(cfg)}
+	if cfg != nil {
+		f.ProbeSerial = cfg.JLinkSerial
+	}
+	return f
 }
 
+// SelectPort picks the serial port to use. Precedence is --port, then
+// --serial, then ALIF_PORT, then default_port from the config, then the
+// port remembered for the project, then auto-detection. A port from the
+// environment or config that does not exist falls back to auto-detection
+// with a warning. On Linux the result is replaced by its /dev/serial/by-id
+// link when one exists, so the path stays valid when ttyACM numbers shift.
 func (f *Flasher) SelectPort() (string, error) {
-	ports, err := enumerator.GetDetailedPortsList()
+	port, err := f.selectPort()
+	if err == nil && !f.NoStablePath && runtime.GOOS == "linux" {
+		if link := StablePath(port, SerialByIDDir); link != "" {
+			f.Report.Item("Stable Path", link)
+			port = link
+		}
+	}
+	f.selectedPort = port
+	return port, err
+}
+
+func (f *Flasher) selectPort() (string, error) {
+	if f.ForgetPort {
+		f.forgetPort()
+	}
+	if f.Port != "" {
+		f.Report.Item("Port", fmt.Sprintf("%s (from --port)", f.Port))
+		return f.Port, nil
+	}
+	if f.Serial != "" {
+		return f.detectPort()
+	}
+
+	preset := []struct{ port, source string }{
+		{os.Getenv(PortEnvVar), PortEnvVar},
+	}
+	if f.Cfg != nil {
+		preset = append(preset, struct{ port, source string }{f.Cfg.DefaultPort, "default_port in config"})
+	}
+	for _, p := range preset {
+		if p.port == "" {
+			continue
+		}
+		if portExists(p.port) {
+			f.Report.Item("Port", fmt.Sprintf("%s (from %s)", p.port, p.source))
+			return p.port, nil
+		}
+		f.Report.Warn(fmt.Sprintf("Port %s (from %s) not found, detecting ports instead", p.port, p.source))
+	}
+
+	if port := f.rememberedPort(); port != "" {
+		return port, nil
+	}
+	return f.detectPort()
+}
+
+// rememberedPort returns the connected port whose USB serial number matches
+// the one saved by RememberPort, or "" when there is none.
+func (f *Flasher) rememberedPort() string {
+	if f.StateDir == "" {
+		return ""
+	}
+	st, err := state.Load(f.StateDir)
+	if err != nil || st.Port == nil || st.Port.Serial == "" {
+		return ""
+	}
+	ports, err := listPorts()
 	if err != nil {
-		return "", fmt.Errorf("failed to list ports: %w", err)
+		return ""
 	}
+	for _, p := range ports {
+		if strings.EqualFold(p.SerialNumber, st.Port.Serial) {
+			f.Report.Item("Port", fmt.Sprintf("%s (remembered)", p.Name))
+			return p.Name
+		}
+	}
+	return ""
+}
 
-	if len(ports) == 0 {
-		return "", fmt.Errorf("no serial ports found")
+// RememberPort saves port and its USB serial number in the project state
+// so the next run selects the same board. Ports without a serial number
+// cannot be recognised again and are not saved.
+func (f *Flasher) RememberPort(port string) error {
+	if f.StateDir == "" {
+		return nil
+	}
+	serial := PortSerialNumber(port)
+	if serial == "" {
+		return nil
 	}
+	st, err := state.Load(f.StateDir)
+	if err != nil {
+		return err
+	}
+	st.Port = &state.PortMemory{Path: port, Serial: serial, SavedAt: time.Now()}
+	return st.Save()
+}
 
-	var candidates []*enumerator.PortDetails
-	// ui.Header("Select Serial Port") // Flash command usually handles header "Flash Target"
+func (f *Flasher) forgetPort() {
+	if f.StateDir == "" {
+		return
+	}
+	st, err := state.Load(f.StateDir)
+	if err != nil || st.Port == nil {
+		return
+	}
+	st.Port = nil
+	if err := st.Save(); err != nil {
+		f.Report.Warn(fmt.Sprintf("Failed to save project state: %v", err))
+		return
+	}
+	f.Report.Info("Forgot the remembered port")
+}
 
-	idx := 1
+// portExists reports whether a serial port name refers to a present device,
+// either as a path on disk or as an enumerated port name (e.g. COM3).
+func portExists(port string) bool {
+	if netserial.IsNetwork(port) {
+		return true
+	}
+	if _, err := os.Stat(port); err == nil {
+		return true
+	}
+	ports, err := portList()
+	if err != nil {
+		return false
+	}
 	for _, p := range ports {
-		name := strings.ToLower(p.Name)
-		isCandidate := false
+		if strings.EqualFold(p.Name, port) {
+			return true
+		}
+	}
+	return false
+}
+
+// detectPort enumerates serial ports, auto-selecting a single likely board
+// and prompting when there are several.
+func (f *Flasher) detectPort() (string, error) {
+	candidates, ports, err := f.CandidatePorts()
+	if err != nil {
+		return "", err
+	}
+
+	if len(ports) == 0 {
+		return "", fmt.Errorf("no serial ports found. %s", noBoardCauses(runtime.GOOS))
+	}
 
-		if strings.Contains(name, "usbmodem") || strings.Contains(name, "jlink") || strings.Contains(name, "mbed") {
-			isCandidate = true
+	// Ports exist but none looks like a board: say so before offering them,
+	// since picking an unrelated port only fails later with a timeout.
+	if len(candidates) == 0 && f.Serial == "" {
+		if err := f.confirmOtherPorts(ports); err != nil {
+			return "", err
 		}
+		candidates = ports
+	}
 
-		if isCandidate {
-			candidates = append(candidates, p)
+	if f.Serial != "" {
+		// The serial number is specific enough to search every port.
+		candidates = matchSerial(ports, f.Serial)
+		if len(candidates) == 0 {
+			var known []string
+			for _, p := range ports {
+				if p.SerialNumber != "" {
+					known = append(known, fmt.Sprintf("%s (%s)", p.SerialNumber, p.Name))
+				}
+			}
+			return "", fmt.Errorf("no serial port with USB serial number '%s'. Connected: %s", f.Serial, strings.Join(known, ", "))
+		}
+		if len(candidates) == 1 {
+			p := candidates[0]
+			f.Report.Item("Port", fmt.Sprintf("%s (serial %s)", p.Name, p.SerialNumber))
+			return p.Name, nil
 		}
-		idx++
 	}
 
-	// Fallback if no "candidate" found, show all?
-	if len(candidates) == 0 {
-		candidates = ports
+	// Probe candidates so stale or busy nodes are not auto-selected.
+	var health map[string]PortHealth
+	auto := candidates
+	if !f.NoProbe {
+		health = ProbePorts(candidates, openAndClose, probeDeadline)
+		var healthy []*enumerator.PortDetails
+		for _, p := range candidates {
+			if health[p.Name] == PortHealthy {
+				healthy = append(healthy, p)
+			}
+		}
+		if len(healthy) > 0 {
+			auto = healthy
+		}
 	}
 
-	if len(candidates) == 1 {
-		p := candidates[0].Name
-		ui.Item("Port", p) // Auto-selected
-		return p, nil
+	if len(auto) == 1 {
+		p := auto[0]
+		if h, ok := health[p.Name]; ok && h != PortHealthy {
+			f.Report.Warn(fmt.Sprintf("%s is %s", p.Name, h))
+		}
+		if p.SerialNumber != "" {
+			f.Report.Item("Port", fmt.Sprintf("%s (auto-detected, serial %s)", p.Name, p.SerialNumber))
+		} else {
+			f.Report.Item("Port", fmt.Sprintf("%s (auto-detected)", p.Name))
+		}
+		return p.Name, nil
 	}
 
-	fmt.Println("Detected Serial Ports:")
-	for i, p := range candidates {
-		fmt.Printf("[%d] %s (VID:%s PID:%s Serial:%s)\n", i+1, p.Name, p.VID, p.PID, p.SerialNumber)
+	var options []string
+	var labels map[string]string
+	if f.Cfg != nil {
+		labels = f.Cfg.PortLabels
+	}
+	stable := stablePorts(candidates, runtime.GOOS)
+	for _, p := range candidates {
+		option := describePort(p, labels, stable[p.Name])
+		if h, ok := health[p.Name]; ok && h != PortHealthy {
+			option += fmt.Sprintf(" [%s]", h)
+		}
+		options = append(options, option)
 	}
 
-	fmt.Print("Select port number: ")
-	reader := bufio.NewReader(os.Stdin)
-	input, _ := reader.ReadString('\n')
-	selection, err := strconv.Atoi(strings.TrimSpace(input))
-	if err != nil || selection < 1 || selection > len(candidates) {
-		return "", fmt.Errorf("invalid selection")
+	selection, err := ui.Select("Detected Serial Ports:", "Select port number: ", options, "Pass --port <port> or --serial <sn> (or set ALIF_PORT) to choose one.")
+	if err != nil {
+		return "", err
 	}
 
-	selectedPort := candidates[selection-1].Name
-	ui.Item("Port", selectedPort)
+	selectedPort := candidates[selection].Name
+	f.Report.Item("Port", selectedPort)
 	return selectedPort, nil
 }
 
+// noBoardCauses lists the usual reasons a connected board has no port.
+func noBoardCauses(goos string) string {
+	causes := "Check that the board is powered and connected through the DevKit's PRG USB connector"
+	if goos == "windows" {
+		causes += ", and that the SEGGER J-Link driver is installed (the port appears as 'JLink CDC UART Port' in Device Manager)"
+	}
+	return causes + "."
+}
+
+// confirmOtherPorts reports that none of ports looks like a board and asks
+// whether to choose from them anyway.
+func (f *Flasher) confirmOtherPorts(ports []*enumerator.PortDetails) error {
+	names := make([]string, len(ports))
+	for i, p := range ports {
+		names[i] = p.Name
+	}
+	summary := fmt.Sprintf("%d serial port(s) found, none look like an Alif board: %s", len(ports), strings.Join(names, ", "))
+	if !ui.CanPrompt() {
+		return fmt.Errorf("%s. %s Pass --port <port> to use one of them anyway", summary, noBoardCauses(runtime.GOOS))
+	}
+	f.Report.Warn(summary)
+	f.Report.Info(noBoardCauses(runtime.GOOS))
+	ok, err := ui.Confirm("Show all ports anyway?")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("no board port selected")
+	}
+	return nil
+}
+
+// CandidatePorts enumerates serial ports and returns those that look like a
+// board (per port_patterns / port_ids) along with every port found.
+func (f *Flasher) CandidatePorts() (candidates, all []*enumerator.PortDetails, err error) {
+	all, err = listPorts()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list ports: %w", err)
+	}
+	var patterns, ids []string
+	if f.Cfg != nil {
+		patterns, ids = f.Cfg.PortPatterns, f.Cfg.PortIDs
+	}
+	return FilterPorts(all, patterns, ids), all, nil
+}
+
+// DefaultPortPatterns identify board serial ports when port_patterns is
+// not configured.
+var DefaultPortPatterns = []string{"usbmodem", "jlink", "mbed"}
+
+// FilterPorts returns the ports that look like a board: the name contains
+// one of patterns (case-insensitive), or the USB "VID:PID" is in ids.
+// DefaultPortPatterns is used when both lists are empty.
+func FilterPorts(ports []*enumerator.PortDetails, patterns, ids []string) []*enumerator.PortDetails {
+	if len(patterns) == 0 && len(ids) == 0 {
+		patterns = DefaultPortPatterns
+	}
+
+	var candidates []*enumerator.PortDetails
+	for _, p := range ports {
+		name := strings.ToLower(p.Name)
+		match := false
+		for _, pattern := range patterns {
+			if strings.Contains(name, strings.ToLower(pattern)) {
+				match = true
+				break
+			}
+		}
+		if !match && p.IsUSB {
+			id := p.VID + ":" + p.PID
+			for _, allowed := range ids {
+				if strings.EqualFold(id, allowed) {
+					match = true
+					break
+				}
+			}
+		}
+		if match {
+			candidates = append(candidates, p)
+		}
+	}
+	return candidates
+}
+
+// matchSerial returns the ports whose USB serial number equals sn. If none
+// do, ports whose serial number starts with sn are returned instead.
+func matchSerial(ports []*enumerator.PortDetails, sn string) []*enumerator.PortDetails {
+	var exact, prefix []*enumerator.PortDetails
+	for _, p := range ports {
+		switch {
+		case strings.EqualFold(p.SerialNumber, sn):
+			exact = append(exact, p)
+		case p.SerialNumber != "" && strings.HasPrefix(strings.ToLower(p.SerialNumber), strings.ToLower(sn)):
+			prefix = append(prefix, p)
+		}
+	}
+	if len(exact) > 0 {
+		return exact
+	}
+	return prefix
+}
+
+// DefaultISPBaud is the ISP baud rate written when none is requested.
+const DefaultISPBaud = 115200
+
+// ISP baud rates accepted by ValidateBaud. The toolkit's dynamic baud
+// switching tops out at 921600.
+const (
+	MinISPBaud = 9600
+	MaxISPBaud = 921600
+)
+
+// ValidateBaud rejects baud rates the ISP tools cannot use.
+func ValidateBaud(baud int) error {
+	if baud < MinISPBaud || baud > MaxISPBaud {
+		return fmt.Errorf("unsupported baud rate %d (use %d to %d)", baud, MinISPBaud, MaxISPBaud)
+	}
+	return nil
+}
+
+// UpdateISPConfig points isp_config_data.cfg at port and, when Baud is set,
+// at that baud rate. Other lines, comments and the file's line endings are
+// kept, and the file is replaced atomically so an interrupted update never
+// leaves the toolkit with a truncated config. A network port is written as
+// the pseudo-terminal bridging it.
 func (f *Flasher) UpdateISPConfig(port string) error {
 	configPath := filepath.Join(f.Cfg.AlifToolsPath, "isp_config_data.cfg")
+	port, err := f.toolkitPort(port)
+	if err != nil {
+		return err
+	}
+	if f.DryRun {
+		setting := "comport " + port
+		if f.Baud != 0 {
+			setting += fmt.Sprintf(", baudrate %d", f.Baud)
+		}
+		f.Report.Item("Would set", fmt.Sprintf("%s in %s", setting, configPath))
+		return nil
+	}
 	content, err := os.ReadFile(configPath)
+	perm := os.FileMode(0644)
 
 	if os.IsNotExist(err) {
 		// Create default config if missing
-		defaultConfig := fmt.Sprintf("comport %s\nbaudrate 115200\n", port)
-		if err := os.WriteFile(configPath, []byte(defaultConfig), 0644); err != nil {
+		baud := f.Baud
+		if baud == 0 {
+			baud = DefaultISPBaud
+		}
+		defaultConfig := fmt.Sprintf("comport %s\nbaudrate %d\n", port, baud)
+		if err := audit.WriteFileAtomic(configPath, []byte(defaultConfig), perm); err != nil {
 			return fmt.Errorf("failed to create isp_config_data.cfg: %w", err)
 		}
 		return nil
 	} else if err != nil {
 		return fmt.Errorf("failed to read isp_config_data.cfg: %w", err)
 	}
-
-	lines := strings.Split(string(content), "\n")
-	found := false
-	for i, line := range lines {
-		if strings.HasPrefix(strings.TrimSpace(line), "comport") {
-			lines[i] = fmt.Sprintf("comport %s", port)
-			found = true
-			break
-		}
+	if info, err := os.Stat(configPath); err == nil {
+		perm = info.Mode().Perm()
 	}
 
-	if !found {
-		// Append comport if not present in existing file
-		lines = append(lines, fmt.Sprintf("comport %s", port))
+	settings := []struct{ key, value string }{{"comport", port}}
+	if f.Baud != 0 {
+		settings = append(settings, struct{ key, value string }{"baudrate", fmt.Sprintf("%d", f.Baud)})
 	}
-
-	updated := strings.Join(lines, "\n")
-	if err := os.WriteFile(configPath, []byte(updated), 0644); err != nil {
+	updated := setConfigLines(string(content), settings)
+	if err := audit.WriteFileAtomic(configPath, []byte(updated), perm); err != nil {
 		return fmt.Errorf("failed to update isp_config_data.cfg: %w", err)
 	}
 	return nil
 }
 
-func (f *Flasher) flashViaJLink(binPath, tocPath, buildDir, device, scriptPathOverride string) error {
-	ui.Info("Using J-Link for JTAG flashing...")
+// setConfigLines replaces the first "key value" line for each setting, or
+// appends one. Every other line is kept byte for byte, and each line keeps
+// its own ending; appended lines use CRLF when most of the file does.
+func setConfigLines(content string, settings []struct{ key, value string }) string {
+	lines := strings.SplitAfter(content, "\n")
+	// SplitAfter leaves an empty last element after a final newline.
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	eol := "\n"
+	if crlf := strings.Count(content, "\r\n"); crlf > 0 && crlf*2 >= strings.Count(content, "\n") {
+		eol = "\r\n"
+	}
+
+	for _, set := range settings {
+		found := false
+		for i, line := range lines {
+			body := strings.TrimRight(line, "\r\n")
+			if fields := strings.Fields(body); len(fields) > 0 && fields[0] == set.key {
+				lines[i] = fmt.Sprintf("%s %s", set.key, set.value) + line[len(body):]
+				found = true
+				break
+			}
+		}
+		if !found {
+			// A last line without a newline needs one before appending.
+			if n := len(lines); n > 0 && !strings.HasSuffix(lines[n-1], "\n") {
+				lines[n-1] += eol
+			}
+			lines = append(lines, fmt.Sprintf("%s %s", set.key, set.value)+eol)
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+func (f *Flasher) flashViaJLink(binPath, tocPath, buildDir, target, device, scriptPathOverride string) error {
+	f.Report.Info("Using J-Link for JTAG flashing...")
 
-	// Resolve addrs from map file
-	mramAddr, err := f.resolveBinaryAddress(buildDir)
+	// Resolve addrs from overrides or map file
+	plan, err := f.resolveAddresses(buildDir, target)
 	if err != nil {
 		return err
 	}
-	tocAddr, err := f.resolveTOCAddress(buildDir)
+	link, err := f.jlinkSettings(scriptPathOverride)
 	if err != nil {
 		return err
 	}
-
-	scriptPath := filepath.Join(buildDir, "flash_jlink.jlink")
-	scriptContent := fmt.Sprintf(`si SWD
-speed 4000
+	files := f.flashFiles(binPath, tocPath, plan)
+	if f.EmitScript != "" {
+		// The script is run from wherever the caller's Makefile is.
+		for i := range files {
+			if abs, err := filepath.Abs(files[i].path); err == nil {
+				files[i].path = abs
+			}
+		}
+	}
+	load, verify := "", ""
+	for _, file := range files {
+		load += fmt.Sprintf("loadbin %s %s\n", file.path, file.addr)
+		if f.Verify {
+			verify += fmt.Sprintf("verifybin %s %s\n", file.path, file.addr)
+		}
+	}
+	f.Report.Item("Reset", describeReset(f.resetMode()))
+	scriptContent := fmt.Sprintf(`si %s
+speed %d
 device %s
 connect
-loadbin %s %s
-loadbin %s %s
-r
-g
-qc
-`, device, binPath, mramAddr, tocPath, tocAddr)
-
-	if err := os.WriteFile(scriptPath, []byte(scriptContent), 0644); err != nil {
-		return fmt.Errorf("failed to create J-Link script: %w", err)
+%s%s%sqc
+`, link.Interface, link.Speed, device, load, verify, jlinkResetCommands(f.resetMode()))
+	if f.EmitScript != "" {
+		return f.emitJLinkScript([]byte(scriptContent), device, scriptPathOverride)
 	}
 
-	args := []string{"-CommandFile", scriptPath}
-	if scriptPathOverride != "" {
-		args = append([]string{"-JLinkScriptFile", scriptPathOverride}, args...)
+	scriptPath, err := f.tempScript("alif-flash-*.jlink", []byte(scriptContent))
+	if err != nil {
+		return fmt.Errorf("failed to create J-Link script: %w", err)
 	}
+	defer os.Remove(scriptPath)
 
-	cmd := exec.Command("JLinkExe", args...)
+	cmd, err := f.jlinkCommand(scriptPathOverride, scriptPath)
+	if err != nil {
+		return err
+	}
 	var output bytes.Buffer
 	cmd.Stdout = &output
 	cmd.Stderr = &output
 
-	sp := ui.StartSpinner(fmt.Sprintf("Flashing %s via J-Link...", device))
-	if err := cmd.Run(); err != nil {
+	sp := f.Report.StartTask(fmt.Sprintf("Flashing %s via J-Link...", device))
+	timing := f.startTiming(sp, "jtag", target, binPath)
+	if err := f.RunTool(cmd); err != nil {
 		sp.Fail("J-Link failed")
-		fmt.Println("\n" + output.String())
+		f.Report.Output(output.String())
 		return fmt.Errorf("J-Link flash failed: %w", err)
 	}
+	if failed := VerifyFailures(output.String()); f.Verify && len(failed) > 0 {
+		sp.Fail("Verification failed")
+		// Read the failed regions back to report where they differ.
+		for _, r := range files {
+			if !containsPath(failed, r.path) && !containsPath(failed, "") {
+				continue
+			}
+			if err := f.verifyReadback(r.path, r.addr, device, scriptPathOverride, 0); err != nil {
+				return err
+			}
+		}
+		f.Report.Output(output.String())
+		names := make([]string, len(failed))
+		for i, p := range failed {
+			names[i] = "an unidentified region"
+			if p != "" {
+				names[i] = filepath.Base(p)
+			}
+		}
+		return fmt.Errorf("verify: J-Link reported a mismatch in %s", strings.Join(names, ", "))
+	}
 	sp.Succeed("Flashed successfully via JTAG")
+	timing.done()
+	if f.Verify && !f.DryRun {
+		f.Report.Success(verifiedMessage(f.Only))
+	}
 	return nil
 }
 
+// verifiedMessage names what a successful --verify covered.
+func verifiedMessage(only string) string {
+	switch only {
+	case OnlyApp:
+		return "Image verified"
+	case OnlyTOC:
+		return "TOC verified"
+	}
+	return "Image and TOC verified"
+}
+
+// MRAM areas app-write-mram can erase.
+const (
+	EraseApp = "APP"
+	// EraseAll wipes the whole application MRAM region, including TOCs
+	// written at non-default offsets.
+	EraseAll = "ALL"
+)
+
+// EraseViaISP erases the application area over ISP.
 func (f *Flasher) EraseViaISP(verbose bool) error {
-	args := []string{"-e", "APP"}
+	return f.EraseAreaViaISP(EraseApp, verbose)
+}
+
+// EraseAreaViaISP erases area (EraseApp or EraseAll) over ISP.
+func (f *Flasher) EraseAreaViaISP(area string, verbose bool) error {
+	f.beginDryRun()
+	args := []string{"-e", area}
 	if verbose {
 		args = append(args, "-v")
 	}
@@ -180,77 +685,235 @@ func (f *Flasher) EraseViaISP(verbose bool) error {
 	cmd.Stdout = &output
 	cmd.Stderr = &output
 
-	sp := ui.StartSpinner("Erasing application area...")
-	if err := cmd.Run(); err != nil {
+	msg := "Erasing application area..."
+	if area == EraseAll {
+		msg = "Erasing all application MRAM..."
+	}
+	sp := f.Report.StartTask(msg)
+	f.InvalidateDeviceIdentity("")
+	if err := f.RunTool(cmd); err != nil {
 		sp.Fail("Erase failed")
-		if verbose {
-			fmt.Println("\n" + output.String())
+		var te *TimeoutError
+		if verbose || errors.As(err, &te) {
+			f.Report.Output(output.String())
 		}
+		f.ReportTimeout(err)
 		return err
 	}
 	sp.Succeed("Erased successfully")
 	return nil
 }
 
-func (f *Flasher) Flash(binPath, tocPath, port, target, configPath string, noSwitch bool, method string, verbose bool, doErase bool) error {
-	buildDir := filepath.Dir(binPath)
+// Options are the per-flash settings of Flash. Settings that hold for every
+// flash a Flasher makes, such as Verify, DryRun and Timeout, are Flasher
+// fields instead.
+type Options struct {
+	// Method is ISP, JTAG, PYOCD or OPENOCD (--method).
+	Method string
+	// Port is the serial port selected for the board.
+	Port string
+	// Target is the part and core, e.g. "AE722F80F55D5LS:M55_HE".
+	Target string
+	// Slow turns off ISP baud rate switching (--slow).
+	Slow bool
+	// Verbose passes -v to the toolkit tools (--verbose).
+	Verbose bool
+	// Erase is the MRAM area to erase first over ISP (EraseApp or
+	// EraseAll), or "" for none (--erase, --erase-all).
+	Erase string
+}
+
+// Flash programs the image at binPath and the TOC at tocPath as opts
+// describes. Their .sign and .crt companions are taken from next to them,
+// and the package map from the TOC's folder, so the artifacts need not be
+// in the build folder. The toolkit's staging lock is held throughout, so a
+// concurrent alif process cannot swap the staged files. With DryRun nothing
+// is staged, written or run; each step is described instead.
+func (f *Flasher) Flash(binPath, tocPath string, opts Options) error {
+	f.beginDryRun()
+	if !f.DryRun {
+		release, err := toolkit.Lock(f.Cfg.AlifToolsPath, toolkit.LockTimeout, f.Report)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+	buildDir := filepath.Dir(tocPath)
+	if err := f.checkOnlyArtifact(binPath, tocPath); err != nil {
+		return err
+	}
 
-	ui.Item("Method", method)
-	// ui.Item("Port", port) // Already printed by SelectPort? No, SelectPort called before.
-	// If caller prints header, we print items.
+	f.Report.Item("Method", opts.Method)
+	if f.Only != "" {
+		f.Report.Item("Writing", map[string]string{OnlyApp: "image only", OnlyTOC: "TOC only"}[f.Only])
+	}
+	if err := f.checkFit(binPath, tocPath, buildDir, opts.Target); err != nil {
+		return err
+	}
+	if f.SkipIfSame && !f.DryRun && f.EmitScript == "" && f.deviceUpToDate(binPath, tocPath, buildDir, opts.Target) {
+		if opts.Method == "ISP" {
+			f.ResetAfterISP(opts.Port)
+		}
+		return nil
+	}
 
 	// 1. Stage Image inside toolkit (bundled Python in app-write-mram needs files in toolkit)
 	imagesDir := filepath.Join(f.Cfg.AlifToolsPath, "build", "images")
-	_ = os.MkdirAll(imagesDir, 0755)
-	imageFiles := []string{"alif-img.bin", "alif-img.bin.sign", "alif-img.bin.crt"}
-	for _, fname := range imageFiles {
-		src := filepath.Join(buildDir, fname)
-		dst := filepath.Join(imagesDir, fname)
-		if _, err := os.Stat(src); err == nil {
-			_ = copyFile(src, dst)
+	if err := f.mkdirAll(imagesDir); err != nil {
+		return err
+	}
+	for _, suffix := range []string{"", ".sign", ".crt"} {
+		src := binPath + suffix
+		dst := filepath.Join(imagesDir, "alif-img.bin"+suffix)
+		if f.Only == OnlyTOC {
+			break
+		}
+		if _, err := os.Stat(src); err == nil && !samePath(src, dst) {
+			if err := f.copyFile(src, dst); err != nil {
+				return fmt.Errorf("failed to stage %s: %w", filepath.Base(src), err)
+			}
 		}
 	}
 
 	// 2. Stage TOC to root and build/ directory (different tools expect different locations)
-	tocFiles := []string{"AppTocPackage.bin", "AppTocPackage.bin.sign", "AppTocPackage.bin.crt"}
-	buildDestDir := filepath.Join(f.Cfg.AlifToolsPath, "build")
-	for _, fname := range tocFiles {
-		src := filepath.Join(buildDir, fname)
-		// Stage to root
-		dstRoot := filepath.Join(f.Cfg.AlifToolsPath, fname)
-		if _, err := os.Stat(src); err == nil {
-			_ = copyFile(src, dstRoot)
-		}
-		// Stage to build/
-		dstBuild := filepath.Join(buildDestDir, fname)
-		if _, err := os.Stat(src); err == nil {
-			_ = copyFile(src, dstBuild)
+	if f.Only != OnlyApp {
+		if err := f.stageTOC(tocPath); err != nil {
+			return err
 		}
 	}
 
 	// 3. Update ISP config
-	if method == "ISP" {
-		if err := f.UpdateISPConfig(port); err != nil {
+	if opts.Method == "ISP" {
+		if err := f.UpdateISPConfig(opts.Port); err != nil {
 			return fmt.Errorf("failed to update ISP config: %w", err)
 		}
+		opts.Slow = opts.Slow || f.FixedSpeed()
 
 		// 3b. Erase if requested
-		if doErase {
-			if err := f.EraseViaISP(verbose); err != nil {
+		if opts.Erase != "" {
+			if err := f.EraseAreaViaISP(opts.Erase, opts.Verbose); err != nil {
 				// We warn but continue, as the -p command might still work if erase failed
-				ui.Warn(fmt.Sprintf("Automatic erase failed: %v", err))
+				f.Report.Warn(fmt.Sprintf("Automatic erase failed: %v", err))
 			}
 		}
 	}
 
 	// 5. Flash
-	if method == "JTAG" {
-		device, script := f.resolveJLinkConfig(buildDir, target)
-		return f.flashViaJLink(binPath, tocPath, buildDir, device, script)
+	f.InvalidateDeviceIdentity(opts.Port)
+	if opts.Method == "JTAG" {
+		device, script := f.resolveJLinkConfig(buildDir, opts.Target)
+		return f.flashViaJLink(binPath, tocPath, buildDir, opts.Target, device, script)
+	}
+	if opts.Method == "PYOCD" {
+		return f.flashViaPyOCD(binPath, tocPath, buildDir, opts.Target)
+	}
+	if opts.Method == "OPENOCD" {
+		return f.flashViaOpenOCD(binPath, tocPath, buildDir, opts.Target)
 	}
 
 	// 4. Flash (app-write-mram uses the script located in bin/application_package.ds)
-	args := []string{"-p"}
+	images, err := f.ispImageArgs(binPath, tocPath, buildDir, opts.Target)
+	if err != nil {
+		return err
+	}
+	if f.DryRun && images == nil {
+		// app-write-mram follows the package map; show where that puts the files.
+		if _, err := f.resolveAddresses(buildDir, opts.Target); err != nil {
+			f.Report.Warn(fmt.Sprintf("Cannot resolve the load addresses: %v", err))
+		}
+	}
+	sp := f.Report.StartProgress(fmt.Sprintf("Flashing %s...", opts.Target))
+	timingMethod := "isp"
+	if opts.Slow {
+		timingMethod = "isp-slow"
+	}
+	timing := f.startTiming(sp, timingMethod, opts.Target, binPath)
+	output, err := f.writeMRAM(opts.Slow, opts.Verbose, images, sp.Update)
+	var timedOut *TimeoutError
+	stopped := errors.As(err, &timedOut) || errors.Is(err, ErrInterrupted)
+	if err != nil && !stopped && !opts.Slow && !f.NoRetry {
+		if msg := RetryableISPError(output); msg != "" {
+			sp.Fail("Flash failed")
+			f.Report.Warn(fmt.Sprintf("app-write-mram reported \"%s\"; retrying once without baud rate switching (--slow)", msg))
+			sp = f.Report.StartProgress(fmt.Sprintf("Flashing %s (slow)...", opts.Target))
+			timing = f.startTiming(sp, "isp-slow", opts.Target, binPath)
+			opts.Slow = true
+			output, err = f.writeMRAM(opts.Slow, opts.Verbose, images, sp.Update)
+			stopped = errors.As(err, &timedOut) || errors.Is(err, ErrInterrupted)
+		}
+	}
+	// A running application can hold the SE-UART pins; halting it over
+	// J-Link lets the SE answer the ISP handshake.
+	if err != nil && !stopped && NoResponseISPError(output) {
+		sp.Fail("Target did not respond")
+		assist, aerr := f.offerISPAssist()
+		if aerr != nil {
+			f.Report.Output(output)
+			return aerr
+		}
+		retried := false
+		if assist {
+			device, script := f.resolveJLinkConfig(buildDir, opts.Target)
+			if herr := f.haltViaJLink(device, script); herr != nil {
+				f.Report.Warn(fmt.Sprintf("J-Link halt failed: %v", herr))
+			} else {
+				sp = f.Report.StartProgress(fmt.Sprintf("Flashing %s (cores halted)...", opts.Target))
+				timing = f.startTiming(sp, "isp-assist", opts.Target, binPath)
+				output, err = f.writeMRAM(opts.Slow, opts.Verbose, images, sp.Update)
+				retried = true
+				if rerr := f.resumeViaJLink(device, script); rerr != nil {
+					f.Report.Warn(fmt.Sprintf("J-Link reset failed: %v; reset the board manually", rerr))
+				}
+			}
+		}
+		if !retried {
+			// The result was reported with the spinner above.
+			f.Report.Output(output)
+			return err
+		}
+	}
+	if err != nil {
+		sp.Fail("Flash failed")
+		f.Report.Output(output)
+		f.ReportTimeout(err)
+		return err
+	}
+	sp.Succeed("Flash complete!")
+	timing.done()
+	f.ResetAfterISP(opts.Port)
+	if f.Verify && !f.DryRun {
+		return f.verifyISP(binPath, tocPath, opts.Target)
+	}
+	return nil
+}
+
+// stageTOC copies the TOC package and its .sign and .crt companions to the
+// toolkit root and build/ directory, where the tools look for them.
+func (f *Flasher) stageTOC(tocPath string) error {
+	buildDestDir := filepath.Join(f.Cfg.AlifToolsPath, "build")
+	for _, suffix := range []string{"", ".sign", ".crt"} {
+		src := tocPath + suffix
+		fname := "AppTocPackage.bin" + suffix
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		for _, dst := range []string{filepath.Join(f.Cfg.AlifToolsPath, fname), filepath.Join(buildDestDir, fname)} {
+			if samePath(src, dst) {
+				continue
+			}
+			if err := f.copyFile(src, dst); err != nil {
+				return fmt.Errorf("failed to stage %s: %w", fname, err)
+			}
+		}
+	}
+	return nil
+}
+
+// writeMRAM runs app-write-mram -p, with images (see ispImageArgs) when
+// only part of the package is written, and returns its combined output. The
+// progress bar the tool draws is parsed as it arrives and passed to progress.
+func (f *Flasher) writeMRAM(noSwitch, verbose bool, images []string, progress func(current, total int64)) (string, error) {
+	args := append(append([]string{}, images...), "-p")
 	if noSwitch {
 		args = append(args, "-s")
 	}
@@ -260,138 +923,155 @@ func (f *Flasher) Flash(binPath, tocPath, port, target, configPath string, noSwi
 
 	cmd := exec.Command(filepath.Join(f.Cfg.AlifToolsPath, "app-write-mram"), args...)
 	cmd.Dir = f.Cfg.AlifToolsPath
-	var output bytes.Buffer
-	cmd.Stdout = &output
-	cmd.Stderr = &output
-
-	sp := ui.StartSpinner(fmt.Sprintf("Flashing %s...", target))
-	if err := cmd.Run(); err != nil {
-		sp.Fail("Flash failed")
-		fmt.Println("\n" + output.String())
-		return err
-	}
-	sp.Succeed("Flash complete!")
-	return nil
+	output := &progressWriter{progress: progress}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err := f.RunTool(cmd)
+	output.flush()
+	return output.String(), err
 }
 
+// genericJLinkDevice is used when no device mapping exists for a target.
+const genericJLinkDevice = "Cortex-M55"
+
+// resolveJLinkConfig maps a target to a J-Link device name and optional
+// script file using the project's .alif/JLinkDevices.xml. Resolutions are
+// cached in the project state and reused while the XML is unchanged.
 func (f *Flasher) resolveJLinkConfig(buildDir, target string) (string, string) {
-	device := "Cortex-M55"
+	device := genericJLinkDevice
 	script := ""
 
-	// Find project root (look for .alif)
-	curr := buildDir
+	// Find project root (look for .alif); StateDir names it directly when
+	// the artifacts were left outside the project.
 	var alifDir string
-	for curr != "/" && curr != "." {
-		info, err := os.Stat(filepath.Join(curr, ".alif"))
-		if err == nil && info.IsDir() {
-			alifDir = filepath.Join(curr, ".alif")
-			break
+	if info, err := os.Stat(f.StateDir); f.StateDir != "" && err == nil && info.IsDir() {
+		alifDir = f.StateDir
+	} else if abs, err := filepath.Abs(buildDir); err == nil {
+		if p := config.FindUpwards(abs, []string{".alif"}); p != "" {
+			if info, err := os.Stat(p); err == nil && info.IsDir() {
+				alifDir = p
+			}
 		}
-		curr = filepath.Dir(curr)
 	}
 
+	if f.JLinkDevice != "" {
+		return f.jlinkDeviceOverride(alifDir, target)
+	}
 	if alifDir == "" {
+		f.warnJLinkFallback(target)
 		return device, script
 	}
 
-	xmlPath := filepath.Join(alifDir, "JLinkDevices.xml")
-	xmlContent, err := os.ReadFile(xmlPath)
+	// ~/.alif holds the global CLI config, not project state, and a dry run
+	// leaves the project state alone.
+	persist := !f.DryRun
+	if home, err := os.UserHomeDir(); err == nil && alifDir == filepath.Join(home, ".alif") {
+		persist = false
+	}
+
+	st, err := state.Load(alifDir)
+	if err != nil {
+		f.Report.Warn(fmt.Sprintf("Ignoring unreadable project state: %v", err))
+	}
+
+	xmlHash := ""
+	xmlContent, err := os.ReadFile(filepath.Join(alifDir, "JLinkDevices.xml"))
 	if err == nil {
-		// Normalize target (AE722F80F55D5LS:M55_HE -> AE722F80F55D5LS_M55_HE)
-		normTarget := strings.ReplaceAll(target, ":", "_")
-
-		// Scan lines for Aliases containing normTarget
-		scanner := bufio.NewScanner(bytes.NewReader(xmlContent))
-		for scanner.Scan() {
-			line := scanner.Text()
-			if strings.Contains(line, normTarget) {
-				// Try to extract Name
-				if parts := strings.Split(line, "Name=\""); len(parts) > 1 {
-					name := strings.Split(parts[1], "\"")[0]
-					if name != "" {
-						device = name
-					}
-				}
-				// Try to extract Script File
-				if parts := strings.Split(line, "JLinkScriptFile=\""); len(parts) > 1 {
-					scriptName := strings.Split(parts[1], "\"")[0]
-					if scriptName != "" {
-						script = filepath.Join(alifDir, scriptName)
-					}
-				}
-				break
-			}
-		}
+		sum := sha256.Sum256(xmlContent)
+		xmlHash = hex.EncodeToString(sum[:])
 	}
 
-	return device, script
-}
+	if cached, ok := st.JLink[target]; ok && !cached.Fallback && cached.XMLHash == xmlHash {
+		f.Report.Item("J-Link Device", cached.Device+" (cached)")
+		return cached.Device, cached.Script
+	}
 
-func (f *Flasher) resolveBinaryAddress(buildDir string) (string, error) {
-	mapPath := filepath.Join(buildDir, "app-package-map.txt")
-	// Fallback to toolkit build dir if not in project build dir
-	if _, err := os.Stat(mapPath); err != nil {
-		mapPath = filepath.Join(f.Cfg.AlifToolsPath, "build", "app-package-map.txt")
+	if xmlContent != nil {
+		device, script = lookupJLinkDevice(xmlContent, target, alifDir)
 	}
 
-	content, err := os.ReadFile(mapPath)
-	if err != nil {
-		return "", fmt.Errorf("could not find package map file (app-package-map.txt). Please ensure the project is built correctly")
+	res := state.JLinkResolution{
+		Device:     device,
+		Script:     script,
+		XMLHash:    xmlHash,
+		Fallback:   device == genericJLinkDevice,
+		ResolvedAt: time.Now(),
+	}
+	if persist {
+		st.SetJLink(target, res)
+		if err := st.Save(); err != nil {
+			f.Report.Warn(fmt.Sprintf("Failed to save project state: %v", err))
+		}
 	}
 
-	scanner := bufio.NewScanner(bytes.NewReader(content))
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.Contains(line, "alif-img.bin") {
-			fields := strings.Fields(line)
-			if len(fields) > 1 {
-				return fields[0], nil
+	if res.Fallback {
+		f.warnJLinkFallback(target)
+	} else {
+		f.Report.Item("J-Link Device", device)
+	}
+	return device, script
+}
+
+// jlinkDeviceOverride returns JLinkDevice, with the script file
+// JLinkDevices.xml in alifDir gives that device for target, if any.
+func (f *Flasher) jlinkDeviceOverride(alifDir, target string) (string, string) {
+	script := ""
+	if alifDir != "" {
+		if xmlContent, err := os.ReadFile(filepath.Join(alifDir, "JLinkDevices.xml")); err == nil {
+			if device, s := lookupJLinkDevice(xmlContent, target, alifDir); device == f.JLinkDevice {
+				script = s
 			}
 		}
 	}
-	return "", fmt.Errorf("failed to extract binary MRAM address from package map. Please check the content of %s", mapPath)
+	f.Report.Item("J-Link Device", fmt.Sprintf("%s (%s)", f.JLinkDevice, f.JLinkDeviceSource))
+	return f.JLinkDevice, script
 }
 
-func (f *Flasher) resolveTOCAddress(buildDir string) (string, error) {
-	mapPath := filepath.Join(buildDir, "app-package-map.txt")
-	// Fallback to toolkit build dir if not in project build dir
-	if _, err := os.Stat(mapPath); err != nil {
-		mapPath = filepath.Join(f.Cfg.AlifToolsPath, "build", "app-package-map.txt")
-	}
+// lookupJLinkDevice scans JLinkDevices.xml for the entry whose aliases
+// mention target and returns its device name and script file.
+func lookupJLinkDevice(xmlContent []byte, target, alifDir string) (string, string) {
+	device := genericJLinkDevice
+	script := ""
 
-	mapContent, err := os.ReadFile(mapPath)
-	if err != nil {
-		return "", fmt.Errorf("could not find package map file (app-package-map.txt) in build directory. Please ensure the project is built correctly")
-	}
+	// Normalize target (AE722F80F55D5LS:M55_HE -> AE722F80F55D5LS_M55_HE)
+	normTarget := strings.ReplaceAll(target, ":", "_")
 
-	scanner := bufio.NewScanner(bytes.NewReader(mapContent))
+	// Scan lines for Aliases containing normTarget
+	scanner := bufio.NewScanner(bytes.NewReader(xmlContent))
 	for scanner.Scan() {
 		line := scanner.Text()
-		if strings.Contains(line, "APP Package Start Address:") {
-			parts := strings.Split(line, ":")
-			if len(parts) > 1 {
-				addr := strings.TrimSpace(parts[1])
-				if addr != "" {
-					return addr, nil
+		if strings.Contains(line, normTarget) {
+			// Try to extract Name
+			if parts := strings.Split(line, "Name=\""); len(parts) > 1 {
+				name := strings.Split(parts[1], "\"")[0]
+				if name != "" {
+					device = name
 				}
 			}
+			// Try to extract Script File
+			if parts := strings.Split(line, "JLinkScriptFile=\""); len(parts) > 1 {
+				scriptName := strings.Split(parts[1], "\"")[0]
+				if scriptName != "" {
+					script = filepath.Join(alifDir, scriptName)
+				}
+			}
+			break
 		}
 	}
 
-	return "", fmt.Errorf("failed to extract TOC address from package map. Please check the content of %s", mapPath)
+	return device, script
+}
+
+func (f *Flasher) warnJLinkFallback(target string) {
+	f.Report.Warn(fmt.Sprintf("No J-Link device mapping for '%s', using generic %s.", target, genericJLinkDevice))
+	f.Report.Warn("JTAG may fail until .alif/JLinkDevices.xml lists this target.")
+	f.Report.Info(fmt.Sprintf("Name the J-Link device with --device (e.g. --device %s), or set jlink_device in .alif/toolchain.yml.",
+		strings.ReplaceAll(target, ":", "_")))
 }
 
-func copyFile(src, dst string) error {
-	in, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer in.Close()
-	out, err := os.Create(dst)
-	if err != nil {
-		return err
-	}
-	defer out.Close()
-	_, err = io.Copy(out, in)
-	return err
+// samePath reports whether a and b name the same file.
+func samePath(a, b string) bool {
+	ai, err1 := os.Stat(a)
+	bi, err2 := os.Stat(b)
+	return err1 == nil && err2 == nil && os.SameFile(ai, bi)
 }
@@ -0,0 +1,314 @@
+package flasher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"alif-cli/internal/config"
+	"alif-cli/internal/state"
+	"alif-cli/internal/ui"
+
+	"go.bug.st/serial/enumerator"
+)
+
+// recorder is a silent Reporter that keeps the items, notes, warnings
+// and task results it was given.
+type recorder struct {
+	ui.Reporter
+	items   []string
+	infos   []string
+	warns   []string
+	results []string
+}
+
+func newRecorder() *recorder {
+	return &recorder{Reporter: ui.Silent}
+}
+
+func (r *recorder) Item(key, value string) { r.items = append(r.items, key+": "+value) }
+func (r *recorder) Info(msg string)        { r.infos = append(r.infos, msg) }
+func (r *recorder) Warn(msg string)        { r.warns = append(r.warns, msg) }
+
+func (r *recorder) StartProgress(msg string) ui.Progress {
+	return &recordedTask{Progress: ui.Silent.StartProgress(msg), r: r}
+}
+
+// recordedTask notes how a task of a recorder ended, as "ok: <msg>" or
+// "failed: <msg>".
+type recordedTask struct {
+	ui.Progress
+	r *recorder
+}
+
+func (t *recordedTask) Succeed(msg string) { t.r.results = append(t.r.results, "ok: "+msg) }
+func (t *recordedTask) Fail(msg string)    { t.r.results = append(t.r.results, "failed: "+msg) }
+
+// jlinkProject creates a project whose .alif holds xml as
+// JLinkDevices.xml (none when xml is empty) and returns the .alif path.
+func jlinkProject(t *testing.T, xml string) string {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	alifDir := filepath.Join(t.TempDir(), ".alif")
+	if err := os.MkdirAll(alifDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if xml != "" {
+		if err := os.WriteFile(filepath.Join(alifDir, "JLinkDevices.xml"), []byte(xml), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return alifDir
+}
+
+const jlinkXML = `<Database>
+  <Device>
+    <ChipInfo Vendor="AlifSemi" Name="AE722F80F55D5_M55_HE" Aliases="AE722F80F55D5LS_M55_HE" JLinkScriptFile="Devices/AlifSemi/Alif_E7.JLinkScript" />
+  </Device>
+</Database>
+`
+
+func TestLookupJLinkDevice(t *testing.T) {
+	tests := []struct {
+		target       string
+		device       string
+		scriptSuffix string
+	}{
+		{"AE722F80F55D5LS:M55_HE", "AE722F80F55D5_M55_HE", "Alif_E7.JLinkScript"},
+		{"AE722F80F55D5LS_M55_HE", "AE722F80F55D5_M55_HE", "Alif_E7.JLinkScript"},
+		{"AE722F80F55D5LS:M55_HP", genericJLinkDevice, ""},
+	}
+	for _, tt := range tests {
+		device, script := lookupJLinkDevice([]byte(jlinkXML), tt.target, "/p/.alif")
+		if device != tt.device || !strings.HasSuffix(script, tt.scriptSuffix) || (tt.scriptSuffix == "") != (script == "") {
+			t.Errorf("lookupJLinkDevice(%q) = %q, %q; want %q, *%s", tt.target, device, script, tt.device, tt.scriptSuffix)
+		}
+	}
+}
+
+func TestResolveJLinkConfigCache(t *testing.T) {
+	alifDir := jlinkProject(t, jlinkXML)
+	const target = "AE722F80F55D5LS:M55_HE"
+
+	rec := newRecorder()
+	f := &Flasher{Report: rec, StateDir: alifDir}
+	if device, _ := f.resolveJLinkConfig(alifDir, target); device != "AE722F80F55D5_M55_HE" {
+		t.Fatalf("first resolve = %q", device)
+	}
+	st, err := state.Load(alifDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := st.JLink[target]; res.Device != "AE722F80F55D5_M55_HE" || res.Fallback || res.XMLHash == "" {
+		t.Fatalf("recorded %+v", res)
+	}
+
+	rec = newRecorder()
+	f.Report = rec
+	if device, _ := f.resolveJLinkConfig(alifDir, target); device != "AE722F80F55D5_M55_HE" ||
+		len(rec.items) != 1 || !strings.Contains(rec.items[0], "(cached)") {
+		t.Fatalf("second resolve = %q, items %q; want the cached device", device, rec.items)
+	}
+
+	// Changing the XML invalidates the cached resolution.
+	if err := os.WriteFile(filepath.Join(alifDir, "JLinkDevices.xml"), []byte(strings.ReplaceAll(jlinkXML, "AE722F80F55D5_M55_HE", "E7_HE")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rec = newRecorder()
+	f.Report = rec
+	if device, _ := f.resolveJLinkConfig(alifDir, target); device != "E7_HE" {
+		t.Fatalf("after XML change = %q, want E7_HE", device)
+	}
+	for _, item := range rec.items {
+		if strings.Contains(item, "(cached)") {
+			t.Errorf("after XML change: stale cache used: %q", item)
+		}
+	}
+}
+
+func TestResolveJLinkConfigFallback(t *testing.T) {
+	tests := []struct {
+		name, xml, target string
+	}{
+		{"no XML", "", "AE722F80F55D5LS:M55_HE"},
+		{"target not listed", jlinkXML, "AE722F80F55D5LS:M55_HP"},
+	}
+	for _, tt := range tests {
+		alifDir := jlinkProject(t, tt.xml)
+		for run := 1; run <= 2; run++ {
+			rec := newRecorder()
+			f := &Flasher{Report: rec, StateDir: alifDir}
+			device, script := f.resolveJLinkConfig(alifDir, tt.target)
+			if device != genericJLinkDevice || script != "" {
+				t.Errorf("%s run %d: resolve = %q, %q; want the generic device", tt.name, run, device, script)
+			}
+			// A fallback is never served from the cache: each run warns.
+			if len(rec.warns) == 0 || !strings.Contains(rec.warns[0], "No J-Link device mapping") {
+				t.Errorf("%s run %d: warnings %q", tt.name, run, rec.warns)
+			}
+		}
+		st, _ := state.Load(alifDir)
+		if res, ok := st.JLink[tt.target]; !ok || !res.Fallback {
+			t.Errorf("%s: recorded %+v, %v; want a fallback", tt.name, res, ok)
+		}
+	}
+}
+
+func TestResolveJLinkConfigDryRun(t *testing.T) {
+	alifDir := jlinkProject(t, jlinkXML)
+	f := &Flasher{Report: newRecorder(), StateDir: alifDir, DryRun: true}
+	f.resolveJLinkConfig(alifDir, "AE722F80F55D5LS:M55_HE")
+	if _, err := os.Stat(state.Path(alifDir)); !os.IsNotExist(err) {
+		t.Errorf("dry run wrote the project state (%v)", err)
+	}
+}
+
+// portNames lists the names of ports, for comparisons.
+func portNames(ports []*enumerator.PortDetails) string {
+	var names []string
+	for _, p := range ports {
+		names = append(names, p.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestFilterPorts(t *testing.T) {
+	ports := []*enumerator.PortDetails{
+		{Name: "/dev/cu.usbmodem0001", IsUSB: true, VID: "1366", PID: "1061"},
+		{Name: "/dev/ttyUSB0", IsUSB: true, VID: "10C4", PID: "EA60"},
+		{Name: "/dev/ttyUSB1", IsUSB: true, VID: "0403", PID: "6001"},
+		{Name: "/dev/ttyS0"},
+		{Name: "/dev/JLink_CDC"},
+	}
+	tests := []struct {
+		name          string
+		patterns, ids []string
+		want          string
+	}{
+		{"defaults", nil, nil, "/dev/cu.usbmodem0001,/dev/JLink_CDC"},
+		{"patterns replace the defaults", []string{"ttyUSB"}, nil, "/dev/ttyUSB0,/dev/ttyUSB1"},
+		{"patterns ignore case", []string{"TTYS"}, nil, "/dev/ttyS0"},
+		{"ids only", nil, []string{"10c4:ea60"}, "/dev/ttyUSB0"},
+		{"patterns and ids", []string{"usbmodem"}, []string{"0403:6001"}, "/dev/cu.usbmodem0001,/dev/ttyUSB1"},
+		{"nothing matches", []string{"ttyACM"}, nil, ""},
+	}
+	for _, tt := range tests {
+		if got := portNames(FilterPorts(ports, tt.patterns, tt.ids)); got != tt.want {
+			t.Errorf("%s: FilterPorts = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatchSerial(t *testing.T) {
+	ports := []*enumerator.PortDetails{
+		{Name: "/dev/ttyACM0", SerialNumber: "000900123456"},
+		{Name: "/dev/ttyACM1", SerialNumber: "000900123457"},
+		{Name: "/dev/ttyACM2", SerialNumber: "00090012345"},
+		{Name: "/dev/ttyS0"},
+	}
+	tests := []struct {
+		sn   string
+		want string
+	}{
+		{"000900123456", "/dev/ttyACM0"},
+		{"00090012345", "/dev/ttyACM2"},
+		{"0009001234", "/dev/ttyACM0,/dev/ttyACM1,/dev/ttyACM2"},
+		{"999", ""},
+	}
+	for _, tt := range tests {
+		if got := portNames(matchSerial(ports, tt.sn)); got != tt.want {
+			t.Errorf("matchSerial(%q) = %q, want %q", tt.sn, got, tt.want)
+		}
+	}
+}
+
+func TestFlashOptions(t *testing.T) {
+	// Erase used to be fed the inverse of --no-erase; a flash without an
+	// erase area must not erase.
+	tests := []struct {
+		name   string
+		opts   Options
+		writes []string
+	}{
+		{"defaults", Options{}, []string{"-p"}},
+		{"slow", Options{Slow: true}, []string{"-p -s"}},
+		{"verbose", Options{Verbose: true}, []string{"-p -v"}},
+		{"erase app", Options{Erase: EraseApp}, []string{"-e APP", "-p"}},
+		{"erase all, slow and verbose", Options{Erase: EraseAll, Slow: true, Verbose: true}, []string{"-e ALL -v", "-p -s -v"}},
+	}
+	for _, tt := range tests {
+		t.Setenv("HOME", t.TempDir())
+		tk := scriptedToolkit(t)
+		if err := os.WriteFile(filepath.Join(tk, "app-write-mram"), []byte("#!/bin/sh\necho \"$*\" >> writes\necho Done\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		build := t.TempDir()
+		for _, name := range []string{"alif-img.bin", "AppTocPackage.bin"} {
+			if err := os.WriteFile(filepath.Join(build, name), []byte(name), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		f := New(&config.Config{AlifToolsPath: tk})
+		f.Report = ui.Silent
+		f.NoRetry = true
+		opts := tt.opts
+		opts.Method, opts.Port, opts.Target = "ISP", filepath.Join(t.TempDir(), "ttyACM0"), "AE722F80F55D5LS:M55_HE"
+		if err := f.Flash(filepath.Join(build, "alif-img.bin"), filepath.Join(build, "AppTocPackage.bin"), opts); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var got []string
+		if log, err := os.ReadFile(filepath.Join(tk, "writes")); err == nil {
+			got = strings.Split(strings.TrimSpace(string(log)), "\n")
+		}
+		if strings.Join(got, "|") != strings.Join(tt.writes, "|") {
+			t.Errorf("%s: app-write-mram runs %q, want %q", tt.name, got, tt.writes)
+		}
+	}
+}
+
+func TestDetectPort(t *testing.T) {
+	tests := []struct {
+		name      string
+		ports     []*enumerator.PortDetails
+		err       error
+		serial    string
+		want      string
+		errSubstr string
+	}{
+		{"no ports at all", nil, nil, "", "", "no serial ports found. Check that the board is powered"},
+		{"ports but none are Alif", []*enumerator.PortDetails{
+			{Name: "/dev/ttyS0"},
+			{Name: "/dev/ttyUSB0", IsUSB: true, VID: "10C4", PID: "EA60"},
+		}, nil, "", "", "2 serial port(s) found, none look like an Alif board: /dev/ttyS0, /dev/ttyUSB0. Check that the board is powered"},
+		{"none are Alif, but the serial matches", []*enumerator.PortDetails{
+			{Name: "/dev/ttyS0"},
+			{Name: "/dev/ttyUSB0", IsUSB: true, VID: "10C4", PID: "EA60", SerialNumber: "000900123456"},
+		}, nil, "000900123456", "/dev/ttyUSB0", ""},
+		{"one board", []*enumerator.PortDetails{
+			{Name: "/dev/ttyS0"},
+			{Name: "/dev/ttyACM0", IsUSB: true, VID: "1366", PID: "1061", Product: "J-Link"},
+		}, nil, "", "/dev/ttyACM0", ""},
+		{"enumeration fails", nil, os.ErrPermission, "", "", "failed to list ports: permission denied"},
+	}
+	defer func(list func() ([]*enumerator.PortDetails, error)) { portList = list }(portList)
+	ui.SetNonInteractive(true)
+	defer ui.SetNonInteractive(false)
+	for _, tt := range tests {
+		portList = func() ([]*enumerator.PortDetails, error) { return tt.ports, tt.err }
+		f := New(&config.Config{PortIDs: []string{"1366:1061"}})
+		f.Report = newRecorder()
+		f.Serial = tt.serial
+		f.NoProbe = true
+		got, err := f.detectPort()
+		switch {
+		case tt.errSubstr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.errSubstr != "" && (err == nil || !strings.Contains(err.Error(), tt.errSubstr)):
+			t.Errorf("%s: error = %v, want one mentioning %q", tt.name, err, tt.errSubstr)
+		case got != tt.want:
+			t.Errorf("%s: port %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package flasher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"alif-cli/internal/portlock"
+)
+
+// AcquirePort makes sure port can be opened for flashing. When it is busy
+// and the holder is an `alif monitor` session, the session is asked to
+// release it; the returned function lets the monitor re-attach and must be
+// called after flashing. Any other holder is reported by name where the
+// platform allows.
+func (f *Flasher) AcquirePort(port string) (func(), error) {
+	if ClassifyOpenError(openAndClose(port)) != PortBusy {
+		return func() {}, nil
+	}
+
+	if dir, err := portlock.Dir(); err == nil {
+		reg, giveBack, err := portlock.RequestRelease(dir, port, portlock.ReleaseTimeout)
+		if err == nil {
+			f.Report.Info(fmt.Sprintf("Paused %s (pid %d) to flash; it re-attaches afterwards", reg.Command, reg.PID))
+			return giveBack, nil
+		}
+		if !errors.Is(err, portlock.ErrNotOurs) {
+			return nil, err
+		}
+	}
+	return nil, busyPortError(port)
+}
+
+// busyPortError names the processes holding port, when they can be listed.
+func busyPortError(port string) error {
+	owners, err := portlock.Owners(port)
+	if err != nil || len(owners) == 0 {
+		return fmt.Errorf("%s is busy or not accessible; close any serial monitor using it and check permissions", port)
+	}
+	names := make([]string, len(owners))
+	for i, o := range owners {
+		names[i] = o.String()
+	}
+	return fmt.Errorf("%s is in use by %s; close it and retry", port, strings.Join(names, ", "))
+}
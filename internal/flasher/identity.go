@@ -0,0 +1,175 @@
+package flasher
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"alif-cli/internal/config"
+	"alif-cli/internal/state"
+	"alif-cli/internal/targets"
+	"alif-cli/internal/ui"
+)
+
+// DefaultProbeCacheTTL is how long a probed device identity is reused when
+// probe_cache_ttl is not configured.
+const DefaultProbeCacheTTL = 60 * time.Second
+
+// ProbeCacheTTL returns the identity cache lifetime from the config
+// (probe_cache_ttl), or DefaultProbeCacheTTL. Zero disables the cache.
+func ProbeCacheTTL(cfg *config.Config) time.Duration {
+	if cfg != nil && cfg.ProbeCacheTTL != "" {
+		if d, err := time.ParseDuration(cfg.ProbeCacheTTL); err == nil && d >= 0 {
+			return d
+		}
+	}
+	return DefaultProbeCacheTTL
+}
+
+// identityCache holds the device identities probed by this process, keyed
+// like state.State.Devices. Entries are also kept in the project state, so
+// the next command within the TTL can reuse them.
+type identityCache struct {
+	mu      sync.Mutex
+	entries map[string]state.DeviceIdentity
+	// now and probe are replaced by tests with a fake clock and prober.
+	now   func() time.Time
+	probe func(alifToolsPath string, r ui.Reporter) (partNumber, revision string, err error)
+}
+
+var identities = &identityCache{
+	entries: make(map[string]state.DeviceIdentity),
+	now:     time.Now,
+	probe:   targets.ProbeDevice,
+}
+
+func (c *identityCache) get(key string) (state.DeviceIdentity, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id, ok := c.entries[key]
+	return id, ok
+}
+
+func (c *identityCache) put(key string, id state.DeviceIdentity) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = id
+}
+
+func (c *identityCache) forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if key == "" {
+		clear(c.entries)
+		return
+	}
+	delete(c.entries, key)
+}
+
+// boardKey identifies the board behind port: its USB serial number, so a
+// board keeps its entry when it comes back on another port, or else the
+// port itself.
+func boardKey(port string) string {
+	if serial := PortSerialNumber(port); serial != "" {
+		return "sn:" + serial
+	}
+	return "port:" + port
+}
+
+// fresh reports whether id was probed less than ttl ago.
+func fresh(id state.DeviceIdentity, ttl time.Duration, now time.Time) bool {
+	return ttl > 0 && !id.ProbedAt.IsZero() && now.Sub(id.ProbedAt) < ttl
+}
+
+// GetDeviceIdentity returns the part number and revision of the board on
+// port. Every consumer of the board's identity goes through it: a probe
+// from this process or, with StateDir set, from an earlier command is
+// reused for ProbeCacheTTL, and the maintenance tool only runs when there
+// is none. Flashing, erasing or resetting the board drops its entry.
+func (f *Flasher) GetDeviceIdentity(port string) (*state.DeviceIdentity, error) {
+	key := boardKey(port)
+	now := identities.now()
+	if id, ok := identities.get(key); ok && fresh(id, f.ProbeCacheTTL, now) {
+		return &id, nil
+	}
+	if f.StateDir != "" {
+		if st, err := state.Load(f.StateDir); err == nil {
+			if id, ok := st.Devices[key]; ok && fresh(id, f.ProbeCacheTTL, now) {
+				identities.put(key, id)
+				return &id, nil
+			}
+		}
+	}
+
+	part, rev, err := identities.probe(f.Cfg.AlifToolsPath, f.Report)
+	if err != nil {
+		return nil, err
+	}
+	id := state.DeviceIdentity{PartNumber: part, Revision: rev, ProbedAt: identities.now()}
+	if f.ProbeCacheTTL <= 0 {
+		return &id, nil
+	}
+	identities.put(key, id)
+	if f.StateDir != "" {
+		if err := state.Update(f.StateDir, func(st *state.State) {
+			if st.Devices == nil {
+				st.Devices = make(map[string]state.DeviceIdentity)
+			}
+			st.Devices[key] = id
+		}); err != nil {
+			f.Report.Warn(fmt.Sprintf("Could not save the device identity: %v", err))
+		}
+	}
+	return &id, nil
+}
+
+// InvalidateDeviceIdentity drops the cached identity of the board on port
+// after it was written, erased or reset; an empty port (and no port
+// chosen by SelectPort) drops every board's.
+func (f *Flasher) InvalidateDeviceIdentity(port string) {
+	if f.DryRun {
+		return
+	}
+	if port == "" {
+		port = f.selectedPort
+	}
+	key := ""
+	if port != "" {
+		key = boardKey(port)
+	}
+	identities.forget(key)
+	if f.StateDir == "" {
+		return
+	}
+	st, err := state.Load(f.StateDir)
+	if err != nil || len(st.Devices) == 0 {
+		return
+	}
+	if _, ok := st.Devices[key]; key != "" && !ok {
+		return
+	}
+	state.Update(f.StateDir, func(st *state.State) {
+		if key == "" {
+			st.Devices = nil
+			return
+		}
+		delete(st.Devices, key)
+	})
+}
+
+// VerifyConnectedDevice checks that the board on port is the expected part
+// ("part" or "part:core"), using the cached identity when there is one.
+// Failures are reported before the error is returned.
+func (f *Flasher) VerifyConnectedDevice(port, expectedID string) error {
+	if f.Cfg.AlifToolsPath == "" || expectedID == "" {
+		return nil
+	}
+	id, err := f.GetDeviceIdentity(port)
+	if err != nil {
+		return err
+	}
+	if age := identities.now().Sub(id.ProbedAt); age >= time.Second {
+		f.Report.Item("Device", fmt.Sprintf("%s (Rev %s), probed %s ago", id.PartNumber, id.Revision, age.Round(time.Second)))
+	}
+	return targets.MatchDevice(id.PartNumber, id.Revision, expectedID, f.Report)
+}
@@ -0,0 +1,185 @@
+package flasher
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"alif-cli/internal/config"
+	"alif-cli/internal/state"
+	"alif-cli/internal/ui"
+)
+
+// fakeIdentities replaces the process identity cache with an empty one on
+// a fake clock whose prober counts its runs and fails while *probeErr is
+// set.
+func fakeIdentities(t *testing.T) (clock *time.Time, probes *int, probeErr *error) {
+	t.Helper()
+	clock = new(time.Time)
+	*clock = time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	probes, probeErr = new(int), new(error)
+	old := identities
+	t.Cleanup(func() { identities = old })
+	identities = &identityCache{
+		entries: make(map[string]state.DeviceIdentity),
+		now:     func() time.Time { return *clock },
+		probe: func(string, ui.Reporter) (string, string, error) {
+			*probes++
+			if *probeErr != nil {
+				return "", "", *probeErr
+			}
+			return "AE722F80F55D5LS", "B2", nil
+		},
+	}
+	return clock, probes, probeErr
+}
+
+// The ports in these tests do not exist, so boards are keyed by port.
+const (
+	portA = "/dev/ttyALIF-A"
+	portB = "/dev/ttyALIF-B"
+)
+
+func TestGetDeviceIdentityCache(t *testing.T) {
+	clock, probes, probeErr := fakeIdentities(t)
+	f := &Flasher{Cfg: &config.Config{}, Report: newRecorder(), ProbeCacheTTL: time.Minute}
+	steps := []struct {
+		name   string
+		action func()
+		port   string
+		probes int
+	}{
+		{"first probe", func() {}, portA, 1},
+		{"hit", func() { *clock = clock.Add(59 * time.Second) }, portA, 1},
+		{"other board", func() {}, portB, 2},
+		{"expired", func() { *clock = clock.Add(time.Second) }, portA, 3},
+		{"reset", func() { f.ResetAfterISP(portA) }, portA, 4},
+		{"other board kept", func() {}, portB, 4},
+		{"dry run keeps", func() { f.DryRun = true; f.InvalidateDeviceIdentity(portA); f.DryRun = false }, portA, 4},
+		{"selected port", func() { f.selectedPort = portB; f.InvalidateDeviceIdentity("") }, portB, 5},
+		{"selected port left the other", func() {}, portA, 5},
+		{"all boards", func() { f.selectedPort = ""; f.InvalidateDeviceIdentity("") }, portA, 6},
+	}
+	for _, step := range steps {
+		step.action()
+		id, err := f.GetDeviceIdentity(step.port)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if id.PartNumber != "AE722F80F55D5LS" || id.Revision != "B2" {
+			t.Errorf("%s: identity %+v", step.name, *id)
+		}
+		if *probes != step.probes {
+			t.Errorf("%s: %d probes in total, want %d", step.name, *probes, step.probes)
+		}
+	}
+
+	// A failed probe is not cached.
+	f.InvalidateDeviceIdentity("")
+	*probeErr = errors.New("no response from the board")
+	if _, err := f.GetDeviceIdentity(portA); err == nil {
+		t.Error("probe error not returned")
+	}
+	*probeErr = nil
+	if _, err := f.GetDeviceIdentity(portA); err != nil || *probes != 8 {
+		t.Errorf("after a failed probe: %d probes, error %v", *probes, err)
+	}
+}
+
+func TestGetDeviceIdentityDisabled(t *testing.T) {
+	_, probes, _ := fakeIdentities(t)
+	dir := t.TempDir()
+	f := &Flasher{Cfg: &config.Config{}, Report: newRecorder(), StateDir: dir}
+	for i := 0; i < 2; i++ {
+		if _, err := f.GetDeviceIdentity(portA); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if *probes != 2 {
+		t.Errorf("%d probes with a TTL of 0, want 2", *probes)
+	}
+	if st, _ := state.Load(dir); len(st.Devices) != 0 {
+		t.Errorf("identities saved with the cache off: %v", st.Devices)
+	}
+}
+
+func TestGetDeviceIdentityAcrossCommands(t *testing.T) {
+	clock, probes, _ := fakeIdentities(t)
+	dir := t.TempDir()
+	command := func() *Flasher {
+		// Each command starts with an empty process cache.
+		identities.forget("")
+		return &Flasher{Cfg: &config.Config{}, Report: newRecorder(), StateDir: dir, ProbeCacheTTL: time.Minute}
+	}
+
+	first, err := command().GetDeviceIdentity(portA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	*clock = clock.Add(30 * time.Second)
+	second, err := command().GetDeviceIdentity(portA)
+	if err != nil || *probes != 1 || !second.ProbedAt.Equal(first.ProbedAt) {
+		t.Errorf("within the TTL: %d probes, identity %+v, error %v", *probes, second, err)
+	}
+
+	*clock = clock.Add(30 * time.Second)
+	if _, err := command().GetDeviceIdentity(portA); err != nil || *probes != 2 {
+		t.Errorf("after the TTL: %d probes, error %v", *probes, err)
+	}
+
+	// A write by another command drops the saved identity too.
+	command().GetDeviceIdentity(portB)
+	command().InvalidateDeviceIdentity(portA)
+	st, err := state.Load(dir)
+	if _, ok := st.Devices["port:"+portA]; ok || err != nil {
+		t.Errorf("state after invalidating %s: %v, %v", portA, st.Devices, err)
+	}
+	if _, ok := st.Devices["port:"+portB]; !ok {
+		t.Errorf("state lost %s: %v", portB, st.Devices)
+	}
+	if _, err := command().GetDeviceIdentity(portA); err != nil || *probes != 4 {
+		t.Errorf("after invalidation: %d probes, error %v", *probes, err)
+	}
+}
+
+func TestEraseInvalidatesIdentity(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	_, probes, _ := fakeIdentities(t)
+	jlinkDir := fakeJLink(t, false)
+	f := New(&config.Config{JLinkPath: filepath.Join(jlinkDir, "JLinkExe")})
+	f.Report = newRecorder()
+	f.ProbeCacheTTL = time.Minute
+	f.GetDeviceIdentity(portA)
+	if err := f.EraseViaJLink(Region{Start: 0x80000000, End: 0x80000010}, "AE722F80F55D5LS", "AE722F80F55D5LS_M55_HE"); err != nil {
+		t.Fatal(err)
+	}
+	f.GetDeviceIdentity(portA)
+	if *probes != 2 {
+		t.Errorf("%d probes around an erase, want 2", *probes)
+	}
+}
+
+func TestProbeCacheTTL(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", DefaultProbeCacheTTL},
+		{"5m", 5 * time.Minute},
+		{"0s", 0},
+		{"-1s", DefaultProbeCacheTTL},
+		{"soon", DefaultProbeCacheTTL},
+	}
+	for _, tt := range tests {
+		if got := ProbeCacheTTL(&config.Config{ProbeCacheTTL: tt.value}); got != tt.want {
+			t.Errorf("ProbeCacheTTL(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+	if got := ProbeCacheTTL(nil); got != DefaultProbeCacheTTL {
+		t.Errorf("ProbeCacheTTL(nil) = %v", got)
+	}
+}
@@ -0,0 +1,121 @@
+package flasher
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"alif-cli/internal/config"
+)
+
+func TestSetConfigLines(t *testing.T) {
+	port := []struct{ key, value string }{{"comport", "/dev/ttyACM0"}}
+	portBaud := append(port, struct{ key, value string }{"baudrate", "921600"})
+	tests := []struct {
+		name     string
+		content  string
+		settings []struct{ key, value string }
+		want     string
+	}{
+		{"LF", "comport COM3\nbaudrate 55000\n", port, "comport /dev/ttyACM0\nbaudrate 55000\n"},
+		{"CRLF", "comport COM3\r\nbaudrate 55000\r\n", portBaud, "comport /dev/ttyACM0\r\nbaudrate 921600\r\n"},
+		{"no final newline", "baudrate 55000\ncomport COM3", port, "baudrate 55000\ncomport /dev/ttyACM0"},
+		{"appended after a line without newline", "baudrate 55000", port, "baudrate 55000\ncomport /dev/ttyACM0\n"},
+		{"appended with CRLF", "# isp\r\nbaudrate 55000\r\n", port, "# isp\r\nbaudrate 55000\r\ncomport /dev/ttyACM0\r\n"},
+		{"mixed endings kept per line", "comport COM3\r\nbaudrate 55000\nverbose 1\r\n", portBaud, "comport /dev/ttyACM0\r\nbaudrate 921600\nverbose 1\r\n"},
+		{"mostly LF appends LF", "a 1\nb 2\nc 3\r\n", port, "a 1\nb 2\nc 3\r\ncomport /dev/ttyACM0\n"},
+		{"first of duplicate keys", "comport COM3\ncomport COM4\n", port, "comport /dev/ttyACM0\ncomport COM4\n"},
+		{"indented key", "  comport\tCOM3\n", port, "comport /dev/ttyACM0\n"},
+		{"comments and similar keys kept", "# comport COM1\ncomports 2\nbaudrate  55000 \n", port, "# comport COM1\ncomports 2\nbaudrate  55000 \ncomport /dev/ttyACM0\n"},
+		{"empty", "", portBaud, "comport /dev/ttyACM0\nbaudrate 921600\n"},
+		{"blank lines kept", "\r\n\r\ncomport COM3\r\n\r\n", port, "\r\n\r\ncomport /dev/ttyACM0\r\n\r\n"},
+	}
+	for _, tt := range tests {
+		if got := setConfigLines(tt.content, tt.settings); got != tt.want {
+			t.Errorf("%s: setConfigLines = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateISPConfig(t *testing.T) {
+	fixture := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	tests := []struct {
+		name    string
+		content *string // nil: no isp_config_data.cfg
+		baud    int
+		want    string
+	}{
+		{"CRLF", ptr(fixture("isp-config-crlf.cfg")), 0,
+			"# ISP settings written by the Alif Security Toolkit\r\ncomport /dev/ttyACM0\r\nbaudrate 55000\r\nverbose 1\r\n"},
+		{"CRLF with baud", ptr(fixture("isp-config-crlf.cfg")), 921600,
+			"# ISP settings written by the Alif Security Toolkit\r\ncomport /dev/ttyACM0\r\nbaudrate 921600\r\nverbose 1\r\n"},
+		{"LF", ptr(fixture("isp-config-lf.cfg")), 0,
+			"# ISP settings\ncomport /dev/ttyACM0\nbaudrate 55000\nreset_delay 200\n"},
+		{"no comport line", ptr(fixture("isp-config-no-comport.cfg")), 0,
+			"# no port yet\r\nbaudrate 55000\r\nverbose 1\r\ncomport /dev/ttyACM0\r\n"},
+		{"empty", ptr(""), 0, "comport /dev/ttyACM0\n"},
+		{"empty with baud", ptr(""), 921600, "comport /dev/ttyACM0\nbaudrate 921600\n"},
+		{"missing", nil, 0, "comport /dev/ttyACM0\nbaudrate " + strconv.Itoa(DefaultISPBaud) + "\n"},
+	}
+	for _, tt := range tests {
+		tk := t.TempDir()
+		path := filepath.Join(tk, "isp_config_data.cfg")
+		if tt.content != nil {
+			if err := os.WriteFile(path, []byte(*tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		f := &Flasher{Cfg: &config.Config{AlifToolsPath: tk}, Report: newRecorder(), Baud: tt.baud}
+		if err := f.UpdateISPConfig("/dev/ttyACM0"); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		got, err := os.ReadFile(path)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("%s: isp_config_data.cfg = %q (%v), want %q", tt.name, got, err, tt.want)
+		}
+		// The file is replaced by a rename: no temporary file is left, and
+		// an existing file keeps its permissions.
+		entries, _ := os.ReadDir(tk)
+		if len(entries) != 1 {
+			t.Errorf("%s: toolkit holds %d files after the update", tt.name, len(entries))
+		}
+		if info, err := os.Stat(path); err == nil && tt.content != nil && runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+			t.Errorf("%s: mode %v, want 0600", tt.name, info.Mode().Perm())
+		}
+	}
+}
+
+func TestUpdateISPConfigFailureKeepsFile(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Getuid() == 0 {
+		t.Skip("needs a directory the user cannot write")
+	}
+	tk := t.TempDir()
+	path := filepath.Join(tk, "isp_config_data.cfg")
+	if err := os.WriteFile(path, []byte("comport COM3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(tk, 0555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(tk, 0755)
+	f := &Flasher{Cfg: &config.Config{AlifToolsPath: tk}, Report: newRecorder()}
+	if err := f.UpdateISPConfig("/dev/ttyACM0"); err == nil || !strings.Contains(err.Error(), "failed to update isp_config_data.cfg") {
+		t.Errorf("error = %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "comport COM3\n" {
+		t.Errorf("isp_config_data.cfg = %q after a failed update", got)
+	}
+}
+
+func ptr(s string) *string { return &s }
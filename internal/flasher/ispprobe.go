@@ -0,0 +1,149 @@
+package flasher
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ISP packets, as the Security Toolkit's isp_protocol builds them: a
+// length byte counting the whole packet, the command, its data and a
+// checksum that makes all bytes add up to zero.
+const (
+	ispCommandStart        = 0x00
+	ispCommandStop         = 0x01
+	ispCommandDataResponse = 0xFD
+	ispCommandAck          = 0xFE
+	ispCommandNak          = 0xFF
+)
+
+// Timing of the bootloader probe: how long the SE gets to answer, and how
+// often START_ISP is repeated meanwhile in case it was still booting.
+const (
+	ispProbeTimeout  = 3 * time.Second
+	ispProbeInterval = 500 * time.Millisecond
+)
+
+// ErrNoISPResponse means nothing on the port answered an ISP packet.
+var ErrNoISPResponse = errors.New("no ISP response")
+
+// ispPacket builds a packet for command with data.
+func ispPacket(command byte, data ...byte) []byte {
+	packet := append([]byte{byte(len(data) + 3), command}, data...)
+	var sum byte
+	for _, b := range packet {
+		sum += b
+	}
+	return append(packet, -sum)
+}
+
+// isISPReply reports whether buf holds a well-formed ACK, NAK or data
+// response packet anywhere in it; boot messages may come before it.
+func isISPReply(buf []byte) bool {
+	for i := 0; i+3 <= len(buf); i++ {
+		length := int(buf[i])
+		if length < 3 || i+length > len(buf) {
+			continue
+		}
+		switch buf[i+1] {
+		case ispCommandAck, ispCommandNak, ispCommandDataResponse:
+		default:
+			continue
+		}
+		var sum byte
+		for _, b := range buf[i : i+length] {
+			sum += b
+		}
+		if sum == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ProbeISP checks that the Secure Enclave's ISP bootloader answers on port
+// before anything is staged: it sends START_ISP at the ISP baud rate,
+// waits up to ispProbeTimeout for a reply and ends the session again with
+// STOP_ISP. A port on the wrong UART, or a board not in ISP mode, fails in
+// seconds instead of when app-write-mram gives up.
+func (f *Flasher) ProbeISP(port string) error {
+	if f.DryRun {
+		f.Report.Item("Would probe", fmt.Sprintf("%s for the ISP bootloader (START_ISP at %d baud)", port, f.ispBaud()))
+		return nil
+	}
+	sp := f.Report.StartTask(fmt.Sprintf("Checking for the ISP bootloader on %s...", port))
+	err := f.pingISP(port)
+	if err != nil {
+		sp.Fail("ISP bootloader did not answer")
+		return err
+	}
+	sp.Succeed("ISP bootloader answered")
+	return nil
+}
+
+// pingISP sends START_ISP every ispProbeInterval until a reply arrives or
+// ispProbeTimeout passes.
+func (f *Flasher) pingISP(port string) error {
+	p, closePort, err := f.openPort(port, f.ispBaud())
+	if err != nil {
+		return err
+	}
+	defer closePort()
+	if err := p.SetReadTimeout(100 * time.Millisecond); err != nil {
+		return err
+	}
+	p.ResetInputBuffer()
+
+	var seen []byte
+	buf := make([]byte, 256)
+	deadline := time.Now().Add(ispProbeTimeout)
+	for time.Now().Before(deadline) {
+		if _, err := p.Write(ispPacket(ispCommandStart)); err != nil {
+			return err
+		}
+		resend := time.Now().Add(ispProbeInterval)
+		for time.Now().Before(resend) {
+			n, err := p.Read(buf)
+			if err != nil {
+				return err
+			}
+			seen = append(seen, buf[:n]...)
+			if isISPReply(seen) {
+				p.Write(ispPacket(ispCommandStop))
+				p.Drain()
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w within %s at %d baud", ErrNoISPResponse, ispProbeTimeout, f.ispBaud())
+}
+
+// ReportISPProbeHelp lists the other ports that look like a board and how
+// to get the SE into ISP mode, after ProbeISP failed on port.
+func (f *Flasher) ReportISPProbeHelp(port string) {
+	var labels map[string]string
+	if f.Cfg != nil {
+		labels = f.Cfg.PortLabels
+	}
+	candidates, _, err := f.CandidatePorts()
+	var others []string
+	if err == nil {
+		for _, p := range candidates {
+			if p.Name == port || samePath(p.Name, port) {
+				continue
+			}
+			others = append(others, describePort(p, labels, ""))
+		}
+	}
+	if len(others) > 0 {
+		f.Report.Info(fmt.Sprintf("%s may not be the SE-UART. Other ports that look like a board:", port))
+		for _, o := range others {
+			f.Report.Info("  " + o)
+		}
+		f.Report.Info("Pick one with --port, or by board with --serial")
+	} else {
+		f.Report.Info(fmt.Sprintf("Check that %s is the SE-UART and not an application UART", port))
+	}
+	f.Report.Info("Hold the ISP button (if the board has one) while pressing RESET, or stop the running application, then flash again")
+	f.Report.Info("Use --skip-probe to flash without this check")
+}
@@ -0,0 +1,185 @@
+package flasher
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"alif-cli/internal/jlink"
+	"alif-cli/internal/ui"
+)
+
+// SelectProbe returns the serial number of the J-Link to use: ProbeSerial
+// when set, otherwise the one attached probe, or the user's choice when
+// several are attached (J-Link Commander would otherwise open its own
+// selection dialog and hang). "" means no probe was listed and J-Link
+// Commander picks. The choice is kept for the Flasher's later runs.
+func (f *Flasher) SelectProbe() (string, error) {
+	if f.probe != nil {
+		return f.probe.Serial, nil
+	}
+	exe, err := f.jlinkExecutable()
+	if err != nil {
+		return "", err
+	}
+	if f.ProbeSerial != "" {
+		f.probe = &jlink.Emulator{Serial: f.ProbeSerial}
+		if emus, err := jlink.Emulators(exe); err == nil {
+			for _, e := range emus {
+				if e.Serial == f.ProbeSerial {
+					f.probe = &e
+				}
+			}
+		}
+		f.Report.Item("J-Link Serial", f.ProbeSerial)
+		return f.ProbeSerial, nil
+	}
+
+	emus, err := jlink.Emulators(exe)
+	if err != nil || len(emus) == 0 {
+		f.probe = &jlink.Emulator{}
+		return "", nil
+	}
+	choice := 0
+	if len(emus) > 1 {
+		options := make([]string, len(emus))
+		for i, e := range emus {
+			options[i] = fmt.Sprintf("%s (%s)", e.Serial, e.Product)
+		}
+		choice, err = ui.Select("Multiple J-Link probes found:", "Select probe: ", options, "Pass --probe-serial <sn> or set jlink_serial in the config.")
+		if err != nil {
+			return "", err
+		}
+		f.Report.Item("J-Link Serial", emus[choice].Serial)
+	}
+	f.probe = &emus[choice]
+	return f.probe.Serial, nil
+}
+
+// jlinkSettings identifies the selected probe once per Flasher and returns
+// its profile with the user's --jlink-speed / --jlink-if applied.
+func (f *Flasher) jlinkSettings(script string) (jlink.Profile, error) {
+	if f.jlinkProfile == nil {
+		if (f.DryRun || f.EmitScript != "") && f.probe == nil {
+			// Listing probes would query the hardware.
+			f.probe = &jlink.Emulator{Serial: f.ProbeSerial}
+		}
+		if _, err := f.SelectProbe(); err != nil {
+			return jlink.Profile{}, err
+		}
+		model := f.probe.Product
+		if model == "" && f.ProbeSerial == "" && !f.DryRun && f.EmitScript == "" {
+			model, _ = jlink.Detect(f.jlinkExe)
+		}
+		p := jlink.ProfileFor(model)
+		if model == "" {
+			f.Report.Item("J-Link Probe", "unknown (default profile)")
+		} else {
+			f.Report.Item("J-Link Probe", fmt.Sprintf("%s (%s profile)", model, p.Name))
+		}
+		if p.NeedsScript && script == "" {
+			f.Report.Warn(fmt.Sprintf("%s usually needs the device's reset script; add JLinkScriptFile to .alif/JLinkDevices.xml", p.Name))
+		}
+		f.jlinkProfile = &p
+	}
+
+	p := *f.jlinkProfile
+	var overridden []string
+	if f.JLinkSpeed > 0 {
+		p.Speed = f.JLinkSpeed
+		overridden = append(overridden, "--jlink-speed")
+	}
+	if f.JLinkInterface != "" {
+		p.Interface = strings.ToUpper(f.JLinkInterface)
+		overridden = append(overridden, "--jlink-if")
+	}
+	settings := fmt.Sprintf("%s, %d kHz", p.Interface, p.Speed)
+	if len(overridden) > 0 {
+		settings += " (" + strings.Join(overridden, ", ") + ")"
+	}
+	f.Report.Item("J-Link Link", settings)
+	return p, nil
+}
+
+// jlinkExecutable resolves J-Link Commander once per Flasher, honouring
+// jlink_path from the config.
+func (f *Flasher) jlinkExecutable() (string, error) {
+	if f.jlinkExe == "" {
+		configured := ""
+		if f.Cfg != nil {
+			configured = f.Cfg.JLinkPath
+		}
+		exe, err := jlink.Resolve(configured)
+		if err != nil && f.DryRun {
+			f.Report.Warn(fmt.Sprintf("%v", err))
+			exe, err = jlink.Executable(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		f.jlinkExe = exe
+	}
+	return f.jlinkExe, nil
+}
+
+// jlinkCommand returns a J-Link Commander run of commandFile on the
+// selected probe, with the device's reset script when there is one.
+func (f *Flasher) jlinkCommand(scriptPathOverride, commandFile string) (*exec.Cmd, error) {
+	exe, err := f.jlinkExecutable()
+	if err != nil {
+		return nil, err
+	}
+	var args []string
+	if f.probe != nil {
+		args = append(args, jlink.SelectArgs(f.probe.Serial)...)
+	}
+	if scriptPathOverride != "" {
+		args = append(args, "-JLinkScriptFile", scriptPathOverride)
+	}
+	args = append(args, "-CommandFile", commandFile)
+	return exec.Command(exe, args...), nil
+}
+
+// emitJLinkScript writes a flash command file to f.EmitScript and prints
+// what JLinkExe needs besides it: the device and the JLinkScriptFile.
+func (f *Flasher) emitJLinkScript(content []byte, device, scriptFile string) error {
+	path, err := filepath.Abs(f.EmitScript)
+	if err != nil {
+		return err
+	}
+	if err := f.writeScript(path, content); err != nil {
+		return fmt.Errorf("failed to write J-Link script: %w", err)
+	}
+	f.Report.Item("Device", device)
+	if scriptFile == "" {
+		f.Report.Item("JLinkScriptFile", "none")
+	} else {
+		f.Report.Item("JLinkScriptFile", scriptFile)
+	}
+	args := []string{"JLinkExe"}
+	if f.ProbeSerial != "" {
+		args = append(args, jlink.SelectArgs(f.ProbeSerial)...)
+	}
+	if scriptFile != "" {
+		args = append(args, "-JLinkScriptFile", scriptFile)
+	}
+	args = append(args, "-CommandFile", path)
+	f.Report.Item("Run", strings.Join(args, " "))
+	f.Report.Success(fmt.Sprintf("J-Link script written to %s", path))
+	return nil
+}
+
+// jlinkCommands builds a J-Link Commander command file that connects to
+// device with link's settings, runs cmds and quits.
+func jlinkCommands(link jlink.Profile, device string, cmds ...string) []byte {
+	lines := []string{
+		"si " + link.Interface,
+		fmt.Sprintf("speed %d", link.Speed),
+		"device " + device,
+		"connect",
+	}
+	lines = append(lines, cmds...)
+	lines = append(lines, "qc")
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
@@ -0,0 +1,59 @@
+package flasher
+
+import (
+	"strings"
+	"testing"
+
+	"alif-cli/internal/jlink"
+)
+
+func TestJLinkSettings(t *testing.T) {
+	ob := jlink.ProfileFor("J-Link OB-K22-Cortex-M")
+	tests := []struct {
+		name      string
+		speed     int
+		iface     string
+		want      jlink.Profile
+		linkEntry string
+	}{
+		{"profile", 0, "", ob, "J-Link Link: SWD, 2000 kHz"},
+		{"speed override", 4000, "", jlink.Profile{Name: ob.Name, Speed: 4000, Interface: "SWD", NeedsScript: true}, "J-Link Link: SWD, 4000 kHz (--jlink-speed)"},
+		{"both overrides", 1000, "jtag", jlink.Profile{Name: ob.Name, Speed: 1000, Interface: "JTAG", NeedsScript: true}, "J-Link Link: JTAG, 1000 kHz (--jlink-speed, --jlink-if)"},
+	}
+	for _, tt := range tests {
+		rec := newRecorder()
+		f := &Flasher{Report: rec, JLinkSpeed: tt.speed, JLinkInterface: tt.iface}
+		f.probe = &jlink.Emulator{Serial: "600101234", Product: "J-Link OB-K22-Cortex-M"}
+
+		got, err := f.jlinkSettings("")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: settings = %+v, want %+v", tt.name, got, tt.want)
+		}
+		items := strings.Join(rec.items, "\n")
+		for _, want := range []string{"J-Link Probe: J-Link OB-K22-Cortex-M (J-Link OB profile)", tt.linkEntry} {
+			if !strings.Contains(items, want) {
+				t.Errorf("%s: items %q lack %q", tt.name, rec.items, want)
+			}
+		}
+		// The OB profile wants the reset script, which was not given.
+		if len(rec.warns) != 1 || !strings.Contains(rec.warns[0], "reset script") {
+			t.Errorf("%s: warnings %q", tt.name, rec.warns)
+		}
+	}
+}
+
+func TestJLinkSettingsWithScript(t *testing.T) {
+	rec := newRecorder()
+	f := &Flasher{Report: rec}
+	f.probe = &jlink.Emulator{Serial: "50123456", Product: "J-Link PLUS"}
+	got, err := f.jlinkSettings("/p/.alif/Alif_E7.JLinkScript")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "J-Link PLUS" || got.Speed != 4000 || len(rec.warns) != 0 {
+		t.Errorf("settings = %+v, warnings %q", got, rec.warns)
+	}
+}
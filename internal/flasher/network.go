@@ -0,0 +1,61 @@
+package flasher
+
+import (
+	"fmt"
+
+	"alif-cli/internal/netserial"
+
+	"go.bug.st/serial"
+)
+
+// toolkitPort returns the device the Security Toolkit should open for port.
+// A network port (tcp:// or rfc2217://) is bridged to a local
+// pseudo-terminal on first use; the bridge runs until the process exits,
+// so every later toolkit run reaches the same connection.
+func (f *Flasher) toolkitPort(port string) (string, error) {
+	if !netserial.IsNetwork(port) {
+		return port, nil
+	}
+	if f.bridge != nil && f.bridge.Port == port {
+		return f.bridge.Path, nil
+	}
+	if _, _, err := netserial.Parse(port); err != nil {
+		return "", err
+	}
+	if f.DryRun {
+		f.Report.Item("Would bridge", fmt.Sprintf("%s to a local pseudo-terminal for the toolkit", port))
+		return port, nil
+	}
+	b, err := netserial.NewBridge(port, f.ispBaud())
+	if err != nil {
+		return "", fmt.Errorf("cannot bridge %s for the Security Toolkit: %w", port, err)
+	}
+	f.bridge = b
+	f.Report.Item("Bridge", fmt.Sprintf("%s -> %s", b.Path, port))
+	if !b.FollowsBaud {
+		f.Report.Info(fmt.Sprintf("%s keeps its baud rate fixed; ISP speed switching is turned off (as with --slow)", port))
+	}
+	return b.Path, nil
+}
+
+// FixedSpeed reports whether the toolkit must stay at the configured baud
+// rate (app-write-mram -s) because the bridged port cannot follow its
+// speed changes.
+func (f *Flasher) FixedSpeed() bool {
+	return f.bridge != nil && !f.bridge.FollowsBaud
+}
+
+// openPort opens port to drive its control lines or read from it. A
+// network port that is bridged already is reached through the bridge's
+// connection, since serial servers usually accept one client at a time;
+// closing it then leaves the bridge running.
+func (f *Flasher) openPort(port string, baud int) (p serial.Port, closePort func(), err error) {
+	if f.bridge != nil && f.bridge.Port == port {
+		return f.bridge.Conn(), func() {}, nil
+	}
+	p, err = netserial.Open(port, &serial.Mode{BaudRate: baud})
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, func() { p.Close() }, nil
+}
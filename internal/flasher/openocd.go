@@ -0,0 +1,99 @@
+package flasher
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// openOCDExecutable is the OpenOCD command-line tool.
+const openOCDExecutable = "openocd"
+
+// openOCDConfigName is the adapter/target config looked up in the
+// project's .alif directory when OpenOCDConfig is not set.
+const openOCDConfigName = "openocd.cfg"
+
+// openOCDConfig returns the adapter config to pass with -f: OpenOCDConfig
+// when set, otherwise .alif/openocd.cfg in the project.
+func (f *Flasher) openOCDConfig() (string, error) {
+	if f.OpenOCDConfig != "" {
+		if _, err := os.Stat(f.OpenOCDConfig); err != nil {
+			return "", fmt.Errorf("OpenOCD config not found: %s", f.OpenOCDConfig)
+		}
+		return f.OpenOCDConfig, nil
+	}
+	if f.StateDir != "" {
+		p := filepath.Join(f.StateDir, openOCDConfigName)
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", errors.New("no OpenOCD adapter config: add .alif/openocd.cfg to the project or pass --openocd-cfg")
+}
+
+// openOCDScript returns the commands that write files and reset the
+// target. OpenOCD's Tcl parser wants forward slashes.
+func openOCDScript(files []flashFile, reset string) string {
+	lines := []string{"init", "reset halt"}
+	for _, file := range files {
+		lines = append(lines, fmt.Sprintf("program {%s} %s", filepath.ToSlash(file.path), file.addr))
+	}
+	lines = append(lines, openOCDResetCommands(reset)...)
+	lines = append(lines, "shutdown")
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// flashViaOpenOCD writes the image and the TOC (or the one f.Only
+// selects) with a generated OpenOCD script, at the addresses the JTAG path
+// would use.
+func (f *Flasher) flashViaOpenOCD(binPath, tocPath, buildDir, target string) error {
+	f.Report.Info("Using OpenOCD for flashing...")
+	exe := openOCDExecutable
+	if runtime.GOOS == "windows" {
+		exe += ".exe"
+	}
+	if _, err := exec.LookPath(exe); err != nil {
+		return fmt.Errorf("%s not found on PATH", exe)
+	}
+
+	plan, err := f.resolveAddresses(buildDir, target)
+	if err != nil {
+		return err
+	}
+	adapterCfg, err := f.openOCDConfig()
+	if err != nil {
+		return err
+	}
+	f.Report.Item("OpenOCD Config", adapterCfg)
+	f.Report.Item("Reset", describeReset(f.resetMode()))
+	if f.Verify {
+		f.Report.Warn("--verify is not supported with OpenOCD; skipping readback")
+	}
+
+	scriptFile, err := f.tempScript("alif-flash-openocd-*.tcl", []byte(openOCDScript(f.flashFiles(binPath, tocPath, plan), f.resetMode())))
+	if err != nil {
+		return fmt.Errorf("failed to create OpenOCD script: %w", err)
+	}
+	defer os.Remove(scriptFile)
+
+	cmd := exec.Command(exe, "-f", adapterCfg, "-f", scriptFile)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	sp := f.Report.StartTask(fmt.Sprintf("Flashing %s via OpenOCD...", target))
+	timing := f.startTiming(sp, "openocd", target, binPath)
+	if err := f.RunTool(cmd); err != nil {
+		sp.Fail("OpenOCD flashing failed")
+		f.Report.Output(output.String())
+		return fmt.Errorf("OpenOCD flash failed: %w", err)
+	}
+	sp.Succeed("Flashed successfully via OpenOCD")
+	timing.done()
+	return nil
+}
@@ -0,0 +1,70 @@
+package flasher
+
+import (
+	"fmt"
+	"os"
+)
+
+// Parts of a bootable image Flash can be limited to (--app-only, --toc-only).
+const (
+	OnlyApp = "app"
+	OnlyTOC = "toc"
+)
+
+// flashFile is one file written at a load address.
+type flashFile struct {
+	path string
+	addr string
+}
+
+// flashFiles returns the image and the TOC at their load addresses, or just
+// the one f.Only selects.
+func (f *Flasher) flashFiles(binPath, tocPath string, plan *AddressPlan) []flashFile {
+	switch f.Only {
+	case OnlyApp:
+		return []flashFile{{binPath, plan.App}}
+	case OnlyTOC:
+		return []flashFile{{tocPath, plan.TOC}}
+	}
+	return []flashFile{{binPath, plan.App}, {tocPath, plan.TOC}}
+}
+
+// checkOnlyArtifact makes sure the artifact f.Only selects exists.
+func (f *Flasher) checkOnlyArtifact(binPath, tocPath string) error {
+	path := ""
+	switch f.Only {
+	case "":
+		return nil
+	case OnlyApp:
+		path = binPath
+	case OnlyTOC:
+		path = tocPath
+	default:
+		return fmt.Errorf("unknown image part '%s'", f.Only)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("%s not found; run 'alif image' to create it", path)
+	}
+	return nil
+}
+
+// ispImageArgs returns the app-write-mram arguments that burn only the part
+// f.Only selects, through the --images option flash raw uses. It is empty
+// for a full write, which follows the package map (-p alone).
+func (f *Flasher) ispImageArgs(binPath, tocPath, buildDir, target string) ([]string, error) {
+	if f.Only == "" {
+		return nil, nil
+	}
+	if err := f.checkRawISPSupport(); err != nil {
+		return nil, fmt.Errorf("--%s-only: %w", f.Only, err)
+	}
+	plan, err := f.resolveAddresses(buildDir, target)
+	if err != nil {
+		return nil, err
+	}
+	var args []string
+	for _, file := range f.flashFiles(binPath, tocPath, plan) {
+		args = append(args, "-i", fmt.Sprintf("%s %s", file.path, file.addr))
+	}
+	return args, nil
+}
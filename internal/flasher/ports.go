@@ -0,0 +1,162 @@
+package flasher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"go.bug.st/serial/enumerator"
+)
+
+// knownPortLabels names common USB serial devices seen with Alif boards,
+// keyed by "VID:PID" or by "VID" for whole vendors.
+var knownPortLabels = map[string]string{
+	"1366":      "SEGGER J-Link",
+	"0403:6001": "FTDI FT232R UART",
+	"0403:6010": "FTDI FT2232 UART",
+	"0403:6011": "FTDI FT4232 UART",
+	"0403:6014": "FTDI FT232H UART",
+	"0403:6015": "FTDI FT-X UART",
+	"10C4:EA60": "Silicon Labs CP210x UART",
+	"10C4:EA70": "Silicon Labs CP2105 UART",
+	"0D28:0204": "Arm DAPLink",
+}
+
+// PortLabel returns a human-readable name for a USB serial device, checking
+// extra (from port_labels in the config) before the built-in table. Returns
+// "" for unknown devices.
+func PortLabel(p *enumerator.PortDetails, extra map[string]string) string {
+	if !p.IsUSB || p.VID == "" {
+		return ""
+	}
+	vid, pid := strings.ToUpper(p.VID), strings.ToUpper(p.PID)
+	for _, table := range []map[string]string{extra, knownPortLabels} {
+		for key, label := range table {
+			k := strings.ToUpper(key)
+			if k == vid+":"+pid {
+				return label
+			}
+		}
+		for key, label := range table {
+			if strings.ToUpper(key) == vid {
+				return label
+			}
+		}
+	}
+	return ""
+}
+
+// describePort formats a port for the picker: the path (and its stable
+// path, if any), its label if known, and always the raw USB IDs and serial
+// number.
+func describePort(p *enumerator.PortDetails, extra map[string]string, stable string) string {
+	name := p.Name
+	if stable != "" {
+		name = fmt.Sprintf("%s → %s", p.Name, stable)
+	}
+	ids := fmt.Sprintf("VID:%s PID:%s Serial:%s", p.VID, p.PID, p.SerialNumber)
+	if label := PortLabel(p, extra); label != "" {
+		return fmt.Sprintf("%s - %s (%s)", name, label, ids)
+	}
+	return fmt.Sprintf("%s (%s)", name, ids)
+}
+
+// DedupePorts collapses the /dev/cu.* and /dev/tty.* nodes macOS creates for
+// every serial device into one entry, keeping the cu. device (opening tty.
+// blocks until carrier detect). Ports are returned unchanged on other
+// platforms (goos is runtime.GOOS in normal use).
+func DedupePorts(ports []*enumerator.PortDetails, goos string) []*enumerator.PortDetails {
+	if goos != "darwin" {
+		return ports
+	}
+
+	hasCU := make(map[string]bool)
+	for _, p := range ports {
+		if suffix, ok := strings.CutPrefix(p.Name, "/dev/cu."); ok {
+			hasCU[suffix] = true
+		}
+	}
+
+	var out []*enumerator.PortDetails
+	for _, p := range ports {
+		if suffix, ok := strings.CutPrefix(p.Name, "/dev/tty."); ok && hasCU[suffix] {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// portList enumerates the serial ports as the system reports them. Tests
+// replace it to simulate the ports of a host.
+var portList = enumerator.GetDetailedPortsList
+
+// listPorts enumerates serial ports, one entry per physical port.
+func listPorts() ([]*enumerator.PortDetails, error) {
+	ports, err := portList()
+	if err != nil {
+		return nil, err
+	}
+	return DedupePorts(ports, runtime.GOOS), nil
+}
+
+// SerialByIDDir holds udev's stable symlinks to serial devices on Linux.
+const SerialByIDDir = "/dev/serial/by-id"
+
+// StablePath returns the symlink in byIDDir that points at port, or "" if
+// there is none (or port already is such a link).
+func StablePath(port, byIDDir string) string {
+	if strings.HasPrefix(port, byIDDir+"/") {
+		return ""
+	}
+	target, err := filepath.EvalSymlinks(port)
+	if err != nil {
+		return ""
+	}
+	entries, err := os.ReadDir(byIDDir)
+	if err != nil {
+		return ""
+	}
+	for _, e := range entries {
+		link := filepath.Join(byIDDir, e.Name())
+		if resolved, err := filepath.EvalSymlinks(link); err == nil && resolved == target {
+			return link
+		}
+	}
+	return ""
+}
+
+// stablePorts maps port names to their stable paths on Linux.
+func stablePorts(ports []*enumerator.PortDetails, goos string) map[string]string {
+	stable := make(map[string]string)
+	if goos != "linux" {
+		return stable
+	}
+	for _, p := range ports {
+		if link := StablePath(p.Name, SerialByIDDir); link != "" {
+			stable[p.Name] = link
+		}
+	}
+	return stable
+}
+
+// PortSerialNumber returns the USB serial number of the device behind port
+// ("" if unknown). Symlinks such as /dev/serial/by-id paths are resolved.
+func PortSerialNumber(port string) string {
+	ports, err := portList()
+	if err != nil {
+		return ""
+	}
+	target, err := filepath.EvalSymlinks(port)
+	if err != nil {
+		target = port
+	}
+	for _, p := range ports {
+		if p.Name == port || p.Name == target {
+			return p.SerialNumber
+		}
+	}
+	return ""
+}
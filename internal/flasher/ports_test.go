@@ -0,0 +1,116 @@
+package flasher
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"go.bug.st/serial/enumerator"
+)
+
+func TestDedupePorts(t *testing.T) {
+	ports := func(names ...string) []*enumerator.PortDetails {
+		var list []*enumerator.PortDetails
+		for _, n := range names {
+			list = append(list, &enumerator.PortDetails{Name: n})
+		}
+		return list
+	}
+	tests := []struct {
+		name  string
+		goos  string
+		ports []*enumerator.PortDetails
+		want  string
+	}{
+		{"macOS pair", "darwin",
+			ports("/dev/tty.usbmodem0001", "/dev/cu.usbmodem0001"),
+			"/dev/cu.usbmodem0001"},
+		{"macOS two boards", "darwin",
+			ports("/dev/cu.usbmodem0001", "/dev/tty.usbmodem0001", "/dev/cu.usbserial-A1", "/dev/tty.usbserial-A1"),
+			"/dev/cu.usbmodem0001,/dev/cu.usbserial-A1"},
+		{"macOS tty without cu", "darwin",
+			ports("/dev/tty.Bluetooth-Incoming-Port", "/dev/cu.usbmodem0001"),
+			"/dev/tty.Bluetooth-Incoming-Port,/dev/cu.usbmodem0001"},
+		{"macOS different suffixes", "darwin",
+			ports("/dev/cu.usbmodem0001", "/dev/tty.usbmodem0002"),
+			"/dev/cu.usbmodem0001,/dev/tty.usbmodem0002"},
+		{"Linux unchanged", "linux",
+			ports("/dev/ttyACM0", "/dev/ttyACM1", "/dev/tty.usbmodem0001", "/dev/cu.usbmodem0001"),
+			"/dev/ttyACM0,/dev/ttyACM1,/dev/tty.usbmodem0001,/dev/cu.usbmodem0001"},
+		{"Windows unchanged", "windows",
+			ports("COM3", "COM4"),
+			"COM3,COM4"},
+	}
+	for _, tt := range tests {
+		if got := portNames(DedupePorts(tt.ports, tt.goos)); got != tt.want {
+			t.Errorf("%s: DedupePorts = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// fakeDev lays out a /dev with two ACM ports and a by-id link to the first,
+// returning the dev directory.
+func fakeDev(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("by-id links are a Linux layout")
+	}
+	dev := t.TempDir()
+	for _, name := range []string{"ttyACM0", "ttyACM1"} {
+		if err := os.WriteFile(filepath.Join(dev, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	byID := filepath.Join(dev, "serial", "by-id")
+	if err := os.MkdirAll(byID, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../../ttyACM0", filepath.Join(byID, "usb-Alif_Semiconductor_DevKit_E7_000900123456-if00")); err != nil {
+		t.Fatal(err)
+	}
+	// A dangling link from an unplugged board is ignored.
+	if err := os.Symlink("../../ttyACM9", filepath.Join(byID, "usb-SEGGER_J-Link_000000001-if00")); err != nil {
+		t.Fatal(err)
+	}
+	return dev
+}
+
+func TestStablePath(t *testing.T) {
+	dev := fakeDev(t)
+	byID := filepath.Join(dev, "serial", "by-id")
+	link := filepath.Join(byID, "usb-Alif_Semiconductor_DevKit_E7_000900123456-if00")
+	if err := os.Symlink("ttyACM1", filepath.Join(dev, "alif-board")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, port, want string
+	}{
+		{"kernel name", filepath.Join(dev, "ttyACM0"), link},
+		{"no link", filepath.Join(dev, "ttyACM1"), ""},
+		{"already stable", link, ""},
+		{"other symlink", filepath.Join(dev, "alif-board"), ""},
+		{"missing port", filepath.Join(dev, "ttyACM7"), ""},
+	}
+	for _, tt := range tests {
+		if got := StablePath(tt.port, byID); got != tt.want {
+			t.Errorf("%s: StablePath(%s) = %q, want %q", tt.name, tt.port, got, tt.want)
+		}
+	}
+
+	if got := StablePath(filepath.Join(dev, "ttyACM0"), filepath.Join(dev, "no-by-id")); got != "" {
+		t.Errorf("without a by-id directory: StablePath = %q", got)
+	}
+}
+
+func TestDescribePortStable(t *testing.T) {
+	p := &enumerator.PortDetails{Name: "/dev/ttyACM0", IsUSB: true, VID: "0403", PID: "6001", SerialNumber: "A1"}
+	got := describePort(p, nil, "/dev/serial/by-id/usb-FTDI-if00")
+	for _, want := range []string{"/dev/ttyACM0 → /dev/serial/by-id/usb-FTDI-if00", "FTDI FT232R UART", "Serial:A1"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("describePort = %q, want it to contain %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package flasher
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"go.bug.st/serial"
+	"go.bug.st/serial/enumerator"
+)
+
+// PortHealth is the result of probing a serial port.
+type PortHealth int
+
+const (
+	PortHealthy PortHealth = iota
+	// PortBusy means another process holds the port or access was denied.
+	PortBusy
+	// PortUnresponsive means the port could not be opened in time.
+	PortUnresponsive
+)
+
+func (h PortHealth) String() string {
+	switch h {
+	case PortBusy:
+		return "busy"
+	case PortUnresponsive:
+		return "unresponsive"
+	default:
+		return "ok"
+	}
+}
+
+// probeDeadline bounds the whole probe so the port menu never stalls.
+const probeDeadline = 750 * time.Millisecond
+
+// openAndClose opens and immediately closes a port, reporting any error.
+func openAndClose(name string) error {
+	p, err := serial.Open(name, &serial.Mode{BaudRate: 115200})
+	if err != nil {
+		return err
+	}
+	return p.Close()
+}
+
+// ClassifyOpenError maps an open error to a PortHealth.
+func ClassifyOpenError(err error) PortHealth {
+	if err == nil {
+		return PortHealthy
+	}
+	var portErr *serial.PortError
+	if errors.As(err, &portErr) {
+		switch portErr.Code() {
+		case serial.PortBusy, serial.PermissionDenied:
+			return PortBusy
+		}
+	}
+	msg := strings.ToLower(err.Error())
+	if strings.Contains(msg, "busy") || strings.Contains(msg, "access is denied") || strings.Contains(msg, "permission denied") {
+		return PortBusy
+	}
+	return PortUnresponsive
+}
+
+// ProbePorts opens every port in parallel using open (openAndClose in normal
+// use). Ports that have not answered by deadline are reported unresponsive.
+func ProbePorts(ports []*enumerator.PortDetails, open func(string) error, deadline time.Duration) map[string]PortHealth {
+	health := make(map[string]PortHealth, len(ports))
+	var mu sync.Mutex
+	for _, p := range ports {
+		health[p.Name] = PortUnresponsive
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, p := range ports {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			h := ClassifyOpenError(open(name))
+			mu.Lock()
+			health[name] = h
+			mu.Unlock()
+		}(p.Name)
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(deadline):
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	result := make(map[string]PortHealth, len(health))
+	for k, v := range health {
+		result[k] = v
+	}
+	return result
+}
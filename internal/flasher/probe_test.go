@@ -0,0 +1,69 @@
+package flasher
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.bug.st/serial"
+	"go.bug.st/serial/enumerator"
+)
+
+func TestClassifyOpenError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want PortHealth
+	}{
+		{"opened", nil, PortHealthy},
+		// The zero PortError carries the PortBusy code.
+		{"serial busy", &serial.PortError{}, PortBusy},
+		{"errno busy", syscall.EBUSY, PortBusy},
+		{"permission denied", errors.New("open /dev/ttyACM0: permission denied"), PortBusy},
+		{"windows access denied", errors.New("Access is denied."), PortBusy},
+		{"gone", errors.New("open /dev/ttyACM0: no such file or directory"), PortUnresponsive},
+		{"i/o error", syscall.EIO, PortUnresponsive},
+	}
+	for _, tt := range tests {
+		if got := ClassifyOpenError(tt.err); got != tt.want {
+			t.Errorf("%s: ClassifyOpenError(%v) = %s, want %s", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestProbePorts(t *testing.T) {
+	ports := []*enumerator.PortDetails{
+		{Name: "/dev/ttyACM0"}, {Name: "/dev/ttyACM1"}, {Name: "/dev/ttyACM2"}, {Name: "/dev/ttyACM3"},
+	}
+	hang := make(chan struct{})
+	defer close(hang)
+	open := func(name string) error {
+		switch name {
+		case "/dev/ttyACM1":
+			return syscall.EBUSY
+		case "/dev/ttyACM2":
+			return syscall.EIO
+		case "/dev/ttyACM3":
+			<-hang // never answers within the deadline
+		}
+		return nil
+	}
+
+	start := time.Now()
+	health := ProbePorts(ports, open, 50*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ProbePorts took %s despite the 50ms deadline", elapsed)
+	}
+	want := map[string]PortHealth{
+		"/dev/ttyACM0": PortHealthy,
+		"/dev/ttyACM1": PortBusy,
+		"/dev/ttyACM2": PortUnresponsive,
+		"/dev/ttyACM3": PortUnresponsive,
+	}
+	for name, h := range want {
+		if health[name] != h {
+			t.Errorf("%s: %s, want %s", name, health[name], h)
+		}
+	}
+}
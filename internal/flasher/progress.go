@@ -0,0 +1,68 @@
+package flasher
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+)
+
+// ispProgressPattern matches the progress bar app-write-mram redraws with
+// carriage returns while writing each image, e.g.
+// "[#########           ]45%: 3744/8320 bytes".
+var ispProgressPattern = regexp.MustCompile(`(\d+)%:\s*(\d+)\s*/\s*(\d+)\s*bytes`)
+
+// ParseISPProgress extracts the bytes written and the image size from one
+// line (or carriage-return segment) of app-write-mram output.
+func ParseISPProgress(line string) (current, total int64, ok bool) {
+	m := ispProgressPattern.FindStringSubmatch(line)
+	if m == nil {
+		return 0, 0, false
+	}
+	current, err1 := strconv.ParseInt(m[2], 10, 64)
+	total, err2 := strconv.ParseInt(m[3], 10, 64)
+	if err1 != nil || err2 != nil || total <= 0 {
+		return 0, 0, false
+	}
+	return current, total, true
+}
+
+// progressWriter keeps a tool's complete output while passing each line
+// and carriage-return segment to ParseISPProgress.
+type progressWriter struct {
+	output   bytes.Buffer
+	pending  []byte
+	progress func(current, total int64)
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.output.Write(p)
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.IndexAny(w.pending, "\r\n")
+		if i < 0 {
+			break
+		}
+		w.scan(w.pending[:i])
+		w.pending = w.pending[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush scans output left without a line terminator.
+func (w *progressWriter) flush() {
+	w.scan(w.pending)
+	w.pending = nil
+}
+
+func (w *progressWriter) scan(segment []byte) {
+	if w.progress == nil {
+		return
+	}
+	if current, total, ok := ParseISPProgress(string(segment)); ok {
+		w.progress(current, total)
+	}
+}
+
+func (w *progressWriter) String() string {
+	return w.output.String()
+}
@@ -0,0 +1,99 @@
+package flasher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseISPProgress(t *testing.T) {
+	tests := []struct {
+		line           string
+		current, total int64
+		ok             bool
+	}{
+		{"[#########           ]45%: 3744/8320 bytes", 3744, 8320, true},
+		{"[####################]100%: 8320/8320 bytes", 8320, 8320, true},
+		{"  12% : 1024 / 8320 bytes", 0, 0, false},
+		{"12%: 1024 / 8320 bytes", 1024, 8320, true},
+		{"[          ]0%: 0/0 bytes", 0, 0, false},
+		{"[####################] 100%", 0, 0, false},
+		{"Burning: alif-img.bin 0x80000000", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, tt := range tests {
+		current, total, ok := ParseISPProgress(tt.line)
+		if current != tt.current || total != tt.total || ok != tt.ok {
+			t.Errorf("ParseISPProgress(%q) = %d, %d, %v; want %d, %d, %v", tt.line, current, total, ok, tt.current, tt.total, tt.ok)
+		}
+	}
+}
+
+func TestProgressWriter(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []string
+	}{
+		{"app-write-mram-progress.txt", []string{
+			"0/8320", "1024/8320", "3744/8320", "8192/8320", "8320/8320",
+			"0/4096", "2048/4096", "4096/4096",
+		}},
+		// Older toolkits only print a bar without byte counts: no progress
+		// is reported and the caller keeps its spinner.
+		{"app-write-mram-no-progress.txt", nil},
+	}
+	for _, tt := range tests {
+		output, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The tool's output arrives in arbitrary pieces; feed it a few
+		// bytes at a time so segments are split across writes.
+		for _, chunk := range []int{1, 7, len(output)} {
+			var got []string
+			w := &progressWriter{progress: func(current, total int64) {
+				got = append(got, fmt.Sprintf("%d/%d", current, total))
+			}}
+			for i := 0; i < len(output); i += chunk {
+				end := i + chunk
+				if end > len(output) {
+					end = len(output)
+				}
+				w.Write(output[i:end])
+			}
+			w.flush()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s in %d-byte writes: progress %q, want %q", tt.fixture, chunk, got, tt.want)
+			}
+			if w.String() != string(output) {
+				t.Errorf("%s in %d-byte writes: output not kept verbatim", tt.fixture, chunk)
+			}
+		}
+	}
+}
+
+func TestProgressWriterFlush(t *testing.T) {
+	var got []string
+	w := &progressWriter{progress: func(current, total int64) {
+		got = append(got, fmt.Sprintf("%d/%d", current, total))
+	}}
+	// A final redraw without a line terminator is only seen on flush.
+	w.Write([]byte("[##########          ]50%: 2048/4096 bytes"))
+	if len(got) != 0 {
+		t.Fatalf("progress before flush: %q", got)
+	}
+	w.flush()
+	if !reflect.DeepEqual(got, []string{"2048/4096"}) {
+		t.Errorf("progress after flush = %q", got)
+	}
+
+	// Without a callback the output is still collected.
+	w = &progressWriter{}
+	w.Write([]byte("50%: 2048/4096 bytes\n"))
+	w.flush()
+	if w.String() != "50%: 2048/4096 bytes\n" {
+		t.Errorf("output = %q", w.String())
+	}
+}
@@ -0,0 +1,95 @@
+package flasher
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"alif-cli/internal/targets"
+)
+
+// pyOCDExecutable is the pyOCD command-line tool.
+const pyOCDExecutable = "pyocd"
+
+// pyOCDInstallHint lists the ways to get pyOCD and the Alif device pack.
+const pyOCDInstallHint = `pyocd not found on PATH. Install it with one of:
+    pip install pyocd
+    pipx install pyocd
+    uv tool install pyocd
+then install the Alif Ensemble device pack: pyocd pack install <part number>`
+
+// pyOCDTarget returns the pyOCD target name: PyOCDTarget when set,
+// otherwise the lower-case part number of the device the toolkit is
+// configured for (pack targets are named after the part, e.g.
+// "ae722f80f55d5ls").
+func (f *Flasher) pyOCDTarget() (string, error) {
+	if f.PyOCDTarget != "" {
+		return f.PyOCDTarget, nil
+	}
+	part, _, err := targets.ToolkitDevice(f.Cfg.AlifToolsPath)
+	if err != nil {
+		return "", fmt.Errorf("cannot derive the pyOCD target (pass --device): %w", err)
+	}
+	// Part# reads like "E7 (AE722F80F55D5LS) - 5.5 MRAM / 13.5 SRAM".
+	if lp, rp := strings.Index(part, "("), strings.Index(part, ")"); lp != -1 && rp > lp {
+		part = part[lp+1 : rp]
+	}
+	if part == "" {
+		return "", fmt.Errorf("cannot derive the pyOCD target: toolkit has no device selected (pass --device)")
+	}
+	return strings.ToLower(part), nil
+}
+
+// flashViaPyOCD writes the image and the TOC (or the one f.Only selects)
+// with a `pyocd flash` invocation each, at the addresses the JTAG path
+// would use.
+func (f *Flasher) flashViaPyOCD(binPath, tocPath, buildDir, target string) error {
+	f.Report.Info("Using pyOCD for CMSIS-DAP flashing...")
+	exe, err := exec.LookPath(pyOCDExecutable)
+	if err != nil {
+		return errors.New(pyOCDInstallHint)
+	}
+
+	plan, err := f.resolveAddresses(buildDir, target)
+	if err != nil {
+		return err
+	}
+	pyTarget, err := f.pyOCDTarget()
+	if err != nil {
+		return err
+	}
+	f.Report.Item("pyOCD Target", pyTarget)
+	if f.resetMode() == ResetHalt {
+		return fmt.Errorf("--reset halt is not supported with pyOCD (use %s or %s)", ResetRun, ResetNone)
+	}
+	f.Report.Item("Reset", describeReset(f.resetMode()))
+	if f.Verify {
+		f.Report.Warn("--verify is not supported with pyOCD; skipping readback")
+	}
+
+	sp := f.Report.StartTask(fmt.Sprintf("Flashing %s via pyOCD...", pyTarget))
+	timing := f.startTiming(sp, "pyocd", target, binPath)
+	files := f.flashFiles(binPath, tocPath, plan)
+	for i, img := range files {
+		args := []string{"flash", "-t", pyTarget, "--base-address", img.addr}
+		// pyOCD resets after each write; only the last one decides.
+		if i < len(files)-1 || f.resetMode() == ResetNone {
+			args = append(args, "--no-reset")
+		}
+		cmd := exec.Command(exe, append(args, img.path)...)
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		if err := f.RunTool(cmd); err != nil {
+			sp.Fail(fmt.Sprintf("pyOCD failed writing %s", filepath.Base(img.path)))
+			f.Report.Output(output.String())
+			return fmt.Errorf("pyOCD flash failed: %w", err)
+		}
+	}
+	sp.Succeed("Flashed successfully via pyOCD")
+	timing.done()
+	return nil
+}
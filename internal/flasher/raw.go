@@ -0,0 +1,149 @@
+package flasher
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"alif-cli/internal/audit"
+)
+
+// WriteRaw programs a file at addr without generating a TOC. Address
+// validation is the caller's responsibility (see ValidateRawWrite). For JTAG,
+// device overrides the J-Link device resolved for target.
+func (f *Flasher) WriteRaw(path string, addr uint64, method, target, device string, verify, verbose bool) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	addrStr := fmt.Sprintf("0x%08x", addr)
+	f.InvalidateDeviceIdentity("")
+
+	if method == "JTAG" {
+		resolved, script := f.resolveJLinkConfig(filepath.Dir(absPath), target)
+		if device == "" {
+			device = resolved
+		}
+		return f.writeRawViaJLink(absPath, addrStr, device, script, verify)
+	}
+
+	if verify {
+		f.Report.Warn("--verify is only supported with --method JTAG; skipping readback")
+	}
+	if err := f.checkRawISPSupport(); err != nil {
+		return err
+	}
+
+	args := []string{"-i", fmt.Sprintf("%s %s", absPath, addrStr), "-p"}
+	if verbose {
+		args = append(args, "-v")
+	}
+	cmd := exec.Command(filepath.Join(f.Cfg.AlifToolsPath, "app-write-mram"), args...)
+	cmd.Dir = f.Cfg.AlifToolsPath
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	sp := f.Report.StartTask(fmt.Sprintf("Writing %s at %s...", filepath.Base(absPath), addrStr))
+	if err := f.RunTool(cmd); err != nil {
+		sp.Fail("Raw write failed")
+		f.Report.Output(output.String())
+		f.ReportTimeout(err)
+		return err
+	}
+	sp.Succeed("Raw write complete!")
+	return nil
+}
+
+// checkRawISPSupport makes sure the installed app-write-mram can burn
+// arbitrary images (its --images option).
+func (f *Flasher) checkRawISPSupport() error {
+	cmd := exec.Command(filepath.Join(f.Cfg.AlifToolsPath, "app-write-mram"), "-h")
+	cmd.Dir = f.Cfg.AlifToolsPath
+	// The bundled Python fails to print its help on non-UTF-8 locales.
+	cmd.Env = append(os.Environ(), "PYTHONIOENCODING=utf-8")
+	out, _ := cmd.CombinedOutput()
+	if !strings.Contains(string(out), "--images") {
+		version := "unknown"
+		if v, err := os.ReadFile(filepath.Join(f.Cfg.AlifToolsPath, "version.txt")); err == nil {
+			version = strings.TrimSpace(string(v))
+		}
+		return fmt.Errorf("raw ISP writes need an app-write-mram with --images support (installed toolkit: %s); use --method JTAG instead", version)
+	}
+	return nil
+}
+
+// VerifyRaw reads back the MRAM at addr over J-Link and compares it with
+// path. Readback is not available over ISP.
+func (f *Flasher) VerifyRaw(path string, addr uint64, target, device string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	resolved, script := f.resolveJLinkConfig(filepath.Dir(absPath), target)
+	if device == "" {
+		device = resolved
+	}
+	return f.runJLinkRaw(absPath, fmt.Sprintf("0x%08x", addr), device, script, false, true)
+}
+
+func (f *Flasher) writeRawViaJLink(path, addr, device, scriptPathOverride string, verify bool) error {
+	return f.runJLinkRaw(path, addr, device, scriptPathOverride, true, verify)
+}
+
+// runJLinkRaw loads and/or verifies path at addr with a generated J-Link
+// command file.
+func (f *Flasher) runJLinkRaw(path, addr, device, scriptPathOverride string, load, verify bool) error {
+	link, err := f.jlinkSettings(scriptPathOverride)
+	if err != nil {
+		return err
+	}
+	var cmds []string
+	if load {
+		cmds = append(cmds, fmt.Sprintf("loadbin %s %s", path, addr))
+	}
+	if verify {
+		cmds = append(cmds, fmt.Sprintf("verifybin %s %s", path, addr))
+	}
+	if load {
+		cmds = append(cmds, "r", "g")
+	}
+
+	scriptPath, err := audit.CreateTemp("alif-raw-*.jlink", jlinkCommands(link, device, cmds...))
+	if err != nil {
+		return fmt.Errorf("failed to create J-Link script: %w", err)
+	}
+	defer os.Remove(scriptPath)
+
+	cmd, err := f.jlinkCommand(scriptPathOverride, scriptPath)
+	if err != nil {
+		return err
+	}
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	action := "Writing"
+	if !load {
+		action = "Verifying"
+	}
+	sp := f.Report.StartTask(fmt.Sprintf("%s %s at %s via J-Link...", action, filepath.Base(path), addr))
+	err = f.RunTool(cmd)
+	if err == nil && verify && strings.Contains(strings.ToLower(output.String()), "verify failed") {
+		err = fmt.Errorf("readback of %s does not match", filepath.Base(path))
+	}
+	if err != nil {
+		sp.Fail(fmt.Sprintf("J-Link %s failed", strings.ToLower(action)))
+		f.Report.Output(output.String())
+		return fmt.Errorf("J-Link raw %s failed: %w", strings.ToLower(action), err)
+	}
+	if load {
+		sp.Succeed("Raw write complete!")
+	} else {
+		sp.Succeed("Readback matches")
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package flasher
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"alif-cli/internal/targets"
+)
+
+// tocReserve is the size kept free for the APP TOC package at the top of the
+// application MRAM area when no package map says where it starts.
+const tocReserve = 0x1000
+
+// Region is a half-open MRAM address range [Start, End).
+type Region struct {
+	Start uint64
+	End   uint64
+}
+
+func (r Region) String() string {
+	return fmt.Sprintf("0x%08x-0x%08x", r.Start, r.End)
+}
+
+// Contains reports whether o lies entirely within r.
+func (r Region) Contains(o Region) bool {
+	return o.Start >= r.Start && o.End <= r.End
+}
+
+// Overlaps reports whether r and o share at least one byte.
+func (r Region) Overlaps(o Region) bool {
+	return r.Start < o.End && o.Start < r.End
+}
+
+// ParseAddress parses a hexadecimal address such as 0x80300000.
+func ParseAddress(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(strings.ToLower(s), "0x") {
+		return 0, fmt.Errorf("address '%s' must be hexadecimal (0x...)", s)
+	}
+	v, err := strconv.ParseUint(s[2:], 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address '%s'", s)
+	}
+	return v, nil
+}
+
+// AppRegion returns the application MRAM area of a device.
+func AppRegion(dev *targets.DeviceInfo) Region {
+	return Region{Start: dev.MRAMBase, End: dev.AppEnd()}
+}
+
+// TOCRegion returns the area occupied by the APP TOC package. The package
+// start comes from a package map when one is available, otherwise the top
+// tocReserve bytes of the application area are assumed.
+func TOCRegion(dev *targets.DeviceInfo, mapPath string) Region {
+	end := dev.AppEnd()
+	if mapPath != "" {
+		if content, err := os.ReadFile(mapPath); err == nil {
+			scanner := bufio.NewScanner(bytes.NewReader(content))
+			for scanner.Scan() {
+				line := scanner.Text()
+				if strings.Contains(line, "APP Package Start Address:") {
+					parts := strings.SplitN(line, ":", 2)
+					if addr, err := ParseAddress(parts[1]); err == nil && addr < end {
+						return Region{Start: addr, End: end}
+					}
+				}
+			}
+		}
+	}
+	return Region{Start: end - tocReserve, End: end}
+}
+
+// ValidateRawWrite checks that size bytes written at addr stay inside the
+// application area and, unless force is set, clear of the TOC region.
+func ValidateRawWrite(addr, size uint64, app, toc Region, force bool) error {
+	if size == 0 {
+		return fmt.Errorf("nothing to write (file is empty)")
+	}
+	w := Region{Start: addr, End: addr + size}
+	if !app.Contains(w) {
+		return fmt.Errorf("write %s is outside the application MRAM area %s", w, app)
+	}
+	if w.Overlaps(toc) && !force {
+		return fmt.Errorf("write %s overlaps the TOC region %s (use --force to write anyway)", w, toc)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package flasher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"alif-cli/internal/targets"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    uint64
+		wantErr bool
+	}{
+		{"0x80300000", 0x80300000, false},
+		{"0X8057F000", 0x8057F000, false},
+		{"  0x0  ", 0, false},
+		{"80300000", 0, true},
+		{"0x", 0, true},
+		{"0xZZ", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAddress(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseAddress(%q) = 0x%x, %v; want 0x%x, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRegion(t *testing.T) {
+	r := Region{Start: 0x100, End: 0x200}
+	tests := []struct {
+		o                  Region
+		contains, overlaps bool
+	}{
+		{Region{0x100, 0x200}, true, true},
+		{Region{0x180, 0x190}, true, true},
+		{Region{0x0f0, 0x110}, false, true},
+		{Region{0x1f0, 0x210}, false, true},
+		{Region{0x000, 0x100}, false, false},
+		{Region{0x200, 0x300}, false, false},
+	}
+	for _, tt := range tests {
+		if got := r.Contains(tt.o); got != tt.contains {
+			t.Errorf("%s.Contains(%s) = %v, want %v", r, tt.o, got, tt.contains)
+		}
+		if got := r.Overlaps(tt.o); got != tt.overlaps {
+			t.Errorf("%s.Overlaps(%s) = %v, want %v", r, tt.o, got, tt.overlaps)
+		}
+	}
+}
+
+func TestTOCRegion(t *testing.T) {
+	dev := &targets.DeviceInfo{MRAMBase: 0x80000000, AppSize: 0x57f000}
+	dir := t.TempDir()
+	withStart := filepath.Join(dir, "with-start.map")
+	if err := os.WriteFile(withStart, []byte("APP Package Start Address: 0x8057E000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pastEnd := filepath.Join(dir, "past-end.map")
+	if err := os.WriteFile(pastEnd, []byte("APP Package Start Address: 0x90000000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, mapPath string
+		want          Region
+	}{
+		{"no map", "", Region{0x8057e000, 0x8057f000}},
+		{"missing map", filepath.Join(dir, "none.map"), Region{0x8057e000, 0x8057f000}},
+		{"map start", withStart, Region{0x8057e000, 0x8057f000}},
+		{"start past the area", pastEnd, Region{0x8057e000, 0x8057f000}},
+	}
+	for _, tt := range tests {
+		if got := TOCRegion(dev, tt.mapPath); got != tt.want {
+			t.Errorf("%s: TOCRegion = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	lower := filepath.Join(dir, "lower.map")
+	if err := os.WriteFile(lower, []byte("Other: 1\nAPP Package Start Address: 0x80570000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := TOCRegion(dev, lower), (Region{0x80570000, 0x8057f000}); got != want {
+		t.Errorf("lower map start: TOCRegion = %s, want %s", got, want)
+	}
+}
+
+func TestValidateRawWrite(t *testing.T) {
+	app := Region{Start: 0x80000000, End: 0x8057f000}
+	toc := Region{Start: 0x8057e000, End: 0x8057f000}
+	tests := []struct {
+		name      string
+		addr      uint64
+		size      uint64
+		force     bool
+		errSubstr string
+	}{
+		{"inside", 0x80300000, 0x1000, false, ""},
+		{"ends at the TOC", 0x8057d000, 0x1000, false, ""},
+		{"empty", 0x80300000, 0, false, "nothing to write"},
+		{"before the area", 0x7ffff000, 0x2000, false, "outside"},
+		{"past the area", 0x8057f000, 0x10, false, "outside"},
+		{"into the TOC", 0x8057d800, 0x1000, false, "--force"},
+		{"into the TOC forced", 0x8057d800, 0x1000, true, ""},
+		{"past the area forced", 0x8057f000, 0x10, true, "outside"},
+	}
+	for _, tt := range tests {
+		err := ValidateRawWrite(tt.addr, tt.size, app, toc, tt.force)
+		switch {
+		case tt.errSubstr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.errSubstr != "" && (err == nil || !strings.Contains(err.Error(), tt.errSubstr)):
+			t.Errorf("%s: error = %v, want one mentioning %q", tt.name, err, tt.errSubstr)
+		}
+	}
+}
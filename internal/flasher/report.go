@@ -0,0 +1,125 @@
+package flasher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"alif-cli/internal/audit"
+)
+
+// Report statuses.
+const (
+	ReportSuccess = "success"
+	ReportFailed  = "failed"
+)
+
+// FlashReport records what a flash wrote to a board, for provisioning
+// records (--report). Fields that were not known when the flash ended are
+// left out.
+type FlashReport struct {
+	Port   string `json:"port,omitempty"`
+	Serial string `json:"usb_serial,omitempty"`
+	Method string `json:"method"`
+	// Target is the part, Core the core on it (e.g. AE722F80F55D5LS and
+	// M55_HE).
+	Target         string      `json:"target,omitempty"`
+	Core           string      `json:"core,omitempty"`
+	Image          *ReportFile `json:"image,omitempty"`
+	TOC            *ReportFile `json:"toc,omitempty"`
+	ToolkitVersion string      `json:"toolkit_version,omitempty"`
+	StartedAt      time.Time   `json:"started_at"`
+	FinishedAt     time.Time   `json:"finished_at"`
+	DurationMS     int64       `json:"duration_ms"`
+	Status         string      `json:"status"`
+	Error          string      `json:"error,omitempty"`
+}
+
+// ReportFile is one artifact in a FlashReport.
+type ReportFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	// Address is the MRAM load address, when it could be resolved.
+	Address string `json:"address,omitempty"`
+}
+
+// NewFlashReport starts a report for a flash with method.
+func NewFlashReport(method string) *FlashReport {
+	return &FlashReport{Method: method, StartedAt: time.Now()}
+}
+
+// SetTarget records target, splitting a "part:core" pair.
+func (r *FlashReport) SetTarget(target string) {
+	r.Target, r.Core, _ = strings.Cut(target, ":")
+}
+
+// SetPort records the port and the USB serial number behind it.
+func (r *FlashReport) SetPort(port string) {
+	r.Port = port
+	r.Serial = PortSerialNumber(port)
+}
+
+// DescribeArtifacts adds the image and TOC at binPath and tocPath (either
+// may be empty) to r, with the load addresses f would resolve for them.
+func (f *Flasher) DescribeArtifacts(r *FlashReport, binPath, tocPath, target string) {
+	var plan *AddressPlan
+	if tocPath != "" {
+		plan, _ = f.PlanAddresses(filepath.Dir(tocPath), target)
+	}
+	if binPath != "" && f.Only != OnlyTOC {
+		r.Image = describeFile(binPath)
+		if r.Image != nil && plan != nil {
+			r.Image.Address = plan.App
+		}
+	}
+	if tocPath != "" && f.Only != OnlyApp {
+		r.TOC = describeFile(tocPath)
+		if r.TOC != nil && plan != nil {
+			r.TOC.Address = plan.TOC
+		}
+	}
+}
+
+// describeFile hashes path, or returns nil when it cannot be read.
+func describeFile(path string) *ReportFile {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, file)
+	if err != nil {
+		return nil
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return &ReportFile{Path: path, Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}
+}
+
+// Finish stamps the end of the flash and its outcome; err is nil for a
+// successful one.
+func (r *FlashReport) Finish(err error) {
+	r.FinishedAt = time.Now()
+	r.DurationMS = r.FinishedAt.Sub(r.StartedAt).Milliseconds()
+	r.Status, r.Error = ReportSuccess, ""
+	if err != nil {
+		r.Status, r.Error = ReportFailed, err.Error()
+	}
+}
+
+// Write saves r as indented JSON at path.
+func (r *FlashReport) Write(path string) error {
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return audit.WriteFileAtomic(path, append(content, '\n'), 0644)
+}
@@ -0,0 +1,120 @@
+package flasher
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// What happens to the target once flashing is done (--reset).
+const (
+	// ResetRun resets the target and lets the new image run.
+	ResetRun = "run"
+	// ResetHalt resets the target and keeps the core halted, so a debugger
+	// can attach at the first instruction.
+	ResetHalt = "halt"
+	// ResetNone leaves the target as the flashing tool left it.
+	ResetNone = "none"
+)
+
+// serialResetPulse is how long DTR and RTS are held asserted.
+const serialResetPulse = 100 * time.Millisecond
+
+// ParseReset validates a --reset value; empty means ResetRun.
+func ParseReset(mode string) (string, error) {
+	switch m := strings.ToLower(mode); m {
+	case "":
+		return ResetRun, nil
+	case ResetRun, ResetHalt, ResetNone:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown reset mode '%s' (use %s, %s or %s)", mode, ResetRun, ResetHalt, ResetNone)
+}
+
+// resetMode returns f.Reset, defaulting to ResetRun.
+func (f *Flasher) resetMode() string {
+	if f.Reset == "" {
+		return ResetRun
+	}
+	return f.Reset
+}
+
+// describeReset names what a probe does for mode, for the output.
+func describeReset(mode string) string {
+	switch mode {
+	case ResetHalt:
+		return "reset and halt (core waits for a debugger)"
+	case ResetNone:
+		return "none (core left as the probe left it)"
+	}
+	return "reset and run"
+}
+
+// jlinkResetCommands end a J-Link flash script: J-Link Commander's r
+// resets and halts the core, and g lets it run.
+func jlinkResetCommands(mode string) string {
+	switch mode {
+	case ResetHalt:
+		return "r\n"
+	case ResetNone:
+		return ""
+	}
+	return "r\ng\n"
+}
+
+// openOCDResetCommands end an OpenOCD flash script.
+func openOCDResetCommands(mode string) []string {
+	switch mode {
+	case ResetHalt:
+		return []string{"reset halt"}
+	case ResetNone:
+		return nil
+	}
+	return []string{"reset run"}
+}
+
+// ResetAfterISP applies f.Reset once an ISP write succeeded. The ISP link
+// has no reset of its own, so ResetRun pulses DTR and RTS on port, which
+// restarts boards that wire either line to the reset pin. A core cannot be
+// held halted over ISP. Failures only warn: the image is already written.
+func (f *Flasher) ResetAfterISP(port string) {
+	switch f.resetMode() {
+	case ResetNone:
+		f.Report.Item("Reset", "none (board left as app-write-mram left it)")
+		return
+	case ResetHalt:
+		f.Report.Warn("--reset halt needs a debug probe; over ISP the board was left as app-write-mram left it")
+		return
+	}
+	if f.DryRun {
+		f.Report.Item("Would reset", fmt.Sprintf("by pulsing DTR/RTS on %s", port))
+		return
+	}
+	f.InvalidateDeviceIdentity(port)
+	if err := f.pulseResetLines(port); err != nil {
+		f.Report.Warn(fmt.Sprintf("Could not reset the board over %s: %v; power-cycle it to start the new image", port, err))
+		return
+	}
+	f.Report.Item("Reset", fmt.Sprintf("DTR/RTS pulsed on %s", port))
+}
+
+// pulseResetLines opens port briefly, asserts DTR and RTS, then releases
+// them.
+func (f *Flasher) pulseResetLines(port string) error {
+	p, closePort, err := f.openPort(port, f.ispBaud())
+	if err != nil {
+		return err
+	}
+	defer closePort()
+	if err := p.SetDTR(true); err != nil {
+		return err
+	}
+	if err := p.SetRTS(true); err != nil {
+		return err
+	}
+	time.Sleep(serialResetPulse)
+	if err := p.SetRTS(false); err != nil {
+		return err
+	}
+	return p.SetDTR(false)
+}
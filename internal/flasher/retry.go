@@ -0,0 +1,35 @@
+package flasher
+
+import "strings"
+
+// retryableISPErrors are app-write-mram messages that usually go away when
+// the write is repeated without dynamic baud rate switching (-s). They are
+// matched case-insensitively against the tool's output.
+var retryableISPErrors = []string{
+	"Target did not respond",
+	"timed out",
+	"timeout",
+}
+
+// RetryableISPError returns the first known transient error found in
+// output, or "" when the failure is not one a slower rerun would fix.
+func RetryableISPError(output string) string {
+	lower := strings.ToLower(output)
+	for _, msg := range retryableISPErrors {
+		if strings.Contains(lower, strings.ToLower(msg)) {
+			return msg
+		}
+	}
+	return ""
+}
+
+// noResponseISPError is what app-write-mram prints when the SE never
+// answers the ISP handshake, e.g. because a running application has
+// reconfigured the SE-UART pins.
+const noResponseISPError = "Target did not respond"
+
+// NoResponseISPError reports whether output shows the ISP handshake went
+// unanswered.
+func NoResponseISPError(output string) bool {
+	return strings.Contains(strings.ToLower(output), strings.ToLower(noResponseISPError))
+}
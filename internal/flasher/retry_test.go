@@ -0,0 +1,104 @@
+package flasher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"alif-cli/internal/config"
+	"alif-cli/internal/ui"
+)
+
+func TestRetryableISPError(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"Connecting...\nTarget did not respond\n", "Target did not respond"},
+		{"ERROR: TARGET DID NOT RESPOND", "Target did not respond"},
+		{"ISP read timed out after 5s", "timed out"},
+		{"serial timeout", "timeout"},
+		{"Error: could not open port /dev/ttyACM0", ""},
+		{"Authentication failed", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := RetryableISPError(tt.output); got != tt.want {
+			t.Errorf("RetryableISPError(%q) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestNoResponseISPError(t *testing.T) {
+	tests := []struct {
+		output string
+		want   bool
+	}{
+		{"Target did not respond", true},
+		{"target did not respond\r\n", true},
+		{"ISP read timed out", false},
+	}
+	for _, tt := range tests {
+		if got := NoResponseISPError(tt.output); got != tt.want {
+			t.Errorf("NoResponseISPError(%q) = %v, want %v", tt.output, got, tt.want)
+		}
+	}
+}
+
+// failingWriteMRAM is an app-write-mram that logs its arguments and fails
+// with message unless it runs with -s.
+func failingWriteMRAM(message string) string {
+	return `#!/bin/sh
+echo "$*" >> writes
+case " $* " in *" -s "*) echo Done; exit 0;; esac
+echo '` + message + `'
+exit 1
+`
+}
+
+func TestFlashRetriesSlow(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		noRetry bool
+		writes  []string
+		wantErr bool
+	}{
+		{"transient error", "Target did not respond", false, []string{"-p", "-p -s"}, false},
+		{"timeout", "ISP read timed out", false, []string{"-p", "-p -s"}, false},
+		{"retry disabled", "ISP read timed out", true, []string{"-p"}, true},
+		{"other error", "Authentication failed", false, []string{"-p"}, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("HOME", t.TempDir())
+		t.Setenv("PATH", "/usr/bin:/bin") // no J-Link to assist with
+		tk := scriptedToolkit(t)
+		if err := os.WriteFile(filepath.Join(tk, "app-write-mram"), []byte(failingWriteMRAM(tt.message)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		build := t.TempDir()
+		for _, name := range []string{"alif-img.bin", "AppTocPackage.bin"} {
+			if err := os.WriteFile(filepath.Join(build, name), []byte(name), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		port := filepath.Join(t.TempDir(), "ttyACM0")
+		if err := os.WriteFile(port, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		f := New(&config.Config{AlifToolsPath: tk})
+		f.Report = ui.Silent
+		f.NoRetry = tt.noRetry
+		err := f.Flash(filepath.Join(build, "alif-img.bin"), filepath.Join(build, "AppTocPackage.bin"),
+			Options{Method: "ISP", Port: port, Target: "AE722F80F55D5LS:M55_HE"})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		log, _ := os.ReadFile(filepath.Join(tk, "writes"))
+		if got := strings.Split(strings.TrimSpace(string(log)), "\n"); strings.Join(got, "|") != strings.Join(tt.writes, "|") {
+			t.Errorf("%s: app-write-mram runs %q, want %q", tt.name, got, tt.writes)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package flasher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"alif-cli/internal/config"
+)
+
+// DefaultToolTimeout bounds a single app-write-mram or J-Link run unless
+// flash_timeout or --timeout says otherwise.
+const DefaultToolTimeout = 120 * time.Second
+
+// terminateGrace is how long a tool gets to exit after SIGTERM before it is
+// killed. app-write-mram is a PyInstaller bundle whose bootloader forwards
+// SIGTERM to the Python process, so it is tried first.
+const terminateGrace = 3 * time.Second
+
+// ErrInterrupted is returned when Ctrl-C stopped a tool.
+var ErrInterrupted = errors.New("interrupted")
+
+// TimeoutError is returned when a tool ran longer than the timeout.
+type TimeoutError struct {
+	Tool  string
+	After time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s did not finish within %s and was stopped", e.Tool, e.After)
+}
+
+// ToolTimeout returns flash_timeout from cfg, or DefaultToolTimeout when it
+// is unset or invalid.
+func ToolTimeout(cfg *config.Config) time.Duration {
+	if cfg != nil && cfg.FlashTimeout != "" {
+		if d, err := time.ParseDuration(cfg.FlashTimeout); err == nil && d > 0 {
+			return d
+		}
+	}
+	return DefaultToolTimeout
+}
+
+// RunTool runs cmd like cmd.Run, but stops it after f.Timeout (zero means
+// no limit) or when Ctrl-C is pressed, so a tool waiting on an unresponsive
+// board is not left running. With DryRun the command line is printed and
+// nothing runs.
+func (f *Flasher) RunTool(cmd *exec.Cmd) error {
+	if f.DryRun {
+		f.Report.Item("Would run", commandLine(cmd))
+		return nil
+	}
+	f.Report.Debug("run: " + commandLine(cmd))
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	// A child of the tool may keep the output pipes open after it is gone.
+	cmd.WaitDelay = terminateGrace
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	var deadline <-chan time.Time
+	if f.Timeout > 0 {
+		timer := time.NewTimer(f.Timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			f.Report.Debug(fmt.Sprintf("%s: %v", filepath.Base(cmd.Path), err))
+		}
+		return err
+	case <-deadline:
+		terminate(cmd, done)
+		return &TimeoutError{Tool: filepath.Base(cmd.Path), After: f.Timeout}
+	case <-interrupt:
+		terminate(cmd, done)
+		return ErrInterrupted
+	}
+}
+
+// commandLine formats cmd for display, quoting arguments that contain
+// spaces.
+func commandLine(cmd *exec.Cmd) string {
+	parts := []string{cmd.Path}
+	for _, arg := range cmd.Args[1:] {
+		if arg == "" || strings.ContainsAny(arg, " \t\"") {
+			arg = fmt.Sprintf("%q", arg)
+		}
+		parts = append(parts, arg)
+	}
+	line := strings.Join(parts, " ")
+	if cmd.Dir != "" {
+		line += fmt.Sprintf(" (in %s)", cmd.Dir)
+	}
+	return line
+}
+
+// terminate asks the process to exit, then kills it after terminateGrace.
+func terminate(cmd *exec.Cmd, done <-chan error) {
+	if runtime.GOOS != "windows" {
+		if cmd.Process.Signal(syscall.SIGTERM) == nil {
+			select {
+			case <-done:
+				return
+			case <-time.After(terminateGrace):
+			}
+		}
+	}
+	cmd.Process.Kill()
+	<-done
+}
+
+// ReportTimeout explains a timed-out ISP run; other errors are ignored.
+func (f *Flasher) ReportTimeout(err error) {
+	var te *TimeoutError
+	if errors.As(err, &te) {
+		f.Report.Warn("The board did not answer the ISP handshake. Reset it (or run `alif recover` over J-Link) to get it into ISP mode, then retry; raise the limit with --timeout or flash_timeout if the write is just slow.")
+	}
+}
@@ -0,0 +1,116 @@
+package flasher
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"alif-cli/internal/config"
+	"alif-cli/internal/signer"
+	"alif-cli/internal/ui"
+)
+
+// scriptedToolkit is a Security Toolkit whose app-gen-toc and
+// app-write-mram are shell scripts that write plausible artifacts and
+// output, so a sign and flash can run end to end without a board.
+func scriptedToolkit(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	root := t.TempDir()
+	files := map[string]string{
+		"utils/global-cfg.db": `{"DEVICE": {"Part#": "E7 (AE722F80F55D5LS) - 5.5 MRAM / 13.5 SRAM", "Revision": "B2"}}`,
+		"isp_config_data.cfg": "comport /dev/ttyACM0\nbaudrate 115200\n",
+		"app-gen-toc": `#!/bin/sh
+mkdir -p build
+printf 'TOC' > build/AppTocPackage.bin
+printf 'APP Package Start Address: 0x8057F000\n0x80000000  0x00000010  alif-img.bin\n' > build/app-package-map.txt
+echo 'Device Part# E7 (AE722F80F55D5LS) - 5.5 MRAM / 13.5 SRAM - Rev: B2'
+echo 'Done!'
+`,
+		"app-write-mram": `#!/bin/sh
+echo 'Burning: alif-img.bin'
+echo '[####################] 100%'
+echo 'Done'
+`,
+	}
+	for rel, content := range files {
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// captureStdio redirects stdout and stderr to files for the rest of the
+// test and returns a function reporting what was written to them.
+func captureStdio(t *testing.T) func() string {
+	t.Helper()
+	out, err := os.CreateTemp(t.TempDir(), "stdio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldOut, oldErr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = out, out
+	t.Cleanup(func() { os.Stdout, os.Stderr = oldOut, oldErr; out.Close() })
+	return func() string {
+		os.Stdout, os.Stderr = oldOut, oldErr
+		out.Seek(0, io.SeekStart)
+		b, _ := io.ReadAll(out)
+		return string(b)
+	}
+}
+
+func TestSilentSignAndFlash(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	tk := scriptedToolkit(t)
+	cfg := &config.Config{AlifToolsPath: tk}
+
+	project := t.TempDir()
+	buildDir := filepath.Join(project, "out")
+	if err := os.MkdirAll(buildDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	bin := filepath.Join(buildDir, "blinky.bin")
+	if err := os.WriteFile(bin, []byte("0123456789abcdef"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	targetCfg := filepath.Join(project, "he.json")
+	if err := os.WriteFile(targetCfg, []byte(`{"USER_APP": {"binary": "alif-img.bin", "mramAddress": "0x80000000", "cpu_id": "M55_HE"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	port := filepath.Join(t.TempDir(), "ttyACM0")
+	if err := os.WriteFile(port, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	written := captureStdio(t)
+
+	s := signer.New(cfg)
+	s.Report = ui.Silent
+	artifacts, err := s.SignArtifact(project, buildDir, bin, "", "", targetCfg)
+	if err != nil {
+		written()
+		t.Fatalf("sign: %v", err)
+	}
+
+	f := New(cfg)
+	f.Report = ui.Silent
+	f.NoProbe = true
+	f.NoStablePath = true
+	err = f.Flash(artifacts.Image, artifacts.TOC, Options{Method: "ISP", Port: port, Target: "AE722F80F55D5LS:M55_HE"})
+
+	if out := written(); out != "" {
+		t.Errorf("silent sign and flash printed:\n%s", out)
+	}
+	if err != nil {
+		t.Fatalf("flash: %v", err)
+	}
+}
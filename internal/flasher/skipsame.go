@@ -0,0 +1,55 @@
+package flasher
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// sameWindow is how much of the image and of the TOC package --skip-if-same
+// reads back: enough to cover the image header and the TOC header, small
+// enough to read in well under a second.
+const sameWindow = 1024
+
+// deviceUpToDate reports whether the start of the image and the TOC in
+// MRAM already match the artifacts, read back over J-Link (--skip-if-same).
+// Without J-Link, or when anything cannot be read or resolved, it returns
+// false so the board is flashed as usual.
+func (f *Flasher) deviceUpToDate(binPath, tocPath, buildDir, target string) bool {
+	if _, err := f.jlinkExecutable(); err != nil {
+		f.Report.Item("Skip If Same", "no J-Link, flashing")
+		return false
+	}
+	plan, err := f.PlanAddresses(buildDir, target)
+	if err != nil {
+		f.Report.Warn(fmt.Sprintf("Cannot compare with the device: %v; flashing", err))
+		return false
+	}
+	device, script := f.resolveJLinkConfig(buildDir, target)
+	regions := []struct{ path, addr string }{{binPath, plan.App}, {tocPath, plan.TOC}}
+
+	sp := f.Report.StartTask("Comparing with the image on the device...")
+	for _, r := range regions {
+		if (f.Only == OnlyApp && r.path != binPath) || (f.Only == OnlyTOC && r.path != tocPath) {
+			continue
+		}
+		want, err := os.ReadFile(r.path)
+		if err != nil {
+			sp.Fail(fmt.Sprintf("Cannot read %s; flashing", filepath.Base(r.path)))
+			return false
+		}
+		want = want[:min(len(want), sameWindow)]
+		got, err := f.readBackJLink(device, script, r.addr, len(want))
+		if err != nil {
+			sp.Fail(fmt.Sprintf("Readback failed (%v); flashing", err))
+			return false
+		}
+		if sha256.Sum256(want) != sha256.Sum256(got) {
+			sp.Succeed(fmt.Sprintf("%s differs from the device; flashing", filepath.Base(r.path)))
+			return false
+		}
+	}
+	sp.Succeed("Device already up to date")
+	return true
+}
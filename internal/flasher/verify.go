@@ -0,0 +1,211 @@
+package flasher
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"alif-cli/internal/audit"
+)
+
+// ispReadbackSize is how much of the image is read back over J-Link after
+// an ISP flash.
+const ispReadbackSize = 16 * 1024
+
+// mismatchWindow is how many bytes a mismatch report shows.
+const mismatchWindow = 16
+
+// Mismatch describes where two byte sequences first differ.
+type Mismatch struct {
+	Offset   int
+	Expected []byte
+	Actual   []byte
+	// ExpectedSize and ActualSize are set when the lengths differ.
+	ExpectedSize int
+	ActualSize   int
+}
+
+func (m *Mismatch) String() string {
+	if m.ExpectedSize != m.ActualSize && m.Expected == nil {
+		return fmt.Sprintf("size differs: expected %d bytes, got %d", m.ExpectedSize, m.ActualSize)
+	}
+	return fmt.Sprintf("first mismatch at offset 0x%X: expected % x, got % x", m.Offset, m.Expected, m.Actual)
+}
+
+// CompareBytes returns nil when actual equals expected, or the first
+// difference. A length difference is reported only when the common prefix
+// matches.
+func CompareBytes(expected, actual []byte) *Mismatch {
+	n := len(expected)
+	if len(actual) < n {
+		n = len(actual)
+	}
+	for i := 0; i < n; i++ {
+		if expected[i] != actual[i] {
+			end := i + mismatchWindow
+			return &Mismatch{
+				Offset:   i,
+				Expected: expected[i:min(end, len(expected))],
+				Actual:   actual[i:min(end, len(actual))],
+			}
+		}
+	}
+	if len(expected) != len(actual) {
+		return &Mismatch{Offset: n, ExpectedSize: len(expected), ActualSize: len(actual)}
+	}
+	return nil
+}
+
+// verifyISP checks an ISP flash. With J-Link Commander installed the start
+// of the image is read back from MRAM; otherwise the toolkit's staged copies
+// are compared with the build artifacts, which catches a stale TOC or image
+// being written.
+func (f *Flasher) verifyISP(binPath, tocPath, target string) error {
+	buildDir := filepath.Dir(tocPath)
+	if _, err := f.jlinkExecutable(); err == nil {
+		plan, err := f.PlanAddresses(buildDir, target)
+		if err == nil {
+			device, script := f.resolveJLinkConfig(buildDir, target)
+			path, addr := binPath, plan.App
+			if f.Only == OnlyTOC {
+				path, addr = tocPath, plan.TOC
+			}
+			return f.verifyReadback(path, addr, device, script, ispReadbackSize)
+		}
+		f.Report.Warn(fmt.Sprintf("Cannot read back over J-Link: %v", err))
+	}
+
+	f.Report.Item("Verify", "staged toolkit copies (no J-Link readback)")
+	staged := []struct{ artifact, copy string }{
+		{binPath, filepath.Join(f.Cfg.AlifToolsPath, "build", "images", "alif-img.bin")},
+		{tocPath, filepath.Join(f.Cfg.AlifToolsPath, "AppTocPackage.bin")},
+		{tocPath, filepath.Join(f.Cfg.AlifToolsPath, "build", "AppTocPackage.bin")},
+	}
+	for _, s := range staged {
+		if (f.Only == OnlyApp && s.artifact != binPath) || (f.Only == OnlyTOC && s.artifact != tocPath) {
+			continue
+		}
+		if err := compareStaged(s.artifact, s.copy); err != nil {
+			return err
+		}
+	}
+	f.Report.Success("Staged files match the build artifacts")
+	return nil
+}
+
+// compareStaged checks that the toolkit copy of an artifact is identical and
+// not older than the artifact itself.
+func compareStaged(artifact, staged string) error {
+	want, err := os.ReadFile(artifact)
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	got, err := os.ReadFile(staged)
+	if err != nil {
+		return fmt.Errorf("verify: staged copy missing: %w", err)
+	}
+	if m := CompareBytes(want, got); m != nil {
+		return fmt.Errorf("verify: %s does not match %s: %s", staged, artifact, m)
+	}
+	ai, err1 := os.Stat(artifact)
+	si, err2 := os.Stat(staged)
+	if err1 == nil && err2 == nil && si.ModTime().Before(ai.ModTime()) {
+		return fmt.Errorf("verify: %s is older than %s", staged, artifact)
+	}
+	return nil
+}
+
+// VerifyFailures returns the files whose verifybin failed in J-Link
+// Commander output. J-Link exits 0 either way, so the output is the only
+// signal. A failure that cannot be tied to a command is reported as "".
+func VerifyFailures(output string) []string {
+	var failed []string
+	current := ""
+	for _, line := range strings.Split(output, "\n") {
+		lower := strings.ToLower(line)
+		if i := strings.Index(lower, "verifybin "); i != -1 {
+			if fields := strings.Fields(line[i+len("verifybin "):]); len(fields) > 0 {
+				current = fields[0]
+			}
+		}
+		if strings.Contains(lower, "verify failed") && !containsPath(failed, current) {
+			failed = append(failed, current)
+		}
+	}
+	return failed
+}
+
+func containsPath(paths []string, p string) bool {
+	for _, q := range paths {
+		if q == p {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyReadback reads up to limit bytes of path's region (0 = whole file)
+// from the target over J-Link and compares them with the file.
+func (f *Flasher) verifyReadback(path, addr, device, scriptPathOverride string, limit int) error {
+	want, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	if limit > 0 && len(want) > limit {
+		want = want[:limit]
+	}
+
+	sp := f.Report.StartTask(fmt.Sprintf("Reading back %s at %s...", filepath.Base(path), addr))
+	got, err := f.readBackJLink(device, scriptPathOverride, addr, len(want))
+	if err != nil {
+		sp.Fail("Readback failed")
+		return err
+	}
+	if m := CompareBytes(want, got); m != nil {
+		sp.Fail(fmt.Sprintf("%s does not match", filepath.Base(path)))
+		return fmt.Errorf("verify: %s at %s: %s", filepath.Base(path), addr, m)
+	}
+	sp.Succeed(fmt.Sprintf("%s matches (%d bytes)", filepath.Base(path), len(want)))
+	return nil
+}
+
+// readBackJLink reads size bytes at addr with J-Link Commander's savebin.
+func (f *Flasher) readBackJLink(device, scriptPathOverride, addr string, size int) ([]byte, error) {
+	out, err := os.CreateTemp("", "alif-readback-*.bin")
+	if err != nil {
+		return nil, err
+	}
+	out.Close()
+	defer os.Remove(out.Name())
+
+	link, err := f.jlinkSettings(scriptPathOverride)
+	if err != nil {
+		return nil, err
+	}
+	content := jlinkCommands(link, device, fmt.Sprintf("savebin %s %s 0x%X", out.Name(), addr, size))
+	scriptPath, err := audit.CreateTemp("alif-readback-*.jlink", content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create J-Link script: %w", err)
+	}
+	defer os.Remove(scriptPath)
+
+	cmd, err := f.jlinkCommand(scriptPathOverride, scriptPath)
+	if err != nil {
+		return nil, err
+	}
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := f.RunTool(cmd); err != nil {
+		f.Report.Output(output.String())
+		return nil, fmt.Errorf("J-Link readback failed: %w", err)
+	}
+	data, err := os.ReadFile(out.Name())
+	if err != nil || len(data) == 0 {
+		f.Report.Output(output.String())
+		return nil, fmt.Errorf("J-Link readback produced no data")
+	}
+	return data, nil
+}
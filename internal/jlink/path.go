@@ -0,0 +1,79 @@
+package jlink
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// InstallDirs returns the directories SEGGER's installers use on this
+// platform, newest versioned folder (e.g. JLink_V794) first.
+func InstallDirs() []string {
+	var roots []string
+	switch runtime.GOOS {
+	case "windows":
+		roots = []string{`C:\Program Files\SEGGER`, `C:\Program Files (x86)\SEGGER`}
+	case "darwin":
+		roots = []string{"/Applications/SEGGER"}
+	default:
+		roots = []string{"/opt/SEGGER"}
+	}
+
+	var dirs []string
+	for _, root := range roots {
+		dirs = append(dirs, filepath.Join(root, "JLink"))
+		versioned, _ := filepath.Glob(filepath.Join(root, "JLink_V*"))
+		sort.Sort(sort.Reverse(sort.StringSlice(versioned)))
+		dirs = append(dirs, versioned...)
+	}
+	if runtime.GOOS == "darwin" {
+		dirs = append(dirs, "/usr/local/bin", "/opt/homebrew/bin")
+	} else if runtime.GOOS != "windows" {
+		dirs = append(dirs, "/usr/bin", "/usr/local/bin")
+	}
+	return dirs
+}
+
+// Resolve returns the J-Link Commander to run: configured (jlink_path, the
+// executable or its folder) when set, otherwise the one on PATH, otherwise
+// one in a default install folder. The error lists every location tried.
+func Resolve(configured string) (string, error) {
+	name := Executable()
+	var tried []string
+
+	if configured != "" {
+		candidate := configured
+		if info, err := os.Stat(configured); err == nil && info.IsDir() {
+			candidate = filepath.Join(configured, name)
+		}
+		if isExecutable(candidate) {
+			return candidate, nil
+		}
+		return "", fmt.Errorf("%s not found at %s (jlink_path); fix it with 'alif config set jlink_path <path>'", name, candidate)
+	}
+
+	if p, err := exec.LookPath(name); err == nil {
+		return p, nil
+	}
+	tried = append(tried, "PATH")
+	for _, dir := range InstallDirs() {
+		candidate := filepath.Join(dir, name)
+		if isExecutable(candidate) {
+			return candidate, nil
+		}
+		tried = append(tried, dir)
+	}
+	return "", fmt.Errorf("%s not found (tried %s); install the SEGGER J-Link Software or set jlink_path", name, strings.Join(tried, ", "))
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	return runtime.GOOS == "windows" || info.Mode()&0111 != 0
+}
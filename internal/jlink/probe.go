@@ -0,0 +1,178 @@
+// Package jlink identifies the connected SEGGER J-Link probe and the
+// connection defaults that suit it.
+package jlink
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"alif-cli/internal/audit"
+)
+
+// Profile holds the connection defaults for a probe model.
+type Profile struct {
+	Name string
+	// Speed is the SWD/JTAG clock in kHz.
+	Speed int
+	// Interface is the target interface ("SWD" or "JTAG").
+	Interface string
+	// NeedsScript is set when the probe only connects reliably with the
+	// device's J-Link reset script (JLinkScriptFile).
+	NeedsScript bool
+}
+
+// ModelProfile maps probe models to a profile. A model matches when it
+// contains any of the Match strings (case-insensitive).
+type ModelProfile struct {
+	Match   []string
+	Profile Profile
+}
+
+// Profiles are checked in order; the first match wins.
+var Profiles = []ModelProfile{
+	// The DevKit's on-board probe drops connections above 2 MHz and needs
+	// the reset script to attach after a reset.
+	{Match: []string{"J-Link OB", " OB-"}, Profile: Profile{Name: "J-Link OB", Speed: 2000, Interface: "SWD", NeedsScript: true}},
+	{Match: []string{"PLUS"}, Profile: Profile{Name: "J-Link PLUS", Speed: 4000, Interface: "SWD"}},
+	{Match: []string{"ULTRA"}, Profile: Profile{Name: "J-Link ULTRA+", Speed: 4000, Interface: "SWD"}},
+	{Match: []string{"PRO"}, Profile: Profile{Name: "J-Link PRO", Speed: 4000, Interface: "SWD"}},
+	{Match: []string{"EDU"}, Profile: Profile{Name: "J-Link EDU", Speed: 4000, Interface: "SWD"}},
+	{Match: []string{"BASE"}, Profile: Profile{Name: "J-Link BASE", Speed: 4000, Interface: "SWD"}},
+}
+
+// Default is used when the probe cannot be identified.
+var Default = Profile{Name: "default", Speed: 4000, Interface: "SWD"}
+
+// ProfileFor returns the profile for a probe model such as
+// "J-Link OB-K22-Cortex-M" or "J-Link PLUS".
+func ProfileFor(model string) Profile {
+	upper := strings.ToUpper(model)
+	for _, mp := range Profiles {
+		for _, m := range mp.Match {
+			if strings.Contains(upper, strings.ToUpper(m)) {
+				return mp.Profile
+			}
+		}
+	}
+	return Default
+}
+
+// ParseModel extracts the first probe model from J-Link Commander output:
+// the ProductName of a ShowEmuList entry, or else the "Firmware:" banner
+// printed when Commander connects to the probe.
+func ParseModel(output string) string {
+	firmware := ""
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if _, name, ok := strings.Cut(line, "ProductName:"); ok {
+			name, _, _ = strings.Cut(name, ",")
+			if name = strings.TrimSpace(name); name != "" {
+				return name
+			}
+		}
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(line), "Firmware:"); ok && firmware == "" {
+			rest, _, _ = strings.Cut(rest, " compiled")
+			firmware = strings.TrimSpace(rest)
+		}
+	}
+	return firmware
+}
+
+// Executable is the J-Link Commander binary name for this platform; see
+// Resolve for its location.
+func Executable() string {
+	if runtime.GOOS == "windows" {
+		return "JLink.exe"
+	}
+	return "JLinkExe"
+}
+
+// detectTimeout bounds the emulator query.
+const detectTimeout = 10 * time.Second
+
+// Emulator is one probe listed by ShowEmuList.
+type Emulator struct {
+	Serial  string
+	Product string
+}
+
+// ParseEmulators extracts the probes from ShowEmuList output, e.g.
+// "J-Link[0]: Connection: USB, Serial number: 600101234, ProductName: J-Link OB-K22-Cortex-M".
+func ParseEmulators(output string) []Emulator {
+	var emus []Emulator
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.Contains(line, "Serial number:") {
+			continue
+		}
+		var e Emulator
+		for _, field := range strings.Split(line, ",") {
+			if _, v, ok := strings.Cut(field, "Serial number:"); ok {
+				e.Serial = strings.TrimSpace(v)
+			} else if _, v, ok := strings.Cut(field, "ProductName:"); ok {
+				e.Product = strings.TrimSpace(v)
+			}
+		}
+		if e.Serial != "" {
+			emus = append(emus, e)
+		}
+	}
+	return emus
+}
+
+// showEmuList runs J-Link Commander (exe) with ShowEmuList and returns its
+// output.
+func showEmuList(exe string) (string, error) {
+	script, err := audit.CreateTemp("alif-emulist-*.jlink", []byte("ShowEmuList\nqc\n"))
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(script)
+
+	ctx, cancel := context.WithTimeout(context.Background(), detectTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, exe, "-NoGui", "1", "-CommandFile", script)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil && output.Len() == 0 {
+		return "", err
+	}
+	return output.String(), nil
+}
+
+// Detect asks J-Link Commander (exe) for the attached emulators and
+// returns the model of the first one ("" when none is reported).
+func Detect(exe string) (string, error) {
+	output, err := showEmuList(exe)
+	if err != nil {
+		return "", err
+	}
+	return ParseModel(output), nil
+}
+
+// Emulators lists the probes J-Link Commander (exe) reports.
+func Emulators(exe string) ([]Emulator, error) {
+	output, err := showEmuList(exe)
+	if err != nil {
+		return nil, err
+	}
+	return ParseEmulators(output), nil
+}
+
+// SelectArgs returns the J-Link Commander arguments that pick the probe
+// with serial, or nil when serial is empty.
+func SelectArgs(serial string) []string {
+	if serial == "" {
+		return nil
+	}
+	return []string{"-USB", serial}
+}
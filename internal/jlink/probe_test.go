@@ -0,0 +1,82 @@
+package jlink
+
+import (
+	"reflect"
+	"testing"
+)
+
+// emuList is ShowEmuList output from J-Link Commander V7.94 with the
+// DevKit's on-board probe and an external probe attached.
+const emuList = `SEGGER J-Link Commander V7.94e (Compiled Jan 15 2024 15:19:52)
+DLL version V7.94e, compiled Jan 15 2024 15:19:16
+
+J-Link Command File read successfully.
+Processing script file...
+J-Link[0]: Connection: USB, Serial number: 600101234, ProductName: J-Link OB-K22-Cortex-M
+J-Link[1]: Connection: USB, Serial number: 50123456, ProductName: J-Link PLUS
+J-Link>qc
+`
+
+// connectBanner is what Commander prints when it opens the probe without
+// ShowEmuList.
+const connectBanner = `SEGGER J-Link Commander V7.94e (Compiled Jan 15 2024 15:19:52)
+DLL version V7.94e, compiled Jan 15 2024 15:19:16
+
+Connecting to J-Link via USB...O.K.
+Firmware: J-Link V11 compiled Dec  7 2023 10:22:59
+Hardware version: V11.00
+J-Link uptime (since boot): 0d 00h 03m 12s
+S/N: 50123456
+`
+
+func TestProfileFor(t *testing.T) {
+	ob := Profile{Name: "J-Link OB", Speed: 2000, Interface: "SWD", NeedsScript: true}
+	tests := []struct {
+		model string
+		want  Profile
+	}{
+		{"J-Link OB-K22-Cortex-M", ob},
+		{"J-Link OB-SAM3U128-V2-NordicSemi", ob},
+		{"j-link ob", ob},
+		{"J-Link PLUS", Profile{Name: "J-Link PLUS", Speed: 4000, Interface: "SWD"}},
+		{"J-Link ULTRA+", Profile{Name: "J-Link ULTRA+", Speed: 4000, Interface: "SWD"}},
+		{"J-Link PRO V5", Profile{Name: "J-Link PRO", Speed: 4000, Interface: "SWD"}},
+		{"J-Link EDU Mini", Profile{Name: "J-Link EDU", Speed: 4000, Interface: "SWD"}},
+		{"J-Link BASE Compact", Profile{Name: "J-Link BASE", Speed: 4000, Interface: "SWD"}},
+		{"J-Link V11", Default},
+		{"", Default},
+	}
+	for _, tt := range tests {
+		if got := ProfileFor(tt.model); got != tt.want {
+			t.Errorf("ProfileFor(%q) = %+v, want %+v", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestParseModel(t *testing.T) {
+	tests := []struct {
+		name, output, want string
+	}{
+		{"emulator list", emuList, "J-Link OB-K22-Cortex-M"},
+		{"connect banner", connectBanner, "J-Link V11"},
+		{"nothing attached", "SEGGER J-Link Commander V7.94e\nJ-Link>qc\n", ""},
+	}
+	for _, tt := range tests {
+		if got := ParseModel(tt.output); got != tt.want {
+			t.Errorf("%s: ParseModel = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseEmulators(t *testing.T) {
+	want := []Emulator{
+		{Serial: "600101234", Product: "J-Link OB-K22-Cortex-M"},
+		{Serial: "50123456", Product: "J-Link PLUS"},
+	}
+	if got := ParseEmulators(emuList); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseEmulators = %+v, want %+v", got, want)
+	}
+	if got := ParseEmulators(connectBanner); got != nil {
+		t.Errorf("ParseEmulators(banner) = %+v, want none", got)
+	}
+}
@@ -0,0 +1,246 @@
+// Package mapfile reads GNU ld linker map files.
+package mapfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Region is a MEMORY region from the "Memory Configuration" table.
+type Region struct {
+	Name   string
+	Origin uint64
+	Length uint64
+}
+
+// Contains reports whether addr lies inside the region.
+func (r Region) Contains(addr uint64) bool {
+	return addr >= r.Origin && addr-r.Origin < r.Length
+}
+
+// Section is an output section, or an input section placed inside one.
+type Section struct {
+	Name    string
+	Address uint64
+	Size    uint64
+	// Parent is the enclosing output section (empty for output sections).
+	Parent string
+	// Load is the load address of an output section stored somewhere
+	// other than where it runs, such as initialised data (0 otherwise).
+	Load uint64
+}
+
+// Map is the parsed content of a linker map file.
+type Map struct {
+	Regions []Region
+	// Output and Input hold sections with a non-zero size, in file order.
+	Output []Section
+	Input  []Section
+}
+
+// RegionOf returns the memory region containing addr, if any. The catch-all
+// *default* region is ignored.
+func (m *Map) RegionOf(addr uint64) (Region, bool) {
+	for _, r := range m.Regions {
+		if r.Name != "*default*" && r.Contains(addr) {
+			return r, true
+		}
+	}
+	return Region{}, false
+}
+
+// ParseFile reads and parses the map file at path.
+func ParseFile(path string) (*Map, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse reads a GNU ld map. Section names too long for their column are
+// wrapped by ld onto their own line; the address and size then follow on
+// the next line.
+func Parse(r io.Reader) (*Map, error) {
+	m := &Map{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	const (
+		none = iota
+		memory
+		layout
+	)
+	part := none
+	var pending string // wrapped section name awaiting its address line
+	var pendingInput bool
+	var parent string
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "Memory Configuration"):
+			part = memory
+			continue
+		case strings.HasPrefix(line, "Linker script and memory map"):
+			part = layout
+			continue
+		}
+
+		fields := strings.Fields(line)
+		switch part {
+		case memory:
+			if len(fields) >= 3 && fields[0] != "Name" {
+				origin, err1 := parseHex(fields[1])
+				length, err2 := parseHex(fields[2])
+				if err1 == nil && err2 == nil {
+					m.Regions = append(m.Regions, Region{Name: fields[0], Origin: origin, Length: length})
+				}
+			}
+
+		case layout:
+			if len(fields) == 0 {
+				pending = ""
+				continue
+			}
+
+			// Continuation of a wrapped name: "   0xADDR   0xSIZE [file]".
+			if pending != "" {
+				name, input := pending, pendingInput
+				pending = ""
+				if len(fields) >= 2 {
+					addr, err1 := parseHex(fields[0])
+					size, err2 := parseHex(fields[1])
+					if err1 == nil && err2 == nil {
+						parent = m.add(name, addr, size, loadAddress(fields[2:]), input, parent)
+						continue
+					}
+				}
+			}
+
+			input := strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "  ")
+			output := !strings.HasPrefix(line, " ")
+			if !input && !output {
+				continue
+			}
+			name := fields[0]
+			if strings.HasPrefix(name, "*") || name == "LOAD" || name == "OUTPUT(" {
+				continue
+			}
+			if len(fields) == 1 {
+				pending, pendingInput = name, input
+				continue
+			}
+			addr, err1 := parseHex(fields[1])
+			if err1 != nil {
+				continue
+			}
+			var size, load uint64
+			if len(fields) >= 3 {
+				if s, err := parseHex(fields[2]); err == nil {
+					size = s
+				}
+				load = loadAddress(fields[3:])
+			}
+			parent = m.add(name, addr, size, load, input, parent)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read map file: %w", err)
+	}
+	return m, nil
+}
+
+// add records a section and returns the output section now in effect.
+func (m *Map) add(name string, addr, size, load uint64, input bool, parent string) string {
+	if !input {
+		parent = name
+	}
+	if size == 0 {
+		return parent
+	}
+	s := Section{Name: name, Address: addr, Size: size}
+	if input {
+		s.Parent = parent
+		m.Input = append(m.Input, s)
+	} else {
+		s.Load = load
+		m.Output = append(m.Output, s)
+	}
+	return parent
+}
+
+// loadAddress reads the "load address 0xADDR" ld appends to an output
+// section line, or returns 0 when fields do not start with it.
+func loadAddress(fields []string) uint64 {
+	if len(fields) < 3 || fields[0] != "load" || fields[1] != "address" {
+		return 0
+	}
+	addr, err := parseHex(fields[2])
+	if err != nil {
+		return 0
+	}
+	return addr
+}
+
+// RegionUsage is how much of a memory region the output sections take.
+type RegionUsage struct {
+	Name string `json:"name"`
+	Used uint64 `json:"used"`
+	Size uint64 `json:"size"`
+}
+
+// nonAlloc are output sections that hold no target memory; debug sections
+// sit at address 0, inside ITCM on many parts.
+var nonAlloc = []string{".debug", ".comment", ".ARM.attributes", ".stab", ".gnu.attributes"}
+
+// Usage sums the output sections in each memory region, in the order of
+// the Memory Configuration table. A section with a load address in another
+// region counts there as well, as initialised data takes space in both.
+// Regions holding nothing are left out.
+func (m *Map) Usage() []RegionUsage {
+	used := make(map[string]uint64)
+	for _, s := range m.Output {
+		if isNonAlloc(s.Name) {
+			continue
+		}
+		r, ok := m.RegionOf(s.Address)
+		if ok {
+			used[r.Name] += s.Size
+		}
+		if s.Load == 0 {
+			continue
+		}
+		if lr, ok := m.RegionOf(s.Load); ok && lr.Name != r.Name {
+			used[lr.Name] += s.Size
+		}
+	}
+	var usage []RegionUsage
+	for _, r := range m.Regions {
+		if n := used[r.Name]; n > 0 {
+			usage = append(usage, RegionUsage{Name: r.Name, Used: n, Size: r.Length})
+		}
+	}
+	return usage
+}
+
+func isNonAlloc(name string) bool {
+	for _, prefix := range nonAlloc {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseHex(s string) (uint64, error) {
+	if !strings.HasPrefix(s, "0x") {
+		return 0, fmt.Errorf("not a hex value: %s", s)
+	}
+	return strconv.ParseUint(s[2:], 16, 64)
+}
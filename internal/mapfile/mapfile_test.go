@@ -0,0 +1,37 @@
+package mapfile
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseFile(t *testing.T) {
+	m, err := ParseFile(filepath.Join("testdata", "kws.map"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Regions) != 5 || m.Regions[3] != (Region{Name: "SRAM0", Origin: 0x02000000, Length: 0x00400000}) {
+		t.Errorf("regions = %+v", m.Regions)
+	}
+
+	want := []RegionUsage{
+		{Name: "DTCM", Used: 0x40000, Size: 0x40000},
+		{Name: "MRAM", Used: 0x58200, Size: 0x580000},
+		{Name: "SRAM0", Used: 0x180000, Size: 0x400000},
+	}
+	if got := m.Usage(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Usage = %+v, want %+v", got, want)
+	}
+
+	// The wrapped name is joined with the address line that follows it.
+	var wrapped *Section
+	for i, s := range m.Output {
+		if s.Name == ".bss.NoInit.activation_buf_sram" {
+			wrapped = &m.Output[i]
+		}
+	}
+	if wrapped == nil || wrapped.Address != 0x02000000 || wrapped.Size != 0x180000 {
+		t.Errorf("wrapped output section = %+v", wrapped)
+	}
+}
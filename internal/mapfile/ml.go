@@ -0,0 +1,160 @@
+package mapfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Kinds of ML memory recognized by AnalyzeML.
+const (
+	KindModel = "model"
+	KindArena = "arena"
+)
+
+// MLSectionsFile is the per-project file (in .alif) that adds section
+// name patterns to the built-in classification table.
+const MLSectionsFile = "ml_sections.json"
+
+// MLRules maps a kind to glob patterns matched against section names.
+type MLRules map[string][]string
+
+// DefaultMLRules covers the section names used by the Alif ML examples and
+// the common Ethos-U / TFLM conventions.
+func DefaultMLRules() MLRules {
+	return MLRules{
+		KindModel: {"*nn_model*", "*model_data*", "*tflm_model*", "*.tflite*"},
+		KindArena: {"*tensor_arena*", "*tensorArena*", "*activation_buf*", "*ethosu_scratch*"},
+	}
+}
+
+// LoadMLRules returns the default rules extended with any patterns listed
+// in <alifDir>/ml_sections.json, e.g. {"arena": ["*my_arena*"]}. A missing
+// file is not an error.
+func LoadMLRules(alifDir string) (MLRules, error) {
+	rules := DefaultMLRules()
+	if alifDir == "" {
+		return rules, nil
+	}
+	data, err := os.ReadFile(filepath.Join(alifDir, MLSectionsFile))
+	if os.IsNotExist(err) {
+		return rules, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var extra MLRules
+	if err := json.Unmarshal(data, &extra); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", MLSectionsFile, err)
+	}
+	for kind, patterns := range extra {
+		rules[kind] = append(rules[kind], patterns...)
+	}
+	return rules, nil
+}
+
+// Classify returns the kind whose patterns match name ("" if none).
+func (r MLRules) Classify(name string) string {
+	kinds := make([]string, 0, len(r))
+	for k := range r {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		for _, p := range r[kind] {
+			if ok, _ := path.Match(p, name); ok {
+				return kind
+			}
+		}
+	}
+	return ""
+}
+
+// MLSection is a section recognized as ML data.
+type MLSection struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"section"`
+	Address uint64 `json:"address"`
+	Size    uint64 `json:"size"`
+	// Region is the memory region the section is placed in ("" if unknown).
+	Region string `json:"region"`
+}
+
+// MLRegionUsage totals ML sections of one kind within one memory region.
+type MLRegionUsage struct {
+	Kind   string `json:"kind"`
+	Region string `json:"region"`
+	Size   uint64 `json:"size"`
+	// Capacity is the region length (0 if the region is unknown).
+	Capacity uint64 `json:"capacity"`
+}
+
+// Exceeds reports whether the usage does not fit its region.
+func (u MLRegionUsage) Exceeds() bool {
+	return u.Capacity > 0 && u.Size > u.Capacity
+}
+
+// AnalyzeML finds model and arena sections in m. Input sections are matched
+// first; an output section is only counted when none of its input sections
+// matched, so nothing is counted twice.
+func AnalyzeML(m *Map, rules MLRules) ([]MLSection, []MLRegionUsage) {
+	var found []MLSection
+	matchedParents := make(map[string]bool)
+
+	add := func(kind string, s Section) {
+		ms := MLSection{Kind: kind, Name: s.Name, Address: s.Address, Size: s.Size}
+		if r, ok := m.RegionOf(s.Address); ok {
+			ms.Region = r.Name
+		}
+		found = append(found, ms)
+	}
+
+	for _, s := range m.Input {
+		if kind := rules.Classify(s.Name); kind != "" {
+			add(kind, s)
+			matchedParents[s.Parent] = true
+		}
+	}
+	for _, s := range m.Output {
+		if matchedParents[s.Name] {
+			continue
+		}
+		if kind := rules.Classify(s.Name); kind != "" {
+			add(kind, s)
+		}
+	}
+
+	totals := make(map[string]*MLRegionUsage)
+	var order []string
+	for _, s := range found {
+		key := s.Kind + "\x00" + s.Region
+		u, ok := totals[key]
+		if !ok {
+			u = &MLRegionUsage{Kind: s.Kind, Region: s.Region}
+			for _, r := range m.Regions {
+				if r.Name == s.Region {
+					u.Capacity = r.Length
+				}
+			}
+			totals[key] = u
+			order = append(order, key)
+		}
+		u.Size += s.Size
+	}
+	var usage []MLRegionUsage
+	for _, key := range order {
+		usage = append(usage, *totals[key])
+	}
+	return found, usage
+}
+
+// IsSRAMRegion guesses whether a linker region name refers to on-chip SRAM
+// (as opposed to MRAM or external flash).
+func IsSRAMRegion(name string) bool {
+	n := strings.ToUpper(name)
+	return strings.Contains(n, "SRAM") || strings.Contains(n, "TCM")
+}
@@ -0,0 +1,132 @@
+package mapfile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeML(t *testing.T) {
+	m, err := ParseFile(filepath.Join("testdata", "kws.map"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		rules    MLRules
+		sections []MLSection
+		usage    []MLRegionUsage
+	}{
+		{
+			name:  "default rules",
+			rules: DefaultMLRules(),
+			sections: []MLSection{
+				{Kind: KindModel, Name: "nn_model", Address: 0x80008000, Size: 0x40000, Region: "MRAM"},
+				{Kind: KindArena, Name: ".bss.NoInit.activation_buf_sram", Address: 0x02000000, Size: 0x180000, Region: "SRAM0"},
+				{Kind: KindArena, Name: ".bss.tensor_arena", Address: 0x20001200, Size: 0x3ee00, Region: "DTCM"},
+			},
+			usage: []MLRegionUsage{
+				{Kind: KindModel, Region: "MRAM", Size: 0x40000, Capacity: 0x580000},
+				{Kind: KindArena, Region: "SRAM0", Size: 0x180000, Capacity: 0x400000},
+				{Kind: KindArena, Region: "DTCM", Size: 0x3ee00, Capacity: 0x40000},
+			},
+		},
+		{
+			name:  "project rule",
+			rules: MLRules{KindModel: {"*my_weights*"}},
+			sections: []MLSection{
+				{Kind: KindModel, Name: ".my_weights", Address: 0x80050000, Size: 0x10000, Region: "MRAM"},
+			},
+			usage: []MLRegionUsage{
+				{Kind: KindModel, Region: "MRAM", Size: 0x10000, Capacity: 0x580000},
+			},
+		},
+		{
+			name:  "no rules",
+			rules: MLRules{},
+		},
+	}
+	for _, tt := range tests {
+		sections, usage := AnalyzeML(m, tt.rules)
+		if !reflect.DeepEqual(sections, tt.sections) {
+			t.Errorf("%s: sections = %+v, want %+v", tt.name, sections, tt.sections)
+		}
+		if !reflect.DeepEqual(usage, tt.usage) {
+			t.Errorf("%s: usage = %+v, want %+v", tt.name, usage, tt.usage)
+		}
+	}
+}
+
+func TestMLRegionUsageExceeds(t *testing.T) {
+	tests := []struct {
+		u    MLRegionUsage
+		want bool
+	}{
+		{MLRegionUsage{Size: 0x40000, Capacity: 0x40000}, false},
+		{MLRegionUsage{Size: 0x40001, Capacity: 0x40000}, true},
+		{MLRegionUsage{Size: 0x40001}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.u.Exceeds(); got != tt.want {
+			t.Errorf("%+v.Exceeds() = %v, want %v", tt.u, got, tt.want)
+		}
+	}
+}
+
+func TestLoadMLRules(t *testing.T) {
+	alifDir := t.TempDir()
+	rules, err := LoadMLRules(alifDir)
+	if err != nil || !reflect.DeepEqual(rules, DefaultMLRules()) {
+		t.Errorf("without %s = %v, %v; want the defaults", MLSectionsFile, rules, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(alifDir, MLSectionsFile), []byte(`{"arena": ["*my_arena*"], "weights": ["*.wts"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err = LoadMLRules(alifDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		section, kind string
+	}{
+		{".bss.my_arena", KindArena},
+		{".bss.tensor_arena", KindArena},
+		{"nn_model", KindModel},
+		{"layer1.wts", "weights"},
+		{".text", ""},
+	}
+	for _, tt := range tests {
+		if got := rules.Classify(tt.section); got != tt.kind {
+			t.Errorf("Classify(%q) = %q, want %q", tt.section, got, tt.kind)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(alifDir, MLSectionsFile), []byte(`{"arena": "*my_arena*"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadMLRules(alifDir); err == nil || !strings.Contains(err.Error(), MLSectionsFile) {
+		t.Errorf("malformed %s: error = %v", MLSectionsFile, err)
+	}
+}
+
+func TestIsSRAMRegion(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"SRAM0", true},
+		{"sram1", true},
+		{"DTCM", true},
+		{"ITCM", true},
+		{"MRAM", false},
+		{"OSPI0", false},
+	}
+	for _, tt := range tests {
+		if got := IsSRAMRegion(tt.name); got != tt.want {
+			t.Errorf("IsSRAMRegion(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package monitor
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// DefaultStepTimeout is used for steps that do not set their own timeout.
+const DefaultStepTimeout = 5 * time.Second
+
+// Step is one interaction: optionally send a line, then optionally wait for
+// a pattern in the device output.
+type Step struct {
+	Send    string        `mapstructure:"send"`
+	Expect  string        `mapstructure:"expect"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// Script is an ordered list of steps.
+type Script struct {
+	// Delay is waited before each send (after the first).
+	Delay time.Duration `mapstructure:"delay"`
+	// LineEnding is appended to every sent command.
+	LineEnding string `mapstructure:"line_ending"`
+	Steps      []Step `mapstructure:"steps"`
+}
+
+// PairSteps builds steps from repeated --send / --expect flags, pairing them
+// by position. Either list may be longer than the other.
+func PairSteps(sends, expects []string, timeout time.Duration) []Step {
+	n := len(sends)
+	if len(expects) > n {
+		n = len(expects)
+	}
+	steps := make([]Step, n)
+	for i := range steps {
+		if i < len(sends) {
+			steps[i].Send = sends[i]
+		}
+		if i < len(expects) {
+			steps[i].Expect = expects[i]
+		}
+		steps[i].Timeout = timeout
+	}
+	return steps
+}
+
+// LoadScript reads a YAML script of the form:
+//
+//	delay: 200ms
+//	steps:
+//	  - send: "version"
+//	    expect: "v[0-9]+\\.[0-9]+"
+//	    timeout: 2s
+func LoadScript(path string) (*Script, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read script %s: %w", path, err)
+	}
+	var s Script
+	if err := v.Unmarshal(&s); err != nil {
+		return nil, fmt.Errorf("invalid script %s: %w", path, err)
+	}
+	if len(s.Steps) == 0 {
+		return nil, fmt.Errorf("script %s has no steps", path)
+	}
+	return &s, nil
+}
+
+// Run executes the script against rw. Device output is accumulated in a
+// buffer; each expectation is matched against output received since the
+// previous match, so patterns must appear in order. Every sent command and
+// all received data are copied to transcript (if non-nil).
+func Run(rw io.ReadWriter, s *Script, transcript io.Writer) error {
+	patterns := make([]*regexp.Regexp, len(s.Steps))
+	for i, st := range s.Steps {
+		if st.Expect == "" {
+			continue
+		}
+		re, err := regexp.Compile(st.Expect)
+		if err != nil {
+			return fmt.Errorf("step %d: invalid pattern %q: %w", i+1, st.Expect, err)
+		}
+		patterns[i] = re
+	}
+
+	lineEnding := s.LineEnding
+	if lineEnding == "" {
+		lineEnding = "\r\n"
+	}
+
+	st := newStream(rw, transcript)
+	go st.pump()
+
+	for i, step := range s.Steps {
+		if step.Send != "" {
+			if i > 0 && s.Delay > 0 {
+				time.Sleep(s.Delay)
+			}
+			st.note(fmt.Sprintf("\n>>> %s\n", step.Send))
+			if _, err := io.WriteString(rw, step.Send+lineEnding); err != nil {
+				return fmt.Errorf("step %d: failed to send %q: %w", i+1, step.Send, err)
+			}
+		}
+
+		if patterns[i] == nil {
+			continue
+		}
+		timeout := step.Timeout
+		if timeout <= 0 {
+			timeout = DefaultStepTimeout
+		}
+		if err := st.waitFor(patterns[i], timeout); err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// stream buffers device output and lets callers wait for a pattern.
+type stream struct {
+	r          io.Reader
+	transcript io.Writer
+
+	mu     sync.Mutex
+	buf    strings.Builder
+	offset int // start of unconsumed output
+	err    error
+	notify chan struct{}
+}
+
+func newStream(r io.Reader, transcript io.Writer) *stream {
+	return &stream{r: r, transcript: transcript, notify: make(chan struct{}, 1)}
+}
+
+// pump copies device output into the buffer until the reader fails.
+func (s *stream) pump() {
+	chunk := make([]byte, 1024)
+	for {
+		n, err := s.r.Read(chunk)
+		s.mu.Lock()
+		if n > 0 {
+			s.buf.Write(chunk[:n])
+			if s.transcript != nil {
+				s.transcript.Write(chunk[:n])
+			}
+		}
+		if err != nil {
+			s.err = err
+		}
+		s.mu.Unlock()
+		s.signal()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *stream) signal() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *stream) note(text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.transcript != nil {
+		io.WriteString(s.transcript, text)
+	}
+}
+
+// waitFor blocks until re matches unconsumed output, consuming up to the end
+// of the match, or fails after timeout.
+func (s *stream) waitFor(re *regexp.Regexp, timeout time.Duration) error {
+	deadline := time.After(timeout)
+	for {
+		s.mu.Lock()
+		pending := s.buf.String()[s.offset:]
+		if loc := re.FindStringIndex(pending); loc != nil {
+			s.offset += loc[1]
+			s.mu.Unlock()
+			return nil
+		}
+		readErr := s.err
+		s.mu.Unlock()
+
+		if readErr != nil {
+			return fmt.Errorf("expected %q, but the connection ended: %v", re, readErr)
+		}
+
+		select {
+		case <-s.notify:
+		case <-deadline:
+			return fmt.Errorf("expected %q within %s", re, timeout)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// device simulates a board's console: it greets, then answers each
+// received line with replies[line], or "unknown command".
+type device struct {
+	in  *io.PipeReader // what the device receives
+	out *io.PipeWriter // what the device prints
+	r   *io.PipeReader
+	w   *io.PipeWriter
+}
+
+func newDevice(t *testing.T, greeting string, replies map[string]string) *device {
+	t.Helper()
+	d := &device{}
+	d.in, d.w = io.Pipe()
+	d.r, d.out = io.Pipe()
+	t.Cleanup(func() { d.w.Close(); d.r.Close() })
+	go func() {
+		io.WriteString(d.out, greeting)
+		scanner := bufio.NewScanner(d.in)
+		for scanner.Scan() {
+			cmd := strings.TrimRight(scanner.Text(), "\r")
+			reply, ok := replies[cmd]
+			if !ok {
+				reply = "unknown command\r\n"
+			}
+			if _, err := io.WriteString(d.out, reply); err != nil {
+				return
+			}
+		}
+		d.out.Close()
+	}()
+	return d
+}
+
+func (d *device) Read(p []byte) (int, error)  { return d.r.Read(p) }
+func (d *device) Write(p []byte) (int, error) { return d.w.Write(p) }
+
+func TestRun(t *testing.T) {
+	replies := map[string]string{
+		"version": "fw v1.4.2\r\n> ",
+		"led on":  "ok\r\n> ",
+		"status":  "temp=41C\r\n> ",
+	}
+	short := 200 * time.Millisecond
+	tests := []struct {
+		name      string
+		steps     []Step
+		errSubstr string
+	}{
+		{"expect the greeting only", []Step{{Expect: "ready"}}, ""},
+		{"send and expect", []Step{
+			{Expect: "ready"},
+			{Send: "version", Expect: `v[0-9]+\.[0-9]+`},
+			{Send: "led on", Expect: "ok"},
+		}, ""},
+		{"send without expect", []Step{{Send: "led on"}, {Send: "status", Expect: "temp=[0-9]+C"}}, ""},
+		{"no match before the timeout", []Step{{Send: "version", Expect: "v2", Timeout: short}}, "step 1: expected"},
+		{"patterns match in order", []Step{
+			{Send: "version", Expect: "fw"},
+			{Expect: "ready", Timeout: short},
+		}, "step 2"},
+		{"invalid pattern", []Step{{Send: "version", Expect: "v[0-9"}}, "invalid pattern"},
+	}
+	for _, tt := range tests {
+		d := newDevice(t, "boot\r\nready\r\n> ", replies)
+		var transcript bytes.Buffer
+		err := Run(d, &Script{Steps: tt.steps}, &transcript)
+		switch {
+		case tt.errSubstr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v\ntranscript:\n%s", tt.name, err, transcript.String())
+		case tt.errSubstr != "" && (err == nil || !strings.Contains(err.Error(), tt.errSubstr)):
+			t.Errorf("%s: error = %v, want one mentioning %q", tt.name, err, tt.errSubstr)
+		}
+	}
+}
+
+func TestRunConnectionEnds(t *testing.T) {
+	d := newDevice(t, "boot\r\n", nil)
+	d.w.Close() // the device's input closes, so it stops and hangs up
+	err := Run(d, &Script{Steps: []Step{{Expect: "ready"}}}, nil)
+	if err == nil || !strings.Contains(err.Error(), "connection ended") {
+		t.Errorf("error = %v, want the connection to have ended", err)
+	}
+}
+
+func TestRunTranscript(t *testing.T) {
+	d := newDevice(t, "ready\r\n", map[string]string{"version": "fw v1.4.2\r\n"})
+	var transcript bytes.Buffer
+	s := &Script{LineEnding: "\n", Steps: []Step{{Send: "version", Expect: "v1"}}}
+	if err := Run(d, s, &transcript); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{">>> version", "fw v1.4.2"} {
+		if !strings.Contains(transcript.String(), want) {
+			t.Errorf("transcript %q lacks %q", transcript.String(), want)
+		}
+	}
+}
+
+func TestPairSteps(t *testing.T) {
+	tests := []struct {
+		sends, expects []string
+		want           []Step
+	}{
+		{[]string{"a", "b"}, []string{"A", "B"}, []Step{{Send: "a", Expect: "A", Timeout: time.Second}, {Send: "b", Expect: "B", Timeout: time.Second}}},
+		{[]string{"a", "b"}, []string{"A"}, []Step{{Send: "a", Expect: "A", Timeout: time.Second}, {Send: "b", Timeout: time.Second}}},
+		{nil, []string{"A", "B"}, []Step{{Expect: "A", Timeout: time.Second}, {Expect: "B", Timeout: time.Second}}},
+		{nil, nil, []Step{}},
+	}
+	for _, tt := range tests {
+		got := PairSteps(tt.sends, tt.expects, time.Second)
+		if len(got) != len(tt.want) {
+			t.Errorf("PairSteps(%q, %q) = %+v, want %+v", tt.sends, tt.expects, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("PairSteps(%q, %q)[%d] = %+v, want %+v", tt.sends, tt.expects, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestLoadScript(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	s, err := LoadScript(write("ok.yml", "delay: 200ms\nsteps:\n  - send: version\n    expect: 'v[0-9]+'\n    timeout: 2s\n  - expect: '> '\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Delay != 200*time.Millisecond || len(s.Steps) != 2 ||
+		s.Steps[0] != (Step{Send: "version", Expect: "v[0-9]+", Timeout: 2 * time.Second}) ||
+		s.Steps[1] != (Step{Expect: "> "}) {
+		t.Errorf("LoadScript = %+v", s)
+	}
+
+	if _, err := LoadScript(write("empty.yml", "delay: 1s\n")); err == nil || !strings.Contains(err.Error(), "no steps") {
+		t.Errorf("script without steps: error = %v", err)
+	}
+	if _, err := LoadScript(filepath.Join(dir, "missing.yml")); err == nil {
+		t.Error("missing script: no error")
+	}
+}
@@ -0,0 +1,161 @@
+package netserial
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+// baudPollInterval is how often a bridge checks the baud rate the tool set
+// on its end.
+const baudPollInterval = 20 * time.Millisecond
+
+// Bridge connects a local pseudo-terminal to a network port, for tools
+// that take a device path, such as the Security Toolkit's comport setting.
+// Everything written to Path goes to the server and everything the server
+// sends can be read from Path; tools may open and close Path as often as
+// they like while the bridge runs.
+type Bridge struct {
+	// Path is the pseudo-terminal device to hand to the tool.
+	Path string
+	// Port is the network port behind it.
+	Port string
+	// FollowsBaud is set when baud rate changes the tool makes on Path are
+	// passed on to the server, which needs rfc2217://. Otherwise the
+	// server's speed stays fixed and tools must not switch speeds.
+	FollowsBaud bool
+
+	conn   *Conn
+	master *os.File
+	// slave stays open so the pseudo-terminal keeps its raw settings, and
+	// its master keeps working, between the tool's runs.
+	slave *os.File
+	done  chan struct{}
+	once  sync.Once
+}
+
+// NewBridge opens port at baud and a pseudo-terminal for it, and starts
+// copying between them. It fails on systems without pseudo-terminals.
+func NewBridge(port string, baud int) (*Bridge, error) {
+	master, slave, path, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+	if err := makeRaw(slave); err != nil {
+		master.Close()
+		slave.Close()
+		return nil, err
+	}
+	p, err := Open(port, &serial.Mode{BaudRate: baud})
+	if err != nil {
+		master.Close()
+		slave.Close()
+		return nil, err
+	}
+	conn := p.(*Conn)
+	b := &Bridge{
+		Path:   path,
+		Port:   port,
+		conn:   conn,
+		master: master,
+		slave:  slave,
+		done:   make(chan struct{}),
+	}
+	go b.toServer()
+	go b.fromServer()
+	if conn.RFC2217() {
+		if _, err := ptyBaud(slave); err == nil {
+			b.FollowsBaud = true
+			go b.followBaud(baud)
+		}
+	}
+	return b, nil
+}
+
+// Conn returns the network connection, e.g. to drive the control lines
+// while a tool holds Path.
+func (b *Bridge) Conn() serial.Port {
+	return b.conn
+}
+
+// Close stops the bridge and closes both ends.
+func (b *Bridge) Close() error {
+	b.once.Do(func() {
+		close(b.done)
+		b.master.Close()
+		b.slave.Close()
+		b.conn.Close()
+	})
+	return nil
+}
+
+func (b *Bridge) closed() bool {
+	select {
+	case <-b.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// toServer copies what the tool writes to the server.
+func (b *Bridge) toServer() {
+	buf := make([]byte, 4096)
+	for {
+		n, err := b.master.Read(buf)
+		if n > 0 {
+			if _, werr := b.conn.Write(buf[:n]); werr != nil {
+				b.Close()
+				return
+			}
+		}
+		if err != nil {
+			// EIO only means no tool has the pseudo-terminal open.
+			if b.closed() || !errors.Is(err, syscall.EIO) {
+				b.Close()
+				return
+			}
+			time.Sleep(baudPollInterval)
+		}
+	}
+}
+
+// fromServer copies what the server sends to the tool.
+func (b *Bridge) fromServer() {
+	buf := make([]byte, 4096)
+	for {
+		n, err := b.conn.Read(buf)
+		if n > 0 {
+			b.master.Write(buf[:n])
+		}
+		if err != nil {
+			b.Close()
+			return
+		}
+	}
+}
+
+// followBaud passes the tool's baud rate changes on to the server.
+func (b *Bridge) followBaud(baud int) {
+	ticker := time.NewTicker(baudPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+		}
+		current, err := ptyBaud(b.slave)
+		if err != nil || current == 0 || current == baud {
+			continue
+		}
+		if b.conn.write(setBaud(current)) != nil {
+			return
+		}
+		baud = current
+	}
+}
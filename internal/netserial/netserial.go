@@ -0,0 +1,260 @@
+// Package netserial opens serial ports that are reached over the network
+// through a serial device server: tcp://host:port for a raw TCP socket, and
+// rfc2217://host:port for a Telnet COM Port Control (RFC 2217) server, which
+// also carries the baud rate and the DTR/RTS lines. Such ports work
+// wherever a local port does, and Bridge exposes them to tools that can
+// only open a device path.
+package netserial
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+// Port name schemes.
+const (
+	SchemeTCP     = "tcp"
+	SchemeRFC2217 = "rfc2217"
+)
+
+// dialTimeout bounds connecting to the device server.
+const dialTimeout = 5 * time.Second
+
+// ErrNoControl is returned for baud rate and control line changes on a raw
+// TCP port, where only the data reaches the device server.
+var ErrNoControl = errors.New("raw TCP ports carry data only; use rfc2217:// to control the baud rate and DTR/RTS")
+
+// IsNetwork reports whether port names a network serial port.
+func IsNetwork(port string) bool {
+	scheme, _, ok := strings.Cut(port, "://")
+	return ok && (scheme == SchemeTCP || scheme == SchemeRFC2217)
+}
+
+// Parse splits a network port name into its scheme and host:port.
+func Parse(port string) (scheme, addr string, err error) {
+	scheme, addr, ok := strings.Cut(port, "://")
+	if !ok || (scheme != SchemeTCP && scheme != SchemeRFC2217) {
+		return "", "", fmt.Errorf("'%s' is not a network port (use tcp://host:port or rfc2217://host:port)", port)
+	}
+	host, p, err := net.SplitHostPort(strings.TrimSuffix(addr, "/"))
+	if err != nil || host == "" || p == "" {
+		return "", "", fmt.Errorf("invalid network port '%s' (use %s://host:port)", port, scheme)
+	}
+	return scheme, net.JoinHostPort(host, p), nil
+}
+
+// Open opens port with mode, whether it is a local device or a network
+// port.
+func Open(port string, mode *serial.Mode) (serial.Port, error) {
+	if !IsNetwork(port) {
+		return serial.Open(port, mode)
+	}
+	scheme, addr, err := Parse(port)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("cannot reach serial server %s: %w", addr, err)
+	}
+	c := &Conn{conn: conn, name: port, timeout: serial.NoTimeout}
+	if scheme == SchemeRFC2217 {
+		c.telnet = &telnet{}
+		if err := c.write(negotiation()); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if mode != nil {
+		if err := c.SetMode(mode); err != nil && !errors.Is(err, ErrNoControl) {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Conn is an open network serial port. It implements serial.Port; with a
+// raw TCP server the baud rate, control lines and breaks are not
+// available.
+type Conn struct {
+	conn net.Conn
+	name string
+	// telnet decodes the RFC 2217 stream; nil for raw TCP.
+	telnet *telnet
+	// pending holds data decoded beyond what the last Read returned.
+	pending []byte
+	timeout time.Duration
+	writeMu sync.Mutex
+}
+
+// RFC2217 reports whether the port is an RFC 2217 port.
+func (c *Conn) RFC2217() bool {
+	return c.telnet != nil
+}
+
+func (c *Conn) SetMode(mode *serial.Mode) error {
+	if c.telnet == nil {
+		return ErrNoControl
+	}
+	parity := byte(1)
+	switch mode.Parity {
+	case serial.OddParity:
+		parity = 2
+	case serial.EvenParity:
+		parity = 3
+	case serial.MarkParity:
+		parity = 4
+	case serial.SpaceParity:
+		parity = 5
+	}
+	stop := byte(1)
+	switch mode.StopBits {
+	case serial.OnePointFiveStopBits:
+		stop = 3
+	case serial.TwoStopBits:
+		stop = 2
+	}
+	dataBits := byte(8)
+	if mode.DataBits != 0 {
+		dataBits = byte(mode.DataBits)
+	}
+	var out []byte
+	if mode.BaudRate != 0 {
+		out = append(out, setBaud(mode.BaudRate)...)
+	}
+	out = append(out, subnegotiation(cpcSetDataSize, dataBits)...)
+	out = append(out, subnegotiation(cpcSetParity, parity)...)
+	out = append(out, subnegotiation(cpcSetStopSize, stop)...)
+	return c.write(out)
+}
+
+// Read returns received data. Like a local port, it returns 0 and no error
+// when the read timeout passes without data.
+func (c *Conn) Read(p []byte) (int, error) {
+	if len(c.pending) > 0 {
+		n := copy(p, c.pending)
+		c.pending = c.pending[n:]
+		return n, nil
+	}
+	deadline := time.Time{}
+	if c.timeout >= 0 {
+		deadline = time.Now().Add(c.timeout)
+	}
+	if err := c.conn.SetReadDeadline(deadline); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, max(len(p), 256))
+	for {
+		n, err := c.conn.Read(buf)
+		data := buf[:n]
+		if c.telnet != nil && n > 0 {
+			var reply []byte
+			data, reply = c.telnet.decode(buf[:n])
+			if len(reply) > 0 {
+				if werr := c.write(reply); werr != nil {
+					return 0, werr
+				}
+			}
+		}
+		if len(data) > 0 {
+			copied := copy(p, data)
+			c.pending = append(c.pending, data[copied:]...)
+			return copied, nil
+		}
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return 0, nil
+			}
+			return 0, err
+		}
+	}
+}
+
+func (c *Conn) Write(p []byte) (int, error) {
+	out := p
+	if c.telnet != nil {
+		out = escape(p)
+	}
+	if err := c.write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// write sends bytes as they are, serialized with other writers.
+func (c *Conn) write(b []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.conn.Write(b)
+	return err
+}
+
+// Drain has nothing to wait for: written data is already with the server.
+func (c *Conn) Drain() error {
+	return nil
+}
+
+func (c *Conn) ResetInputBuffer() error {
+	c.pending = nil
+	if c.telnet == nil {
+		return nil
+	}
+	return c.write(subnegotiation(cpcPurgeData, purgeReceive))
+}
+
+func (c *Conn) ResetOutputBuffer() error {
+	if c.telnet == nil {
+		return nil
+	}
+	return c.write(subnegotiation(cpcPurgeData, purgeTransmit))
+}
+
+func (c *Conn) SetDTR(dtr bool) error {
+	return c.control(dtr, controlDTROn, controlDTROff)
+}
+
+func (c *Conn) SetRTS(rts bool) error {
+	return c.control(rts, controlRTSOn, controlRTSOff)
+}
+
+func (c *Conn) control(on bool, onValue, offValue byte) error {
+	if c.telnet == nil {
+		return ErrNoControl
+	}
+	value := offValue
+	if on {
+		value = onValue
+	}
+	return c.write(subnegotiation(cpcSetControl, value))
+}
+
+// GetModemStatusBits is not supported: the server's line state
+// notifications are not requested.
+func (c *Conn) GetModemStatusBits() (*serial.ModemStatusBits, error) {
+	return nil, fmt.Errorf("modem status bits are not available on %s", c.name)
+}
+
+func (c *Conn) SetReadTimeout(t time.Duration) error {
+	c.timeout = t
+	return nil
+}
+
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Conn) Break(d time.Duration) error {
+	if err := c.control(true, controlBreakOn, controlBreakOff); err != nil {
+		return err
+	}
+	time.Sleep(d)
+	return c.control(false, controlBreakOn, controlBreakOff)
+}
@@ -0,0 +1,374 @@
+package netserial
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+// echoServer is an in-process serial device server that echoes the data
+// it receives. In RFC 2217 mode it decodes Telnet like a real server,
+// records the COM Port Control commands and escapes what it echoes.
+type echoServer struct {
+	ln      net.Listener
+	rfc2217 bool
+
+	mu sync.Mutex
+	// commands holds each COM Port Control subnegotiation: command byte
+	// first, then its value.
+	commands [][]byte
+	// options holds the WILL/WONT/DO/DONT the client sent, verb first.
+	options [][2]byte
+	// greeting is sent to each client when it connects.
+	greeting []byte
+}
+
+func newEchoServer(t *testing.T, rfc2217 bool, greeting ...byte) *echoServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &echoServer{ln: ln, rfc2217: rfc2217, greeting: greeting}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// port names the server with scheme.
+func (s *echoServer) port() string {
+	scheme := SchemeTCP
+	if s.rfc2217 {
+		scheme = SchemeRFC2217
+	}
+	return scheme + "://" + s.ln.Addr().String()
+}
+
+func (s *echoServer) serve(conn net.Conn) {
+	defer conn.Close()
+	conn.Write(s.greeting)
+	buf := make([]byte, 1024)
+	state, verb := stateData, byte(0)
+	var sb []byte
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		if !s.rfc2217 {
+			conn.Write(buf[:n])
+			continue
+		}
+		var data []byte
+		for _, b := range buf[:n] {
+			switch state {
+			case stateData:
+				if b == telnetIAC {
+					state = stateIAC
+				} else {
+					data = append(data, b)
+				}
+			case stateIAC:
+				switch b {
+				case telnetIAC:
+					data = append(data, b)
+					state = stateData
+				case telnetWill, telnetWont, telnetDo, telnetDont:
+					verb, state = b, stateOption
+				case telnetSB:
+					sb, state = nil, stateSB
+				default:
+					state = stateData
+				}
+			case stateOption:
+				s.mu.Lock()
+				s.options = append(s.options, [2]byte{verb, b})
+				s.mu.Unlock()
+				state = stateData
+			case stateSB:
+				if b == telnetIAC {
+					state = stateSBIAC
+				} else {
+					sb = append(sb, b)
+				}
+			case stateSBIAC:
+				switch b {
+				case telnetSE:
+					if len(sb) > 1 && sb[0] == optComPort {
+						s.mu.Lock()
+						s.commands = append(s.commands, sb[1:])
+						s.mu.Unlock()
+					}
+					state = stateData
+				case telnetIAC:
+					sb, state = append(sb, b), stateSB
+				default:
+					state = stateSB
+				}
+			}
+		}
+		conn.Write(escape(data))
+	}
+}
+
+// waitCommands returns the COM Port Control commands once n have arrived.
+func (s *echoServer) waitCommands(t *testing.T, n int) [][]byte {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		s.mu.Lock()
+		got := append([][]byte{}, s.commands...)
+		s.mu.Unlock()
+		if len(got) >= n {
+			return got
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t.Fatalf("server got %d COM Port Control commands, want %d: %v", len(s.commands), n, s.commands)
+	return nil
+}
+
+// readFull reads len(want) bytes from r, failing the test after a timeout.
+func readFull(t *testing.T, r io.Reader, n int) []byte {
+	t.Helper()
+	got := make([]byte, 0, n)
+	buf := make([]byte, n)
+	for deadline := time.Now().Add(2 * time.Second); len(got) < n && time.Now().Before(deadline); {
+		m, err := r.Read(buf[:n-len(got)])
+		got = append(got, buf[:m]...)
+		if err != nil {
+			t.Fatalf("read after %q: %v", got, err)
+		}
+	}
+	return got
+}
+
+func baudValue(baud int) []byte {
+	return binary.BigEndian.AppendUint32([]byte{cpcSetBaudRate}, uint32(baud))
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		port, scheme, addr string
+		network            bool
+		errSubstr          string
+	}{
+		{"tcp://192.168.1.20:4001", SchemeTCP, "192.168.1.20:4001", true, ""},
+		{"rfc2217://chamber-3:7000/", SchemeRFC2217, "chamber-3:7000", true, ""},
+		{"rfc2217://[fe80::1]:7000", SchemeRFC2217, "[fe80::1]:7000", true, ""},
+		{"tcp://chamber-3", "", "", true, "invalid network port 'tcp://chamber-3' (use tcp://host:port)"},
+		{"tcp://:4001", "", "", true, "invalid network port"},
+		{"telnet://chamber-3:23", "", "", false, "not a network port"},
+		{"/dev/ttyACM0", "", "", false, "not a network port"},
+		{"COM3", "", "", false, "not a network port"},
+	}
+	for _, tt := range tests {
+		if got := IsNetwork(tt.port); got != tt.network {
+			t.Errorf("IsNetwork(%q) = %v", tt.port, got)
+		}
+		scheme, addr, err := Parse(tt.port)
+		switch {
+		case tt.errSubstr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.port, err)
+		case tt.errSubstr != "" && (err == nil || !strings.Contains(err.Error(), tt.errSubstr)):
+			t.Errorf("%s: error = %v, want one mentioning %q", tt.port, err, tt.errSubstr)
+		case scheme != tt.scheme || addr != tt.addr:
+			t.Errorf("Parse(%q) = %q, %q; want %q, %q", tt.port, scheme, addr, tt.scheme, tt.addr)
+		}
+	}
+}
+
+func TestOpenRaw(t *testing.T) {
+	s := newEchoServer(t, false)
+	p, err := Open(s.port(), &serial.Mode{BaudRate: 115200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	// Raw TCP passes every byte, 0xFF included, unchanged.
+	msg := []byte{'I', 'S', 'P', 0xFF, 0x00, '\n'}
+	if _, err := p.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFull(t, p, len(msg)); !bytes.Equal(got, msg) {
+		t.Errorf("echo = %q, want %q", got, msg)
+	}
+	if err := p.SetDTR(true); !errors.Is(err, ErrNoControl) {
+		t.Errorf("SetDTR on raw TCP: %v", err)
+	}
+	if err := p.SetMode(&serial.Mode{BaudRate: 921600}); !errors.Is(err, ErrNoControl) {
+		t.Errorf("SetMode on raw TCP: %v", err)
+	}
+
+	// A read timeout without data returns nothing, like a local port.
+	p.SetReadTimeout(50 * time.Millisecond)
+	if n, err := p.Read(make([]byte, 16)); n != 0 || err != nil {
+		t.Errorf("idle read = %d, %v", n, err)
+	}
+}
+
+func TestOpenRFC2217(t *testing.T) {
+	// The server offers ECHO, which the client must refuse.
+	s := newEchoServer(t, true, telnetIAC, telnetWill, 1)
+	p, err := Open(s.port(), &serial.Mode{BaudRate: 921600, Parity: serial.EvenParity, StopBits: serial.TwoStopBits})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if !p.(*Conn).RFC2217() {
+		t.Error("RFC2217() = false")
+	}
+
+	msg := []byte{0x00, 0xFF, 0xFF, 'o', 'k', 0xFF}
+	if _, err := p.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFull(t, p, len(msg)); !bytes.Equal(got, msg) {
+		t.Errorf("echo = %q, want %q", got, msg)
+	}
+
+	if err := p.SetDTR(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetRTS(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.ResetInputBuffer(); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]byte{
+		baudValue(921600),
+		{cpcSetDataSize, 8},
+		{cpcSetParity, 3},
+		{cpcSetStopSize, 2},
+		{cpcSetControl, controlDTROff},
+		{cpcSetControl, controlRTSOn},
+		{cpcPurgeData, purgeReceive},
+	}
+	if got := s.waitCommands(t, len(want)); !reflect.DeepEqual(got, want) {
+		t.Errorf("COM Port Control commands = %v, want %v", got, want)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wantOptions := [][2]byte{
+		{telnetWill, optBinary}, {telnetDo, optBinary},
+		{telnetWill, optSGA}, {telnetDo, optSGA},
+		{telnetWill, optComPort},
+		{telnetDont, 1},
+	}
+	if !reflect.DeepEqual(s.options, wantOptions) {
+		t.Errorf("client options = %v, want %v", s.options, wantOptions)
+	}
+}
+
+func TestOpenUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	if _, err := Open("rfc2217://"+addr, nil); err == nil || !strings.Contains(err.Error(), "cannot reach serial server "+addr) {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestTelnetDecode(t *testing.T) {
+	tests := []struct {
+		name  string
+		reads [][]byte
+		data  []byte
+		reply []byte
+	}{
+		{"plain data", [][]byte{[]byte("hello")}, []byte("hello"), nil},
+		{"escaped IAC", [][]byte{{'a', telnetIAC, telnetIAC, 'b'}}, []byte{'a', telnetIAC, 'b'}, nil},
+		{"IAC split across reads", [][]byte{{'a', telnetIAC}, {telnetIAC, 'b'}}, []byte{'a', telnetIAC, 'b'}, nil},
+		{"accepted options", [][]byte{{telnetIAC, telnetDo, optComPort, telnetIAC, telnetWill, optBinary, 'x'}}, []byte("x"), nil},
+		{"refused options", [][]byte{{telnetIAC, telnetWill, 1, telnetIAC, telnetDo, 24, telnetIAC, telnetWont, 1}},
+			nil, []byte{telnetIAC, telnetDont, 1, telnetIAC, telnetWont, 24}},
+		{"server answers dropped", [][]byte{{'a', telnetIAC, telnetSB, optComPort, 101, 0, 0, telnetIAC, telnetIAC, 0, telnetIAC, telnetSE, 'b'}}, []byte("ab"), nil},
+		{"subnegotiation split across reads", [][]byte{{telnetIAC, telnetSB, optComPort, 101}, {1, telnetIAC}, {telnetSE, 'z'}}, []byte("z"), nil},
+		{"other commands dropped", [][]byte{{'a', telnetIAC, 241, 'b'}}, []byte("ab"), nil},
+	}
+	for _, tt := range tests {
+		var tn telnet
+		var data, reply []byte
+		for _, r := range tt.reads {
+			d, rp := tn.decode(r)
+			data, reply = append(data, d...), append(reply, rp...)
+		}
+		if !bytes.Equal(data, tt.data) || !bytes.Equal(reply, tt.reply) {
+			t.Errorf("%s: decode = %v, reply %v; want %v, %v", tt.name, data, reply, tt.data, tt.reply)
+		}
+	}
+}
+
+func TestBridge(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("pseudo-terminals need Linux or macOS")
+	}
+	for _, rfc2217 := range []bool{false, true} {
+		s := newEchoServer(t, rfc2217)
+		b, err := NewBridge(s.port(), 115200)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.Port != s.port() || b.FollowsBaud != rfc2217 {
+			t.Errorf("%s: bridge %+v", s.port(), b)
+		}
+
+		// A tool opens the device, talks through it and closes it, twice.
+		for run := 0; run < 2; run++ {
+			tty, err := os.OpenFile(b.Path, os.O_RDWR, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg := []byte{'r', 'u', 'n', byte('0' + run), 0xFF, '\n'}
+			if _, err := tty.Write(msg); err != nil {
+				t.Fatal(err)
+			}
+			if got := readFull(t, tty, len(msg)); !bytes.Equal(got, msg) {
+				t.Errorf("%s run %d: echo through %s = %q, want %q", s.port(), run, b.Path, got, msg)
+			}
+			tty.Close()
+		}
+
+		if rfc2217 {
+			// The toolkit switching speed is passed on to the server.
+			tool, err := serial.Open(b.Path, &serial.Mode{BaudRate: 921600})
+			if err != nil {
+				t.Fatal(err)
+			}
+			commands := s.waitCommands(t, 5)
+			tool.Close()
+			if !bytes.Equal(commands[0], baudValue(115200)) || !bytes.Equal(commands[4], baudValue(921600)) {
+				t.Errorf("COM Port Control commands = %v, want the open at 115200, then 921600", commands)
+			}
+		}
+		b.Close()
+		if _, err := b.conn.Write([]byte("x")); err == nil {
+			t.Errorf("%s: connection open after Close", s.port())
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package netserial
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// openPTY creates a pseudo-terminal pair and returns its master, its slave
+// and the slave's device path.
+func openPTY() (master, slave *os.File, path string, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("cannot create a pseudo-terminal: %w", err)
+	}
+	if err := ioctl(master.Fd(), syscall.TIOCPTYGRANT, 0); err != nil {
+		master.Close()
+		return nil, nil, "", fmt.Errorf("cannot grant the pseudo-terminal: %w", err)
+	}
+	if err := ioctl(master.Fd(), syscall.TIOCPTYUNLK, 0); err != nil {
+		master.Close()
+		return nil, nil, "", fmt.Errorf("cannot unlock the pseudo-terminal: %w", err)
+	}
+	name := make([]byte, 128)
+	if err := ioctl(master.Fd(), syscall.TIOCPTYGNAME, uintptr(unsafe.Pointer(&name[0]))); err != nil {
+		master.Close()
+		return nil, nil, "", fmt.Errorf("cannot name the pseudo-terminal: %w", err)
+	}
+	if i := bytes.IndexByte(name, 0); i >= 0 {
+		name = name[:i]
+	}
+	path = string(name)
+	slave, err = os.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, "", err
+	}
+	return master, slave, path, nil
+}
+
+// makeRaw turns off line editing, echo and character translation, so the
+// pseudo-terminal passes bytes through unchanged.
+func makeRaw(tty *os.File) error {
+	var t syscall.Termios
+	if err := ioctl(tty.Fd(), syscall.TIOCGETA, uintptr(unsafe.Pointer(&t))); err != nil {
+		return err
+	}
+	t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	t.Oflag &^= syscall.OPOST
+	t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	t.Cflag &^= syscall.CSIZE | syscall.PARENB
+	t.Cflag |= syscall.CS8
+	t.Cc[syscall.VMIN] = 1
+	t.Cc[syscall.VTIME] = 0
+	return ioctl(tty.Fd(), syscall.TIOCSETA, uintptr(unsafe.Pointer(&t)))
+}
+
+// ptyBaud returns the baud rate last set on tty; macOS keeps it as a
+// number.
+func ptyBaud(tty *os.File) (int, error) {
+	var t syscall.Termios
+	if err := ioctl(tty.Fd(), syscall.TIOCGETA, uintptr(unsafe.Pointer(&t))); err != nil {
+		return 0, err
+	}
+	return int(t.Ospeed), nil
+}
+
+func ioctl(fd, request, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
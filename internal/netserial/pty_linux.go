@@ -0,0 +1,79 @@
+package netserial
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// openPTY creates a pseudo-terminal pair and returns its master, its slave
+// and the slave's device path.
+func openPTY() (master, slave *os.File, path string, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("cannot create a pseudo-terminal: %w", err)
+	}
+	var unlock int32
+	if err := ioctl(master.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		master.Close()
+		return nil, nil, "", fmt.Errorf("cannot unlock the pseudo-terminal: %w", err)
+	}
+	var n uint32
+	if err := ioctl(master.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); err != nil {
+		master.Close()
+		return nil, nil, "", fmt.Errorf("cannot name the pseudo-terminal: %w", err)
+	}
+	path = fmt.Sprintf("/dev/pts/%d", n)
+	slave, err = os.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, "", err
+	}
+	return master, slave, path, nil
+}
+
+// makeRaw turns off line editing, echo and character translation, so the
+// pseudo-terminal passes bytes through unchanged.
+func makeRaw(tty *os.File) error {
+	var t syscall.Termios
+	if err := ioctl(tty.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&t))); err != nil {
+		return err
+	}
+	t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	t.Oflag &^= syscall.OPOST
+	t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	t.Cflag &^= syscall.CSIZE | syscall.PARENB
+	t.Cflag |= syscall.CS8
+	t.Cc[syscall.VMIN] = 1
+	t.Cc[syscall.VTIME] = 0
+	return ioctl(tty.Fd(), syscall.TCSETS, uintptr(unsafe.Pointer(&t)))
+}
+
+// cbaud masks the speed code in c_cflag (CBAUD, which package syscall
+// does not define).
+const cbaud = 0x100f
+
+// linuxBauds maps termios speed codes to baud rates.
+var linuxBauds = map[uint32]int{
+	syscall.B9600: 9600, syscall.B19200: 19200, syscall.B38400: 38400,
+	syscall.B57600: 57600, syscall.B115200: 115200, syscall.B230400: 230400,
+	syscall.B460800: 460800, syscall.B500000: 500000, syscall.B576000: 576000,
+	syscall.B921600: 921600,
+}
+
+// ptyBaud returns the baud rate last set on tty.
+func ptyBaud(tty *os.File) (int, error) {
+	var t syscall.Termios
+	if err := ioctl(tty.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&t))); err != nil {
+		return 0, err
+	}
+	return linuxBauds[t.Cflag&cbaud], nil
+}
+
+func ioctl(fd, request, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+//go:build !linux && !darwin
+
+package netserial
+
+import (
+	"errors"
+	"os"
+)
+
+// errNoPTY explains why a network port cannot be handed to the toolkit
+// here: its tools open the comport by name, and only Linux and macOS let
+// alif create a local device that forwards to the network.
+var errNoPTY = errors.New("the Security Toolkit can only open local COM ports on this system; map the network port to a virtual COM port (e.g. with the serial server vendor's driver or com0com) and pass that port instead")
+
+func openPTY() (master, slave *os.File, path string, err error) {
+	return nil, nil, "", errNoPTY
+}
+
+func makeRaw(tty *os.File) error {
+	return errNoPTY
+}
+
+func ptyBaud(tty *os.File) (int, error) {
+	return 0, errNoPTY
+}
@@ -0,0 +1,148 @@
+package netserial
+
+import "encoding/binary"
+
+// Telnet commands and options (RFC 854, 856, 858).
+const (
+	telnetSE   = 240
+	telnetSB   = 250
+	telnetWill = 251
+	telnetWont = 252
+	telnetDo   = 253
+	telnetDont = 254
+	telnetIAC  = 255
+
+	optBinary = 0
+	optSGA    = 3
+	// optComPort is the COM Port Control option of RFC 2217.
+	optComPort = 44
+)
+
+// RFC 2217 client commands and values.
+const (
+	cpcSetBaudRate = 1
+	cpcSetDataSize = 2
+	cpcSetParity   = 3
+	cpcSetStopSize = 4
+	cpcSetControl  = 5
+	cpcPurgeData   = 12
+
+	controlBreakOn  = 5
+	controlBreakOff = 6
+	controlDTROn    = 8
+	controlDTROff   = 9
+	controlRTSOn    = 11
+	controlRTSOff   = 12
+
+	purgeReceive  = 1
+	purgeTransmit = 2
+)
+
+// negotiation is what a client sends first: binary transfer and no
+// go-ahead both ways, and the COM Port Control option.
+func negotiation() []byte {
+	return []byte{
+		telnetIAC, telnetWill, optBinary, telnetIAC, telnetDo, optBinary,
+		telnetIAC, telnetWill, optSGA, telnetIAC, telnetDo, optSGA,
+		telnetIAC, telnetWill, optComPort,
+	}
+}
+
+// subnegotiation builds a COM Port Control command, escaping its value.
+func subnegotiation(command byte, value ...byte) []byte {
+	out := []byte{telnetIAC, telnetSB, optComPort, command}
+	out = append(out, escape(value)...)
+	return append(out, telnetIAC, telnetSE)
+}
+
+// setBaud builds a SET-BAUDRATE command.
+func setBaud(baud int) []byte {
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint32(value, uint32(baud))
+	return subnegotiation(cpcSetBaudRate, value...)
+}
+
+// escape doubles IAC bytes so data is not taken for a command.
+func escape(p []byte) []byte {
+	out := make([]byte, 0, len(p))
+	for _, b := range p {
+		out = append(out, b)
+		if b == telnetIAC {
+			out = append(out, telnetIAC)
+		}
+	}
+	return out
+}
+
+// telnet states.
+const (
+	stateData = iota
+	stateIAC
+	stateOption
+	stateSB
+	stateSBIAC
+)
+
+// telnet separates serial data from Telnet commands in what the server
+// sends. Its state carries over between reads, since a command can be
+// split across them.
+type telnet struct {
+	state int
+	// verb is the WILL, WONT, DO or DONT waiting for its option.
+	verb byte
+}
+
+// accepted reports whether option is one the client agreed to.
+func accepted(option byte) bool {
+	return option == optBinary || option == optSGA || option == optComPort
+}
+
+// decode returns the data in p and the replies to send back: options the
+// client does not support are refused, everything else (including the
+// server's answers to COM Port Control commands) is dropped.
+func (t *telnet) decode(p []byte) (data, reply []byte) {
+	for _, b := range p {
+		switch t.state {
+		case stateData:
+			if b == telnetIAC {
+				t.state = stateIAC
+			} else {
+				data = append(data, b)
+			}
+		case stateIAC:
+			switch b {
+			case telnetIAC:
+				data = append(data, b)
+				t.state = stateData
+			case telnetWill, telnetWont, telnetDo, telnetDont:
+				t.verb = b
+				t.state = stateOption
+			case telnetSB:
+				t.state = stateSB
+			default:
+				t.state = stateData
+			}
+		case stateOption:
+			if !accepted(b) {
+				switch t.verb {
+				case telnetWill:
+					reply = append(reply, telnetIAC, telnetDont, b)
+				case telnetDo:
+					reply = append(reply, telnetIAC, telnetWont, b)
+				}
+			}
+			t.state = stateData
+		case stateSB:
+			if b == telnetIAC {
+				t.state = stateSBIAC
+			}
+		case stateSBIAC:
+			if b == telnetSE {
+				t.state = stateData
+			} else {
+				t.state = stateSB
+			}
+		}
+	}
+	return data, reply
+}
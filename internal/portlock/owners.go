@@ -0,0 +1,67 @@
+package portlock
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Owner is a process holding a port open.
+type Owner struct {
+	PID     int
+	Command string
+}
+
+func (o Owner) String() string {
+	return fmt.Sprintf("%s (pid %d)", o.Command, o.PID)
+}
+
+// ErrOwnersUnsupported is returned where open handles cannot be listed.
+var ErrOwnersUnsupported = errors.New("listing port owners is not supported on this platform")
+
+// Owners lists the processes holding port open, using lsof on Linux and
+// macOS.
+func Owners(port string) ([]Owner, error) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		return nil, ErrOwnersUnsupported
+	}
+	if resolved, err := filepath.EvalSymlinks(port); err == nil {
+		port = resolved
+	}
+	out, err := exec.Command("lsof", "-F", "pc", port).Output()
+	// lsof exits 1 when nothing holds the file.
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && len(out) == 0) {
+		return nil, fmt.Errorf("lsof: %w", err)
+	}
+	return ParseLsof(string(out)), nil
+}
+
+// ParseLsof reads lsof -F pc output: a "p<pid>" line starts each process,
+// followed by "c<command>".
+func ParseLsof(output string) []Owner {
+	var owners []Owner
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		switch line[0] {
+		case 'p':
+			if pid, err := strconv.Atoi(line[1:]); err == nil {
+				owners = append(owners, Owner{PID: pid})
+			}
+		case 'c':
+			if len(owners) > 0 {
+				owners[len(owners)-1].Command = line[1:]
+			}
+		}
+	}
+	return owners
+}
@@ -0,0 +1,211 @@
+// Package portlock lets a monitor session and a flash share a serial port.
+//
+// A monitor registers the port it holds with a small file under
+// ~/.alif/ports. A flash that finds the port busy looks for that
+// registration and, when the holder is one of ours, asks it to let go by
+// creating a release request next to it. The monitor closes the port,
+// acknowledges, and re-opens it once the request is removed (or the
+// requesting process has exited).
+package portlock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"alif-cli/internal/audit"
+)
+
+// PollInterval is how often both sides check the handoff files.
+const PollInterval = 200 * time.Millisecond
+
+// ReleaseTimeout bounds how long a flash waits for a monitor to let go.
+const ReleaseTimeout = 5 * time.Second
+
+// ErrNotOurs is returned by RequestRelease when no live session of ours
+// holds the port.
+var ErrNotOurs = errors.New("port is not held by an alif monitor session")
+
+// Registration is the content of a port's registration file.
+type Registration struct {
+	Port      string    `json:"port"`
+	PID       int       `json:"pid"`
+	Command   string    `json:"command"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// request is the content of a release request.
+type request struct {
+	PID int `json:"pid"`
+}
+
+// Dir returns the directory holding port registrations (~/.alif/ports).
+func Dir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".alif", "ports"), nil
+}
+
+// fileBase maps a port name to a file name, e.g. /dev/ttyACM0 -> dev_ttyACM0.
+// Symlinks (such as /dev/serial/by-id) are resolved so both names agree.
+func fileBase(port string) string {
+	if resolved, err := filepath.EvalSymlinks(port); err == nil {
+		port = resolved
+	}
+	name := strings.Trim(strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '.':
+			return '_'
+		}
+		return r
+	}, port), "_")
+	return strings.ToLower(name)
+}
+
+func regPath(dir, port string) string     { return filepath.Join(dir, fileBase(port)+".json") }
+func requestPath(dir, port string) string { return filepath.Join(dir, fileBase(port)+".release") }
+func ackPath(dir, port string) string     { return filepath.Join(dir, fileBase(port)+".released") }
+
+// Session is a registered hold on a port.
+type Session struct {
+	dir  string
+	port string
+}
+
+// Register records that this process holds port. A registration left by a
+// process that no longer runs is replaced.
+func Register(dir, port, command string) (*Session, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if reg, err := Lookup(dir, port); err == nil && reg.PID != os.Getpid() {
+		return nil, fmt.Errorf("%s is registered by %s (pid %d)", port, reg.Command, reg.PID)
+	}
+	os.Remove(requestPath(dir, port))
+	os.Remove(ackPath(dir, port))
+	content, err := json.MarshalIndent(Registration{Port: port, PID: os.Getpid(), Command: command, StartedAt: time.Now()}, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	if err := audit.WriteFile(regPath(dir, port), content, 0644); err != nil {
+		return nil, err
+	}
+	return &Session{dir: dir, port: port}, nil
+}
+
+// Close removes the registration and any pending handoff files.
+func (s *Session) Close() {
+	os.Remove(regPath(s.dir, s.port))
+	os.Remove(ackPath(s.dir, s.port))
+}
+
+// ReleaseRequested reports whether a flash has asked for the port.
+func (s *Session) ReleaseRequested() bool {
+	_, err := readRequest(s.dir, s.port)
+	return err == nil
+}
+
+// Released acknowledges a release request once the port is closed.
+func (s *Session) Released() error {
+	return audit.WriteFile(ackPath(s.dir, s.port), []byte(fmt.Sprintf("%d\n", os.Getpid())), 0644)
+}
+
+// WaitForReturn blocks until the requester removes its request or exits,
+// then clears the acknowledgement so the port can be re-opened.
+func (s *Session) WaitForReturn() {
+	for {
+		req, err := readRequest(s.dir, s.port)
+		if err != nil {
+			break
+		}
+		if !ProcessAlive(req.PID) {
+			os.Remove(requestPath(s.dir, s.port))
+			break
+		}
+		time.Sleep(PollInterval)
+	}
+	os.Remove(ackPath(s.dir, s.port))
+}
+
+// Lookup returns the live registration for port. Registrations whose
+// process has exited are removed and reported as not found.
+func Lookup(dir, port string) (*Registration, error) {
+	content, err := os.ReadFile(regPath(dir, port))
+	if err != nil {
+		return nil, err
+	}
+	var reg Registration
+	if err := json.Unmarshal(content, &reg); err != nil {
+		return nil, err
+	}
+	if !ProcessAlive(reg.PID) {
+		os.Remove(regPath(dir, port))
+		return nil, os.ErrNotExist
+	}
+	return &reg, nil
+}
+
+// RequestRelease asks the monitor session holding port to close it and
+// waits up to timeout for the acknowledgement. The returned function hands
+// the port back; it must be called once the caller is done with it.
+func RequestRelease(dir, port string, timeout time.Duration) (*Registration, func(), error) {
+	reg, err := Lookup(dir, port)
+	if err != nil {
+		return nil, nil, ErrNotOurs
+	}
+	content, _ := json.Marshal(request{PID: os.Getpid()})
+	if err := audit.WriteFile(requestPath(dir, port), content, 0644); err != nil {
+		return reg, nil, err
+	}
+	giveBack := func() { os.Remove(requestPath(dir, port)) }
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(ackPath(dir, port)); err == nil {
+			return reg, giveBack, nil
+		}
+		if !ProcessAlive(reg.PID) {
+			break
+		}
+		time.Sleep(PollInterval)
+	}
+	giveBack()
+	return reg, nil, fmt.Errorf("%s (pid %d) did not release %s within %s", reg.Command, reg.PID, port, timeout)
+}
+
+func readRequest(dir, port string) (*request, error) {
+	content, err := os.ReadFile(requestPath(dir, port))
+	if err != nil {
+		return nil, err
+	}
+	var req request
+	if err := json.Unmarshal(content, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// ProcessAlive reports whether a process with pid is running.
+func ProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// On Windows FindProcess fails for processes that do not exist.
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,314 @@
+package portlock
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The monitor side of the handoff runs in a second process: the test
+// binary re-executed with these variables set, so registration, release
+// and re-attach cross a real process boundary.
+const (
+	helperEnv     = "PORTLOCK_TEST_MONITOR"
+	helperDirEnv  = "PORTLOCK_TEST_DIR"
+	helperPortEnv = "PORTLOCK_TEST_PORT"
+)
+
+// TestHelperMonitor is not a test: it plays a monitor session that
+// registers the port, hands it over once on request and reports each step
+// on stdout.
+func TestHelperMonitor(t *testing.T) {
+	if os.Getenv(helperEnv) != "1" {
+		t.Skip("helper process")
+	}
+	s, err := Register(os.Getenv(helperDirEnv), os.Getenv(helperPortEnv), "alif monitor")
+	if err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	defer s.Close()
+	fmt.Println("registered")
+	deadline := time.Now().Add(10 * time.Second)
+	for !s.ReleaseRequested() {
+		if time.Now().After(deadline) {
+			fmt.Println("error: no release request")
+			os.Exit(1)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := s.Released(); err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	fmt.Println("released")
+	s.WaitForReturn()
+	fmt.Println("reattached")
+}
+
+// monitorProcess is a running helper monitor.
+type monitorProcess struct {
+	cmd   *exec.Cmd
+	lines chan string
+}
+
+func startMonitor(t *testing.T, dir, port string) *monitorProcess {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("process liveness checks are POSIX signals")
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperMonitor$")
+	cmd.Env = append(os.Environ(), helperEnv+"=1", helperDirEnv+"="+dir, helperPortEnv+"="+port)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	m := &monitorProcess{cmd: cmd, lines: make(chan string, 16)}
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			m.lines <- scanner.Text()
+		}
+		close(m.lines)
+	}()
+	t.Cleanup(func() { cmd.Process.Kill(); cmd.Wait() })
+	m.expect(t, "registered")
+	return m
+}
+
+// expect waits for the monitor to report step.
+func (m *monitorProcess) expect(t *testing.T, step string) {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case line, ok := <-m.lines:
+			if !ok {
+				t.Fatalf("monitor exited before %q", step)
+			}
+			if line == step {
+				return
+			}
+			if strings.HasPrefix(line, "error:") {
+				t.Fatalf("monitor: %s", line)
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for the monitor to report %q", step)
+		}
+	}
+}
+
+// deadPID returns the pid of a process that has already exited.
+func deadPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+func writeJSON(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	content, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandoff(t *testing.T) {
+	dir := t.TempDir()
+	const port = "/dev/ttyACM0"
+	m := startMonitor(t, dir, port)
+
+	reg, err := Lookup(dir, port)
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	if reg.PID != m.cmd.Process.Pid || reg.Command != "alif monitor" || reg.Port != port {
+		t.Errorf("registration = %+v, want pid %d", reg, m.cmd.Process.Pid)
+	}
+
+	reg, giveBack, err := RequestRelease(dir, port, ReleaseTimeout)
+	if err != nil {
+		t.Fatalf("RequestRelease: %v", err)
+	}
+	if reg.PID != m.cmd.Process.Pid {
+		t.Errorf("released by pid %d, want %d", reg.PID, m.cmd.Process.Pid)
+	}
+	m.expect(t, "released")
+
+	// The monitor holds off until the port is given back.
+	select {
+	case line := <-m.lines:
+		t.Fatalf("monitor reported %q before the port was given back", line)
+	case <-time.After(3 * PollInterval):
+	}
+	giveBack()
+	m.expect(t, "reattached")
+
+	if err := m.cmd.Wait(); err != nil {
+		t.Fatalf("monitor: %v", err)
+	}
+	for _, path := range []string{regPath(dir, port), requestPath(dir, port), ackPath(dir, port)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s left behind (%v)", filepath.Base(path), err)
+		}
+	}
+}
+
+func TestHandoffRequesterExits(t *testing.T) {
+	dir := t.TempDir()
+	const port = "/dev/ttyACM0"
+	m := startMonitor(t, dir, port)
+
+	// A flash that asked for the port and died without giving it back.
+	writeJSON(t, requestPath(dir, port), request{PID: deadPID(t)})
+	m.expect(t, "released")
+	m.expect(t, "reattached")
+	if _, err := os.Stat(requestPath(dir, port)); !os.IsNotExist(err) {
+		t.Errorf("stale request left behind (%v)", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "ports")
+	const port = "/dev/ttyACM0"
+
+	s, err := Register(dir, port, "alif monitor")
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	reg, err := Lookup(dir, port)
+	if err != nil || reg.PID != os.Getpid() {
+		t.Fatalf("Lookup = %+v, %v; want our pid", reg, err)
+	}
+	// Registering again from the same process is allowed.
+	if _, err := Register(dir, port, "alif run"); err != nil {
+		t.Errorf("re-register: %v", err)
+	}
+	s.Close()
+	if _, err := Lookup(dir, port); !os.IsNotExist(err) {
+		t.Errorf("Lookup after Close = %v, want not found", err)
+	}
+}
+
+func TestRegisterHeldByOther(t *testing.T) {
+	dir := t.TempDir()
+	const port = "/dev/ttyACM0"
+	startMonitor(t, dir, port)
+
+	_, err := Register(dir, port, "alif run")
+	if err == nil || !strings.Contains(err.Error(), "registered by alif monitor") {
+		t.Errorf("Register = %v, want the monitor's registration reported", err)
+	}
+}
+
+func TestRegisterStale(t *testing.T) {
+	dir := t.TempDir()
+	const port = "/dev/ttyACM0"
+	if runtime.GOOS == "windows" {
+		t.Skip("process liveness checks are POSIX signals")
+	}
+	pid := deadPID(t)
+	writeJSON(t, regPath(dir, port), Registration{Port: port, PID: pid, Command: "alif monitor"})
+	writeJSON(t, requestPath(dir, port), request{PID: pid})
+
+	if _, err := Lookup(dir, port); !os.IsNotExist(err) {
+		t.Errorf("Lookup of a dead session = %v, want not found", err)
+	}
+	writeJSON(t, regPath(dir, port), Registration{Port: port, PID: pid, Command: "alif monitor"})
+	if _, err := Register(dir, port, "alif run"); err != nil {
+		t.Fatalf("Register over a dead session: %v", err)
+	}
+	if _, err := os.Stat(requestPath(dir, port)); !os.IsNotExist(err) {
+		t.Errorf("old release request kept (%v)", err)
+	}
+}
+
+func TestRequestRelease(t *testing.T) {
+	dir := t.TempDir()
+	const port = "/dev/ttyACM0"
+
+	if _, _, err := RequestRelease(dir, port, time.Second); !errors.Is(err, ErrNotOurs) {
+		t.Errorf("unregistered port: error = %v, want ErrNotOurs", err)
+	}
+
+	// A session that never acknowledges: the request is withdrawn on timeout.
+	s, err := Register(dir, port, "alif monitor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	_, giveBack, err := RequestRelease(dir, port, 2*PollInterval)
+	if err == nil || giveBack != nil || !strings.Contains(err.Error(), "did not release") {
+		t.Errorf("unacknowledged request: error = %v, want a timeout", err)
+	}
+	if s.ReleaseRequested() {
+		t.Error("request left behind after the timeout")
+	}
+}
+
+func TestFileBase(t *testing.T) {
+	tests := []struct {
+		port, want string
+	}{
+		{"/dev/ttyACM0", "dev_ttyacm0"},
+		{"/dev/cu.usbmodem0001", "dev_cu_usbmodem0001"},
+		{"COM3", "com3"},
+		{`\\.\COM12`, "com12"},
+	}
+	for _, tt := range tests {
+		if got := fileBase(tt.port); got != tt.want {
+			t.Errorf("fileBase(%q) = %q, want %q", tt.port, got, tt.want)
+		}
+	}
+
+	// A by-id link and the device it points at share one registration.
+	if runtime.GOOS != "windows" {
+		dev := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dev, "ttyACM0"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		link := filepath.Join(dev, "usb-Alif_DevKit-if00")
+		if err := os.Symlink("ttyACM0", link); err != nil {
+			t.Fatal(err)
+		}
+		if a, b := fileBase(link), fileBase(filepath.Join(dev, "ttyACM0")); a != b {
+			t.Errorf("link and device map to %q and %q", a, b)
+		}
+	}
+}
+
+func TestParseLsof(t *testing.T) {
+	tests := []struct {
+		name, output string
+		want         []Owner
+	}{
+		{"empty", "", nil},
+		{"one", "p4242\ncscreen\nf3\n", []Owner{{PID: 4242, Command: "screen"}}},
+		{"two", "p100\ncminicom\np200\ncalif\n", []Owner{{PID: 100, Command: "minicom"}, {PID: 200, Command: "alif"}}},
+		{"no command", "p7\n", []Owner{{PID: 7}}},
+		{"bad pid", "pxyz\ncscreen\n", nil},
+	}
+	for _, tt := range tests {
+		if got := ParseLsof(tt.output); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ParseLsof = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
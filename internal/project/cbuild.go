@@ -0,0 +1,252 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// ContextOutput describes where a build context places its artifacts, as
+// declared in its <context>.cbuild.yml.
+type ContextOutput struct {
+	Context string
+	// File is the path of the .cbuild.yml the data came from.
+	File string
+	// OutDir is the absolute output directory.
+	OutDir string
+	// Bin and Elf are the declared output file names (empty if not declared).
+	Bin string
+	Elf string
+	Map string
+	// Device is build.device, e.g. "Alif Semiconductor::AE722F80F55D5LS:M55_HE".
+	Device string
+}
+
+// BinPath returns the absolute path of the declared bin output.
+func (c *ContextOutput) BinPath() string {
+	if c.Bin == "" {
+		return ""
+	}
+	return filepath.Join(c.OutDir, c.Bin)
+}
+
+// skipDirs are never searched for .cbuild.yml files.
+var skipDirs = map[string]bool{
+	".git": true, "packs": true, "tools": true, "node_modules": true, "out": true, "tmp": true,
+}
+
+// FindCbuildFiles maps every context with a .cbuild.yml under solDir to
+// that file's path.
+func FindCbuildFiles(solDir string) map[string]string {
+	found := make(map[string]string)
+	filepath.Walk(solDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if path != solDir && skipDirs[info.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if name := info.Name(); strings.HasSuffix(name, ".cbuild.yml") {
+			found[strings.TrimSuffix(name, ".cbuild.yml")] = path
+		}
+		return nil
+	})
+	return found
+}
+
+// FindCbuildFile returns the <context>.cbuild.yml under solDir, or "" when
+// the context has none.
+func FindCbuildFile(solDir, context string) string {
+	target := context + ".cbuild.yml"
+	found := ""
+	filepath.Walk(solDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if path != solDir && skipDirs[info.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Name() == target {
+			found = path
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found
+}
+
+// FindContextOutput reads the .cbuild.yml of context under solDir.
+func FindContextOutput(solDir, context string) (*ContextOutput, error) {
+	file := FindCbuildFile(solDir, context)
+	if file == "" {
+		return nil, fmt.Errorf("build configuration file '%s.cbuild.yml' not found", context)
+	}
+	return ReadContextOutput(file)
+}
+
+// ReadContextOutput parses the output section of a .cbuild.yml. Both the
+// list form of build.output and a map keyed by output type are accepted.
+func ReadContextOutput(path string) (*ContextOutput, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+	}
+
+	out := &ContextOutput{
+		Context: strings.TrimSuffix(filepath.Base(path), ".cbuild.yml"),
+		File:    path,
+		OutDir:  filepath.Join(filepath.Dir(path), v.GetString("build.output-dirs.outdir")),
+		Device:  v.GetString("build.device"),
+	}
+
+	switch outputs := v.Get("build.output").(type) {
+	case []interface{}:
+		for _, o := range outputs {
+			omap, ok := o.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			kind, _ := omap["type"].(string)
+			file, _ := omap["file"].(string)
+			out.setOutput(kind, file)
+		}
+	case map[string]interface{}:
+		for kind, file := range outputs {
+			if f, ok := file.(string); ok {
+				out.setOutput(kind, f)
+			}
+		}
+	}
+	return out, nil
+}
+
+// Artifacts returns the absolute bin and elf paths the context declares.
+// Without a bin output, bin is the elf's name with .bin in the same folder,
+// where the elf is converted to. Either is "" when nothing names it.
+func (c *ContextOutput) Artifacts() (bin, elf string) {
+	if c.Elf != "" {
+		elf = filepath.Join(c.OutDir, c.Elf)
+	}
+	switch {
+	case c.Bin != "":
+		bin = c.BinPath()
+	case c.Elf != "":
+		bin = strings.TrimSuffix(elf, filepath.Ext(elf)) + ".bin"
+	}
+	return bin, elf
+}
+
+// PartNumber returns the part number from Device, e.g. "AE722F80F55D5LS".
+func (c *ContextOutput) PartNumber() string {
+	device := c.Device
+	if idx := strings.Index(device, "::"); idx != -1 {
+		device = device[idx+2:]
+	}
+	return strings.Split(device, ":")[0]
+}
+
+// MapPath returns the declared linker map, or the one written next to the
+// elf (<elf>.map). Returns "" if there is none.
+func (c *ContextOutput) MapPath() string {
+	if c.Map != "" {
+		return filepath.Join(c.OutDir, c.Map)
+	}
+	if c.Elf == "" {
+		return ""
+	}
+	base := strings.TrimSuffix(c.Elf, filepath.Ext(c.Elf))
+	for _, name := range []string{base + ".map", c.Elf + ".map"} {
+		p := filepath.Join(c.OutDir, name)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+func (c *ContextOutput) setOutput(kind, file string) {
+	switch kind {
+	case "bin":
+		c.Bin = file
+	case "elf":
+		c.Elf = file
+	case "map":
+		c.Map = file
+	}
+}
+
+// ReadAllContextOutputs parses every .cbuild.yml under solDir, skipping
+// files that cannot be read.
+func ReadAllContextOutputs(solDir string) []*ContextOutput {
+	var outputs []*ContextOutput
+	for _, path := range FindCbuildFiles(solDir) {
+		if o, err := ReadContextOutput(path); err == nil {
+			outputs = append(outputs, o)
+		}
+	}
+	return outputs
+}
+
+// SharingOutDir returns the other contexts that write into the same output
+// directory as selected.
+func SharingOutDir(all []*ContextOutput, selected *ContextOutput) []*ContextOutput {
+	var shared []*ContextOutput
+	for _, o := range all {
+		if o.Context != selected.Context && filepath.Clean(o.OutDir) == filepath.Clean(selected.OutDir) {
+			shared = append(shared, o)
+		}
+	}
+	return shared
+}
+
+// CheckSharedOutDir decides whether selected's binary can be trusted when
+// other contexts share its output directory. Identical bin names make the
+// artifact ambiguous and return an error; a newer binary from another
+// context only produces a warning. modTime looks up file timestamps.
+func CheckSharedOutDir(selected *ContextOutput, shared []*ContextOutput, modTime func(string) (time.Time, bool)) ([]string, error) {
+	var warnings []string
+	if len(shared) == 0 {
+		return nil, nil
+	}
+
+	selTime, selExists := modTime(selected.BinPath())
+	for _, o := range shared {
+		if o.Bin != "" && o.Bin == selected.Bin {
+			return warnings, fmt.Errorf("contexts %s and %s both write %s into %s; the binary cannot be attributed to one of them",
+				selected.Context, o.Context, selected.Bin, selected.OutDir)
+		}
+		if t, ok := modTime(o.BinPath()); ok && selExists && t.After(selTime) {
+			warnings = append(warnings, fmt.Sprintf("%s from context %s is newer than %s in the shared output directory",
+				o.Bin, o.Context, selected.Bin))
+		}
+	}
+	if !selExists {
+		return warnings, fmt.Errorf("%s for context %s not found in shared output directory %s",
+			selected.Bin, selected.Context, selected.OutDir)
+	}
+	return warnings, nil
+}
+
+// FileModTime looks up a file's modification time for CheckSharedOutDir.
+func FileModTime(path string) (time.Time, bool) {
+	if path == "" {
+		return time.Time{}, false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return info.ModTime(), true
+}
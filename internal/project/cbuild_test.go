@@ -0,0 +1,146 @@
+package project
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSharingOutDir(t *testing.T) {
+	he := &ContextOutput{Context: "blinky.debug+E7-HE", OutDir: "/p/out/blinky"}
+	hp := &ContextOutput{Context: "blinky.debug+E7-HP", OutDir: "/p/out/blinky/"}
+	rel := &ContextOutput{Context: "blinky.release+E7-HE", OutDir: "/p/out/blinky/release"}
+	all := []*ContextOutput{he, hp, rel}
+
+	tests := []struct {
+		selected *ContextOutput
+		want     []string
+	}{
+		{he, []string{hp.Context}},
+		{hp, []string{he.Context}},
+		{rel, nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, o := range SharingOutDir(all, tt.selected) {
+			got = append(got, o.Context)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("SharingOutDir(%s) = %v, want %v", tt.selected.Context, got, tt.want)
+		}
+	}
+}
+
+func TestCheckSharedOutDir(t *testing.T) {
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	const dir = "/p/out"
+	he := &ContextOutput{Context: "app+E7-HE", OutDir: dir, Bin: "he.bin"}
+	hp := &ContextOutput{Context: "app+E7-HP", OutDir: dir, Bin: "hp.bin"}
+	sameName := &ContextOutput{Context: "app+E7-HP", OutDir: dir, Bin: "he.bin"}
+	elfOnly := &ContextOutput{Context: "app+E7-HP", OutDir: dir, Elf: "hp.elf"}
+
+	tests := []struct {
+		name      string
+		shared    []*ContextOutput
+		times     map[string]time.Time
+		warnings  int
+		errSubstr string
+	}{
+		{"nothing shared", nil, nil, 0, ""},
+		{"selected is newer", []*ContextOutput{hp}, map[string]time.Time{
+			"/p/out/he.bin": base.Add(time.Minute), "/p/out/hp.bin": base,
+		}, 0, ""},
+		{"other context is newer", []*ContextOutput{hp}, map[string]time.Time{
+			"/p/out/he.bin": base, "/p/out/hp.bin": base.Add(time.Minute),
+		}, 1, ""},
+		{"other context never built", []*ContextOutput{hp}, map[string]time.Time{
+			"/p/out/he.bin": base,
+		}, 0, ""},
+		{"same bin name", []*ContextOutput{sameName}, map[string]time.Time{
+			"/p/out/he.bin": base,
+		}, 0, "cannot be attributed"},
+		{"selected bin missing", []*ContextOutput{hp}, map[string]time.Time{
+			"/p/out/hp.bin": base,
+		}, 0, "not found"},
+		{"other declares no bin", []*ContextOutput{elfOnly}, map[string]time.Time{
+			"/p/out/he.bin": base,
+		}, 0, ""},
+	}
+	for _, tt := range tests {
+		modTime := func(path string) (time.Time, bool) {
+			at, ok := tt.times[path]
+			return at, ok
+		}
+		warnings, err := CheckSharedOutDir(he, tt.shared, modTime)
+		if len(warnings) != tt.warnings {
+			t.Errorf("%s: warnings %q, want %d", tt.name, warnings, tt.warnings)
+		}
+		switch {
+		case tt.errSubstr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.errSubstr != "" && (err == nil || !strings.Contains(err.Error(), tt.errSubstr)):
+			t.Errorf("%s: error = %v, want one mentioning %q", tt.name, err, tt.errSubstr)
+		}
+	}
+}
+
+func TestReadContextOutput(t *testing.T) {
+	dir, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		file      string
+		outDir    string
+		bin, elf  string
+		part      string
+		artBin    string
+		errSubstr string
+	}{
+		// build.output as a list of {type, file}, as csolution writes it.
+		{"list", "../out/blinky/E7-HE/debug", "blinky.bin", "blinky.elf", "AE722F80F55D5LS", "../out/blinky/E7-HE/debug/blinky.bin", ""},
+		// build.output as a map keyed by output type.
+		{"map", "out", "blinky.bin", "blinky.elf", "AE722F80F55D5LS", "out/blinky.bin", ""},
+		// No bin output: the bin is made from the elf, next to it.
+		{"elf-only", "out", "", "blinky.elf", "AE722F80F55D5LS", "out/blinky.bin", ""},
+		// Entries without a type and file name are skipped.
+		{"no-output", "out", "", "", "AE722F80F55D5LS", "", ""},
+		{"broken", "", "", "", "", "", "failed to read broken.cbuild.yml"},
+		{"missing", "", "", "", "", "", "failed to read missing.cbuild.yml"},
+	}
+	for _, tt := range tests {
+		out, err := ReadContextOutput(filepath.Join(dir, tt.file+".cbuild.yml"))
+		switch {
+		case tt.errSubstr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.file, err)
+			continue
+		case tt.errSubstr != "":
+			if err == nil || !strings.Contains(err.Error(), tt.errSubstr) {
+				t.Errorf("%s: error = %v, want one mentioning %q", tt.file, err, tt.errSubstr)
+			}
+			continue
+		}
+		if out.Context != tt.file {
+			t.Errorf("%s: context %q", tt.file, out.Context)
+		}
+		if want := filepath.Join(dir, tt.outDir); out.OutDir != want {
+			t.Errorf("%s: OutDir %q, want %q", tt.file, out.OutDir, want)
+		}
+		if out.Bin != tt.bin || out.Elf != tt.elf {
+			t.Errorf("%s: bin %q, elf %q, want %q, %q", tt.file, out.Bin, out.Elf, tt.bin, tt.elf)
+		}
+		if got := out.PartNumber(); got != tt.part {
+			t.Errorf("%s: part number %q, want %q", tt.file, got, tt.part)
+		}
+		bin, _ := out.Artifacts()
+		if want := tt.artBin; want != "" {
+			want = filepath.Join(dir, want)
+			if bin != want {
+				t.Errorf("%s: artifact bin %q, want %q", tt.file, bin, want)
+			}
+		} else if bin != "" {
+			t.Errorf("%s: artifact bin %q, want none", tt.file, bin)
+		}
+	}
+}
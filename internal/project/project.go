@@ -5,6 +5,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/spf13/viper"
 )
 
 // IsSolutionRoot checks if the given directory (or current dir if empty)
@@ -70,3 +72,21 @@ func FindCsolution(dir string) (string, error) {
 	// Return the first one found
 	return files[0], nil
 }
+
+// SolutionCompiler returns the toolchain the .csolution.yml in dir selects
+// with its solution-level compiler: node, without the version ("AC6" for
+// "AC6@>=6.22.0"), or "" when it names none.
+func SolutionCompiler(dir string) string {
+	path, err := FindCsolution(dir)
+	if err != nil {
+		return ""
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return ""
+	}
+	compiler, _, _ := strings.Cut(v.GetString("solution.compiler"), "@")
+	return strings.TrimSpace(compiler)
+}
@@ -0,0 +1,132 @@
+// Package remote runs alif-cli on another host over ssh, for boards that
+// are attached to a shared lab machine rather than the build host.
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"alif-cli/internal/ui"
+)
+
+// sshFailed is the exit status ssh itself uses for connection and
+// authentication failures.
+const sshFailed = 255
+
+// batchOptions make ssh and scp fail instead of prompting, so only the
+// user's agent and keys are used.
+var batchOptions = []string{"-o", "BatchMode=yes"}
+
+// Host is a machine reached with the system's ssh and scp clients.
+type Host struct {
+	// Target is the ssh destination, e.g. "user@lab-host".
+	Target string
+	// Report receives progress output; New sets it to ui.Console.
+	Report ui.Reporter
+}
+
+// ExitError is a non-zero exit status of the command run on the host.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("the remote command exited with status %d", e.Code)
+}
+
+// New checks the destination and that ssh and scp are installed.
+func New(target string) (*Host, error) {
+	if target == "" || strings.HasPrefix(target, "-") || strings.ContainsAny(target, " \t") {
+		return nil, fmt.Errorf("invalid remote host '%s' (use user@host)", target)
+	}
+	for _, tool := range []string{"ssh", "scp"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			return nil, fmt.Errorf("%s not found on the PATH; install an OpenSSH client", tool)
+		}
+	}
+	return &Host{Target: target, Report: ui.Console}, nil
+}
+
+// TempDir creates a private directory on the host and returns its path.
+func (h *Host) TempDir() (string, error) {
+	out, err := h.command(false, "mktemp", "-d", "-t", "alif-remote.XXXXXX").Output()
+	if err != nil {
+		return "", h.explain("could not create a directory on "+h.Target, err)
+	}
+	dir := strings.TrimSpace(string(out))
+	if dir == "" {
+		return "", fmt.Errorf("mktemp on %s printed no directory", h.Target)
+	}
+	return dir, nil
+}
+
+// Copy uploads files into dir on the host, keeping their names.
+func (h *Host) Copy(files []string, dir string) error {
+	args := append(append([]string{"-q"}, batchOptions...), files...)
+	args = append(args, h.Target+":"+Quote(dir)+"/")
+	cmd := exec.Command("scp", args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("scp to %s failed: %w\n%s", h.Target, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Run runs args on the host with its output streamed to ours. With tty the
+// remote gets a terminal, so its spinners and progress bars draw as they
+// would locally. A non-zero remote exit status is returned as *ExitError.
+func (h *Host) Run(args []string, tty bool) error {
+	cmd := h.command(tty, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return h.explain("could not run alif on "+h.Target, err)
+	}
+	return nil
+}
+
+// Remove deletes dir on the host, warning when that fails.
+func (h *Host) Remove(dir string) {
+	if err := h.command(false, "rm", "-rf", dir).Run(); err != nil {
+		h.Report.Warn(fmt.Sprintf("Failed to remove %s on %s: %v", dir, h.Target, err))
+	}
+}
+
+// command builds an ssh run of args, each quoted for the remote shell.
+func (h *Host) command(tty bool, args ...string) *exec.Cmd {
+	sshArgs := append([]string{}, batchOptions...)
+	if tty {
+		sshArgs = append(sshArgs, "-t")
+	}
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = Quote(a)
+	}
+	sshArgs = append(sshArgs, h.Target, "--", strings.Join(quoted, " "))
+	return exec.Command("ssh", sshArgs...)
+}
+
+// explain turns an ssh failure into an error: the remote command's own
+// exit status as *ExitError, or a connection problem with a hint.
+func (h *Host) explain(what string, err error) error {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return fmt.Errorf("%s: %w", what, err)
+	}
+	if code := exitErr.ExitCode(); code != sshFailed {
+		return &ExitError{Code: code}
+	}
+	return fmt.Errorf("%s: ssh failed; check that 'ssh %s' logs in without a password (ssh-agent or a key)", what, h.Target)
+}
+
+// Quote quotes s for a POSIX shell unless it only holds safe characters.
+func Quote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789@%+=:,./_-") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,235 @@
+// Package rescue walks a user through getting an unresponsive board back:
+// a decision tree, defined as data, of questions about what they can see,
+// automated checks and recovery actions. Every walk ends with a
+// conclusion and the findings that led there, which describe the board's
+// state precisely enough for a support request.
+package rescue
+
+import (
+	"fmt"
+	"strings"
+
+	"alif-cli/internal/ui"
+)
+
+// Step kinds.
+const (
+	// Question asks the user a yes/no question about the hardware.
+	Question = "question"
+	// Check runs an automated check; it passes when it returns no error.
+	Check = "check"
+	// Action asks for confirmation, then runs a recovery action.
+	Action = "action"
+	// End concludes the walk.
+	End = "end"
+)
+
+// maxSteps bounds a walk, so a tree with a cycle (e.g. recheck after a
+// recovery) cannot loop forever.
+const maxSteps = 50
+
+// Step is one node of a Tree.
+type Step struct {
+	ID   string
+	Kind string
+	// Text is the question, the check's description, the action's
+	// confirmation prompt or the conclusion.
+	Text string
+	// Run names the check or action in Engine.Checks or Engine.Actions.
+	Run string
+	// Yes follows a yes answer, a passing check or a successful action;
+	// No follows a no answer, a failed check, or a declined or failed
+	// action. End steps have neither.
+	Yes, No string
+}
+
+// Tree is a decision tree starting at Start.
+type Tree struct {
+	Start string
+	Steps []Step
+}
+
+// step returns the step called id.
+func (t Tree) step(id string) (Step, bool) {
+	for _, s := range t.Steps {
+		if s.ID == id {
+			return s, true
+		}
+	}
+	return Step{}, false
+}
+
+// Validate checks that every step leads to steps that exist, that checks
+// and actions name one, and that IDs are unique.
+func (t Tree) Validate() error {
+	seen := map[string]bool{}
+	for _, s := range t.Steps {
+		if seen[s.ID] {
+			return fmt.Errorf("step %s is defined twice", s.ID)
+		}
+		seen[s.ID] = true
+	}
+	if !seen[t.Start] {
+		return fmt.Errorf("start step %s does not exist", t.Start)
+	}
+	for _, s := range t.Steps {
+		switch s.Kind {
+		case End:
+			if s.Yes != "" || s.No != "" {
+				return fmt.Errorf("end step %s has a next step", s.ID)
+			}
+			continue
+		case Check, Action:
+			if s.Run == "" {
+				return fmt.Errorf("%s step %s names nothing to run", s.Kind, s.ID)
+			}
+		case Question:
+		default:
+			return fmt.Errorf("step %s has unknown kind '%s'", s.ID, s.Kind)
+		}
+		for _, next := range []string{s.Yes, s.No} {
+			if !seen[next] {
+				return fmt.Errorf("step %s leads to unknown step '%s'", s.ID, next)
+			}
+		}
+	}
+	return nil
+}
+
+// Finding is what one step of a walk found out.
+type Finding struct {
+	Step Step
+	// Passed is the answer (yes), the check's result, or whether the
+	// action ran and succeeded.
+	Passed bool
+	// Detail is what a check or action reported, such as the ports found
+	// or the error.
+	Detail string
+}
+
+// String formats f as a line of the hardware state summary.
+func (f Finding) String() string {
+	var result string
+	switch f.Step.Kind {
+	case Question:
+		result = map[bool]string{true: "yes", false: "no"}[f.Passed]
+	case Check:
+		result = map[bool]string{true: "passed", false: "failed"}[f.Passed]
+	case Action:
+		result = map[bool]string{true: "done", false: "not done"}[f.Passed]
+	}
+	line := fmt.Sprintf("%s: %s", f.Step.Text, result)
+	if f.Detail != "" {
+		line += " (" + f.Detail + ")"
+	}
+	return line
+}
+
+// Outcome is the result of a walk.
+type Outcome struct {
+	Findings   []Finding
+	Conclusion Step
+}
+
+// Summary describes the board's state: the conclusion and every finding
+// in order, for a support request.
+func (o *Outcome) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Conclusion: %s\n", o.Conclusion.Text)
+	b.WriteString("Findings:\n")
+	for _, f := range o.Findings {
+		fmt.Fprintf(&b, "  - %s\n", f)
+	}
+	return b.String()
+}
+
+// Engine walks a Tree. Ask, Checks and Actions are its only contact with
+// the user and the hardware.
+type Engine struct {
+	Tree Tree
+	// Ask puts a yes/no question to the user.
+	Ask func(question string) (bool, error)
+	// Checks returns a detail to show and nil when the check passes.
+	Checks map[string]func() (string, error)
+	// Actions run recovery steps the user confirmed.
+	Actions map[string]func() error
+	Report  ui.Reporter
+}
+
+// Run walks the tree from its start to an End step. It fails on a tree
+// that does not validate, a check or action it has no function for, or
+// when the user cannot be asked.
+func (e *Engine) Run() (*Outcome, error) {
+	if err := e.Tree.Validate(); err != nil {
+		return nil, err
+	}
+	out := &Outcome{}
+	id := e.Tree.Start
+	for range maxSteps {
+		s, _ := e.Tree.step(id)
+		if s.Kind == End {
+			out.Conclusion = s
+			return out, nil
+		}
+		f, err := e.do(s)
+		if err != nil {
+			return out, err
+		}
+		out.Findings = append(out.Findings, f)
+		if f.Passed {
+			id = s.Yes
+		} else {
+			id = s.No
+		}
+	}
+	return out, fmt.Errorf("gave up after %d steps without a conclusion", maxSteps)
+}
+
+// do carries out one step that is not an End.
+func (e *Engine) do(s Step) (Finding, error) {
+	f := Finding{Step: s}
+	switch s.Kind {
+	case Question:
+		yes, err := e.Ask(s.Text)
+		if err != nil {
+			return f, err
+		}
+		f.Passed = yes
+	case Check:
+		check, ok := e.Checks[s.Run]
+		if !ok {
+			return f, fmt.Errorf("no check called '%s'", s.Run)
+		}
+		sp := e.Report.StartTask(s.Text + "...")
+		detail, err := check()
+		f.Passed, f.Detail = err == nil, detail
+		if err != nil {
+			if f.Detail == "" {
+				f.Detail = err.Error()
+			}
+			sp.Fail(fmt.Sprintf("%s: %s", s.Text, f.Detail))
+		} else {
+			sp.Succeed(strings.TrimSuffix(fmt.Sprintf("%s: %s", s.Text, detail), ": "))
+		}
+	case Action:
+		action, ok := e.Actions[s.Run]
+		if !ok {
+			return f, fmt.Errorf("no action called '%s'", s.Run)
+		}
+		yes, err := e.Ask(s.Text)
+		if err != nil {
+			return f, err
+		}
+		if !yes {
+			f.Detail = "declined"
+			return f, nil
+		}
+		if err := action(); err != nil {
+			f.Detail = err.Error()
+			e.Report.Warn(fmt.Sprintf("%s failed: %v", s.Run, err))
+			return f, nil
+		}
+		f.Passed = true
+	}
+	return f, nil
+}
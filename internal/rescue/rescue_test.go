@@ -0,0 +1,296 @@
+package rescue
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"alif-cli/internal/ui"
+)
+
+type warnings struct {
+	ui.Reporter
+	list []string
+}
+
+func (w *warnings) Warn(msg string) { w.list = append(w.list, msg) }
+
+// script drives an Engine: the user's answers in order, and the results
+// of each check and action in the order they are run. A false action
+// result is a failure.
+type script struct {
+	answers []bool
+	checks  map[string][]bool
+	actions map[string][]bool
+
+	asked []string
+	ran   []string
+}
+
+func (s *script) engine(t *testing.T, tree Tree, r ui.Reporter) *Engine {
+	next := func(results map[string][]bool, name string) bool {
+		if len(results[name]) == 0 {
+			t.Fatalf("%s run more often than scripted", name)
+		}
+		ok := results[name][0]
+		results[name] = results[name][1:]
+		return ok
+	}
+	e := &Engine{
+		Tree: tree,
+		Ask: func(question string) (bool, error) {
+			if len(s.answers) == 0 {
+				t.Fatalf("unscripted question %q", question)
+			}
+			s.asked = append(s.asked, question)
+			yes := s.answers[0]
+			s.answers = s.answers[1:]
+			return yes, nil
+		},
+		Checks:  map[string]func() (string, error){},
+		Actions: map[string]func() error{},
+		Report:  r,
+	}
+	for _, name := range []string{CheckPorts, CheckISP, CheckJLink} {
+		e.Checks[name] = func() (string, error) {
+			if next(s.checks, name) {
+				return "/dev/ttyACM0", nil
+			}
+			return "", errors.New(name + " not found")
+		}
+	}
+	for _, name := range []string{ActionAutoISP, ActionErase, ActionRecover} {
+		e.Actions[name] = func() error {
+			s.ran = append(s.ran, name)
+			if next(s.actions, name) {
+				return nil
+			}
+			return errors.New("no response")
+		}
+	}
+	return e
+}
+
+// stepIDs lists the steps of findings.
+func stepIDs(findings []Finding) []string {
+	var ids []string
+	for _, f := range findings {
+		ids = append(ids, f.Step.ID)
+	}
+	return ids
+}
+
+func TestDefaultTreePaths(t *testing.T) {
+	tests := []struct {
+		name       string
+		script     script
+		steps      []string
+		conclusion string
+		ran        []string
+		warnings   int
+	}{
+		{"no power",
+			script{answers: []bool{false}},
+			[]string{"power"}, "no-power", nil, 0},
+		{"healthy board",
+			script{answers: []bool{true, false}, checks: map[string][]bool{CheckPorts: {true}, CheckISP: {true}}},
+			[]string{"power", "ports", "isp", "isp-ok"}, "isp-healthy", nil, 0},
+		{"reset into ISP over DTR/RTS, then erase",
+			script{
+				answers: []bool{true, true, true},
+				checks:  map[string][]bool{CheckPorts: {true}, CheckISP: {false, true}},
+				actions: map[string][]bool{ActionAutoISP: {true}, ActionErase: {true}},
+			},
+			[]string{"power", "ports", "isp", "auto-isp", "isp-after-reset", "isp-ok"}, "erased",
+			[]string{ActionAutoISP, ActionErase}, 0},
+		{"reset over DTR/RTS fails, manual reset works",
+			script{
+				answers: []bool{true, true, true, false},
+				checks:  map[string][]bool{CheckPorts: {true}, CheckISP: {false, true}},
+				actions: map[string][]bool{ActionAutoISP: {false}},
+			},
+			[]string{"power", "ports", "isp", "auto-isp", "reset-by-hand", "isp-after-manual", "isp-ok"}, "isp-healthy",
+			[]string{ActionAutoISP}, 1},
+		{"manual reset fails, holding ISP works",
+			script{
+				answers: []bool{true, false, true, true, true},
+				checks:  map[string][]bool{CheckPorts: {true, true}, CheckISP: {false, false, true}},
+				actions: map[string][]bool{ActionErase: {true}},
+			},
+			[]string{"power", "ports", "isp", "auto-isp", "reset-by-hand", "isp-after-manual", "hold-isp", "ports-held", "isp-held", "isp-ok"}, "erased",
+			[]string{ActionErase}, 0},
+		{"no port, J-Link recovery",
+			script{
+				answers: []bool{true, true, true},
+				checks:  map[string][]bool{CheckPorts: {false, false}, CheckJLink: {true}, CheckISP: {true}},
+				actions: map[string][]bool{ActionRecover: {true}},
+			},
+			[]string{"power", "ports", "hold-isp", "ports-held", "jlink", "recover", "isp-after-recover"}, "recovered",
+			[]string{ActionRecover}, 0},
+		{"J-Link recovery does not bring ISP back",
+			script{
+				answers: []bool{true, false, true, true},
+				checks:  map[string][]bool{CheckPorts: {false}, CheckJLink: {false, true}, CheckISP: {false}},
+				actions: map[string][]bool{ActionRecover: {true}},
+			},
+			[]string{"power", "ports", "hold-isp", "jlink", "attach-jlink", "jlink-attached", "recover", "isp-after-recover"}, "recover-no-isp",
+			[]string{ActionRecover}, 0},
+		{"J-Link recovery declined",
+			script{
+				answers: []bool{true, false, false},
+				checks:  map[string][]bool{CheckPorts: {false}, CheckJLink: {true}},
+			},
+			[]string{"power", "ports", "hold-isp", "jlink", "recover"}, "not-recovered", nil, 0},
+		{"nothing reaches the board",
+			script{
+				answers: []bool{true, false, false},
+				checks:  map[string][]bool{CheckPorts: {false}, CheckJLink: {false}},
+			},
+			[]string{"power", "ports", "hold-isp", "jlink", "attach-jlink"}, "no-jlink", nil, 0},
+	}
+	for _, tt := range tests {
+		w := &warnings{Reporter: ui.Silent}
+		s := tt.script
+		out, err := s.engine(t, DefaultTree, w).Run()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := stepIDs(out.Findings); !reflect.DeepEqual(got, tt.steps) {
+			t.Errorf("%s: steps %q, want %q", tt.name, got, tt.steps)
+		}
+		if out.Conclusion.ID != tt.conclusion {
+			t.Errorf("%s: conclusion %s, want %s", tt.name, out.Conclusion.ID, tt.conclusion)
+		}
+		if !reflect.DeepEqual(s.ran, tt.ran) {
+			t.Errorf("%s: ran %q, want %q", tt.name, s.ran, tt.ran)
+		}
+		if len(w.list) != tt.warnings {
+			t.Errorf("%s: warnings %q", tt.name, w.list)
+		}
+		if len(s.answers) != 0 {
+			t.Errorf("%s: %d scripted answers left", tt.name, len(s.answers))
+		}
+	}
+}
+
+func TestOutcomeSummary(t *testing.T) {
+	s := &script{
+		answers: []bool{true, false, true},
+		checks:  map[string][]bool{CheckPorts: {false}, CheckJLink: {false, false}},
+	}
+	out, err := s.engine(t, DefaultTree, ui.Silent).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `Conclusion: The board is powered but neither the ISP bootloader nor a J-Link can reach it. Send the findings below to support.
+Findings:
+  - Does the board's power LED light up?: yes
+  - Looking for the board's serial port: failed (ports not found)
+  - Hold the ISP button (if the board has one), press and release RESET, and keep holding ISP. Are you holding it now?: no
+  - Looking for a J-Link probe: failed (jlink not found)
+  - Can you attach a J-Link (or the DevKit's on-board one) to the debug connector now?: yes
+  - Looking for a J-Link probe again: failed (jlink not found)
+`
+	if got := out.Summary(); got != want {
+		t.Errorf("Summary =\n%s\nwant\n%s", got, want)
+	}
+
+	declined := Finding{Step: Step{Kind: Action, Text: "Erase?"}, Detail: "declined"}
+	if got := declined.String(); got != "Erase?: not done (declined)" {
+		t.Errorf("declined action = %q", got)
+	}
+}
+
+func TestDefaultTree(t *testing.T) {
+	if err := DefaultTree.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	// Every step can be reached from the start.
+	reached := map[string]bool{}
+	var walk func(id string)
+	walk = func(id string) {
+		if id == "" || reached[id] {
+			return
+		}
+		reached[id] = true
+		s, _ := DefaultTree.step(id)
+		walk(s.Yes)
+		walk(s.No)
+	}
+	walk(DefaultTree.Start)
+	runs := map[string]bool{CheckPorts: true, CheckISP: true, CheckJLink: true, ActionAutoISP: true, ActionErase: true, ActionRecover: true}
+	for _, s := range DefaultTree.Steps {
+		if !reached[s.ID] {
+			t.Errorf("step %s cannot be reached", s.ID)
+		}
+		if s.Run != "" && !runs[s.Run] {
+			t.Errorf("step %s runs unknown %q", s.ID, s.Run)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	end := Step{ID: "end", Kind: End, Text: "done"}
+	tests := []struct {
+		name      string
+		tree      Tree
+		errSubstr string
+	}{
+		{"valid", Tree{Start: "q", Steps: []Step{{ID: "q", Kind: Question, Yes: "end", No: "end"}, end}}, ""},
+		{"duplicate", Tree{Start: "end", Steps: []Step{end, end}}, "step end is defined twice"},
+		{"no start", Tree{Start: "q", Steps: []Step{end}}, "start step q does not exist"},
+		{"unknown next", Tree{Start: "q", Steps: []Step{{ID: "q", Kind: Question, Yes: "end", No: "maybe"}, end}}, "step q leads to unknown step 'maybe'"},
+		{"missing next", Tree{Start: "q", Steps: []Step{{ID: "q", Kind: Question, Yes: "end"}, end}}, "step q leads to unknown step ''"},
+		{"end with next", Tree{Start: "end", Steps: []Step{{ID: "end", Kind: End, Yes: "end"}}}, "end step end has a next step"},
+		{"check without run", Tree{Start: "c", Steps: []Step{{ID: "c", Kind: Check, Yes: "end", No: "end"}, end}}, "check step c names nothing to run"},
+		{"unknown kind", Tree{Start: "w", Steps: []Step{{ID: "w", Kind: "wait", Yes: "end", No: "end"}, end}}, "step w has unknown kind 'wait'"},
+	}
+	for _, tt := range tests {
+		err := tt.tree.Validate()
+		switch {
+		case tt.errSubstr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.errSubstr != "" && (err == nil || !strings.Contains(err.Error(), tt.errSubstr)):
+			t.Errorf("%s: error = %v, want one mentioning %q", tt.name, err, tt.errSubstr)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	end := Step{ID: "end", Kind: End, Text: "done"}
+	loop := Tree{Start: "again", Steps: []Step{{ID: "again", Kind: Question, Text: "Again?", Yes: "again", No: "end"}, end}}
+	tests := []struct {
+		name      string
+		engine    *Engine
+		findings  int
+		errSubstr string
+	}{
+		{"invalid tree", &Engine{Tree: Tree{Start: "x"}}, 0, "start step x does not exist"},
+		{"unknown check", &Engine{
+			Tree:   Tree{Start: "c", Steps: []Step{{ID: "c", Kind: Check, Run: "usb", Yes: "end", No: "end"}, end}},
+			Report: ui.Silent,
+		}, 0, "no check called 'usb'"},
+		{"unknown action", &Engine{
+			Tree: Tree{Start: "a", Steps: []Step{{ID: "a", Kind: Action, Run: "flash", Yes: "end", No: "end"}, end}},
+		}, 0, "no action called 'flash'"},
+		{"cannot ask", &Engine{
+			Tree: loop,
+			Ask:  func(string) (bool, error) { return false, errors.New("stdin is not a terminal") },
+		}, 0, "stdin is not a terminal"},
+		{"cycle", &Engine{
+			Tree: loop,
+			Ask:  func(string) (bool, error) { return true, nil },
+		}, maxSteps, "gave up after 50 steps"},
+	}
+	for _, tt := range tests {
+		out, err := tt.engine.Run()
+		if err == nil || !strings.Contains(err.Error(), tt.errSubstr) {
+			t.Errorf("%s: error = %v, want one mentioning %q", tt.name, err, tt.errSubstr)
+		}
+		if out != nil && len(out.Findings) != tt.findings {
+			t.Errorf("%s: %d findings, want %d", tt.name, len(out.Findings), tt.findings)
+		}
+	}
+}
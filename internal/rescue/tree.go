@@ -0,0 +1,51 @@
+package rescue
+
+// Checks and actions DefaultTree runs; the command layer provides them.
+const (
+	// CheckPorts passes when a serial port that looks like a board exists.
+	CheckPorts = "ports"
+	// CheckISP passes when the Secure Enclave answers an ISP packet.
+	CheckISP = "isp"
+	// CheckJLink passes when a J-Link probe is attached.
+	CheckJLink = "jlink"
+	// ActionAutoISP resets the board into ISP mode over DTR/RTS.
+	ActionAutoISP = "auto-isp"
+	// ActionRecover clears the boot entries in MRAM over J-Link.
+	ActionRecover = "recover"
+	// ActionErase erases the application area over ISP.
+	ActionErase = "erase"
+)
+
+// DefaultTree is the escalation path for a board that stopped answering:
+// power, then the SE-UART port, then the ISP bootloader (first reset over
+// DTR/RTS, then by hand), then J-Link recovery. New branches are added
+// here; the engine needs no change.
+var DefaultTree = Tree{
+	Start: "power",
+	Steps: []Step{
+		{ID: "power", Kind: Question, Text: "Does the board's power LED light up?", Yes: "ports", No: "no-power"},
+		{ID: "ports", Kind: Check, Run: CheckPorts, Text: "Looking for the board's serial port", Yes: "isp", No: "hold-isp"},
+		{ID: "hold-isp", Kind: Question, Text: "Hold the ISP button (if the board has one), press and release RESET, and keep holding ISP. Are you holding it now?", Yes: "ports-held", No: "jlink"},
+		{ID: "ports-held", Kind: Check, Run: CheckPorts, Text: "Looking for a serial port while ISP is held", Yes: "isp-held", No: "jlink"},
+		{ID: "isp", Kind: Check, Run: CheckISP, Text: "Checking for the ISP bootloader", Yes: "isp-ok", No: "auto-isp"},
+		{ID: "auto-isp", Kind: Action, Run: ActionAutoISP, Text: "Reset the board into ISP mode over the serial DTR/RTS lines?", Yes: "isp-after-reset", No: "reset-by-hand"},
+		{ID: "isp-after-reset", Kind: Check, Run: CheckISP, Text: "Checking for the ISP bootloader after the reset", Yes: "isp-ok", No: "reset-by-hand"},
+		{ID: "reset-by-hand", Kind: Question, Text: "Close every terminal using the port, then press and release RESET (or power-cycle the board). Done?", Yes: "isp-after-manual", No: "jlink"},
+		{ID: "isp-after-manual", Kind: Check, Run: CheckISP, Text: "Checking for the ISP bootloader after the manual reset", Yes: "isp-ok", No: "hold-isp"},
+		{ID: "isp-held", Kind: Check, Run: CheckISP, Text: "Checking for the ISP bootloader while ISP is held", Yes: "isp-ok", No: "jlink"},
+		{ID: "isp-ok", Kind: Action, Run: ActionErase, Text: "The ISP bootloader answers. Erase the application area so a crashing application cannot get in the way?", Yes: "erased", No: "isp-healthy"},
+		{ID: "jlink", Kind: Check, Run: CheckJLink, Text: "Looking for a J-Link probe", Yes: "recover", No: "attach-jlink"},
+		{ID: "attach-jlink", Kind: Question, Text: "Can you attach a J-Link (or the DevKit's on-board one) to the debug connector now?", Yes: "jlink-attached", No: "no-jlink"},
+		{ID: "jlink-attached", Kind: Check, Run: CheckJLink, Text: "Looking for a J-Link probe again", Yes: "recover", No: "no-jlink"},
+		{ID: "recover", Kind: Action, Run: ActionRecover, Text: "Clear the boot entries in MRAM over J-Link (alif recover) so the Secure Enclave stays in ISP mode?", Yes: "isp-after-recover", No: "not-recovered"},
+		{ID: "isp-after-recover", Kind: Check, Run: CheckISP, Text: "Checking for the ISP bootloader after the J-Link recovery", Yes: "recovered", No: "recover-no-isp"},
+
+		{ID: "no-power", Kind: End, Text: "The board has no power. Check the USB cable and that it is plugged into the DevKit's PRG USB connector, or the external supply and its jumpers."},
+		{ID: "isp-healthy", Kind: End, Text: "The Secure Enclave answers ISP, so the board can be flashed: run alif flash again (add --no-auto-isp if the reset over DTR/RTS was not needed)."},
+		{ID: "erased", Kind: End, Text: "The application area was erased and the Secure Enclave answers ISP: flash the application again with alif flash."},
+		{ID: "recovered", Kind: End, Text: "The boot entries were cleared over J-Link and the Secure Enclave answers ISP again: flash the application with alif flash."},
+		{ID: "recover-no-isp", Kind: End, Text: "The J-Link recovery ran, but the ISP bootloader still does not answer. The SE-UART wiring or the Secure Enclave firmware may be at fault; send the findings below to support."},
+		{ID: "not-recovered", Kind: End, Text: "A J-Link is attached but the recovery was not done. Run alif recover when ready, or send the findings below to support."},
+		{ID: "no-jlink", Kind: End, Text: "The board is powered but neither the ISP bootloader nor a J-Link can reach it. Send the findings below to support."},
+	},
+}
@@ -0,0 +1,210 @@
+package signer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"alif-cli/internal/audit"
+	"alif-cli/internal/targets"
+)
+
+const (
+	// CompressNone explicitly clears the COMPRESS flag.
+	CompressNone = "none"
+	// CompressLZF is the default algorithm selected by a bare --compress.
+	CompressLZF = "lzf"
+
+	compressFlag = "COMPRESS"
+)
+
+// CompressionAlgorithms maps each supported algorithm to the compressor
+// helper app-gen-toc runs from the toolkit's utils directory.
+var CompressionAlgorithms = map[string]string{
+	CompressLZF: "lzf",
+}
+
+// compressedSuffix is appended to image names in the package map when the
+// toolkit stored them compressed.
+const compressedSuffix = ".lzf"
+
+// ValidateCompression checks that name is an algorithm the CLI knows about.
+func ValidateCompression(name string) error {
+	if name == "" || name == CompressNone {
+		return nil
+	}
+	if _, ok := CompressionAlgorithms[name]; !ok {
+		return fmt.Errorf("unsupported compression '%s' (use lzf or none)", name)
+	}
+	return nil
+}
+
+// probeCompression verifies the installed toolkit can produce the requested
+// compression by looking for its compressor helper (utils/lzf-lnx,
+// utils/lzf.exe, ...).
+func (s *Signer) probeCompression(algo string) error {
+	helper, ok := CompressionAlgorithms[algo]
+	if !ok {
+		return ValidateCompression(algo)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(s.Cfg.AlifToolsPath, "utils", helper+"*"))
+	if len(matches) == 0 {
+		version := "unknown"
+		if v, err := os.ReadFile(filepath.Join(s.Cfg.AlifToolsPath, "version.txt")); err == nil {
+			version = strings.TrimSpace(string(v))
+		}
+		return fmt.Errorf("installed toolkit (version %s) does not support %s compression", version, algo)
+	}
+	return nil
+}
+
+// StageConfig writes srcCfg to dst, applying the requested compression to
+// the application image section. Encoding problems app-gen-toc cannot read
+// (BOMs, UTF-16, typographic quotes) are fixed in the copy and reported.
+func (s *Signer) StageConfig(srcCfg, dst string) error {
+	content, fixes, err := targets.ReadConfig(srcCfg)
+	if err != nil {
+		return err
+	}
+	for _, line := range targets.DescribeFixes(fixes) {
+		s.Report.Warn(fmt.Sprintf("%s: %s (fixed in the staged copy)", filepath.Base(srcCfg), line))
+	}
+	if s.Compression == "" {
+		if len(fixes) == 0 {
+			return audit.CopyFile(srcCfg, dst)
+		}
+		return audit.WriteFile(dst, content, 0644)
+	}
+	if err := ValidateCompression(s.Compression); err != nil {
+		return err
+	}
+	if s.Compression != CompressNone {
+		if err := s.probeCompression(s.Compression); err != nil {
+			return err
+		}
+	}
+
+	var cfg map[string]interface{}
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		return fmt.Errorf("failed to parse signing config: %w", err)
+	}
+
+	appSection, _, err := s.findAppSection(cfg)
+	if err != nil {
+		return err
+	}
+	section := cfg[appSection].(map[string]interface{})
+	// app-gen-toc refuses to compress images executed in place from MRAM.
+	if addr, _ := section["mramAddress"].(string); addr != "" && s.Compression != CompressNone {
+		return fmt.Errorf("%s executes in place from MRAM (mramAddress %s) and cannot be compressed; use loadAddress with the LOAD flag instead", appSection, addr)
+	}
+	setCompression(section, s.Compression != CompressNone)
+	s.Report.Item("Compression", s.Compression)
+
+	out, err := json.MarshalIndent(cfg, "", "    ")
+	if err != nil {
+		return err
+	}
+	return audit.WriteFile(dst, out, 0644)
+}
+
+// setCompression adds or removes the COMPRESS flag in an image section,
+// leaving any other flags as they were.
+func setCompression(section map[string]interface{}, enabled bool) {
+	var flags []interface{}
+	if existing, ok := section["flags"].([]interface{}); ok {
+		for _, f := range existing {
+			if str, ok := f.(string); ok && strings.EqualFold(str, compressFlag) {
+				continue
+			}
+			flags = append(flags, f)
+		}
+	}
+	if enabled {
+		flags = append(flags, compressFlag)
+	}
+	if flags == nil {
+		flags = []interface{}{}
+	}
+	section["flags"] = flags
+}
+
+// PackageEntry is one image row of app-package-map.txt.
+type PackageEntry struct {
+	Address    string
+	Size       int64
+	Compressed bool
+}
+
+// ReadPackageEntry finds the row for binaryName in a package map. Rows list
+// the MRAM address, hex size, decimal size, an optional "unsigned" marker
+// and the file name, which ends in .lzf when the image was compressed.
+func ReadPackageEntry(mapPath, binaryName string) (*PackageEntry, error) {
+	content, err := os.ReadFile(mapPath)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.Contains(line, binaryName) {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "0x") {
+			continue
+		}
+		name := fields[len(fields)-1]
+		if name != binaryName && name != binaryName+compressedSuffix {
+			continue
+		}
+		size, err := strconv.ParseInt(strings.TrimPrefix(fields[1], "0x"), 16, 64)
+		if err != nil {
+			continue
+		}
+		return &PackageEntry{
+			Address:    fields[0],
+			Size:       size,
+			Compressed: strings.HasSuffix(name, compressedSuffix),
+		}, nil
+	}
+	return nil, fmt.Errorf("no entry for %s in %s", binaryName, filepath.Base(mapPath))
+}
+
+// reportCompression prints original vs packaged size and warns about the RAM
+// the boot loader needs to decompress the image.
+func (s *Signer) reportCompression(mapPath, binaryPath, imageName string, section interface{}) {
+	info, err := os.Stat(binaryPath)
+	if err != nil {
+		return
+	}
+	entry, err := ReadPackageEntry(mapPath, imageName)
+	if err != nil {
+		s.Report.Warn(fmt.Sprintf("Could not read compressed size: %v", err))
+		return
+	}
+	if !entry.Compressed {
+		s.Report.Warn("Toolkit did not mark the image as compressed")
+		return
+	}
+
+	saved := 100 - entry.Size*100/max(info.Size(), 1)
+	s.Report.Item("Size", fmt.Sprintf("%d → %d bytes (%d%% smaller)", info.Size(), entry.Size, saved))
+
+	loadAddr := ""
+	if sec, ok := section.(map[string]interface{}); ok {
+		loadAddr, _ = sec["loadAddress"].(string)
+	}
+	if loadAddr == "" {
+		s.Report.Warn(fmt.Sprintf("Compressed images are decompressed into RAM at boot; set loadAddress with %d bytes free", info.Size()))
+	} else {
+		s.Report.Warn(fmt.Sprintf("Decompression needs %d bytes of RAM at %s", info.Size(), loadAddr))
+	}
+}
@@ -0,0 +1,156 @@
+package signer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"alif-cli/internal/config"
+	"alif-cli/internal/ui"
+)
+
+func TestSetCompression(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   interface{}
+		enabled bool
+		want    []interface{}
+	}{
+		{"no flags, enable", nil, true, []interface{}{"COMPRESS"}},
+		{"no flags, disable", nil, false, []interface{}{}},
+		{"other flags kept", []interface{}{"LOAD", "BOOT"}, true, []interface{}{"LOAD", "BOOT", "COMPRESS"}},
+		{"already set", []interface{}{"COMPRESS", "LOAD"}, true, []interface{}{"LOAD", "COMPRESS"}},
+		{"cleared in any case", []interface{}{"LOAD", "compress"}, false, []interface{}{"LOAD"}},
+		{"flags not a list", "LOAD", true, []interface{}{"COMPRESS"}},
+	}
+	for _, tt := range tests {
+		section := map[string]interface{}{"binary": "alif-img.bin"}
+		if tt.flags != nil {
+			section["flags"] = tt.flags
+		}
+		setCompression(section, tt.enabled)
+		if !reflect.DeepEqual(section["flags"], tt.want) {
+			t.Errorf("%s: flags = %v, want %v", tt.name, section["flags"], tt.want)
+		}
+		if section["binary"] != "alif-img.bin" {
+			t.Errorf("%s: binary changed to %v", tt.name, section["binary"])
+		}
+	}
+}
+
+// compressToolkit returns a toolkit folder holding the given files in utils
+// and, when version is set, a version.txt.
+func compressToolkit(t *testing.T, version string, utils ...string) string {
+	t.Helper()
+	tk := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tk, "utils"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range utils {
+		if err := os.WriteFile(filepath.Join(tk, "utils", name), nil, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if version != "" {
+		if err := os.WriteFile(filepath.Join(tk, "version.txt"), []byte(version+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tk
+}
+
+func TestProbeCompression(t *testing.T) {
+	tests := []struct {
+		name      string
+		version   string
+		utils     []string
+		algo      string
+		errSubstr string
+	}{
+		{"linux helper", "1.107.0", []string{"lzf-lnx"}, "lzf", ""},
+		{"windows helper", "1.107.0", []string{"lzf.exe"}, "lzf", ""},
+		{"no helper", "1.98.2", []string{"app-gen-toc"}, "lzf", "installed toolkit (version 1.98.2) does not support lzf compression"},
+		{"no helper, no version", "", nil, "lzf", "installed toolkit (version unknown) does not support lzf compression"},
+		{"other name", "1.98.2", []string{"lz4-lnx"}, "lzf", "does not support lzf compression"},
+		{"unknown algorithm", "1.107.0", []string{"lzf-lnx"}, "zstd", "unsupported compression 'zstd'"},
+	}
+	for _, tt := range tests {
+		s := New(&config.Config{AlifToolsPath: compressToolkit(t, tt.version, tt.utils...)})
+		err := s.probeCompression(tt.algo)
+		switch {
+		case tt.errSubstr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.errSubstr != "" && (err == nil || !strings.Contains(err.Error(), tt.errSubstr)):
+			t.Errorf("%s: error = %v, want one mentioning %q", tt.name, err, tt.errSubstr)
+		}
+	}
+}
+
+func TestStageConfig(t *testing.T) {
+	const (
+		loaded  = `{"DEVICE": {"disable_wdt": true}, "USER_APP": {"binary": "alif-img.bin", "loadAddress": "0x02000000", "flags": ["LOAD"], "cpu_id": "M55_HE"}}`
+		inPlace = `{"USER_APP": {"binary": "alif-img.bin", "mramAddress": "0x80000000", "flags": ["COMPRESS"], "cpu_id": "M55_HE"}}`
+	)
+	tests := []struct {
+		name        string
+		config      string
+		compression string
+		utils       []string
+		flags       []interface{} // USER_APP flags in the staged copy; nil keeps the file as is
+		errSubstr   string
+	}{
+		{"not requested", loaded, "", []string{"lzf-lnx"}, nil, ""},
+		{"lzf", loaded, "lzf", []string{"lzf-lnx"}, []interface{}{"LOAD", "COMPRESS"}, ""},
+		{"none", inPlace, "none", nil, []interface{}{}, ""},
+		{"toolkit without lzf", loaded, "lzf", []string{"app-gen-toc"}, nil, "does not support lzf compression"},
+		{"executed in place", inPlace, "lzf", []string{"lzf-lnx"}, nil, "USER_APP executes in place from MRAM (mramAddress 0x80000000) and cannot be compressed"},
+		{"unknown algorithm", loaded, "zstd", []string{"lzf-lnx"}, nil, "unsupported compression 'zstd'"},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		src, dst := filepath.Join(dir, "app.json"), filepath.Join(dir, "staged.json")
+		if err := os.WriteFile(src, []byte(tt.config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		s := New(&config.Config{AlifToolsPath: compressToolkit(t, "1.107.0", tt.utils...)})
+		s.Report = ui.Silent
+		s.Compression = tt.compression
+		err := s.StageConfig(src, dst)
+		switch {
+		case tt.errSubstr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		case tt.errSubstr != "" && (err == nil || !strings.Contains(err.Error(), tt.errSubstr)):
+			t.Errorf("%s: error = %v, want one mentioning %q", tt.name, err, tt.errSubstr)
+			continue
+		case tt.errSubstr != "":
+			if _, err := os.Stat(dst); err == nil {
+				t.Errorf("%s: config staged despite the error", tt.name)
+			}
+			continue
+		}
+		staged, err := os.ReadFile(dst)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if tt.flags == nil {
+			if string(staged) != tt.config {
+				t.Errorf("%s: staged copy differs:\n%s", tt.name, staged)
+			}
+			continue
+		}
+		var cfg map[string]map[string]interface{}
+		if err := json.Unmarshal(staged, &cfg); err != nil {
+			t.Fatalf("%s: staged copy: %v", tt.name, err)
+		}
+		if got := cfg["USER_APP"]["flags"]; !reflect.DeepEqual(got, tt.flags) {
+			t.Errorf("%s: flags = %v, want %v", tt.name, got, tt.flags)
+		}
+		if got := cfg["USER_APP"]["cpu_id"]; got != "M55_HE" {
+			t.Errorf("%s: cpu_id = %v, want M55_HE", tt.name, got)
+		}
+	}
+}
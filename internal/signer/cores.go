@@ -0,0 +1,222 @@
+package signer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"alif-cli/internal/audit"
+	"alif-cli/internal/targets"
+	"alif-cli/internal/toolkit"
+)
+
+// CoreImage is one core's application for SignCores.
+type CoreImage struct {
+	// Core is the cpu_id the image runs on, e.g. "M55_HE".
+	Core string
+	// Binary is the built application.
+	Binary string
+	// CoreHint and ProjectHint pick the core's signing config, as for
+	// SignArtifact.
+	CoreHint    string
+	ProjectHint string
+}
+
+// CoreArtifacts are the files SignCores produced: the signed image of each
+// core, in the order the cores were given, and one TOC package for all.
+type CoreArtifacts struct {
+	Images []string
+	TOC    string
+	Map    string
+}
+
+// SignCores creates one bootable package holding an image per core. The
+// image section for each core (matched by cpu_id) is taken from its signing
+// config, or from configPathOverride when one config describes every core,
+// and app-gen-toc runs once on the combined config. Compression and image
+// reuse are not supported here.
+func (s *Signer) SignCores(projectDir, buildDir string, images []CoreImage, configPathOverride string) (*CoreArtifacts, error) {
+	s.Report.Header("Create Multi-Core Image")
+	release, err := toolkit.Lock(s.Cfg.AlifToolsPath, toolkit.LockTimeout, s.Report)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if s.Compression != "" && s.Compression != CompressNone {
+		return nil, fmt.Errorf("compression is not supported for multi-core images")
+	}
+
+	outDir := s.outputDir(buildDir)
+	if outDir != "" {
+		if err := checkWritable(outDir); err != nil {
+			return nil, fmt.Errorf("cannot write the image to %s (use --artifacts in-place or --artifacts <dir>): %w", outDir, err)
+		}
+	}
+
+	combined, names, err := s.combinedConfig(projectDir, images, configPathOverride)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each core's binary is staged under its own name, in the toolkit root
+	// and in build/images/ like SignArtifact does.
+	var staged []string
+	for i, img := range images {
+		rootDst := filepath.Join(s.Cfg.AlifToolsPath, names[i])
+		imagesDst := filepath.Join(s.Cfg.AlifToolsPath, "build", "images", names[i])
+		s.Report.Item("Staging", fmt.Sprintf("%s → %s (%s)", filepath.Base(img.Binary), names[i], img.Core))
+		for _, dst := range []string{rootDst, imagesDst} {
+			if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+				return nil, fmt.Errorf("failed to create directory for binary: %w", err)
+			}
+			_ = os.Remove(dst)
+			if err := audit.CopyFile(img.Binary, dst); err != nil {
+				return nil, fmt.Errorf("failed to stage %s: %w", filepath.Base(img.Binary), err)
+			}
+		}
+		staged = append(staged, rootDst)
+	}
+
+	data, err := json.MarshalIndent(combined, "", "    ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode combined config: %w", err)
+	}
+	stagedCfgPath := filepath.Join(s.Cfg.AlifToolsPath, "staged_config.json")
+	if err := audit.WriteFile(stagedCfgPath, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to stage config file: %w", err)
+	}
+	defer os.Remove(stagedCfgPath)
+
+	if err := s.generateTOC(); err != nil {
+		return nil, err
+	}
+
+	toolkitBuild := filepath.Join(s.Cfg.AlifToolsPath, "build")
+	result := &CoreArtifacts{
+		Images: staged,
+		TOC:    filepath.Join(toolkitBuild, "AppTocPackage.bin"),
+		Map:    filepath.Join(toolkitBuild, "app-package-map.txt"),
+	}
+	if outDir == "" {
+		for _, img := range result.Images {
+			s.Report.Item("Image", img)
+		}
+		s.Report.Item("TOC", result.TOC)
+		return result, nil
+	}
+
+	moved := &CoreArtifacts{
+		TOC: filepath.Join(outDir, "AppTocPackage.bin"),
+		Map: filepath.Join(outDir, "app-package-map.txt"),
+	}
+	moves := []struct{ src, dst string }{{result.Map, moved.Map}}
+	for _, suffix := range []string{"", ".sign", ".crt"} {
+		moves = append(moves, struct{ src, dst string }{result.TOC + suffix, moved.TOC + suffix})
+	}
+	for i, img := range result.Images {
+		dst := filepath.Join(outDir, names[i])
+		for _, suffix := range []string{"", ".sign", ".crt"} {
+			moves = append(moves, struct{ src, dst string }{img + suffix, dst + suffix})
+		}
+		moved.Images = append(moved.Images, dst)
+	}
+	for _, m := range moves {
+		if _, err := os.Stat(m.src); err == nil {
+			if err := audit.Rename(m.src, m.dst); err != nil {
+				return nil, fmt.Errorf("failed to write %s: %w", m.dst, err)
+			}
+		}
+	}
+	return moved, nil
+}
+
+// combinedConfig merges the image section of each core into one signing
+// config and returns it with the binary name given to each core's image.
+// Sections other than images (DEVICE, ...) come from the first config.
+func (s *Signer) combinedConfig(projectDir string, images []CoreImage, configPathOverride string) (map[string]interface{}, []string, error) {
+	combined := map[string]interface{}{}
+	names := make([]string, len(images))
+	addresses := map[string]string{}
+	for i, img := range images {
+		path := configPathOverride
+		if path == "" {
+			var err error
+			if _, path, err = targets.ResolveTargetConfig("", projectDir, img.CoreHint, img.ProjectHint, s.Report); err != nil {
+				return nil, nil, fmt.Errorf("failed to resolve signing config for %s: %w", img.Core, err)
+			}
+		}
+		cfgBytes, _, err := targets.ReadConfig(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read signing config: %w", err)
+		}
+		var cfg map[string]interface{}
+		if err := json.Unmarshal(cfgBytes, &cfg); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse signing config: %w", err)
+		}
+
+		if i == 0 {
+			for k, v := range cfg {
+				if !isImageSection(v) {
+					combined[k] = v
+				}
+			}
+		}
+
+		key, section := coreSection(cfg, img.Core)
+		if section == nil {
+			return nil, nil, fmt.Errorf("%s has no image section with cpu_id %s", filepath.Base(path), img.Core)
+		}
+		if addr, _ := section["mramAddress"].(string); addr != "" {
+			if other, taken := addresses[strings.ToLower(addr)]; taken {
+				return nil, nil, fmt.Errorf("the %s and %s images are both placed at mramAddress %s", other, img.Core, addr)
+			}
+			addresses[strings.ToLower(addr)] = img.Core
+		}
+
+		names[i] = fmt.Sprintf("alif-img-%s.bin", strings.ToLower(img.Core))
+		section["binary"] = names[i]
+		if _, taken := combined[key]; taken {
+			key += "_" + img.Core
+		}
+		combined[key] = section
+	}
+	return combined, names, nil
+}
+
+// coreSection returns the image section whose cpu_id is core. A config with
+// a single image section that names no cpu_id is taken to be for any core.
+func coreSection(cfg map[string]interface{}, core string) (string, map[string]interface{}) {
+	var anyKey string
+	var anySection map[string]interface{}
+	images := 0
+	for k, v := range cfg {
+		if !isImageSection(v) {
+			continue
+		}
+		images++
+		section := v.(map[string]interface{})
+		id, _ := section["cpu_id"].(string)
+		if strings.EqualFold(id, core) {
+			return k, section
+		}
+		if id == "" {
+			anyKey, anySection = k, section
+		}
+	}
+	if images == 1 && anySection != nil {
+		return anyKey, anySection
+	}
+	return "", nil
+}
+
+// isImageSection reports whether a config section describes an image.
+func isImageSection(v interface{}) bool {
+	section, ok := v.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, ok = section["binary"].(string)
+	return ok
+}
@@ -0,0 +1,130 @@
+package signer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+
+	"alif-cli/internal/state"
+)
+
+// imageOutputs must all be present in the build directory for an image to
+// be reused.
+var imageOutputs = []string{"alif-img.bin", "AppTocPackage.bin", "app-package-map.txt"}
+
+// ImageChanges lists which inputs differ between the image recorded in prev
+// and cur ("binary", "config", "toolkit", "compression"). No record counts
+// as every input changed; an empty result means the image can be reused.
+func ImageChanges(prev *state.ImageInputs, cur state.ImageInputs) []string {
+	if prev == nil {
+		return []string{"no previous image"}
+	}
+	var changed []string
+	if prev.Binary != cur.Binary {
+		changed = append(changed, "binary")
+	}
+	if prev.Config != cur.Config {
+		changed = append(changed, "config")
+	}
+	if prev.Toolkit != cur.Toolkit {
+		changed = append(changed, "toolkit")
+	}
+	if prev.Compression != cur.Compression {
+		changed = append(changed, "compression")
+	}
+	return changed
+}
+
+// imageInputs hashes the binary, the signing config and the toolkit
+// identity (app-gen-toc and utils/global-cfg.db, after the device sync).
+func (s *Signer) imageInputs(binaryPath, configPath string) (state.ImageInputs, error) {
+	in := state.ImageInputs{Compression: s.Compression}
+	var err error
+	if in.Binary, err = fileSum(binaryPath); err != nil {
+		return in, err
+	}
+	if in.Config, err = fileSum(configPath); err != nil {
+		return in, err
+	}
+	if in.Toolkit, err = fileSum(
+		filepath.Join(s.Cfg.AlifToolsPath, "app-gen-toc"),
+		filepath.Join(s.Cfg.AlifToolsPath, "utils", "global-cfg.db"),
+	); err != nil {
+		return in, err
+	}
+	return in, nil
+}
+
+// imageKey names buildDir in the project state.
+func (s *Signer) imageKey(buildDir string) string {
+	if rel, err := filepath.Rel(filepath.Dir(s.StateDir), buildDir); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return buildDir
+}
+
+// reusableImage reports whether buildDir already holds an image made from
+// in, and otherwise why not.
+func (s *Signer) reusableImage(buildDir string, in state.ImageInputs) (bool, []string) {
+	st, err := state.Load(s.StateDir)
+	if err != nil {
+		return false, []string{"no previous image"}
+	}
+	var prev *state.ImageInputs
+	if rec, ok := st.Images[s.imageKey(buildDir)]; ok {
+		prev = &rec
+	}
+	changed := ImageChanges(prev, in)
+	if len(changed) > 0 {
+		return false, changed
+	}
+	for _, name := range imageOutputs {
+		if _, err := os.Stat(filepath.Join(buildDir, name)); err != nil {
+			return false, []string{name + " missing"}
+		}
+	}
+	return true, nil
+}
+
+// ImageUpToDate reports whether SignArtifact would reuse the image in
+// buildDir instead of generating it from binaryPath and configPath.
+func (s *Signer) ImageUpToDate(buildDir, binaryPath, configPath string) bool {
+	if s.StateDir == "" || s.ForceImage {
+		return false
+	}
+	in, err := s.imageInputs(binaryPath, configPath)
+	if err != nil {
+		return false
+	}
+	ok, _ := s.reusableImage(buildDir, in)
+	return ok
+}
+
+// recordImage stores in as the inputs of the image in buildDir.
+func (s *Signer) recordImage(buildDir string, in state.ImageInputs) error {
+	return state.Update(s.StateDir, func(st *state.State) {
+		if st.Images == nil {
+			st.Images = make(map[string]state.ImageInputs)
+		}
+		st.Images[s.imageKey(buildDir)] = in
+	})
+}
+
+// fileSum returns the hex SHA-256 of the concatenated content of paths.
+func fileSum(paths ...string) (string, error) {
+	h := sha256.New()
+	for _, p := range paths {
+		in, err := os.Open(p)
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(h, in)
+		in.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,142 @@
+package signer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"alif-cli/internal/config"
+	"alif-cli/internal/state"
+	"alif-cli/internal/ui"
+)
+
+func TestImageChanges(t *testing.T) {
+	prev := state.ImageInputs{Binary: "b1", Config: "c1", Toolkit: "t1", CreatedAt: time.Now()}
+	tests := []struct {
+		name string
+		prev *state.ImageInputs
+		cur  state.ImageInputs
+		want []string
+	}{
+		{"unchanged", &prev, state.ImageInputs{Binary: "b1", Config: "c1", Toolkit: "t1"}, nil},
+		{"no record", nil, state.ImageInputs{Binary: "b1", Config: "c1", Toolkit: "t1"}, []string{"no previous image"}},
+		{"binary", &prev, state.ImageInputs{Binary: "b2", Config: "c1", Toolkit: "t1"}, []string{"binary"}},
+		{"config", &prev, state.ImageInputs{Binary: "b1", Config: "c2", Toolkit: "t1"}, []string{"config"}},
+		{"toolkit", &prev, state.ImageInputs{Binary: "b1", Config: "c1", Toolkit: "t2"}, []string{"toolkit"}},
+		{"compression", &prev, state.ImageInputs{Binary: "b1", Config: "c1", Toolkit: "t1", Compression: "lzf"}, []string{"compression"}},
+		{"binary and config", &prev, state.ImageInputs{Binary: "b2", Config: "c2", Toolkit: "t1"}, []string{"binary", "config"}},
+		{"binary and toolkit", &prev, state.ImageInputs{Binary: "b2", Config: "c1", Toolkit: "t2"}, []string{"binary", "toolkit"}},
+		{"config and toolkit", &prev, state.ImageInputs{Binary: "b1", Config: "c2", Toolkit: "t2"}, []string{"config", "toolkit"}},
+		{"everything", &prev, state.ImageInputs{Binary: "b2", Config: "c2", Toolkit: "t2", Compression: "lzf"}, []string{"binary", "config", "toolkit", "compression"}},
+	}
+	for _, tt := range tests {
+		if got := ImageChanges(tt.prev, tt.cur); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ImageChanges = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// imageToolkit creates a toolkit whose app-gen-toc writes the image
+// outputs and counts its runs in "runs".
+func imageToolkit(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	root := t.TempDir()
+	files := map[string]string{
+		"utils/global-cfg.db": `{"DEVICE": {"Part#": "` + e7Part + `", "Revision": "B2"}}`,
+		"app-gen-toc": `#!/bin/sh
+echo $(( $(cat runs 2>/dev/null || echo 0) + 1 )) > runs
+mkdir -p build
+printf 'TOC' > build/AppTocPackage.bin
+printf '0x80000000  0x00000010  alif-img.bin\n' > build/app-package-map.txt
+echo 'Device Part# ` + e7Part + ` - Rev: B2'
+`,
+	}
+	for rel, content := range files {
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestSignArtifactReuse(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	tk := imageToolkit(t)
+	project := t.TempDir()
+	buildDir := filepath.Join(project, "out")
+	if err := os.MkdirAll(buildDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	bin := filepath.Join(buildDir, "blinky.bin")
+	targetCfg := filepath.Join(project, "he.json")
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(bin, "0123456789abcdef")
+	write(targetCfg, `{"USER_APP": {"binary": "alif-img.bin", "mramAddress": "0x80000000", "cpu_id": "M55_HE"}}`)
+
+	steps := []struct {
+		name   string
+		change func()
+		force  bool
+		runs   int
+	}{
+		{"first image", func() {}, false, 1},
+		// CI copies the binary again: new mtime, same bytes.
+		{"binary copied again", func() {
+			write(bin, "0123456789abcdef")
+			later := time.Now().Add(time.Hour)
+			os.Chtimes(bin, later, later)
+		}, false, 1},
+		{"forced", func() {}, true, 2},
+		{"binary rebuilt", func() { write(bin, "fedcba9876543210") }, false, 3},
+		{"config edited", func() {
+			write(targetCfg, `{"USER_APP": {"binary": "alif-img.bin", "mramAddress": "0x80010000", "cpu_id": "M55_HE"}}`)
+		}, false, 4},
+		{"toolkit updated", func() {
+			f, err := os.OpenFile(filepath.Join(tk, "app-gen-toc"), os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.WriteString("# 1.1\n")
+			f.Close()
+		}, false, 5},
+		{"image deleted", func() { os.Remove(filepath.Join(buildDir, "AppTocPackage.bin")) }, false, 6},
+		{"unchanged", func() {}, false, 6},
+	}
+	previous := 0
+	for _, step := range steps {
+		step.change()
+		s := New(&config.Config{AlifToolsPath: tk})
+		s.Report = ui.Silent
+		s.StateDir = filepath.Join(project, ".alif")
+		s.ForceImage = step.force
+		reuse := step.runs == previous
+		if got := s.ImageUpToDate(buildDir, bin, targetCfg); got != reuse {
+			t.Errorf("%s: ImageUpToDate = %v, want %v", step.name, got, reuse)
+		}
+		if _, err := s.SignArtifact(project, buildDir, bin, "", "", targetCfg); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		runs, _ := os.ReadFile(filepath.Join(tk, "runs"))
+		if got := strings.TrimSpace(string(runs)); got != strconv.Itoa(step.runs) {
+			t.Errorf("%s: app-gen-toc ran %s time(s) in total, want %d", step.name, got, step.runs)
+		}
+		previous = step.runs
+	}
+}
@@ -0,0 +1,84 @@
+package signer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"alif-cli/internal/config"
+	"alif-cli/internal/ui"
+)
+
+const (
+	oneSection  = `{"DEVICE": {"disable_wdt": true}, "USER_APP": {"binary": "alif-img.bin", "mramAddress": "0x80000000", "cpu_id": "M55_HE"}}`
+	twoSections = `{"HP_APP": {"binary": "hp.bin", "mramAddress": "0x80200000", "cpu_id": "M55_HP"},
+		"HE_APP": {"binary": "he.bin", "mramAddress": "0x80000000", "cpu_id": "M55_HE"}}`
+	noSection = `{"DEVICE": {"disable_wdt": true}, "NOTES": {"text": "no image here"}}`
+)
+
+func TestSignArtifactSections(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	tests := []struct {
+		name      string
+		config    string
+		section   string
+		image     string // binary name the chosen section gives
+		errSubstr string
+	}{
+		{"one section", oneSection, "", "alif-img.bin", ""},
+		{"one section named", oneSection, "USER_APP", "alif-img.bin", ""},
+		{"two sections", twoSections, "", "", "several image sections (HE_APP, HP_APP)"},
+		{"two sections, first chosen", twoSections, "HE_APP", "he.bin", ""},
+		{"two sections, second chosen", twoSections, "HP_APP", "hp.bin", ""},
+		{"unknown section", twoSections, "APP", "", "no section 'APP' (image sections: HE_APP, HP_APP)"},
+		{"section without binary", oneSection, "DEVICE", "", "section 'DEVICE' has no binary field"},
+		{"no section", noSection, "", "", "could not find application binary field"},
+	}
+	for _, tt := range tests {
+		tk := imageToolkit(t)
+		project := t.TempDir()
+		bin := filepath.Join(project, "blinky.bin")
+		targetCfg := filepath.Join(project, "app.json")
+		if err := os.WriteFile(bin, []byte("0123456789abcdef"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(targetCfg, []byte(tt.config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		s := New(&config.Config{AlifToolsPath: tk})
+		s.Report = ui.Silent
+		s.StateDir = filepath.Join(project, ".alif")
+		s.Section = tt.section
+		art, err := s.SignArtifact(project, project, bin, "", "", targetCfg)
+		switch {
+		case tt.errSubstr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.errSubstr != "" && (err == nil || !strings.Contains(err.Error(), tt.errSubstr)):
+			t.Errorf("%s: error = %v, want one mentioning %q", tt.name, err, tt.errSubstr)
+		case tt.errSubstr != "":
+			// A rejected config stages nothing and does not run the tool.
+			if _, err := os.Stat(filepath.Join(tk, "runs")); err == nil {
+				t.Errorf("%s: app-gen-toc ran", tt.name)
+			}
+		default:
+			// app-gen-toc reads the binary the section names from build/images.
+			staged, err := os.ReadFile(filepath.Join(tk, "build", "images", tt.image))
+			if err != nil || string(staged) != "0123456789abcdef" {
+				t.Errorf("%s: staged %s = %q, %v", tt.name, tt.image, staged, err)
+			}
+			if image, err := os.ReadFile(art.Image); err != nil || string(image) != "0123456789abcdef" {
+				t.Errorf("%s: image %s = %q, %v", tt.name, art.Image, image, err)
+			}
+			// Only the chosen section's binary is staged.
+			for _, other := range []string{"he.bin", "hp.bin", "alif-img.bin"} {
+				if other == tt.image {
+					continue
+				}
+				if _, err := os.Stat(filepath.Join(tk, "build", "images", other)); err == nil {
+					t.Errorf("%s: %s staged too", tt.name, other)
+				}
+			}
+		}
+	}
+}
@@ -4,164 +4,381 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
+	"alif-cli/internal/audit"
 	"alif-cli/internal/config"
+	"alif-cli/internal/state"
 	"alif-cli/internal/targets"
+	"alif-cli/internal/toolkit"
 	"alif-cli/internal/ui"
 )
 
+// Output policies for SignArtifact; any other value of Signer.Output is a
+// directory to move the artifacts into.
+const (
+	// OutputBuildDir moves the artifacts into the build directory.
+	OutputBuildDir = "build"
+	// OutputInPlace leaves the artifacts in the toolkit, where app-gen-toc
+	// wrote them, and reports their paths.
+	OutputInPlace = "in-place"
+)
+
+// Artifacts are the files SignArtifact produced.
+type Artifacts struct {
+	Image string
+	TOC   string
+	Map   string
+	// Config is the signing config the image was made from.
+	Config string
+}
+
 type Signer struct {
 	Cfg *config.Config
+	// Report receives progress output; New sets it to ui.Console.
+	Report ui.Reporter
+	// Compression selects TOC image compression (see CompressionAlgorithms).
+	// Empty leaves the config's flags untouched.
+	Compression string
+	// StateDir is the project's .alif directory. When set, the inputs of
+	// each generated image are recorded there and an image whose inputs
+	// are unchanged is reused instead of running app-gen-toc again.
+	StateDir string
+	// ForceImage regenerates the image even when its inputs are unchanged.
+	ForceImage bool
+	// Output is where the artifacts end up: OutputBuildDir (the default
+	// when empty), OutputInPlace, or a directory.
+	Output string
+	// Section names the config section that receives the binary when the
+	// config describes several images (--section).
+	Section string
 }
 
 func New(cfg *config.Config) *Signer {
-	return &Signer{Cfg: cfg}
+	return &Signer{Cfg: cfg, Report: ui.Console}
 }
 
-// SignArtifact creates a bootable image.
-func (s *Signer) SignArtifact(projectDir, buildDir, binaryPath string, coreHint, projectHint, configPathOverride string) (string, error) {
-	ui.Header("Create Bootable Image")
+// outputDir returns the directory the artifacts are moved into, or "" for
+// OutputInPlace.
+func (s *Signer) outputDir(buildDir string) string {
+	switch s.Output {
+	case "", OutputBuildDir:
+		return buildDir
+	case OutputInPlace:
+		return ""
+	default:
+		if abs, err := filepath.Abs(s.Output); err == nil {
+			return abs
+		}
+		return s.Output
+	}
+}
 
-	// Use ResolveTargetConfig to find the config file with hints
-	resolvedCfg, srcCfg, err := targets.ResolveTargetConfig(configPathOverride, projectDir, coreHint, projectHint)
+// SignArtifact creates a bootable image. It holds the toolkit's staging
+// lock while the binary and config are staged and app-gen-toc runs.
+func (s *Signer) SignArtifact(projectDir, buildDir, binaryPath string, coreHint, projectHint, configPathOverride string) (*Artifacts, error) {
+	s.Report.Header("Create Bootable Image")
+	release, err := toolkit.Lock(s.Cfg.AlifToolsPath, toolkit.LockTimeout, s.Report)
 	if err != nil {
-		return "", fmt.Errorf("failed to resolve signing config: %w", err)
+		return nil, err
 	}
+	defer release()
 
-	// Sync Toolkit Config to match the detected device
-	if err := targets.SyncToolkitConfig(s.Cfg.AlifToolsPath, resolvedCfg.GetCPU()); err != nil {
-		ui.Warn(fmt.Sprintf("Toolkit sync failed: %v", err))
+	outDir := s.outputDir(buildDir)
+	if outDir != "" {
+		if err := checkWritable(outDir); err != nil {
+			return nil, fmt.Errorf("cannot write the image to %s (use --artifacts in-place or --artifacts <dir>): %w", outDir, err)
+		}
 	}
 
-	// 1. Load Config (to find 'binary' path mapping)
-	cfgBytes, err := os.ReadFile(srcCfg)
+	// Use ResolveTargetConfig to find the config file with hints
+	resolvedCfg, srcCfg, err := targets.ResolveTargetConfig(configPathOverride, projectDir, coreHint, projectHint, s.Report)
 	if err != nil {
-		return "", fmt.Errorf("failed to read signing config: %w", err)
+		return nil, fmt.Errorf("failed to resolve signing config: %w", err)
 	}
 
-	var cfg map[string]interface{}
-	if err := json.Unmarshal(cfgBytes, &cfg); err != nil {
-		return "", fmt.Errorf("failed to parse signing config: %w", err)
+	// Sync Toolkit Config to match the detected device
+	if err := targets.SyncToolkitConfig(s.Cfg.AlifToolsPath, resolvedCfg.GetCPU(), s.Report); err != nil {
+		s.Report.Warn(fmt.Sprintf("Toolkit sync failed: %v", err))
 	}
 
-	// 2. Find the application binary path in config (prioritize USER_APP or entries with mramAddress)
-	var binaryPathInConfig string
-	if userApp, ok := cfg["USER_APP"].(map[string]interface{}); ok {
-		if bin, ok := userApp["binary"].(string); ok {
-			binaryPathInConfig = bin
+	// Skip app-gen-toc when the last image was made from the same inputs.
+	var inputs state.ImageInputs
+	track := s.StateDir != "" && outDir != ""
+	if track {
+		if inputs, err = s.imageInputs(binaryPath, srcCfg); err != nil {
+			s.Report.Warn(fmt.Sprintf("Cannot fingerprint image inputs, regenerating: %v", err))
+			track = false
+		} else if !s.ForceImage {
+			ok, changed := s.reusableImage(outDir, inputs)
+			if ok {
+				s.Report.Success("Image up to date (binary, config and toolkit unchanged); skipping app-gen-toc")
+				result := outputArtifacts(outDir)
+				result.Config = srcCfg
+				return result, nil
+			}
+			s.Report.Item("Regenerating", strings.Join(changed, ", "))
 		}
 	}
 
-	if binaryPathInConfig == "" {
-		for k, v := range cfg {
-			if k == "DEVICE" {
-				continue
-			}
-			if sub, ok := v.(map[string]interface{}); ok {
-				if _, exists := sub["mramAddress"]; exists {
-					if bin, ok := sub["binary"].(string); ok {
-						binaryPathInConfig = bin
-						break
-					}
-				}
-			}
-		}
+	// 1. Load Config (to find 'binary' path mapping)
+	cfgBytes, _, err := targets.ReadConfig(srcCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing config: %w", err)
 	}
 
-	if binaryPathInConfig == "" {
-		return "", fmt.Errorf("could not find application binary field in config")
+	var cfg map[string]interface{}
+	if err := json.Unmarshal(cfgBytes, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse signing config: %w", err)
+	}
+
+	// 2. Find the application binary path in config
+	appSection, binaryPathInConfig, err := s.findAppSection(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(srcCfg), err)
 	}
 
 	// Double Staging: app-gen-toc is picky about locations.
 	// 1. Stage in toolkit root (legacy/internal reference)
 	rootDst := filepath.Join(s.Cfg.AlifToolsPath, binaryPathInConfig)
-	ui.Item("Staging", filepath.Base(rootDst))
+	s.Report.Item("Staging", filepath.Base(rootDst))
 	if err := os.MkdirAll(filepath.Dir(rootDst), 0755); err != nil {
-		return "", fmt.Errorf("failed to create directory for binary: %w", err)
+		return nil, fmt.Errorf("failed to create directory for binary: %w", err)
 	}
 	_ = os.Remove(rootDst)
-	if err := copyFile(binaryPath, rootDst); err != nil {
-		return "", fmt.Errorf("failed to copy binary to root: %w", err)
+	if err := audit.CopyFile(binaryPath, rootDst); err != nil {
+		return nil, fmt.Errorf("failed to copy binary to root: %w", err)
 	}
 
 	// 2. Stage in build/images/ (required for newer tool versions/specific configs)
 	imagesDst := filepath.Join(s.Cfg.AlifToolsPath, "build", "images", binaryPathInConfig)
 	if imagesDst != rootDst {
 		if err := os.MkdirAll(filepath.Dir(imagesDst), 0755); err != nil {
-			return "", fmt.Errorf("failed to create build/images directory: %w", err)
+			return nil, fmt.Errorf("failed to create build/images directory: %w", err)
 		}
 		_ = os.Remove(imagesDst)
-		if err := copyFile(binaryPath, imagesDst); err != nil {
-			return "", fmt.Errorf("failed to copy binary to build/images: %w", err)
+		if err := audit.CopyFile(binaryPath, imagesDst); err != nil {
+			return nil, fmt.Errorf("failed to copy binary to build/images: %w", err)
 		}
 	}
 
 	// 3. Copy config to toolkit dir to ensure relative paths work (staging)
 	stagedCfgPath := filepath.Join(s.Cfg.AlifToolsPath, "staged_config.json")
-	if err := copyFile(srcCfg, stagedCfgPath); err != nil {
-		return "", fmt.Errorf("failed to stage config file: %w", err)
+	if err := s.StageConfig(srcCfg, stagedCfgPath); err != nil {
+		return nil, fmt.Errorf("failed to stage config file: %w", err)
 	}
 	defer os.Remove(stagedCfgPath)
 
 	// 4. Run tool from ROOT with STAGED config
-	toolPath := filepath.Join(s.Cfg.AlifToolsPath, "app-gen-toc")
-	cmd := exec.Command(toolPath, "-f", "staged_config.json", "-o", "build/AppTocPackage.bin")
-	cmd.Dir = s.Cfg.AlifToolsPath
-
-	// Capture output
-	var output bytes.Buffer
-	cmd.Stdout = &output
-	cmd.Stderr = &output
-
-	sp := ui.StartSpinner("Running app-gen-toc...")
-	if err := cmd.Run(); err != nil {
-		sp.Fail("TOC generation failed")
-		fmt.Println("\n" + output.String()) // Print full tool output
-		return "", fmt.Errorf("app-gen-toc failed: %w", err)
-	}
-	sp.Succeed("TOC generated successfully")
-
-	// 4. Retrieve ALL generated artifacts back to Project buildDir
-	artifacts := []struct{ src, dst string }{
-		{filepath.Join(s.Cfg.AlifToolsPath, "build", "app-package-map.txt"), filepath.Join(buildDir, "app-package-map.txt")},
-		{filepath.Join(s.Cfg.AlifToolsPath, "build", "AppTocPackage.bin"), filepath.Join(buildDir, "AppTocPackage.bin")},
-		{filepath.Join(s.Cfg.AlifToolsPath, "build", "AppTocPackage.bin.sign"), filepath.Join(buildDir, "AppTocPackage.bin.sign")},
-		{filepath.Join(s.Cfg.AlifToolsPath, "build", "AppTocPackage.bin.crt"), filepath.Join(buildDir, "AppTocPackage.bin.crt")},
-		{rootDst + ".sign", filepath.Join(buildDir, "alif-img.bin.sign")},
-		{rootDst + ".crt", filepath.Join(buildDir, "alif-img.bin.crt")},
-		{rootDst, filepath.Join(buildDir, "alif-img.bin")},
-	}
-
-	for _, a := range artifacts {
-		if _, err := os.Stat(a.src); err == nil {
-			_ = os.Remove(a.dst)
-			if err := os.Rename(a.src, a.dst); err != nil {
-				_ = copyFile(a.src, a.dst)
-				_ = os.Remove(a.src)
+	if err := s.generateTOC(); err != nil {
+		return nil, err
+	}
+
+	toolkitBuild := filepath.Join(s.Cfg.AlifToolsPath, "build")
+	result := &Artifacts{
+		Image: rootDst,
+		TOC:   filepath.Join(toolkitBuild, "AppTocPackage.bin"),
+		Map:   filepath.Join(toolkitBuild, "app-package-map.txt"),
+	}
+	if outDir == "" {
+		s.Report.Item("Image", result.Image)
+		s.Report.Item("TOC", result.TOC)
+	} else {
+		// 4. Retrieve ALL generated artifacts into the output directory
+		artifacts := []struct{ src, dst string }{
+			{result.Map, filepath.Join(outDir, "app-package-map.txt")},
+			{result.TOC, filepath.Join(outDir, "AppTocPackage.bin")},
+			{result.TOC + ".sign", filepath.Join(outDir, "AppTocPackage.bin.sign")},
+			{result.TOC + ".crt", filepath.Join(outDir, "AppTocPackage.bin.crt")},
+			{rootDst + ".sign", filepath.Join(outDir, "alif-img.bin.sign")},
+			{rootDst + ".crt", filepath.Join(outDir, "alif-img.bin.crt")},
+			{rootDst, filepath.Join(outDir, "alif-img.bin")},
+		}
+
+		for _, a := range artifacts {
+			if _, err := os.Stat(a.src); err == nil {
+				if err := audit.Rename(a.src, a.dst); err != nil {
+					return nil, fmt.Errorf("failed to write %s: %w", a.dst, err)
+				}
 			}
 		}
+		result = outputArtifacts(outDir)
+	}
+	result.Config = srcCfg
+
+	if s.Compression != "" && s.Compression != CompressNone {
+		s.reportCompression(result.Map, binaryPath, filepath.Base(rootDst), cfg[appSection])
+	}
+
+	if track {
+		inputs.CreatedAt = time.Now()
+		if err := s.recordImage(outDir, inputs); err != nil {
+			s.Report.Warn(fmt.Sprintf("Failed to record image inputs: %v", err))
+		}
 	}
+	return result, nil
+}
 
-	// Report result
-	finalToc := filepath.Join(buildDir, "AppTocPackage.bin")
-	return finalToc, nil
+// outputArtifacts names the artifacts moved into dir.
+func outputArtifacts(dir string) *Artifacts {
+	return &Artifacts{
+		Image: filepath.Join(dir, "alif-img.bin"),
+		TOC:   filepath.Join(dir, "AppTocPackage.bin"),
+		Map:   filepath.Join(dir, "app-package-map.txt"),
+	}
 }
 
-func copyFile(src, dst string) error {
-	in, err := os.Open(src)
-	if err != nil {
+// checkWritable fails when files cannot be created in dir, so a read-only
+// destination is reported before app-gen-toc runs.
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
-	defer in.Close()
-	out, err := os.Create(dst)
+	probe, err := os.CreateTemp(dir, ".alif-write-check-*")
 	if err != nil {
 		return err
 	}
-	defer out.Close()
-	_, err = io.Copy(out, in)
-	return err
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+// StagingPath returns where SignArtifact copies the application binary in
+// the toolkit, as named by the signing config's application section.
+func (s *Signer) StagingPath(configPath string) (string, error) {
+	cfgBytes, _, err := targets.ReadConfig(configPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read signing config: %w", err)
+	}
+	var cfg map[string]interface{}
+	if err := json.Unmarshal(cfgBytes, &cfg); err != nil {
+		return "", fmt.Errorf("failed to parse signing config: %w", err)
+	}
+	_, binaryPathInConfig, err := s.findAppSection(cfg)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", filepath.Base(configPath), err)
+	}
+	return filepath.Join(s.Cfg.AlifToolsPath, binaryPathInConfig), nil
+}
+
+// generateTOC runs app-gen-toc on the staged config. Some toolkit versions
+// only pick up a freshly synced device on their second run, so the device
+// the tool reports is compared with global-cfg.db and the run is repeated
+// once on a mismatch.
+func (s *Signer) generateTOC() error {
+	wantPart, wantRev, _ := targets.ToolkitDevice(s.Cfg.AlifToolsPath)
+
+	for attempt := 1; ; attempt++ {
+		toolPath := filepath.Join(s.Cfg.AlifToolsPath, "app-gen-toc")
+		cmd := exec.Command(toolPath, "-f", "staged_config.json", "-o", "build/AppTocPackage.bin")
+		cmd.Dir = s.Cfg.AlifToolsPath
+
+		// Capture output
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+
+		sp := s.Report.StartTask("Running app-gen-toc...")
+		s.Report.Debug(fmt.Sprintf("run: %s (in %s)", strings.Join(cmd.Args, " "), cmd.Dir))
+		if err := cmd.Run(); err != nil {
+			sp.Fail("TOC generation failed")
+			s.Report.Output(output.String()) // Print full tool output
+			return fmt.Errorf("app-gen-toc failed: %w", err)
+		}
+
+		gotPart, gotRev, ok := ParseTOCDevice(output.String())
+		mismatch := ok && wantPart != "" && (gotPart != wantPart || (wantRev != "" && gotRev != wantRev))
+		if !mismatch {
+			sp.Succeed("TOC generated successfully")
+			return nil
+		}
+
+		used := fmt.Sprintf("%s rev %s", gotPart, gotRev)
+		want := fmt.Sprintf("%s rev %s", wantPart, wantRev)
+		if attempt > 1 {
+			sp.Fail("TOC generated for the wrong device")
+			return fmt.Errorf("app-gen-toc used device %s, expected %s", used, want)
+		}
+		sp.Fail("TOC generated for the wrong device")
+		s.Report.Warn(fmt.Sprintf("app-gen-toc used %s instead of %s; retrying once", used, want))
+	}
+}
+
+// ParseTOCDevice extracts the device app-gen-toc generated the package for,
+// from its "Device Part# <part> - Rev: <rev>" banner line.
+func ParseTOCDevice(output string) (part, revision string, ok bool) {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		rest, found := strings.CutPrefix(line, "Device Part#")
+		if !found {
+			continue
+		}
+		rest = strings.TrimSpace(rest)
+		if idx := strings.LastIndex(rest, " - Rev:"); idx != -1 {
+			return strings.TrimSpace(rest[:idx]), strings.TrimSpace(rest[idx+len(" - Rev:"):]), true
+		}
+		return rest, "", true
+	}
+	return "", "", false
+}
+
+// findAppSection returns the config section holding the application image
+// and its binary path: the one named by s.Section, or else the only section
+// with a binary and an mramAddress (USER_APP counts with a binary alone).
+// Several such sections are an error naming them, so a config for two
+// applications is never half updated.
+func (s *Signer) findAppSection(cfg map[string]interface{}) (string, string, error) {
+	if s.Section != "" {
+		sub, ok := cfg[s.Section].(map[string]interface{})
+		if !ok {
+			return "", "", fmt.Errorf("config has no section '%s' (image sections: %s)", s.Section, describeSections(appSections(cfg)))
+		}
+		bin, ok := sub["binary"].(string)
+		if !ok {
+			return "", "", fmt.Errorf("config section '%s' has no binary field", s.Section)
+		}
+		return s.Section, bin, nil
+	}
+
+	sections := appSections(cfg)
+	switch len(sections) {
+	case 0:
+		return "", "", fmt.Errorf("could not find application binary field in config (no section with binary and mramAddress)")
+	case 1:
+		bin, _ := cfg[sections[0]].(map[string]interface{})["binary"].(string)
+		return sections[0], bin, nil
+	}
+	return "", "", fmt.Errorf("config has several image sections (%s); choose one with --section, or flash them together with --all-cores", describeSections(sections))
+}
+
+// appSections lists, sorted, the config sections that describe an
+// application image.
+func appSections(cfg map[string]interface{}) []string {
+	var sections []string
+	for k, v := range cfg {
+		sub, ok := v.(map[string]interface{})
+		if !ok || k == "DEVICE" {
+			continue
+		}
+		if _, ok := sub["binary"].(string); !ok {
+			continue
+		}
+		if _, exists := sub["mramAddress"]; exists || k == "USER_APP" {
+			sections = append(sections, k)
+		}
+	}
+	sort.Strings(sections)
+	return sections
+}
+
+// describeSections joins section names for error messages.
+func describeSections(sections []string) string {
+	if len(sections) == 0 {
+		return "none"
+	}
+	return strings.Join(sections, ", ")
 }
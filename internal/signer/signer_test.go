@@ -0,0 +1,231 @@
+package signer
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"alif-cli/internal/config"
+	"alif-cli/internal/ui"
+)
+
+const e7Part = "E7 (AE722F80F55D5LS) - 5.5 MRAM / 13.5 SRAM"
+
+func TestParseTOCDevice(t *testing.T) {
+	tests := []struct {
+		fixture        string
+		part, revision string
+		ok             bool
+	}{
+		{"app-gen-toc-e7-b2.txt", e7Part, "B2", true},
+		{"app-gen-toc-stale-e3.txt", "E3 (AE302F80F55D5AE) - 5.5 MRAM / 13.5 SRAM", "A1", true},
+		{"app-gen-toc-no-device.txt", "", "", false},
+	}
+	for _, tt := range tests {
+		output, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		part, revision, ok := ParseTOCDevice(string(output))
+		if part != tt.part || revision != tt.revision || ok != tt.ok {
+			t.Errorf("%s: ParseTOCDevice = %q, %q, %v; want %q, %q, %v", tt.fixture, part, revision, ok, tt.part, tt.revision, tt.ok)
+		}
+	}
+
+	if part, revision, ok := ParseTOCDevice("Device Part# AE722F80F55D5LS\n"); part != "AE722F80F55D5LS" || revision != "" || !ok {
+		t.Errorf("banner without revision = %q, %q, %v", part, revision, ok)
+	}
+}
+
+// warnings is a silent Reporter that keeps its warnings.
+type warnings struct {
+	ui.Reporter
+	list []string
+}
+
+func (w *warnings) Warn(msg string) { w.list = append(w.list, msg) }
+
+// fakeTOCToolkit creates a toolkit set to the E7 rev B2 whose app-gen-toc
+// prints the given testdata captures, one per run, repeating the last.
+func fakeTOCToolkit(t *testing.T, outputs ...string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "utils"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	db := `{"DEVICE": {"Part#": "` + e7Part + `", "Revision": "B2"}}`
+	if err := os.WriteFile(filepath.Join(root, "utils", "global-cfg.db"), []byte(db), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for i, fixture := range outputs {
+		content, err := os.ReadFile(filepath.Join("testdata", fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, "out."+strconv.Itoa(i+1)), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	script := `#!/bin/sh
+n=$(( $(cat runs 2>/dev/null || echo 0) + 1 ))
+echo $n > runs
+[ -f out.$n ] || n=` + strconv.Itoa(len(outputs)) + `
+cat out.$n
+`
+	if err := os.WriteFile(filepath.Join(root, "app-gen-toc"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestGenerateTOCRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		outputs   []string
+		runs      int
+		warned    bool
+		errSubstr string
+	}{
+		{"right device", []string{"app-gen-toc-e7-b2.txt"}, 1, false, ""},
+		{"no device banner", []string{"app-gen-toc-no-device.txt"}, 1, false, ""},
+		{"stale device, then right", []string{"app-gen-toc-stale-e3.txt", "app-gen-toc-e7-b2.txt"}, 2, true, ""},
+		{"stale device twice", []string{"app-gen-toc-stale-e3.txt"}, 2, true, "expected " + e7Part + " rev B2"},
+	}
+	for _, tt := range tests {
+		root := fakeTOCToolkit(t, tt.outputs...)
+		w := &warnings{Reporter: ui.Silent}
+		s := &Signer{Cfg: &config.Config{AlifToolsPath: root}, Report: w}
+
+		err := s.generateTOC()
+		switch {
+		case tt.errSubstr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.errSubstr != "" && (err == nil || !strings.Contains(err.Error(), tt.errSubstr)):
+			t.Errorf("%s: error = %v, want one mentioning %q", tt.name, err, tt.errSubstr)
+		}
+		runs, _ := os.ReadFile(filepath.Join(root, "runs"))
+		if got := strings.TrimSpace(string(runs)); got != strconv.Itoa(tt.runs) {
+			t.Errorf("%s: app-gen-toc ran %s time(s), want %d", tt.name, got, tt.runs)
+		}
+		if (len(w.list) > 0) != tt.warned {
+			t.Errorf("%s: warnings %q, want some: %v", tt.name, w.list, tt.warned)
+		}
+	}
+}
+
+func TestSignArtifactOutput(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	tests := []struct {
+		name   string
+		output string // "DEST" is replaced by a folder outside the build folder
+		dir    string // where the artifacts end up: "build", "toolkit" or "DEST"
+	}{
+		{"default", "", "build"},
+		{"build", OutputBuildDir, "build"},
+		{"in place", OutputInPlace, "toolkit"},
+		{"directory", "DEST", "DEST"},
+		{"missing directory", "DEST/release/e7", "DEST/release/e7"},
+	}
+	for _, tt := range tests {
+		tk := imageToolkit(t)
+		build, dest := t.TempDir(), t.TempDir()
+		bin, targetCfg := filepath.Join(build, "blinky.bin"), filepath.Join(build, "app.json")
+		if err := os.WriteFile(bin, []byte("0123456789abcdef"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(targetCfg, []byte(oneSection), 0644); err != nil {
+			t.Fatal(err)
+		}
+		s := New(&config.Config{AlifToolsPath: tk})
+		s.Report = ui.Silent
+		s.Output = strings.Replace(tt.output, "DEST", dest, 1)
+		art, err := s.SignArtifact(build, build, bin, "", "", targetCfg)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+
+		want := map[string]string{
+			"build":   build,
+			"toolkit": filepath.Join(tk, "build"),
+		}[tt.dir]
+		if want == "" {
+			want = filepath.Join(dest, filepath.FromSlash(strings.TrimPrefix(tt.dir, "DEST")))
+		}
+		image := filepath.Join(want, "alif-img.bin")
+		if tt.dir == "toolkit" {
+			// app-gen-toc reads the image from the toolkit root.
+			image = filepath.Join(tk, "alif-img.bin")
+		}
+		if art.Image != image || art.TOC != filepath.Join(want, "AppTocPackage.bin") || art.Map != filepath.Join(want, "app-package-map.txt") {
+			t.Errorf("%s: artifacts %s, %s, %s; want them in %s", tt.name, art.Image, art.TOC, art.Map, want)
+		}
+		for _, path := range []string{art.Image, art.TOC, art.Map} {
+			if _, err := os.Stat(path); err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+		}
+		// Moved artifacts leave nothing behind in the toolkit.
+		_, err = os.Stat(filepath.Join(tk, "build", "AppTocPackage.bin"))
+		if inToolkit := err == nil; inToolkit != (tt.dir == "toolkit") {
+			t.Errorf("%s: TOC left in the toolkit: %v", tt.name, inToolkit)
+		}
+		if tt.dir != "build" {
+			if _, err := os.Stat(filepath.Join(build, "AppTocPackage.bin")); err == nil {
+				t.Errorf("%s: TOC written to the build folder too", tt.name)
+			}
+		}
+	}
+}
+
+func TestSignArtifactReadOnlyDestination(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	tests := []struct {
+		name     string
+		output   string // "RO" is replaced by the read-only folder
+		readOnly string // which folder is made read-only: "build" or "dest"
+	}{
+		{"read-only build folder", OutputBuildDir, "build"},
+		{"read-only directory", "RO", "dest"},
+		{"below a read-only directory", "RO/e7", "dest"},
+	}
+	for _, tt := range tests {
+		tk := imageToolkit(t)
+		build, dest := t.TempDir(), t.TempDir()
+		bin, targetCfg := filepath.Join(build, "blinky.bin"), filepath.Join(build, "app.json")
+		if err := os.WriteFile(bin, []byte("0123456789abcdef"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(targetCfg, []byte(oneSection), 0644); err != nil {
+			t.Fatal(err)
+		}
+		ro := map[string]string{"build": build, "dest": dest}[tt.readOnly]
+		if err := os.Chmod(ro, 0555); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { os.Chmod(ro, 0755) })
+		if f, err := os.CreateTemp(ro, "probe-*"); err == nil {
+			f.Close()
+			os.Remove(f.Name())
+			t.Skip("permissions are not enforced for this user")
+		}
+
+		s := New(&config.Config{AlifToolsPath: tk})
+		s.Report = ui.Silent
+		s.Output = strings.Replace(tt.output, "RO", dest, 1)
+		_, err := s.SignArtifact(build, build, bin, "", "", targetCfg)
+		if err == nil || !strings.Contains(err.Error(), "cannot write the image to") || !strings.Contains(err.Error(), "--artifacts in-place") {
+			t.Errorf("%s: error = %v, want one naming the read-only destination", tt.name, err)
+		}
+		// The destination is checked before the toolkit is touched.
+		if _, err := os.Stat(filepath.Join(tk, "runs")); err == nil {
+			t.Errorf("%s: app-gen-toc ran", tt.name)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package state
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"alif-cli/internal/audit"
+	"alif-cli/internal/eta"
+)
+
+// FileName is the project state file, stored in the project's .alif folder.
+const FileName = "flash_state.json"
+
+// JLinkResolution records how a target was mapped to a J-Link device.
+type JLinkResolution struct {
+	Device string `json:"device"`
+	Script string `json:"script,omitempty"`
+	// XMLHash is the SHA-256 of the JLinkDevices.xml the mapping came from.
+	XMLHash string `json:"xml_hash,omitempty"`
+	// Fallback is set when no mapping was found and the generic device was used.
+	Fallback   bool      `json:"fallback,omitempty"`
+	ResolvedAt time.Time `json:"resolved_at"`
+}
+
+// PortMemory records the serial port of the last successful flash.
+type PortMemory struct {
+	Path string `json:"path"`
+	// Serial is the USB serial number used to find the board again.
+	Serial  string    `json:"serial"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+// ImageInputs records the SHA-256 of what a bootable image was generated
+// from, so an unchanged image is not generated again.
+type ImageInputs struct {
+	Binary string `json:"binary"`
+	Config string `json:"config"`
+	// Toolkit covers app-gen-toc and the device selected in global-cfg.db.
+	Toolkit     string    `json:"toolkit"`
+	Compression string    `json:"compression,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// DeviceIdentity is what the connected board reported about itself over
+// ISP, kept for a short while so one command does not probe it twice.
+type DeviceIdentity struct {
+	PartNumber string    `json:"part_number"`
+	Revision   string    `json:"revision"`
+	ProbedAt   time.Time `json:"probed_at"`
+}
+
+// ToolchainVersions records the cbuild and arm-none-eabi-gcc a build ran
+// with, so a toolchain upgrade between builds of a context is noticed.
+type ToolchainVersions struct {
+	Cbuild     string    `json:"cbuild,omitempty"`
+	CbuildPath string    `json:"cbuild_path,omitempty"`
+	GCC        string    `json:"gcc,omitempty"`
+	GCCPath    string    `json:"gcc_path,omitempty"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// State is the per-project state persisted between CLI runs.
+type State struct {
+	JLink map[string]JLinkResolution `json:"jlink,omitempty"`
+	Port  *PortMemory                `json:"port,omitempty"`
+	// Durations holds recent run times per eta.Key, used for estimates.
+	Durations map[string][]eta.Sample `json:"durations,omitempty"`
+	// Images holds the inputs of the last image generated per build
+	// directory (relative to the project).
+	Images map[string]ImageInputs `json:"images,omitempty"`
+	// Devices holds recent device identity probes, keyed by the USB
+	// serial number of the board's port (or the port path without one).
+	Devices map[string]DeviceIdentity `json:"devices,omitempty"`
+	// Toolchains holds the toolchain of the last successful build per
+	// context ("all" for a build of every context).
+	Toolchains map[string]ToolchainVersions `json:"toolchains,omitempty"`
+
+	path string
+}
+
+// Path returns the state file location for a project's .alif directory.
+func Path(alifDir string) string {
+	return filepath.Join(alifDir, FileName)
+}
+
+// Load reads the state stored in alifDir. A missing file yields empty state.
+func Load(alifDir string) (*State, error) {
+	st := &State{path: Path(alifDir)}
+	content, err := os.ReadFile(st.path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	if err := json.Unmarshal(content, st); err != nil {
+		return st, err
+	}
+	return st, nil
+}
+
+// Save writes the state back to the file it was loaded from.
+func (s *State) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return err
+	}
+	return audit.WriteFile(s.path, content, 0644)
+}
+
+// SetJLink records the J-Link resolution for a target.
+func (s *State) SetJLink(target string, res JLinkResolution) {
+	if s.JLink == nil {
+		s.JLink = make(map[string]JLinkResolution)
+	}
+	s.JLink[target] = res
+}
+
+// updateMu serializes Update within the process (parallel workspace builds
+// share one state file).
+var updateMu sync.Mutex
+
+// Update loads the state in alifDir, applies fn and saves it.
+func Update(alifDir string, fn func(*State)) error {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+	st, err := Load(alifDir)
+	if err != nil {
+		return err
+	}
+	fn(st)
+	return st.Save()
+}
+
+// RecordDuration adds a successful run's duration under key.
+func (s *State) RecordDuration(key string, d time.Duration, size int64) {
+	if s.Durations == nil {
+		s.Durations = make(map[string][]eta.Sample)
+	}
+	s.Durations[key] = eta.Add(s.Durations[key], eta.Sample{Seconds: d.Seconds(), Size: size, At: time.Now()})
+}
+
+// EstimateDuration predicts a run's duration from the samples under key.
+func (s *State) EstimateDuration(key string, size int64) (time.Duration, bool) {
+	return eta.Estimate(s.Durations[key], size)
+}
@@ -0,0 +1,68 @@
+package targets
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"alif-cli/internal/assets"
+)
+
+// BuiltinDBLabel names the embedded device database wherever a device
+// resolved from it is shown.
+const BuiltinDBLabel = "built-in database (toolkit DB not found)"
+
+type builtinDB struct {
+	FeatureSets []struct {
+		Name      string `xml:"name,attr"`
+		MRAMBase  string `xml:"mramBase,attr"`
+		Revisions string `xml:"revisions,attr"`
+	} `xml:"featureSet"`
+	Parts []struct {
+		Name       string `xml:"name,attr"`
+		Family     string `xml:"family,attr"`
+		FeatureSet string `xml:"featureSet,attr"`
+		AppSize    string `xml:"appSize,attr"`
+		MRAMSize   string `xml:"mramSize,attr"`
+		SRAMSize   string `xml:"sramSize,attr"`
+		Cores      string `xml:"cores,attr"`
+	} `xml:"part"`
+}
+
+// builtinDatabases parses assets.DevicesXML into the shapes of the
+// toolkit's devicesDB.db and featuresDB.db, so callers handle both alike.
+func builtinDatabases() (devices, features map[string]interface{}, err error) {
+	var db builtinDB
+	if err := xml.Unmarshal(assets.DevicesXML, &db); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse built-in device database: %w", err)
+	}
+	features = map[string]interface{}{}
+	for _, fs := range db.FeatureSets {
+		features[fs.Name] = map[string]interface{}{
+			"mram_base": fs.MRAMBase,
+			"revisions": stringList(fs.Revisions),
+		}
+	}
+	devices = map[string]interface{}{}
+	for _, p := range db.Parts {
+		devices[p.Name] = map[string]interface{}{
+			"family":     p.Family,
+			"featureSet": p.FeatureSet,
+			"app_size":   p.AppSize,
+			"mram_size":  p.MRAMSize,
+			"sram_size":  p.SRAMSize,
+			"cores":      stringList(p.Cores),
+		}
+	}
+	return devices, features, nil
+}
+
+// stringList splits a space-separated attribute into the []interface{} a
+// JSON array decodes to.
+func stringList(s string) []interface{} {
+	var list []interface{}
+	for _, f := range strings.Fields(s) {
+		list = append(list, f)
+	}
+	return list
+}
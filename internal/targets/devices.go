@@ -0,0 +1,201 @@
+package targets
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DeviceInfo describes a part as listed in the toolkit's device databases.
+type DeviceInfo struct {
+	// PartName is the full devicesDB key, e.g. "E7 (AE722F80F55D5LS) - 5.5 MRAM / 13.5 SRAM".
+	PartName string
+	// PartNumber is the bare part number, e.g. "AE722F80F55D5LS".
+	PartNumber string
+	Family     string
+	FeatureSet string
+	MRAMBase   uint64
+	// AppSize is the size of the MRAM area available to applications.
+	AppSize uint64
+	// SRAMSize is the total on-chip SRAM in bytes (0 if unknown).
+	SRAMSize  uint64
+	Revisions []string
+	// Cores lists the part's cores by toolkit cpu_id, e.g. "M55_HE" (empty
+	// if unknown).
+	Cores []string
+	// Source is BuiltinDBLabel when the toolkit databases were missing and
+	// the device came from the built-in one, or "" otherwise.
+	Source string
+}
+
+// DisplayName returns the part name, marked when it came from the
+// built-in database.
+func (d *DeviceInfo) DisplayName() string {
+	if d.Source == "" {
+		return d.PartName
+	}
+	return fmt.Sprintf("%s [%s]", d.PartName, d.Source)
+}
+
+// AppEnd returns the first address past the application MRAM area.
+func (d *DeviceInfo) AppEnd() uint64 {
+	return d.MRAMBase + d.AppSize
+}
+
+// loadDevicesDB reads the toolkit's utils/devicesDB.db. When the toolkit
+// has none, the built-in database is returned and builtin is true.
+func loadDevicesDB(alifToolsPath string) (devices map[string]interface{}, builtin bool, err error) {
+	dbBytes, err := os.ReadFile(filepath.Join(alifToolsPath, "utils", "devicesDB.db"))
+	if os.IsNotExist(err) {
+		devices, _, err = builtinDatabases()
+		return devices, true, err
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read devices database: %w", err)
+	}
+	if err := json.Unmarshal(dbBytes, &devices); err != nil {
+		return nil, false, fmt.Errorf("failed to parse devices database: %w", err)
+	}
+	return devices, false, nil
+}
+
+// loadFeaturesDB reads the toolkit's utils/featuresDB.db, falling back to
+// the built-in database like loadDevicesDB.
+func loadFeaturesDB(alifToolsPath string) (features map[string]interface{}, builtin bool, err error) {
+	fdbBytes, err := os.ReadFile(filepath.Join(alifToolsPath, "utils", "featuresDB.db"))
+	if os.IsNotExist(err) {
+		_, features, err = builtinDatabases()
+		return features, true, err
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read features database: %w", err)
+	}
+	if err := json.Unmarshal(fdbBytes, &features); err != nil {
+		return nil, false, fmt.Errorf("failed to parse features database: %w", err)
+	}
+	return features, false, nil
+}
+
+// featureRevisions returns the revisions featuresDB lists for featureSet.
+func featureRevisions(features map[string]interface{}, featureSet string) []string {
+	feat, _ := features[featureSet].(map[string]interface{})
+	return stringSlice(feat["revisions"])
+}
+
+// findPartName returns the devicesDB key containing targetID, ignoring any
+// core suffix (e.g. AE722F80F55D5LS:M55_HE).
+func findPartName(devices map[string]interface{}, targetID string) string {
+	id := strings.Split(targetID, ":")[0]
+	if id == "" {
+		return ""
+	}
+	for key := range devices {
+		if strings.Contains(key, id) {
+			return key
+		}
+	}
+	return ""
+}
+
+// LookupDevice resolves a part number (or full devicesDB key) to its
+// memory layout.
+func LookupDevice(alifToolsPath, targetID string) (*DeviceInfo, error) {
+	devices, builtinDevices, err := loadDevicesDB(alifToolsPath)
+	if err != nil {
+		return nil, err
+	}
+	partName := findPartName(devices, targetID)
+	if partName == "" {
+		return nil, fmt.Errorf("device '%s' not found in devices database", targetID)
+	}
+
+	entry, _ := devices[partName].(map[string]interface{})
+	info := &DeviceInfo{PartName: partName, PartNumber: partName}
+	if lp, rp := strings.Index(partName, "("), strings.Index(partName, ")"); lp != -1 && rp > lp {
+		info.PartNumber = partName[lp+1 : rp]
+	}
+	info.Family, _ = entry["family"].(string)
+	info.FeatureSet, _ = entry["featureSet"].(string)
+	if size, ok := entry["app_size"].(string); ok {
+		info.AppSize, _ = strconv.ParseUint(strings.TrimPrefix(size, "0x"), 16, 64)
+	}
+	// sram_size is given in MB, e.g. "13.5".
+	if size, ok := entry["sram_size"].(string); ok {
+		if mb, err := strconv.ParseFloat(size, 64); err == nil {
+			info.SRAMSize = uint64(mb * 1024 * 1024)
+		}
+	}
+
+	info.Cores = stringSlice(entry["cores"])
+
+	features, builtinFeatures, err := loadFeaturesDB(alifToolsPath)
+	if err == nil {
+		if feat, ok := features[info.FeatureSet].(map[string]interface{}); ok {
+			if base, ok := feat["mram_base"].(string); ok {
+				info.MRAMBase, _ = strconv.ParseUint(strings.TrimPrefix(base, "0x"), 16, 64)
+			}
+		}
+		info.Revisions = featureRevisions(features, info.FeatureSet)
+	}
+	if builtinDevices || builtinFeatures {
+		info.Source = BuiltinDBLabel
+	}
+	// The toolkit's devicesDB does not list cores; take them from the
+	// built-in database.
+	if len(info.Cores) == 0 {
+		if builtin, _, err := builtinDatabases(); err == nil {
+			if e, ok := builtin[partName].(map[string]interface{}); ok {
+				info.Cores = stringSlice(e["cores"])
+			}
+		}
+	}
+
+	if info.MRAMBase == 0 || info.AppSize == 0 {
+		return nil, fmt.Errorf("device '%s' has no MRAM layout in the toolkit databases", partName)
+	}
+	return info, nil
+}
+
+// stringSlice converts a decoded JSON array of strings.
+func stringSlice(v interface{}) []string {
+	list, _ := v.([]interface{})
+	var out []string
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// ToolkitDevice returns the Part# and Revision the toolkit is configured
+// for in utils/global-cfg.db.
+func ToolkitDevice(alifToolsPath string) (part, revision string, err error) {
+	cfgBytes, err := os.ReadFile(filepath.Join(alifToolsPath, "utils", "global-cfg.db"))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read toolkit global config: %w", err)
+	}
+	var globalCfg map[string]map[string]interface{}
+	if err := json.Unmarshal(cfgBytes, &globalCfg); err != nil {
+		return "", "", fmt.Errorf("failed to parse toolkit global config: %w", err)
+	}
+	part, _ = globalCfg["DEVICE"]["Part#"].(string)
+	revision, _ = globalCfg["DEVICE"]["Revision"].(string)
+	return part, revision, nil
+}
+
+// CurrentDevice returns the device the toolkit is currently configured for
+// (DEVICE/Part# in utils/global-cfg.db).
+func CurrentDevice(alifToolsPath string) (*DeviceInfo, error) {
+	part, _, err := ToolkitDevice(alifToolsPath)
+	if err != nil {
+		return nil, err
+	}
+	if part == "" {
+		return nil, fmt.Errorf("toolkit has no device selected")
+	}
+	return LookupDevice(alifToolsPath, part)
+}
@@ -0,0 +1,181 @@
+package targets
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"alif-cli/internal/ui"
+)
+
+const e7Part = "E7 (AE722F80F55D5LS) - 5.5 MRAM / 13.5 SRAM"
+
+type warnings struct {
+	ui.Reporter
+	list []string
+}
+
+func (w *warnings) Warn(msg string) { w.list = append(w.list, msg) }
+
+// toolkitDir creates a toolkit whose utils folder holds the given
+// databases; a nil value leaves that file out.
+func toolkitDir(t *testing.T, dbs map[string]interface{}) string {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "utils"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, db := range dbs {
+		if db == nil {
+			continue
+		}
+		data, err := json.Marshal(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, "utils", name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// The toolkit's databases differ from the built-in one in app size and
+// revisions, so tests can tell which source a value came from.
+var (
+	toolkitDevices = map[string]interface{}{
+		e7Part: map[string]interface{}{"family": "Ensemble", "featureSet": "Fusion", "app_size": "0x570000", "sram_size": "13.5"},
+	}
+	toolkitFeatures = map[string]interface{}{
+		"Fusion": map[string]interface{}{"mram_base": "0x80000000", "revisions": []string{"B2", "B4"}},
+	}
+)
+
+func TestLookupDeviceSources(t *testing.T) {
+	cores := []string{"A32_0", "A32_1", "M55_HP", "M55_HE"}
+	tests := []struct {
+		name     string
+		devices  interface{}
+		features interface{}
+		want     DeviceInfo
+	}{
+		{"toolkit databases", toolkitDevices, toolkitFeatures, DeviceInfo{
+			AppSize: 0x570000, Revisions: []string{"B2", "B4"}, Cores: cores,
+		}},
+		{"no toolkit databases", nil, nil, DeviceInfo{
+			AppSize: 0x580000, Revisions: []string{"B4"}, Cores: cores, Source: BuiltinDBLabel,
+		}},
+		{"no features database", toolkitDevices, nil, DeviceInfo{
+			AppSize: 0x570000, Revisions: []string{"B4"}, Cores: cores, Source: BuiltinDBLabel,
+		}},
+		{"no devices database", nil, toolkitFeatures, DeviceInfo{
+			AppSize: 0x580000, Revisions: []string{"B2", "B4"}, Cores: cores, Source: BuiltinDBLabel,
+		}},
+	}
+	for _, tt := range tests {
+		tk := toolkitDir(t, map[string]interface{}{"devicesDB.db": tt.devices, "featuresDB.db": tt.features})
+		info, err := LookupDevice(tk, "AE722F80F55D5LS:M55_HE")
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		want := tt.want
+		want.PartName, want.PartNumber, want.Family, want.FeatureSet = e7Part, "AE722F80F55D5LS", "Ensemble", "Fusion"
+		want.MRAMBase, want.SRAMSize = 0x80000000, 13.5*1024*1024
+		if !reflect.DeepEqual(*info, want) {
+			t.Errorf("%s: LookupDevice =\n%+v\nwant\n%+v", tt.name, *info, want)
+		}
+	}
+}
+
+func TestLookupDeviceToolkitOnly(t *testing.T) {
+	// A part only the toolkit knows resolves from it; the built-in
+	// database adds nothing.
+	devices := map[string]interface{}{
+		"E9 (AE922XX) - 8 MRAM / 16 SRAM": map[string]interface{}{"family": "Ensemble", "featureSet": "Fusion", "app_size": "0x780000"},
+	}
+	tk := toolkitDir(t, map[string]interface{}{"devicesDB.db": devices, "featuresDB.db": toolkitFeatures})
+	info, err := LookupDevice(tk, "AE922XX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.DisplayName() != "E9 (AE922XX) - 8 MRAM / 16 SRAM" || info.Cores != nil || info.AppEnd() != 0x80780000 {
+		t.Errorf("LookupDevice = %+v", *info)
+	}
+	// A part the toolkit database lacks is not taken from the built-in one.
+	if _, err := LookupDevice(tk, "AE722F80F55D5LS"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("built-in part with a toolkit database: error = %v", err)
+	}
+}
+
+func TestBuiltinDatabases(t *testing.T) {
+	devices, features, err := builtinDatabases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, prefix := range []string{"E1C", "E3", "E5", "E7", "E8", "B1"} {
+		found := false
+		for name := range devices {
+			found = found || strings.HasPrefix(name, prefix+" (")
+		}
+		if !found {
+			t.Errorf("no %s part", prefix)
+		}
+	}
+	for name, v := range devices {
+		entry := v.(map[string]interface{})
+		fs, _ := entry["featureSet"].(string)
+		if len(featureRevisions(features, fs)) == 0 {
+			t.Errorf("%s: feature set %q has no revisions", name, fs)
+		}
+		if size, err := strconv.ParseUint(strings.TrimPrefix(entry["app_size"].(string), "0x"), 16, 64); err != nil || size == 0 {
+			t.Errorf("%s: app_size %q", name, entry["app_size"])
+		}
+		if len(stringSlice(entry["cores"])) == 0 {
+			t.Errorf("%s: no cores", name)
+		}
+		if _, err := strconv.ParseFloat(entry["sram_size"].(string), 64); err != nil {
+			t.Errorf("%s: sram_size %q", name, entry["sram_size"])
+		}
+	}
+}
+
+func TestSyncToolkitConfigSources(t *testing.T) {
+	tests := []struct {
+		name     string
+		devices  interface{}
+		features interface{}
+		revision string // the toolkit's current revision
+		wantRev  string
+		warned   bool
+	}{
+		{"toolkit databases keep a listed revision", toolkitDevices, toolkitFeatures, "B2", "B2", false},
+		{"toolkit databases replace an unlisted revision", toolkitDevices, toolkitFeatures, "A0", "B2", false},
+		{"built-in database", nil, nil, "B2", "B4", true},
+		{"built-in features database", toolkitDevices, nil, "B4", "B4", true},
+	}
+	for _, tt := range tests {
+		tk := toolkitDir(t, map[string]interface{}{
+			"devicesDB.db":  tt.devices,
+			"featuresDB.db": tt.features,
+			"global-cfg.db": map[string]interface{}{"DEVICE": map[string]interface{}{"Part#": "", "Revision": tt.revision}},
+		})
+		w := &warnings{Reporter: ui.Silent}
+		if err := SyncToolkitConfig(tk, "AE722F80F55D5LS:M55_HE", w); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		part, rev, err := ToolkitDevice(tk)
+		if err != nil || part != e7Part || rev != tt.wantRev {
+			t.Errorf("%s: toolkit set to %q rev %q (%v), want rev %s", tt.name, part, rev, err, tt.wantRev)
+		}
+		warned := len(w.list) == 1 && strings.Contains(w.list[0], BuiltinDBLabel)
+		if warned != tt.warned || (!tt.warned && len(w.list) != 0) {
+			t.Errorf("%s: warnings %q", tt.name, w.list)
+		}
+	}
+}
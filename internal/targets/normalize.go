@@ -0,0 +1,209 @@
+package targets
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Problems NormalizeConfig repairs.
+const (
+	FixUTF8BOM     = "UTF-8 byte order mark removed"
+	FixUTF16       = "UTF-16 text converted to UTF-8"
+	FixSmartQuote  = "typographic quote replaced with \""
+	FixLineEndings = "mixed line endings converted to LF"
+)
+
+// ConfigFix is one repair made by NormalizeConfig. Offset is a byte offset
+// in the original file, or in the UTF-8 text after a UTF-16 conversion.
+type ConfigFix struct {
+	Offset  int
+	Problem string
+}
+
+// NormalizeConfig repairs what editors and copy-paste leave in JSON configs
+// that app-gen-toc's Python parser rejects with a misleading error at line 1:
+// byte order marks, UTF-16 encoding, typographic quotes used as string
+// delimiters (as copied from PDFs) and mixed line endings. Quotes
+// inside properly quoted strings are left alone. data is returned as is
+// when nothing needed fixing.
+func NormalizeConfig(data []byte) ([]byte, []ConfigFix) {
+	var fixes []ConfigFix
+	// shift maps offsets in the text being fixed back to the original file.
+	shift := 0
+
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		data = data[3:]
+		shift = 3
+		fixes = append(fixes, ConfigFix{0, FixUTF8BOM})
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		data = decodeUTF16(data[2:], binary.LittleEndian)
+		fixes = append(fixes, ConfigFix{0, FixUTF16})
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		data = decodeUTF16(data[2:], binary.BigEndian)
+		fixes = append(fixes, ConfigFix{0, FixUTF16})
+	case len(data) >= 2 && data[0] != 0 && data[1] == 0:
+		// No BOM, but an ASCII first character followed by NUL.
+		data = decodeUTF16(data, binary.LittleEndian)
+		fixes = append(fixes, ConfigFix{0, FixUTF16})
+	case len(data) >= 2 && data[0] == 0 && data[1] != 0:
+		data = decodeUTF16(data, binary.BigEndian)
+		fixes = append(fixes, ConfigFix{0, FixUTF16})
+	}
+
+	// Line endings are checked before quotes change byte lengths.
+	lineOffset, mixed := mixedLineEndings(data)
+
+	data, quoteFixes := replaceSmartQuotes(data)
+	for _, f := range quoteFixes {
+		fixes = append(fixes, ConfigFix{f.Offset + shift, f.Problem})
+	}
+
+	if mixed {
+		data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+		data = bytes.ReplaceAll(data, []byte("\r"), []byte("\n"))
+		fixes = append(fixes, ConfigFix{lineOffset + shift, FixLineEndings})
+	}
+	return data, fixes
+}
+
+func decodeUTF16(data []byte, order binary.ByteOrder) []byte {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	var out bytes.Buffer
+	for _, r := range utf16.Decode(units) {
+		out.WriteRune(r)
+	}
+	return out.Bytes()
+}
+
+// smartQuote returns '"' or '\” for a typographic double or single
+// quote, and 0 for anything else.
+func smartQuote(r rune) rune {
+	switch r {
+	case '“', '”', '„', '‟', '″':
+		return '"'
+	case '‘', '’', '‚', '‛', '′':
+		return '\''
+	}
+	return 0
+}
+
+// replaceSmartQuotes turns typographic quotes that open or close a string
+// into ASCII double quotes. A string opened with an ASCII quote keeps any
+// typographic quotes in its text; one opened with a typographic quote is
+// closed by an ASCII quote or a typographic one of the same kind, so an
+// apostrophe inside “...” stays as it is.
+func replaceSmartQuotes(data []byte) ([]byte, []ConfigFix) {
+	var fixes []ConfigFix
+	var out []byte
+	inString, escaped := false, false
+	var opener rune // kind of typographic quote that opened the string
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		kind := smartQuote(r)
+		delimiter := false
+		switch {
+		case inString && escaped:
+			escaped = false
+		case inString && r == '\\':
+			escaped = true
+		case inString && r == '"':
+			inString = false
+		case inString && opener != 0 && kind == opener:
+			inString, delimiter = false, true
+		case !inString && r == '"':
+			inString, opener = true, 0
+		case !inString && kind != 0:
+			inString, opener, delimiter = true, kind, true
+		}
+		if delimiter && out == nil {
+			out = append([]byte{}, data[:i]...)
+		}
+		switch {
+		case delimiter:
+			out = append(out, '"')
+			fixes = append(fixes, ConfigFix{i, FixSmartQuote})
+		case out != nil:
+			out = append(out, data[i:i+size]...)
+		}
+		i += size
+	}
+	if out == nil {
+		return data, nil
+	}
+	return out, fixes
+}
+
+// mixedLineEndings reports whether data mixes CRLF, LF and bare CR line
+// endings, with the offset of the first ending that differs from the first
+// one seen.
+func mixedLineEndings(data []byte) (int, bool) {
+	first := ""
+	for i := 0; i < len(data); i++ {
+		var kind string
+		switch {
+		case data[i] == '\r' && i+1 < len(data) && data[i+1] == '\n':
+			kind = "crlf"
+		case data[i] == '\r':
+			kind = "cr"
+		case data[i] == '\n':
+			kind = "lf"
+		default:
+			continue
+		}
+		if first == "" {
+			first = kind
+		} else if kind != first {
+			return i, true
+		}
+		if kind == "crlf" {
+			i++
+		}
+	}
+	return 0, false
+}
+
+// DescribeFixes summarizes fixes one line per problem, e.g.
+// "typographic quote replaced with \" at offsets 12, 40".
+func DescribeFixes(fixes []ConfigFix) []string {
+	offsets := map[string][]string{}
+	var order []string
+	for _, f := range fixes {
+		if _, ok := offsets[f.Problem]; !ok {
+			order = append(order, f.Problem)
+		}
+		offsets[f.Problem] = append(offsets[f.Problem], fmt.Sprint(f.Offset))
+	}
+	var lines []string
+	for _, p := range order {
+		list := offsets[p]
+		label := "offset"
+		if len(list) > 1 {
+			label = "offsets"
+		}
+		if len(list) > 8 {
+			list = append(list[:8:8], fmt.Sprintf("and %d more", len(list)-8))
+		}
+		lines = append(lines, fmt.Sprintf("%s at %s %s", p, label, strings.Join(list, ", ")))
+	}
+	return lines
+}
+
+// ReadConfig reads a JSON config and returns it normalized by
+// NormalizeConfig, along with what was fixed.
+func ReadConfig(path string) ([]byte, []ConfigFix, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, fixes := NormalizeConfig(data)
+	return data, fixes, nil
+}
@@ -0,0 +1,146 @@
+package targets
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"unicode/utf16"
+)
+
+var bom = []byte{0xEF, 0xBB, 0xBF}
+
+// utf16Bytes encodes s as UTF-16 in order, after prefix (a BOM or nothing).
+func utf16Bytes(s string, order binary.AppendByteOrder, prefix ...byte) []byte {
+	out := append([]byte{}, prefix...)
+	for _, u := range utf16.Encode([]rune(s)) {
+		out = order.AppendUint16(out, u)
+	}
+	return out
+}
+
+func cat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestNormalizeConfig(t *testing.T) {
+	quote := func(offsets ...int) []ConfigFix {
+		var fixes []ConfigFix
+		for _, o := range offsets {
+			fixes = append(fixes, ConfigFix{o, FixSmartQuote})
+		}
+		return fixes
+	}
+	tests := []struct {
+		name  string
+		in    []byte
+		want  string
+		fixes []ConfigFix
+	}{
+		{"clean", []byte("{\"a\": \"b\"}\n"), "{\"a\": \"b\"}\n", nil},
+		{"empty", nil, "", nil},
+		{"one byte", []byte("{"), "{", nil},
+		{"consistent CRLF", []byte("{\r\n\"a\": 1\r\n}\r\n"), "{\r\n\"a\": 1\r\n}\r\n", nil},
+		{"UTF-8 BOM", cat(bom, []byte(`{"a":1}`)), `{"a":1}`, []ConfigFix{{0, FixUTF8BOM}}},
+		{"UTF-16LE with BOM", utf16Bytes(`{"a":1}`, binary.LittleEndian, 0xFF, 0xFE), `{"a":1}`, []ConfigFix{{0, FixUTF16}}},
+		{"UTF-16BE with BOM", utf16Bytes(`{"a":1}`, binary.BigEndian, 0xFE, 0xFF), `{"a":1}`, []ConfigFix{{0, FixUTF16}}},
+		{"UTF-16LE without BOM", utf16Bytes(`{"a":1}`, binary.LittleEndian), `{"a":1}`, []ConfigFix{{0, FixUTF16}}},
+		{"UTF-16BE without BOM", utf16Bytes(`{"a":1}`, binary.BigEndian), `{"a":1}`, []ConfigFix{{0, FixUTF16}}},
+		{"UTF-16 text beyond ASCII", utf16Bytes(`{"név":"µ"}`, binary.LittleEndian, 0xFF, 0xFE), `{"név":"µ"}`, []ConfigFix{{0, FixUTF16}}},
+		{"smart double quotes", []byte(`{“a”: “b”}`), `{"a": "b"}`, quote(1, 5, 10, 14)},
+		{"smart single quotes", []byte(`{‘a’: 1}`), `{"a": 1}`, quote(1, 5)},
+		{"low and reversed quotes", []byte(`{„a‟: 1}`), `{"a": 1}`, quote(1, 5)},
+		{"smart opener, ASCII closer", []byte(`{“a": 1}`), `{"a": 1}`, quote(1)},
+		{"quotes inside an ASCII string", []byte(`{"a": "it’s “x”"}`), `{"a": "it’s “x”"}`, nil},
+		{"apostrophe inside smart quotes", []byte(`{“a”: “it’s”}`), `{"a": "it’s"}`, quote(1, 5, 10, 19)},
+		{"escaped quote before a smart one", []byte(`{"a": "x\"“y"}`), `{"a": "x\"“y"}`, nil},
+		{"escaped backslash ends the string", []byte(`{"a": "x\\", “b”: 1}`), `{"a": "x\\", "b": 1}`, quote(13, 17)},
+		{"primes as quotes", []byte(`{″a″: ′b′}`), `{"a": "b"}`, quote(1, 5, 10, 14)},
+		{"BOM shifts quote offsets", cat(bom, []byte(`{“a”: 1}`)), `{"a": 1}`, []ConfigFix{{0, FixUTF8BOM}, {4, FixSmartQuote}, {8, FixSmartQuote}}},
+		{"UTF-16 quote offsets are in UTF-8", utf16Bytes(`{“a”: 1}`, binary.LittleEndian, 0xFF, 0xFE), `{"a": 1}`, []ConfigFix{{0, FixUTF16}, {1, FixSmartQuote}, {5, FixSmartQuote}}},
+		{"CRLF then LF", []byte("{\r\n\"a\": 1\n}"), "{\n\"a\": 1\n}", []ConfigFix{{9, FixLineEndings}}},
+		{"LF then CRLF", []byte("{\n\"a\": 1\r\n}"), "{\n\"a\": 1\n}", []ConfigFix{{8, FixLineEndings}}},
+		{"bare CR then LF", []byte("{\r\"a\": 1\n}"), "{\n\"a\": 1\n}", []ConfigFix{{8, FixLineEndings}}},
+		{"BOM shifts the line offset", cat(bom, []byte("{\r\n\"a\": 1\n}")), "{\n\"a\": 1\n}", []ConfigFix{{0, FixUTF8BOM}, {12, FixLineEndings}}},
+		{"line offset before quotes shrink", []byte("{“a”: 1\r\n}\n"), "{\"a\": 1\n}\n", []ConfigFix{{1, FixSmartQuote}, {5, FixSmartQuote}, {14, FixLineEndings}}},
+		{"everything", cat(bom, []byte("{“a”: 1\r\n}\n")), "{\"a\": 1\n}\n", []ConfigFix{{0, FixUTF8BOM}, {4, FixSmartQuote}, {8, FixSmartQuote}, {17, FixLineEndings}}},
+	}
+	for _, tt := range tests {
+		got, fixes := NormalizeConfig(tt.in)
+		if string(got) != tt.want {
+			t.Errorf("%s: NormalizeConfig = %q, want %q", tt.name, got, tt.want)
+		}
+		if !reflect.DeepEqual(fixes, tt.fixes) {
+			t.Errorf("%s: fixes = %v, want %v", tt.name, fixes, tt.fixes)
+		}
+	}
+}
+
+func FuzzNormalizeConfig(f *testing.F) {
+	for _, seed := range [][]byte{
+		[]byte(`{"a": "b"}`),
+		cat(bom, []byte(`{“a”: ‘b’}`)),
+		utf16Bytes("{\"a\":\r\n1\n}", binary.LittleEndian, 0xFF, 0xFE),
+		{0xFF, 0xFE, 0x00},
+		{0xE2, 0x80},
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, in []byte) {
+		out, fixes := NormalizeConfig(in)
+		if len(fixes) == 0 && !bytes.Equal(out, in) {
+			t.Errorf("NormalizeConfig(%q) changed the data to %q without reporting a fix", in, out)
+		}
+	})
+}
+
+func TestDescribeFixes(t *testing.T) {
+	var many []ConfigFix
+	for i := 0; i < 10; i++ {
+		many = append(many, ConfigFix{i * 10, FixSmartQuote})
+	}
+	tests := []struct {
+		name  string
+		fixes []ConfigFix
+		want  []string
+	}{
+		{"none", nil, nil},
+		{"one", []ConfigFix{{0, FixUTF8BOM}}, []string{"UTF-8 byte order mark removed at offset 0"}},
+		{"grouped in order seen",
+			[]ConfigFix{{0, FixUTF8BOM}, {4, FixSmartQuote}, {8, FixSmartQuote}, {17, FixLineEndings}},
+			[]string{
+				"UTF-8 byte order mark removed at offset 0",
+				`typographic quote replaced with " at offsets 4, 8`,
+				"mixed line endings converted to LF at offset 17",
+			}},
+		{"long list elided", many, []string{`typographic quote replaced with " at offsets 0, 10, 20, 30, 40, 50, 60, 70, and 2 more`}},
+	}
+	for _, tt := range tests {
+		if got := DescribeFixes(tt.fixes); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: DescribeFixes = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "he.json")
+	if err := os.WriteFile(path, cat(bom, []byte(`{“USER_APP”: {"cpu_id": "M55_HE"}}`)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data, fixes, err := ReadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"USER_APP": {"cpu_id": "M55_HE"}}` || len(fixes) != 3 {
+		t.Errorf("ReadConfig = %q, %v", data, fixes)
+	}
+	if _, _, err := ReadConfig(filepath.Join(t.TempDir(), "missing.json")); !os.IsNotExist(err) {
+		t.Errorf("missing file: error = %v", err)
+	}
+	// The file itself is left for the user to fix.
+	if src, err := os.ReadFile(path); err != nil || !bytes.HasPrefix(src, bom) {
+		t.Errorf("source file changed: %q, %v", src, err)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"regexp"
 	"strings"
 
+	"alif-cli/internal/audit"
 	"alif-cli/internal/ui"
 )
 
@@ -51,7 +52,7 @@ func (tc TargetConfig) GetCPU() string {
 }
 
 // ResolveTargetConfig determines the configuration to use
-func ResolveTargetConfig(explicitPath string, searchRoot string, coreHint, projectHint string) (TargetConfig, string, error) {
+func ResolveTargetConfig(explicitPath string, searchRoot string, coreHint, projectHint string, r ui.Reporter) (TargetConfig, string, error) {
 	var finalConfig TargetConfig
 	var resolvedPath string
 	// var sourceDescription string // Handled by UI Item
@@ -59,15 +60,15 @@ func ResolveTargetConfig(explicitPath string, searchRoot string, coreHint, proje
 	// 1. Explicit Config
 	if explicitPath != "" {
 		resolvedPath = explicitPath
-		content, err := os.ReadFile(explicitPath)
+		content, _, err := ReadConfig(explicitPath)
 		if err != nil {
 			return nil, "", fmt.Errorf("failed to read config file '%s': %w", explicitPath, err)
 		}
 		if err = json.Unmarshal(content, &finalConfig); err != nil {
 			return nil, "", fmt.Errorf("failed to parse config file '%s': %w", explicitPath, err)
 		}
-		ui.Item("Config Source", "Explicit File")
-		ui.Item("File", filepath.Base(explicitPath))
+		r.Item("Config Source", "Explicit File")
+		r.Item("File", filepath.Base(explicitPath))
 	} else {
 		// 2. Auto-detect logic
 		root := "."
@@ -89,7 +90,7 @@ func ResolveTargetConfig(explicitPath string, searchRoot string, coreHint, proje
 				if strings.Contains(base, "device-config") || base == "vcpkg-configuration.json" {
 					continue
 				}
-				content, err := os.ReadFile(f)
+				content, _, err := ReadConfig(f)
 				if err == nil {
 					var temp TargetConfig
 					if json.Unmarshal(content, &temp) == nil {
@@ -116,7 +117,7 @@ func ResolveTargetConfig(explicitPath string, searchRoot string, coreHint, proje
 				if len(filtered) > 0 {
 					candidates = filtered
 					if len(candidates) == 1 {
-						ui.Item("Auto-Select", fmt.Sprintf("Based on core hint '%s'", coreHint))
+						r.Item("Auto-Select", fmt.Sprintf("Based on core hint '%s'", coreHint))
 					}
 				}
 			}
@@ -131,7 +132,7 @@ func ResolveTargetConfig(explicitPath string, searchRoot string, coreHint, proje
 				if len(filtered) > 0 {
 					candidates = filtered
 					if len(candidates) == 1 {
-						ui.Item("Auto-Select", fmt.Sprintf("Based on project hint '%s'", projectHint))
+						r.Item("Auto-Select", fmt.Sprintf("Based on project hint '%s'", projectHint))
 					}
 				}
 			}
@@ -139,23 +140,17 @@ func ResolveTargetConfig(explicitPath string, searchRoot string, coreHint, proje
 
 		if len(candidates) == 1 {
 			resolvedPath = candidates[0]
-			ui.Item("Config", filepath.Base(resolvedPath))
+			r.Item("Config", filepath.Base(resolvedPath))
 		} else {
-			fmt.Println("Multiple configuration files found:")
-			for i, c := range candidates {
-				fmt.Printf("[%d] %s\n", i+1, c)
+			selection, err := ui.Select("Multiple configuration files found:", "Select configuration file (enter number): ", candidates, "Pass -c <config.json> to choose one.")
+			if err != nil {
+				return nil, "", err
 			}
-			fmt.Print("Select configuration file (enter number): ")
-			var selection int
-			_, err := fmt.Scanln(&selection)
-			if err != nil || selection < 1 || selection > len(candidates) {
-				return nil, "", fmt.Errorf("invalid selection")
-			}
-			resolvedPath = candidates[selection-1]
-			ui.Item("Selected", filepath.Base(resolvedPath))
+			resolvedPath = candidates[selection]
+			r.Item("Selected", filepath.Base(resolvedPath))
 		}
 
-		content, err := os.ReadFile(resolvedPath)
+		content, _, err := ReadConfig(resolvedPath)
 		if err != nil {
 			return nil, "", fmt.Errorf("failed to read selected config: %w", err)
 		}
@@ -168,32 +163,20 @@ func ResolveTargetConfig(explicitPath string, searchRoot string, coreHint, proje
 }
 
 // SyncToolkitConfig updates the toolkit's global configuration to match the project's target device
-func SyncToolkitConfig(alifToolsPath string, targetID string) error {
+func SyncToolkitConfig(alifToolsPath string, targetID string, r ui.Reporter) error {
 	if alifToolsPath == "" || targetID == "" {
 		return nil
 	}
 
 	// 1. Resolve the full Part# string from devicesDB.db
-	devicesDBPath := filepath.Join(alifToolsPath, "utils", "devicesDB.db")
-	dbBytes, err := os.ReadFile(devicesDBPath)
+	devices, builtinDevices, err := loadDevicesDB(alifToolsPath)
 	if err != nil {
-		return fmt.Errorf("failed to read devices database: %w", err)
-	}
-
-	var devices map[string]interface{}
-	if err := json.Unmarshal(dbBytes, &devices); err != nil {
-		return fmt.Errorf("failed to parse devices database: %w", err)
+		return err
 	}
 
 	// Strip core suffix if present (e.g., AE722F80F55D5LS:M55_HE -> AE722F80F55D5LS)
 	id := strings.Split(targetID, ":")[0]
-	var fullPartName string
-	for key := range devices {
-		if strings.Contains(key, id) {
-			fullPartName = key
-			break
-		}
-	}
+	fullPartName := findPartName(devices, targetID)
 
 	if fullPartName == "" {
 		// Log a debug message but don't error.
@@ -222,28 +205,23 @@ func SyncToolkitConfig(alifToolsPath string, targetID string) error {
 	featureSet, _ := deviceInfo["featureSet"].(string)
 
 	validRev := ""
-	featuresDBPath := filepath.Join(alifToolsPath, "utils", "featuresDB.db")
-	if fdbBytes, err := os.ReadFile(featuresDBPath); err == nil {
-		var features map[string]interface{}
-		if err := json.Unmarshal(fdbBytes, &features); err == nil {
-			if feat, ok := features[featureSet].(map[string]interface{}); ok {
-				if revs, ok := feat["revisions"].([]interface{}); ok && len(revs) > 0 {
-					currentRev, _ := globalCfg["DEVICE"]["Revision"].(string)
-					// Check if current is valid
-					for _, r := range revs {
-						if r.(string) == currentRev {
-							validRev = currentRev
-							break
-						}
-					}
-					// If not valid, pick the first one
-					if validRev == "" {
-						validRev = revs[0].(string)
-					}
+	features, builtinFeatures, err := loadFeaturesDB(alifToolsPath)
+	if err == nil {
+		if revs := featureRevisions(features, featureSet); len(revs) > 0 {
+			currentRev, _ := globalCfg["DEVICE"]["Revision"].(string)
+			// Check if current is valid, otherwise pick the first one
+			validRev = revs[0]
+			for _, rev := range revs {
+				if rev == currentRev {
+					validRev = currentRev
+					break
 				}
 			}
 		}
 	}
+	if builtinDevices || builtinFeatures {
+		r.Warn(fmt.Sprintf("Syncing from the %s; its part list and revisions may be older than this toolkit", BuiltinDBLabel))
+	}
 
 	// Default to A0 if we couldn't find anything in DBs (fallback)
 	if validRev == "" {
@@ -253,19 +231,19 @@ func SyncToolkitConfig(alifToolsPath string, targetID string) error {
 	// 4. Update and check if actual changes are needed
 	needsUpdate := false
 	if globalCfg["DEVICE"]["Part#"] != fullPartName {
-		ui.Item("Toolkit Sync", fmt.Sprintf("Part# → %s", id))
+		r.Item("Toolkit Sync", fmt.Sprintf("Part# → %s", id))
 		globalCfg["DEVICE"]["Part#"] = fullPartName
 		needsUpdate = true
 	} else {
-		ui.Item("Toolkit Target", id)
+		r.Item("Toolkit Target", id)
 	}
 
 	if globalCfg["DEVICE"]["Revision"] != validRev {
-		ui.Item("Toolkit Sync", fmt.Sprintf("Rev → %s", validRev))
+		r.Item("Toolkit Sync", fmt.Sprintf("Rev → %s", validRev))
 		globalCfg["DEVICE"]["Revision"] = validRev
 		needsUpdate = true
 	} else {
-		ui.Item("Toolkit Rev", validRev)
+		r.Item("Toolkit Rev", validRev)
 	}
 
 	if !needsUpdate {
@@ -277,15 +255,13 @@ func SyncToolkitConfig(alifToolsPath string, targetID string) error {
 		return fmt.Errorf("failed to encode toolkit global config: %w", err)
 	}
 
-	return os.WriteFile(globalCfgPath, newCfgBytes, 0644)
+	return audit.WriteFile(globalCfgPath, newCfgBytes, 0644)
 }
 
-// VerifyConnectedDevice probes the hardware and compares it with the expected ID
-func VerifyConnectedDevice(alifToolsPath string, expectedID string) error {
-	if alifToolsPath == "" || expectedID == "" {
-		return nil
-	}
-
+// ProbeDevice asks the connected board for its part number and revision
+// with the maintenance tool. It takes seconds over ISP; callers go through
+// the flasher's identity cache rather than calling it directly.
+func ProbeDevice(alifToolsPath string, r ui.Reporter) (partNumber, revision string, err error) {
 	// 1. Run maintenance tool to get revision info
 	// We use the menu sequence: 2 (Device Info) -> 5 (Get Revision Info) -> Enter -> Enter
 	toolPath := filepath.Join(alifToolsPath, "maintenance")
@@ -297,10 +273,10 @@ func VerifyConnectedDevice(alifToolsPath string, expectedID string) error {
 	cmd.Stdout = &output
 	cmd.Stderr = &output
 
-	sp := ui.StartSpinner("Verifying connected hardware...")
+	sp := r.StartTask("Verifying connected hardware...")
 	if err := cmd.Run(); err != nil {
 		sp.Fail("Hardware probe failed")
-		return fmt.Errorf("could not communicate with board: %w", err)
+		return "", "", fmt.Errorf("could not communicate with board: %w", err)
 	}
 
 	// 2. Parse output for ALIF_PN and Version
@@ -340,19 +316,22 @@ func VerifyConnectedDevice(alifToolsPath string, expectedID string) error {
 
 	if actualPN == "" {
 		sp.Fail("Verification failed")
-		return fmt.Errorf("target did not report its part number")
+		return "", "", fmt.Errorf("target did not report its part number")
 	}
+	sp.Succeed("Hardware probed")
+	return actualPN, actualRev, nil
+}
 
-	// 3. Compare with expected
+// MatchDevice compares a probed part number with the expected ID
+// ("part" or "part:core"), reporting the result.
+func MatchDevice(partNumber, revision, expectedID string, r ui.Reporter) error {
 	expectedBase := strings.Split(expectedID, ":")[0]
-
-	if !strings.Contains(actualPN, expectedBase) {
-		sp.Fail("Hardware Mismatch!")
-		ui.Warn(fmt.Sprintf("Connected: %s (Rev %s)", actualPN, actualRev))
-		ui.Warn(fmt.Sprintf("Expected:  %s", expectedBase))
+	if !strings.Contains(partNumber, expectedBase) {
+		r.Warn("Hardware Mismatch!")
+		r.Warn(fmt.Sprintf("Connected: %s (Rev %s)", partNumber, revision))
+		r.Warn(fmt.Sprintf("Expected:  %s", expectedBase))
 		return fmt.Errorf("hardware mismatch")
 	}
-
-	sp.Succeed(fmt.Sprintf("Hardware Match: %s (Rev %s)", actualPN, actualRev))
+	r.Success(fmt.Sprintf("Hardware Match: %s (Rev %s)", partNumber, revision))
 	return nil
 }
@@ -0,0 +1,92 @@
+// Package toc recognises the packaged images app-gen-toc produces, so a
+// file that is already bootable is not wrapped in another TOC.
+package toc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Identifier opens the APP TOC header app-gen-toc writes at the end of an
+// APP package (AppTocPackage.bin).
+const Identifier = "OEMTOC01"
+
+// tailSize is the length of the APP TOC tail that closes a package: its
+// CRC32, the MRAM address of the TOC header, the package start address and
+// the package size, each a little-endian 32-bit word.
+const tailSize = 16
+
+// headerOffset returns where the APP TOC header of a package lies in data,
+// as its tail gives it, or false when data does not end in a tail that
+// describes data itself.
+func headerOffset(data []byte) (int, bool) {
+	if len(data) < tailSize+len(Identifier) {
+		return 0, false
+	}
+	tail := data[len(data)-tailSize:]
+	header := binary.LittleEndian.Uint32(tail[4:])
+	start := binary.LittleEndian.Uint32(tail[8:])
+	size := binary.LittleEndian.Uint32(tail[12:])
+	if int64(size) != int64(len(data)) || header < start {
+		return 0, false
+	}
+	offset := int64(header - start)
+	if offset+int64(len(Identifier)) > int64(len(data)-tailSize) {
+		return 0, false
+	}
+	return int(offset), true
+}
+
+// Detect returns why the file at path looks like an already packaged
+// image rather than a raw application binary: it ends in an APP TOC tail
+// pointing at an APP TOC header, or it has the .sign/.crt companions of a
+// signed image next to it. It returns "" for a raw binary, also one that
+// happens to contain the Identifier elsewhere.
+func Detect(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if offset, ok := headerOffset(data); ok && bytes.HasPrefix(data[offset:], []byte(Identifier)) {
+		return fmt.Sprintf("it contains an APP TOC header (%s), like AppTocPackage.bin", Identifier), nil
+	}
+	for _, suffix := range []string{".sign", ".crt"} {
+		if _, err := os.Stat(path + suffix); err == nil {
+			return fmt.Sprintf("%s lies next to it, like a signed alif-img.bin", filepath.Base(path+suffix)), nil
+		}
+	}
+	return "", nil
+}
+
+// InsideDir reports whether path lies inside dir. Both are compared by
+// file identity rather than by name, so a symlink into dir, a symlinked
+// dir and a differently cased path on a case-insensitive filesystem all
+// count.
+func InsideDir(path, dir string) bool {
+	dirInfo, err := os.Stat(dir)
+	if err != nil || !dirInfo.IsDir() {
+		return false
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	candidates := []string{abs}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil && resolved != abs {
+		candidates = append(candidates, resolved)
+	}
+	for _, p := range candidates {
+		for d := filepath.Dir(p); ; d = filepath.Dir(d) {
+			if info, err := os.Stat(d); err == nil && os.SameFile(info, dirInfo) {
+				return true
+			}
+			if filepath.Dir(d) == d {
+				break
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package toc
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// appPackage lays out an APP package as app-gen-toc writes it at start:
+// the images, the APP TOC header, one entry and the tail. sizeDelta is
+// added to the size the tail gives.
+func appPackage(images []byte, start uint32, sizeDelta int) []byte {
+	header := append([]byte(Identifier), make([]byte, 24)...)
+	binary.LittleEndian.PutUint16(header[8:], 0x20)
+	copy(header[16:], "AE722F80F55D5LS0")
+	data := append(append(append([]byte(nil), images...), header...), make([]byte, 32)...)
+	tail := make([]byte, tailSize)
+	binary.LittleEndian.PutUint32(tail[4:], start+uint32(len(images)))
+	binary.LittleEndian.PutUint32(tail[8:], start)
+	binary.LittleEndian.PutUint32(tail[12:], uint32(len(data)+tailSize+sizeDelta))
+	return append(data, tail...)
+}
+
+func TestDetect(t *testing.T) {
+	app := []byte("\x00\x00\x02\x20\x41\x01\x00\x80 raw application code")
+	tests := []struct {
+		name      string
+		data      []byte
+		companion string
+		reason    string // substring of the reason; "" for a raw binary
+	}{
+		{"raw binary", app, "", ""},
+		{"raw binary with the identifier inside", append(append([]byte(nil), app...), "strings: OEMTOC01 table\x00"...), "", ""},
+		{"raw binary ending in the identifier", append(append([]byte(nil), app...), Identifier...), "", ""},
+		{"empty", nil, "", ""},
+		{"packaged image", appPackage(app, 0x8057a770, 0), "", "it contains an APP TOC header (OEMTOC01)"},
+		{"package tail with another size", appPackage(app, 0x8057a770, 4), "", ""},
+		{"package with a damaged header", []byte(strings.Replace(string(appPackage(app, 0x80000000, 0)), Identifier, "OEMTOC00", 1)), "", ""},
+		{"signed image", app, ".sign", "app.bin.sign lies next to it"},
+		{"certificate next to it", app, ".crt", "app.bin.crt lies next to it"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "app.bin")
+		if err := os.WriteFile(path, tt.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if tt.companion != "" {
+			if err := os.WriteFile(path+tt.companion, []byte("SIG"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		reason, err := Detect(path)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if (tt.reason == "") != (reason == "") || !strings.Contains(reason, tt.reason) {
+			t.Errorf("%s: reason %q, want %q", tt.name, reason, tt.reason)
+		}
+	}
+
+	if _, err := Detect(filepath.Join(t.TempDir(), "missing.bin")); err == nil {
+		t.Error("missing file: no error")
+	}
+}
+
+func TestInsideDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	// root/toolkit/build/images/alif-img.bin
+	// root/link -> toolkit
+	// root/project/alif-img.bin -> ../toolkit/build/images/alif-img.bin
+	// root/project/app.bin
+	root := t.TempDir()
+	toolkit := filepath.Join(root, "toolkit")
+	image := filepath.Join(toolkit, "build", "images", "alif-img.bin")
+	project := filepath.Join(root, "project")
+	for _, dir := range []string{filepath.Dir(image), project} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{image, filepath.Join(project, "app.bin")} {
+		if err := os.WriteFile(f, []byte("APP"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(toolkit, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join("..", "toolkit", "build", "images", "alif-img.bin"), filepath.Join(project, "alif-img.bin")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		dir  string
+		want bool
+	}{
+		{"image in the toolkit", image, toolkit, true},
+		{"image through a symlinked toolkit path", filepath.Join(root, "link", "build", "images", "alif-img.bin"), toolkit, true},
+		{"image against the symlinked toolkit path", image, filepath.Join(root, "link"), true},
+		{"symlink into the toolkit", filepath.Join(project, "alif-img.bin"), toolkit, true},
+		{"raw binary in the project", filepath.Join(project, "app.bin"), toolkit, false},
+		{"toolkit sibling with a common prefix", filepath.Join(project, "app.bin"), filepath.Join(root, "proj"), false},
+		{"missing toolkit", image, filepath.Join(root, "missing"), false},
+		{"toolkit is a file", image, filepath.Join(project, "app.bin"), false},
+	}
+	for _, tt := range tests {
+		if got := InsideDir(tt.path, tt.dir); got != tt.want {
+			t.Errorf("%s: InsideDir(%q, %q) = %v, want %v", tt.name, tt.path, tt.dir, got, tt.want)
+		}
+	}
+}
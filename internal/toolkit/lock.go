@@ -0,0 +1,191 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"alif-cli/internal/portlock"
+	"alif-cli/internal/ui"
+)
+
+// LockFile is the lock taken in a toolkit directory while images, TOCs and
+// configs are staged there. Toolkit copies must not link it.
+const LockFile = ".alif.lock"
+
+// LockTimeout bounds how long an operation waits for another one to finish
+// with the toolkit.
+const LockTimeout = 2 * time.Minute
+
+// lockPoll is how often a waiting operation checks the lock.
+const lockPoll = 250 * time.Millisecond
+
+// Holder is the content of a lock file.
+type Holder struct {
+	PID       int       `json:"pid"`
+	Command   string    `json:"command"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// hold is this process's hold on a lock. count lets an operation that
+// locks the toolkit call one that locks it again (such as signing inside a
+// flash); stop ends the Ctrl-C watch.
+type hold struct {
+	count int
+	stop  func()
+}
+
+var (
+	heldMu sync.Mutex
+	held   = map[string]*hold{}
+)
+
+// Lock takes the staging lock of the toolkit at dir, waiting up to timeout
+// while another alif process holds it. A lock left by a process that is no
+// longer running is taken over. The returned function releases the lock;
+// Ctrl-C releases it too. A toolkit the lock cannot be created in (one
+// that is not writable) is not locked.
+func Lock(dir string, timeout time.Duration, r ui.Reporter) (func(), error) {
+	path := filepath.Join(dir, LockFile)
+	heldMu.Lock()
+	if h := held[path]; h != nil {
+		h.count++
+		heldMu.Unlock()
+		return func() { unlock(path) }, nil
+	}
+	heldMu.Unlock()
+
+	content, err := json.Marshal(Holder{PID: os.Getpid(), Command: commandName(), StartedAt: time.Now()})
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	waiting := false
+	for {
+		err := createLock(path, content)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrExist) {
+			r.Debug(fmt.Sprintf("Not locking %s: %v", dir, err))
+			return func() {}, nil
+		}
+		holder, err := readHolder(path)
+		if err != nil || !portlock.ProcessAlive(holder.PID) {
+			// Left behind by a process that was killed, or half written.
+			if err == nil || time.Since(lockTime(path)) > time.Second {
+				os.Remove(path)
+			}
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("another alif operation is in progress (pid %d: %s since %s) and holds the toolkit at %s; wait for it to finish or stop it",
+				holder.PID, holder.Command, holder.StartedAt.Local().Format("15:04:05"), dir)
+		}
+		if !waiting {
+			r.Info(fmt.Sprintf("Waiting for another alif operation (pid %d: %s) to finish with the toolkit...", holder.PID, holder.Command))
+			waiting = true
+		}
+		time.Sleep(lockPoll)
+	}
+	heldMu.Lock()
+	held[path] = &hold{count: 1, stop: watchInterrupt(path)}
+	heldMu.Unlock()
+	return func() { unlock(path) }, nil
+}
+
+// createLock creates the lock file only when it does not exist yet.
+func createLock(path string, content []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(content)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func readHolder(path string) (*Holder, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var h Holder
+	if err := json.Unmarshal(content, &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// lockTime is when the lock file was written, or now when it is gone.
+func lockTime(path string) time.Time {
+	if info, err := os.Stat(path); err == nil {
+		return info.ModTime()
+	}
+	return time.Now()
+}
+
+// unlock drops one hold on path and removes the lock with the last one.
+func unlock(path string) {
+	heldMu.Lock()
+	defer heldMu.Unlock()
+	h := held[path]
+	if h == nil {
+		return
+	}
+	h.count--
+	if h.count == 0 {
+		delete(held, path)
+		os.Remove(path)
+		h.stop()
+	}
+}
+
+// watchInterrupt removes the lock at path when Ctrl-C is pressed while it
+// is held, then passes the interrupt on: to a running tool's handler when
+// there is one, otherwise to the default action, which ends the process.
+// The returned function ends the watch.
+func watchInterrupt(path string) func() {
+	interrupt := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		select {
+		case <-done:
+			return
+		case <-interrupt:
+		}
+		heldMu.Lock()
+		delete(held, path)
+		os.Remove(path)
+		heldMu.Unlock()
+		signal.Stop(interrupt)
+		if p, err := os.FindProcess(os.Getpid()); err != nil || p.Signal(os.Interrupt) != nil {
+			os.Exit(130)
+		}
+	}()
+	return func() {
+		signal.Stop(interrupt)
+		close(done)
+	}
+}
+
+// commandName describes this process for the lock holder, e.g. "alif flash".
+func commandName() string {
+	args := []string{filepath.Base(os.Args[0])}
+	for _, a := range os.Args[1:] {
+		if strings.HasPrefix(a, "-") {
+			break
+		}
+		args = append(args, a)
+	}
+	return strings.Join(args, " ")
+}
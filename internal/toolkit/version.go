@@ -0,0 +1,27 @@
+package toolkit
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// versionTimeout bounds the app-gen-toc -V run Version makes.
+const versionTimeout = 10 * time.Second
+
+// Version returns the version line app-gen-toc prints for -V, or "" when
+// the toolkit at dir does not report one.
+func Version(dir string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), versionTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, filepath.Join(dir, "app-gen-toc"), "-V")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return strings.TrimSpace(line)
+}
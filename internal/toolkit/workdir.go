@@ -0,0 +1,167 @@
+// Package toolkit runs the Alif Security Toolkit from a per-user working
+// directory when the installation itself is read-only.
+//
+// The toolkit's tools resolve utils/, build/, cert/ and bin/ relative to
+// their working directory, and the CLI stages images, configs and
+// isp_config_data.cfg there too. A work directory mirrors the installation:
+// directories that receive output are real directories, files the CLI
+// rewrites are copied, and everything else (binaries, databases, keys) is a
+// symlink back to the installation.
+package toolkit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"alif-cli/internal/audit"
+)
+
+// ErrIncompatible is returned when an installation cannot be run through a
+// work directory.
+var ErrIncompatible = errors.New("toolkit cannot run from a read-only installation")
+
+// linkedDirs are created as real directories whose entries link back to the
+// installation, so new files can be added next to them.
+var linkedDirs = []string{".", "utils"}
+
+// copiedDirs receive tool output; their files are copied so the tools can
+// overwrite them. Subdirectories not listed (e.g. build/config) are linked.
+var copiedDirs = []string{"build", "build/images", "build/logs", "cert", "bin"}
+
+// copiedFiles are rewritten in place by the CLI.
+var copiedFiles = []string{"utils/global-cfg.db", "isp_config_data.cfg"}
+
+// requiredFiles must exist in the installation.
+var requiredFiles = []string{"app-gen-toc", "app-write-mram", "utils/global-cfg.db"}
+
+// Writable reports whether files can be created in dir.
+func Writable(dir string) bool {
+	f, err := os.CreateTemp(dir, ".alif-write-test-*")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return true
+}
+
+// DefaultWorkDir returns ~/.alif/toolkit-work/<hash>, where hash identifies
+// the installation path.
+func DefaultWorkDir(toolsPath string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(toolsPath)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(home, ".alif", "toolkit-work", hex.EncodeToString(sum[:])[:12]), nil
+}
+
+// Prepare creates or refreshes the work directory for toolsPath. Existing
+// entries are kept, so state written by earlier runs (global-cfg.db, the
+// ISP config) survives; delete the directory to start over.
+func Prepare(toolsPath, workDir string) error {
+	for _, name := range requiredFiles {
+		if _, err := os.Stat(filepath.Join(toolsPath, name)); err != nil {
+			return fmt.Errorf("%w: %s not found in %s", ErrIncompatible, name, toolsPath)
+		}
+	}
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return fmt.Errorf("failed to create toolkit work directory: %w", err)
+	}
+	if err := mirror(toolsPath, workDir, "."); err != nil {
+		return err
+	}
+	for _, rel := range copiedDirs {
+		if err := os.MkdirAll(filepath.Join(workDir, rel), 0755); err != nil {
+			return fmt.Errorf("failed to create toolkit work directory: %w", err)
+		}
+	}
+	return nil
+}
+
+// NewOverlay makes dir a private copy of the toolkit at toolsPath for one
+// board of a batch flashed at once. It is laid out like a work directory,
+// so global-cfg.db, the ISP config and everything under build/ belong to
+// the board and only read-only content links back. Staged TOC packages are
+// left out; each board stages its own.
+func NewOverlay(toolsPath, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create toolkit overlay: %w", err)
+	}
+	if err := mirror(toolsPath, dir, "."); err != nil {
+		return err
+	}
+	for _, rel := range copiedDirs {
+		if err := os.MkdirAll(filepath.Join(dir, rel), 0755); err != nil {
+			return fmt.Errorf("failed to create toolkit overlay: %w", err)
+		}
+	}
+	return nil
+}
+
+// mirror fills workDir/rel from toolsPath/rel.
+func mirror(toolsPath, workDir, rel string) error {
+	entries, err := os.ReadDir(filepath.Join(toolsPath, rel))
+	if err != nil {
+		return fmt.Errorf("failed to read toolkit: %w", err)
+	}
+	copyFiles := contains(copiedDirs, rel)
+
+	for _, e := range entries {
+		entryRel := filepath.Join(rel, e.Name())
+		if entryRel == LockFile || stagedTOC(entryRel) {
+			continue
+		}
+		src := filepath.Join(toolsPath, entryRel)
+		dst := filepath.Join(workDir, entryRel)
+
+		if e.IsDir() && (contains(linkedDirs, entryRel) || contains(copiedDirs, entryRel)) {
+			if err := os.MkdirAll(dst, 0755); err != nil {
+				return fmt.Errorf("failed to create toolkit work directory: %w", err)
+			}
+			if err := mirror(toolsPath, workDir, entryRel); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, err := os.Lstat(dst); err == nil {
+			continue
+		}
+		if !e.IsDir() && (copyFiles || contains(copiedFiles, entryRel)) {
+			if err := audit.CopyFile(src, dst); err != nil {
+				return fmt.Errorf("failed to copy %s: %w", entryRel, err)
+			}
+			continue
+		}
+		if err := audit.Symlink(src, dst); err != nil {
+			return fmt.Errorf("%w: cannot link %s: %v", ErrIncompatible, entryRel, err)
+		}
+	}
+	return nil
+}
+
+// stagedTOC reports a TOC package, or its signature or certificate, that
+// the CLI stages in the toolkit root or build/ for each flash.
+func stagedTOC(rel string) bool {
+	dir := filepath.Dir(rel)
+	return (dir == "." || dir == "build") && strings.HasPrefix(filepath.Base(rel), "AppTocPackage.bin")
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,229 @@
+package toolkit
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeToolkit lays out the parts of a Security Toolkit installation the
+// mirrors care about.
+func fakeToolkit(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, rel := range []string{
+		"app-gen-toc", "app-write-mram", "isp_config_data.cfg",
+		"AppTocPackage.bin", "AppTocPackage.bin.sign", LockFile,
+		"utils/global-cfg.db", "utils/isp_protocol.py",
+		"build/AppTocPackage.bin", "build/images/alif-img.bin", "build/logs/run.log",
+		"build/config/app-cfg.json",
+		"cert/SBL.crt", "bin/app-device-config.bin",
+	} {
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(rel), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestNewOverlay(t *testing.T) {
+	tk := fakeToolkit(t)
+	dir := filepath.Join(t.TempDir(), "board-1")
+	if err := NewOverlay(tk, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	const (
+		copied  = "copied"
+		linked  = "linked"
+		realDir = "dir"
+		missing = "missing"
+	)
+	tests := []struct {
+		rel  string
+		want string
+	}{
+		{"app-write-mram", linked},
+		{"app-gen-toc", linked},
+		{"isp_config_data.cfg", copied},
+		{"utils", realDir},
+		{"utils/global-cfg.db", copied},
+		{"utils/isp_protocol.py", linked},
+		{"build", realDir},
+		{"build/images", realDir},
+		{"build/images/alif-img.bin", copied},
+		{"build/logs/run.log", copied},
+		{"build/config", linked},
+		{"cert/SBL.crt", copied},
+		{"bin/app-device-config.bin", copied},
+		{"AppTocPackage.bin", missing},
+		{"AppTocPackage.bin.sign", missing},
+		{"build/AppTocPackage.bin", missing},
+		{LockFile, missing},
+	}
+	for _, tt := range tests {
+		info, err := os.Lstat(filepath.Join(dir, tt.rel))
+		got := missing
+		switch {
+		case err != nil:
+		case info.Mode()&os.ModeSymlink != 0:
+			got = linked
+		case info.IsDir():
+			got = realDir
+		default:
+			got = copied
+		}
+		if got != tt.want {
+			t.Errorf("%s: %s, want %s", tt.rel, got, tt.want)
+		}
+	}
+
+	// Writing the board's copy of the database leaves the toolkit's alone.
+	if err := os.WriteFile(filepath.Join(dir, "utils", "global-cfg.db"), []byte("board-1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(tk, "utils", "global-cfg.db")); string(b) != "utils/global-cfg.db" {
+		t.Errorf("toolkit global-cfg.db changed to %q", b)
+	}
+}
+
+func TestStagedTOC(t *testing.T) {
+	tests := []struct {
+		rel  string
+		want bool
+	}{
+		{"AppTocPackage.bin", true},
+		{"AppTocPackage.bin.sign", true},
+		{"AppTocPackage.bin.crt", true},
+		{"build/AppTocPackage.bin", true},
+		{"build/images/AppTocPackage.bin", false},
+		{"app-gen-toc", false},
+		{"build/config", false},
+	}
+	for _, tt := range tests {
+		if got := stagedTOC(tt.rel); got != tt.want {
+			t.Errorf("stagedTOC(%q) = %v, want %v", tt.rel, got, tt.want)
+		}
+	}
+}
+
+// readOnly makes the tree at root read-only for the rest of the test.
+func readOnly(t *testing.T, root string) {
+	t.Helper()
+	var dirs []string
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			dirs = append(dirs, path)
+		} else if err == nil && d.Type().IsRegular() {
+			os.Chmod(path, 0444)
+		}
+		return nil
+	})
+	for _, dir := range dirs {
+		os.Chmod(dir, 0555)
+	}
+	t.Cleanup(func() {
+		for _, dir := range dirs {
+			os.Chmod(dir, 0755)
+		}
+	})
+}
+
+// snapshot lists every entry under root with its content, so a test can
+// tell whether anything in the tree changed.
+func snapshot(t *testing.T, root string) string {
+	t.Helper()
+	var b strings.Builder
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		b.WriteString(rel + "\n")
+		if d.Type().IsRegular() {
+			content, _ := os.ReadFile(path)
+			b.Write(content)
+			b.WriteString("\n")
+		}
+		return nil
+	})
+	return b.String()
+}
+
+func TestWritable(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("directory permissions do not stop this user")
+	}
+	dir := t.TempDir()
+	if !Writable(dir) {
+		t.Errorf("Writable(%s) = false for a fresh directory", dir)
+	}
+	readOnly(t, dir)
+	if Writable(dir) {
+		t.Errorf("Writable(%s) = true for a read-only directory", dir)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Writable left %d file(s) behind", len(entries))
+	}
+}
+
+func TestPrepareReadOnly(t *testing.T) {
+	tk := fakeToolkit(t)
+	readOnly(t, tk)
+	before := snapshot(t, tk)
+	work := filepath.Join(t.TempDir(), "work")
+
+	if err := Prepare(tk, work); err != nil {
+		t.Fatal(err)
+	}
+	// Everything the CLI and the tools write lands in the work directory.
+	for _, rel := range []string{"isp_config_data.cfg", "utils/global-cfg.db", "build/images/staged.bin", "build/logs/new.log", "staged_config.json"} {
+		if err := os.WriteFile(filepath.Join(work, rel), []byte("written"), 0644); err != nil {
+			t.Errorf("writing %s in the work directory: %v", rel, err)
+		}
+	}
+	if after := snapshot(t, tk); after != before {
+		t.Error("the read-only installation changed")
+	}
+
+	// A second Prepare keeps what earlier runs wrote.
+	if err := Prepare(tk, work); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(work, "isp_config_data.cfg")); string(b) != "written" {
+		t.Errorf("isp_config_data.cfg after a second Prepare = %q, want the earlier run's", b)
+	}
+}
+
+func TestPrepareIncompatible(t *testing.T) {
+	tk := fakeToolkit(t)
+	if err := os.Remove(filepath.Join(tk, "app-write-mram")); err != nil {
+		t.Fatal(err)
+	}
+	err := Prepare(tk, filepath.Join(t.TempDir(), "work"))
+	if !errors.Is(err, ErrIncompatible) || !strings.Contains(err.Error(), "app-write-mram") {
+		t.Errorf("Prepare without app-write-mram = %v, want ErrIncompatible naming it", err)
+	}
+}
+
+func TestDefaultWorkDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	a, err := DefaultWorkDir("/opt/alif/app-release-exec-linux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := DefaultWorkDir("/opt/alif/app-release-exec-linux/")
+	c, _ := DefaultWorkDir("/opt/alif/app-release-exec-linux-1.107")
+	if a != b || a == c || filepath.Dir(a) != filepath.Join(home, ".alif", "toolkit-work") {
+		t.Errorf("DefaultWorkDir = %q, %q, %q", a, b, c)
+	}
+}
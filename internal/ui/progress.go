@@ -0,0 +1,142 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"alif-cli/internal/color"
+)
+
+// progressWidth is the number of cells in the bar.
+const progressWidth = 30
+
+// ProgressBar shows current/total bytes with an ETA. Until the first
+// Update it behaves like a Spinner, so callers whose tool never reports
+// progress still get an animation.
+type ProgressBar struct {
+	msg     string
+	start   time.Time
+	out     io.Writer
+	spinner *Spinner
+
+	mu      sync.Mutex
+	active  bool
+	total   int64
+	started time.Time
+}
+
+// StartProgress starts a spinner that turns into a progress bar on the
+// first Update.
+func StartProgress(msg string) *ProgressBar {
+	return &ProgressBar{
+		msg:     msg,
+		start:   time.Now(),
+		out:     decorWriter(),
+		spinner: StartSpinner(msg),
+		active:  true,
+	}
+}
+
+// SetEstimate shows d next to the spinner until byte progress arrives,
+// after which the ETA comes from the transfer rate.
+func (p *ProgressBar) SetEstimate(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.spinner != nil {
+		p.spinner.SetEstimate(d)
+	}
+}
+
+// Update redraws the bar. A new total (e.g. the next file of a multi-file
+// write) restarts the ETA estimate.
+func (p *ProgressBar) Update(current, total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.active || total <= 0 {
+		return
+	}
+	if p.spinner != nil {
+		p.spinner.finish()
+		p.spinner = nil
+	}
+	if total != p.total {
+		p.total = total
+		p.started = time.Now()
+	}
+	if current > total {
+		current = total
+	}
+	emit(Event{Kind: EventProgress, Text: p.msg, Current: current, Total: total})
+	if p.out == nil {
+		return
+	}
+
+	filled := int(current * progressWidth / total)
+	bar := strings.Repeat("█", filled) + strings.Repeat("░", progressWidth-filled)
+	line := fmt.Sprintf("%s %3d%% %s/%s", paint(true, color.Cyan, bar), current*100/total, formatSize(current), formatSize(total))
+	if eta := p.eta(current, total); eta != "" {
+		line += " ETA " + eta
+	}
+	fmt.Fprintf(p.out, "\r\033[2K  %s %s", line, paint(true, color.Dim, p.msg))
+}
+
+// eta estimates the remaining time from the rate so far.
+func (p *ProgressBar) eta(current, total int64) string {
+	elapsed := time.Since(p.started)
+	if current <= 0 || elapsed < time.Second {
+		return ""
+	}
+	remaining := time.Duration(float64(elapsed) * float64(total-current) / float64(current))
+	return remaining.Round(time.Second).String()
+}
+
+func (p *ProgressBar) finish() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.active {
+		return false
+	}
+	p.active = false
+	if p.spinner != nil {
+		p.spinner.finish()
+	} else if p.out != nil {
+		fmt.Fprint(p.out, "\r\033[2K")
+	}
+	return true
+}
+
+// Succeed clears the bar and prints a green checkmark.
+func (p *ProgressBar) Succeed(finalMsg string) {
+	if !p.finish() {
+		return
+	}
+	if finalMsg == "" {
+		finalMsg = p.msg
+	}
+	emit(Event{Kind: EventTaskSucceed, Text: finalMsg, Elapsed: time.Since(p.start)})
+}
+
+// Fail clears the bar and prints a red cross.
+func (p *ProgressBar) Fail(finalMsg string) {
+	if !p.finish() {
+		return
+	}
+	if finalMsg == "" {
+		finalMsg = p.msg
+	}
+	emit(Event{Kind: EventTaskFail, Text: finalMsg, Elapsed: time.Since(p.start)})
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1024*1024:
+		return fmt.Sprintf("%.2f MB", float64(n)/(1024*1024))
+	case n >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(n)/1024)
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
@@ -0,0 +1,119 @@
+package ui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"alif-cli/internal/color"
+)
+
+// ExitPromptAbandoned is the process exit code used when a prompt times out.
+const ExitPromptAbandoned = 3
+
+// ErrPromptAbandoned is returned by Select, Input and Confirm when no answer
+// came within the prompt timeout. Commands exit with ExitPromptAbandoned
+// for it once their cleanup has run.
+var ErrPromptAbandoned = errors.New("prompt abandoned")
+
+// A single reader is shared by all prompts so buffered input is never lost
+// between consecutive questions.
+var stdin = bufio.NewReader(os.Stdin)
+
+// promptTimeout bounds how long a prompt waits for an answer (0 = forever).
+var promptTimeout time.Duration
+
+// SetPromptTimeout sets how long prompts wait before the command is
+// abandoned. Zero disables the timeout.
+func SetPromptTimeout(d time.Duration) {
+	promptTimeout = d
+}
+
+// readLine reads one answer, giving up after promptTimeout. On timeout it
+// reports what was being asked (and the candidates, if any) and returns
+// ErrPromptAbandoned so unattended runs never block indefinitely.
+func readLine(question string, candidates []string) (string, error) {
+	if promptTimeout <= 0 {
+		input, _ := stdin.ReadString('\n')
+		return strings.TrimSpace(input), nil
+	}
+
+	answer := make(chan string, 1)
+	go func() {
+		input, _ := stdin.ReadString('\n')
+		answer <- input
+	}()
+
+	select {
+	case input := <-answer:
+		return strings.TrimSpace(input), nil
+	case <-time.After(promptTimeout):
+		w := promptWriter()
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "  %s No answer after %s, abandoning prompt: %s\n",
+			color.Sprintf(color.Red, "✖"), promptTimeout, strings.TrimSpace(question))
+		for _, c := range candidates {
+			fmt.Fprintf(w, "      - %s\n", c)
+		}
+		return "", fmt.Errorf("%w: no answer after %s", ErrPromptAbandoned, promptTimeout)
+	}
+}
+
+// Select shows a numbered list of options and returns the zero-based index
+// of the user's choice. When prompting is not possible it fails immediately
+// instead of blocking, listing the candidates in the error followed by hint,
+// which should name the flag that disambiguates.
+func Select(title, prompt string, options []string, hint string) (int, error) {
+	if !CanPrompt() {
+		msg := fmt.Sprintf("%s (%s, cannot prompt):\n  %s",
+			strings.TrimSuffix(title, ":"), noPromptReason(), strings.Join(options, "\n  "))
+		if hint != "" {
+			msg += "\n" + hint
+		}
+		return -1, fmt.Errorf("%s", msg)
+	}
+
+	w := promptWriter()
+	fmt.Fprintln(w, title)
+	for i, o := range options {
+		fmt.Fprintf(w, "[%d] %s\n", i+1, o)
+	}
+	fmt.Fprint(w, prompt)
+
+	input, err := readLine(title, options)
+	if err != nil {
+		return -1, err
+	}
+	selection, err := strconv.Atoi(input)
+	if err != nil || selection < 1 || selection > len(options) {
+		return -1, fmt.Errorf("invalid selection")
+	}
+	return selection - 1, nil
+}
+
+// Input asks for a single line of free text.
+func Input(prompt string) (string, error) {
+	if !CanPrompt() {
+		return "", fmt.Errorf("cannot ask %q: %s", strings.TrimSpace(prompt), noPromptReason())
+	}
+	fmt.Fprint(promptWriter(), prompt)
+	return readLine(prompt, nil)
+}
+
+// Confirm asks a yes/no question; anything but y/yes counts as no.
+func Confirm(question string) (bool, error) {
+	if !CanPrompt() {
+		return false, fmt.Errorf("cannot confirm %q: %s", question, noPromptReason())
+	}
+	fmt.Fprintf(promptWriter(), "%s [y/N]: ", question)
+	answer, err := readLine(question, nil)
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes", nil
+}
@@ -0,0 +1,144 @@
+package ui
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// answer makes the prompts read from r as if stdin were a terminal, and
+// restores the real stdin when the test ends.
+func answer(t *testing.T, r io.Reader) {
+	t.Helper()
+	oldStdin, oldTTY, oldNonInteractive, oldTimeout := stdin, stdinTTY, nonInteractive, promptTimeout
+	t.Cleanup(func() {
+		stdin, stdinTTY, nonInteractive, promptTimeout = oldStdin, oldTTY, oldNonInteractive, oldTimeout
+	})
+	stdin = bufio.NewReader(r)
+	stdinTTY = true
+	nonInteractive = false
+	promptTimeout = 0
+}
+
+func TestSelect(t *testing.T) {
+	options := []string{"blinky+E7-HE", "blinky+E7-HP", "hello+E7-HE"}
+	tests := []struct {
+		input   string
+		want    int
+		wantErr bool
+	}{
+		{"1\n", 0, false},
+		{"3\n", 2, false},
+		{"  2  \n", 1, false},
+		{"2", 1, false},
+		{"0\n", -1, true},
+		{"4\n", -1, true},
+		{"two\n", -1, true},
+		{"\n", -1, true},
+		{"", -1, true},
+	}
+	for _, tt := range tests {
+		answer(t, strings.NewReader(tt.input))
+		got, err := Select("Contexts:", "Select: ", options, "")
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("Select(%q) = %d, %v; want %d, error %v", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"y\n", true},
+		{"Y\n", true},
+		{"yes\n", true},
+		{" YES \n", true},
+		{"n\n", false},
+		{"no\n", false},
+		{"\n", false},
+		{"yep\n", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		answer(t, strings.NewReader(tt.input))
+		got, err := Confirm("Erase?")
+		if err != nil || got != tt.want {
+			t.Errorf("Confirm(%q) = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestInput(t *testing.T) {
+	answer(t, strings.NewReader("  AE722F80F55D5XX  \nnext\n"))
+	got, err := Input("Device: ")
+	if err != nil || got != "AE722F80F55D5XX" {
+		t.Errorf("Input = %q, %v; want %q", got, err, "AE722F80F55D5XX")
+	}
+	// The second answer is still buffered for the next prompt.
+	got, err = Input("Again: ")
+	if err != nil || got != "next" {
+		t.Errorf("second Input = %q, %v; want %q", got, err, "next")
+	}
+}
+
+func TestPromptsCannotPrompt(t *testing.T) {
+	tests := []struct {
+		name           string
+		tty, nonInter  bool
+		wantReasonPart string
+	}{
+		{"not a terminal", false, false, "stdin is not a terminal"},
+		{"non-interactive", true, true, "non-interactive mode"},
+	}
+	for _, tt := range tests {
+		answer(t, strings.NewReader("1\n"))
+		stdinTTY, nonInteractive = tt.tty, tt.nonInter
+
+		if _, err := Select("Ports:", "Select: ", []string{"/dev/ttyACM0", "/dev/ttyACM1"}, "Pass --port <port> to choose one."); err == nil ||
+			!strings.Contains(err.Error(), tt.wantReasonPart) ||
+			!strings.Contains(err.Error(), "/dev/ttyACM1") ||
+			!strings.Contains(err.Error(), "--port") {
+			t.Errorf("%s: Select error = %v", tt.name, err)
+		}
+		if _, err := Input("Device: "); err == nil || !strings.Contains(err.Error(), tt.wantReasonPart) {
+			t.Errorf("%s: Input error = %v", tt.name, err)
+		}
+		if _, err := Confirm("Erase?"); err == nil || !strings.Contains(err.Error(), tt.wantReasonPart) {
+			t.Errorf("%s: Confirm error = %v", tt.name, err)
+		}
+	}
+}
+
+func TestPromptAbandoned(t *testing.T) {
+	prompts := map[string]func() error{
+		"Select": func() error {
+			_, err := Select("Ports:", "Select: ", []string{"a", "b"}, "")
+			return err
+		},
+		"Input": func() error {
+			_, err := Input("Device: ")
+			return err
+		},
+		"Confirm": func() error {
+			_, err := Confirm("Erase?")
+			return err
+		},
+	}
+	for name, prompt := range prompts {
+		// Nothing is ever written, so the prompt waits until it times out.
+		r, w := io.Pipe()
+		t.Cleanup(func() { w.Close() })
+		answer(t, r)
+		promptTimeout = 20 * time.Millisecond
+
+		err := prompt()
+		if !errors.Is(err, ErrPromptAbandoned) {
+			t.Errorf("%s error = %v; want ErrPromptAbandoned", name, err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Reporter receives the progress output of library code (builder, signer,
+// flasher, targets), so the command layer decides how, or whether, it is
+// rendered.
+type Reporter interface {
+	Header(title string)
+	Item(key, value string)
+	Info(msg string)
+	Warn(msg string)
+	Success(msg string)
+	// Output shows raw tool output, typically after a failure.
+	Output(text string)
+	// Debug records detail only logs show, such as tool command lines.
+	Debug(msg string)
+	// StartTask begins a long-running step, finished with Succeed or Fail.
+	StartTask(msg string) Task
+	// StartProgress begins a step that may report byte progress.
+	StartProgress(msg string) Progress
+}
+
+// Task is a long-running step started by a Reporter.
+type Task interface {
+	Succeed(finalMsg string)
+	Fail(finalMsg string)
+	// SetEstimate shows the expected total duration, from earlier runs.
+	SetEstimate(d time.Duration)
+}
+
+// Progress is a Task that can show how far along it is. Until Update is
+// called it is rendered like a plain task.
+type Progress interface {
+	Task
+	Update(current, total int64)
+}
+
+// Console renders reports with the package's terminal helpers.
+var Console Reporter = console{}
+
+// Silent discards all reports.
+var Silent Reporter = silent{}
+
+type console struct{}
+
+func (console) Header(title string)       { Header(title) }
+func (console) Item(key, value string)    { Item(key, value) }
+func (console) Info(msg string)           { Info(msg) }
+func (console) Warn(msg string)           { Warn(msg) }
+func (console) Success(msg string)        { Success(msg) }
+func (console) Output(text string)        { Output(text) }
+func (console) Debug(msg string)          { Debug(msg) }
+func (console) StartTask(msg string) Task { return StartSpinner(msg) }
+func (console) StartProgress(msg string) Progress {
+	return StartProgress(msg)
+}
+
+type silent struct{}
+
+func (silent) Header(string)         {}
+func (silent) Item(string, string)   {}
+func (silent) Info(string)           {}
+func (silent) Warn(string)           {}
+func (silent) Success(string)        {}
+func (silent) Output(string)         {}
+func (silent) Debug(string)          {}
+func (silent) StartTask(string) Task { return silentTask{} }
+func (silent) StartProgress(string) Progress {
+	return silentTask{}
+}
+
+type silentTask struct{}
+
+func (silentTask) Succeed(string)            {}
+func (silentTask) Fail(string)               {}
+func (silentTask) Update(int64, int64)       {}
+func (silentTask) SetEstimate(time.Duration) {}
+
+// Prefixed returns a Reporter for one of several jobs running at once,
+// such as one board of a batch. Every line starts with prefix, and tasks
+// print a line when they start, at each quarter of their progress and
+// when they end instead of animating, so the jobs' lines can interleave.
+func Prefixed(prefix string) Reporter {
+	return prefixed{prefix: prefix}
+}
+
+type prefixed struct {
+	prefix string
+}
+
+func (p prefixed) Header(title string)    { Info(p.prefix + title) }
+func (p prefixed) Item(key, value string) { Item(p.prefix+key, value) }
+func (p prefixed) Info(msg string)        { Info(p.prefix + msg) }
+func (p prefixed) Warn(msg string)        { Warn(p.prefix + msg) }
+func (p prefixed) Success(msg string)     { Success(p.prefix + msg) }
+func (p prefixed) Debug(msg string)       { Debug(p.prefix + msg) }
+func (p prefixed) Output(text string) {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = p.prefix + line
+	}
+	Output(strings.Join(lines, "\n"))
+}
+func (p prefixed) StartTask(msg string) Task { return p.StartProgress(msg) }
+func (p prefixed) StartProgress(msg string) Progress {
+	Info(p.prefix + msg)
+	return &prefixedTask{prefix: p.prefix, msg: msg, start: time.Now()}
+}
+
+// prefixedTask reports a task of a Prefixed reporter as plain lines.
+type prefixedTask struct {
+	prefix  string
+	msg     string
+	start   time.Time
+	quarter int64
+}
+
+func (t *prefixedTask) Succeed(finalMsg string) {
+	emit(Event{Kind: EventTaskSucceed, Text: t.prefix + finalMsg, Elapsed: time.Since(t.start)})
+}
+
+func (t *prefixedTask) Fail(finalMsg string) {
+	emit(Event{Kind: EventTaskFail, Text: t.prefix + finalMsg, Elapsed: time.Since(t.start)})
+}
+
+func (t *prefixedTask) SetEstimate(time.Duration) {}
+
+func (t *prefixedTask) Update(current, total int64) {
+	if total <= 0 {
+		return
+	}
+	quarter := current * 4 / total
+	if quarter <= t.quarter || quarter >= 4 {
+		return
+	}
+	t.quarter = quarter
+	Info(fmt.Sprintf("%s%s %d%% of %s", t.prefix, t.msg, quarter*25, formatSize(total)))
+}
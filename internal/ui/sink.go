@@ -0,0 +1,179 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"alif-cli/internal/color"
+)
+
+// EventKind says what an Event reports.
+type EventKind int
+
+const (
+	EventHeader EventKind = iota
+	EventItem
+	EventInfo
+	EventWarn
+	EventError
+	EventSuccess
+	// EventOutput is raw tool output, typically shown after a failure.
+	EventOutput
+	// EventDebug is detail only logs record, such as tool command lines.
+	EventDebug
+	EventTaskStart
+	EventTaskSucceed
+	EventTaskFail
+	EventProgress
+	// EventTable is a table already laid out in columns, one row per line.
+	EventTable
+)
+
+// Event is one step of a command's output. Every sink renders the same
+// events: the terminal animates tasks, a log writes them as plain lines.
+type Event struct {
+	Time time.Time
+	Kind EventKind
+	// Key labels an EventItem.
+	Key  string
+	Text string
+	// Elapsed is the duration of a finished task.
+	Elapsed time.Duration
+	// Current and Total are the byte counts of an EventProgress.
+	Current int64
+	Total   int64
+}
+
+// Sink renders events.
+type Sink interface {
+	Emit(e Event)
+}
+
+var (
+	sinksMu sync.Mutex
+	sinks   = []Sink{terminal{}}
+)
+
+// AddSink sends every later event to s as well as to the terminal.
+func AddSink(s Sink) {
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	sinks = append(sinks, s)
+}
+
+// emit stamps e and hands it to every sink in turn.
+func emit(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	for _, s := range sinks {
+		s.Emit(e)
+	}
+}
+
+// terminal prints events to stdout (stderr after UseStderr) with colors. Task starts and progress
+// are drawn by the Spinner and ProgressBar animations instead, and debug
+// detail is left to logs.
+type terminal struct{}
+
+func (terminal) Emit(e Event) {
+	w := outWriter()
+	switch e.Kind {
+	case EventHeader:
+		fmt.Fprintf(w, "\n%s\n", color.Sprintf(color.BoldCyan, "%s", e.Text))
+	case EventItem:
+		// Key is dim, value is white; padding keeps values aligned.
+		k := color.Sprintf(color.Dim, "  • %-12s", e.Key+":")
+		fmt.Fprintf(w, "%s %s\n", k, e.Text)
+	case EventInfo:
+		fmt.Fprintf(w, "  %s %s\n", color.Sprintf(color.Blue, "ℹ"), e.Text)
+	case EventWarn:
+		fmt.Fprintf(w, "  %s %s\n", color.Sprintf(color.Yellow, "!"), e.Text)
+	case EventError, EventTaskFail:
+		fmt.Fprintf(w, "  %s %s\n", color.Sprintf(color.Red, "✖"), e.Text)
+	case EventSuccess, EventTaskSucceed:
+		fmt.Fprintf(w, "  %s %s\n", color.Sprintf(color.Green, "✓"), e.Text)
+	case EventOutput:
+		fmt.Fprintln(w, "\n"+e.Text)
+	case EventTable:
+		fmt.Fprint(w, "\n"+e.Text)
+	}
+}
+
+// LogSink writes events as plain, timestamped lines, one per step, for
+// build logs and CI: no colors, no animation frames, and progress only at
+// every quarter.
+type LogSink struct {
+	w io.Writer
+	// quarter is the last progress quarter logged for the file of size
+	// total the running task is writing.
+	quarter int64
+	total   int64
+}
+
+// NewLogSink returns a LogSink writing to w.
+func NewLogSink(w io.Writer) *LogSink {
+	return &LogSink{w: w}
+}
+
+func (l *LogSink) Emit(e Event) {
+	var line string
+	switch e.Kind {
+	case EventHeader:
+		line = "== " + e.Text
+	case EventItem:
+		line = fmt.Sprintf("%s: %s", e.Key, e.Text)
+	case EventInfo:
+		line = "info: " + e.Text
+	case EventWarn:
+		line = "warning: " + e.Text
+	case EventError:
+		line = "error: " + e.Text
+	case EventSuccess:
+		line = "ok: " + e.Text
+	case EventDebug:
+		line = "debug: " + e.Text
+	case EventTaskStart:
+		l.quarter, l.total = 0, 0
+		line = "start: " + e.Text
+	case EventTaskSucceed:
+		line = fmt.Sprintf("done: %s (%s)", e.Text, e.Elapsed.Round(100*time.Millisecond))
+	case EventTaskFail:
+		line = fmt.Sprintf("failed: %s (%s)", e.Text, e.Elapsed.Round(100*time.Millisecond))
+	case EventProgress:
+		if e.Total <= 0 {
+			return
+		}
+		if e.Total != l.total {
+			l.quarter, l.total = 0, e.Total
+		}
+		quarter := e.Current * 4 / e.Total
+		if quarter <= l.quarter {
+			return
+		}
+		l.quarter = quarter
+		line = fmt.Sprintf("progress: %d%% of %s, %s", quarter*25, formatSize(e.Total), e.Text)
+	case EventOutput:
+		for _, out := range strings.Split(strings.TrimRight(e.Text, "\n"), "\n") {
+			l.write(e.Time, "| "+out)
+		}
+		return
+	case EventTable:
+		for _, row := range strings.Split(strings.TrimRight(e.Text, "\n"), "\n") {
+			l.write(e.Time, strings.TrimSpace(row))
+		}
+		return
+	default:
+		return
+	}
+	l.write(e.Time, line)
+}
+
+func (l *LogSink) write(t time.Time, line string) {
+	fmt.Fprintf(l.w, "%s %s\n", t.Format("2006-01-02 15:04:05.000"), line)
+}
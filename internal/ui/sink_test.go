@@ -0,0 +1,171 @@
+package ui
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTerminal makes stdout a file that counts as a terminal, and leaves
+// the terminal as the only sink. It returns a function reading what was
+// drawn on it.
+func fakeTerminal(t *testing.T) func() string {
+	t.Helper()
+	streams(t, true, false, false)
+	f, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldStdout, oldSinks := os.Stdout, sinks
+	t.Cleanup(func() {
+		os.Stdout, sinks = oldStdout, oldSinks
+		f.Close()
+	})
+	os.Stdout, sinks = f, []Sink{terminal{}}
+	return func() string {
+		data, err := os.ReadFile(f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+}
+
+// scriptedFlash reports the steps of a flash the way the flasher does,
+// waiting long enough for the spinners to draw.
+func scriptedFlash() {
+	Header("Flash Application")
+	Item("Port", "/dev/ttyACM0")
+	Item("Baud", "921600")
+	Debug("run: app-write-mram -p (in /opt/alif)")
+
+	s := StartSpinner("Resetting board...")
+	time.Sleep(150 * time.Millisecond)
+	s.Succeed("")
+
+	p := StartProgress("Writing MRAM...")
+	time.Sleep(150 * time.Millisecond)
+	for written := int64(0); written <= 256*1024; written += 32 * 1024 {
+		p.Update(written, 256*1024)
+	}
+	p.Succeed("Written alif-img.bin")
+
+	Warn("Board was not reset after the write")
+	Output("[INFO] burning: alif-img.bin\n[INFO] done\n")
+	Table([]string{"FILE", "ADDRESS"}, [][]string{{"alif-img.bin", "0x80000000"}, {"AppTocPackage.bin", "0x8057f000"}})
+	Success("Flash complete")
+}
+
+var (
+	logTime    = regexp.MustCompile(`(?m)^\d{4}-\d\d-\d\d \d\d:\d\d:\d\d\.\d{3} `)
+	logElapsed = regexp.MustCompile(`\((\d+(\.\d+)?(ms|s))+\)$`)
+)
+
+func TestLogSinkGolden(t *testing.T) {
+	screen := fakeTerminal(t)
+	var log bytes.Buffer
+	AddSink(NewLogSink(&log))
+	before := time.Now().Truncate(time.Millisecond)
+	scriptedFlash()
+
+	// Every line is stamped, in order, within the run.
+	var last time.Time
+	for _, line := range strings.Split(strings.TrimRight(log.String(), "\n"), "\n") {
+		stamp, err := time.ParseInLocation("2006-01-02 15:04:05.000", line[:min(len(line), 23)], time.Local)
+		if err != nil || stamp.Before(before) || stamp.Before(last) {
+			t.Errorf("log line %q: time %v (%v), after %v", line, stamp, err, last)
+		}
+		last = stamp
+	}
+
+	want, err := os.ReadFile(filepath.Join("testdata", "flash.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := logElapsedLines(logTime.ReplaceAllString(log.String(), "TIME ")); got != string(want) {
+		t.Errorf("log differs from testdata/flash.log:\n%s", got)
+	}
+	if strings.ContainsAny(log.String(), "\r\033") {
+		t.Errorf("log has terminal control characters:\n%q", log.String())
+	}
+
+	// The terminal got the animation, and no debug detail.
+	drawn := screen()
+	for _, want := range []string{
+		"\r\033[2K  \033[33m⠋", // first spinner frame
+		"██████████████████████████████", // full progress bar
+		"256.0 KB/256.0 KB",
+		"✓ Written alif-img.bin",
+		"[INFO] burning: alif-img.bin",
+	} {
+		if !strings.Contains(drawn, want) {
+			t.Errorf("terminal lacks %q:\n%q", want, drawn)
+		}
+	}
+	if strings.Contains(drawn, "app-write-mram") {
+		t.Errorf("terminal shows debug detail:\n%q", drawn)
+	}
+}
+
+func TestLogSinkPlainTerminal(t *testing.T) {
+	// Without a terminal nothing is animated, and the log is the same.
+	screen := fakeTerminal(t)
+	streams(t, false, false, false)
+	var log bytes.Buffer
+	AddSink(NewLogSink(&log))
+	scriptedFlash()
+
+	if drawn := screen(); strings.Contains(drawn, "\r") || !strings.Contains(drawn, "✓ Written alif-img.bin") {
+		t.Errorf("plain terminal output:\n%q", drawn)
+	}
+	want, err := os.ReadFile(filepath.Join("testdata", "flash.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := logElapsedLines(logTime.ReplaceAllString(log.String(), "TIME "))
+	if got != string(want) {
+		t.Errorf("log differs from testdata/flash.log:\n%s", got)
+	}
+}
+
+// logElapsedLines replaces the task durations in a log.
+func logElapsedLines(log string) string {
+	lines := strings.Split(log, "\n")
+	for i, line := range lines {
+		lines[i] = logElapsed.ReplaceAllString(line, "(ELAPSED)")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestLogSinkProgress(t *testing.T) {
+	var log bytes.Buffer
+	l := NewLogSink(&log)
+	at := time.Date(2026, 3, 1, 9, 30, 0, 0, time.Local)
+	for _, e := range []Event{
+		{Kind: EventTaskStart, Text: "Writing MRAM..."},
+		{Kind: EventProgress, Text: "Writing MRAM...", Current: 10, Total: 0},
+		{Kind: EventProgress, Text: "Writing MRAM...", Current: 100, Total: 1000},
+		{Kind: EventProgress, Text: "Writing MRAM...", Current: 600, Total: 1000},
+		{Kind: EventProgress, Text: "Writing MRAM...", Current: 700, Total: 1000},
+		// The next file restarts the quarters.
+		{Kind: EventProgress, Text: "Writing MRAM...", Current: 512, Total: 2048},
+		{Kind: EventProgress, Text: "Writing MRAM...", Current: 2048, Total: 2048},
+		{Kind: EventTaskFail, Text: "Write failed", Elapsed: 1234 * time.Millisecond},
+	} {
+		e.Time = at
+		l.Emit(e)
+	}
+	want := `2026-03-01 09:30:00.000 start: Writing MRAM...
+2026-03-01 09:30:00.000 progress: 50% of 1000 B, Writing MRAM...
+2026-03-01 09:30:00.000 progress: 25% of 2.0 KB, Writing MRAM...
+2026-03-01 09:30:00.000 progress: 100% of 2.0 KB, Writing MRAM...
+2026-03-01 09:30:00.000 failed: Write failed (1.2s)
+`
+	if log.String() != want {
+		t.Errorf("log =\n%s\nwant\n%s", log.String(), want)
+	}
+}
@@ -0,0 +1,113 @@
+package ui
+
+import (
+	"io"
+	"os"
+
+	"alif-cli/internal/color"
+)
+
+// Stream capabilities, detected once at startup. Each standard stream is
+// checked independently so half-redirected setups (e.g. `alif build | tee`)
+// still get sensible behavior.
+var (
+	stdinTTY  = isTerminal(os.Stdin)
+	stdoutTTY = isTerminal(os.Stdout)
+	stderrTTY = isTerminal(os.Stderr)
+)
+
+func init() {
+	// Regular output goes to stdout; keep the pipe free of escape codes.
+	if !stdoutTTY {
+		color.DisableColors()
+	}
+}
+
+// isTerminal reports whether f is attached to a character device.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// StdinIsTerminal reports whether stdin is interactive.
+func StdinIsTerminal() bool { return stdinTTY }
+
+// StdoutIsTerminal reports whether stdout is a terminal.
+func StdoutIsTerminal() bool { return stdoutTTY }
+
+// StderrIsTerminal reports whether stderr is a terminal.
+func StderrIsTerminal() bool { return stderrTTY }
+
+// nonInteractive is set by --non-interactive.
+var nonInteractive bool
+
+// SetNonInteractive disables all prompts, even when stdin is a terminal.
+func SetNonInteractive(v bool) {
+	nonInteractive = v
+}
+
+// CanPrompt reports whether interactive prompts may be shown.
+func CanPrompt() bool {
+	return stdinTTY && !nonInteractive
+}
+
+// noPromptReason explains why CanPrompt is false.
+func noPromptReason() string {
+	if nonInteractive {
+		return "non-interactive mode"
+	}
+	return "stdin is not a terminal"
+}
+
+// stdoutReserved is set by UseStderr.
+var stdoutReserved bool
+
+// UseStderr moves all regular output, animations and prompts to stderr,
+// leaving stdout to a command's machine-readable result.
+func UseStderr() {
+	stdoutReserved = true
+	if !stderrTTY {
+		color.DisableColors()
+	}
+}
+
+// outWriter returns the stream regular output is printed on.
+func outWriter() io.Writer {
+	if stdoutReserved {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// decorWriter returns the stream animated output (spinner frames) should be
+// written to. When stdout is piped but stderr is a terminal, decoration moves
+// to stderr so the pipe only receives content. Returns nil when neither
+// stream is a terminal.
+func decorWriter() io.Writer {
+	if stdoutTTY && !stdoutReserved {
+		return os.Stdout
+	}
+	if stderrTTY {
+		return os.Stderr
+	}
+	return nil
+}
+
+// promptWriter returns the stream menus and questions should be shown on.
+func promptWriter() io.Writer {
+	if stdoutReserved || (!stdoutTTY && stderrTTY) {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// paint colors text when enabled, independent of the global color setting.
+func paint(enabled bool, code, text string) string {
+	if !enabled {
+		return text
+	}
+	return code + text + color.Reset
+}
@@ -0,0 +1,81 @@
+package ui
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+// streams sets which standard streams count as terminals and whether
+// stdout is reserved, restoring them when the test ends.
+func streams(t *testing.T, stdout, stderr, reserved bool) {
+	t.Helper()
+	oldOut, oldErr, oldReserved := stdoutTTY, stderrTTY, stdoutReserved
+	t.Cleanup(func() { stdoutTTY, stderrTTY, stdoutReserved = oldOut, oldErr, oldReserved })
+	stdoutTTY, stderrTTY, stdoutReserved = stdout, stderr, reserved
+}
+
+func TestStreamWriters(t *testing.T) {
+	tests := []struct {
+		name                     string
+		stdout, stderr, reserved bool
+		out, decor, prompt       io.Writer
+	}{
+		{"both terminals", true, true, false, os.Stdout, os.Stdout, os.Stdout},
+		{"stdout piped", false, true, false, os.Stdout, os.Stderr, os.Stderr},
+		{"stderr piped", true, false, false, os.Stdout, os.Stdout, os.Stdout},
+		{"both piped", false, false, false, os.Stdout, nil, os.Stdout},
+		{"json on a terminal", true, true, true, os.Stderr, os.Stderr, os.Stderr},
+		{"json piped, stderr terminal", false, true, true, os.Stderr, os.Stderr, os.Stderr},
+		{"json, stderr piped", true, false, true, os.Stderr, nil, os.Stderr},
+	}
+	for _, tt := range tests {
+		streams(t, tt.stdout, tt.stderr, tt.reserved)
+		if got := outWriter(); got != tt.out {
+			t.Errorf("%s: outWriter = %s, want %s", tt.name, name(got), name(tt.out))
+		}
+		if got := decorWriter(); got != tt.decor {
+			t.Errorf("%s: decorWriter = %s, want %s", tt.name, name(got), name(tt.decor))
+		}
+		if got := promptWriter(); got != tt.prompt {
+			t.Errorf("%s: promptWriter = %s, want %s", tt.name, name(got), name(tt.prompt))
+		}
+	}
+}
+
+func TestCanPrompt(t *testing.T) {
+	oldTTY, oldNonInteractive := stdinTTY, nonInteractive
+	t.Cleanup(func() { stdinTTY, nonInteractive = oldTTY, oldNonInteractive })
+
+	tests := []struct {
+		tty, nonInteractive bool
+		want                bool
+		reason              string
+	}{
+		{true, false, true, ""},
+		{false, false, false, "stdin is not a terminal"},
+		{true, true, false, "non-interactive mode"},
+		{false, true, false, "non-interactive mode"},
+	}
+	for _, tt := range tests {
+		stdinTTY, nonInteractive = tt.tty, tt.nonInteractive
+		if got := CanPrompt(); got != tt.want {
+			t.Errorf("CanPrompt(tty=%v, non-interactive=%v) = %v, want %v", tt.tty, tt.nonInteractive, got, tt.want)
+		}
+		if !tt.want && noPromptReason() != tt.reason {
+			t.Errorf("noPromptReason(tty=%v, non-interactive=%v) = %q, want %q", tt.tty, tt.nonInteractive, noPromptReason(), tt.reason)
+		}
+	}
+}
+
+func name(w io.Writer) string {
+	switch w {
+	case os.Stdout:
+		return "stdout"
+	case os.Stderr:
+		return "stderr"
+	case nil:
+		return "none"
+	}
+	return "other"
+}
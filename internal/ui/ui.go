@@ -1,44 +1,58 @@
 package ui
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"text/tabwriter"
 	"time"
 
 	"alif-cli/internal/color"
+	"alif-cli/internal/eta"
 )
 
 // Header prints a bold cyan section title without "STEP:" prefix
 func Header(title string) {
-	fmt.Printf("\n%s\n", color.Sprintf(color.BoldCyan, "%s", title))
+	emit(Event{Kind: EventHeader, Text: title})
 }
 
 // Item prints a key-value pair in list format
 func Item(key, value string) {
-	// Key is dim, value is white
-	// We handle padding manually for alignment
-	k := color.Sprintf(color.Dim, "  • %-12s", key+":")
-	fmt.Printf("%s %s\n", k, value)
+	emit(Event{Kind: EventItem, Key: key, Text: value})
 }
 
 // Spinner handles loading animation
 type Spinner struct {
 	msg    string
+	out    io.Writer
 	stop   chan struct{}
 	wg     sync.WaitGroup
 	mu     sync.Mutex
 	active bool
+	start  time.Time
+	// estimate is the expected total duration (0 = unknown).
+	estimate atomic.Int64
 }
 
-// StartSpinner starts the animation in background
+// StartSpinner starts the animation in background. Frames are drawn on
+// whichever stream is a terminal; with no terminal attached nothing is
+// animated and only the final line is printed.
 func StartSpinner(msg string) *Spinner {
 	s := &Spinner{
 		msg:    msg,
+		out:    decorWriter(),
 		stop:   make(chan struct{}),
 		active: true,
+		start:  time.Now(),
+	}
+	emit(Event{Time: s.start, Kind: EventTaskStart, Text: msg})
+	if s.out != nil {
+		s.wg.Add(1)
+		go s.run()
 	}
-	s.wg.Add(1)
-	go s.run()
 	return s
 }
 
@@ -49,76 +63,121 @@ func (s *Spinner) run() {
 	t := time.NewTicker(100 * time.Millisecond)
 	defer t.Stop()
 
-	// Initial print
-	// fmt.Printf("\r  %s %s ", color.Sprintf(color.Yellow, "%s", chars[i]), color.Sprintf(color.Dim, "%s", s.msg))
-
 	for {
 		select {
 		case <-s.stop:
 			return
 		case <-t.C:
-			frame := color.Sprintf(color.Yellow, "%s", chars[i])
-			// Check msg for updates (not implemented here, dynamic msg requires mutex)
-			text := color.Sprintf(color.Dim, "%s", s.msg)
+			frame := paint(true, color.Yellow, chars[i])
+			text := paint(true, color.Dim, s.msg+s.timing())
 			// \033[2K clears line first to avoid artifacts
-			fmt.Printf("\r\033[2K  %s %s ", frame, text)
+			fmt.Fprintf(s.out, "\r\033[2K  %s %s ", frame, text)
 			i = (i + 1) % len(chars)
 		}
 	}
 }
 
-// Succeed stops spinner with green checkmark
-func (s *Spinner) Succeed(finalMsg string) {
+// SetEstimate shows d as the expected total duration next to the elapsed
+// time. Estimates are approximate and only affect the display.
+func (s *Spinner) SetEstimate(d time.Duration) {
+	s.estimate.Store(int64(d))
+}
+
+// timing renders " 40s elapsed, ~1m10s total" once a second has passed.
+func (s *Spinner) timing() string {
+	elapsed := time.Since(s.start)
+	if elapsed < time.Second {
+		return ""
+	}
+	text := fmt.Sprintf(" %s elapsed", elapsed.Round(time.Second))
+	if est := time.Duration(s.estimate.Load()); est > 0 {
+		if elapsed > est {
+			text += ", taking longer than usual"
+		} else {
+			text += fmt.Sprintf(", ~%s total (est.)", eta.Format(est))
+		}
+	}
+	return text
+}
+
+// finish stops the animation and clears the spinner line.
+func (s *Spinner) finish() bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if !s.active {
-		return
+		return false
 	}
 	s.active = false
 	close(s.stop)
 	s.wg.Wait()
 
-	fmt.Printf("\r\033[2K") // Clear line
+	if s.out != nil {
+		fmt.Fprint(s.out, "\r\033[2K") // Clear line
+	}
+	return true
+}
+
+// Succeed stops spinner with green checkmark
+func (s *Spinner) Succeed(finalMsg string) {
+	if !s.finish() {
+		return
+	}
 	if finalMsg == "" {
 		finalMsg = s.msg
 	}
-	fmt.Printf("  %s %s\n", color.Sprintf(color.Green, "✓"), finalMsg)
+	emit(Event{Kind: EventTaskSucceed, Text: finalMsg, Elapsed: time.Since(s.start)})
 }
 
 // Fail stops spinner with red cross
 func (s *Spinner) Fail(finalMsg string) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	if !s.active {
+	if !s.finish() {
 		return
 	}
-	s.active = false
-	close(s.stop)
-	s.wg.Wait()
-
-	fmt.Printf("\r\033[2K") // Clear line
 	if finalMsg == "" {
 		finalMsg = s.msg
 	}
-	fmt.Printf("  %s %s\n", color.Sprintf(color.Red, "✖"), finalMsg)
+	emit(Event{Kind: EventTaskFail, Text: finalMsg, Elapsed: time.Since(s.start)})
 }
 
 // Info prints a simple info line (e.g. for sub-steps or logs)
 func Info(msg string) {
-	fmt.Printf("  %s %s\n", color.Sprintf(color.Blue, "ℹ"), msg)
+	emit(Event{Kind: EventInfo, Text: msg})
 }
 
 // Warn prints a warning line
 func Warn(msg string) {
-	fmt.Printf("  %s %s\n", color.Sprintf(color.Yellow, "!"), msg)
+	emit(Event{Kind: EventWarn, Text: msg})
 }
 
 // Error prints error line
 func Error(msg string) {
-	fmt.Printf("  %s %s\n", color.Sprintf(color.Red, "✖"), msg)
+	emit(Event{Kind: EventError, Text: msg})
 }
 
 // Success prints success line
 func Success(msg string) {
-	fmt.Printf("  %s %s\n", color.Sprintf(color.Green, "✓"), msg)
+	emit(Event{Kind: EventSuccess, Text: msg})
+}
+
+// Output shows raw tool output after a blank line.
+func Output(text string) {
+	emit(Event{Kind: EventOutput, Text: text})
+}
+
+// Table prints rows under header in aligned columns, after a blank line.
+func Table(header []string, rows [][]string) {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 3, ' ', 0)
+	fmt.Fprintf(w, "  %s\n", strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintf(w, "  %s\n", strings.Join(row, "\t"))
+	}
+	w.Flush()
+	emit(Event{Kind: EventTable, Text: buf.String()})
+}
+
+// Debug records detail, such as a tool's command line, in logs only; the
+// terminal does not show it.
+func Debug(msg string) {
+	emit(Event{Kind: EventDebug, Text: msg})
 }